@@ -0,0 +1,246 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// AlertSettings defines the alerting preferences of an entry.
+type AlertSettings struct {
+	// FailureThreshold is the number of consecutive failed executions
+	// of the entry before alerting. Zero means the default threshold.
+	FailureThreshold int `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty"`
+	// Channel is the notification channel the alerts of the entry are
+	// routed to. Empty means the default channel.
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+}
+
+// validateAlertSettings returns ErrMalformedEntry
+// if the given alerting preferences are not valid.
+func validateAlertSettings(s *AlertSettings) error {
+	if s == nil {
+		return nil
+	}
+	if s.FailureThreshold < 0 {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// Alert defines a notification about the failures of the jobs of an entry.
+type Alert struct {
+	Type    CronType `json:"type"`
+	EntryID string   `json:"entry_id"`
+	TeamID  string   `json:"team_id"`
+	// Channel is the notification channel of the entry,
+	// empty for the default one.
+	Channel string `json:"channel,omitempty"`
+	// Failures is the number of consecutive failed executions.
+	Failures int `json:"failures"`
+	// Error is the error returned by the last execution.
+	Error string `json:"error,omitempty"`
+	// Resolved is true when the entry succeeds again after alerting.
+	Resolved bool `json:"resolved"`
+}
+
+// Notifier defines the services needed to notify the alerts
+// of the entries, e.g. to the on-call of the owners of the entries.
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// DefaultAlertFailureThreshold is the number of consecutive failures before
+// alerting of the entries that don't define their own threshold, when no
+// AlertFailureThreshold is configured.
+const DefaultAlertFailureThreshold = 1
+
+// WithNotifier makes crontinuous notify the given notifier when the jobs of
+// an entry fail as many consecutive times as the threshold of the entry,
+// or the default threshold, and when they succeed again.
+func WithNotifier(n Notifier, defaultThreshold int) Option {
+	return func(c *Crontinuous) {
+		if defaultThreshold <= 0 {
+			defaultThreshold = DefaultAlertFailureThreshold
+		}
+		c.alerts = &alertTracker{
+			notifier:  n,
+			threshold: defaultThreshold,
+			failures:  map[alertKey]int{},
+			log:       c.log,
+		}
+	}
+}
+
+type alertKey struct {
+	typ CronType
+	id  string
+}
+
+// alertTracker counts the consecutive failures of the entries.
+type alertTracker struct {
+	notifier  Notifier
+	threshold int
+	failures  map[alertKey]int
+	mux       sync.Mutex
+	log       *logrus.Logger
+}
+
+// record registers the result of an execution of an entry and returns the
+// alert to notify, if any.
+func (t *alertTracker) record(a Alert, threshold int, err error) (Alert, bool) {
+	if threshold <= 0 {
+		threshold = t.threshold
+	}
+	key := alertKey{typ: a.Type, id: a.EntryID}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	failures := t.failures[key]
+	if err == nil {
+		delete(t.failures, key)
+		a.Failures = failures
+		a.Resolved = true
+		return a, failures >= threshold
+	}
+	failures++
+	t.failures[key] = failures
+	a.Failures = failures
+	a.Error = err.Error()
+	// Only the failure reaching the threshold is notified,
+	// so a failing entry does not alert on every execution.
+	return a, failures == threshold
+}
+
+func (t *alertTracker) notify(a Alert) {
+	if err := t.notifier.Notify(a); err != nil {
+		t.log.Errorf("error notifying alert of %s entry %s: %v", a.Type, a.EntryID, err)
+	}
+}
+
+// alertingJob wraps the job of an entry so the
+// failures of its executions are notified.
+type alertingJob struct {
+	entryJob
+	settings AlertSettings
+	alerts   *alertTracker
+}
+
+func (j *alertingJob) run(ctx context.Context) error {
+	err := j.entryJob.run(ctx)
+	// The executions cancelled by a stop are neither successes nor failures.
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	a := Alert{
+		Type:    j.cronType(),
+		EntryID: j.entryID(),
+		TeamID:  j.team(),
+		Channel: j.settings.Channel,
+	}
+	if a, ok := j.alerts.record(a, j.settings.FailureThreshold, err); ok {
+		j.alerts.notify(a)
+	}
+	return err
+}
+
+// notifyTimeout is the maximum time to wait
+// for the response of a webhook.
+const notifyTimeout = 10 * time.Second
+
+// WebhookNotifier notifies the alerts by sending them, encoded in JSON, in
+// the body of a POST request to the webhook of their channel.
+type WebhookNotifier struct {
+	defaultURL string
+	channels   map[string]string
+	client     *http.Client
+	secret     []byte
+}
+
+// WebhookOption configures optional behaviour of the WebhookNotifier.
+type WebhookOption func(*WebhookNotifier)
+
+// WithWebhookSecret makes the notifier sign the requests with the given
+// secret, so the receivers can verify they were sent by crontinuous. See
+// SignWebhook.
+func WithWebhookSecret(secret string) WebhookOption {
+	return func(n *WebhookNotifier) {
+		n.secret = []byte(secret)
+	}
+}
+
+// NewWebhookNotifier returns a notifier sending the alerts to the webhooks of
+// the given channels, as a map from the name of the channel to the URL of its
+// webhook. The alerts without channel or with an unknown channel are sent to
+// the given default webhook.
+func NewWebhookNotifier(defaultURL string, channels map[string]string, opts ...WebhookOption) *WebhookNotifier {
+	n := &WebhookNotifier{
+		defaultURL: defaultURL,
+		channels:   channels,
+		client:     &http.Client{Timeout: notifyTimeout},
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Notify sends the given alert to the webhook of its channel.
+func (n *WebhookNotifier) Notify(a Alert) error {
+	url, ok := n.channels[a.Channel]
+	if !ok {
+		url = n.defaultURL
+	}
+	if url == "" {
+		return fmt.Errorf("no webhook for channel %q", a.Channel)
+	}
+	return n.post(url, a)
+}
+
+// NotifyDivergence sends the given divergence to the default webhook.
+func (n *WebhookNotifier) NotifyDivergence(d Divergence) error {
+	if n.defaultURL == "" {
+		return errors.New("no default webhook")
+	}
+	return n.post(n.defaultURL, d)
+}
+
+// post sends the given payload, encoded in JSON, to the given webhook.
+func (n *WebhookNotifier) post(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != nil {
+		SignWebhook(req.Header, n.secret, body, time.Now())
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body) // nolint
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
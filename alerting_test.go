@@ -0,0 +1,127 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockNotifier struct {
+	alerts []Alert
+}
+
+func (m *mockNotifier) Notify(a Alert) error {
+	m.alerts = append(m.alerts, a)
+	return nil
+}
+
+func TestAlertingJob(t *testing.T) {
+	fail := errors.New("vulcan-api unavailable")
+	tests := []struct {
+		name     string
+		alerting *AlertSettings
+		results  []error
+		want     []Alert
+	}{
+		{
+			name:    "DefaultThreshold",
+			results: []error{nil, fail, fail, fail, fail, nil},
+			want: []Alert{
+				{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Failures: 2, Error: fail.Error()},
+				{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Failures: 4, Resolved: true},
+			},
+		},
+		{
+			name:     "EntryThresholdAndChannel",
+			alerting: &AlertSettings{FailureThreshold: 3, Channel: "team-a"},
+			results:  []error{fail, fail, nil, fail, fail, fail, nil},
+			want: []Alert{
+				{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Channel: "team-a", Failures: 3, Error: fail.Error()},
+				{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Channel: "team-a", Failures: 3, Resolved: true},
+			},
+		},
+		{
+			name:    "IgnoresCancellations",
+			results: []error{fail, context.Canceled, fail},
+			want: []Alert{
+				{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Failures: 2, Error: fail.Error()},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []error
+			creator := func(string, string) error {
+				err := results[0]
+				results = results[1:]
+				return err
+			}
+			notifier := &mockNotifier{}
+			c := NewCrontinuous(Config{}, logrus.New(), &mockScanCreator{creator: creator}, nil, nil, nil,
+				WithNotifier(notifier, 2))
+
+			job := c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{Alerting: tt.alerting}}))
+			results = tt.results
+			for range tt.results {
+				job.Run()
+			}
+
+			if diff := cmp.Diff(tt.want, notifier.alerts); diff != "" {
+				t.Errorf("alerts diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := "secret"
+	received := map[string][]Alert{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading alert: %v", err)
+		}
+		if err := VerifyWebhook(r.Header, []byte(secret), body, time.Now(), time.Minute); err != nil {
+			t.Errorf("verifying signature: %v", err)
+		}
+		var a Alert
+		if err := json.Unmarshal(body, &a); err != nil {
+			t.Errorf("decoding alert: %v", err)
+		}
+		received[r.URL.Path] = append(received[r.URL.Path], a)
+	}))
+	defer srv.Close()
+
+	n := NewWebhookNotifier(srv.URL+"/oncall", map[string]string{"team-a": srv.URL + "/team-a"},
+		WithWebhookSecret(secret))
+	alerts := []Alert{
+		{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Channel: "team-a", Failures: 1},
+		{Type: ReportCronType, EntryID: "t2", TeamID: "t2", Failures: 1},
+		{Type: ScanCronType, EntryID: "p3", TeamID: "t3", Channel: "unknown", Failures: 1},
+	}
+	for _, a := range alerts {
+		if err := n.Notify(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string][]Alert{
+		"/team-a": {alerts[0]},
+		"/oncall": {alerts[1], alerts[2]},
+	}
+	if diff := cmp.Diff(want, received); diff != "" {
+		t.Errorf("received alerts diff: %s", diff)
+	}
+}
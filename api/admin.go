@@ -0,0 +1,131 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// adminAuth wraps a handler so it is only executed when the request
+// carries the configured admin token as a bearer token. If no admin token
+// is configured the handler is executed without authentication.
+func adminAuth(token string, h httprouter.Handle) httprouter.Handle {
+	if token == "" {
+		return h
+	}
+	want := []byte(fmt.Sprintf("Bearer %s", token))
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r, ps)
+	}
+}
+
+// h.addDebugRoutes mounts the pprof and runtime diagnostics endpoints.
+func (h *handler) addDebugRoutes(router *httprouter.Router, adminToken string) {
+	router.GET("/debug/pprof/*item", adminAuth(adminToken, pprofHandler))
+	router.GET("/debug/runtime", adminAuth(adminToken, h.runtimeHandler))
+}
+
+// h.addAdminRoutes mounts the endpoints used to operate the scheduler.
+func (h *handler) addAdminRoutes(router routes, adminToken string) {
+	router.POST("/admin/restart", adminAuth(adminToken, h.restartHandler))
+	router.POST("/admin/history/prune", adminAuth(adminToken, h.writes(h.pruneHistoryHandler)))
+	router.POST("/admin/flush", adminAuth(adminToken, h.writes(h.flushHandler)))
+	router.POST("/admin/suppress", adminAuth(adminToken, h.writes(h.suppressHandler)))
+	router.GET("/admin/suppress", adminAuth(adminToken, h.getSuppressionsHandler))
+	router.DELETE("/admin/suppress/:id", adminAuth(adminToken, h.writes(h.unsuppressHandler)))
+	router.GET("/internal/divergence", adminAuth(adminToken, h.divergenceHandler))
+}
+
+func (h *handler) restartHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.cron.Restart(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// pruneHistoryHandler applies the retention policy to the history now,
+// without waiting for the next pruning.
+func (h *handler) pruneHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	res, err := h.cron.PruneHistory()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// flushResponse defines the types of entries saved by a flush.
+type flushResponse struct {
+	Flushed []crontinuous.CronType `json:"flushed" yaml:"flushed"`
+}
+
+// flushHandler saves now the entries pending to be saved, without waiting
+// for the save interval.
+func (h *handler) flushHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	flushed, err := h.cron.Flush()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := encodeResponse(w, r, flushResponse{Flushed: flushed}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// divergenceHandler returns the differences between the stores and the
+// entries in memory found by the last check, checking them now if they were
+// never checked.
+func (h *handler) divergenceHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	d, ok := h.cron.Divergence()
+	if !ok {
+		var err error
+		if d, err = h.cron.CheckDivergence(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := encodeResponse(w, r, d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func pprofHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	switch ps.ByName("item") {
+	case "/cmdline":
+		pprof.Cmdline(w, r)
+	case "/profile":
+		pprof.Profile(w, r)
+	case "/symbol":
+		pprof.Symbol(w, r)
+	case "/trace":
+		pprof.Trace(w, r)
+	default:
+		// Index also serves the named profiles like heap or goroutine.
+		pprof.Index(w, r)
+	}
+}
+
+func (h *handler) runtimeHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	d := h.cron.Diagnostics()
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(&d)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
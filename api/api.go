@@ -0,0 +1,236 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+// Package api implements the HTTP API of crontinuous as an http.Handler, so
+// it can be mounted by other services under their own server, middlewares
+// and TLS termination.
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// Options defines the optional components and settings of the API. The
+// endpoints of the components not provided respond with a 404 status.
+type Options struct {
+	// GitSyncer is reported by the git sync status endpoint.
+	GitSyncer *crontinuous.GitSyncer
+	// TeamLister is used to compute the coverage of all the teams.
+	TeamLister crontinuous.TeamLister
+	// Provisioner is used by the provisioning endpoint.
+	Provisioner *crontinuous.Provisioner
+	// AdminToken is the bearer token required by the admin and debug
+	// endpoints. If empty they are not authenticated.
+	AdminToken string
+	// EnableDebug exposes the pprof and runtime diagnostics endpoints.
+	EnableDebug bool
+	// Timeouts are the times the endpoints have to start responding.
+	// The pprof and runtime diagnostics endpoints have no timeout.
+	Timeouts Timeouts
+	// IdempotencyWindow is the time the responses to the POST requests with
+	// an Idempotency-Key header are replayed to the retries of the requests.
+	// If zero the requests are not deduplicated.
+	IdempotencyWindow time.Duration
+}
+
+// handler holds the dependencies of the endpoints.
+type handler struct {
+	cron        *crontinuous.Crontinuous
+	gitSyncer   *crontinuous.GitSyncer
+	teamLister  crontinuous.TeamLister
+	provisioner *crontinuous.Provisioner
+}
+
+// NewHandler returns an http.Handler serving the API
+// of the given crontinuous instance.
+func NewHandler(c *crontinuous.Crontinuous, opts Options) http.Handler {
+	h := &handler{
+		cron:        c,
+		gitSyncer:   opts.GitSyncer,
+		teamLister:  opts.TeamLister,
+		provisioner: opts.Provisioner,
+	}
+
+	router := routes{Router: httprouter.New(), timeouts: opts.Timeouts}
+
+	router.GET("/healthcheck", h.status)
+	router.GET("/readyz", h.readyzHandler)
+
+	// Scan scheduling endpoints.
+	router.GET("/entries", h.getScanSchedulesHandler)
+	router.POST("/entries", h.writes(h.scanBulkSettingsHandler))
+	router.GET("/entries/:programID", h.getScanScheduleByIDHandler)
+	router.DELETE("/entries", h.writes(h.bulkRemoveScanSchedulesHandler))
+	router.DELETE("/entries/:programID", h.writes(h.removeScanScheduleHandler))
+	router.POST("/settings/:programID/:teamID", h.writes(h.scanSettingHandler))
+	router.POST("/entries/:programID", h.lookupScanSchedulesHandler)
+	router.POST("/entries/:programID/run", h.writes(h.runScanScheduleHandler))
+	router.GET("/entries/:programID/history", h.getScanHistoryHandler)
+	router.POST("/entries/:programID/revert", h.writes(h.revertScanScheduleHandler))
+	router.POST("/entries/:programID/transfer", h.writes(h.transferScanScheduleHandler))
+
+	// Report scheduling endpoints.
+	router.GET("/report/entries", h.getReportSchedulesHandler)
+	router.POST("/report/entries", h.writes(h.reportBulkSettingsHandler))
+	router.GET("/report/entries/:reportID", h.getReportScheduleByIDHandler)
+	router.DELETE("/report/entries", h.writes(h.bulkRemoveReportSchedulesHandler))
+	router.DELETE("/report/entries/:reportID", h.writes(h.removeReportScheduleHandler))
+	router.POST("/report/settings/:teamID", h.writes(h.reportSettingHandler))
+	router.POST("/report/entries/:reportID", h.lookupReportSchedulesHandler)
+	router.POST("/report/entries/:reportID/run", h.writes(h.runReportScheduleHandler))
+	router.GET("/report/entries/:reportID/history", h.getReportHistoryHandler)
+	router.POST("/report/entries/:reportID/revert", h.writes(h.revertReportScheduleHandler))
+	router.POST("/report/derive", h.writes(h.deriveReportSchedulesHandler))
+
+	// Team scan scheduling endpoints.
+	router.GET("/team-scan/entries", h.getTeamScanSchedulesHandler)
+	router.POST("/team-scan/entries", h.writes(h.teamScanBulkSettingsHandler))
+	router.GET("/team-scan/entries/:teamID", h.getTeamScanScheduleByIDHandler)
+	router.DELETE("/team-scan/entries", h.writes(h.bulkRemoveTeamScanSchedulesHandler))
+	router.DELETE("/team-scan/entries/:teamID", h.writes(h.removeTeamScanScheduleHandler))
+	router.POST("/team-scan/settings/:teamID", h.writes(h.teamScanSettingHandler))
+	router.POST("/team-scan/entries/:teamID", h.lookupTeamScanSchedulesHandler)
+	router.POST("/team-scan/entries/:teamID/run", h.writes(h.runTeamScanScheduleHandler))
+	router.GET("/team-scan/entries/:teamID/history", h.getTeamScanHistoryHandler)
+	router.POST("/team-scan/entries/:teamID/revert", h.writes(h.revertTeamScanScheduleHandler))
+
+	// Command scheduling endpoints.
+	router.GET("/command/entries", h.getCommandSchedulesHandler)
+	router.POST("/command/entries", h.writes(h.commandBulkSettingsHandler))
+	router.GET("/command/entries/:id", h.getCommandScheduleByIDHandler)
+	router.DELETE("/command/entries", h.writes(h.bulkRemoveCommandSchedulesHandler))
+	router.DELETE("/command/entries/:id", h.writes(h.removeCommandScheduleHandler))
+	router.POST("/command/settings/:id", h.writes(h.commandSettingHandler))
+	router.POST("/command/entries/:id", h.lookupCommandSchedulesHandler)
+	router.POST("/command/entries/:id/run", h.writes(h.runCommandScheduleHandler))
+	router.GET("/command/entries/:id/history", h.getCommandHistoryHandler)
+	router.POST("/command/entries/:id/revert", h.writes(h.revertCommandScheduleHandler))
+	router.GET("/command/entries/:id/executions", h.getCommandExecutionsHandler)
+
+	// Templates
+	router.GET("/templates", h.getTemplatesHandler)
+	router.GET("/templates/:name", h.getTemplateHandler)
+	router.PUT("/templates/:name", h.writes(h.saveTemplateHandler))
+	router.DELETE("/templates/:name", h.writes(h.removeTemplateHandler))
+
+	// Pause windows
+	router.GET("/pause-windows", h.getPauseWindowsHandler)
+	router.GET("/pause-windows/:name", h.getPauseWindowHandler)
+	router.PUT("/pause-windows/:name", h.writes(h.savePauseWindowHandler))
+	router.DELETE("/pause-windows/:name", h.writes(h.removePauseWindowHandler))
+
+	// Team defaults
+	router.GET("/team-defaults", h.getAllTeamDefaultsHandler)
+	router.GET("/teams/:teamID/defaults", h.getTeamDefaultsHandler)
+	router.PUT("/teams/:teamID/defaults", h.writes(h.saveTeamDefaultsHandler))
+	router.DELETE("/teams/:teamID/defaults", h.writes(h.removeTeamDefaultsHandler))
+
+	// Spec rules
+	router.GET("/spec-rules", h.getSpecRulesHandler)
+	router.POST("/spec-rules/preview", h.previewSpecRulesHandler)
+
+	// Spec aliases
+	router.GET("/spec-aliases", h.getSpecAliasesHandler)
+
+	// Whitelists
+	router.POST("/config/whitelists/preview", h.previewWhitelistsHandler)
+
+	router.GET("/executions", h.executionsHandler)
+	router.POST("/executions/:id/replay", h.writes(h.replayExecutionHandler))
+	router.GET("/canaries/:id", h.canaryHandler)
+	router.GET("/operations/:id", h.operationHandler)
+	router.DELETE("/operations/:id", h.writes(h.cancelOperationHandler))
+	router.GET("/teams/:teamID/summary", h.teamSummaryHandler)
+	router.GET("/teams/:teamID/reports", h.teamReportSchedulesHandler)
+	router.POST("/teams/entries", h.teamsEntriesHandler)
+
+	router.GET("/snapshot", h.snapshotHandler)
+	router.GET("/simulate", h.simulateHandler)
+	router.GET("/calendar", h.calendarHandler)
+	router.GET("/git-sync/status", h.gitSyncStatusHandler)
+	router.GET("/analysis/coverage", h.coverageHandler)
+	router.GET("/analysis/scan-density", h.scanDensityHandler)
+	router.POST("/analysis/coverage", h.uploadedTeamsCoverageHandler)
+	router.POST("/provision/:teamID", h.writes(h.provisionHandler))
+
+	// Staging
+	router.GET("/manifest", h.manifestHandler)
+	router.GET("/staging", h.getStagedHandler)
+	router.PUT("/staging", h.writes(h.stageHandler))
+	router.DELETE("/staging", h.writes(h.discardStagedHandler))
+	router.GET("/staging/changes", h.stagedChangesHandler)
+	router.GET("/staging/simulate", h.simulateStagedHandler)
+	router.POST("/staging/swap", h.writes(h.swapStagedHandler))
+
+	h.addAdminRoutes(router, opts.AdminToken)
+	if opts.EnableDebug {
+		// The profiles take longer than the timeouts of the requests.
+		h.addDebugRoutes(router.Router, opts.AdminToken)
+	}
+	return withRequestID(h.whileLoading(withIdempotency(opts.IdempotencyWindow, router.Router)))
+}
+
+// writes wraps the given handler of an endpoint changing the entries, or
+// executing them, so it is rejected with a 403 status if the instance is a
+// read-only replica.
+func (h *handler) writes(next httprouter.Handle) httprouter.Handle {
+	if !h.cron.ReadOnly() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		writeError(w, crontinuous.ErrReadOnlyReplica)
+	}
+}
+
+// maxRequestIDLength is the maximum length of the inbound request IDs.
+const maxRequestIDLength = 128
+
+// withRequestID wraps the given handler so the context of every request
+// carries the ID in its X-Request-ID header, or a new one if it has none or
+// it is not valid. The ID is returned in the same header of the response, so
+// the callers can correlate their logs with the ones of crontinuous.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(crontinuous.RequestIDHeader)
+		if !validRequestID(id) {
+			id = crontinuous.NewRequestID()
+		}
+		w.Header().Set(crontinuous.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(crontinuous.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID returns true if the given ID is not empty, not too long
+// and only contains printable ASCII characters other than spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// whileLoading wraps the given handler so, until crontinuous is ready, the
+// requests are rejected with a 503 status, except the health and readiness
+// checks. This way the API can be served while the entries are loaded
+// without exposing incomplete entries or losing changes.
+func (h *handler) whileLoading(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.cron.Ready() && r.URL.Path != "/healthcheck" && r.URL.Path != "/readyz" {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Loading entries", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
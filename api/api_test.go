@@ -0,0 +1,2451 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/yaml.v2"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/client"
+)
+
+type memStore struct {
+	scans   map[string]crontinuous.ScanEntry
+	reports map[string]crontinuous.ReportEntry
+}
+
+func (m *memStore) GetScanEntries() (map[string]crontinuous.ScanEntry, error) {
+	return m.scans, nil
+}
+
+func (m *memStore) SaveScanEntries(entries map[string]crontinuous.ScanEntry) error {
+	m.scans = entries
+	return nil
+}
+
+func (m *memStore) GetReportEntries() (map[string]crontinuous.ReportEntry, error) {
+	return m.reports, nil
+}
+
+func (m *memStore) SaveReportEntries(entries map[string]crontinuous.ReportEntry) error {
+	m.reports = entries
+	return nil
+}
+
+func TestNewHandler_Mounted(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+
+	mux := http.NewServeMux()
+	mux.Handle("/scheduler/", http.StripPrefix("/scheduler", NewHandler(c, Options{})))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The entries are not served until they are loaded.
+	for _, path := range []string{"/scheduler/entries", "/scheduler/readyz"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s status before starting got %d, want %d", path, resp.StatusCode, http.StatusServiceUnavailable)
+		}
+	}
+
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	resp, err := http.Get(srv.URL + "/scheduler/entries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var entries []crontinuous.ScanEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ProgramID != "p1" {
+		t.Errorf("entries got %+v, want entry p1", entries)
+	}
+
+	// The endpoints of the components not provided are not found.
+	resp, err = http.Post(srv.URL+"/scheduler/provision/t1", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("provision status got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestLookupHandler(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "30 * * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	found, missing, err := client.NewClient(srv.URL).LookupScanEntries(context.Background(), []string{"p2", "p3", "p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFound := []crontinuous.ScanEntry{store.scans["p2"], store.scans["p1"]}
+	if diff := cmp.Diff(wantFound, found); diff != "" {
+		t.Errorf("found entries diff: %s", diff)
+	}
+	if diff := cmp.Diff([]string{"p3"}, missing); diff != "" {
+		t.Errorf("missing entries diff: %s", diff)
+	}
+}
+
+func TestScheduleStatus(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	cfg := crontinuous.Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1"}}
+	c := crontinuous.NewCrontinuous(cfg, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		programID, teamID string
+		want              saveResponse
+	}{
+		{
+			programID: "p1",
+			teamID:    "t1",
+			want: saveResponse{
+				ID:             "p1",
+				ScheduleStatus: crontinuous.ScheduleStatus{Scheduled: true},
+				Message:        "stored and scheduled",
+			},
+		},
+		{
+			programID: "p2",
+			teamID:    "t2",
+			want: saveResponse{
+				ID:             "p2",
+				ScheduleStatus: crontinuous.ScheduleStatus{UnscheduledReason: crontinuous.UnscheduledTeamNotWhitelisted},
+				Message:        "stored but not scheduled (team not whitelisted)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+"/settings/"+tt.programID+"/"+tt.teamID, "application/json",
+			strings.NewReader(`{"str": "0 * * * *"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got saveResponse
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("save response of %s diff: %s", tt.programID, diff)
+		}
+
+		resp, err = http.Get(srv.URL + "/entries/" + tt.programID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entry scanEntryState
+		err = json.NewDecoder(resp.Body).Decode(&entry)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.ProgramID != tt.programID || entry.ScheduleStatus != tt.want.ScheduleStatus {
+			t.Errorf("entry got %+v, want program %s with status %+v", entry, tt.programID, tt.want.ScheduleStatus)
+		}
+		// Only the scheduled entries are executed next.
+		if (entry.NextRun != nil) != entry.Scheduled || entry.Paused || entry.LastResult != nil {
+			t.Errorf("entry got state %+v, want the next run only if scheduled", entry.EntryState)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "Inbound", header: "caller-42", want: "caller-42"},
+		{name: "Missing"},
+		{name: "Invalid", header: "with spaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/healthcheck", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.header != "" {
+				req.Header.Set(crontinuous.RequestIDHeader, tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			got := resp.Header.Get(crontinuous.RequestIDHeader)
+			if tt.want != "" && got != tt.want {
+				t.Errorf("want request ID %q, got %q", tt.want, got)
+			}
+			if got == "" || got == tt.header && tt.want == "" {
+				t.Errorf("want new request ID, got %q", got)
+			}
+		})
+	}
+}
+
+type memTemplates struct {
+	templates map[string]crontinuous.Template
+}
+
+func (m *memTemplates) GetTemplates() (map[string]crontinuous.Template, error) {
+	return m.templates, nil
+}
+
+func (m *memTemplates) SaveTemplates(templates map[string]crontinuous.Template) error {
+	m.templates = templates
+	return nil
+}
+
+func TestTemplates(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithTemplates(&memTemplates{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	cli := client.NewClient(srv.URL)
+	ctx := context.Background()
+
+	weekly := crontinuous.Template{Name: "weekly-report", CronSpec: "0 8 * * 1"}
+	if err := cli.SaveTemplate(ctx, weekly); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SaveTemplate(ctx, crontinuous.Template{Name: "weekly-report", CronSpec: "invalid"}); err != crontinuous.ErrMalformedTemplate {
+		t.Errorf("want ErrMalformedTemplate, got %v", err)
+	}
+	if _, err := cli.GetTemplate(ctx, "unknown"); err != crontinuous.ErrTemplateNotFound {
+		t.Errorf("want ErrTemplateNotFound, got %v", err)
+	}
+
+	entry := crontinuous.ReportEntry{TeamID: "t1", EntrySettings: crontinuous.EntrySettings{Template: weekly.Name}}
+	if err := cli.SaveReportEntry(ctx, entry); err != nil {
+		t.Fatal(err)
+	}
+	weekly.CronSpec = "0 9 * * 1"
+	if err := cli.SaveTemplate(ctx, weekly); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cli.GetReportEntry(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CronSpec != weekly.CronSpec || got.Template != weekly.Name {
+		t.Errorf("want the entry to take the spec of the template, got %+v", got)
+	}
+
+	templates, err := cli.ListTemplates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]crontinuous.Template{weekly}, templates); diff != "" {
+		t.Errorf("templates diff: %s", diff)
+	}
+	if err := cli.RemoveTemplate(ctx, weekly.Name); err != crontinuous.ErrTemplateInUse {
+		t.Errorf("want ErrTemplateInUse, got %v", err)
+	}
+}
+
+type memPauseWindows struct {
+	windows map[string]crontinuous.PauseWindow
+}
+
+func (m *memPauseWindows) GetPauseWindows() (map[string]crontinuous.PauseWindow, error) {
+	return m.windows, nil
+}
+
+func (m *memPauseWindows) SavePauseWindows(windows map[string]crontinuous.PauseWindow) error {
+	m.windows = windows
+	return nil
+}
+
+func TestPauseWindows(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithPauseWindows(&memPauseWindows{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	cli := client.NewClient(srv.URL)
+	ctx := context.Background()
+
+	freeze := crontinuous.PauseWindow{Name: "freeze", Teams: []string{"t1"}, CronSpec: "* * * * *",
+		Duration: crontinuous.Duration(time.Hour)}
+	if err := cli.SavePauseWindow(ctx, freeze); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SavePauseWindow(ctx, crontinuous.PauseWindow{Name: "freeze", CronSpec: "invalid"}); err != crontinuous.ErrMalformedPauseWindow {
+		t.Errorf("want ErrMalformedPauseWindow, got %v", err)
+	}
+	windows, err := cli.ListPauseWindows(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]crontinuous.PauseWindow{freeze}, windows); diff != "" {
+		t.Errorf("pause windows diff: %s", diff)
+	}
+
+	resp, err := http.Get(srv.URL + "/pause-windows/freeze")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var status struct {
+		Active bool `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Active {
+		t.Error("want the window active")
+	}
+	if err := cli.RunScanEntry(ctx, "p1"); err != crontinuous.ErrTeamNotAllowed {
+		t.Errorf("want ErrTeamNotAllowed, got %v", err)
+	}
+
+	if err := cli.RemovePauseWindow(ctx, freeze.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.RemovePauseWindow(ctx, freeze.Name); err != crontinuous.ErrPauseWindowNotFound {
+		t.Errorf("want ErrPauseWindowNotFound, got %v", err)
+	}
+}
+
+type memTeamDefaults struct {
+	defaults map[string]crontinuous.TeamDefaults
+}
+
+func (m *memTeamDefaults) GetTeamDefaults() (map[string]crontinuous.TeamDefaults, error) {
+	return m.defaults, nil
+}
+
+func (m *memTeamDefaults) SaveTeamDefaults(defaults map[string]crontinuous.TeamDefaults) error {
+	m.defaults = defaults
+	return nil
+}
+
+func TestTeamDefaults(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithTeamDefaults(&memTeamDefaults{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	cli := client.NewClient(srv.URL)
+	ctx := context.Background()
+
+	defaults := crontinuous.TeamDefaults{
+		TeamID:      "t1",
+		Jitter:      crontinuous.Duration(time.Minute),
+		Timezone:    "UTC",
+		Alerting:    &crontinuous.AlertSettings{Channel: "#team-alerts"},
+		Concurrency: crontinuous.ConcurrencyForbid,
+	}
+	if err := cli.SaveTeamDefaults(ctx, defaults); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.SaveTeamDefaults(ctx, crontinuous.TeamDefaults{TeamID: "t1", Concurrency: "replace"}); err != crontinuous.ErrMalformedTeamDefaults {
+		t.Errorf("want ErrMalformedTeamDefaults, got %v", err)
+	}
+	got, err := cli.GetTeamDefaults(ctx, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(defaults, got); diff != "" {
+		t.Errorf("team defaults diff: %s", diff)
+	}
+
+	resp, err := http.Get(srv.URL + "/team-defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []crontinuous.TeamDefaults
+	err = json.NewDecoder(resp.Body).Decode(&all)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]crontinuous.TeamDefaults{defaults}, all); diff != "" {
+		t.Errorf("all team defaults diff: %s", diff)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/teams/t1/defaults", strings.NewReader(`{"team_id": "t2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400 saving the defaults of other team, got %d", resp.StatusCode)
+	}
+
+	if err := cli.RemoveTeamDefaults(ctx, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.GetTeamDefaults(ctx, "t1"); err != crontinuous.ErrTeamDefaultsNotFound {
+		t.Errorf("want ErrTeamDefaultsNotFound, got %v", err)
+	}
+}
+
+type nopDeadLetterQueue struct{}
+
+func (nopDeadLetterQueue) Publish(ctx context.Context, l crontinuous.DeadLetter) error {
+	return nil
+}
+
+type scanCreatorFunc func(programID, teamID string) error
+
+func (f scanCreatorFunc) CreateScan(programID, teamID string) error {
+	return f(programID, teamID)
+}
+
+func TestExecutions(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	creator := scanCreatorFunc(func(programID, teamID string) error {
+		return nil
+	})
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), creator, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	cli := client.NewClient(srv.URL)
+	ctx := context.Background()
+
+	if err := cli.RunScanEntry(ctx, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	filter := crontinuous.ExecutionFilter{
+		Types:  []crontinuous.CronType{crontinuous.ScanCronType},
+		TeamID: "t1",
+		Status: crontinuous.ExecutionSucceeded,
+	}
+	var executions []crontinuous.ExecutionRecord
+	for i := 0; i < 50 && len(executions) == 0; i++ {
+		var err error
+		if executions, err = cli.ListExecutions(ctx, filter); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(executions) != 1 || executions[0].EntryID != "p1" {
+		t.Errorf("want the execution of p1, got %+v", executions)
+	}
+
+	filter.Status = crontinuous.ExecutionSkipped
+	skipped, err := cli.ListExecutions(ctx, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("want no skipped executions, got %+v", skipped)
+	}
+	if _, err := cli.ListExecutions(ctx, crontinuous.ExecutionFilter{Status: "unknown"}); err != crontinuous.ErrMalformedExecutionFilter {
+		t.Errorf("want ErrMalformedExecutionFilter, got %v", err)
+	}
+}
+
+func TestReplayExecution(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	var created []string
+	creator := scanCreatorFunc(func(programID, teamID string) error {
+		created = append(created, programID)
+		return nil
+	})
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), creator, store, nil, store,
+		crontinuous.WithDeadLetterQueue(nopDeadLetterQueue{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	cli := client.NewClient(srv.URL)
+	ctx := context.Background()
+
+	if _, err := cli.ReplayExecution(ctx, "unknown"); err != crontinuous.ErrExecutionNotFound {
+		t.Errorf("want ErrExecutionNotFound, got %v", err)
+	}
+
+	letter := crontinuous.DeadLetter{
+		ID:        "l1",
+		Execution: "e1",
+		Type:      crontinuous.ScanCronType,
+		EntryID:   "p1",
+		Entry:     json.RawMessage("null"),
+		Payload:   crontinuous.ScanCreation{ProgramID: "p1", TeamID: "t1"},
+		Error:     "vulcan-api unavailable",
+	}
+	replayed, err := cli.ReplayDeadLetter(ctx, letter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Replay == nil || len(created) != 1 || created[0] != "p1" {
+		t.Errorf("want the scan of p1 replayed, got %+v, created %v", replayed, created)
+	}
+	if _, err := cli.ReplayExecution(ctx, "e1"); err != crontinuous.ErrExecutionReplayed {
+		t.Errorf("want ErrExecutionReplayed, got %v", err)
+	}
+
+	resp, err := http.Post(srv.URL+"/executions/e2/replay", "application/json", strings.NewReader(`{"execution":"e3"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status %d for a dead letter of other execution, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	// The dead letters are read and written in YAML with the entry as a
+	// document.
+	body := `id: l4
+execution: e4
+type: scan
+entry_id: p4
+entry:
+  program_id: p4
+  team_id: t1
+  cron_spec: 0 4 * * *
+payload:
+  program_id: p4
+  team_id: t1
+`
+	req, err := http.NewRequest("POST", srv.URL+"/executions/e4/replay", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close() // nolint
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %s", http.StatusOK, resp.StatusCode, content)
+	}
+	var got crontinuous.DeadLetter
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	var entry crontinuous.ScanEntry
+	if err := json.Unmarshal(got.Entry, &entry); err != nil {
+		t.Fatalf("decoding the entry %s: %v", got.Entry, err)
+	}
+	want := crontinuous.ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "0 4 * * *"}
+	if got.EntryID != "p4" || got.Replay == nil || !cmp.Equal(entry, want) {
+		t.Errorf("want the dead letter of p4 replayed, got %s", content)
+	}
+	for _, key := range []string{"entry_id: p4", "failed_at:", "\nentry:\n  cron_spec: 0 4 * * *"} {
+		if !strings.Contains(string(content), key) {
+			t.Errorf("want %q in the YAML dead letter, got %s", key, content)
+		}
+	}
+}
+
+type memCommands struct {
+	entries map[string]crontinuous.CommandEntry
+}
+
+func (m *memCommands) GetCommandEntries() (map[string]crontinuous.CommandEntry, error) {
+	return m.entries, nil
+}
+
+func (m *memCommands) SaveCommandEntries(entries map[string]crontinuous.CommandEntry) error {
+	m.entries = entries
+	return nil
+}
+
+func TestCommandEntries(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	commands := &memCommands{entries: map[string]crontinuous.CommandEntry{}}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithCommands(commands, t.TempDir()))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{"POST", "/command/settings/c1", `{"str":"0 4 * * *","script":"cleanup.sh","args":["-v"]}`, http.StatusOK},
+		{"POST", "/command/settings/c2", `{"str":"0 4 * * *","script":"../cleanup.sh"}`, http.StatusUnprocessableEntity},
+		{"POST", "/command/settings/c3", `{"str":"0 4 * * *","script":"/bin/sh"}`, http.StatusUnprocessableEntity},
+		{"GET", "/command/entries/c1", "", http.StatusOK},
+		{"GET", "/command/entries/c1/executions", "", http.StatusOK},
+		{"GET", "/command/entries/c2/executions", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: want status %d, got %d", tt.method, tt.path, tt.want, resp.StatusCode)
+		}
+	}
+	want := crontinuous.CommandEntry{ID: "c1", Script: "cleanup.sh", Args: []string{"-v"}, CronSpec: "0 4 * * *"}
+	if diff := cmp.Diff(want, commands.entries["c1"], cmpopts.IgnoreFields(crontinuous.CommandEntry{}, "UpdatedAt")); diff != "" {
+		t.Errorf("stored entry diff: %s", diff)
+	}
+}
+
+func TestCanary(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/settings/p1/t1?canary=bogus", "application/json", strings.NewReader(`{"str":"0 4 * * *"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status %d for an invalid canary mode, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/settings/p1/t1?canary=dry-run", "application/json", strings.NewReader(`{"str":"0 4 * * *"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved saveResponse
+	err = json.NewDecoder(resp.Body).Decode(&saved)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Canary == nil || saved.Canary.EntryID != "p1" {
+		t.Fatalf("want the canary of the entry in the response, got %+v", saved)
+	}
+
+	resp, err = http.Get(srv.URL + "/canaries/" + saved.Canary.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var canary crontinuous.Canary
+	err = json.NewDecoder(resp.Body).Decode(&canary)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canary.ID != saved.Canary.ID {
+		t.Errorf("want canary %s, got %+v", saved.Canary.ID, canary)
+	}
+
+	resp, err = http.Get(srv.URL + "/canaries/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want status %d for an unknown canary, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+// teamsValidator validates the entries against the given programs per team.
+type teamsValidator map[string][]string
+
+func (v teamsValidator) GetTeamContext(ctx context.Context, teamID string) (crontinuous.Team, error) {
+	if _, ok := v[teamID]; !ok {
+		return crontinuous.Team{}, crontinuous.ErrTeamNotFound
+	}
+	return crontinuous.Team{ID: teamID}, nil
+}
+
+func (v teamsValidator) GetProgramContext(ctx context.Context, teamID, programID string) (crontinuous.Program, error) {
+	for _, p := range v[teamID] {
+		if p == programID {
+			return crontinuous.Program{ID: programID}, nil
+		}
+	}
+	return crontinuous.Program{}, crontinuous.ErrProgramNotFound
+}
+
+func TestValidateEntries(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	validator := teamsValidator{"t1": {"p1"}}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithValidator(validator))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/settings/p1/t1", http.StatusOK, ""},
+		{"/settings/p2/t1", http.StatusUnprocessableEntity, `ErrorProgramNotFound: scan entry "p2", field program_id`},
+		{"/settings/p3/t2", http.StatusUnprocessableEntity, `ErrorTeamNotFound: scan entry "p3", field team_id`},
+		{"/settings/p1/t2", http.StatusUnprocessableEntity, `ErrorTeamNotFound: scan entry "p1", field team_id`},
+		{"/report/settings/t2", http.StatusUnprocessableEntity, `ErrorTeamNotFound: report entry "t2", field team_id`},
+		{"/report/settings/t1", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(`{"str":"0 4 * * *"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: want status %d, got %d", tt.path, tt.wantCode, resp.StatusCode)
+		}
+		if tt.wantBody != "" && strings.TrimSpace(string(body)) != tt.wantBody {
+			t.Errorf("%s: want body %q, got %q", tt.path, tt.wantBody, body)
+		}
+	}
+
+	// Existing entries are updated without being validated again unless
+	// they are moved to another team.
+	delete(validator, "t1")
+	resp, err := http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(`{"str":"0 5 * * *"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want the existing entry updated, got status %d", resp.StatusCode)
+	}
+}
+
+func TestDeriveReportSchedules(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/report/derive", "application/json", strings.NewReader(`{"delay":"25h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("want status %d for an invalid delay, got %d", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/report/derive", "application/json", strings.NewReader(`{"delay":"6h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changes []struct {
+		Action string `json:"action"`
+		ID     string `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&changes)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != "add" || changes[0].ID != "t1" {
+		t.Errorf("want the report of t1 created, got %+v", changes)
+	}
+	if got := store.reports["t1"].CronSpec; got != "0 8 * * *" {
+		t.Errorf("want report spec %q, got %q", "0 8 * * *", got)
+	}
+}
+
+func TestTeamSummary(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * *"},
+		},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/teams/t1/summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		TeamID string `json:"team_id"`
+		Scans  []struct {
+			Type      string                `json:"type"`
+			Entry     crontinuous.ScanEntry `json:"entry"`
+			Scheduled bool                  `json:"scheduled"`
+			NextRuns  []time.Time           `json:"next_runs"`
+		} `json:"scans"`
+		Reports []json.RawMessage `json:"reports"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&summary)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.TeamID != "t1" || len(summary.Scans) != 1 || len(summary.Reports) != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if s := summary.Scans[0]; s.Type != "scan" || s.Entry.ProgramID != "p1" || !s.Scheduled || len(s.NextRuns) == 0 {
+		t.Errorf("unexpected scan summary %+v", s)
+	}
+}
+
+func TestScanCapacity(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{ScanCapacity: 1, EnforceScanCapacity: true}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+	}{
+		{"/settings/p2/t2", http.StatusConflict},
+		{"/settings/p2/t2?force=true", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(`{"str":"0 2 * * *"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: want status %d, got %d", tt.path, tt.wantCode, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/analysis/scan-density")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var density crontinuous.ScanDensity
+	err = json.NewDecoder(resp.Body).Decode(&density)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if density.Capacity != 1 || density.Peak != 2 || density.ExceededMinutes != 1 {
+		t.Errorf("unexpected scan density %+v", density)
+	}
+}
+
+func TestSpecRules(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	rules := []crontinuous.SpecRule{
+		{Name: "staggered-daily", Match: "^@daily$", Rewrite: "H H(0-5) * * *"},
+		{Name: "no-weekends", Teams: []string{"t1"}, ForbiddenWeekdays: []time.Weekday{time.Saturday, time.Sunday}},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithSpecRules(rules))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		path     string
+		wantCode int
+	}{
+		{"/settings/p1/t2", http.StatusOK},
+		{"/settings/p2/t1", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+tt.path, "application/json", strings.NewReader(`{"str":"@daily"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: want status %d, got %d", tt.path, tt.wantCode, resp.StatusCode)
+		}
+	}
+	if got := store.scans["p1"].CronSpec; got != "H H(0-5) * * *" {
+		t.Errorf("want the spec rewritten, got %q", got)
+	}
+
+	body := `{"type": "scan", "entry": {"program_id": "p3", "team_id": "t1", "cron_spec": "@daily"}}`
+	resp, err := http.Post(srv.URL+"/spec-rules/preview", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rw crontinuous.SpecRewrite
+	err = json.NewDecoder(resp.Body).Decode(&rw)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rw.CronSpec != "H H(0-5) * * *" || len(rw.Rules) != 2 || rw.RejectedBy != "no-weekends" {
+		t.Errorf("unexpected preview %+v", rw)
+	}
+	if _, ok := store.scans["p3"]; ok {
+		t.Error("want the previewed entry not stored")
+	}
+}
+
+func TestSpecAliases(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	aliases := map[string]string{"@business-nightly": "0 2 * * 1-5"}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithSpecAliases(aliases))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		spec     string
+		wantCode int
+	}{
+		{"@business-nightly", http.StatusOK},
+		{"@business-weekly", http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(`{"str":"`+tt.spec+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: want status %d, got %d", tt.spec, tt.wantCode, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/entries/p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry crontinuous.ScanEntry
+	err = json.NewDecoder(resp.Body).Decode(&entry)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.CronSpec != "0 2 * * 1-5" || entry.SpecAlias != "@business-nightly" {
+		t.Errorf("want the spec and its alias, got %+v", entry)
+	}
+
+	resp, err = http.Get(srv.URL + "/spec-aliases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]string
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(aliases, got); diff != "" {
+		t.Errorf("aliases mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestEntryMetadata(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	schema := crontinuous.MetadataSchema{Required: []string{"cost_center"}}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithMetadataSchema(schema))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		body     string
+		wantCode int
+	}{
+		{`{"str": "@daily", "metadata": {"cost_center": "security", "ticket": "SEC-1"}}`, http.StatusOK},
+		{`{"str": "@daily", "metadata": {"ticket": "SEC-1"}}`, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s: want status %d, got %d", tt.body, tt.wantCode, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(srv.URL + "/entries/p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry crontinuous.ScanEntry
+	err = json.NewDecoder(resp.Body).Decode(&entry)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"cost_center": "security", "ticket": "SEC-1"}
+	if diff := cmp.Diff(want, entry.Metadata); diff != "" {
+		t.Errorf("metadata mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchEntries(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	settings := []string{
+		`{"str": "@weekly", "name": "Weekly PCI scan", "description": "Assets in scope of PCI DSS"}`,
+		`{"str": "@daily", "name": "Daily scan"}`,
+	}
+	for i, body := range settings {
+		resp, err := http.Post(fmt.Sprintf("%s/settings/p%d/t1", srv.URL, i), "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want status 200, got %d", body, resp.StatusCode)
+		}
+	}
+
+	tests := []struct {
+		query   string
+		accept  string
+		wantIDs []string
+	}{
+		{"", "", []string{"p0", "p1"}},
+		{"q=pci", "", []string{"p0"}},
+		{"q=" + url.QueryEscape("DSS weekly"), "", []string{"p0"}},
+		{"q=scan&changed_since=2000-01-01T00:00:00Z", "", []string{"p0", "p1"}},
+		{"q=daily", "application/x-ndjson", []string{"p1"}},
+		{"q=monthly", "", nil},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/entries?"+tt.query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		dec := json.NewDecoder(resp.Body)
+		if tt.accept != "" {
+			for dec.More() {
+				var e crontinuous.ScanEntry
+				if err := dec.Decode(&e); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, e.ProgramID)
+			}
+		} else {
+			var entries []crontinuous.ScanEntry
+			if err := dec.Decode(&entries); err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				ids = append(ids, e.ProgramID)
+			}
+		}
+		resp.Body.Close() // nolint
+		if diff := cmp.Diff(tt.wantIDs, ids); diff != "" {
+			t.Errorf("%s: entries mismatch (-want +got):\n%s", tt.query, diff)
+		}
+	}
+
+	entry, err := c.GetEntryByID(crontinuous.ScanCronType, "p0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := entry.(crontinuous.ScanEntry); e.Name != "Weekly PCI scan" || e.Description != "Assets in scope of PCI DSS" {
+		t.Errorf("want name and description stored, got %+v", e)
+	}
+}
+
+func TestTeamsEntries(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 4 * * 1"},
+		},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	teams, err := client.NewClient(srv.URL).GetTeamsEntries(context.Background(), []string{"t1", "t3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []crontinuous.TeamEntries{
+		{
+			TeamID:  "t1",
+			Scans:   []crontinuous.ScanEntry{store.scans["p1"]},
+			Reports: []crontinuous.ReportEntry{store.reports["t1"]},
+		},
+		{TeamID: "t3", Scans: []crontinuous.ScanEntry{}, Reports: []crontinuous.ReportEntry{}},
+	}
+	if diff := cmp.Diff(want, teams); diff != "" {
+		t.Errorf("entries mismatch (-want +got):\n%s", diff)
+	}
+
+	// The entries are returned with their status.
+	resp, err := http.Post(srv.URL+"/teams/entries", "application/json", strings.NewReader(`{"ids": ["t2"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var withStatus []struct {
+		Scans []scanEntryStatus `json:"scans"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&withStatus)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withStatus) != 1 || len(withStatus[0].Scans) != 1 || !withStatus[0].Scans[0].Scheduled {
+		t.Errorf("want the scan entry of t2 scheduled, got %+v", withStatus)
+	}
+
+	ids := make([]string, maxLookupIDs+1)
+	body, err := json.Marshal(lookupRequest{IDs: ids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Post(srv.URL+"/teams/entries", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400 for more than %d teams, got %d", maxLookupIDs, resp.StatusCode)
+	}
+}
+
+func TestChangedSince(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	if err := c.SaveEntry(crontinuous.ScanCronType, crontinuous.ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		query    string
+		wantCode int
+		wantLen  int
+	}{
+		{"changed_since=2000-01-01T00:00:00Z", http.StatusOK, 1},
+		{"changed_since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339), http.StatusOK, 0},
+		{"changed_since=yesterday", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(srv.URL + "/entries?" + tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entries []crontinuous.ScanEntry
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&entries)
+		}
+		resp.Body.Close() // nolint
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.wantCode || len(entries) != tt.wantLen {
+			t.Errorf("%s: want status %d and %d entries, got %d and %d", tt.query, tt.wantCode, tt.wantLen, resp.StatusCode, len(entries))
+		}
+	}
+}
+
+func TestStreamEntries(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	for i := 0; i < streamChunkSize+10; i++ {
+		id := fmt.Sprintf("p%04d", i)
+		store.scans[id] = crontinuous.ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 2 * * *"}
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		accept      string
+		wantType    string
+		wantEntries int
+	}{
+		{"application/x-ndjson", ndjsonContentType, streamChunkSize + 10},
+		{"application/ndjson, application/json", ndjsonContentType, streamChunkSize + 10},
+		{"application/json, application/x-ndjson", "", streamChunkSize + 10},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/entries", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", tt.accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		if tt.wantType == ndjsonContentType {
+			dec := json.NewDecoder(resp.Body)
+			for dec.More() {
+				var e crontinuous.ScanEntry
+				if err := dec.Decode(&e); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, e.ProgramID)
+			}
+		} else {
+			var entries []crontinuous.ScanEntry
+			if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				ids = append(ids, e.ProgramID)
+			}
+		}
+		resp.Body.Close() // nolint
+		if got := resp.Header.Get("Content-Type"); tt.wantType != "" && got != tt.wantType {
+			t.Errorf("%s: want content type %s, got %s", tt.accept, tt.wantType, got)
+		}
+		if len(ids) != tt.wantEntries || !sort.StringsAreSorted(ids) {
+			t.Errorf("%s: want %d entries sorted, got %d", tt.accept, tt.wantEntries, len(ids))
+		}
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	store.scans = map[string]crontinuous.ScanEntry{}
+	resp, err := http.Get(srv.URL + "/internal/divergence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Changes []struct {
+			Action string `json:"action"`
+			ID     string `json:"id"`
+		} `json:"changes"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Changes) != 1 || got.Changes[0].Action != "delete" || got.Changes[0].ID != "p1" {
+		t.Errorf("unexpected divergence %+v", got)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithSaveInterval(time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	err := c.SaveEntry(crontinuous.ScanCronType, crontinuous.ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.scans) != 0 {
+		t.Fatalf("want the entry pending to be saved, got %+v", store.scans)
+	}
+	resp, err := http.Post(srv.URL+"/admin/flush", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Flushed []string `json:"flushed"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&got)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"scan"}, got.Flushed); diff != "" {
+		t.Errorf("flushed types mismatch (-want +got):\n%s", diff)
+	}
+	if _, ok := store.scans["p1"]; !ok {
+		t.Errorf("want the entry saved, got %+v", store.scans)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{IdempotencyWindow: time.Minute}))
+	defer srv.Close()
+
+	post := func(path, key, body string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(crontinuous.IdempotencyKeyHeader, key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, string(content)
+	}
+
+	bulk := `[{"program_id":"p1","team_id":"t1","str":"0 2 * * *"}]`
+	first, firstBody := post("/entries?mode=sync", "k1", bulk)
+	if first.StatusCode != http.StatusOK || !strings.Contains(firstBody, "p1") {
+		t.Fatalf("want status 200, got %d: %s", first.StatusCode, firstBody)
+	}
+	retry, retryBody := post("/entries?mode=sync", "k1", bulk)
+	if retry.StatusCode != first.StatusCode || retryBody != firstBody {
+		t.Errorf("want the first response replayed, got %d: %s", retry.StatusCode, retryBody)
+	}
+	if retry.Header.Get(crontinuous.IdempotentReplayedHeader) != "true" {
+		t.Errorf("want the response marked as replayed")
+	}
+	if retry.Header.Get(crontinuous.RequestIDHeader) == first.Header.Get(crontinuous.RequestIDHeader) {
+		t.Errorf("want the ID of the retry, got the one of the first request")
+	}
+	if _, body := post("/entries?mode=sync", "k2", bulk); strings.TrimSpace(body) != "[]" {
+		t.Errorf("want the request with a new key applied again without changes, got %s", body)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		key      string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"ReusedBody", "/entries?mode=sync", "k1", `[{"program_id":"p2","team_id":"t1","str":"0 2 * * *"}]`,
+			http.StatusUnprocessableEntity, crontinuous.ErrIdempotencyKeyReused.Error()},
+		{"ReusedPath", "/entries", "k1", bulk,
+			http.StatusUnprocessableEntity, crontinuous.ErrIdempotencyKeyReused.Error()},
+		{"Malformed", "/entries", "a key", bulk,
+			http.StatusBadRequest, crontinuous.ErrMalformedIdempotencyKey.Error()},
+	}
+	for _, tt := range tests {
+		resp, body := post(tt.path, tt.key, tt.body)
+		if resp.StatusCode != tt.wantCode || strings.TrimSpace(body) != tt.wantBody {
+			t.Errorf("%s: want %d %s, got %d %s", tt.name, tt.wantCode, tt.wantBody, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestIdempotencyCache(t *testing.T) {
+	now := time.Now()
+	cache := newIdempotencyCache(time.Minute)
+	cache.now = func() time.Time { return now }
+	calls := 0
+	release := make(chan struct{})
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			<-release
+		}
+		if calls == 2 {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "call %d", calls)
+	})
+	h = cache.wrap(h)
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/entries", strings.NewReader("{}"))
+		req.Header.Set(crontinuous.IdempotencyKeyHeader, "k1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- post() }()
+	for {
+		cache.mux.Lock()
+		n := len(cache.responses)
+		cache.mux.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rec := post(); rec.Code != http.StatusConflict {
+		t.Errorf("want status 409 while the first request is served, got %d", rec.Code)
+	}
+	close(release)
+	if rec := <-done; rec.Body.String() != "call 1" {
+		t.Errorf("want the first request served, got %q", rec.Body.String())
+	}
+	if rec := post(); rec.Body.String() != "call 1" {
+		t.Errorf("want the first response replayed, got %q", rec.Body.String())
+	}
+
+	now = now.Add(time.Minute)
+	if rec := post(); rec.Code != http.StatusInternalServerError {
+		t.Errorf("want the request served again after the window, got %d", rec.Code)
+	}
+	if rec := post(); rec.Body.String() != "call 3" {
+		t.Errorf("want the request served again after a server error, got %q", rec.Body.String())
+	}
+}
+
+func TestPreviewWhitelists(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	post := func(body string) (int, string) {
+		resp, err := http.Post(srv.URL+"/config/whitelists/preview", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, strings.TrimSpace(string(content))
+	}
+
+	status, body := post(`{"scan_whitelist": {"enabled": true, "rules": ["t2"]}}`)
+	want := `{"scheduled":[],"unscheduled":[{"type":"scan","id":"p1","team_id":"t1"}]}`
+	if status != http.StatusOK || body != want {
+		t.Errorf("want %s, got %d %s", want, status, body)
+	}
+	status, body = post(`{"scan_whitelist": {"enabled": true, "rules": ["t1@someday"]}}`)
+	if status != http.StatusUnprocessableEntity || !strings.HasPrefix(body, crontinuous.ErrMalformedWhitelistRule.Error()) {
+		t.Errorf("want the malformed rule rejected, got %d %s", status, body)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		fmt.Fprint(w, "slow")
+	}
+	streaming := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		fmt.Fprintln(w, "first")
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintln(w, "second")
+	}
+	router := routes{Router: httprouter.New(), timeouts: Timeouts{
+		Read:      10 * time.Millisecond,
+		Write:     time.Hour,
+		Endpoints: map[string]time.Duration{"GET /unbounded/:id": 0},
+	}}
+	router.GET("/slow", slow)
+	router.GET("/streaming", streaming)
+	router.GET("/unbounded/:id", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Errorf("want no deadline for %s", r.URL.Path)
+		}
+	})
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close() // nolint
+		content, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, strings.TrimSpace(string(content))
+	}
+
+	resp, body := get("/slow")
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("want status 504, got %d", resp.StatusCode)
+	}
+	want := `{"error":"ErrorRequestTimeout","timeout":"10ms"}`
+	if resp.Header.Get("Content-Type") != "application/json" || body != want {
+		t.Errorf("want body %s, got %s", want, body)
+	}
+	if resp, body = get("/streaming"); resp.StatusCode != http.StatusOK || body != "first\nsecond" {
+		t.Errorf("want the streamed response not interrupted, got %d %q", resp.StatusCode, body)
+	}
+	if resp, _ = get("/unbounded/1"); resp.StatusCode != http.StatusOK {
+		t.Errorf("want status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestParseEndpointTimeout(t *testing.T) {
+	tests := []struct {
+		item         string
+		wantEndpoint string
+		wantTimeout  time.Duration
+		wantErr      bool
+	}{
+		{item: "POST /entries=30s", wantEndpoint: "POST /entries", wantTimeout: 30 * time.Second},
+		{item: "GET /entries/:programID=0s", wantEndpoint: "GET /entries/:programID"},
+		{item: "POST /entries", wantErr: true},
+		{item: "/entries=30s", wantErr: true},
+		{item: "post /entries=30s", wantErr: true},
+		{item: "POST /entries=-1s", wantErr: true},
+		{item: "POST /entries=soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		endpoint, timeout, err := ParseEndpointTimeout(tt.item)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: error got %v, want error %v", tt.item, err, tt.wantErr)
+			continue
+		}
+		if endpoint != tt.wantEndpoint || timeout != tt.wantTimeout {
+			t.Errorf("%q: got %q %s, want %q %s", tt.item, endpoint, timeout, tt.wantEndpoint, tt.wantTimeout)
+		}
+	}
+}
+
+func TestReadOnlyReplica(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store, crontinuous.WithReadOnlyReplica())
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{http.MethodGet, "/entries", http.StatusOK},
+		{http.MethodGet, "/entries/p1", http.StatusOK},
+		{http.MethodPost, "/settings/p2/t1", http.StatusForbidden},
+		{http.MethodDelete, "/entries/p1", http.StatusForbidden},
+		{http.MethodPost, "/entries/p1/run", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(`{"str":"0 2 * * *"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantCode {
+			t.Errorf("%s %s: want status %d, got %d", tt.method, tt.path, tt.wantCode, resp.StatusCode)
+		}
+	}
+}
+
+func TestScanIDs(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithScanIDStrategy(crontinuous.ProgramSpecScanIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	var ids []string
+	for _, spec := range []string{"0 2 * * *", "0 3 * * 0"} {
+		resp, err := http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(`{"str":"`+spec+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved saveResponse
+		err = json.NewDecoder(resp.Body).Decode(&saved)
+		resp.Body.Close() // nolint
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, saved.ID)
+	}
+	if ids[0] == "" || ids[0] == ids[1] {
+		t.Fatalf("want different IDs for the entries of the program, got %v", ids)
+	}
+
+	resp, err := http.Get(srv.URL + "/entries/" + ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry crontinuous.ScanEntry
+	err = json.NewDecoder(resp.Body).Decode(&entry)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ID != ids[1] || entry.CronSpec != "0 3 * * 0" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+
+	body := `{"id":"` + ids[1] + `","str":"0 2 * * *"}`
+	resp, err = http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("want status %d saving a duplicated entry, got %d", http.StatusConflict, resp.StatusCode)
+	}
+}
+
+func TestScanIDsSharded(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithScanIDStrategy(crontinuous.ProgramSpecScanIDs{}), crontinuous.WithShard(0, 2))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	// The status of every entry depends on the shard its generated ID is
+	// assigned to.
+	for i := 0; i < 20; i++ {
+		program := fmt.Sprintf("p%d", i)
+		resp, err := http.Post(srv.URL+"/settings/"+program+"/t1", "application/json", strings.NewReader(`{"str":"0 2 * * *"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved saveResponse
+		err = json.NewDecoder(resp.Body).Decode(&saved)
+		resp.Body.Close() // nolint
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		entry, err := c.GetEntryByID(crontinuous.ScanCronType, saved.ID)
+		if err != nil {
+			t.Fatalf("entry %q of %s not saved: %v", saved.ID, program, err)
+		}
+		if want := c.ScheduleStatus(crontinuous.ScanCronType, entry); saved.ScheduleStatus != want {
+			t.Errorf("entry %s of %s: want status %+v, got %+v", saved.ID, program, want, saved.ScheduleStatus)
+		}
+	}
+}
+
+func TestReportIDs(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithReportIDStrategy(crontinuous.TeamSpecReportIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		team string
+		spec string
+	}{{"t1", "0 8 1 * *"}, {"t2", "0 9 * * 1"}} {
+		resp, err := http.Post(srv.URL+"/report/settings/"+tt.team, "application/json", strings.NewReader(`{"str":"`+tt.spec+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("want status %d saving the report of %s, got %d", http.StatusOK, tt.team, resp.StatusCode)
+		}
+	}
+
+	entries, err := client.NewClient(srv.URL).ListTeamReportEntries(context.Background(), "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("want 2 report entries of t1, got %+v", entries)
+	}
+
+	// The team ID keeps identifying the only entry of t2.
+	entry, err := client.NewClient(srv.URL).GetReportEntry(context.Background(), "t2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.TeamID != "t2" || entry.ID == "" || entry.ID == "t2" {
+		t.Errorf("unexpected report entry %+v", entry)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	got, err := client.NewClient(srv.URL).GetSnapshot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := c.Snapshot()
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(crontinuous.Snapshot{}, "TakenAt")); diff != "" {
+		t.Errorf("snapshot diff: %s", diff)
+	}
+}
+
+func TestExportCrontab(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/entries/export?format=crontab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("want status 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(string(body), "\n0 2 * * * scan p1\n") {
+		t.Errorf("want the crontab line of the scan entry, got %q", body)
+	}
+
+	resp, err = http.Get(srv.URL + "/entries/export?format=xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("want status 400 for an unsupported format, got %d", resp.StatusCode)
+	}
+
+	// The export shares the route of the scan entries, so no entry can
+	// have its ID.
+	resp, err = http.Post(srv.URL+"/settings/export/t1", "application/json", strings.NewReader(`{"str":"0 3 * * *"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("want status 422 for the export ID, got %d", resp.StatusCode)
+	}
+	if _, err := c.GetEntryByID(crontinuous.ScanCronType, "export"); err == nil {
+		t.Error("want the entry with the export ID not saved")
+	}
+}
+
+func TestImportCrontab(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	post := func(contentType, query, body string) int {
+		resp, err := http.Post(srv.URL+"/entries/import"+query, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	crontab := "# scan p1 of team t1\n0 5 * * * scan.sh p1\n# scan p2 of team t2\n30 4 * * * scan.sh p2\n"
+	if status := post("application/json", "", crontab); status != http.StatusUnsupportedMediaType {
+		t.Errorf("want status 415 for a JSON payload, got %d", status)
+	}
+	if status := post("text/x-crontab", "", "0 5 * * * scan.sh p1\n"); status != http.StatusBadRequest {
+		t.Errorf("want status 400 for a line without entry comment, got %d", status)
+	}
+	if status := post("text/x-crontab", "?overwrite=true", crontab); status != http.StatusOK {
+		t.Fatalf("want status 200, got %d", status)
+	}
+	want := map[string][2]string{"p1": {"t1", "0 5 * * *"}, "p2": {"t2", "30 4 * * *"}}
+	for id, w := range want {
+		e, err := c.GetEntryByID(crontinuous.ScanCronType, id)
+		if err != nil {
+			t.Fatalf("Error getting entry %s: %v", id, err)
+		}
+		if e.GetTeamID() != w[0] || e.GetCronSpec() != w[1] {
+			t.Errorf("want entry %s imported with team %s and spec %q, got %+v", id, w[0], w[1], e)
+		}
+	}
+}
+
+func TestBulkRemove(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	missing, err := client.NewClient(srv.URL).RemoveScanEntries(context.Background(), []string{"p1", "p2", "p3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"p3"}, missing); diff != "" {
+		t.Errorf("missing IDs diff: %s", diff)
+	}
+	entries, err := c.GetEntries(crontinuous.ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("want no entries, got %+v", entries)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"NotFound", crontinuous.ErrScheduleNotFound, http.StatusNotFound},
+		{"Wrapped", fmt.Errorf("applying change: %w", crontinuous.ErrScheduleConflict), http.StatusConflict},
+		{"Unknown", fmt.Errorf("store unreachable"), http.StatusInternalServerError},
+		{"EntryTemplateNotFound", &crontinuous.EntryError{Type: crontinuous.ScanCronType, ID: "p1", Err: crontinuous.ErrTemplateNotFound}, http.StatusUnprocessableEntity},
+		{"EntryConflict", &crontinuous.EntryError{Type: crontinuous.ScanCronType, ID: "p1", Err: crontinuous.ErrScheduleConflict}, http.StatusConflict},
+		{"EntryUnknown", &crontinuous.EntryError{Type: crontinuous.ScanCronType, ID: "p1", Err: fmt.Errorf("vulcan-api unavailable")}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatus(tt.err, http.StatusInternalServerError); got != tt.want {
+				t.Errorf("want status %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	// The errors of the entries are sent with the entry causing them.
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/settings/p1/t1", "application/json", strings.NewReader(`{"str":"* *"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close() // nolint
+	want := `ErrorMalformedSchedule: scan entry "p1", field cron_spec`
+	if resp.StatusCode != http.StatusUnprocessableEntity || strings.TrimSpace(string(body)) != want {
+		t.Errorf("want %d %q, got %d %q", http.StatusUnprocessableEntity, want, resp.StatusCode, body)
+	}
+}
+
+func TestBulkOverwrite(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		body     string
+		wantCode int
+		wantSpec string
+	}{
+		{"Default", "", `[{"program_id":"p1","team_id":"t1","str":"0 3 * * *"}]`, http.StatusOK, "0 2 * * *"},
+		{"PerEntry", "", `[{"program_id":"p1","team_id":"t1","str":"0 3 * * *","overwrite":true}]`, http.StatusOK, "0 3 * * *"},
+		{"PresetAll", "?overwrite=all", `[{"program_id":"p1","team_id":"t1","str":"0 4 * * *"}]`, http.StatusOK, "0 4 * * *"},
+		{"PerEntryOverPreset", "?overwrite=all", `[{"program_id":"p1","team_id":"t1","str":"0 5 * * *","overwrite":false}]`, http.StatusOK, "0 4 * * *"},
+		{"PresetMissingOnly", "?overwrite=missing_only", `[{"program_id":"p1","team_id":"t1","str":"0 5 * * *"}]`, http.StatusOK, "0 4 * * *"},
+		{"PresetNone", "?overwrite=none", `[{"program_id":"p1","team_id":"t1","str":"0 5 * * *"}]`, http.StatusConflict, "0 4 * * *"},
+		{"UnknownPreset", "?overwrite=some", `[{"program_id":"p1","team_id":"t1","str":"0 5 * * *"}]`, http.StatusBadRequest, "0 4 * * *"},
+		{"PresetWithMode", "?overwrite=all&mode=sync", `[{"program_id":"p1","team_id":"t1","str":"0 5 * * *"}]`, http.StatusBadRequest, "0 4 * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/entries"+tt.query, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close() // nolint
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("want status %d, got %d %s", tt.wantCode, resp.StatusCode, body)
+			}
+			e, err := c.GetEntryByID(crontinuous.ScanCronType, "p1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.GetCronSpec() != tt.wantSpec {
+				t.Errorf("want spec %q, got %q", tt.wantSpec, e.GetCronSpec())
+			}
+		})
+	}
+}
+
+func TestBulkReplaceTeam(t *testing.T) {
+	store := &memStore{
+		scans: map[string]crontinuous.ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"},
+		},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []string
+	}{
+		{"WithoutTeams", "?mode=replace_team", http.StatusBadRequest, []string{"p1", "p2"}},
+		{"TeamParam", "?mode=replace_team&team=t1", http.StatusOK, []string{"p2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/entries"+tt.query, "application/json", strings.NewReader("[]"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close() // nolint
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("want status %d, got %d %s", tt.wantCode, resp.StatusCode, body)
+			}
+			entries, err := c.GetEntries(crontinuous.ScanCronType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, e := range entries {
+				ids = append(ids, e.GetID())
+			}
+			sort.Strings(ids)
+			if diff := cmp.Diff(tt.wantIDs, ids); diff != "" {
+				t.Errorf("entries mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestOperations(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	body := `[{"program_id":"p1","team_id":"t1","str":"0 2 * * *"},{"program_id":"p2","team_id":"t1","str":"* *"}]`
+	resp, err := http.Post(srv.URL+"/entries?async=true", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var op crontinuous.Operation
+	err = json.NewDecoder(resp.Body).Decode(&op)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusAccepted || resp.Header.Get("Location") != "/operations/"+op.ID || op.Total != 2 {
+		t.Fatalf("want the operation accepted, got %d %+v", resp.StatusCode, op)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for op.Status == crontinuous.OperationRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("operation not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+		resp, err := http.Get(srv.URL + "/operations/" + op.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&op)
+		resp.Body.Close() // nolint
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if op.Status != crontinuous.OperationCompleted || op.Processed != 2 || op.Failed != 1 ||
+		op.Entries[0].Result != crontinuous.EntryCreated || op.Entries[1].Result != crontinuous.EntryFailed {
+		t.Errorf("want the operation completed with a failed entry, got %+v", op)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"Cancel", http.MethodDelete, "/operations/" + op.ID, http.StatusOK},
+		{"NotFound", http.MethodGet, "/operations/unknown", http.StatusNotFound},
+		{"CancelNotFound", http.MethodDelete, "/operations/unknown", http.StatusNotFound},
+		{"AsyncWithMode", http.MethodPost, "/entries?async=true&mode=sync", http.StatusBadRequest},
+		{"InvalidAsync", http.MethodPost, "/entries?async=maybe", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close() // nolint
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("want status %d, got %d", tt.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestExecutionWindows(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{}))
+	defer srv.Close()
+
+	tests := []struct {
+		body       string
+		wantStatus int
+	}{
+		{`{"str": "0 23 * * *", "windows": ["22:00-06:00"]}`, http.StatusOK},
+		{`{"str": "0 12 * * *", "windows": ["22:00-06:00"], "window_policy": "defer"}`, http.StatusOK},
+		{`{"str": "0 12 * * *", "windows": ["22:00-06:00"]}`, http.StatusUnprocessableEntity},
+		{`{"str": "0 23 * * *", "windows": ["22:00"]}`, http.StatusUnprocessableEntity},
+	}
+	for i, tt := range tests {
+		resp, err := http.Post(fmt.Sprintf("%s/settings/p%d/t1", srv.URL, i), "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close() // nolint
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: want status %d, got %d", tt.body, tt.wantStatus, resp.StatusCode)
+		}
+	}
+
+	e, err := c.GetEntryByID(crontinuous.ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := e.(crontinuous.ScanEntry)
+	if len(entry.Windows) != 1 || entry.WindowPolicy != crontinuous.WindowDefer {
+		t.Errorf("want the execution windows of the entry saved, got %v %q", entry.Windows, entry.WindowPolicy)
+	}
+}
+
+type memSuppressions struct {
+	suppressions map[string]crontinuous.Suppression
+}
+
+func (m *memSuppressions) GetSuppressions() (map[string]crontinuous.Suppression, error) {
+	return m.suppressions, nil
+}
+
+func (m *memSuppressions) SaveSuppressions(suppressions map[string]crontinuous.Suppression) error {
+	m.suppressions = suppressions
+	return nil
+}
+
+func TestSuppress(t *testing.T) {
+	store := &memStore{
+		scans:   map[string]crontinuous.ScanEntry{},
+		reports: map[string]crontinuous.ReportEntry{},
+	}
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store,
+		crontinuous.WithSuppressions(&memSuppressions{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	srv := httptest.NewServer(NewHandler(c, Options{AdminToken: "secret"}))
+	defer srv.Close()
+
+	do := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/admin/suppress", `{"teams": ["t1"], "ttl": "2h"}`)
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("want status 422 without reason, got %d", resp.StatusCode)
+	}
+
+	resp = do(http.MethodPost, "/admin/suppress", `{"teams": ["t1"], "labels": {"env": "pro"}, "ttl": "2h", "reason": "INC-1234"}`)
+	var created crontinuous.Suppression
+	err := json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || created.ID == "" || created.Reason != "INC-1234" {
+		t.Fatalf("want the suppression created, got status %d and %+v", resp.StatusCode, created)
+	}
+
+	resp = do(http.MethodGet, "/admin/suppress", "")
+	var listed []crontinuous.Suppression
+	err = json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close() // nolint
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Errorf("want the suppression listed, got %+v", listed)
+	}
+
+	resp = do(http.MethodDelete, "/admin/suppress/"+created.ID, "")
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("want status 200 removing the suppression, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodDelete, "/admin/suppress/"+created.ID, "")
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("want status 404 removing it twice, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(srv.URL+"/admin/suppress", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close() // nolint
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("want status 401 without the admin token, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+type commandSetting struct {
+	Str              string                     `json:"str" yaml:"str"`
+	ID               string                     `json:"id,omitempty" yaml:"id,omitempty"`
+	Script           string                     `json:"script" yaml:"script"`
+	Args             []string                   `json:"args,omitempty" yaml:"args,omitempty"`
+	TeamID           string                     `json:"team_id,omitempty" yaml:"team_id,omitempty"`
+	Overwrite        *bool                      `json:"overwrite,omitempty" yaml:"overwrite,omitempty"`
+	ExecutionTimeout crontinuous.Duration       `json:"execution_timeout,omitempty" yaml:"execution_timeout,omitempty"`
+	PingURL          string                     `json:"ping_url,omitempty" yaml:"ping_url,omitempty"`
+	Alerting         *crontinuous.AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	Jitter           crontinuous.Duration       `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	Windows          []string                   `json:"windows,omitempty" yaml:"windows,omitempty"`
+	WindowPolicy     crontinuous.WindowPolicy   `json:"window_policy,omitempty" yaml:"window_policy,omitempty"`
+	Template         string                     `json:"template,omitempty" yaml:"template,omitempty"`
+	Name             string                     `json:"name,omitempty" yaml:"name,omitempty"`
+	Description      string                     `json:"description,omitempty" yaml:"description,omitempty"`
+	Metadata         map[string]string          `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+func (s commandSetting) entry(id string) crontinuous.CommandEntry {
+	return crontinuous.CommandEntry{
+		ID:       id,
+		Script:   s.Script,
+		Args:     s.Args,
+		CronSpec: s.Str,
+		TeamID:   s.TeamID,
+		EntrySettings: crontinuous.EntrySettings{
+			ExecutionTimeout: s.ExecutionTimeout,
+			PingURL:          s.PingURL,
+			Alerting:         s.Alerting,
+			Jitter:           s.Jitter,
+			Windows:          s.Windows,
+			WindowPolicy:     s.WindowPolicy,
+			Template:         s.Template,
+			Name:             s.Name,
+			Description:      s.Description,
+			Metadata:         s.Metadata,
+		},
+	}
+}
+
+func (h *handler) commandBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings := []commandSetting{}
+	if err := decodeBody(r, &settings); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entries := []crontinuous.CronEntry{}
+	overwrite := []*bool{}
+	for _, s := range settings {
+		entries = append(entries, s.entry(s.ID))
+		overwrite = append(overwrite, s.Overwrite)
+	}
+
+	h.bulkSettingsHandler(crontinuous.CommandCronType, entries, overwrite, w, r, ps)
+}
+
+func (h *handler) commandSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+	if id == "" {
+		http.Error(w, "Command ID missing", 400)
+		return
+	}
+
+	var s commandSetting
+	if err := decodeBody(r, &s); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	h.settingHandler(crontinuous.CommandCronType, s.entry(id), w, r, ps)
+}
+
+func (h *handler) getCommandSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.getSchedulesHandler(crontinuous.CommandCronType, w, r, ps)
+}
+
+func (h *handler) getCommandScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.getScheduleByIDHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+func (h *handler) removeCommandScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.removeScheduleHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+func (h *handler) bulkRemoveCommandSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.bulkRemoveHandler(crontinuous.CommandCronType, w, r, ps)
+}
+
+func (h *handler) lookupCommandSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.lookupHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+func (h *handler) runCommandScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.runScheduleHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+func (h *handler) getCommandHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.historyHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+func (h *handler) revertCommandScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.revertHandler(crontinuous.CommandCronType, ps.ByName("id"), w, r, ps)
+}
+
+// getCommandExecutionsHandler returns the last executions of a command entry,
+// with the output of its script.
+func (h *handler) getCommandExecutionsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	executions, err := h.cron.CommandExecutions(ps.ByName("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, executions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
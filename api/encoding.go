@@ -0,0 +1,98 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	yamlContentType    = "application/yaml"
+	crontabContentType = "text/x-crontab"
+	ndjsonContentType  = "application/x-ndjson"
+)
+
+var yamlMediaTypes = map[string]bool{
+	"application/yaml":   true,
+	"application/x-yaml": true,
+	"text/yaml":          true,
+	"text/x-yaml":        true,
+}
+
+func isYAML(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return yamlMediaTypes[mediaType]
+}
+
+func isCrontab(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == crontabContentType
+}
+
+// acceptsYAML returns true if the first media type of the Accept header of
+// the request that crontinuous can produce is YAML.
+func acceptsYAML(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted = strings.TrimSpace(accepted)
+		if isYAML(accepted) {
+			return true
+		}
+		mediaType, _, err := mime.ParseMediaType(accepted)
+		if err == nil && (mediaType == "application/json" || mediaType == "*/*") {
+			return false
+		}
+	}
+	return false
+}
+
+// acceptsNDJSON returns true if the first media type of the Accept header of
+// the request that crontinuous can produce is newline delimited JSON.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch {
+		case mediaType == ndjsonContentType || mediaType == "application/ndjson":
+			return true
+		case mediaType == "application/json" || mediaType == "*/*" || yamlMediaTypes[mediaType]:
+			return false
+		}
+	}
+	return false
+}
+
+// decodeBody decodes the body of the request as YAML if its
+// Content-Type is YAML, or as JSON otherwise.
+func decodeBody(r *http.Request, v interface{}) error {
+	if isYAML(r.Header.Get("Content-Type")) {
+		return yaml.NewDecoder(r.Body).Decode(v)
+	}
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// encodeResponse writes v to the response as YAML if the request
+// accepts it, or as JSON otherwise.
+func encodeResponse(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if acceptsYAML(r) {
+		content, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", yamlContentType)
+		_, err = w.Write(content)
+		return err
+	}
+	return json.NewEncoder(w).Encode(v)
+}
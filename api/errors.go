@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// errorStatuses are the statuses of the responses to the requests failing
+// with the crontinuous errors. The errors are matched with errors.Is, so
+// wrapped errors get the status of the error they wrap.
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	{crontinuous.ErrScheduleNotFound, http.StatusNotFound},
+	{crontinuous.ErrInvalidCronType, http.StatusNotFound},
+	{crontinuous.ErrHistoryDisabled, http.StatusNotFound},
+	{crontinuous.ErrRevisionNotFound, http.StatusNotFound},
+	{crontinuous.ErrCommandsDisabled, http.StatusNotFound},
+	{crontinuous.ErrNothingStaged, http.StatusNotFound},
+	{crontinuous.ErrTemplatesDisabled, http.StatusNotFound},
+	{crontinuous.ErrTemplateNotFound, http.StatusNotFound},
+	{crontinuous.ErrPauseWindowsDisabled, http.StatusNotFound},
+	{crontinuous.ErrPauseWindowNotFound, http.StatusNotFound},
+	{crontinuous.ErrSuppressionsDisabled, http.StatusNotFound},
+	{crontinuous.ErrSuppressionNotFound, http.StatusNotFound},
+	{crontinuous.ErrTeamDefaultsDisabled, http.StatusNotFound},
+	{crontinuous.ErrTeamDefaultsNotFound, http.StatusNotFound},
+	{crontinuous.ErrDeadLettersDisabled, http.StatusNotFound},
+	{crontinuous.ErrExecutionNotFound, http.StatusNotFound},
+	{crontinuous.ErrCanaryNotFound, http.StatusNotFound},
+	{crontinuous.ErrOperationNotFound, http.StatusNotFound},
+
+	{crontinuous.ErrMalformedSchedule, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedEntry, http.StatusUnprocessableEntity},
+	{crontinuous.ErrTeamNotFound, http.StatusUnprocessableEntity},
+	{crontinuous.ErrProgramNotFound, http.StatusUnprocessableEntity},
+	{crontinuous.ErrSpecRuleViolation, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMetadataSchemaViolation, http.StatusUnprocessableEntity},
+	{crontinuous.ErrOutOfWindows, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedReportDelay, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedTemplate, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedPauseWindow, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedSuppression, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedTeamDefaults, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedDeadLetter, http.StatusUnprocessableEntity},
+	{crontinuous.ErrMalformedWhitelistRule, http.StatusUnprocessableEntity},
+
+	{crontinuous.ErrScheduleConflict, http.StatusConflict},
+	{crontinuous.ErrScanCapacityExceeded, http.StatusConflict},
+	{crontinuous.ErrDuplicatedEntry, http.StatusConflict},
+	{crontinuous.ErrTemplateInUse, http.StatusConflict},
+	{crontinuous.ErrExecutionReplayed, http.StatusConflict},
+	{crontinuous.ErrEntryExists, http.StatusConflict},
+
+	{crontinuous.ErrInvalidBulkMode, http.StatusBadRequest},
+	{crontinuous.ErrInvalidOverwrite, http.StatusBadRequest},
+	{crontinuous.ErrInvalidTimeWindow, http.StatusBadRequest},
+	{crontinuous.ErrMalformedCrontab, http.StatusBadRequest},
+	{crontinuous.ErrMalformedExecutionFilter, http.StatusBadRequest},
+
+	{crontinuous.ErrTeamNotAllowed, http.StatusForbidden},
+	{crontinuous.ErrReadOnlyReplica, http.StatusForbidden},
+	{crontinuous.ErrBudgetExhausted, http.StatusTooManyRequests},
+}
+
+// errorStatus returns the status of the response to a request failing with
+// the given error, or the given fallback status if it is not a crontinuous
+// error. The errors of an entry referencing something not found, e.g. a
+// template, are unprocessable, instead of not found.
+func errorStatus(err error, fallback int) int {
+	status := fallback
+	for _, s := range errorStatuses {
+		if errors.Is(err, s.err) {
+			status = s.status
+			break
+		}
+	}
+	var entryErr *crontinuous.EntryError
+	if status == http.StatusNotFound && errors.As(err, &entryErr) {
+		status = http.StatusUnprocessableEntity
+	}
+	return status
+}
+
+// writeError writes the response to a request failing with the given error.
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// replayExecutionHandler replays a failed execution and returns its dead
+// letter marked as replayed. The request can contain the dead letter of the
+// execution, as published in the dead letter queue, to replay executions not
+// recorded by this instance, e.g. failed before its start.
+func (h *handler) replayExecutionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+	var l crontinuous.DeadLetter
+	err := decodeBody(r, &l)
+	if err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var replayed crontinuous.DeadLetter
+	if err == io.EOF {
+		replayed, err = h.cron.ReplayExecution(r.Context(), id)
+	} else {
+		if l.Execution != id && (l.Execution != "" || l.ID != id) {
+			http.Error(w, "Dead letter does not match the execution of the path", http.StatusBadRequest)
+			return
+		}
+		replayed, err = h.cron.ReplayDeadLetter(r.Context(), l)
+	}
+	if err != nil {
+		// The errors not reported by crontinuous come from vulcan-api.
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadGateway))
+		return
+	}
+	if err := encodeResponse(w, r, replayed); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// executionsHandler returns the executions of the entries, including the
+// skipped ones, filtered by the type, entry, team, status, skip_reason,
+// since, scan_id and report_id params of the request. The type param can be
+// repeated.
+func (h *handler) executionsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	q := r.URL.Query()
+	f := crontinuous.ExecutionFilter{
+		EntryID:    q.Get("entry"),
+		TeamID:     q.Get("team"),
+		Status:     crontinuous.ExecutionStatus(q.Get("status")),
+		SkipReason: crontinuous.SkipReason(q.Get("skip_reason")),
+		ScanID:     q.Get("scan_id"),
+		ReportID:   q.Get("report_id"),
+	}
+	for _, v := range q["type"] {
+		var typ crontinuous.CronType
+		if err := typ.UnmarshalText([]byte(v)); err != nil {
+			http.Error(w, "Invalid type param", http.StatusBadRequest)
+			return
+		}
+		f.Types = append(f.Types, typ)
+	}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid since param", http.StatusBadRequest)
+			return
+		}
+		f.Since = since
+	}
+
+	executions, err := h.cron.Executions(f)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, executions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// canaryHandler returns the canary with the given ID, started when creating
+// an entry with the canary param.
+func (h *handler) canaryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	canary, err := h.cron.Canary(ps.ByName("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, canary); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
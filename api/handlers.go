@@ -0,0 +1,1379 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+type HealthcheckResponse struct {
+	Status string `json:"status"`
+}
+
+func (h *handler) status(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	resp := HealthcheckResponse{
+		Status: "OK",
+	}
+	// The entries loaded from the local cache keep being executed, so
+	// the instance is still healthy although the store is unreachable.
+	if len(h.cron.LoadStatus().Degraded) > 0 {
+		resp.Status = "DEGRADED"
+	}
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(&resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// readyzHandler reports the progress of the start of crontinuous, with a
+// 503 status until it is ready.
+func (h *handler) readyzHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	status := h.cron.LoadStatus()
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// changedByHeader is the header identifying who makes
+// the changes recorded in the history of the entries.
+const changedByHeader = "X-Requested-By"
+
+type cronString struct {
+	// ID identifies the entry to update when there can be many entries per
+	// program or team.
+	ID               string                     `json:"id,omitempty" yaml:"id,omitempty"`
+	Str              string                     `json:"str" yaml:"str"`
+	ExecutionTimeout crontinuous.Duration       `json:"execution_timeout,omitempty" yaml:"execution_timeout,omitempty"`
+	PingURL          string                     `json:"ping_url,omitempty" yaml:"ping_url,omitempty"`
+	Alerting         *crontinuous.AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	Jitter           crontinuous.Duration       `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	Windows          []string                   `json:"windows,omitempty" yaml:"windows,omitempty"`
+	WindowPolicy     crontinuous.WindowPolicy   `json:"window_policy,omitempty" yaml:"window_policy,omitempty"`
+	Template         string                     `json:"template,omitempty" yaml:"template,omitempty"`
+	Name             string                     `json:"name,omitempty" yaml:"name,omitempty"`
+	Description      string                     `json:"description,omitempty" yaml:"description,omitempty"`
+	Metadata         map[string]string          `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+type createSetting struct {
+	ID               string                     `json:"id,omitempty" yaml:"id,omitempty"`
+	Str              string                     `json:"str" yaml:"str"`
+	TeamID           string                     `json:"team_id" yaml:"team_id"`
+	ProgramID        string                     `json:"program_id" yaml:"program_id"`
+	Overwrite        *bool                      `json:"overwrite,omitempty" yaml:"overwrite,omitempty"`
+	ExecutionTimeout crontinuous.Duration       `json:"execution_timeout,omitempty" yaml:"execution_timeout,omitempty"`
+	PingURL          string                     `json:"ping_url,omitempty" yaml:"ping_url,omitempty"`
+	Alerting         *crontinuous.AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	Jitter           crontinuous.Duration       `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	Windows          []string                   `json:"windows,omitempty" yaml:"windows,omitempty"`
+	WindowPolicy     crontinuous.WindowPolicy   `json:"window_policy,omitempty" yaml:"window_policy,omitempty"`
+	Template         string                     `json:"template,omitempty" yaml:"template,omitempty"`
+	Name             string                     `json:"name,omitempty" yaml:"name,omitempty"`
+	Description      string                     `json:"description,omitempty" yaml:"description,omitempty"`
+	Metadata         map[string]string          `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+// Bulk Settings
+func (h *handler) scanBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings := []createSetting{}
+	if err := decodeBody(r, &settings); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entries := []crontinuous.CronEntry{}
+	overwrite := []*bool{}
+	for _, s := range settings {
+		entries = append(entries, crontinuous.ScanEntry{
+			ID:       s.ID,
+			CronSpec: s.Str,
+			EntrySettings: crontinuous.EntrySettings{
+				ExecutionTimeout: s.ExecutionTimeout,
+				PingURL:          s.PingURL,
+				Alerting:         s.Alerting,
+				Jitter:           s.Jitter,
+				Windows:          s.Windows,
+				WindowPolicy:     s.WindowPolicy,
+				Template:         s.Template,
+				Name:             s.Name,
+				Description:      s.Description,
+				Metadata:         s.Metadata,
+			},
+			ProgramID: s.ProgramID,
+			TeamID:    s.TeamID,
+		})
+		overwrite = append(overwrite, s.Overwrite)
+	}
+
+	h.bulkSettingsHandler(crontinuous.ScanCronType, entries, overwrite, w, r, ps)
+}
+func (h *handler) reportBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings := []createSetting{}
+	if err := decodeBody(r, &settings); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entries := []crontinuous.CronEntry{}
+	overwrite := []*bool{}
+	for _, s := range settings {
+		entries = append(entries, crontinuous.ReportEntry{
+			ID:       s.ID,
+			CronSpec: s.Str,
+			EntrySettings: crontinuous.EntrySettings{
+				ExecutionTimeout: s.ExecutionTimeout,
+				PingURL:          s.PingURL,
+				Alerting:         s.Alerting,
+				Jitter:           s.Jitter,
+				Windows:          s.Windows,
+				WindowPolicy:     s.WindowPolicy,
+				Template:         s.Template,
+				Name:             s.Name,
+				Description:      s.Description,
+				Metadata:         s.Metadata,
+			},
+			TeamID: s.TeamID,
+		})
+		overwrite = append(overwrite, s.Overwrite)
+	}
+
+	h.bulkSettingsHandler(crontinuous.ReportCronType, entries, overwrite, w, r, ps)
+}
+func (h *handler) teamScanBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings := []createSetting{}
+	if err := decodeBody(r, &settings); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entries := []crontinuous.CronEntry{}
+	overwrite := []*bool{}
+	for _, s := range settings {
+		entries = append(entries, crontinuous.TeamScanEntry{
+			CronSpec: s.Str,
+			EntrySettings: crontinuous.EntrySettings{
+				ExecutionTimeout: s.ExecutionTimeout,
+				PingURL:          s.PingURL,
+				Alerting:         s.Alerting,
+				Jitter:           s.Jitter,
+				Windows:          s.Windows,
+				WindowPolicy:     s.WindowPolicy,
+				Template:         s.Template,
+				Name:             s.Name,
+				Description:      s.Description,
+				Metadata:         s.Metadata,
+			},
+			TeamID: s.TeamID,
+		})
+		overwrite = append(overwrite, s.Overwrite)
+	}
+
+	h.bulkSettingsHandler(crontinuous.TeamScanCronType, entries, overwrite, w, r, ps)
+}
+
+// bulkSettingsHandler creates the given entries or, if the mode query param
+// is given, replaces the entries in its scope with them. When creating, the
+// given overwrite settings of the entries, nil if not set, take precedence
+// over the overwrite query param, and the entries are created in background
+// if the async query param is true. The force query param skips the schedule
+// conflicts and scan capacity checks, as when saving an entry.
+func (h *handler) bulkSettingsHandler(typ crontinuous.CronType, entries []crontinuous.CronEntry, overwrite []*bool,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	preset, err := overwritePreset(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var async bool
+	if v := r.URL.Query().Get("async"); v != "" {
+		if async, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "Invalid async", http.StatusBadRequest)
+			return
+		}
+	}
+	var opts []crontinuous.SaveOption
+	if r.URL.Query().Get("force") == "true" {
+		opts = append(opts, crontinuous.IgnoreScheduleConflicts(), crontinuous.IgnoreScanCapacity())
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		if len(overwrite) != len(entries) {
+			writeError(w, crontinuous.ErrInvalidOverwrite)
+			return
+		}
+		policies := make([]crontinuous.OverwritePolicy, len(entries))
+		for i, o := range overwrite {
+			switch {
+			case o == nil:
+				policies[i] = preset
+			case *o:
+				policies[i] = crontinuous.OverwriteAll
+			default:
+				policies[i] = crontinuous.OverwriteMissingOnly
+			}
+		}
+		if async {
+			h.startBulkCreate(typ, entries, policies, opts, w, r)
+			return
+		}
+		if err := h.cron.BulkCreateWithOverwriteContext(r.Context(), typ, entries, policies, opts...); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+	if r.URL.Query().Get("overwrite") != "" {
+		writeError(w, fmt.Errorf("%w: overwrite not allowed with mode", crontinuous.ErrInvalidOverwrite))
+		return
+	}
+	if async {
+		writeError(w, fmt.Errorf("%w: mode not allowed in async operations", crontinuous.ErrInvalidBulkMode))
+		return
+	}
+	if teams := r.URL.Query()["team"]; len(teams) > 0 {
+		opts = append(opts, crontinuous.ReplaceTeams(teams...))
+	}
+
+	changes, err := h.cron.BulkReplaceContext(r.Context(), typ, entries, crontinuous.BulkMode(mode), opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeChangesResponse(changes, w, r)
+}
+
+// overwritePreset returns the overwrite policy of the entries of a bulk
+// creation not setting their own, given in the overwrite query param as
+// all, missing_only or none, or as true or false, the same as all and
+// missing_only. It defaults to missing_only.
+func overwritePreset(r *http.Request) (crontinuous.OverwritePolicy, error) {
+	v := r.URL.Query().Get("overwrite")
+	switch v {
+	case "":
+		return crontinuous.OverwriteMissingOnly, nil
+	case "true":
+		return crontinuous.OverwriteAll, nil
+	case "false":
+		return crontinuous.OverwriteMissingOnly, nil
+	}
+	if p := crontinuous.OverwritePolicy(v); p.Valid() {
+		return p, nil
+	}
+	return "", fmt.Errorf("%w: overwrite %q, want all, missing_only or none", crontinuous.ErrInvalidOverwrite, v)
+}
+
+// Setting
+func (h *handler) scanSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	programID := ps.ByName("programID")
+	if programID == "" {
+		http.Error(w, "Program ID missing", 400)
+		return
+	}
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	var c cronString
+	if err := decodeBody(r, &c); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entry := crontinuous.ScanEntry{
+		ID:        c.ID,
+		ProgramID: programID,
+		TeamID:    teamID,
+		CronSpec:  c.Str,
+		EntrySettings: crontinuous.EntrySettings{
+			ExecutionTimeout: c.ExecutionTimeout,
+			PingURL:          c.PingURL,
+			Alerting:         c.Alerting,
+			Jitter:           c.Jitter,
+			Windows:          c.Windows,
+			WindowPolicy:     c.WindowPolicy,
+			Template:         c.Template,
+			Name:             c.Name,
+			Description:      c.Description,
+			Metadata:         c.Metadata,
+		},
+	}
+
+	h.settingHandler(crontinuous.ScanCronType, entry, w, r, ps)
+}
+func (h *handler) reportSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	var c cronString
+	if err := decodeBody(r, &c); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entry := crontinuous.ReportEntry{
+		ID:       c.ID,
+		TeamID:   teamID,
+		CronSpec: c.Str,
+		EntrySettings: crontinuous.EntrySettings{
+			ExecutionTimeout: c.ExecutionTimeout,
+			PingURL:          c.PingURL,
+			Alerting:         c.Alerting,
+			Jitter:           c.Jitter,
+			Windows:          c.Windows,
+			WindowPolicy:     c.WindowPolicy,
+			Template:         c.Template,
+			Name:             c.Name,
+			Description:      c.Description,
+			Metadata:         c.Metadata,
+		},
+	}
+
+	h.settingHandler(crontinuous.ReportCronType, entry, w, r, ps)
+}
+func (h *handler) teamScanSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	var c cronString
+	if err := decodeBody(r, &c); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	entry := crontinuous.TeamScanEntry{
+		TeamID:   teamID,
+		CronSpec: c.Str,
+		EntrySettings: crontinuous.EntrySettings{
+			ExecutionTimeout: c.ExecutionTimeout,
+			PingURL:          c.PingURL,
+			Alerting:         c.Alerting,
+			Jitter:           c.Jitter,
+			Windows:          c.Windows,
+			WindowPolicy:     c.WindowPolicy,
+			Template:         c.Template,
+			Name:             c.Name,
+			Description:      c.Description,
+			Metadata:         c.Metadata,
+		},
+	}
+
+	h.settingHandler(crontinuous.TeamScanCronType, entry, w, r, ps)
+}
+func (h *handler) settingHandler(typ crontinuous.CronType, entry crontinuous.CronEntry,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var id string
+	opts := []crontinuous.SaveOption{
+		crontinuous.ChangedBy(r.Header.Get(changedByHeader)),
+		crontinuous.SavedID(&id),
+	}
+	if r.URL.Query().Get("force") == "true" {
+		opts = append(opts, crontinuous.IgnoreScheduleConflicts(), crontinuous.IgnoreScanCapacity())
+	}
+	var canary crontinuous.Canary
+	if mode := r.URL.Query().Get("canary"); mode != "" {
+		m, err := crontinuous.ParseCanaryMode(mode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, crontinuous.WithCanary(m, &canary))
+	}
+
+	if err := h.cron.SaveEntryContext(r.Context(), typ, entry, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	// The status is the one of the entry saved, which may have been given
+	// an ID and rewritten by the templates and the spec rules.
+	if saved, err := h.cron.GetEntryByID(typ, id); err == nil {
+		entry = saved
+	}
+
+	resp := saveResponse{ID: id, ScheduleStatus: h.cron.ScheduleStatus(typ, entry), Message: "stored and scheduled"}
+	if !resp.Scheduled {
+		resp.Message = fmt.Sprintf("stored but not scheduled (%s)", resp.UnscheduledReason)
+	}
+	if canary.ID != "" {
+		resp.Canary = &canary
+	}
+	if err := encodeResponse(w, r, resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// saveResponse is the response of the endpoints saving an entry, telling
+// whether the entry was scheduled or only stored.
+type saveResponse struct {
+	// ID is the ID of the entry saved.
+	ID                         string `json:"id" yaml:"id"`
+	crontinuous.ScheduleStatus `yaml:",inline"`
+	Message                    string `json:"message" yaml:"message"`
+	// Canary is the first execution of the entry started when it is
+	// created with the canary param, which can be followed in
+	// /canaries/:id.
+	Canary *crontinuous.Canary `json:"canary,omitempty" yaml:"canary,omitempty"`
+}
+
+// The entries are returned with their schedule status, so the UIs can
+// show why an entry is not active.
+type (
+	scanEntryStatus struct {
+		crontinuous.ScanEntry      `yaml:",inline"`
+		crontinuous.ScheduleStatus `yaml:",inline"`
+	}
+	reportEntryStatus struct {
+		crontinuous.ReportEntry    `yaml:",inline"`
+		crontinuous.ScheduleStatus `yaml:",inline"`
+	}
+	teamScanEntryStatus struct {
+		crontinuous.TeamScanEntry  `yaml:",inline"`
+		crontinuous.ScheduleStatus `yaml:",inline"`
+	}
+	commandEntryStatus struct {
+		crontinuous.CommandEntry   `yaml:",inline"`
+		crontinuous.ScheduleStatus `yaml:",inline"`
+	}
+)
+
+// withStatus returns the given entry together with its schedule status.
+func (h *handler) withStatus(typ crontinuous.CronType, entry crontinuous.CronEntry) interface{} {
+	status := h.cron.ScheduleStatus(typ, entry)
+	switch e := entry.(type) {
+	case crontinuous.ScanEntry:
+		return scanEntryStatus{e, status}
+	case crontinuous.ReportEntry:
+		return reportEntryStatus{e, status}
+	case crontinuous.TeamScanEntry:
+		return teamScanEntryStatus{e, status}
+	case crontinuous.CommandEntry:
+		return commandEntryStatus{e, status}
+	}
+	return entry
+}
+
+// The entries requested by their ID are returned with their complete state,
+// so a single request tells whether they are executed and when.
+type (
+	scanEntryState struct {
+		crontinuous.ScanEntry  `yaml:",inline"`
+		crontinuous.EntryState `yaml:",inline"`
+	}
+	reportEntryState struct {
+		crontinuous.ReportEntry `yaml:",inline"`
+		crontinuous.EntryState  `yaml:",inline"`
+	}
+	teamScanEntryState struct {
+		crontinuous.TeamScanEntry `yaml:",inline"`
+		crontinuous.EntryState    `yaml:",inline"`
+	}
+	commandEntryState struct {
+		crontinuous.CommandEntry `yaml:",inline"`
+		crontinuous.EntryState   `yaml:",inline"`
+	}
+)
+
+// withState returns the given entry together with the given state.
+func withState(entry crontinuous.CronEntry, state crontinuous.EntryState) interface{} {
+	switch e := entry.(type) {
+	case crontinuous.ScanEntry:
+		return scanEntryState{e, state}
+	case crontinuous.ReportEntry:
+		return reportEntryState{e, state}
+	case crontinuous.TeamScanEntry:
+		return teamScanEntryState{e, state}
+	case crontinuous.CommandEntry:
+		return commandEntryState{e, state}
+	}
+	return entry
+}
+
+// Remove Schedule
+func (h *handler) removeScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("programID")
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.removeScheduleHandler(crontinuous.ScanCronType, id, w, r, ps)
+}
+func (h *handler) removeReportScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := h.cron.ResolveReportID(ps.ByName("reportID"))
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.removeScheduleHandler(crontinuous.ReportCronType, id, w, r, ps)
+}
+func (h *handler) removeTeamScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("teamID")
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.removeScheduleHandler(crontinuous.TeamScanCronType, id, w, r, ps)
+}
+func (h *handler) removeScheduleHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	err := h.cron.RemoveEntry(typ, id, crontinuous.ChangedBy(r.Header.Get(changedByHeader)))
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// Bulk remove
+
+type bulkRemoveResponse struct {
+	Removed []crontinuous.Change `json:"removed" yaml:"removed"`
+	Missing []string             `json:"missing" yaml:"missing"`
+}
+
+func (h *handler) bulkRemoveScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.bulkRemoveHandler(crontinuous.ScanCronType, w, r, ps)
+}
+func (h *handler) bulkRemoveReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.bulkRemoveHandler(crontinuous.ReportCronType, w, r, ps)
+}
+func (h *handler) bulkRemoveTeamScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.bulkRemoveHandler(crontinuous.TeamScanCronType, w, r, ps)
+}
+func (h *handler) bulkRemoveHandler(typ crontinuous.CronType,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var req lookupRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxLookupIDs {
+		http.Error(w, fmt.Sprintf("More than %d IDs", maxLookupIDs), http.StatusBadRequest)
+		return
+	}
+
+	removed, missing, err := h.cron.RemoveEntries(typ, req.IDs, crontinuous.ChangedBy(r.Header.Get(changedByHeader)))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if missing == nil {
+		missing = []string{}
+	}
+	err = encodeResponse(w, r, bulkRemoveResponse{Removed: removed, Missing: missing})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Run Schedule
+func (h *handler) runScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("programID")
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.runScheduleHandler(crontinuous.ScanCronType, id, w, r, ps)
+}
+func (h *handler) runReportScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := h.cron.ResolveReportID(ps.ByName("reportID"))
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.runScheduleHandler(crontinuous.ReportCronType, id, w, r, ps)
+}
+func (h *handler) runTeamScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("teamID")
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.runScheduleHandler(crontinuous.TeamScanCronType, id, w, r, ps)
+}
+func (h *handler) runScheduleHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	err := h.cron.RunEntryContext(r.Context(), typ, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Get Schedules
+func (h *handler) getScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.getSchedulesHandler(crontinuous.ScanCronType, w, r, ps)
+}
+func (h *handler) getReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.getSchedulesHandler(crontinuous.ReportCronType, w, r, ps)
+}
+func (h *handler) getTeamScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.getSchedulesHandler(crontinuous.TeamScanCronType, w, r, ps)
+}
+
+// teamReportSchedulesHandler returns the report entries of a team, as it can
+// have many.
+func (h *handler) teamReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	withStatus := []interface{}{}
+	for _, e := range h.cron.TeamReportEntries(teamID) {
+		if crontinuous.MatchEntry(e, q) {
+			withStatus = append(withStatus, h.withStatus(crontinuous.ReportCronType, e))
+		}
+	}
+	if err := encodeResponse(w, r, &withStatus); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// teamEntries are the entries of a team, with their status.
+type teamEntries struct {
+	TeamID  string        `json:"team_id" yaml:"team_id"`
+	Scans   []interface{} `json:"scans" yaml:"scans"`
+	Reports []interface{} `json:"reports" yaml:"reports"`
+}
+
+// teamsEntriesHandler returns the scan and report entries of the given teams
+// grouped by team, so the entries of many teams are got in one request.
+func (h *handler) teamsEntriesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req lookupRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxLookupIDs {
+		http.Error(w, fmt.Sprintf("More than %d IDs", maxLookupIDs), http.StatusBadRequest)
+		return
+	}
+
+	teams := []teamEntries{}
+	for _, te := range h.cron.EntriesOfTeams(req.IDs) {
+		withStatus := teamEntries{
+			TeamID:  te.TeamID,
+			Scans:   make([]interface{}, len(te.Scans)),
+			Reports: make([]interface{}, len(te.Reports)),
+		}
+		for i, e := range te.Scans {
+			withStatus.Scans[i] = h.withStatus(crontinuous.ScanCronType, e)
+		}
+		for i, e := range te.Reports {
+			withStatus.Reports[i] = h.withStatus(crontinuous.ReportCronType, e)
+		}
+		teams = append(teams, withStatus)
+	}
+	if err := encodeResponse(w, r, teams); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) getSchedulesHandler(typ crontinuous.CronType,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	var (
+		entries []crontinuous.CronEntry
+		since   time.Time
+		err     error
+	)
+	v := r.URL.Query().Get("changed_since")
+	if v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			http.Error(w, "Invalid changed_since param", 400)
+			return
+		}
+	}
+	q := r.URL.Query().Get("q")
+	if acceptsNDJSON(r) {
+		h.streamSchedules(typ, since, q, w)
+		return
+	}
+	if v != "" {
+		entries, err = h.cron.GetEntriesChangedSince(typ, since)
+	} else {
+		entries, err = h.cron.GetEntries(typ)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	withStatus := []interface{}{}
+	for _, e := range entries {
+		if crontinuous.MatchEntry(e, q) {
+			withStatus = append(withStatus, h.withStatus(typ, e))
+		}
+	}
+
+	err = encodeResponse(w, r, &withStatus)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// streamChunkSize is the number of entries read at once when streaming them.
+const streamChunkSize = 500
+
+// streamSchedules writes the entries of the given type changed after the
+// given time, all of them if zero, matching the given query, as newline
+// delimited JSON, one entry with its status per line. The entries are read and flushed in chunks, so the
+// response is written without holding all the entries in memory nor locking
+// them for the whole request.
+func (h *handler) streamSchedules(typ crontinuous.CronType, since time.Time, q string, w http.ResponseWriter) {
+	var written bool
+	w.Header().Set("Content-Type", ndjsonContentType)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	err := h.cron.StreamEntries(typ, since, streamChunkSize, func(entries []crontinuous.CronEntry) error {
+		written = true
+		for _, e := range entries {
+			if !crontinuous.MatchEntry(e, q) {
+				continue
+			}
+			if err := enc.Encode(h.withStatus(typ, e)); err != nil {
+				return err
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	// Once the response is being written, the errors can only come from
+	// the client going away, so there is nobody to report them to.
+	if err != nil && !written {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Lookup
+
+// maxLookupIDs is the maximum number of entries looked up in one request.
+const maxLookupIDs = 10000
+
+type lookupRequest struct {
+	IDs []string `json:"ids" yaml:"ids"`
+}
+
+type lookupResponse struct {
+	Found   []crontinuous.CronEntry `json:"found" yaml:"found"`
+	Missing []string                `json:"missing" yaml:"missing"`
+}
+
+// The router does not allow to register /entries/lookup together with
+// /entries/:programID/run, so the lookup is served by POST /entries/:ID.
+func (h *handler) lookupScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if ps.ByName("programID") == "import" {
+		// The router does not allow to register /entries/import
+		// together with /entries/:programID.
+		h.writes(h.importHandler)(w, r, ps)
+		return
+	}
+	h.lookupHandler(crontinuous.ScanCronType, ps.ByName("programID"), w, r, ps)
+}
+func (h *handler) lookupReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.lookupHandler(crontinuous.ReportCronType, ps.ByName("reportID"), w, r, ps)
+}
+func (h *handler) lookupTeamScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.lookupHandler(crontinuous.TeamScanCronType, ps.ByName("teamID"), w, r, ps)
+}
+func (h *handler) lookupHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	if id != "lookup" {
+		http.NotFound(w, r)
+		return
+	}
+	var req lookupRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > maxLookupIDs {
+		http.Error(w, fmt.Sprintf("More than %d IDs", maxLookupIDs), http.StatusBadRequest)
+		return
+	}
+
+	found, missing, err := h.cron.LookupEntries(typ, req.IDs)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	err = encodeResponse(w, r, lookupResponse{Found: found, Missing: missing})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Get Schedule by ID
+func (h *handler) getScanScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("programID")
+	if id == "export" {
+		// The router does not allow to register /entries/export
+		// together with /entries/:programID.
+		h.exportHandler(w, r, ps)
+		return
+	}
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.getScheduleByIDHandler(crontinuous.ScanCronType, id, w, r, ps)
+}
+func (h *handler) getReportScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := h.cron.ResolveReportID(ps.ByName("reportID"))
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.getScheduleByIDHandler(crontinuous.ReportCronType, id, w, r, ps)
+}
+func (h *handler) getTeamScanScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id := ps.ByName("teamID")
+	if id == "" {
+		http.Error(w, "Bad request", 400)
+		return
+	}
+
+	h.getScheduleByIDHandler(crontinuous.TeamScanCronType, id, w, r, ps)
+}
+func (h *handler) getScheduleByIDHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	entry, state, err := h.cron.GetEntryState(typ, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = encodeResponse(w, r, withState(entry, state))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// History
+func (h *handler) getScanHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.historyHandler(crontinuous.ScanCronType, ps.ByName("programID"), w, r, ps)
+}
+func (h *handler) getReportHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.historyHandler(crontinuous.ReportCronType, h.cron.ResolveReportID(ps.ByName("reportID")), w, r, ps)
+}
+func (h *handler) getTeamScanHistoryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.historyHandler(crontinuous.TeamScanCronType, ps.ByName("teamID"), w, r, ps)
+}
+func (h *handler) historyHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	revisions, err := h.cron.History(typ, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []crontinuous.Revision{}
+	}
+
+	encoder := json.NewEncoder(w)
+	err = encoder.Encode(&revisions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Revert
+func (h *handler) revertScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.revertHandler(crontinuous.ScanCronType, ps.ByName("programID"), w, r, ps)
+}
+func (h *handler) revertReportScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.revertHandler(crontinuous.ReportCronType, h.cron.ResolveReportID(ps.ByName("reportID")), w, r, ps)
+}
+func (h *handler) revertTeamScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.revertHandler(crontinuous.TeamScanCronType, ps.ByName("teamID"), w, r, ps)
+}
+func (h *handler) revertHandler(typ crontinuous.CronType, id string,
+	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+
+	revision, err := strconv.Atoi(r.URL.Query().Get("revision"))
+	if err != nil {
+		http.Error(w, "Invalid revision param", 400)
+		return
+	}
+
+	opts := []crontinuous.SaveOption{crontinuous.ChangedBy(r.Header.Get(changedByHeader))}
+	if r.URL.Query().Get("force") == "true" {
+		opts = append(opts, crontinuous.IgnoreScheduleConflicts(), crontinuous.IgnoreScanCapacity())
+	}
+
+	entry, err := h.cron.Revert(typ, id, revision, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if entry == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	err = encodeResponse(w, r, entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Transfer
+type transferRequest struct {
+	TeamID string `json:"team_id" yaml:"team_id"`
+}
+
+func (h *handler) transferScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req transferRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if req.TeamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	opts := []crontinuous.SaveOption{crontinuous.ChangedBy(r.Header.Get(changedByHeader))}
+	if r.URL.Query().Get("force") == "true" {
+		opts = append(opts, crontinuous.IgnoreScheduleConflicts())
+	}
+
+	entry, err := h.cron.TransferScanEntryContext(r.Context(), ps.ByName("programID"), req.TeamID, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	err = encodeResponse(w, r, h.withStatus(crontinuous.ScanCronType, entry))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type deriveReportsRequest struct {
+	Delay     crontinuous.Duration `json:"delay" yaml:"delay"`
+	Overwrite bool                 `json:"overwrite" yaml:"overwrite"`
+}
+
+// deriveReportSchedulesHandler creates the report entries of the teams with
+// scans, after their latest scan, and returns the changes performed.
+func (h *handler) deriveReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req deriveReportsRequest
+	if err := decodeBody(r, &req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	opts := []crontinuous.SaveOption{crontinuous.ChangedBy(r.Header.Get(changedByHeader))}
+	if r.URL.Query().Get("force") == "true" {
+		opts = append(opts, crontinuous.IgnoreScheduleConflicts())
+	}
+
+	changes, err := h.cron.DeriveReportEntries(time.Duration(req.Delay), req.Overwrite, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeChangesResponse(changes, w, r)
+}
+
+// Simulate
+func (h *handler) simulateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	writeSimulation(h.cron.Simulate, w, r)
+}
+func writeSimulation(simulate func(from, to time.Time) ([]crontinuous.PlannedExecution, error),
+	w http.ResponseWriter, r *http.Request) {
+
+	from, to, ok := timeWindow(w, r, 24*time.Hour)
+	if !ok {
+		return
+	}
+
+	executions, err := simulate(from, to)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	err = encoder.Encode(&executions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// timeWindow returns the window defined by the from and to params of the
+// request, that default to now and the given duration after from. It writes
+// the error and returns false if the params are invalid.
+func timeWindow(w http.ResponseWriter, r *http.Request, def time.Duration) (time.Time, time.Time, bool) {
+	from := time.Now()
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid from param", 400)
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	to := from.Add(def)
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid to param", 400)
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	return from, to, true
+}
+
+// Calendar
+func (h *handler) calendarHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	from, to, ok := timeWindow(w, r, 7*24*time.Hour)
+	if !ok {
+		return
+	}
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			http.Error(w, "Invalid tz param", 400)
+			return
+		}
+		loc = l
+	}
+
+	cal, err := h.cron.Calendar(from, to, loc)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	err = encoder.Encode(&cal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Git sync
+func (h *handler) gitSyncStatusHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if h.gitSyncer == nil {
+		http.Error(w, "Git sync not enabled", http.StatusNotFound)
+		return
+	}
+
+	status := h.gitSyncer.Status()
+	encoder := json.NewEncoder(w)
+	err := encoder.Encode(&status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Export
+func (h *handler) exportHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "csv" && format != "crontab" {
+		http.Error(w, "Unsupported format", 400)
+		return
+	}
+
+	entries, err := h.cron.Export(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if format == "crontab" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="crontab"`)
+		if err := crontinuous.WriteCrontab(w, entries); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="entries.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"type", "id", "team_id", "cron_spec", "description", "next_run"}) // nolint
+	for _, e := range entries {
+		var nextRun string
+		if !e.NextRun.IsZero() {
+			nextRun = e.NextRun.UTC().Format(time.RFC3339)
+		}
+		cw.Write([]string{e.Type.String(), e.ID, e.TeamID, e.CronSpec, e.Description, nextRun}) // nolint
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// importHandler creates the scan entries of the crontab in the body. Like
+// the bulk creation, the existing entries are treated as the overwrite query
+// parameter tells, and the mode query parameter replaces the entries instead.
+func (h *handler) importHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !isCrontab(r.Header.Get("Content-Type")) {
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	scans, err := crontinuous.ParseCrontab(r.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries := []crontinuous.CronEntry{}
+	for _, e := range scans {
+		entries = append(entries, e)
+	}
+	h.bulkSettingsHandler(crontinuous.ScanCronType, entries, make([]*bool, len(entries)), w, r, ps)
+}
+
+// scanDensityHandler returns how close the scans created by the schedule are
+// to the capacity of vulcan-api.
+func (h *handler) scanDensityHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	density, err := h.cron.ScanDensity()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := encodeResponse(w, r, &density); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Spec rules
+func (h *handler) getSpecRulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	rules := h.cron.SpecRules()
+	if err := encodeResponse(w, r, &rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// specRulesPreviewRequest defines the entry the spec rules are previewed for.
+type specRulesPreviewRequest struct {
+	Type  crontinuous.CronType `json:"type"`
+	Entry json.RawMessage      `json:"entry"`
+}
+
+// previewSpecRulesHandler returns how the spec rules rewrite the given entry,
+// without saving it.
+func (h *handler) previewSpecRulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var req specRulesPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := crontinuous.UnmarshalEntry(req.Type, req.Entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rw, err := h.cron.PreviewSpecRules(req.Type, entry)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, &rw); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Spec aliases
+func (h *handler) getSpecAliasesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	aliases := h.cron.SpecAliases()
+	if err := encodeResponse(w, r, &aliases); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Whitelists
+
+// previewWhitelistsHandler returns the stored entries that the given
+// whitelists would schedule or unschedule, without applying them.
+func (h *handler) previewWhitelistsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var proposal crontinuous.WhitelistProposal
+	if err := decodeBody(r, &proposal); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	impact, err := h.cron.PreviewWhitelists(proposal)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, &impact); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Coverage
+func (h *handler) coverageHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teams, err := crontinuous.ListTeams(r.Context(), h.teamLister)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	h.writeCoverage(teams, w, r)
+}
+func (h *handler) uploadedTeamsCoverageHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teams := []string{}
+	if err := decodeBody(r, &teams); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	h.writeCoverage(teams, w, r)
+}
+func (h *handler) writeCoverage(teams []string, w http.ResponseWriter, r *http.Request) {
+	report, err := h.cron.Coverage(teams)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = encodeResponse(w, r, &report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Provision
+func (h *handler) provisionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if h.provisioner == nil {
+		http.Error(w, "Provisioning not enabled", http.StatusNotFound)
+		return
+	}
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	created, err := h.provisioner.Provision(teamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if created == nil {
+		created = []crontinuous.CronEntry{}
+	}
+	err = encodeResponse(w, r, &created)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Staging
+func (h *handler) manifestHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	m, err := h.cron.CurrentManifest()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeManifest(m, w, r)
+}
+func (h *handler) getStagedHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	m, err := h.cron.Staged()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeManifest(m, w, r)
+}
+func writeManifest(m crontinuous.Manifest, w http.ResponseWriter, r *http.Request) {
+	if m.Scans == nil {
+		m.Scans = []crontinuous.ScanEntry{}
+	}
+	if m.Reports == nil {
+		m.Reports = []crontinuous.ReportEntry{}
+	}
+	err := encodeResponse(w, r, &m)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+func (h *handler) stageHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var m crontinuous.Manifest
+	if err := decodeBody(r, &m); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := h.cron.Stage(m); err != nil {
+		writeError(w, err)
+	}
+}
+func (h *handler) discardStagedHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.cron.DiscardStaged()
+}
+func (h *handler) stagedChangesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	changes, err := h.cron.StagedChanges()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeChangesResponse(changes, w, r)
+}
+func (h *handler) simulateStagedHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	writeSimulation(h.cron.SimulateStaged, w, r)
+}
+func (h *handler) swapStagedHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	changes, err := h.cron.SwapStaged()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeChangesResponse(changes, w, r)
+}
+func writeChangesResponse(changes []crontinuous.Change, w http.ResponseWriter, r *http.Request) {
+	if changes == nil {
+		changes = []crontinuous.Change{}
+	}
+	err := encodeResponse(w, r, &changes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// maxIdempotencyKeys is the maximum number of idempotency keys remembered.
+// When exceeded, the oldest ones are forgotten before their window ends.
+const maxIdempotencyKeys = 10000
+
+// idempotentResponse is the response to the first request with an
+// idempotency key.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	expires     time.Time
+	// pending is true while the first request is being served.
+	pending bool
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+// idempotencyKey is an idempotency key queued to be forgotten.
+type idempotencyKey struct {
+	key     string
+	expires time.Time
+}
+
+// idempotencyCache remembers the responses to the POST requests carrying an
+// idempotency key during a window, so the retries of a request, e.g. by a
+// load balancer or a client after a timeout, get the same response without
+// applying the request again.
+type idempotencyCache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mux       sync.Mutex
+	responses map[string]*idempotentResponse
+	// keys holds the keys in the order they expire.
+	keys []idempotencyKey
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		window:    window,
+		now:       time.Now,
+		responses: map[string]*idempotentResponse{},
+	}
+}
+
+// withIdempotency wraps the given handler so the responses to the POST
+// requests with an idempotency key are replayed to the requests with the same
+// key, method, path and body during the given window. Requests reusing a key
+// with another endpoint or body are rejected with a 422 status, and the ones
+// arriving while the first is still being served with a 409 status. The
+// responses with a 5xx status are not remembered, so those requests can be
+// retried. If the window is zero the requests are not deduplicated.
+func withIdempotency(window time.Duration, next http.Handler) http.Handler {
+	if window <= 0 {
+		return next
+	}
+	return newIdempotencyCache(window).wrap(next)
+}
+
+func (c *idempotencyCache) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(crontinuous.IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validRequestID(key) {
+			http.Error(w, crontinuous.ErrMalformedIdempotencyKey.Error(), http.StatusBadRequest)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := requestFingerprint(r, body)
+		resp, pending := c.begin(key, fingerprint)
+		switch {
+		case pending != nil:
+		case resp.fingerprint != fingerprint:
+			http.Error(w, crontinuous.ErrIdempotencyKeyReused.Error(), http.StatusUnprocessableEntity)
+			return
+		case resp.pending:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, crontinuous.ErrIdempotencyKeyInUse.Error(), http.StatusConflict)
+			return
+		default:
+			resp.replay(w)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w}
+		defer func() {
+			// The key is released even if the handler panics,
+			// so the request can be retried.
+			c.end(key, pending, rec)
+		}()
+		next.ServeHTTP(rec, r)
+		rec.completed = true
+	})
+}
+
+// begin returns the response remembered for the given key, if any.
+// Otherwise, it remembers the key as pending and returns its response.
+func (c *idempotencyCache) begin(key string, fingerprint [sha256.Size]byte) (idempotentResponse, *idempotentResponse) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	now := c.now()
+	c.forget(now)
+	if resp, ok := c.responses[key]; ok {
+		return *resp, nil
+	}
+	expires := now.Add(c.window)
+	pending := &idempotentResponse{fingerprint: fingerprint, expires: expires, pending: true}
+	c.responses[key] = pending
+	c.keys = append(c.keys, idempotencyKey{key: key, expires: expires})
+	return idempotentResponse{}, pending
+}
+
+// end remembers the response recorded for the given pending key or, if the
+// response was not completed or has a 5xx status, forgets the key. Nothing
+// is remembered if the key was forgotten meanwhile.
+func (c *idempotencyCache) end(key string, resp *idempotentResponse, rec *recordingWriter) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.responses[key] != resp {
+		return
+	}
+	status := rec.statusCode()
+	if !rec.completed || status >= http.StatusInternalServerError {
+		delete(c.responses, key)
+		return
+	}
+	resp.pending = false
+	resp.status = status
+	resp.header = rec.Header().Clone()
+	resp.body = rec.body.Bytes()
+}
+
+// forget removes the keys whose window ended, and the oldest ones while
+// there are too many. It must be called holding the lock of the cache.
+func (c *idempotencyCache) forget(now time.Time) {
+	n := 0
+	for ; n < len(c.keys); n++ {
+		k := c.keys[n]
+		if k.expires.After(now) && len(c.keys)-n < maxIdempotencyKeys {
+			break
+		}
+		// The key may have been forgotten and used again.
+		if resp, ok := c.responses[k.key]; ok && resp.expires.Equal(k.expires) {
+			delete(c.responses, k.key)
+		}
+	}
+	c.keys = c.keys[n:]
+}
+
+// replay writes the remembered response, except the request ID of the
+// first request, marking it as replayed.
+func (resp idempotentResponse) replay(w http.ResponseWriter) {
+	for name, values := range resp.header {
+		if name == http.CanonicalHeaderKey(crontinuous.RequestIDHeader) {
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(crontinuous.IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body) // nolint
+}
+
+// requestFingerprint returns a hash of the method, URL and body of the
+// given request.
+func requestFingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n")) // nolint
+	h.Write(body)                                               // nolint
+	var fingerprint [sha256.Size]byte
+	copy(fingerprint[:], h.Sum(nil))
+	return fingerprint
+}
+
+// recordingWriter is an http.ResponseWriter recording
+// the response written to the wrapped one.
+type recordingWriter struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	completed bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b) // nolint
+	return w.ResponseWriter.Write(b)
+}
+
+// statusCode returns the status of the response, which is 200 if the
+// handler did not write any.
+func (w *recordingWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
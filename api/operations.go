@@ -0,0 +1,62 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// startBulkCreate starts the creation of the given entries in background
+// and responds with the operation started, which can be followed in
+// /operations/:id.
+func (h *handler) startBulkCreate(typ crontinuous.CronType, entries []crontinuous.CronEntry,
+	policies []crontinuous.OverwritePolicy, opts []crontinuous.SaveOption, w http.ResponseWriter, r *http.Request) {
+
+	op, err := h.cron.StartBulkCreate(typ, entries, policies, opts...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/operations/"+op.ID)
+	if acceptsYAML(r) {
+		// The content type must be set before writing the status.
+		w.Header().Set("Content-Type", yamlContentType)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	if err := encodeResponse(w, r, op); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// operationHandler returns the operation with the given ID, with the progress
+// and the results of its entries.
+func (h *handler) operationHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	op, err := h.cron.Operation(ps.ByName("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, op); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// cancelOperationHandler cancels the operation with the given ID and returns
+// it. The operation is cancelled once the entries being created are created,
+// so its status may still be running in the response.
+func (h *handler) cancelOperationHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	op, err := h.cron.CancelOperation(ps.ByName("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, op); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// pauseWindowStatus is a pause window together with whether it is active.
+type pauseWindowStatus struct {
+	crontinuous.PauseWindow `yaml:",inline"`
+	Active                  bool `json:"active" yaml:"active"`
+}
+
+func (h *handler) getPauseWindowsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	windows, err := h.cron.PauseWindows()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	now := time.Now()
+	statuses := make([]pauseWindowStatus, len(windows))
+	for i, pw := range windows {
+		statuses[i] = pauseWindowStatus{PauseWindow: pw, Active: pw.ActiveAt(now)}
+	}
+	if err := encodeResponse(w, r, statuses); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) getPauseWindowHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	pw, err := h.cron.PauseWindow(ps.ByName("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := pauseWindowStatus{PauseWindow: pw, Active: pw.ActiveAt(time.Now())}
+	if err := encodeResponse(w, r, status); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) savePauseWindowHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var pw crontinuous.PauseWindow
+	if err := decodeBody(r, &pw); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := ps.ByName("name")
+	if pw.Name != "" && pw.Name != name {
+		http.Error(w, "Pause window name does not match the path", http.StatusBadRequest)
+		return
+	}
+	pw.Name = name
+
+	if err := h.cron.SavePauseWindow(pw); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *handler) removePauseWindowHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.cron.RemovePauseWindow(ps.ByName("name")); err != nil {
+		writeError(w, err)
+	}
+}
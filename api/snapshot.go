@@ -0,0 +1,20 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// snapshotHandler returns the scan and report entries, the whitelists and
+// the paused state of the instance captured at one instant.
+func (h *handler) snapshotHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	snapshot := h.cron.Snapshot()
+	if err := encodeResponse(w, r, &snapshot); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
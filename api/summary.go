@@ -0,0 +1,30 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// teamSummaryHandler returns the entries of a team with their state in a
+// single document.
+func (h *handler) teamSummaryHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	teamID := ps.ByName("teamID")
+	if teamID == "" {
+		http.Error(w, "Team ID missing", 400)
+		return
+	}
+
+	summary, err := h.cron.TeamSummary(teamID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := encodeResponse(w, r, &summary); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
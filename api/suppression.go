@@ -0,0 +1,48 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// suppressHandler creates a suppression and returns it with its ID and
+// expiration.
+func (h *handler) suppressHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var s crontinuous.Suppression
+	if err := decodeBody(r, &s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s, err := h.cron.Suppress(s)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, s); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) getSuppressionsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	suppressions, err := h.cron.Suppressions()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, suppressions); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) unsuppressHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.cron.Unsuppress(ps.ByName("id")); err != nil {
+		writeError(w, err)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+func (h *handler) getAllTeamDefaultsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	defaults, err := h.cron.AllTeamDefaults()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, defaults); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) getTeamDefaultsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	d, err := h.cron.TeamDefaults(ps.ByName("teamID"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) saveTeamDefaultsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var d crontinuous.TeamDefaults
+	if err := decodeBody(r, &d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	teamID := ps.ByName("teamID")
+	if d.TeamID != "" && d.TeamID != teamID {
+		http.Error(w, "Team ID does not match the path", http.StatusBadRequest)
+		return
+	}
+	d.TeamID = teamID
+
+	if err := h.cron.SaveTeamDefaults(d); err != nil {
+		writeError(w, err)
+	}
+}
+
+func (h *handler) removeTeamDefaultsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.cron.RemoveTeamDefaults(ps.ByName("teamID")); err != nil {
+		writeError(w, err)
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+func (h *handler) getTemplatesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	templates, err := h.cron.Templates()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, templates); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (h *handler) getTemplateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	t, err := h.cron.Template(ps.ByName("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := encodeResponse(w, r, t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// saveTemplateHandler creates or updates a template and returns the changes
+// performed to the entries referencing it.
+func (h *handler) saveTemplateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	var t crontinuous.Template
+	if err := decodeBody(r, &t); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := ps.ByName("name")
+	if t.Name != "" && t.Name != name {
+		http.Error(w, "Template name does not match the path", http.StatusBadRequest)
+		return
+	}
+	t.Name = name
+
+	changes, err := h.cron.SaveTemplate(t, crontinuous.ChangedBy(r.Header.Get(changedByHeader)))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeChangesResponse(changes, w, r)
+}
+
+func (h *handler) removeTemplateHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if err := h.cron.RemoveTemplate(ps.ByName("name")); err != nil {
+		writeError(w, err)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// Timeouts defines the time the endpoints have to start writing their
+// responses. The requests exceeding it get a 504 status. Zero disables the
+// timeout.
+type Timeouts struct {
+	// Read applies to the GET endpoints.
+	Read time.Duration
+	// Write applies to the rest of the endpoints.
+	Write time.Duration
+	// Endpoints overrides the timeout of the endpoints given as the method
+	// and the path of their route, e.g. "POST /entries" or
+	// "GET /entries/:programID". The lookup, import and export endpoints
+	// are served by the routes of the entries, so their timeouts are the
+	// ones of "POST /entries/:programID", for the lookup and the import,
+	// and "GET /entries/:programID", for the export.
+	Endpoints map[string]time.Duration
+}
+
+// of returns the timeout of the endpoint with the given method and route.
+func (t Timeouts) of(method, path string) time.Duration {
+	if d, ok := t.Endpoints[method+" "+path]; ok {
+		return d
+	}
+	if method == http.MethodGet {
+		return t.Read
+	}
+	return t.Write
+}
+
+// ParseEndpointTimeout parses the timeout of an endpoint given as
+// "<METHOD> <route>=<duration>", e.g. "POST /entries=30s", and returns the
+// endpoint, as expected by Timeouts, and its timeout.
+func ParseEndpointTimeout(s string) (string, time.Duration, error) {
+	endpoint, timeout, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", 0, fmt.Errorf("endpoint timeout %q has no duration", s)
+	}
+	method, path, ok := strings.Cut(endpoint, " ")
+	if !ok || method == "" || method != strings.ToUpper(method) || !strings.HasPrefix(path, "/") {
+		return "", 0, fmt.Errorf("endpoint timeout %q has no method and route", s)
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil || d < 0 {
+		return "", 0, fmt.Errorf("endpoint timeout %q has an invalid duration", s)
+	}
+	return endpoint, d, nil
+}
+
+// timeoutResponse is the body of the responses to the requests timed out.
+type timeoutResponse struct {
+	Error   string `json:"error"`
+	Timeout string `json:"timeout"`
+}
+
+// routes registers the endpoints in the wrapped router, applying
+// their timeouts.
+type routes struct {
+	*httprouter.Router
+	timeouts Timeouts
+}
+
+func (r routes) GET(path string, h httprouter.Handle) {
+	r.Router.GET(path, withTimeout(r.timeouts.of(http.MethodGet, path), h))
+}
+
+func (r routes) POST(path string, h httprouter.Handle) {
+	r.Router.POST(path, withTimeout(r.timeouts.of(http.MethodPost, path), h))
+}
+
+func (r routes) PUT(path string, h httprouter.Handle) {
+	r.Router.PUT(path, withTimeout(r.timeouts.of(http.MethodPut, path), h))
+}
+
+func (r routes) DELETE(path string, h httprouter.Handle) {
+	r.Router.DELETE(path, withTimeout(r.timeouts.of(http.MethodDelete, path), h))
+}
+
+// withTimeout wraps the given handler so, if it does not start writing the
+// response in the given time, the request gets a 504 status with a
+// timeoutResponse and the context of the request is canceled. The handler
+// keeps running until it returns, so a change being saved to the store when
+// the request times out may still be applied. Once the handler starts
+// writing the response, e.g. when streaming the entries, it is not
+// interrupted. If the timeout is zero the handler is returned as is.
+func withTimeout(timeout time.Duration, next httprouter.Handle) httprouter.Handle {
+	if timeout <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		// The context is canceled once the request is marked as timed out,
+		// so the handler can not start the response meanwhile.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		tw := &timeoutWriter{w: w, header: http.Header{}}
+		done := make(chan struct{})
+		panics := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panics <- p
+				}
+			}()
+			next(tw, r.WithContext(ctx), ps)
+			close(done)
+		}()
+
+		select {
+		case p := <-panics:
+			panic(p)
+		case <-done:
+			return
+		case <-timer.C:
+		}
+		timedOut := tw.timeout()
+		cancel()
+		if !timedOut {
+			// The response is being written.
+			select {
+			case p := <-panics:
+				panic(p)
+			case <-done:
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusGatewayTimeout)
+		json.NewEncoder(w).Encode(timeoutResponse{ // nolint
+			Error:   crontinuous.ErrRequestTimeout.Error(),
+			Timeout: timeout.String(),
+		})
+	}
+}
+
+// timeoutWriter is an http.ResponseWriter writing to the wrapped one until
+// the request times out, unless the response was started before.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mux      sync.Mutex
+	started  bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	tw.writeHeader(status)
+}
+
+// writeHeader writes the header of the response, if not written before. It
+// must be called holding the lock of the writer.
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.started || tw.timedOut {
+		return
+	}
+	tw.started = true
+	for name, values := range tw.header {
+		tw.w.Header()[name] = values
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.writeHeader(http.StatusOK)
+	return tw.w.Write(b)
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.writeHeader(http.StatusOK)
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeout marks the request as timed out and returns true, unless the
+// response was started before.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mux.Lock()
+	defer tw.mux.Unlock()
+	if tw.started {
+		return false
+	}
+	tw.timedOut = true
+	return true
+}
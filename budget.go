@@ -0,0 +1,155 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ErrBudgetExhausted is returned when running an entry of a team that has
+// consumed its daily runtime budget.
+var ErrBudgetExhausted = errors.New("ErrorBudgetExhausted")
+
+// WithTeamBudgets limits the time the jobs of every team can spend calling
+// vulcan-api per day to the given budget, or to the budget of the team in the
+// given map. The further executions of a team that exhausts its budget are
+// skipped until the next day, in the local time of crontinuous. A zero
+// budget means no limit, but the runtime of the teams is tracked anyway.
+func WithTeamBudgets(daily time.Duration, teams map[string]time.Duration) Option {
+	return func(c *Crontinuous) {
+		c.budgets.daily = daily
+		c.budgets.teams = teams
+	}
+}
+
+// BudgetUsage defines the runtime consumed by the jobs of a team in the
+// current day.
+type BudgetUsage struct {
+	Used Duration `json:"used" yaml:"used"`
+	// Budget is the daily budget of the team, zero if it is not limited.
+	Budget    Duration `json:"budget,omitempty" yaml:"budget,omitempty"`
+	Exhausted bool     `json:"exhausted" yaml:"exhausted"`
+}
+
+// teamBudgets tracks the runtime consumed by the jobs of every team in the
+// current day.
+type teamBudgets struct {
+	daily time.Duration
+	teams map[string]time.Duration
+
+	mux  sync.Mutex
+	day  string
+	used map[string]time.Duration
+}
+
+// limited returns true if the runtime of any team is limited.
+func (b *teamBudgets) limited() bool {
+	return b.daily > 0 || len(b.teams) > 0
+}
+
+// budget returns the daily budget of the given team, zero if it is not
+// limited.
+func (b *teamBudgets) budget(teamID string) time.Duration {
+	if d, ok := b.teams[teamID]; ok {
+		return d
+	}
+	return b.daily
+}
+
+// rotate discards the runtime consumed before the day of the given time. It
+// must be called with the mutex held.
+func (b *teamBudgets) rotate(t time.Time) {
+	day := t.Format("2006-01-02")
+	if b.day != day {
+		b.day = day
+		b.used = map[string]time.Duration{}
+	}
+}
+
+// add counts the given runtime of a job of the given team executed at the
+// given time.
+func (b *teamBudgets) add(teamID string, d time.Duration, t time.Time) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.rotate(t)
+	b.used[teamID] += d
+}
+
+// usage returns the runtime consumed by the given team in the day of the
+// given time.
+func (b *teamBudgets) usage(teamID string, t time.Time) BudgetUsage {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.rotate(t)
+	u := BudgetUsage{Used: Duration(b.used[teamID]), Budget: Duration(b.budget(teamID))}
+	u.Exhausted = u.Budget > 0 && u.Used >= u.Budget
+	return u
+}
+
+// snapshot returns the runtime consumed by every team with executions in the
+// day of the given time.
+func (b *teamBudgets) snapshot(t time.Time) map[string]BudgetUsage {
+	b.mux.Lock()
+	teams := make([]string, 0, len(b.used))
+	b.rotate(t)
+	for team := range b.used {
+		teams = append(teams, team)
+	}
+	b.mux.Unlock()
+
+	usages := make(map[string]BudgetUsage, len(teams))
+	for _, team := range teams {
+		usages[team] = b.usage(team, t)
+	}
+	return usages
+}
+
+// TeamBudget returns the runtime consumed today by the jobs of the given
+// team.
+func (c *Crontinuous) TeamBudget(teamID string) BudgetUsage {
+	return c.budgets.usage(teamID, time.Now())
+}
+
+// meteredJob counts the runtime of the executions of a job in the budget of
+// its team.
+type meteredJob struct {
+	entryJob
+	budgets *teamBudgets
+}
+
+func (j *meteredJob) run(ctx context.Context) error {
+	start := time.Now()
+	err := j.entryJob.run(ctx)
+	j.budgets.add(j.team(), time.Since(start), start)
+	return err
+}
+
+// budgetedJob skips the executions of a job while its team has exhausted its
+// budget.
+type budgetedJob struct {
+	entryJob
+	budgets *teamBudgets
+	log     *logrus.Logger
+}
+
+func (j *budgetedJob) run(ctx context.Context) error {
+	if u := j.budgets.usage(j.team(), time.Now()); u.Exhausted {
+		j.log.WithFields(executionFields(ctx)).WithFields(logrus.Fields{
+			"team":   j.team(),
+			"type":   j.cronType().String(),
+			"used":   time.Duration(u.Used).String(),
+			"budget": time.Duration(u.Budget).String(),
+		}).Info("Skipping job, daily budget of the team exhausted")
+		return skipJob(SkipBudget, "daily budget of the team exhausted")
+	}
+	return j.entryJob.run(ctx)
+}
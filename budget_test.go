@@ -0,0 +1,91 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestTeamBudgets(t *testing.T) {
+	b := &teamBudgets{daily: time.Hour, teams: map[string]time.Duration{"t2": 10 * time.Minute}}
+	monday := time.Date(2020, 6, 1, 10, 0, 0, 0, time.Local)
+
+	b.add("t1", 40*time.Minute, monday)
+	b.add("t2", 40*time.Minute, monday)
+	if u := b.usage("t1", monday); u.Exhausted || u.Used != Duration(40*time.Minute) || u.Budget != Duration(time.Hour) {
+		t.Errorf("want t1 under its budget, got %+v", u)
+	}
+	if u := b.usage("t2", monday); !u.Exhausted || u.Budget != Duration(10*time.Minute) {
+		t.Errorf("want t2 over its own budget, got %+v", u)
+	}
+	b.add("t1", 20*time.Minute, monday.Add(time.Hour))
+	if u := b.usage("t1", monday.Add(time.Hour)); !u.Exhausted {
+		t.Errorf("want t1 over its budget, got %+v", u)
+	}
+	if u := b.usage("t1", monday.Add(24*time.Hour)); u.Exhausted || u.Used != 0 {
+		t.Errorf("want the budget of t1 renewed the next day, got %+v", u)
+	}
+
+	unlimited := &teamBudgets{}
+	unlimited.add("t1", 48*time.Hour, monday)
+	if u := unlimited.usage("t1", monday); u.Exhausted || u.Used != Duration(48*time.Hour) {
+		t.Errorf("want the runtime tracked without limit, got %+v", u)
+	}
+}
+
+func TestCrontinuous_TeamBudgets(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	var created int
+	creator := &mockScanCreator{creator: func(string, string) error {
+		created++
+		time.Sleep(time.Millisecond)
+		return nil
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store,
+		WithTeamBudgets(time.Millisecond, nil))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	job := c.wrapJob(c.newScanJob(store.scanEntries["p1"]))
+	job.Run()
+	job.Run()
+	if created != 1 {
+		t.Errorf("want the executions skipped once the budget is exhausted, got %d scans", created)
+	}
+	skipped, err := c.Executions(ExecutionFilter{SkipReason: SkipBudget})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 {
+		t.Errorf("want one execution skipped by the budget, got %+v", skipped)
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != ErrBudgetExhausted {
+		t.Errorf("want ErrBudgetExhausted, got %v", err)
+	}
+
+	s, err := c.TeamSummary("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Budget.Exhausted || s.Budget.Used < Duration(time.Millisecond) {
+		t.Errorf("want the budget of the team exhausted, got %+v", s.Budget)
+	}
+	if len(s.Scans) != 1 || !s.Scans[0].Paused {
+		t.Errorf("want the scan entry paused, got %+v", s.Scans)
+	}
+	if u := c.TeamBudget("t2"); u.Exhausted || u.Used != 0 {
+		t.Errorf("want the budget of t2 untouched, got %+v", u)
+	}
+}
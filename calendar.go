@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"time"
+)
+
+// MaxCalendarWindow is the maximum time window of a calendar.
+const MaxCalendarWindow = 31 * 24 * time.Hour
+
+// Calendar defines the executions planned in a time window grouped by day
+// and hour, to be rendered as a calendar.
+type Calendar struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Total is the number of executions in the window.
+	Total int `json:"total"`
+	// Days holds, in ascending order, the days of the window with
+	// planned executions.
+	Days []CalendarDay `json:"days"`
+}
+
+// CalendarDay defines the executions planned in a day.
+type CalendarDay struct {
+	// Date is the day in the format 2006-01-02.
+	Date  string `json:"date"`
+	Total int    `json:"total"`
+	// Hours holds, in ascending order, the hours of the
+	// day with planned executions.
+	Hours []CalendarHour `json:"hours"`
+}
+
+// CalendarHour defines the executions planned in an hour of a day.
+type CalendarHour struct {
+	Hour  int `json:"hour"`
+	Total int `json:"total"`
+	// Types holds the number of executions of each type of entry.
+	Types map[CronType]int `json:"types"`
+	// Executions holds the executions sorted by time.
+	Executions []PlannedExecution `json:"executions"`
+}
+
+// Calendar returns the executions that would happen in the interval
+// (from, to], as Simulate does, grouped by the day and the hour they happen
+// in the given location. The window can not be longer than MaxCalendarWindow.
+func (c *Crontinuous) Calendar(from, to time.Time, loc *time.Location) (Calendar, error) {
+	executions, err := c.simulate(from, to, MaxCalendarWindow, c.GetEntries)
+	if err != nil {
+		return Calendar{}, err
+	}
+	return newCalendar(from, to, loc, executions), nil
+}
+
+// newCalendar groups the given executions, sorted by time, by day and hour.
+func newCalendar(from, to time.Time, loc *time.Location, executions []PlannedExecution) Calendar {
+	cal := Calendar{From: from, To: to, Total: len(executions), Days: []CalendarDay{}}
+	for _, e := range executions {
+		t := e.Time.In(loc)
+		date := t.Format("2006-01-02")
+		if n := len(cal.Days); n == 0 || cal.Days[n-1].Date != date {
+			cal.Days = append(cal.Days, CalendarDay{Date: date})
+		}
+		day := &cal.Days[len(cal.Days)-1]
+		day.Total++
+
+		if n := len(day.Hours); n == 0 || day.Hours[n-1].Hour != t.Hour() {
+			day.Hours = append(day.Hours, CalendarHour{Hour: t.Hour(), Types: map[CronType]int{}})
+		}
+		hour := &day.Hours[len(day.Hours)-1]
+		hour.Total++
+		hour.Types[e.Type]++
+		hour.Executions = append(hour.Executions, e)
+	}
+	return cal
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrontinuous_Calendar(t *testing.T) {
+	c := newTestCrontinuous(Config{},
+		nil, map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 22 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "15 22 * * *"},
+		},
+		nil, map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "30 6 * * *"},
+		})
+
+	from := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	got, err := c.Calendar(from, to, madrid)
+	if err != nil {
+		t.Fatalf("Error getting calendar: %v", err)
+	}
+
+	report := PlannedExecution{Type: ReportCronType, EntryID: "t1", TeamID: "t1", Time: from.Add(6*time.Hour + 30*time.Minute)}
+	scan1 := PlannedExecution{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Time: from.Add(22 * time.Hour)}
+	scan2 := PlannedExecution{Type: ScanCronType, EntryID: "p2", TeamID: "t2", Time: from.Add(22*time.Hour + 15*time.Minute)}
+	// The scans at 22:00 UTC happen the next day in Madrid.
+	want := Calendar{
+		From:  from,
+		To:    to,
+		Total: 3,
+		Days: []CalendarDay{
+			{
+				Date:  "2020-06-01",
+				Total: 1,
+				Hours: []CalendarHour{
+					{Hour: 8, Total: 1, Types: map[CronType]int{ReportCronType: 1}, Executions: []PlannedExecution{report}},
+				},
+			},
+			{
+				Date:  "2020-06-02",
+				Total: 2,
+				Hours: []CalendarHour{
+					{Hour: 0, Total: 2, Types: map[CronType]int{ScanCronType: 2}, Executions: []PlannedExecution{scan1, scan2}},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("calendar got!=want, diff %s", diff)
+	}
+
+	if _, err := c.Calendar(from, from.Add(32*24*time.Hour), time.UTC); err != ErrInvalidTimeWindow {
+		t.Errorf("expected ErrInvalidTimeWindow, got %v", err)
+	}
+}
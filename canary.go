@@ -0,0 +1,267 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// maxCanaries is the maximum number of canaries kept. The oldest ones are
+	// discarded first.
+	maxCanaries = 1000
+	// canaryDryRunTimeout is the maximum time the checks of a dry-run canary
+	// can last.
+	canaryDryRunTimeout = time.Minute
+)
+
+var (
+	// ErrCanaryNotFound is returned when getting a canary that was not
+	// started or is not kept anymore.
+	ErrCanaryNotFound = errors.New("ErrorCanaryNotFound")
+
+	// ErrMalformedCanaryMode indicates the given canary mode is invalid.
+	ErrMalformedCanaryMode = errors.New("ErrorMalformedCanaryMode")
+
+	// errCanaryUnverifiable is returned by the dry-run checks that can not
+	// be performed with the components configured.
+	errCanaryUnverifiable = errors.New("the entry can not be verified without listing the programs of the teams")
+)
+
+// CanaryMode defines how the first execution of a new entry is performed
+// right after creating it.
+type CanaryMode string
+
+const (
+	// CanaryDryRun checks the entry could be executed, e.g. its program
+	// belongs to its team, without executing it.
+	CanaryDryRun CanaryMode = "dry-run"
+	// CanaryReal executes the entry as if it was activated by the cron.
+	CanaryReal CanaryMode = "real"
+)
+
+// ParseCanaryMode returns the canary mode with the given name or
+// ErrMalformedCanaryMode.
+func ParseCanaryMode(s string) (CanaryMode, error) {
+	switch m := CanaryMode(s); m {
+	case CanaryDryRun, CanaryReal:
+		return m, nil
+	}
+	return "", ErrMalformedCanaryMode
+}
+
+// CanaryStatus defines the state of a canary.
+type CanaryStatus string
+
+const (
+	CanaryPending   CanaryStatus = "pending"
+	CanarySucceeded CanaryStatus = "succeeded"
+	CanaryFailed    CanaryStatus = "failed"
+	// CanarySkipped indicates the entry was not checked or executed, e.g.
+	// because its team is not whitelisted.
+	CanarySkipped CanaryStatus = "skipped"
+)
+
+// Canary defines the first execution of a new entry, performed right after
+// creating it.
+type Canary struct {
+	// ID identifies the canary and, in real mode, its execution in the logs.
+	ID         string       `json:"id" yaml:"id"`
+	Type       CronType     `json:"type" yaml:"type"`
+	EntryID    string       `json:"entry_id" yaml:"entry_id"`
+	Mode       CanaryMode   `json:"mode" yaml:"mode"`
+	Status     CanaryStatus `json:"status" yaml:"status"`
+	Error      string       `json:"error,omitempty" yaml:"error,omitempty"`
+	StartedAt  time.Time    `json:"started_at" yaml:"started_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+}
+
+// WithCanary makes SaveEntry perform, if the entry is new, its first
+// execution right away in the given mode, without waiting for it to finish.
+// The canary started, if any, is stored in the given one, so its result can
+// be followed with Canary.
+func WithCanary(mode CanaryMode, started *Canary) SaveOption {
+	return func(o *saveOptions) {
+		o.canary = mode
+		o.startedCanary = started
+	}
+}
+
+// canaries holds the last canaries started.
+type canaries struct {
+	mux     sync.Mutex
+	started map[string]*Canary
+	order   []string
+}
+
+func (cs *canaries) add(ca Canary) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	if cs.started == nil {
+		cs.started = map[string]*Canary{}
+	}
+	if len(cs.order) >= maxCanaries {
+		delete(cs.started, cs.order[0])
+		cs.order = cs.order[1:]
+	}
+	cs.started[ca.ID] = &ca
+	cs.order = append(cs.order, ca.ID)
+}
+
+// finish records the result of the canary with the given ID, unless it was
+// discarded meanwhile.
+func (cs *canaries) finish(id string, status CanaryStatus, err error) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	ca, ok := cs.started[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	ca.Status, ca.FinishedAt = status, &now
+	if err != nil {
+		ca.Error = err.Error()
+	}
+}
+
+func (cs *canaries) get(id string) (Canary, bool) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	ca, ok := cs.started[id]
+	if !ok {
+		return Canary{}, false
+	}
+	return *ca, true
+}
+
+// Canary returns the canary with the given ID. Only the last 1000 canaries
+// started since the start are kept.
+func (c *Crontinuous) Canary(id string) (Canary, error) {
+	ca, ok := c.canaries.get(id)
+	if !ok {
+		return Canary{}, ErrCanaryNotFound
+	}
+	return ca, nil
+}
+
+// startCanary performs in background the first execution of the given new
+// entry in the given mode and returns the canary started.
+func (c *Crontinuous) startCanary(typ CronType, entry CronEntry, mode CanaryMode) Canary {
+	ca := Canary{
+		ID:        NewRequestID(),
+		Type:      typ,
+		EntryID:   entry.GetID(),
+		Mode:      mode,
+		Status:    CanaryPending,
+		StartedAt: time.Now(),
+	}
+	c.canaries.add(ca)
+
+	ctx := c.jobsCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	log := c.log.WithFields(logrus.Fields{
+		"canary":   ca.ID,
+		"type":     typ.String(),
+		"entry_id": ca.EntryID,
+		"mode":     string(mode),
+	})
+	go func() {
+		var err error
+		if mode == CanaryReal {
+			err = c.runCanary(ctx, typ, ca)
+		} else {
+			ctx, cancel := context.WithTimeout(ctx, canaryDryRunTimeout)
+			err = c.dryRun(ctx, entry)
+			cancel()
+		}
+		switch {
+		case err == nil:
+			log.Info("Canary succeeded")
+			c.canaries.finish(ca.ID, CanarySucceeded, nil)
+		case errors.Is(err, errJobSkipped), errors.Is(err, errCanaryUnverifiable),
+			errors.Is(err, ErrTeamNotAllowed):
+			log.WithError(err).Info("Canary skipped")
+			c.canaries.finish(ca.ID, CanarySkipped, err)
+		default:
+			log.WithError(err).Warn("Canary failed")
+			c.canaries.finish(ca.ID, CanaryFailed, err)
+		}
+	}()
+	return ca
+}
+
+// runCanary executes the job of the entry of the given canary, with the
+// same checks as the executions activated by the cron, using the ID of the
+// canary as the ID of the execution.
+func (c *Crontinuous) runCanary(ctx context.Context, typ CronType, ca Canary) error {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return err
+	}
+	job, err := set.job(ca.EntryID)
+	if err != nil {
+		return err
+	}
+	if !c.isTeamWhitelisted(typ, job.team()) {
+		return ErrTeamNotAllowed
+	}
+	cj := c.wrapJob(job)
+	return cj.run(context.WithValue(ctx, executionIDKey{}, ca.ID))
+}
+
+// dryRun checks the given entry could be executed: the team of the entry is
+// whitelisted, the program of a scan entry is an active program of its team,
+// the team of a report or team scan entry exists, and the script of a
+// command entry is allowed.
+func (c *Crontinuous) dryRun(ctx context.Context, entry CronEntry) error {
+	typ := entry.GetType()
+	if !c.isTeamWhitelisted(typ, entry.GetTeamID()) {
+		return ErrTeamNotAllowed
+	}
+	if c.flags != nil && !c.flags.Enabled(featureFlag(typ), entry.GetTeamID()) {
+		return skipJob(SkipFeatureFlag, "feature flag "+featureFlag(typ)+" disabled for the team")
+	}
+
+	switch e := entry.(type) {
+	case CommandEntry:
+		_, err := resolveScript(c.scriptsDir, e.Script)
+		return err
+	case ScanEntry, ReportEntry, TeamScanEntry:
+	default:
+		return nil
+	}
+	if c.programLister == nil {
+		return errCanaryUnverifiable
+	}
+	programs, err := listPrograms(ctx, c.programLister, entry.GetTeamID())
+	if err != nil {
+		return fmt.Errorf("listing the programs of team %s: %w", entry.GetTeamID(), err)
+	}
+	switch e := entry.(type) {
+	case ScanEntry:
+		for _, p := range programs {
+			if p == e.ProgramID {
+				return nil
+			}
+		}
+		return fmt.Errorf("program %s is not an active program of team %s", e.ProgramID, e.TeamID)
+	case TeamScanEntry:
+		if len(programs) == 0 {
+			return fmt.Errorf("team %s has no active programs", e.TeamID)
+		}
+	}
+	return nil
+}
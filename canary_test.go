@@ -0,0 +1,203 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type mockTeamScanCronStore struct {
+	entries map[string]TeamScanEntry
+}
+
+func (s *mockTeamScanCronStore) GetTeamScanEntries() (map[string]TeamScanEntry, error) {
+	return s.entries, nil
+}
+
+func (s *mockTeamScanCronStore) SaveTeamScanEntries(entries map[string]TeamScanEntry) error {
+	s.entries = entries
+	return nil
+}
+
+type programListerFunc func(teamID string) ([]string, error)
+
+func (f programListerFunc) ListPrograms(teamID string) ([]string, error) {
+	return f(teamID)
+}
+
+// executionsScanCreator records the executions the scans are created in.
+type executionsScanCreator struct {
+	mux        sync.Mutex
+	executions []Execution
+}
+
+func (s *executionsScanCreator) CreateScan(programID, teamID string) error {
+	return s.CreateScanContext(context.Background(), programID, teamID)
+}
+
+func (s *executionsScanCreator) CreateScanContext(ctx context.Context, programID, teamID string) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	e, _ := ExecutionFromContext(ctx)
+	s.executions = append(s.executions, e)
+	return nil
+}
+
+// waitCanary waits until the canary with the given ID finishes and returns it.
+func waitCanary(t *testing.T, c *Crontinuous, id string) Canary {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ca, err := c.Canary(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ca.Status != CanaryPending {
+			return ca
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("canary %s not finished", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCrontinuous_Canary(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	teamScans := &mockTeamScanCronStore{entries: map[string]TeamScanEntry{}}
+	lister := programListerFunc(func(teamID string) ([]string, error) {
+		switch teamID {
+		case "t1":
+			return []string{"p1"}, nil
+		case "t2":
+			return nil, nil
+		}
+		return nil, errors.New("team not found")
+	})
+	creator := &executionsScanCreator{}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1", "t2", "t3"}}
+	c := NewCrontinuous(cfg, logrus.New(), creator, store, nil, store, WithTeamScans(lister, teamScans))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	tests := []struct {
+		name  string
+		typ   CronType
+		entry CronEntry
+		mode  CanaryMode
+		want  CanaryStatus
+	}{
+		{"ActiveProgram", ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1"}, CanaryDryRun, CanarySucceeded},
+		{"ProgramOfOtherTeam", ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1"}, CanaryDryRun, CanaryFailed},
+		{"UnknownTeam", ScanCronType, ScanEntry{ProgramID: "p3", TeamID: "t3"}, CanaryDryRun, CanaryFailed},
+		{"TeamNotWhitelisted", ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t4"}, CanaryDryRun, CanarySkipped},
+		{"TeamWithoutPrograms", TeamScanCronType, TeamScanEntry{TeamID: "t2"}, CanaryDryRun, CanaryFailed},
+		{"TeamWithPrograms", TeamScanCronType, TeamScanEntry{TeamID: "t1"}, CanaryDryRun, CanarySucceeded},
+		{"Real", ScanCronType, ScanEntry{ProgramID: "p5", TeamID: "t1"}, CanaryReal, CanarySucceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			switch e := entry.(type) {
+			case ScanEntry:
+				e.CronSpec = "0 0 1 1 *"
+				entry = e
+			case TeamScanEntry:
+				e.CronSpec = "0 0 1 1 *"
+				entry = e
+			}
+			var started Canary
+			if err := c.SaveEntry(tt.typ, entry, WithCanary(tt.mode, &started)); err != nil {
+				t.Fatal(err)
+			}
+			if started.ID == "" || started.Status != CanaryPending || started.EntryID != entry.GetID() {
+				t.Fatalf("want a pending canary of the entry, got %+v", started)
+			}
+			got := waitCanary(t, c, started.ID)
+			if got.Status != tt.want || got.FinishedAt == nil {
+				t.Errorf("want canary %s, got %+v", tt.want, got)
+			}
+			if tt.want != CanarySucceeded && got.Error == "" {
+				t.Errorf("want the reason of the canary %s, got %+v", got.Status, got)
+			}
+		})
+	}
+
+	creator.mux.Lock()
+	executions := creator.executions
+	creator.mux.Unlock()
+	if len(executions) != 1 || executions[0].EntryID != "p5" {
+		t.Fatalf("want only the scan of the real canary created, got %+v", executions)
+	}
+
+	// The canary of the real execution is identified as its execution.
+	var canaryID string
+	c.canaries.mux.Lock()
+	for _, ca := range c.canaries.started {
+		if ca.Mode == CanaryReal {
+			canaryID = ca.ID
+		}
+	}
+	c.canaries.mux.Unlock()
+	if executions[0].ID != canaryID {
+		t.Errorf("want the execution identified as the canary %s, got %s", canaryID, executions[0].ID)
+	}
+
+	// Updates do not start canaries.
+	var started Canary
+	err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"}, WithCanary(CanaryReal, &started))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.ID != "" {
+		t.Errorf("want no canary for an update, got %+v", started)
+	}
+	if _, err := c.Canary("unknown"); err != ErrCanaryNotFound {
+		t.Errorf("want ErrCanaryNotFound, got %v", err)
+	}
+}
+
+func TestCrontinuous_CanaryWithoutProgramLister(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	var started Canary
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	if err := c.SaveEntry(ScanCronType, entry, WithCanary(CanaryDryRun, &started)); err != nil {
+		t.Fatal(err)
+	}
+	if got := waitCanary(t, c, started.ID); got.Status != CanarySkipped {
+		t.Errorf("want the canary skipped, got %+v", got)
+	}
+}
+
+func TestCanaries_Limit(t *testing.T) {
+	var cs canaries
+	for i := 0; i < maxCanaries+1; i++ {
+		cs.add(Canary{ID: NewRequestID()})
+	}
+	if len(cs.started) != maxCanaries || len(cs.order) != maxCanaries {
+		t.Errorf("want %d canaries kept, got %d", maxCanaries, len(cs.started))
+	}
+}
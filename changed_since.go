@@ -0,0 +1,61 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"reflect"
+	"time"
+)
+
+// GetEntriesChangedSince returns the entries of the given type changed after
+// the given time, sorted by ID. The entries not changed since the updated_at
+// metadata was introduced, which do not have it, are not returned, nor are
+// the removed entries.
+func (c *Crontinuous) GetEntriesChangedSince(typ CronType, t time.Time) ([]CronEntry, error) {
+	all, err := c.GetEntries(typ)
+	if err != nil {
+		return nil, err
+	}
+	entries := []CronEntry{}
+	for _, e := range all {
+		if u := entryUpdatedAt(e); u != nil && u.After(t) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// entryUpdatedAt returns the time the given entry was last changed, nil if
+// it is unknown.
+func entryUpdatedAt(e CronEntry) *time.Time {
+	return entrySettings(e).UpdatedAt
+}
+
+// withUpdatedAt returns the given entry with the given time of its last
+// change.
+func withUpdatedAt(e CronEntry, t *time.Time) CronEntry {
+	s := entrySettings(e)
+	s.UpdatedAt = t
+	return withEntrySettings(e, s)
+}
+
+// sameEntry returns true if the given entries are equal regardless of the
+// time of their last change.
+func sameEntry(a, b CronEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return reflect.DeepEqual(withUpdatedAt(a, nil), withUpdatedAt(b, nil))
+}
+
+// stampEntry returns the given entry with the time of its last change set to
+// the given time, or to the one of its current version in the given entries
+// if it does not change.
+func stampEntry[T CronEntry](current map[string]T, entry T, now time.Time) T {
+	if previous, ok := current[entry.GetID()]; ok && sameEntry(previous, entry) {
+		return withUpdatedAt(entry, entryUpdatedAt(previous)).(T)
+	}
+	return withUpdatedAt(entry, &now).(T)
+}
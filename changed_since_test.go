@@ -0,0 +1,50 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCrontinuous_GetEntriesChangedSince(t *testing.T) {
+	store := &mockCronStore{}
+	c := newTestCrontinuous(Config{}, store, map[string]ScanEntry{
+		"p0": {ProgramID: "p0", TeamID: "t1", CronSpec: "0 1 * * *"},
+	}, store, nil)
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	p1, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := *p1.(ScanEntry).UpdatedAt
+	time.Sleep(10 * time.Millisecond)
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	// Saving an entry without changes must keep the time of its last change.
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.GetEntriesChangedSince(ScanCronType, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].GetID() != "p2" {
+		t.Errorf("want only p2 changed, got %+v", got)
+	}
+
+	got, err = c.GetEntriesChangedSince(ScanCronType, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("want p1 and p2 changed, entries without updated_at excluded, got %+v", got)
+	}
+}
@@ -0,0 +1,596 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+// Package client provides a Go client for the crontinuous HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+const (
+	scanEntriesPath   = "/entries"
+	scanSettingsPath  = "/settings"
+	reportEntriesPath = "/report/entries"
+	reportSettingPath = "/report/settings"
+	templatesPath     = "/templates"
+	executionsPath    = "/executions"
+	teamsPath         = "/teams"
+	snapshotPath      = "/snapshot"
+	pauseWindowsPath  = "/pause-windows"
+	operationsPath    = "/operations"
+)
+
+// crontinuousErrors are the errors returned by crontinuous whose
+// message is sent as the body of the response.
+var crontinuousErrors = []error{
+	crontinuous.ErrScheduleNotFound,
+	crontinuous.ErrMalformedSchedule,
+	crontinuous.ErrMalformedEntry,
+	crontinuous.ErrScheduleConflict,
+	crontinuous.ErrInvalidCronType,
+	crontinuous.ErrTeamNotAllowed,
+	crontinuous.ErrBudgetExhausted,
+	crontinuous.ErrTemplatesDisabled,
+	crontinuous.ErrTemplateNotFound,
+	crontinuous.ErrMalformedTemplate,
+	crontinuous.ErrTemplateInUse,
+	crontinuous.ErrDeadLettersDisabled,
+	crontinuous.ErrExecutionNotFound,
+	crontinuous.ErrExecutionReplayed,
+	crontinuous.ErrMalformedDeadLetter,
+	crontinuous.ErrCanaryNotFound,
+	crontinuous.ErrMalformedCanaryMode,
+	crontinuous.ErrTeamNotFound,
+	crontinuous.ErrProgramNotFound,
+	crontinuous.ErrMalformedReportDelay,
+	crontinuous.ErrScanCapacityExceeded,
+	crontinuous.ErrSpecRuleViolation,
+	crontinuous.ErrMetadataSchemaViolation,
+	crontinuous.ErrOutOfWindows,
+	crontinuous.ErrReadOnlyReplica,
+	crontinuous.ErrDuplicatedEntry,
+	crontinuous.ErrPauseWindowsDisabled,
+	crontinuous.ErrPauseWindowNotFound,
+	crontinuous.ErrMalformedPauseWindow,
+	crontinuous.ErrSuppressionsDisabled,
+	crontinuous.ErrSuppressionNotFound,
+	crontinuous.ErrMalformedSuppression,
+	crontinuous.ErrMalformedExecutionFilter,
+	crontinuous.ErrIdempotencyKeyInUse,
+	crontinuous.ErrIdempotencyKeyReused,
+	crontinuous.ErrMalformedIdempotencyKey,
+	crontinuous.ErrRequestTimeout,
+	crontinuous.ErrInvalidOverwrite,
+	crontinuous.ErrEntryExists,
+	crontinuous.ErrOperationNotFound,
+	crontinuous.ErrTeamDefaultsDisabled,
+	crontinuous.ErrTeamDefaultsNotFound,
+	crontinuous.ErrMalformedTeamDefaults,
+}
+
+// Client provides functionality for interacting with the crontinuous API.
+// Requests failing because of network errors or server errors are retried
+// with an exponential backoff until the given context is done. Errors
+// reported by crontinuous are returned as the corresponding crontinuous
+// error, e.g.: crontinuous.ErrScheduleNotFound.
+type Client struct {
+	// URL is the base URL of the crontinuous API.
+	URL string
+	// HTTPClient is the client used to perform the requests,
+	// http.DefaultClient is used if nil.
+	HTTPClient *http.Client
+}
+
+// NewClient creates a client for the crontinuous API at the given URL.
+func NewClient(URL string) *Client {
+	return &Client{URL: strings.TrimSuffix(URL, "/")}
+}
+
+// BulkSetting defines an entry to create using the bulk endpoints.
+type BulkSetting struct {
+	// ID identifies the scan or report entry to create or update when
+	// there can be many entries per program or team.
+	ID        string `json:"id,omitempty"`
+	Str       string `json:"str"`
+	TeamID    string `json:"team_id"`
+	ProgramID string `json:"program_id,omitempty"`
+	// Overwrite indicates the entry must replace the existing
+	// entry with the same ID, if any.
+	Overwrite bool `json:"overwrite"`
+	// ExecutionTimeout overrides the global execution timeout
+	// of the jobs of the entry.
+	ExecutionTimeout crontinuous.Duration `json:"execution_timeout,omitempty"`
+	// PingURL is the URL of a dead-man's switch requested
+	// after every successful execution of the entry.
+	PingURL string `json:"ping_url,omitempty"`
+	// Alerting overrides the default alerting
+	// preferences of the entry.
+	Alerting *crontinuous.AlertSettings `json:"alerting,omitempty"`
+	// Jitter delays the executions of the entry
+	// by a duration lower than it.
+	Jitter crontinuous.Duration `json:"jitter,omitempty"`
+	// Windows restricts the executions of the entry to the
+	// given time windows, e.g. 22:00-06:00, and WindowPolicy
+	// defines what happens to the activations out of them.
+	Windows      []string                 `json:"windows,omitempty"`
+	WindowPolicy crontinuous.WindowPolicy `json:"window_policy,omitempty"`
+	// Template is the name of the template the entry takes
+	// its spec and presets from, if any.
+	Template string `json:"template,omitempty"`
+	// Name and Description identify the entry for humans,
+	// e.g. "Weekly PCI scan".
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Metadata holds free-form references of the entry,
+	// like ticket IDs or cost centers.
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type cronString struct {
+	ID               string                     `json:"id,omitempty"`
+	Str              string                     `json:"str"`
+	ExecutionTimeout crontinuous.Duration       `json:"execution_timeout,omitempty"`
+	PingURL          string                     `json:"ping_url,omitempty"`
+	Alerting         *crontinuous.AlertSettings `json:"alerting,omitempty"`
+	Jitter           crontinuous.Duration       `json:"jitter,omitempty"`
+	Windows          []string                   `json:"windows,omitempty"`
+	WindowPolicy     crontinuous.WindowPolicy   `json:"window_policy,omitempty"`
+	Template         string                     `json:"template,omitempty"`
+	Name             string                     `json:"name,omitempty"`
+	Description      string                     `json:"description,omitempty"`
+	Metadata         map[string]string          `json:"metadata,omitempty"`
+}
+
+type lookupRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type transferRequest struct {
+	TeamID string `json:"team_id"`
+}
+
+// ListScanEntries returns all the scan entries.
+func (c *Client) ListScanEntries(ctx context.Context) ([]crontinuous.ScanEntry, error) {
+	var entries []crontinuous.ScanEntry
+	err := c.do(ctx, http.MethodGet, scanEntriesPath, nil, &entries)
+	return entries, err
+}
+
+// SearchScanEntries returns the scan entries whose ID, name or description
+// contain every word of the given query, ignoring the case.
+func (c *Client) SearchScanEntries(ctx context.Context, q string) ([]crontinuous.ScanEntry, error) {
+	var entries []crontinuous.ScanEntry
+	err := c.do(ctx, http.MethodGet, scanEntriesPath+"?"+url.Values{"q": {q}}.Encode(), nil, &entries)
+	return entries, err
+}
+
+// GetScanEntry returns the scan entry of the given program.
+func (c *Client) GetScanEntry(ctx context.Context, programID string) (crontinuous.ScanEntry, error) {
+	var entry crontinuous.ScanEntry
+	err := c.do(ctx, http.MethodGet, path(scanEntriesPath, programID), nil, &entry)
+	return entry, err
+}
+
+// LookupScanEntries returns the scan entries of the given programs that
+// exist and the IDs of the programs without entry, in a single request.
+func (c *Client) LookupScanEntries(ctx context.Context, programIDs []string) ([]crontinuous.ScanEntry, []string, error) {
+	var resp struct {
+		Found   []crontinuous.ScanEntry `json:"found"`
+		Missing []string                `json:"missing"`
+	}
+	err := c.do(ctx, http.MethodPost, path(scanEntriesPath, "lookup"), lookupRequest{IDs: programIDs}, &resp)
+	return resp.Found, resp.Missing, err
+}
+
+// SaveScanEntry creates or updates the given scan entry.
+func (c *Client) SaveScanEntry(ctx context.Context, entry crontinuous.ScanEntry) error {
+	p := path(scanSettingsPath, entry.ProgramID, entry.TeamID)
+	return c.do(ctx, http.MethodPost, p, cronString{
+		ID:               entry.ID,
+		Str:              entry.CronSpec,
+		ExecutionTimeout: entry.ExecutionTimeout,
+		PingURL:          entry.PingURL,
+		Alerting:         entry.Alerting,
+		Jitter:           entry.Jitter,
+		Windows:          entry.Windows,
+		WindowPolicy:     entry.WindowPolicy,
+		Template:         entry.Template,
+		Name:             entry.Name,
+		Description:      entry.Description,
+		Metadata:         entry.Metadata,
+	}, nil)
+}
+
+// BulkCreateScanEntries creates the given scan entries in a single operation.
+func (c *Client) BulkCreateScanEntries(ctx context.Context, settings []BulkSetting) error {
+	return c.do(ctx, http.MethodPost, scanEntriesPath, settings, nil)
+}
+
+// RemoveScanEntry removes the scan entry of the given program.
+func (c *Client) RemoveScanEntry(ctx context.Context, programID string) error {
+	return c.do(ctx, http.MethodDelete, path(scanEntriesPath, programID), nil, nil)
+}
+
+// RemoveScanEntries removes the scan entries with the given IDs in a single
+// request, and returns the IDs not found.
+func (c *Client) RemoveScanEntries(ctx context.Context, ids []string) ([]string, error) {
+	return c.removeEntries(ctx, scanEntriesPath, ids)
+}
+
+// RunScanEntry makes crontinuous create the scan of the given
+// program now, without waiting for its next execution.
+func (c *Client) RunScanEntry(ctx context.Context, programID string) error {
+	return c.do(ctx, http.MethodPost, path(scanEntriesPath, programID, "run"), nil, nil)
+}
+
+// TransferScanEntry reassigns the scan entry of the given program to the
+// given team and returns the transferred entry.
+func (c *Client) TransferScanEntry(ctx context.Context, programID, teamID string) (crontinuous.ScanEntry, error) {
+	var entry crontinuous.ScanEntry
+	err := c.do(ctx, http.MethodPost, path(scanEntriesPath, programID, "transfer"), transferRequest{TeamID: teamID}, &entry)
+	return entry, err
+}
+
+// ListReportEntries returns all the report entries.
+func (c *Client) ListReportEntries(ctx context.Context) ([]crontinuous.ReportEntry, error) {
+	var entries []crontinuous.ReportEntry
+	err := c.do(ctx, http.MethodGet, reportEntriesPath, nil, &entries)
+	return entries, err
+}
+
+// SearchReportEntries returns the report entries whose ID, name or
+// description contain every word of the given query, ignoring the case.
+func (c *Client) SearchReportEntries(ctx context.Context, q string) ([]crontinuous.ReportEntry, error) {
+	var entries []crontinuous.ReportEntry
+	err := c.do(ctx, http.MethodGet, reportEntriesPath+"?"+url.Values{"q": {q}}.Encode(), nil, &entries)
+	return entries, err
+}
+
+// GetReportEntry returns the report entry with the given ID. The ID of a
+// team with a single report entry identifies it.
+func (c *Client) GetReportEntry(ctx context.Context, id string) (crontinuous.ReportEntry, error) {
+	var entry crontinuous.ReportEntry
+	err := c.do(ctx, http.MethodGet, path(reportEntriesPath, id), nil, &entry)
+	return entry, err
+}
+
+// ListTeamReportEntries returns the report entries of the given team.
+func (c *Client) ListTeamReportEntries(ctx context.Context, teamID string) ([]crontinuous.ReportEntry, error) {
+	var entries []crontinuous.ReportEntry
+	err := c.do(ctx, http.MethodGet, path(teamsPath, teamID, "reports"), nil, &entries)
+	return entries, err
+}
+
+// GetTeamsEntries returns the scan and report entries of the given teams,
+// grouped by team in the order of the teams, in a single request.
+func (c *Client) GetTeamsEntries(ctx context.Context, teamIDs []string) ([]crontinuous.TeamEntries, error) {
+	var teams []crontinuous.TeamEntries
+	err := c.do(ctx, http.MethodPost, path(teamsPath, "entries"), lookupRequest{IDs: teamIDs}, &teams)
+	return teams, err
+}
+
+// SaveReportEntry creates or updates the given report entry.
+func (c *Client) SaveReportEntry(ctx context.Context, entry crontinuous.ReportEntry) error {
+	p := path(reportSettingPath, entry.TeamID)
+	return c.do(ctx, http.MethodPost, p, cronString{
+		ID:               entry.ID,
+		Str:              entry.CronSpec,
+		ExecutionTimeout: entry.ExecutionTimeout,
+		PingURL:          entry.PingURL,
+		Alerting:         entry.Alerting,
+		Jitter:           entry.Jitter,
+		Windows:          entry.Windows,
+		WindowPolicy:     entry.WindowPolicy,
+		Template:         entry.Template,
+		Name:             entry.Name,
+		Description:      entry.Description,
+		Metadata:         entry.Metadata,
+	}, nil)
+}
+
+// BulkCreateReportEntries creates the given report entries in a single operation.
+func (c *Client) BulkCreateReportEntries(ctx context.Context, settings []BulkSetting) error {
+	return c.do(ctx, http.MethodPost, reportEntriesPath, settings, nil)
+}
+
+// StartBulkCreateScanEntries starts creating the given scan entries in
+// background and returns the operation started, which can be followed with
+// GetOperation.
+func (c *Client) StartBulkCreateScanEntries(ctx context.Context, settings []BulkSetting) (crontinuous.Operation, error) {
+	var op crontinuous.Operation
+	err := c.do(ctx, http.MethodPost, scanEntriesPath+"?async=true", settings, &op)
+	return op, err
+}
+
+// StartBulkCreateReportEntries starts creating the given report entries in
+// background and returns the operation started, which can be followed with
+// GetOperation.
+func (c *Client) StartBulkCreateReportEntries(ctx context.Context, settings []BulkSetting) (crontinuous.Operation, error) {
+	var op crontinuous.Operation
+	err := c.do(ctx, http.MethodPost, reportEntriesPath+"?async=true", settings, &op)
+	return op, err
+}
+
+// GetOperation returns the operation with the given ID, with its progress.
+func (c *Client) GetOperation(ctx context.Context, id string) (crontinuous.Operation, error) {
+	var op crontinuous.Operation
+	err := c.do(ctx, http.MethodGet, path(operationsPath, id), nil, &op)
+	return op, err
+}
+
+// CancelOperation cancels the operation with the given ID and returns it.
+func (c *Client) CancelOperation(ctx context.Context, id string) (crontinuous.Operation, error) {
+	var op crontinuous.Operation
+	err := c.do(ctx, http.MethodDelete, path(operationsPath, id), nil, &op)
+	return op, err
+}
+
+// RemoveReportEntry removes the report entry with the given ID. The ID of a
+// team with a single report entry identifies it.
+func (c *Client) RemoveReportEntry(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, path(reportEntriesPath, id), nil, nil)
+}
+
+// RemoveReportEntries removes the report entries with the given IDs in a
+// single request, and returns the IDs not found.
+func (c *Client) RemoveReportEntries(ctx context.Context, ids []string) ([]string, error) {
+	return c.removeEntries(ctx, reportEntriesPath, ids)
+}
+
+func (c *Client) removeEntries(ctx context.Context, p string, ids []string) ([]string, error) {
+	var resp struct {
+		Missing []string `json:"missing"`
+	}
+	err := c.do(ctx, http.MethodDelete, p, lookupRequest{IDs: ids}, &resp)
+	return resp.Missing, err
+}
+
+// RunReportEntry makes crontinuous send the report of the entry with the
+// given ID now, without waiting for its next execution. The ID of a team
+// with a single report entry identifies it.
+func (c *Client) RunReportEntry(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, path(reportEntriesPath, id, "run"), nil, nil)
+}
+
+// GetSnapshot returns the scan and report entries, the whitelists and the
+// paused state of crontinuous captured at one instant.
+func (c *Client) GetSnapshot(ctx context.Context) (crontinuous.Snapshot, error) {
+	var snapshot crontinuous.Snapshot
+	err := c.do(ctx, http.MethodGet, snapshotPath, nil, &snapshot)
+	return snapshot, err
+}
+
+// ListTemplates returns all the templates.
+func (c *Client) ListTemplates(ctx context.Context) ([]crontinuous.Template, error) {
+	var templates []crontinuous.Template
+	err := c.do(ctx, http.MethodGet, templatesPath, nil, &templates)
+	return templates, err
+}
+
+// GetTemplate returns the template with the given name.
+func (c *Client) GetTemplate(ctx context.Context, name string) (crontinuous.Template, error) {
+	var t crontinuous.Template
+	err := c.do(ctx, http.MethodGet, path(templatesPath, name), nil, &t)
+	return t, err
+}
+
+// SaveTemplate creates or updates the given template. The entries
+// referencing it are updated and rescheduled by crontinuous.
+func (c *Client) SaveTemplate(ctx context.Context, t crontinuous.Template) error {
+	return c.do(ctx, http.MethodPut, path(templatesPath, t.Name), t, nil)
+}
+
+// RemoveTemplate removes the template with the given name.
+func (c *Client) RemoveTemplate(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, path(templatesPath, name), nil, nil)
+}
+
+// ListPauseWindows returns all the pause windows.
+func (c *Client) ListPauseWindows(ctx context.Context) ([]crontinuous.PauseWindow, error) {
+	var windows []crontinuous.PauseWindow
+	err := c.do(ctx, http.MethodGet, pauseWindowsPath, nil, &windows)
+	return windows, err
+}
+
+// SavePauseWindow creates or updates the given pause window.
+func (c *Client) SavePauseWindow(ctx context.Context, w crontinuous.PauseWindow) error {
+	return c.do(ctx, http.MethodPut, path(pauseWindowsPath, w.Name), w, nil)
+}
+
+// RemovePauseWindow removes the pause window with the given name.
+func (c *Client) RemovePauseWindow(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, path(pauseWindowsPath, name), nil, nil)
+}
+
+// GetTeamDefaults returns the default settings of the given team.
+func (c *Client) GetTeamDefaults(ctx context.Context, teamID string) (crontinuous.TeamDefaults, error) {
+	var d crontinuous.TeamDefaults
+	err := c.do(ctx, http.MethodGet, path(teamsPath, teamID, "defaults"), nil, &d)
+	return d, err
+}
+
+// SaveTeamDefaults creates or updates the given default settings of a team.
+// The entries of the team are rescheduled by crontinuous.
+func (c *Client) SaveTeamDefaults(ctx context.Context, d crontinuous.TeamDefaults) error {
+	return c.do(ctx, http.MethodPut, path(teamsPath, d.TeamID, "defaults"), d, nil)
+}
+
+// RemoveTeamDefaults removes the default settings of the given team.
+func (c *Client) RemoveTeamDefaults(ctx context.Context, teamID string) error {
+	return c.do(ctx, http.MethodDelete, path(teamsPath, teamID, "defaults"), nil, nil)
+}
+
+// ReplayExecution makes crontinuous create again the scan of the failed
+// execution with the given ID and returns its dead letter marked as replayed.
+func (c *Client) ReplayExecution(ctx context.Context, id string) (crontinuous.DeadLetter, error) {
+	var l crontinuous.DeadLetter
+	err := c.do(ctx, http.MethodPost, path(executionsPath, id, "replay"), nil, &l)
+	return l, err
+}
+
+// ListExecutions returns, from the oldest to the newest, the executions of
+// the entries matching the given filter, including the skipped ones.
+func (c *Client) ListExecutions(ctx context.Context, f crontinuous.ExecutionFilter) ([]crontinuous.ExecutionRecord, error) {
+	q := url.Values{}
+	for _, typ := range f.Types {
+		q.Add("type", typ.String())
+	}
+	if f.EntryID != "" {
+		q.Set("entry", f.EntryID)
+	}
+	if f.TeamID != "" {
+		q.Set("team", f.TeamID)
+	}
+	if f.Status != "" {
+		q.Set("status", string(f.Status))
+	}
+	if f.SkipReason != "" {
+		q.Set("skip_reason", string(f.SkipReason))
+	}
+	if !f.Since.IsZero() {
+		q.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if f.ScanID != "" {
+		q.Set("scan_id", f.ScanID)
+	}
+	if f.ReportID != "" {
+		q.Set("report_id", f.ReportID)
+	}
+	p := executionsPath
+	if len(q) > 0 {
+		p += "?" + q.Encode()
+	}
+	var executions []crontinuous.ExecutionRecord
+	err := c.do(ctx, http.MethodGet, p, nil, &executions)
+	return executions, err
+}
+
+// ReplayDeadLetter is like ReplayExecution but it sends the given dead
+// letter, so crontinuous can replay executions it did not record, e.g. failed
+// before its last start.
+func (c *Client) ReplayDeadLetter(ctx context.Context, l crontinuous.DeadLetter) (crontinuous.DeadLetter, error) {
+	id := l.Execution
+	if id == "" {
+		id = l.ID
+	}
+	var replayed crontinuous.DeadLetter
+	err := c.do(ctx, http.MethodPost, path(executionsPath, id, "replay"), l, &replayed)
+	return replayed, err
+}
+
+// path joins the given base path with the escaped params.
+func path(base string, params ...string) string {
+	for _, p := range params {
+		base += "/" + url.PathEscape(p)
+	}
+	return base
+}
+
+// do performs a request against the crontinuous API retrying it when it
+// fails because of network or server errors. If out is not nil the
+// response body is decoded into it. The POST requests carry an idempotency
+// key, the same for all the retries, so a retry of a request that was
+// applied does not apply it again.
+func (c *Client) do(ctx context.Context, method, path string, payload interface{}, out interface{}) error {
+	var key string
+	if method == http.MethodPost {
+		key = crontinuous.NewRequestID()
+	}
+	operation := func() error {
+		return c.doReq(ctx, method, path, key, payload, out)
+	}
+	return backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+}
+
+func (c *Client) doReq(ctx context.Context, method, path, key string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		content, err := json.Marshal(payload)
+		if err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		body = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return &backoff.PermanentError{Err: err}
+	}
+	if payload != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	if key != "" {
+		req.Header.Set(crontinuous.IdempotencyKeyHeader, key)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// Network errors are retried.
+		return err
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode >= 300 {
+		err := responseError(resp)
+		// The retries of a request still being served are retried.
+		if resp.StatusCode >= 500 || err == crontinuous.ErrIdempotencyKeyInUse {
+			return err
+		}
+		return &backoff.PermanentError{Err: err}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+	}
+	return nil
+}
+
+// responseError returns the crontinuous error contained in the body of the
+// response, as is or in the error field of a JSON object, or a generic error
+// if none. The errors wrapped with context are returned wrapping the
+// corresponding crontinuous error, so they can be checked with errors.Is.
+func responseError(resp *http.Response) error {
+	var content string
+	b, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		content = strings.TrimSpace(string(b))
+	}
+	var structured struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(b, &structured) == nil && structured.Error != "" {
+		content = structured.Error
+	}
+	for _, e := range crontinuousErrors {
+		if content == e.Error() {
+			return e
+		}
+		// The errors of the entries are wrapped with the entry causing them.
+		if strings.HasPrefix(content, e.Error()+": ") {
+			return fmt.Errorf("%w: %s", e, strings.TrimPrefix(content, e.Error()+": "))
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return crontinuous.ErrScheduleNotFound
+	}
+	return fmt.Errorf("Error. Response status %s. Content: %s", resp.Status, content)
+}
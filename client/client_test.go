@@ -0,0 +1,183 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+func TestClient_ListScanEntries(t *testing.T) {
+	want := []crontinuous.ScanEntry{
+		{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+	}
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			// Server errors must be retried.
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/entries" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(want) // nolint
+	}))
+	defer srv.Close()
+
+	got, err := NewClient(srv.URL).ListScanEntries(context.Background())
+	if err != nil {
+		t.Fatalf("Error listing entries: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries got!=want, diff %s", diff)
+	}
+	if calls != 2 {
+		t.Errorf("calls got %d, want 2", calls)
+	}
+}
+
+func TestClient_SearchScanEntries(t *testing.T) {
+	want := []crontinuous.ScanEntry{
+		{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *", EntrySettings: crontinuous.EntrySettings{Name: "Weekly PCI scan"}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/entries" || r.URL.Query().Get("q") != "weekly pci" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		json.NewEncoder(w).Encode(want) // nolint
+	}))
+	defer srv.Close()
+
+	got, err := NewClient(srv.URL).SearchScanEntries(context.Background(), "weekly pci")
+	if err != nil {
+		t.Fatalf("Error searching entries: %v", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries got!=want, diff %s", diff)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		call    func(c *Client) error
+		wantErr error
+	}{
+		{
+			name:   "ScheduleConflict",
+			status: http.StatusConflict,
+			body:   crontinuous.ErrScheduleConflict.Error(),
+			call: func(c *Client) error {
+				return c.SaveReportEntry(context.Background(), crontinuous.ReportEntry{TeamID: "t1", CronSpec: "* * * * *"})
+			},
+			wantErr: crontinuous.ErrScheduleConflict,
+		},
+		{
+			name:   "EntryError",
+			status: http.StatusUnprocessableEntity,
+			body:   `ErrorTeamNotFound: report entry "t1", field team_id`,
+			call: func(c *Client) error {
+				return c.SaveReportEntry(context.Background(), crontinuous.ReportEntry{TeamID: "t1", CronSpec: "* * * * *"})
+			},
+			wantErr: crontinuous.ErrTeamNotFound,
+		},
+		{
+			name:   "NotFound",
+			status: http.StatusNotFound,
+			body:   "404 page not found",
+			call: func(c *Client) error {
+				return c.RunScanEntry(context.Background(), "p1")
+			},
+			wantErr: crontinuous.ErrScheduleNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				http.Error(w, tt.body, tt.status)
+			}))
+			defer srv.Close()
+
+			if err := tt.call(NewClient(srv.URL)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error got %v, want %v", err, tt.wantErr)
+			}
+			if calls != 1 {
+				t.Errorf("client errors must not be retried, got %d calls", calls)
+			}
+		})
+	}
+}
+
+func TestClient_IdempotencyKey(t *testing.T) {
+	keys := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(crontinuous.IdempotencyKeyHeader))
+		switch len(keys) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			// The retries of a request still being served must be retried.
+			http.Error(w, crontinuous.ErrIdempotencyKeyInUse.Error(), http.StatusConflict)
+		}
+	}))
+	defer srv.Close()
+
+	if err := NewClient(srv.URL).RunScanEntry(context.Background(), "p1"); err != nil {
+		t.Fatalf("Error running entry: %v", err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("calls got %d, want 3", len(keys))
+	}
+	if keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("want the same idempotency key in all the retries, got %q", keys)
+	}
+}
+
+func TestClient_Operations(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		status := crontinuous.OperationRunning
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		if r.Method == http.MethodDelete {
+			status = crontinuous.OperationCancelled
+		}
+		json.NewEncoder(w).Encode(crontinuous.Operation{ID: "op1", Status: status, Total: 1}) // nolint
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	ctx := context.Background()
+	op, err := c.StartBulkCreateScanEntries(ctx, []BulkSetting{{ProgramID: "p1", TeamID: "t1", Str: "@daily"}})
+	if err != nil || op.ID != "op1" || op.Status != crontinuous.OperationRunning {
+		t.Fatalf("want the operation started, got %+v, %v", op, err)
+	}
+	if op, err = c.GetOperation(ctx, op.ID); err != nil || op.Total != 1 {
+		t.Fatalf("want the operation, got %+v, %v", op, err)
+	}
+	if op, err = c.CancelOperation(ctx, op.ID); err != nil || op.Status != crontinuous.OperationCancelled {
+		t.Fatalf("want the operation cancelled, got %+v, %v", op, err)
+	}
+	want := []string{"POST /entries?async=true", "GET /operations/op1", "DELETE /operations/op1"}
+	if diff := cmp.Diff(want, requests); diff != "" {
+		t.Errorf("requests got!=want, diff %s", diff)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package commands
+
+import (
+	_ "embed" // Used to embed the dev fixtures.
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	yaml "gopkg.in/yaml.v2"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// Defaults of the dev mode, matching the Minio
+// service of the docker-compose.yml file.
+const (
+	devHTTPPort    = 8081
+	devRegion      = "local-region"
+	devS3Endpoint  = "http://localhost:9000"
+	devBucket      = "crontinuous"
+	devAccessKey   = "minioadmin"
+	devSecretKey   = "minioadmin"
+	devVulcanAPI   = "http://localhost:8080/api"
+	devVulcanUser  = "vulcan-scheduler@vulcan.com"
+	devVulcanToken = "dev-token"
+)
+
+// devFixtures is the manifest with the entries
+// seeded in the empty bucket of the dev mode.
+//
+//go:embed fixtures/dev.yaml
+var devFixtures []byte
+
+// withDevDefaults returns the given config with the settings not
+// configured set to the ones of the dev mode. The S3 requests are
+// always path-style, as Minio and localstack need.
+func withDevDefaults(c config) config {
+	if c.HTTPPort == 0 {
+		c.HTTPPort = devHTTPPort
+	}
+	if c.Region == "" {
+		c.Region = devRegion
+	}
+	if c.AWSS3Endpoint == "" {
+		c.AWSS3Endpoint = devS3Endpoint
+	}
+	c.PathStyle = true
+	if c.Bucket == "" {
+		c.Bucket = devBucket
+	}
+	if c.VulcanAPI == "" {
+		c.VulcanAPI = devVulcanAPI
+	}
+	if c.VulcanUser == "" {
+		c.VulcanUser = devVulcanUser
+	}
+	if c.VulcanToken == "" {
+		c.VulcanToken = devVulcanToken
+	}
+	return c
+}
+
+// devCredentials returns the credentials of the Minio of the
+// docker-compose.yml file, unless other ones are set in the environment.
+func devCredentials() *credentials.Credentials {
+	if os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		return nil
+	}
+	return credentials.NewStaticCredentials(devAccessKey, devSecretKey, "")
+}
+
+// createBucket creates the given bucket if it does not exist.
+func createBucket(client s3iface.S3API, bucket string) error {
+	_, err := client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return nil
+	}
+	_, err = client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou {
+		return nil
+	}
+	return err
+}
+
+// seedDevEntries saves the entries of the dev fixtures in
+// the given store if it does not have any scan or report entry.
+func seedDevEntries(store *crontinuous.S3CronStore) (bool, error) {
+	scans, err := store.GetScanEntries()
+	if err != nil {
+		return false, err
+	}
+	reports, err := store.GetReportEntries()
+	if err != nil {
+		return false, err
+	}
+	if len(scans) > 0 || len(reports) > 0 {
+		return false, nil
+	}
+
+	var m crontinuous.Manifest
+	if err := yaml.UnmarshalStrict(devFixtures, &m); err != nil {
+		return false, fmt.Errorf("decoding dev fixtures: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return false, fmt.Errorf("invalid dev fixtures: %w", err)
+	}
+	for _, e := range m.Scans {
+		scans[e.ProgramID] = e
+	}
+	for _, e := range m.Reports {
+		reports[e.TeamID] = e
+	}
+	if err := store.SaveScanEntries(scans); err != nil {
+		return false, err
+	}
+	return true, store.SaveReportEntries(reports)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/client"
+)
+
+// commandTimeout is the maximum time the plan and apply
+// commands wait for crontinuous, including retries.
+const commandTimeout = 5 * time.Minute
+
+var (
+	manifestFile   string
+	crontinuousURL string
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes needed to match the schedules of a file",
+	Long: `Compares the scan and report entries defined in a YAML file with the
+entries of a running crontinuous and shows the entries to add, change and delete.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		_, err := plan(ctx, os.Stdout)
+		return err
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply the schedules of a file",
+	Long: `Makes the scan and report entries of a running crontinuous match the
+ones defined in a YAML file. Entries not defined in the file are deleted.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+		changes, err := plan(ctx, os.Stdout)
+		if err != nil {
+			return err
+		}
+		return apply(ctx, client.NewClient(crontinuousURL), changes)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{planCmd, applyCmd} {
+		cmd.Flags().StringVarP(&manifestFile, "file", "f", "schedules.yaml", "file defining the desired schedules")
+		cmd.Flags().StringVarP(&crontinuousURL, "url", "u", "http://localhost:8081", "URL of the crontinuous API")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+// plan reads the manifest file and writes to w the changes needed
+// to make the entries of crontinuous match it.
+func plan(ctx context.Context, w io.Writer) ([]crontinuous.Change, error) {
+	m, err := readManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	c := client.NewClient(crontinuousURL)
+	scans, err := c.ListScanEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing scan entries: %w", err)
+	}
+	reports, err := c.ListReportEntries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing report entries: %w", err)
+	}
+	current := crontinuous.Manifest{Scans: scans, Reports: reports}
+
+	var changes []crontinuous.Change
+	for _, typ := range m.Types() {
+		changes = append(changes, crontinuous.Diff(typ, current.Entries(typ), m.Entries(typ))...)
+	}
+	writeChanges(w, changes)
+	return changes, nil
+}
+
+func readManifest(path string) (crontinuous.Manifest, error) {
+	var m crontinuous.Manifest
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := yaml.UnmarshalStrict(content, &m); err != nil {
+		return m, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return m, m.Validate()
+}
+
+func writeChanges(w io.Writer, changes []crontinuous.Change) {
+	var added, changed, deleted int
+	for _, c := range changes {
+		switch c.Action {
+		case crontinuous.ChangeAdd:
+			added++
+			fmt.Fprintf(w, "+ %s %s (team %s) %q\n", c.Type, c.ID, c.Desired.GetTeamID(), c.Desired.GetCronSpec())
+		case crontinuous.ChangeUpdate:
+			changed++
+			fmt.Fprintf(w, "~ %s %s (team %s) %q -> %q\n", c.Type, c.ID, c.Desired.GetTeamID(),
+				c.Current.GetCronSpec(), c.Desired.GetCronSpec())
+		case crontinuous.ChangeDelete:
+			deleted++
+			fmt.Fprintf(w, "- %s %s (team %s) %q\n", c.Type, c.ID, c.Current.GetTeamID(), c.Current.GetCronSpec())
+		}
+	}
+	fmt.Fprintf(w, "Plan: %d to add, %d to change, %d to delete.\n", added, changed, deleted)
+}
+
+// apply performs the given changes, stopping at the first error.
+func apply(ctx context.Context, c *client.Client, changes []crontinuous.Change) error {
+	for _, ch := range changes {
+		var err error
+		switch ch.Action {
+		case crontinuous.ChangeAdd, crontinuous.ChangeUpdate:
+			err = saveEntry(ctx, c, ch.Desired)
+		case crontinuous.ChangeDelete:
+			err = removeEntry(ctx, c, ch.Current)
+		}
+		if err != nil {
+			return fmt.Errorf("applying %s of %s entry %s: %w", ch.Action, ch.Type, ch.ID, err)
+		}
+	}
+	fmt.Printf("Applied %d changes.\n", len(changes))
+	return nil
+}
+
+func saveEntry(ctx context.Context, c *client.Client, entry crontinuous.CronEntry) error {
+	switch e := entry.(type) {
+	case crontinuous.ScanEntry:
+		return c.SaveScanEntry(ctx, e)
+	case crontinuous.ReportEntry:
+		return c.SaveReportEntry(ctx, e)
+	}
+	return errors.New("unsupported entry")
+}
+
+func removeEntry(ctx context.Context, c *client.Client, entry crontinuous.CronEntry) error {
+	switch entry.(type) {
+	case crontinuous.ScanEntry:
+		return c.RemoveScanEntry(ctx, entry.GetID())
+	case crontinuous.ReportEntry:
+		return c.RemoveReportEntry(ctx, entry.GetID())
+	}
+	return errors.New("unsupported entry")
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/client"
+)
+
+var deadLetterFile string
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [execution-id]",
+	Short: "Replay a failed execution",
+	Long: `Makes a running crontinuous create again the scan of a failed execution,
+using the parameters recorded in its dead letter. The execution is identified
+by its ID or by a file containing its dead letter, as published in the dead
+letter queue, to replay executions failed before the last start of crontinuous.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (len(args) == 0) == (deadLetterFile == "") {
+			return errors.New("either an execution ID or a dead letter file is required")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+
+		c := client.NewClient(crontinuousURL)
+		var (
+			replayed crontinuous.DeadLetter
+			err      error
+		)
+		if deadLetterFile != "" {
+			var l crontinuous.DeadLetter
+			l, err = readDeadLetter(deadLetterFile)
+			if err != nil {
+				return err
+			}
+			replayed, err = c.ReplayDeadLetter(ctx, l)
+		} else {
+			replayed, err = c.ReplayExecution(ctx, args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("replaying execution: %w", err)
+		}
+		fmt.Printf("Replayed scan of program %s (team %s) by execution %s.\n",
+			replayed.Payload.ProgramID, replayed.Payload.TeamID, replayed.Replay.Execution)
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringVarP(&deadLetterFile, "file", "f", "", "file containing the dead letter of the execution")
+	replayCmd.Flags().StringVarP(&crontinuousURL, "url", "u", "http://localhost:8081", "URL of the crontinuous API")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func readDeadLetter(path string) (crontinuous.DeadLetter, error) {
+	var l crontinuous.DeadLetter
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(content, &l); err != nil {
+		return l, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return l, nil
+}
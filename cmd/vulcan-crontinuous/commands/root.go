@@ -5,30 +5,38 @@ Copyright 2020 Adevinta
 package commands
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"runtime"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/julienschmidt/httprouter"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/api"
 )
 
 var (
 	cfgFile string
 	cfg     config
-	cron    *crontinuous.Crontinuous
+	devMode bool
 )
 
 var rootCmd = &cobra.Command{
@@ -37,6 +45,11 @@ var rootCmd = &cobra.Command{
 	Args:  cobra.NoArgs,
 	Long:  `Schedules executions of scans using cron strings`,
 
+	// The config is only needed to run the server,
+	// so it is not read for the subcommands.
+	PreRun: func(cmd *cobra.Command, args []string) {
+		initConfig()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runServer(cfg)
 	},
@@ -52,8 +65,8 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initConfig)
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.vulcan-crontinuous.yaml)")
+	rootCmd.Flags().StringVarP(&cfgFile, "config", "c", "", "config file (default is $HOME/.vulcan-crontinuous.yaml)")
+	rootCmd.Flags().BoolVar(&devMode, "dev", false, "run against a local Minio or localstack, creating the bucket and seeding fixture entries")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -75,45 +88,145 @@ func initConfig() {
 	}
 
 	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println("can't read config: ", err)
-		os.Exit(1)
+		// The dev mode can run without config file.
+		_, notFound := err.(viper.ConfigFileNotFoundError)
+		if !devMode || !notFound {
+			fmt.Println("can't read config: ", err)
+			os.Exit(1)
+		}
 	}
 
-	if err := viper.Unmarshal(&cfg); err != nil {
+	// Unknown keys are rejected so typos and removed
+	// settings do not go unnoticed.
+	if err := viper.GetViper().UnmarshalExact(&cfg); err != nil {
 		fmt.Printf("Can't not decode confing file %s: %s", viper.ConfigFileUsed(), err.Error())
 		os.Exit(1)
 	}
+	if devMode {
+		cfg = withDevDefaults(cfg)
+	}
 
-	if cfg.Group == "" {
-		if runtime.GOOS == "darwin" {
-			cfg.Group = "wheel"
-		} else {
-			cfg.Group = "root"
+	if problems := cfg.validate(); len(problems) > 0 {
+		fmt.Printf("Invalid config file %s:\n", viper.ConfigFileUsed())
+		for _, p := range problems {
+			fmt.Printf("  - %s\n", p)
 		}
+		os.Exit(1)
 	}
 }
 
 type config struct {
-	HTTPPort                   int      `mapstructure:"http-port"`
-	CronDir                    string   `mapstructure:"cron-dir"`
-	CronScriptPath             string   `mapstructure:"cron-script-path"`
-	Region                     string   `mapstructure:"region"`
-	Bucket                     string   `mapstructure:"bucket"`
-	AWSS3Endpoint              string   `mapstructure:"aws-s3-endpoint"`
-	PathStyle                  bool     `mapstructure:"path-style"`
-	Username                   string   `mapstructure:"username"`
-	Group                      string   `mapstructure:"group"`
-	VulcanAPI                  string   `mapstructure:"vulcan-api"`
-	VulcanToken                string   `mapstructure:"vulcan-token"`
-	VulcanUser                 string   `mapstructure:"vulcan-user"`
-	EnableTeamsWhitelistScan   bool     `mapstructure:"enable-teams-whitelist-scan"`
-	TeamsWhitelistScan         []string `mapstructure:"teams-whitelist-scan"`
-	EnableTeamsWhitelistReport bool     `mapstructure:"enable-teams-whitelist-report"`
-	TeamsWhitelistReport       []string `mapstructure:"teams-whitelist-report"`
+	HTTPPort                   int           `mapstructure:"http-port"`
+	HTTPReadHeaderTimeout      time.Duration `mapstructure:"http-read-header-timeout"`
+	HTTPReadTimeout            time.Duration `mapstructure:"http-read-timeout"`
+	HTTPWriteTimeout           time.Duration `mapstructure:"http-write-timeout"`
+	HTTPIdleTimeout            time.Duration `mapstructure:"http-idle-timeout"`
+	HTTPMaxHeaderBytes         int           `mapstructure:"http-max-header-bytes"`
+	TLSCertFile                string        `mapstructure:"tls-cert-file"`
+	TLSKeyFile                 string        `mapstructure:"tls-key-file"`
+	TLSAutocertDomains         []string      `mapstructure:"tls-autocert-domains"`
+	TLSAutocertCacheDir        string        `mapstructure:"tls-autocert-cache-dir"`
+	Region                     string        `mapstructure:"region"`
+	Bucket                     string        `mapstructure:"bucket"`
+	AWSS3Endpoint              string        `mapstructure:"aws-s3-endpoint"`
+	PathStyle                  bool          `mapstructure:"path-style"`
+	S3Shards                   int           `mapstructure:"s3-shards"`
+	S3Compression              bool          `mapstructure:"s3-compression"`
+	S3SortedEntries            bool          `mapstructure:"s3-sorted-entries"`
+	VulcanAPI                  string        `mapstructure:"vulcan-api"`
+	VulcanToken                string        `mapstructure:"vulcan-token"`
+	VulcanUser                 string        `mapstructure:"vulcan-user"`
+	VulcanUserAgent            string        `mapstructure:"vulcan-user-agent"`
+	TeamCredentialsS3Key       string        `mapstructure:"vulcan-team-credentials-s3-key"`
+	TeamCredentialsSSMParam    string        `mapstructure:"vulcan-team-credentials-ssm-parameter"`
+	TeamCredentialsTTL         time.Duration `mapstructure:"vulcan-team-credentials-ttl"`
+	TeamCredentialsRequired    bool          `mapstructure:"vulcan-team-credentials-required"`
+	ReportSchedule             bool          `mapstructure:"vulcan-report-schedule"`
+	HealthProbe                bool          `mapstructure:"vulcan-api-health-probe"`
+	HealthProbeDelay           time.Duration `mapstructure:"vulcan-api-health-probe-delay"`
+	HealthProbeRetries         int           `mapstructure:"vulcan-api-health-probe-retries"`
+	ValidateEntries            bool          `mapstructure:"validate-entries"`
+	EnableTeamsWhitelistScan   bool          `mapstructure:"enable-teams-whitelist-scan"`
+	TeamsWhitelistScan         []string      `mapstructure:"teams-whitelist-scan"`
+	EnableTeamsWhitelistReport bool          `mapstructure:"enable-teams-whitelist-report"`
+	TeamsWhitelistReport       []string      `mapstructure:"teams-whitelist-report"`
+	JournalPath                string        `mapstructure:"journal-path"`
+	StoreCacheDir              string        `mapstructure:"store-cache-dir"`
+	StoreCacheTTL              time.Duration `mapstructure:"store-cache-ttl"`
+	StoreFaultGetRate          float64       `mapstructure:"store-fault-get-rate"`
+	StoreFaultSaveRate         float64       `mapstructure:"store-fault-save-rate"`
+	StoreFaultLatency          time.Duration `mapstructure:"store-fault-latency"`
+	ReportScanMinGap           time.Duration `mapstructure:"report-scan-min-gap"`
+	ScanCapacity               int           `mapstructure:"scan-capacity"`
+	EnforceScanCapacity        bool          `mapstructure:"enforce-scan-capacity"`
+	SpecRulesFile              string        `mapstructure:"spec-rules-file"`
+	SpecAliases                []string      `mapstructure:"spec-aliases"`
+	MetadataSchemaFile         string        `mapstructure:"metadata-schema-file"`
+	ScanIDStrategy             string        `mapstructure:"scan-id-strategy"`
+	ReportIDStrategy           string        `mapstructure:"report-id-strategy"`
+	FeatureFlagsFile           string        `mapstructure:"feature-flags-file"`
+	EnableDebug                bool          `mapstructure:"enable-debug"`
+	AdminToken                 string        `mapstructure:"admin-token"`
+	IdempotencyWindow          time.Duration `mapstructure:"idempotency-window"`
+	RequestReadTimeout         time.Duration `mapstructure:"request-read-timeout"`
+	RequestWriteTimeout        time.Duration `mapstructure:"request-write-timeout"`
+	RequestTimeouts            []string      `mapstructure:"request-timeouts"`
+	StopTimeout                time.Duration `mapstructure:"stop-timeout"`
+	ExecutionTimeout           time.Duration `mapstructure:"execution-timeout"`
+	WarmUp                     time.Duration `mapstructure:"warm-up"`
+	WarmUpGradual              bool          `mapstructure:"warm-up-gradual"`
+	GitSyncRepo                string        `mapstructure:"git-sync-repo"`
+	GitSyncBranch              string        `mapstructure:"git-sync-branch"`
+	GitSyncPath                string        `mapstructure:"git-sync-path"`
+	GitSyncDir                 string        `mapstructure:"git-sync-dir"`
+	GitSyncInterval            time.Duration `mapstructure:"git-sync-interval"`
+	ProvisionTeamScanSpec      string        `mapstructure:"provision-team-scan-spec"`
+	ProvisionReportSpec        string        `mapstructure:"provision-report-spec"`
+	ProvisionInterval          time.Duration `mapstructure:"provision-interval"`
+	CronEngine                 string        `mapstructure:"cron-engine"`
+	CronEngineWatchdogInterval time.Duration `mapstructure:"cron-engine-watchdog-interval"`
+	SaveInterval               time.Duration `mapstructure:"save-interval"`
+	ShardIndex                 int           `mapstructure:"shard-index"`
+	ShardCount                 int           `mapstructure:"shard-count"`
+	ShardSyncInterval          time.Duration `mapstructure:"shard-sync-interval"`
+	ExecutionLockTable         string        `mapstructure:"execution-lock-table"`
+	ExecutionLockTTL           time.Duration `mapstructure:"execution-lock-ttl"`
+	EnableHistory              bool          `mapstructure:"enable-history"`
+	HistoryLimit               int           `mapstructure:"history-limit"`
+	HistoryMaxAge              time.Duration `mapstructure:"history-max-age"`
+	HistoryPruneInterval       time.Duration `mapstructure:"history-prune-interval"`
+	DivergenceCheckInterval    time.Duration `mapstructure:"divergence-check-interval"`
+	ReadOnlyReplica            bool          `mapstructure:"read-only-replica"`
+	ReplicaRefreshInterval     time.Duration `mapstructure:"replica-refresh-interval"`
+	StatsdAddress              string        `mapstructure:"statsd-address"`
+	StatsdPrefix               string        `mapstructure:"statsd-prefix"`
+	StatsdTags                 []string      `mapstructure:"statsd-tags"`
+	StatsdInterval             time.Duration `mapstructure:"statsd-interval"`
+	SentryDSN                  string        `mapstructure:"sentry-dsn"`
+	SentryEnvironment          string        `mapstructure:"sentry-environment"`
+	SentryTags                 []string      `mapstructure:"sentry-tags"`
+	AlertWebhookURL            string        `mapstructure:"alert-webhook-url"`
+	AlertWebhookSecret         string        `mapstructure:"alert-webhook-secret"`
+	AlertChannelWebhooks       []string      `mapstructure:"alert-channel-webhooks"`
+	AlertFailureThreshold      int           `mapstructure:"alert-failure-threshold"`
+	MaxConcurrentScanJobs      int           `mapstructure:"max-concurrent-scan-jobs"`
+	MaxConcurrentReportJobs    int           `mapstructure:"max-concurrent-report-jobs"`
+	MaxConcurrentTeamScanJobs  int           `mapstructure:"max-concurrent-team-scan-jobs"`
+	TeamCircuitThreshold       int           `mapstructure:"team-circuit-threshold"`
+	TeamCircuitCooldown        time.Duration `mapstructure:"team-circuit-cooldown"`
+	TeamDailyBudget            time.Duration `mapstructure:"team-daily-budget"`
+	TeamBudgets                []string      `mapstructure:"team-budgets"`
+	DeadLetterSQSQueueURL      string        `mapstructure:"dead-letter-sqs-queue-url"`
+	DeadLetterS3Prefix         string        `mapstructure:"dead-letter-s3-prefix"`
+	CronScriptPath             string        `mapstructure:"cron-script-path"`
 }
 
 func runServer(c config) error {
-	sess, err := session.NewSession(&aws.Config{Region: &c.Region})
+	awsCfg := &aws.Config{Region: &c.Region}
+	if devMode {
+		awsCfg.Credentials = devCredentials()
+	}
+	sess, err := session.NewSession(awsCfg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -122,290 +235,434 @@ func runServer(c config) error {
 	if c.AWSS3Endpoint != "" {
 		s3Client = s3.New(sess, aws.NewConfig().WithEndpoint(c.AWSS3Endpoint).WithS3ForcePathStyle(c.PathStyle))
 	}
+	if devMode {
+		if err := createBucket(s3Client, c.Bucket); err != nil {
+			fmt.Printf("Can not create dev bucket error: %s", err.Error())
+			os.Exit(1)
+		}
+	}
 
 	vulcanc := &crontinuous.VulcanClient{
 		VulcanAPI:   c.VulcanAPI,
 		VulcanToken: c.VulcanToken,
 		VulcanUser:  c.VulcanUser,
+		UserAgent:   c.VulcanUserAgent,
 	}
-
-	s3Store := crontinuous.NewS3CronStore(c.Bucket,
-		crontinuous.S3ScansCrontabFilename, crontinuous.S3ReportsCrontabFilename,
-		s3Client)
-
-	cron = crontinuous.NewCrontinuous(
-		crontinuous.Config{
-			Bucket:                     c.Bucket,
-			EnableTeamsWhitelistScan:   c.EnableTeamsWhitelistScan,
-			TeamsWhitelistScan:         c.TeamsWhitelistScan,
-			EnableTeamsWhitelistReport: c.EnableTeamsWhitelistReport,
-			TeamsWhitelistReport:       c.TeamsWhitelistReport,
-		},
-		logrus.New(),
-		vulcanc, s3Store,
-		vulcanc, s3Store,
-	)
-
-	err = cron.Start()
-	if err != nil {
-		fmt.Printf("Can not start crontinuous error: %s", err.Error())
-		os.Exit(1)
+	switch {
+	case c.TeamCredentialsSSMParam != "":
+		store := crontinuous.NewSSMTeamCredentialsStore(ssm.New(sess), c.TeamCredentialsSSMParam)
+		vulcanc.TeamCredentials = crontinuous.NewTeamCredentialsCache(store, c.TeamCredentialsTTL)
+	case c.TeamCredentialsS3Key != "":
+		store := crontinuous.NewS3TeamCredentialsStore(s3Client, c.Bucket, c.TeamCredentialsS3Key)
+		vulcanc.TeamCredentials = crontinuous.NewTeamCredentialsCache(store, c.TeamCredentialsTTL)
 	}
+	vulcanc.RequireTeamCredentials = c.TeamCredentialsRequired
+	vulcanc.ReportSchedule = c.ReportSchedule
 
-	router := httprouter.New()
-
-	router.GET("/healthcheck", status)
-
-	// Scan scheduling endpoints.
-	router.GET("/entries", getScanSchedulesHandler)
-	router.POST("/entries", scanBulkSettingsHandler)
-	router.GET("/entries/:programID", getScanScheduleByIDHandler)
-	router.DELETE("/entries/:programID", removeScanScheduleHandler)
-	router.POST("/settings/:programID/:teamID", scanSettingHandler)
-
-	// Report scheduling endpoints.
-	router.GET("/report/entries", getReportSchedulesHandler)
-	router.POST("/report/entries", reportBulkSettingsHandler)
-	router.GET("/report/entries/:teamID", getReportScheduleByIDHandler)
-	router.DELETE("/report/entries/:teamID", removeReportScheduleHandler)
-	router.POST("/report/settings/:teamID", reportSettingHandler)
-
-	addr := fmt.Sprintf(":%v", c.HTTPPort)
-	fmt.Printf("Start listening at %s\n", addr)
-	err = http.ListenAndServe(addr, router)
-	cron.Stop()
-
-	return err
-}
-
-type HealthcheckResponse struct {
-	Status string `json:"status"`
-}
-
-func status(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	resp := HealthcheckResponse{
-		Status: "OK",
+	storeOpts := []crontinuous.S3StoreOption{crontinuous.WithS3Shards(c.S3Shards)}
+	if c.S3Compression {
+		storeOpts = append(storeOpts, crontinuous.WithS3Compression())
 	}
-	encoder := json.NewEncoder(w)
-	err := encoder.Encode(&resp)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if c.S3SortedEntries {
+		storeOpts = append(storeOpts, crontinuous.WithS3SortedEntries())
 	}
-}
-
-type cronString struct {
-	Str string `json:"str"`
-}
-
-type createSetting struct {
-	Str       string `json:"str"`
-	TeamID    string `json:"team_id"`
-	ProgramID string `json:"program_id"`
-	Overwrite bool   `json:"overwrite"`
-}
-
-// Bulk Settings
-func scanBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	settings := []createSetting{}
-	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+	s3Store := crontinuous.NewS3CronStore(c.Bucket,
+		crontinuous.S3ScansCrontabFilename, crontinuous.S3ReportsCrontabFilename,
+		s3Client, storeOpts...)
+	if devMode {
+		seeded, err := seedDevEntries(s3Store)
+		if err != nil {
+			fmt.Printf("Can not seed dev entries error: %s", err.Error())
+			os.Exit(1)
+		}
+		if seeded {
+			fmt.Println("Seeded the dev fixture entries")
+		}
 	}
 
-	entries := []crontinuous.CronEntry{}
-	overwriteSettings := []bool{}
-	for _, s := range settings {
-		entries = append(entries, crontinuous.ScanEntry{
-			CronSpec:  s.Str,
-			ProgramID: s.ProgramID,
-			TeamID:    s.TeamID,
-		})
-		overwriteSettings = append(overwriteSettings, s.Overwrite)
+	// The faults are only injected in the operations of the entries.
+	var entryStore entryStores = s3Store
+	faults := crontinuous.Faults{
+		GetFailureRate:  c.StoreFaultGetRate,
+		SaveFailureRate: c.StoreFaultSaveRate,
+		Latency:         c.StoreFaultLatency,
 	}
-
-	bulkSettingsHandler(crontinuous.ScanCronType, entries, overwriteSettings, w, r, ps)
-}
-func reportBulkSettingsHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	settings := []createSetting{}
-	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+	if faults != (crontinuous.Faults{}) {
+		fmt.Printf("Injecting faults in the store of the entries: %+v\n", faults)
+		entryStore = crontinuous.NewFaultyStore(s3Store, faults)
 	}
 
-	entries := []crontinuous.CronEntry{}
-	overwriteSettings := []bool{}
-	for _, s := range settings {
-		entries = append(entries, crontinuous.ReportEntry{
-			CronSpec: s.Str,
-			TeamID:   s.TeamID,
-		})
-		overwriteSettings = append(overwriteSettings, s.Overwrite)
+	newScheduler, err := crontinuous.SchedulerEngine(c.CronEngine, logrus.New())
+	if err != nil {
+		fmt.Printf("Can not create the cron engine error: %s", err.Error())
+		os.Exit(1)
 	}
-
-	bulkSettingsHandler(crontinuous.ReportCronType, entries, overwriteSettings, w, r, ps)
-}
-func bulkSettingsHandler(typ crontinuous.CronType, entries []crontinuous.CronEntry, overwriteSettings []bool,
-	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-
-	if err := cron.BulkCreate(typ, entries, overwriteSettings); err != nil {
-		status := http.StatusInternalServerError
-		if err == crontinuous.ErrMalformedSchedule {
-			status = http.StatusUnprocessableEntity
+	opts := []crontinuous.Option{
+		crontinuous.WithTeamScans(vulcanc, entryStore),
+		crontinuous.WithTemplates(s3Store),
+		crontinuous.WithPauseWindows(s3Store),
+		crontinuous.WithSuppressions(s3Store),
+		crontinuous.WithTeamDefaults(s3Store),
+		crontinuous.WithScheduler(newScheduler),
+		crontinuous.WithEngineWatchdog(c.CronEngineWatchdogInterval),
+		crontinuous.WithSaveInterval(c.SaveInterval),
+		crontinuous.WithShard(c.ShardIndex, c.ShardCount),
+		crontinuous.WithTeamCircuit(c.TeamCircuitThreshold, c.TeamCircuitCooldown),
+		crontinuous.WithTeamBudgets(c.TeamDailyBudget, teamBudgets(c.TeamBudgets)),
+		crontinuous.WithMaxConcurrentJobs(crontinuous.ScanCronType, c.MaxConcurrentScanJobs),
+		crontinuous.WithMaxConcurrentJobs(crontinuous.ReportCronType, c.MaxConcurrentReportJobs),
+		crontinuous.WithMaxConcurrentJobs(crontinuous.TeamScanCronType, c.MaxConcurrentTeamScanJobs),
+	}
+	if c.CronScriptPath != "" {
+		opts = append(opts, crontinuous.WithCommands(entryStore, c.CronScriptPath))
+	}
+	if c.ReadOnlyReplica {
+		opts = append(opts, crontinuous.WithReadOnlyReplica())
+	}
+	if c.ValidateEntries {
+		opts = append(opts, crontinuous.WithValidator(vulcanc))
+	}
+	if c.HealthProbe {
+		opts = append(opts, crontinuous.WithHealthProbe(vulcanc, c.HealthProbeDelay, c.HealthProbeRetries))
+	}
+	if c.ExecutionLockTable != "" {
+		locker := crontinuous.NewDynamoDBExecutionLocker(dynamodb.New(sess), c.ExecutionLockTable)
+		opts = append(opts, crontinuous.WithExecutionLocks(locker, c.ExecutionLockTTL))
+	}
+	if c.EnableHistory {
+		opts = append(opts,
+			crontinuous.WithHistory(s3Store, c.HistoryLimit),
+			crontinuous.WithHistoryRetention(c.HistoryMaxAge),
+		)
+	}
+	if c.JournalPath != "" {
+		opts = append(opts, crontinuous.WithJournal(crontinuous.NewFileJournal(c.JournalPath)))
+	}
+	if c.StoreCacheDir != "" {
+		opts = append(opts, crontinuous.WithStoreCache(c.StoreCacheDir, c.StoreCacheTTL))
+	}
+	if c.FeatureFlagsFile != "" {
+		flags, err := crontinuous.NewFileFeatureFlags(c.FeatureFlagsFile, logrus.New())
+		if err != nil {
+			fmt.Printf("Can not load feature flags error: %s", err.Error())
+			os.Exit(1)
 		}
-		http.Error(w, err.Error(), status)
+		opts = append(opts, crontinuous.WithFeatureFlags(flags))
 	}
-}
-
-// Setting
-func scanSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	programID := ps.ByName("programID")
-	if programID == "" {
-		http.Error(w, "Program ID missing", 400)
-		return
+	scanIDs, err := crontinuous.ScanIDStrategyByName(c.ScanIDStrategy)
+	if err != nil {
+		fmt.Printf("Can not create the scan ID strategy error: %s", err.Error())
+		os.Exit(1)
 	}
-	teamID := ps.ByName("teamID")
-	if teamID == "" {
-		http.Error(w, "Team ID missing", 400)
-		return
+	reportIDs, err := crontinuous.ReportIDStrategyByName(c.ReportIDStrategy)
+	if err != nil {
+		fmt.Printf("Can not create the report ID strategy error: %s", err.Error())
+		os.Exit(1)
 	}
-
-	var c cronString
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+	opts = append(opts,
+		crontinuous.WithScanIDStrategy(scanIDs),
+		crontinuous.WithReportIDStrategy(reportIDs),
+	)
+	if c.SpecRulesFile != "" {
+		rules, err := crontinuous.LoadSpecRules(c.SpecRulesFile)
+		if err != nil {
+			fmt.Printf("Can not load spec rules error: %s", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, crontinuous.WithSpecRules(rules))
 	}
-
-	entry := crontinuous.ScanEntry{
-		ProgramID: programID,
-		TeamID:    teamID,
-		CronSpec:  c.Str,
+	if len(c.SpecAliases) > 0 {
+		opts = append(opts, crontinuous.WithSpecAliases(specAliases(c.SpecAliases)))
 	}
-
-	settingHandler(crontinuous.ScanCronType, entry, w, r, ps)
-}
-func reportSettingHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	teamID := ps.ByName("teamID")
-	if teamID == "" {
-		http.Error(w, "Team ID missing", 400)
-		return
+	if c.MetadataSchemaFile != "" {
+		schema, err := crontinuous.LoadMetadataSchema(c.MetadataSchemaFile)
+		if err != nil {
+			fmt.Printf("Can not load metadata schema error: %s", err.Error())
+			os.Exit(1)
+		}
+		opts = append(opts, crontinuous.WithMetadataSchema(schema))
+	}
+	var sentry *crontinuous.SentryReporter
+	if c.SentryDSN != "" {
+		reporter, err := crontinuous.NewSentryReporter(crontinuous.SentryConfig{
+			DSN:         c.SentryDSN,
+			Environment: c.SentryEnvironment,
+			Tags:        c.SentryTags,
+		}, logrus.New())
+		if err != nil {
+			fmt.Printf("Can not create sentry reporter error: %s", err.Error())
+			os.Exit(1)
+		}
+		sentry = reporter
+		opts = append(opts, crontinuous.WithErrorReporter(reporter))
 	}
-
-	var c cronString
-	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), 400)
-		return
+	if c.AlertWebhookURL != "" {
+		var webhookOpts []crontinuous.WebhookOption
+		if c.AlertWebhookSecret != "" {
+			webhookOpts = append(webhookOpts, crontinuous.WithWebhookSecret(c.AlertWebhookSecret))
+		}
+		notifier := crontinuous.NewWebhookNotifier(c.AlertWebhookURL, channelWebhooks(c.AlertChannelWebhooks), webhookOpts...)
+		opts = append(opts, crontinuous.WithNotifier(notifier, c.AlertFailureThreshold),
+			crontinuous.WithDivergenceNotifier(notifier))
 	}
 
-	entry := crontinuous.ReportEntry{
-		TeamID:   teamID,
-		CronSpec: c.Str,
+	switch {
+	case c.DeadLetterSQSQueueURL != "":
+		q := crontinuous.NewSQSDeadLetterQueue(sqs.New(sess), c.DeadLetterSQSQueueURL)
+		opts = append(opts, crontinuous.WithDeadLetterQueue(q))
+	case c.DeadLetterS3Prefix != "":
+		q := crontinuous.NewS3DeadLetterQueue(s3Client, c.Bucket, c.DeadLetterS3Prefix)
+		opts = append(opts, crontinuous.WithDeadLetterQueue(q))
 	}
 
-	settingHandler(crontinuous.ReportCronType, entry, w, r, ps)
-}
-func settingHandler(typ crontinuous.CronType, entry crontinuous.CronEntry,
-	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	cron := crontinuous.NewCrontinuous(
+		crontinuousConfig(c),
+		logrus.New(),
+		vulcanc, entryStore,
+		vulcanc, entryStore,
+		opts...,
+	)
 
-	if err := cron.SaveEntry(typ, entry); err != nil {
-		status := http.StatusInternalServerError
-		if err == crontinuous.ErrMalformedSchedule {
-			status = http.StatusUnprocessableEntity
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	defer stopSync()
+	var (
+		gitSyncer   *crontinuous.GitSyncer
+		provisioner *crontinuous.Provisioner
+		// runners mutate the entries, so they
+		// are run once the entries are loaded.
+		runners []func(context.Context)
+	)
+	if c.GitSyncRepo != "" {
+		gitSyncer = crontinuous.NewGitSyncer(crontinuous.GitSyncConfig{
+			Repo:     c.GitSyncRepo,
+			Branch:   c.GitSyncBranch,
+			Path:     c.GitSyncPath,
+			Dir:      c.GitSyncDir,
+			Interval: c.GitSyncInterval,
+		}, cron, logrus.New())
+		runners = append(runners, gitSyncer.Run)
+	}
+	if c.ProvisionTeamScanSpec != "" || c.ProvisionReportSpec != "" {
+		provisioner, err = crontinuous.NewProvisioner(crontinuous.ProvisionConfig{
+			TeamScanSpec: c.ProvisionTeamScanSpec,
+			ReportSpec:   c.ProvisionReportSpec,
+			Interval:     c.ProvisionInterval,
+		}, cron, vulcanc, logrus.New())
+		if err != nil {
+			fmt.Printf("Can not create provisioner error: %s", err.Error())
+			os.Exit(1)
+		}
+		runners = append(runners, provisioner.Run)
+	}
+	if c.EnableHistory && c.HistoryPruneInterval > 0 && !c.ReadOnlyReplica {
+		pruner := crontinuous.NewHistoryPruner(cron, c.HistoryPruneInterval, logrus.New())
+		runners = append(runners, pruner.Run)
+	}
+	if c.DivergenceCheckInterval > 0 && !c.ReadOnlyReplica {
+		checker := crontinuous.NewDivergenceChecker(cron, c.DivergenceCheckInterval, logrus.New())
+		runners = append(runners, checker.Run)
+	}
+	if c.ShardCount > 1 && !c.ReadOnlyReplica {
+		syncer := crontinuous.NewShardSyncer(cron, c.ShardSyncInterval, logrus.New())
+		runners = append(runners, syncer.Run)
+	}
+	if c.ReadOnlyReplica {
+		refresher := crontinuous.NewReplicaRefresher(cron, c.ReplicaRefreshInterval, logrus.New())
+		runners = append(runners, refresher.Run)
+	}
+	if c.StatsdAddress != "" {
+		emitter, err := crontinuous.NewStatsdEmitter(crontinuous.StatsdConfig{
+			Address:  c.StatsdAddress,
+			Prefix:   c.StatsdPrefix,
+			Tags:     c.StatsdTags,
+			Interval: c.StatsdInterval,
+		}, cron, logrus.New())
+		if err != nil {
+			fmt.Printf("Can not create statsd emitter error: %s", err.Error())
+			os.Exit(1)
+		}
+		go emitter.Run(syncCtx)
+	}
+
+	prometheus.MustRegister(crontinuous.NewMetricsCollector(cron))
+	if sentry != nil {
+		prometheus.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: "crontinuous",
+			Name:      "sentry_dropped_total",
+			Help:      "Number of errors not reported to Sentry because its queue was full.",
+		}, func() float64 { return float64(sentry.Dropped()) }))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/", api.NewHandler(cron, api.Options{
+		GitSyncer:         gitSyncer,
+		TeamLister:        vulcanc,
+		Provisioner:       provisioner,
+		AdminToken:        c.AdminToken,
+		EnableDebug:       c.EnableDebug,
+		IdempotencyWindow: c.IdempotencyWindow,
+		Timeouts: api.Timeouts{
+			Read:      c.RequestReadTimeout,
+			Write:     c.RequestWriteTimeout,
+			Endpoints: endpointTimeouts(c.RequestTimeouts),
+		},
+	}))
+
+	srv, serve := newServer(c, mux)
+	fmt.Printf("Start listening at %s\n", srv.Addr)
+	srvErrs := make(chan error, 1)
+	go func() {
+		srvErrs <- serve()
+	}()
+
+	// The entries are loaded while the API is served,
+	// reporting the progress in /readyz.
+	startErrs := make(chan error, 1)
+	go func() {
+		startErrs <- cron.Start()
+	}()
+
+	// Stop gracefully on termination so running jobs
+	// are given a chance to finish and state is flushed.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	// SIGHUP reloads the config and the entries,
+	// and SIGUSR1 logs the state of the instance.
+	ops := make(chan os.Signal, 1)
+	signal.Notify(ops, syscall.SIGHUP, syscall.SIGUSR1)
+	for stopped := false; !stopped; {
+		select {
+		case startErr := <-startErrs:
+			if startErr != nil {
+				fmt.Printf("Can not start crontinuous error: %s", startErr.Error())
+				os.Exit(1)
+			}
+			startErrs = nil
+			for _, run := range runners {
+				go run(syncCtx)
+			}
+		case err = <-srvErrs:
+			stopped = true
+		case sig := <-ops:
+			if sig == syscall.SIGUSR1 {
+				cron.LogState()
+				continue
+			}
+			fmt.Printf("Received signal %s, reloading\n", sig)
+			reloaded, reloadErr := reloadConfig()
+			if reloadErr == nil {
+				reloadErr = cron.Reload(crontinuousConfig(reloaded))
+			}
+			if reloadErr != nil {
+				fmt.Printf("Error reloading: %s\n", reloadErr.Error())
+			}
+		case sig := <-sigs:
+			fmt.Printf("Received signal %s, stopping\n", sig)
+			err = srv.Shutdown(context.Background())
+			stopped = true
 		}
-		http.Error(w, err.Error(), status)
 	}
-}
 
-// Remove Schedule
-func removeScanScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	id := ps.ByName("programID")
-	if id == "" {
-		http.Error(w, "Bad request", 400)
-		return
+	stopSync()
+	if stopErr := cron.Stop(); stopErr != nil {
+		fmt.Printf("Error stopping crontinuous: %s\n", stopErr.Error())
 	}
-
-	removeScheduleHandler(crontinuous.ScanCronType, id, w, r, ps)
-}
-func removeReportScheduleHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	id := ps.ByName("teamID")
-	if id == "" {
-		http.Error(w, "Bad request", 400)
-		return
+	if sentry != nil {
+		sentry.Stop()
 	}
 
-	removeScheduleHandler(crontinuous.ReportCronType, id, w, r, ps)
+	return err
 }
-func removeScheduleHandler(typ crontinuous.CronType, id string,
-	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
-	err := cron.RemoveEntry(typ, id)
-	if err != nil {
-		if err == crontinuous.ErrScheduleNotFound {
-			http.NotFound(w, r)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// crontinuousConfig returns the config of crontinuous
+// in the given config.
+func crontinuousConfig(c config) crontinuous.Config {
+	return crontinuous.Config{
+		Bucket:                     c.Bucket,
+		EnableTeamsWhitelistScan:   c.EnableTeamsWhitelistScan,
+		TeamsWhitelistScan:         c.TeamsWhitelistScan,
+		EnableTeamsWhitelistReport: c.EnableTeamsWhitelistReport,
+		TeamsWhitelistReport:       c.TeamsWhitelistReport,
+		ReportScanMinGap:           c.ReportScanMinGap,
+		ScanCapacity:               c.ScanCapacity,
+		EnforceScanCapacity:        c.EnforceScanCapacity,
+		StopTimeout:                c.StopTimeout,
+		ExecutionTimeout:           c.ExecutionTimeout,
+		WarmUp:                     c.WarmUp,
+		WarmUpGradual:              c.WarmUpGradual,
 	}
 }
 
-// Get Schedules
-func getScanSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	getSchedulesHandler(crontinuous.ScanCronType, w, r, ps)
-}
-func getReportSchedulesHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	getSchedulesHandler(crontinuous.ReportCronType, w, r, ps)
-}
-func getSchedulesHandler(typ crontinuous.CronType,
-	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-
-	entries, err := cron.GetEntries(typ)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// reloadConfig reads the config file and the ENV variables
+// again. Unlike initConfig it returns the problems found
+// instead of exiting, so the current config is kept.
+func reloadConfig() (config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		if _, notFound := err.(viper.ConfigFileNotFoundError); !devMode || !notFound {
+			return config{}, err
+		}
 	}
-
-	encoder := json.NewEncoder(w)
-	err = encoder.Encode(&entries)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	var c config
+	if err := viper.GetViper().UnmarshalExact(&c); err != nil {
+		return config{}, err
 	}
+	if devMode {
+		c = withDevDefaults(c)
+	}
+	if problems := c.validate(); len(problems) > 0 {
+		return config{}, fmt.Errorf("invalid config: %s", strings.Join(problems, ", "))
+	}
+	return c, nil
 }
 
-// Get Schedule by ID
-func getScanScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	id := ps.ByName("programID")
-	if id == "" {
-		http.Error(w, "Bad request", 400)
-		return
+// teamBudgets returns the daily budgets of the teams
+// given as validated team=duration pairs.
+func teamBudgets(pairs []string) map[string]time.Duration {
+	budgets := make(map[string]time.Duration, len(pairs))
+	for _, p := range pairs {
+		team, budget, _ := strings.Cut(p, "=")
+		budgets[team], _ = time.ParseDuration(budget)
 	}
+	return budgets
+}
 
-	getScheduleByIDHandler(crontinuous.ScanCronType, id, w, r, ps)
+// entryStores are the stores of all the types of entries.
+type entryStores interface {
+	crontinuous.ScanCronStore
+	crontinuous.ReportCronStore
+	crontinuous.TeamScanCronStore
+	crontinuous.CommandCronStore
 }
-func getReportScheduleByIDHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	id := ps.ByName("teamID")
-	if id == "" {
-		http.Error(w, "Bad request", 400)
-		return
-	}
 
-	getScheduleByIDHandler(crontinuous.ReportCronType, id, w, r, ps)
+// specAliases returns the specs of the aliases
+// given as validated @name=spec pairs.
+func specAliases(pairs []string) map[string]string {
+	aliases := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		name, spec, _ := strings.Cut(p, "=")
+		aliases[name] = spec
+	}
+	return aliases
 }
-func getScheduleByIDHandler(typ crontinuous.CronType, id string,
-	w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 
-	entry, err := cron.GetEntryByID(typ, id)
-	if err != nil {
-		if err == crontinuous.ErrScheduleNotFound {
-			http.NotFound(w, r)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// endpointTimeouts returns the timeouts of the endpoints
+// given as validated "<METHOD> <route>=<duration>" items.
+func endpointTimeouts(items []string) map[string]time.Duration {
+	timeouts := make(map[string]time.Duration, len(items))
+	for _, item := range items {
+		endpoint, d, _ := api.ParseEndpointTimeout(item)
+		timeouts[endpoint] = d
 	}
+	return timeouts
+}
 
-	encoder := json.NewEncoder(w)
-	err = encoder.Encode(entry)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// channelWebhooks returns the webhooks of the alert channels
+// given as validated channel=url pairs.
+func channelWebhooks(pairs []string) map[string]string {
+	webhooks := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		channel, url, _ := strings.Cut(p, "=")
+		webhooks[channel] = url
 	}
+	return webhooks
 }
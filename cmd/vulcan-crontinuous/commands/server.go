@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package commands
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Defaults of the HTTP server, used when they are not configured.
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	// The write timeout must leave room for the slowest
+	// endpoints, like the pprof profiles.
+	defaultWriteTimeout   = 2 * time.Minute
+	defaultIdleTimeout    = 2 * time.Minute
+	defaultMaxHeaderBytes = 1 << 20
+)
+
+// newServer returns the HTTP server of the API configured with the timeouts,
+// the header limits and the TLS settings of the given config, and the
+// function that starts serving. HTTP/2 is negotiated when serving HTTPS. The
+// TLS settings must have been validated.
+func newServer(c config, handler http.Handler) (*http.Server, func() error) {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%v", c.HTTPPort),
+		Handler:           handler,
+		ReadHeaderTimeout: orDefault(c.HTTPReadHeaderTimeout, defaultReadHeaderTimeout),
+		ReadTimeout:       orDefault(c.HTTPReadTimeout, defaultReadTimeout),
+		WriteTimeout:      orDefault(c.HTTPWriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       orDefault(c.HTTPIdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes:    c.HTTPMaxHeaderBytes,
+	}
+	if srv.MaxHeaderBytes <= 0 {
+		srv.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+
+	switch {
+	case c.TLSCertFile != "":
+		return srv, func() error {
+			return srv.ListenAndServeTLS(c.TLSCertFile, c.TLSKeyFile)
+		}
+	case len(c.TLSAutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.TLSAutocertDomains...),
+		}
+		if c.TLSAutocertCacheDir != "" {
+			m.Cache = autocert.DirCache(c.TLSAutocertCacheDir)
+		}
+		srv.TLSConfig = &tls.Config{
+			GetCertificate: m.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+			MinVersion:     tls.VersionTLS12,
+		}
+		return srv, func() error {
+			return srv.ListenAndServeTLS("", "")
+		}
+	}
+	return srv, srv.ListenAndServe
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
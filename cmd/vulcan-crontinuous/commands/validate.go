@@ -0,0 +1,290 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package commands
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/api"
+)
+
+// validate checks the config, returning all the problems found, so they can
+// be fixed at once before crontinuous starts instead of making it fail later
+// with AWS or parse errors.
+func (c config) validate() []string {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.HTTPPort < 1 || c.HTTPPort > 65535 {
+		problemf("http-port %d is not between 1 and 65535", c.HTTPPort)
+	}
+	if c.HTTPMaxHeaderBytes < 0 {
+		problemf("http-max-header-bytes can not be negative")
+	}
+	certs := c.TLSCertFile != "" || c.TLSKeyFile != ""
+	if certs && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
+		problemf("tls-cert-file and tls-key-file must be defined together")
+	}
+	if certs && len(c.TLSAutocertDomains) > 0 {
+		problemf("tls-cert-file and tls-autocert-domains are mutually exclusive")
+	}
+
+	// S3 store.
+	if c.Region == "" {
+		problemf("region is required")
+	}
+	if c.Bucket == "" {
+		problemf("bucket is required")
+	}
+	if c.AWSS3Endpoint != "" && !isHTTPURL(c.AWSS3Endpoint) {
+		problemf("aws-s3-endpoint %q is not an HTTP(S) URL", c.AWSS3Endpoint)
+	}
+	if c.S3Shards < 0 || c.S3Shards > 999 {
+		problemf("s3-shards %d is not between 0 and 999", c.S3Shards)
+	}
+
+	// Vulcan API.
+	if !isHTTPURL(c.VulcanAPI) {
+		problemf("vulcan-api %q is not an HTTP(S) URL", c.VulcanAPI)
+	}
+	if c.VulcanToken == "" {
+		problemf("vulcan-token is required")
+	}
+	if c.VulcanUser == "" {
+		problemf("vulcan-user is required")
+	}
+	if c.TeamCredentialsS3Key != "" && c.TeamCredentialsSSMParam != "" {
+		problemf("vulcan-team-credentials-s3-key and vulcan-team-credentials-ssm-parameter can not be both defined")
+	}
+	if c.TeamCredentialsRequired && c.TeamCredentialsS3Key == "" && c.TeamCredentialsSSMParam == "" {
+		problemf("vulcan-team-credentials-required requires vulcan-team-credentials-s3-key or vulcan-team-credentials-ssm-parameter")
+	}
+
+	problems = append(problems, validateWhitelist("scan", c.EnableTeamsWhitelistScan, c.TeamsWhitelistScan)...)
+	problems = append(problems, validateWhitelist("report", c.EnableTeamsWhitelistReport, c.TeamsWhitelistReport)...)
+
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"http-read-header-timeout", c.HTTPReadHeaderTimeout},
+		{"http-read-timeout", c.HTTPReadTimeout},
+		{"http-write-timeout", c.HTTPWriteTimeout},
+		{"http-idle-timeout", c.HTTPIdleTimeout},
+		{"vulcan-team-credentials-ttl", c.TeamCredentialsTTL},
+		{"report-scan-min-gap", c.ReportScanMinGap},
+		{"store-cache-ttl", c.StoreCacheTTL},
+		{"store-fault-latency", c.StoreFaultLatency},
+		{"stop-timeout", c.StopTimeout},
+		{"execution-timeout", c.ExecutionTimeout},
+		{"warm-up", c.WarmUp},
+		{"provision-interval", c.ProvisionInterval},
+		{"history-max-age", c.HistoryMaxAge},
+		{"history-prune-interval", c.HistoryPruneInterval},
+		{"divergence-check-interval", c.DivergenceCheckInterval},
+		{"replica-refresh-interval", c.ReplicaRefreshInterval},
+		{"shard-sync-interval", c.ShardSyncInterval},
+		{"statsd-interval", c.StatsdInterval},
+		{"team-circuit-cooldown", c.TeamCircuitCooldown},
+		{"execution-lock-ttl", c.ExecutionLockTTL},
+		{"vulcan-api-health-probe-delay", c.HealthProbeDelay},
+		{"team-daily-budget", c.TeamDailyBudget},
+		{"cron-engine-watchdog-interval", c.CronEngineWatchdogInterval},
+		{"save-interval", c.SaveInterval},
+		{"idempotency-window", c.IdempotencyWindow},
+		{"request-read-timeout", c.RequestReadTimeout},
+		{"request-write-timeout", c.RequestWriteTimeout},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			problemf("%s can not be negative", d.name)
+		}
+	}
+
+	if c.GitSyncRepo != "" {
+		if c.GitSyncPath == "" {
+			problemf("git-sync-path is required when git-sync-repo is defined")
+		}
+		if c.GitSyncDir == "" {
+			problemf("git-sync-dir is required when git-sync-repo is defined")
+		}
+		if c.GitSyncInterval <= 0 {
+			problemf("git-sync-interval must be positive when git-sync-repo is defined")
+		}
+	}
+	if c.ProvisionInterval > 0 && c.ProvisionTeamScanSpec == "" && c.ProvisionReportSpec == "" {
+		problemf("provision-interval requires provision-team-scan-spec or provision-report-spec")
+	}
+	if _, err := crontinuous.SchedulerEngine(c.CronEngine, nil); err != nil {
+		problemf("cron-engine: %v", err)
+	}
+	if _, err := crontinuous.ScanIDStrategyByName(c.ScanIDStrategy); err != nil {
+		problemf("scan-id-strategy: %v", err)
+	}
+	if _, err := crontinuous.ReportIDStrategyByName(c.ReportIDStrategy); err != nil {
+		problemf("report-id-strategy: %v", err)
+	}
+	if c.WarmUpGradual && c.WarmUp <= 0 {
+		problemf("warm-up-gradual requires warm-up")
+	}
+	if c.ShardCount < 0 {
+		problemf("shard-count can not be negative")
+	}
+	if c.ShardCount > 1 && (c.ShardIndex < 0 || c.ShardIndex >= c.ShardCount) {
+		problemf("shard-index %d is not between 0 and %d", c.ShardIndex, c.ShardCount-1)
+	}
+	if c.ShardCount > 1 && !c.ReadOnlyReplica && c.ShardSyncInterval <= 0 {
+		problemf("shard-sync-interval must be positive when shard-count is greater than 1")
+	}
+	if c.ReadOnlyReplica {
+		if c.ReplicaRefreshInterval <= 0 {
+			problemf("replica-refresh-interval must be positive when read-only-replica is set")
+		}
+		// The journal and the synchronizations change the entries.
+		if c.JournalPath != "" {
+			problemf("read-only-replica and journal-path can not be both defined")
+		}
+		if c.GitSyncRepo != "" {
+			problemf("read-only-replica and git-sync-repo can not be both defined")
+		}
+		if c.ProvisionInterval > 0 {
+			problemf("read-only-replica and provision-interval can not be both defined")
+		}
+	}
+	if c.EnableHistory && c.HistoryLimit < 0 {
+		problemf("history-limit can not be negative")
+	}
+	if c.StatsdAddress != "" {
+		if _, _, err := net.SplitHostPort(c.StatsdAddress); err != nil {
+			problemf("statsd-address %q is not a host:port address", c.StatsdAddress)
+		}
+	}
+	if c.SentryDSN != "" && !isHTTPURL(c.SentryDSN) {
+		problemf("sentry-dsn %q is not an HTTP(S) URL", c.SentryDSN)
+	}
+	for _, t := range c.SentryTags {
+		if !strings.Contains(t, ":") {
+			problemf("sentry-tags %q is not in the format key:value", t)
+		}
+	}
+	if c.AlertWebhookURL != "" && !isHTTPURL(c.AlertWebhookURL) {
+		problemf("alert-webhook-url %q is not an HTTP(S) URL", c.AlertWebhookURL)
+	}
+	if len(c.AlertChannelWebhooks) > 0 && c.AlertWebhookURL == "" {
+		problemf("alert-channel-webhooks requires alert-webhook-url")
+	}
+	if c.AlertWebhookSecret != "" && c.AlertWebhookURL == "" {
+		problemf("alert-webhook-secret requires alert-webhook-url")
+	}
+	for _, w := range c.AlertChannelWebhooks {
+		channel, url, ok := strings.Cut(w, "=")
+		if !ok || channel == "" || !isHTTPURL(url) {
+			problemf("alert-channel-webhooks %q is not in the format channel=url", w)
+		}
+	}
+	if c.DeadLetterSQSQueueURL != "" && !isHTTPURL(c.DeadLetterSQSQueueURL) {
+		problemf("dead-letter-sqs-queue-url %q is not an HTTP(S) URL", c.DeadLetterSQSQueueURL)
+	}
+	if c.DeadLetterSQSQueueURL != "" && c.DeadLetterS3Prefix != "" {
+		problemf("dead-letter-sqs-queue-url and dead-letter-s3-prefix can not be both defined")
+	}
+	limits := []struct {
+		name string
+		n    int
+	}{
+		{"max-concurrent-scan-jobs", c.MaxConcurrentScanJobs},
+		{"max-concurrent-report-jobs", c.MaxConcurrentReportJobs},
+		{"max-concurrent-team-scan-jobs", c.MaxConcurrentTeamScanJobs},
+		{"scan-capacity", c.ScanCapacity},
+		{"vulcan-api-health-probe-retries", c.HealthProbeRetries},
+	}
+	for _, l := range limits {
+		if l.n < 0 {
+			problemf("%s can not be negative", l.name)
+		}
+	}
+	if c.EnforceScanCapacity && c.ScanCapacity == 0 {
+		problemf("enforce-scan-capacity requires scan-capacity")
+	}
+	if c.TeamCircuitThreshold < 0 {
+		problemf("team-circuit-threshold can not be negative")
+	}
+	if c.TeamCircuitThreshold > 0 && c.TeamCircuitCooldown == 0 {
+		problemf("team-circuit-cooldown must be positive when team-circuit-threshold is defined")
+	}
+	for _, b := range c.TeamBudgets {
+		team, budget, ok := strings.Cut(b, "=")
+		d, err := time.ParseDuration(budget)
+		if !ok || team == "" || err != nil || d < 0 {
+			problemf("team-budgets %q is not in the format team=duration", b)
+		}
+	}
+	for _, a := range c.SpecAliases {
+		name, spec, ok := strings.Cut(a, "=")
+		if !ok || crontinuous.ValidateSpecAlias(name, spec) != nil {
+			problemf("spec-aliases %q is not in the format @name=spec with a valid spec", a)
+		}
+	}
+	for _, item := range c.RequestTimeouts {
+		if _, _, err := api.ParseEndpointTimeout(item); err != nil {
+			problemf("request-timeouts: %v", err)
+		}
+	}
+	if c.StoreFaultGetRate < 0 || c.StoreFaultGetRate > 1 {
+		problemf("store-fault-get-rate must be between 0 and 1")
+	}
+	if c.StoreFaultSaveRate < 0 || c.StoreFaultSaveRate > 1 {
+		problemf("store-fault-save-rate must be between 0 and 1")
+	}
+	if c.AlertFailureThreshold < 0 {
+		problemf("alert-failure-threshold can not be negative")
+	}
+	if c.CronScriptPath != "" {
+		if !filepath.IsAbs(c.CronScriptPath) {
+			problemf("cron-script-path %q is not an absolute path", c.CronScriptPath)
+		} else if info, err := os.Stat(c.CronScriptPath); err != nil || !info.IsDir() {
+			problemf("cron-script-path %q is not a directory", c.CronScriptPath)
+		}
+	}
+	return problems
+}
+
+// validateWhitelist returns the problems of the whitelist of the given type.
+func validateWhitelist(typ string, enabled bool, teams []string) []string {
+	var problems []string
+	if enabled && len(teams) == 0 {
+		problems = append(problems, fmt.Sprintf("teams-whitelist-%s is empty, no %s entry would be scheduled", typ, typ))
+	}
+	seen := map[string]bool{}
+	for _, t := range teams {
+		t = strings.TrimSpace(t)
+		switch {
+		case t == "":
+			problems = append(problems, fmt.Sprintf("teams-whitelist-%s contains an empty team ID", typ))
+		case seen[t]:
+			problems = append(problems, fmt.Sprintf("teams-whitelist-%s contains the team %s more than once", typ, t))
+		default:
+			if _, err := crontinuous.ParseWhitelistRule(t); err != nil {
+				problems = append(problems, fmt.Sprintf("teams-whitelist-%s: %v", typ, err))
+			}
+		}
+		seen[t] = true
+	}
+	return problems
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,312 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	S3CommandsCrontabFilename = "commandsCrontab.json"
+)
+
+const (
+	// maxCommandOutput is the maximum number of bytes of the output of a
+	// command kept in its execution.
+	maxCommandOutput = 64 * 1024
+	// maxCommandExecutions is the number of executions kept per command
+	// entry. The oldest ones are discarded first.
+	maxCommandExecutions = 20
+)
+
+var (
+	// ErrCommandsDisabled indicates the command entries are not enabled.
+	ErrCommandsDisabled = errors.New("ErrorCommandsDisabled")
+
+	// ErrScriptNotAllowed is returned when executing a command entry whose
+	// script is not an executable file inside the scripts directory.
+	ErrScriptNotAllowed = errors.New("ErrorScriptNotAllowed")
+)
+
+// CommandEntry defines the data stored by a command cron entry. When
+// executed, the script of the entry is run with the given arguments. The
+// scripts are referenced by their path relative to the scripts directory
+// configured with WithCommands, so only the scripts installed there by the
+// operators can be executed.
+type CommandEntry struct {
+	ID       string   `json:"id" yaml:"id"`
+	Script   string   `json:"script" yaml:"script"`
+	Args     []string `json:"args,omitempty" yaml:"args,omitempty"`
+	CronSpec string   `json:"cron_spec" yaml:"cron_spec"`
+	// TeamID is the team owning the command, if any. It is only used to
+	// consult the feature flags and exposed to the script.
+	TeamID string `json:"team_id,omitempty" yaml:"team_id,omitempty"`
+
+	EntrySettings `yaml:",inline"`
+}
+
+func (e CommandEntry) GetID() string {
+	return e.ID
+}
+func (e CommandEntry) GetTeamID() string {
+	return e.TeamID
+}
+func (e CommandEntry) GetType() CronType {
+	return CommandCronType
+}
+func (e CommandEntry) GetCronSpec() string {
+	return e.CronSpec
+}
+func (e CommandEntry) Validate() error {
+	if e.ID == "" || strings.Contains(e.ID, "/") {
+		return ErrMalformedEntry
+	}
+	if err := validateScriptPath(e.Script); err != nil {
+		return err
+	}
+	if err := e.EntrySettings.validate(); err != nil {
+		return err
+	}
+	return validateCronSpec(e.CronSpec)
+}
+
+// validateScriptPath returns ErrMalformedEntry unless the given path is a
+// clean relative path that does not leave the directory it is relative to.
+func validateScriptPath(script string) error {
+	if script == "" || strings.ContainsAny(script, "\\\x00") ||
+		filepath.IsAbs(script) || filepath.Clean(script) != script {
+		return ErrMalformedEntry
+	}
+	for _, part := range strings.Split(script, "/") {
+		if part == ".." || part == "." {
+			return ErrMalformedEntry
+		}
+	}
+	return nil
+}
+
+// resolveScript returns the absolute path of the given script of the given
+// scripts directory, once the symlinks are followed. It returns
+// ErrScriptNotAllowed if the script is outside the directory or it is not an
+// executable regular file.
+func resolveScript(dir, script string) (string, error) {
+	if err := validateScriptPath(script); err != nil {
+		return "", ErrScriptNotAllowed
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, script))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrScriptNotAllowed, err)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrScriptNotAllowed
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrScriptNotAllowed, err)
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		return "", ErrScriptNotAllowed
+	}
+	return path, nil
+}
+
+// WithCommands enables the command entries, which execute the scripts of the
+// given directory. The directory must only be writable by the operators of
+// crontinuous, as any executable file in it can be scheduled through the API.
+func WithCommands(store CommandCronStore, scriptsDir string) Option {
+	return func(c *Crontinuous) {
+		c.commandCronStore = store
+		c.scriptsDir = scriptsDir
+	}
+}
+
+// CommandExecution defines the result of an execution of a command entry.
+type CommandExecution struct {
+	// Execution is the ID of the execution, as logged.
+	Execution string    `json:"execution" yaml:"execution"`
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	Duration  Duration  `json:"duration" yaml:"duration"`
+	ExitCode  int       `json:"exit_code" yaml:"exit_code"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+	// Output holds the combined standard output and error of the script,
+	// up to 64KiB.
+	Output    string `json:"output" yaml:"output"`
+	Truncated bool   `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+}
+
+// commandExecutions holds the last executions of every command entry.
+type commandExecutions struct {
+	mux        sync.Mutex
+	executions map[string][]CommandExecution
+}
+
+func (ce *commandExecutions) record(entryID string, e CommandExecution) {
+	ce.mux.Lock()
+	defer ce.mux.Unlock()
+
+	if ce.executions == nil {
+		ce.executions = map[string][]CommandExecution{}
+	}
+	executions := append(ce.executions[entryID], e)
+	if len(executions) > maxCommandExecutions {
+		executions = executions[len(executions)-maxCommandExecutions:]
+	}
+	ce.executions[entryID] = executions
+}
+
+func (ce *commandExecutions) get(entryID string) []CommandExecution {
+	ce.mux.Lock()
+	defer ce.mux.Unlock()
+
+	executions := make([]CommandExecution, len(ce.executions[entryID]))
+	copy(executions, ce.executions[entryID])
+	return executions
+}
+
+// CommandExecutions returns, from the oldest to the newest, the last
+// executions of the command entry with the given ID since the start, up to
+// 20. It returns ErrCommandsDisabled if the command entries are not enabled
+// and ErrScheduleNotFound if the entry does not exist.
+func (c *Crontinuous) CommandExecutions(ID string) ([]CommandExecution, error) {
+	if !c.commandsEnabled() {
+		return nil, ErrCommandsDisabled
+	}
+	if _, err := c.GetEntryByID(CommandCronType, ID); err != nil {
+		return nil, err
+	}
+	return c.commandRuns.get(ID), nil
+}
+
+// limitedBuffer keeps the first bytes written to it, up to its limit, and
+// discards the rest.
+type limitedBuffer struct {
+	mux       sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if room := b.limit - b.buf.Len(); len(p) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+type commandJob struct {
+	entry      CommandEntry
+	scriptsDir string
+	runs       *commandExecutions
+	log        *logrus.Entry
+}
+
+func (j *commandJob) team() string {
+	return j.entry.TeamID
+}
+
+func (j *commandJob) cronType() CronType {
+	return CommandCronType
+}
+
+func (j *commandJob) entryID() string {
+	return j.entry.GetID()
+}
+
+func (j *commandJob) run(ctx context.Context) error {
+	log := j.log.WithFields(executionFields(ctx))
+	log.Info("Executing Command Job")
+
+	e := CommandExecution{StartedAt: time.Now(), ExitCode: -1}
+	if ex, ok := ExecutionFromContext(ctx); ok {
+		e.Execution = ex.ID
+	}
+	err := j.exec(ctx, &e)
+	e.Duration = Duration(time.Since(e.StartedAt))
+	if err != nil {
+		e.Error = err.Error()
+	}
+	j.runs.record(j.entry.ID, e)
+
+	log = log.WithFields(logrus.Fields{
+		"exit_code": e.ExitCode,
+		"duration":  time.Duration(e.Duration).String(),
+	})
+	if err != nil {
+		log.WithError(err).Error("Error Executing Command Job")
+		return err
+	}
+	log.Info("Executed Command Job")
+	return nil
+}
+
+// exec runs the script of the entry, storing in the given execution its exit
+// code and output.
+func (j *commandJob) exec(ctx context.Context, e *CommandExecution) error {
+	path, err := resolveScript(j.scriptsDir, j.entry.Script)
+	if err != nil {
+		return err
+	}
+	out := &limitedBuffer{limit: maxCommandOutput}
+	cmd := exec.CommandContext(ctx, path, j.entry.Args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdout, cmd.Stderr = out, out
+	cmd.Env = append(os.Environ(),
+		"CRONTINUOUS_ENTRY_ID="+j.entry.ID,
+		"CRONTINUOUS_EXECUTION_ID="+e.Execution,
+		"CRONTINUOUS_TEAM_ID="+j.entry.TeamID,
+	)
+	err = cmd.Run()
+	e.Output, e.Truncated = out.buf.String(), out.truncated
+	if cmd.ProcessState != nil {
+		e.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if err != nil && ctx.Err() != nil {
+		// The script was killed because the execution timed out or was
+		// cancelled.
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *Crontinuous) newCommandJob(e CommandEntry) entryJob {
+	return c.newEntryJob(&commandJob{
+		entry:      e,
+		scriptsDir: c.scriptsDir,
+		runs:       &c.commandRuns,
+		log:        logrus.New().WithFields(logrus.Fields{"job": e.ID}),
+	}, jobSettings{
+		timeout:      e.ExecutionTimeout,
+		pingURL:      e.PingURL,
+		alerting:     e.Alerting,
+		jitter:       e.Jitter,
+		windows:      e.Windows,
+		windowPolicy: e.WindowPolicy,
+	})
+}
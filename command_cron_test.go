@@ -0,0 +1,229 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type mockCommandCronStore struct {
+	entries map[string]CommandEntry
+}
+
+func (s *mockCommandCronStore) GetCommandEntries() (map[string]CommandEntry, error) {
+	return s.entries, nil
+}
+
+func (s *mockCommandCronStore) SaveCommandEntries(entries map[string]CommandEntry) error {
+	s.entries = entries
+	return nil
+}
+
+// writeScript creates in the given directory a script with the given content
+// and permissions.
+func writeScript(t *testing.T, dir, name, content string, perm os.FileMode) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+content), perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommandEntry_Validate(t *testing.T) {
+	tests := []struct {
+		script string
+		want   error
+	}{
+		{"task.sh", nil},
+		{"maintenance/task.sh", nil},
+		{"", ErrMalformedEntry},
+		{"/bin/sh", ErrMalformedEntry},
+		{"../task.sh", ErrMalformedEntry},
+		{"maintenance/../../task.sh", ErrMalformedEntry},
+		{"./task.sh", ErrMalformedEntry},
+		{"maintenance//task.sh", ErrMalformedEntry},
+		{"maintenance/", ErrMalformedEntry},
+		{`maintenance\task.sh`, ErrMalformedEntry},
+	}
+	for _, tt := range tests {
+		e := CommandEntry{ID: "c1", Script: tt.script, CronSpec: "0 4 * * *"}
+		if got := e.Validate(); got != tt.want {
+			t.Errorf("script %q: got %v, want %v", tt.script, got, tt.want)
+		}
+	}
+	if err := (CommandEntry{ID: "a/b", Script: "task.sh", CronSpec: "0 4 * * *"}).Validate(); err != ErrMalformedEntry {
+		t.Errorf("want ID with slashes rejected, got %v", err)
+	}
+}
+
+func TestResolveScript(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "scripts")
+	writeScript(t, dir, "task.sh", "", 0755)
+	writeScript(t, dir, "data.txt", "", 0644)
+	writeScript(t, root, "outside.sh", "", 0755)
+	if err := os.Symlink(filepath.Join(root, "outside.sh"), filepath.Join(dir, "escape.sh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "task.sh"), filepath.Join(dir, "alias.sh")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		script string
+		want   string
+		err    bool
+	}{
+		{script: "task.sh", want: "task.sh"},
+		{script: "alias.sh", want: "task.sh"},
+		{script: "data.txt", err: true},
+		{script: "escape.sh", err: true},
+		{script: "missing.sh", err: true},
+		{script: "../outside.sh", err: true},
+	}
+	for _, tt := range tests {
+		got, err := resolveScript(dir, tt.script)
+		if tt.err {
+			if !errors.Is(err, ErrScriptNotAllowed) {
+				t.Errorf("script %q: want ErrScriptNotAllowed, got %q, %v", tt.script, got, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("script %q: %v", tt.script, err)
+			continue
+		}
+		if filepath.Base(got) != tt.want || !filepath.IsAbs(got) {
+			t.Errorf("script %q: got %q, want the absolute path of %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+// waitCommandExecutions waits until the command entry with the given ID has
+// the given number of executions and returns them.
+func waitCommandExecutions(t *testing.T, c *Crontinuous, ID string, n int) []CommandExecution {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		executions, err := c.CommandExecutions(ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(executions) >= n {
+			return executions
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("want %d executions of %s, got %+v", n, ID, executions)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCrontinuous_Commands(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "echo.sh", `echo "$CRONTINUOUS_ENTRY_ID $*"; echo oops >&2`, 0755)
+	writeScript(t, dir, "fail.sh", "exit 3", 0755)
+	writeScript(t, dir, "sleep.sh", "exec sleep 10", 0755)
+	writeScript(t, dir, "noisy.sh", "yes | head -c 100000", 0755)
+
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	commands := &mockCommandCronStore{entries: map[string]CommandEntry{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithCommands(commands, dir))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	entries := []CommandEntry{
+		{ID: "echo", Script: "echo.sh", Args: []string{"a", "b"}, CronSpec: "0 0 1 1 *"},
+		{ID: "fail", Script: "fail.sh", CronSpec: "0 0 1 1 *"},
+		{ID: "sleep", Script: "sleep.sh", CronSpec: "0 0 1 1 *", EntrySettings: EntrySettings{ExecutionTimeout: Duration(100 * time.Millisecond)}},
+		{ID: "noisy", Script: "noisy.sh", CronSpec: "0 0 1 1 *"},
+		{ID: "missing", Script: "missing.sh", CronSpec: "0 0 1 1 *"},
+	}
+	for _, e := range entries {
+		if err := c.SaveEntry(CommandCronType, e); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.RunEntry(CommandCronType, e.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := commands.entries["echo"]; !ok {
+		t.Errorf("want the entries saved in the command store, got %v", commands.entries)
+	}
+
+	got := waitCommandExecutions(t, c, "echo", 1)[0]
+	if got.ExitCode != 0 || got.Error != "" || got.Execution == "" {
+		t.Errorf("unexpected execution %+v", got)
+	}
+	if !strings.Contains(got.Output, "echo a b\n") || !strings.Contains(got.Output, "oops\n") {
+		t.Errorf("want the output of the script captured, got %q", got.Output)
+	}
+
+	got = waitCommandExecutions(t, c, "fail", 1)[0]
+	if got.ExitCode != 3 || got.Error == "" {
+		t.Errorf("want the exit code of the failed script, got %+v", got)
+	}
+
+	got = waitCommandExecutions(t, c, "sleep", 1)[0]
+	if got.Error != context.DeadlineExceeded.Error() || time.Duration(got.Duration) > 5*time.Second {
+		t.Errorf("want the script killed on timeout, got %+v", got)
+	}
+
+	got = waitCommandExecutions(t, c, "noisy", 1)[0]
+	if len(got.Output) != maxCommandOutput || !got.Truncated {
+		t.Errorf("want the output truncated to %d bytes, got %d", maxCommandOutput, len(got.Output))
+	}
+
+	got = waitCommandExecutions(t, c, "missing", 1)[0]
+	if !strings.Contains(got.Error, ErrScriptNotAllowed.Error()) {
+		t.Errorf("want the missing script not allowed, got %+v", got)
+	}
+
+	if _, err := c.CommandExecutions("unknown"); err != ErrScheduleNotFound {
+		t.Errorf("want ErrScheduleNotFound, got %v", err)
+	}
+}
+
+func TestCrontinuous_CommandsDisabled(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	err := c.SaveEntry(CommandCronType, CommandEntry{ID: "c1", Script: "task.sh", CronSpec: "0 4 * * *"})
+	if err != ErrInvalidCronType {
+		t.Errorf("want ErrInvalidCronType, got %v", err)
+	}
+	if _, err := c.CommandExecutions("c1"); err != ErrCommandsDisabled {
+		t.Errorf("want ErrCommandsDisabled, got %v", err)
+	}
+}
+
+func TestCommandExecutions_Limit(t *testing.T) {
+	var ce commandExecutions
+	for i := 0; i < maxCommandExecutions+5; i++ {
+		ce.record("c1", CommandExecution{ExitCode: i})
+	}
+	got := ce.get("c1")
+	if len(got) != maxCommandExecutions || got[0].ExitCode != 5 {
+		t.Errorf("want the last %d executions kept, got %+v", maxCommandExecutions, got)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+)
+
+// WithMaxConcurrentJobs limits to the given number the jobs of the given
+// type running at the same time, so the bursts of one type don't starve the
+// execution of the others. The jobs activated while the limit is reached wait
+// for a running job of the same type to finish, without consuming their
+// execution timeout. Zero means no limit.
+func WithMaxConcurrentJobs(typ CronType, n int) Option {
+	return func(c *Crontinuous) {
+		if n <= 0 {
+			delete(c.jobSlots, typ)
+			return
+		}
+		if c.jobSlots == nil {
+			c.jobSlots = map[CronType]chan struct{}{}
+		}
+		c.jobSlots[typ] = make(chan struct{}, n)
+	}
+}
+
+// limitedJob wraps the job of an entry so it waits for a free slot of its
+// type before running.
+type limitedJob struct {
+	entryJob
+	slots   chan struct{}
+	waiting *runningJobs
+}
+
+func (j *limitedJob) run(ctx context.Context) error {
+	j.waiting.add(j.cronType(), 1)
+	select {
+	case j.slots <- struct{}{}:
+		j.waiting.add(j.cronType(), -1)
+	case <-ctx.Done():
+		j.waiting.add(j.cronType(), -1)
+		return &skipError{reason: SkipConcurrency, err: ctx.Err(), detail: "waiting for a free slot"}
+	}
+	defer func() { <-j.slots }()
+	return j.entryJob.run(ctx)
+}
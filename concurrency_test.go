@@ -0,0 +1,90 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_MaxConcurrentJobs(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			"p3": {ProgramID: "p3", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+	}
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		started <- programID
+		<-release
+		return nil
+	}}
+	reports := make(chan string, 1)
+	sender := &mockReportSender{sender: func(teamID string) error {
+		reports <- teamID
+		return nil
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, sender, store,
+		WithMaxConcurrentJobs(ScanCronType, 1), WithMaxConcurrentJobs(ReportCronType, 1))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	for _, id := range []string{"p1", "p2", "p3"} {
+		if err := c.RunEntry(ScanCronType, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan job not executed")
+	}
+	waitFor(t, func() bool { return c.Diagnostics().WaitingJobs[ScanCronType.String()] == 2 })
+	if n := c.Diagnostics().RunningJobs[ScanCronType.String()]; n != 1 {
+		t.Errorf("want 1 scan job running, got %d", n)
+	}
+
+	// The limit of the scans does not delay the reports.
+	if err := c.RunEntry(ReportCronType, "t1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reports:
+	case <-time.After(5 * time.Second):
+		t.Fatal("report job not executed while the scan jobs are waiting")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("waiting scan job not executed")
+		}
+	}
+	waitFor(t, func() bool { return c.Diagnostics().WaitingJobs[ScanCronType.String()] == 0 })
+}
+
+// waitFor waits until the given condition is true.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
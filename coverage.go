@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"sort"
+)
+
+// TeamLister defines the service needed to get the IDs of all the teams
+// when analysing the coverage of the entries.
+type TeamLister interface {
+	ListTeams() ([]string, error)
+}
+
+// ContextTeamLister is implemented by the team listers
+// that support aborting the listing.
+type ContextTeamLister interface {
+	ListTeamsContext(ctx context.Context) ([]string, error)
+}
+
+// ListTeams returns the teams using the given lister, aborting
+// the listing when the context is done if the lister supports it.
+func ListTeams(ctx context.Context, tl TeamLister) ([]string, error) {
+	if ctl, ok := tl.(ContextTeamLister); ok {
+		return ctl.ListTeamsContext(ctx)
+	}
+	return tl.ListTeams()
+}
+
+// CoverageReport describes the teams lacking scheduled scans or reports.
+type CoverageReport struct {
+	// Teams is the number of teams analysed.
+	Teams int `json:"teams" yaml:"teams"`
+	// WithoutScans are the teams with no scan or team scan entries.
+	WithoutScans []string `json:"without_scans" yaml:"without_scans"`
+	// WithoutReports are the teams with no report entry.
+	WithoutReports []string `json:"without_reports" yaml:"without_reports"`
+	// Uncovered are the teams with neither scans nor reports.
+	Uncovered []string `json:"uncovered" yaml:"uncovered"`
+}
+
+// Coverage returns which of the given teams have no scans or no reports
+// scheduled. Entries of teams not whitelisted are not considered, as they
+// are never executed.
+func (c *Crontinuous) Coverage(teams []string) (CoverageReport, error) {
+	scanned := map[string]bool{}
+	reported := map[string]bool{}
+	for _, typ := range c.cronTypes() {
+		entries, err := c.GetEntries(typ)
+		if err != nil {
+			return CoverageReport{}, err
+		}
+		if typ == CommandCronType {
+			continue
+		}
+		covered := scanned
+		if typ == ReportCronType {
+			covered = reported
+		}
+		for _, e := range entries {
+			if c.isTeamWhitelisted(typ, e.GetTeamID()) {
+				covered[e.GetTeamID()] = true
+			}
+		}
+	}
+
+	report := CoverageReport{
+		WithoutScans:   []string{},
+		WithoutReports: []string{},
+		Uncovered:      []string{},
+	}
+	seen := map[string]bool{}
+	for _, t := range teams {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		report.Teams++
+		if !scanned[t] {
+			report.WithoutScans = append(report.WithoutScans, t)
+		}
+		if !reported[t] {
+			report.WithoutReports = append(report.WithoutReports, t)
+		}
+		if !scanned[t] && !reported[t] {
+			report.Uncovered = append(report.Uncovered, t)
+		}
+	}
+	sort.Strings(report.WithoutScans)
+	sort.Strings(report.WithoutReports)
+	sort.Strings(report.Uncovered)
+	return report, nil
+}
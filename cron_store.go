@@ -6,9 +6,16 @@ package crontinuous
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -16,8 +23,22 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
 
+// S3HistoryKeyTemplate is the template of the keys storing the revisions
+// of the entries, formatted with the type and the ID of the entry.
+const S3HistoryKeyTemplate = s3HistoryPrefix + "%s/%s.json"
+
+// s3HistoryPrefix is the prefix of the keys storing the revisions.
+const s3HistoryPrefix = "history/"
+
 var (
 	errEntriesFileNotFound = errors.New("EntriesFileNotFound")
+	// errAmbiguousCrontab indicates a crontab is stored both unsharded and
+	// sharded, or sharded in different numbers of shards, so it is not
+	// known which of them holds the current entries.
+	errAmbiguousCrontab = errors.New("AmbiguousCrontab")
+
+	// gzipMagic is the header of the gzip compressed content.
+	gzipMagic = []byte{0x1f, 0x8b}
 )
 
 type ScanCronStore interface {
@@ -30,24 +51,181 @@ type ReportCronStore interface {
 	SaveReportEntries(entries map[string]ReportEntry) error
 }
 
+type TeamScanCronStore interface {
+	GetTeamScanEntries() (map[string]TeamScanEntry, error)
+	SaveTeamScanEntries(entries map[string]TeamScanEntry) error
+}
+
+type CommandCronStore interface {
+	GetCommandEntries() (map[string]CommandEntry, error)
+	SaveCommandEntries(entries map[string]CommandEntry) error
+}
+
 type S3CronStore struct {
-	bucket        string
-	scanCronKey   string
-	reportCronKey string
-	s3Client      s3iface.S3API
+	bucket          string
+	scanCronKey     string
+	reportCronKey   string
+	teamScanCronKey string
+	commandCronKey  string
+	s3Client        s3iface.S3API
+	shards          int
+	compress        bool
+	sorted          bool
+
+	sizesMux sync.Mutex
+	sizes    map[string]int64
+
+	// written holds the hash of the content last read from or written
+	// to each shard, so unchanged shards are not written again.
+	written    map[string]uint64
+	writtenMux sync.Mutex
+
+	// locks serialize the reads and writes of the shards of each crontab,
+	// so the written hashes match the content of the shards.
+	locks    map[string]*sync.Mutex
+	locksMux sync.Mutex
+
+	// cleaned holds the crontabs with no objects left of other layouts
+	// than the configured one.
+	cleaned    map[string]bool
+	cleanedMux sync.Mutex
+}
+
+// S3StoreOption configures optional behaviour of the S3CronStore.
+type S3StoreOption func(*S3CronStore)
+
+// WithS3Shards makes the store split each crontab in the given number of
+// objects, assigning the entries by the hash of their ID. The shards are read
+// in parallel and only the shards with changes are written, which reduces
+// the latency of the saves of large crontabs. A crontab stored unsharded or
+// in a different number of shards is read as it is stored, and migrated on
+// the next save, which removes the objects of the previous layout. A crontab
+// stored in more than one layout, as written by instances configured with
+// different numbers of shards, is read from the configured layout, and the
+// other layouts are removed on the next save, discarding the changes written
+// only to them, so every instance sharing the bucket must change the number
+// at once. Reading a crontab stored in more than one layout fails if none of
+// them is the configured one, as it is not known which of them is current. A
+// number lower than 2 disables the sharding.
+func WithS3Shards(n int) S3StoreOption {
+	return func(s *S3CronStore) {
+		s.shards = n
+	}
 }
 
-func NewS3CronStore(bucket, scanCronKey, reportCronKey string, s3Client s3iface.S3API) *S3CronStore {
-	return &S3CronStore{
-		bucket:        bucket,
-		scanCronKey:   scanCronKey,
-		reportCronKey: reportCronKey,
-		s3Client:      s3Client,
+// WithS3Compression makes the store compress with gzip the objects it
+// writes. The objects are decompressed on read based on their content, so
+// the objects written before enabling the compression are still read.
+func WithS3Compression() S3StoreOption {
+	return func(s *S3CronStore) {
+		s.compress = true
 	}
 }
 
+// WithS3SortedEntries makes the store write each crontab as an indented
+// array of entries sorted by ID, instead of an object keyed by ID, so the
+// objects produce readable diffs when versioned or backed up. Both formats
+// are read regardless of the option, so the crontabs are migrated on the
+// next save after enabling or disabling it.
+func WithS3SortedEntries() S3StoreOption {
+	return func(s *S3CronStore) {
+		s.sorted = true
+	}
+}
+
+func NewS3CronStore(bucket, scanCronKey, reportCronKey string, s3Client s3iface.S3API, opts ...S3StoreOption) *S3CronStore {
+	s := &S3CronStore{
+		bucket:          bucket,
+		scanCronKey:     scanCronKey,
+		reportCronKey:   reportCronKey,
+		teamScanCronKey: S3TeamScansCrontabFilename,
+		commandCronKey:  S3CommandsCrontabFilename,
+		s3Client:        s3Client,
+		sizes:           map[string]int64{},
+		written:         map[string]uint64{},
+		locks:           map[string]*sync.Mutex{},
+		cleaned:         map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
 func (s *S3CronStore) GetScanEntries() (map[string]ScanEntry, error) {
-	entriesData, err := s.getEntriesData(s.scanCronKey)
+	return readCrontab[ScanEntry](s, s.scanCronKey)
+}
+
+func (s *S3CronStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	return writeCrontab(s, s.scanCronKey, entries)
+}
+
+func (s *S3CronStore) GetReportEntries() (map[string]ReportEntry, error) {
+	return readCrontab[ReportEntry](s, s.reportCronKey)
+}
+
+func (s *S3CronStore) SaveReportEntries(entries map[string]ReportEntry) error {
+	return writeCrontab(s, s.reportCronKey, entries)
+}
+
+func (s *S3CronStore) GetTeamScanEntries() (map[string]TeamScanEntry, error) {
+	return readCrontab[TeamScanEntry](s, s.teamScanCronKey)
+}
+
+func (s *S3CronStore) SaveTeamScanEntries(entries map[string]TeamScanEntry) error {
+	return writeCrontab(s, s.teamScanCronKey, entries)
+}
+
+func (s *S3CronStore) GetCommandEntries() (map[string]CommandEntry, error) {
+	return readCrontab[CommandEntry](s, s.commandCronKey)
+}
+
+func (s *S3CronStore) SaveCommandEntries(entries map[string]CommandEntry) error {
+	return writeCrontab(s, s.commandCronKey, entries)
+}
+
+func readCrontab[T any](s *S3CronStore, key string) (map[string]T, error) {
+	l := s.crontabLock(key)
+	l.Lock()
+	defer l.Unlock()
+
+	layouts, err := s.storedLayouts(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(layouts) > 1 {
+		current := s.layout()
+		keys, ok := layouts[current]
+		if !ok {
+			var found []string
+			for _, keys := range layouts {
+				found = append(found, keys...)
+			}
+			sort.Strings(found)
+			return nil, fmt.Errorf("%w: %s stored in %s", errAmbiguousCrontab, key, strings.Join(found, ", "))
+		}
+		// Another store configured with a different number of shards, as
+		// in a rolling deploy changing it, wrote the crontab after this one
+		// removed the other layouts. The configured layout is read, and the
+		// other layouts are looked up again and removed on the next write.
+		s.cleanedMux.Lock()
+		delete(s.cleaned, key)
+		s.cleanedMux.Unlock()
+		layouts = map[int][]string{current: keys}
+	}
+	if _, ok := layouts[s.layout()]; !ok {
+		// The shards of the configured layout, if any, were removed since
+		// they were last read or written, so they are written again on the
+		// next save even if their entries are unchanged.
+		s.forgetShards(key)
+	}
+	for shards := range layouts {
+		if shards > 0 {
+			return readShards[T](s, key, shards)
+		}
+	}
+
+	entriesData, err := s.getEntriesData(key)
 	if err != nil {
 		// If entries file is not found
 		// return void entries map.
@@ -56,45 +234,486 @@ func (s *S3CronStore) GetScanEntries() (map[string]ScanEntry, error) {
 		// automatically in remote store when a new entry
 		// is added via API.
 		if err == errEntriesFileNotFound {
-			return map[string]ScanEntry{}, nil
+			return map[string]T{}, nil
 		}
 		return nil, err
 	}
 
-	var scanEntries map[string]ScanEntry
-	err = json.Unmarshal(entriesData, &scanEntries)
-	return scanEntries, err
+	return decodeCrontab[T](entriesData)
 }
 
-func (s *S3CronStore) SaveScanEntries(entries map[string]ScanEntry) error {
-	return s.saveEntries(s.scanCronKey, entries)
+// encodeCrontab returns the content of a crontab with the given entries, in
+// the format configured in the store.
+func encodeCrontab[T any](s *S3CronStore, entries map[string]T) ([]byte, error) {
+	if !s.sorted {
+		return json.Marshal(entries)
+	}
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	sorted := make([]T, len(ids))
+	for i, id := range ids {
+		sorted[i] = entries[id]
+	}
+	return json.MarshalIndent(sorted, "", "  ")
 }
 
-func (s *S3CronStore) GetReportEntries() (map[string]ReportEntry, error) {
-	entriesData, err := s.getEntriesData(s.reportCronKey)
+// decodeCrontab returns the entries of the given crontab content, written
+// either as an object keyed by ID or as an array of entries.
+func decodeCrontab[T any](content []byte) (map[string]T, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(content), []byte("[")) {
+		var entries map[string]T
+		err := json.Unmarshal(content, &entries)
+		return entries, err
+	}
+	var sorted []T
+	if err := json.Unmarshal(content, &sorted); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]T, len(sorted))
+	for _, e := range sorted {
+		ce, ok := any(e).(interface{ GetID() string })
+		if !ok {
+			return nil, fmt.Errorf("entries of type %T have no ID", e)
+		}
+		entries[ce.GetID()] = e
+	}
+	return entries, nil
+}
+
+// readShards reads in parallel the given number of shards of the crontab
+// with the given key.
+func readShards[T any](s *S3CronStore, key string, n int) (map[string]T, error) {
+	shards := make([]map[string]T, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := s.getEntriesData(shardKey(key, i, n))
+			if err != nil {
+				if err == errEntriesFileNotFound {
+					s.forget(shardKey(key, i, n))
+				}
+				errs[i] = err
+				return
+			}
+			s.setWritten(shardKey(key, i, n), data)
+			shards[i], errs[i] = decodeCrontab[T](data)
+		}(i)
+	}
+	wg.Wait()
+
+	entries := map[string]T{}
+	for i := range shards {
+		if errs[i] == errEntriesFileNotFound {
+			continue
+		}
+		if errs[i] != nil {
+			return nil, fmt.Errorf("reading shard %d of %s: %w", i, key, errs[i])
+		}
+		for id, e := range shards[i] {
+			entries[id] = e
+		}
+	}
+	return entries, nil
+}
+
+// storedLayouts returns the keys of the objects storing the crontab with
+// the given key, by the number of shards they belong to, 0 for the
+// unsharded crontab.
+func (s *S3CronStore) storedLayouts(key string) (map[int][]string, error) {
+	base := strings.TrimSuffix(key, path.Ext(key))
+	layouts := map[int][]string{}
+	err := s.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(base),
+	}, func(out *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range out.Contents {
+			k := aws.StringValue(o.Key)
+			if k == key {
+				layouts[0] = append(layouts[0], k)
+				continue
+			}
+			if shards, ok := parseShardKey(key, k); ok {
+				layouts[shards] = append(layouts[shards], k)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing the objects of %s: %w", key, err)
+	}
+	return layouts, nil
+}
+
+// layout returns the number of shards the crontabs are written in, 0 if they
+// are not sharded.
+func (s *S3CronStore) layout() int {
+	if s.shards > 1 {
+		return s.shards
+	}
+	return 0
+}
+
+// removeStaleLayouts removes the objects storing the crontab with the given
+// key in other layouts than the configured one, so they are never read
+// instead of the current entries. The objects are looked up only until they
+// are removed once, or until a read finds other layouts again. It must be
+// called holding the lock of the crontab.
+func (s *S3CronStore) removeStaleLayouts(key string) error {
+	s.cleanedMux.Lock()
+	cleaned := s.cleaned[key]
+	s.cleanedMux.Unlock()
+	if cleaned {
+		return nil
+	}
+
+	layouts, err := s.storedLayouts(key)
+	if err != nil {
+		return err
+	}
+	for shards, keys := range layouts {
+		if shards == s.layout() {
+			continue
+		}
+		for _, k := range keys {
+			_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+				Bucket: aws.String(s.bucket),
+				Key:    aws.String(k),
+			})
+			if err != nil {
+				return fmt.Errorf("removing %s: %w", k, err)
+			}
+			s.forget(k)
+		}
+	}
+
+	s.cleanedMux.Lock()
+	s.cleaned[key] = true
+	s.cleanedMux.Unlock()
+	return nil
+}
+
+// writeCrontab saves the given entries in the crontab with the given key
+// and then removes the objects of the other layouts, if any.
+func writeCrontab[T any](s *S3CronStore, key string, entries map[string]T) error {
+	l := s.crontabLock(key)
+	l.Lock()
+	defer l.Unlock()
+
+	if s.shards <= 1 {
+		content, err := encodeCrontab(s, entries)
+		if err != nil {
+			return err
+		}
+		if err := s.saveEntries(key, content); err != nil {
+			return err
+		}
+		return s.removeStaleLayouts(key)
+	}
+
+	shards := make([]map[string]T, s.shards)
+	for i := range shards {
+		shards[i] = map[string]T{}
+	}
+	for id, e := range entries {
+		shards[shardOf(id, s.shards)][id] = e
+	}
+
+	errs := make([]error, s.shards)
+	var wg sync.WaitGroup
+	for i := range shards {
+		content, err := encodeCrontab(s, shards[i])
+		if err != nil {
+			return err
+		}
+		k := shardKey(key, i, s.shards)
+		if !s.changed(k, content) {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var size int
+			if size, errs[i] = s.putContent(k, content); errs[i] == nil {
+				s.setSize(k, size)
+				s.setWritten(k, content)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("writing shard %d of %s: %w", i, key, err)
+		}
+	}
+	return s.removeStaleLayouts(key)
+}
+
+// crontabLock returns the lock of the crontab with the given key.
+func (s *S3CronStore) crontabLock(key string) *sync.Mutex {
+	s.locksMux.Lock()
+	defer s.locksMux.Unlock()
+
+	l, ok := s.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[key] = l
+	}
+	return l
+}
+
+// shardKey returns the key of the given shard of the crontab with the given
+// key, e.g. scans-003-of-016.json for the shard 3 of the crontab scans.json.
+func shardKey(key string, shard, shards int) string {
+	ext := path.Ext(key)
+	return fmt.Sprintf("%s-%03d-of-%03d%s", strings.TrimSuffix(key, ext), shard, shards, ext)
+}
+
+// parseShardKey returns the number of shards of the given shard key of the
+// crontab with the given key, if it is a key generated by shardKey.
+func parseShardKey(key, k string) (int, bool) {
+	ext := path.Ext(key)
+	rest := strings.TrimPrefix(k, strings.TrimSuffix(key, ext)+"-")
+	if rest == k || !strings.HasSuffix(rest, ext) {
+		return 0, false
+	}
+	var shard, shards int
+	n, err := fmt.Sscanf(strings.TrimSuffix(rest, ext), "%d-of-%d", &shard, &shards)
+	if err != nil || n != 2 || shards < 2 || shard < 0 || shard >= shards || shardKey(key, shard, shards) != k {
+		return 0, false
+	}
+	return shards, true
+}
+
+// shardOf returns the shard the entry with the given ID is stored in.
+func shardOf(ID string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(ID)) // nolint
+	return int(h.Sum32() % uint32(shards))
+}
+
+// changed returns true if the given content differs from
+// the one last read from or written to the key.
+func (s *S3CronStore) changed(key string, content []byte) bool {
+	s.writtenMux.Lock()
+	defer s.writtenMux.Unlock()
+
+	last, ok := s.written[key]
+	return !ok || last != contentHash(content)
+}
+
+func (s *S3CronStore) setWritten(key string, content []byte) {
+	s.writtenMux.Lock()
+	defer s.writtenMux.Unlock()
+
+	s.written[key] = contentHash(content)
+}
+
+// forget discards the hash and the size recorded for the given key, once
+// it is removed.
+func (s *S3CronStore) forget(key string) {
+	s.writtenMux.Lock()
+	delete(s.written, key)
+	s.writtenMux.Unlock()
+
+	s.sizesMux.Lock()
+	delete(s.sizes, key)
+	s.sizesMux.Unlock()
+}
+
+// forgetShards discards the hashes and the sizes recorded for the shards of
+// the configured layout of the crontab with the given key.
+func (s *S3CronStore) forgetShards(key string) {
+	for i := 0; i < s.layout(); i++ {
+		s.forget(shardKey(key, i, s.shards))
+	}
+}
+
+func contentHash(content []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(content) // nolint
+	return h.Sum64()
+}
+
+// GetHistory returns the revisions of the given entry stored in the bucket.
+func (s *S3CronStore) GetHistory(typ CronType, ID string) ([]Revision, error) {
+	data, _, err := s.getObject(historyKey(typ, ID))
 	if err != nil {
-		// If entries file is not found
-		// return void entries map.
-		//
-		// This allows to auto create the entries file
-		// automatically in remote store when a new entry
-		// is added via API.
 		if err == errEntriesFileNotFound {
-			return map[string]ReportEntry{}, nil
+			return []Revision{}, nil
 		}
 		return nil, err
 	}
 
-	var reportEntries map[string]ReportEntry
-	err = json.Unmarshal(entriesData, &reportEntries)
-	return reportEntries, err
+	var revisions []Revision
+	err = json.Unmarshal(data, &revisions)
+	return revisions, err
 }
 
-func (s *S3CronStore) SaveReportEntries(entries map[string]ReportEntry) error {
-	return s.saveEntries(s.reportCronKey, entries)
+// SaveHistory stores in the bucket the revisions of the given entry.
+func (s *S3CronStore) SaveHistory(typ CronType, ID string, revisions []Revision) error {
+	_, err := s.putObject(historyKey(typ, ID), revisions)
+	return err
+}
+
+// GetTemplates returns the templates stored in the bucket.
+func (s *S3CronStore) GetTemplates() (map[string]Template, error) {
+	data, _, err := s.getObject(S3TemplatesFilename)
+	if err != nil {
+		if err == errEntriesFileNotFound {
+			return map[string]Template{}, nil
+		}
+		return nil, err
+	}
+
+	templates := map[string]Template{}
+	err = json.Unmarshal(data, &templates)
+	return templates, err
+}
+
+// SaveTemplates stores in the bucket the given templates.
+func (s *S3CronStore) SaveTemplates(templates map[string]Template) error {
+	_, err := s.putObject(S3TemplatesFilename, templates)
+	return err
+}
+
+// GetPauseWindows returns the pause windows stored in the bucket.
+func (s *S3CronStore) GetPauseWindows() (map[string]PauseWindow, error) {
+	data, _, err := s.getObject(S3PauseWindowsFilename)
+	if err != nil {
+		if err == errEntriesFileNotFound {
+			return map[string]PauseWindow{}, nil
+		}
+		return nil, err
+	}
+
+	windows := map[string]PauseWindow{}
+	err = json.Unmarshal(data, &windows)
+	return windows, err
+}
+
+// SavePauseWindows stores in the bucket the given pause windows.
+func (s *S3CronStore) SavePauseWindows(windows map[string]PauseWindow) error {
+	_, err := s.putObject(S3PauseWindowsFilename, windows)
+	return err
+}
+
+// GetTeamDefaults returns the default settings of the teams stored in the
+// bucket.
+func (s *S3CronStore) GetTeamDefaults() (map[string]TeamDefaults, error) {
+	data, _, err := s.getObject(S3TeamDefaultsFilename)
+	if err != nil {
+		if err == errEntriesFileNotFound {
+			return map[string]TeamDefaults{}, nil
+		}
+		return nil, err
+	}
+
+	defaults := map[string]TeamDefaults{}
+	err = json.Unmarshal(data, &defaults)
+	return defaults, err
+}
+
+// SaveTeamDefaults stores in the bucket the given default settings of the
+// teams.
+func (s *S3CronStore) SaveTeamDefaults(defaults map[string]TeamDefaults) error {
+	_, err := s.putObject(S3TeamDefaultsFilename, defaults)
+	return err
+}
+
+// GetSuppressions returns the suppressions stored in the bucket.
+func (s *S3CronStore) GetSuppressions() (map[string]Suppression, error) {
+	data, _, err := s.getObject(S3SuppressionsFilename)
+	if err != nil {
+		if err == errEntriesFileNotFound {
+			return map[string]Suppression{}, nil
+		}
+		return nil, err
+	}
+
+	suppressions := map[string]Suppression{}
+	err = json.Unmarshal(data, &suppressions)
+	return suppressions, err
+}
+
+// SaveSuppressions stores in the bucket the given suppressions.
+func (s *S3CronStore) SaveSuppressions(suppressions map[string]Suppression) error {
+	_, err := s.putObject(S3SuppressionsFilename, suppressions)
+	return err
+}
+
+func historyKey(typ CronType, ID string) string {
+	return fmt.Sprintf(S3HistoryKeyTemplate, typ, ID)
+}
+
+// ListHistories returns the histories stored in the bucket.
+func (s *S3CronStore) ListHistories() ([]HistoryRef, error) {
+	var refs []HistoryRef
+	err := s.s3Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s3HistoryPrefix),
+	}, func(out *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range out.Contents {
+			if ref, ok := parseHistoryKey(aws.StringValue(o.Key)); ok {
+				refs = append(refs, ref)
+			}
+		}
+		return true
+	})
+	return refs, err
+}
+
+// DeleteHistory removes from the bucket the revisions of the given entry.
+func (s *S3CronStore) DeleteHistory(typ CronType, ID string) error {
+	_, err := s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(historyKey(typ, ID)),
+	})
+	return err
+}
+
+// parseHistoryKey returns the history stored in the given key, if it is a
+// key generated by historyKey.
+func parseHistoryKey(key string) (HistoryRef, bool) {
+	var ref HistoryRef
+	rest := strings.TrimPrefix(key, s3HistoryPrefix)
+	name, file, ok := strings.Cut(rest, "/")
+	if !ok || rest == key || !strings.HasSuffix(file, ".json") {
+		return ref, false
+	}
+	if err := ref.Type.UnmarshalText([]byte(name)); err != nil {
+		return ref, false
+	}
+	ref.ID = strings.TrimSuffix(file, ".json")
+	return ref, ref.ID != ""
 }
 
 func (s *S3CronStore) getEntriesData(key string) ([]byte, error) {
+	content, size, err := s.getObject(key)
+	if err != nil {
+		return nil, err
+	}
+	s.setSize(key, size)
+	return content, nil
+}
+
+func (s *S3CronStore) saveEntries(key string, content []byte) error {
+	size, err := s.putContent(key, content)
+	if err != nil {
+		return err
+	}
+	s.setSize(key, size)
+	return nil
+}
+
+// getObject returns the content of the object with the given key,
+// decompressing it if needed, and the size of the stored object.
+func (s *S3CronStore) getObject(key string) ([]byte, int, error) {
 	output, err := s.s3Client.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
@@ -103,27 +722,86 @@ func (s *S3CronStore) getEntriesData(key string) ([]byte, error) {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case s3.ErrCodeNoSuchKey:
-				return nil, errEntriesFileNotFound
+				return nil, 0, errEntriesFileNotFound
 			default:
-				return nil, err
+				return nil, 0, err
 			}
 		}
-		return nil, err
+		return nil, 0, err
 	}
 
-	return ioutil.ReadAll(output.Body)
+	stored, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The objects are decompressed based on their content, so the
+	// compression can be enabled and disabled at any time.
+	if !bytes.HasPrefix(stored, gzipMagic) {
+		return stored, len(stored), nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(stored))
+	if err != nil {
+		return nil, 0, fmt.Errorf("decompressing %s: %w", key, err)
+	}
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decompressing %s: %w", key, err)
+	}
+	return content, len(stored), nil
 }
 
-func (s *S3CronStore) saveEntries(key string, entries interface{}) error {
-	content, err := json.Marshal(entries)
+// putObject stores the given value encoded as JSON
+// and returns the size of the object written.
+func (s *S3CronStore) putObject(key string, v interface{}) (int, error) {
+	content, err := json.Marshal(v)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return s.putContent(key, content)
+}
+
+// putContent stores the given content, compressed if the compression is
+// enabled, and returns the size of the object written.
+func (s *S3CronStore) putContent(key string, content []byte) (int, error) {
 	params := &s3.PutObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(key),
-		Body:   bytes.NewReader(content),
 	}
-	_, err = s.s3Client.PutObject(params)
-	return err
+	if s.compress {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(content); err != nil {
+			return 0, err
+		}
+		if err := w.Close(); err != nil {
+			return 0, err
+		}
+		content = buf.Bytes()
+		params.ContentType = aws.String("application/gzip")
+	}
+	params.Body = bytes.NewReader(content)
+	if _, err := s.s3Client.PutObject(params); err != nil {
+		return 0, err
+	}
+	return len(content), nil
+}
+
+// StoredSizes returns the size in bytes of the crontabs
+// last read from or written to the bucket.
+func (s *S3CronStore) StoredSizes() map[string]int64 {
+	s.sizesMux.Lock()
+	defer s.sizesMux.Unlock()
+
+	sizes := make(map[string]int64, len(s.sizes))
+	for k, v := range s.sizes {
+		sizes[k] = v
+	}
+	return sizes
+}
+
+func (s *S3CronStore) setSize(key string, size int) {
+	s.sizesMux.Lock()
+	defer s.sizesMux.Unlock()
+
+	s.sizes[key] = int64(size)
 }
@@ -0,0 +1,408 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+// mockS3 implements the S3 operations used by the S3CronStore
+// keeping the objects in memory.
+type mockS3 struct {
+	s3iface.S3API
+	mux     sync.Mutex
+	objects map[string][]byte
+	puts    []string
+}
+
+func newMockS3() *mockS3 {
+	return &mockS3{objects: map[string][]byte{}}
+}
+
+func (m *mockS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	content, ok := m.objects[aws.StringValue(in.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(content))}, nil
+}
+
+func (m *mockS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	content, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.objects[aws.StringValue(in.Key)] = content
+	m.puts = append(m.puts, aws.StringValue(in.Key))
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *mockS3) ListObjectsV2Pages(in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	m.mux.Lock()
+	out := &s3.ListObjectsV2Output{}
+	for k := range m.objects {
+		if strings.HasPrefix(k, aws.StringValue(in.Prefix)) {
+			out.Contents = append(out.Contents, &s3.Object{Key: aws.String(k)})
+		}
+	}
+	m.mux.Unlock()
+
+	fn(out, true)
+	return nil
+}
+
+func (m *mockS3) DeleteObject(in *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.objects, aws.StringValue(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// keys returns the keys of the objects stored sorted.
+func (m *mockS3) keys() []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var keys []string
+	for k := range m.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (m *mockS3) takePuts() []string {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	puts := m.puts
+	m.puts = nil
+	sort.Strings(puts)
+	return puts
+}
+
+func TestS3CronStore_Shards(t *testing.T) {
+	client := newMockS3()
+
+	// Entries stored before enabling the sharding.
+	legacy := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client)
+	entries := map[string]ScanEntry{}
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5", "p6"} {
+		entries[id] = ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 * * * *"}
+	}
+	if err := legacy.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	client.takePuts()
+
+	store := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(4))
+	got, err := store.GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Fatalf("unsharded entries not read, diff: %s", diff)
+	}
+
+	// The first save migrates the entries to the shards and removes the
+	// unsharded crontab.
+	if err := store.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	if puts := client.takePuts(); len(puts) != 4 {
+		t.Fatalf("shards written got %v, want 4 shards", puts)
+	}
+	for _, k := range client.keys() {
+		if k == S3ScansCrontabFilename {
+			t.Fatalf("unsharded crontab not removed after the migration")
+		}
+	}
+
+	// Only the shard of the changed entry is written.
+	e := entries["p3"]
+	e.CronSpec = "30 * * * *"
+	entries["p3"] = e
+	if err := store.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{shardKey(S3ScansCrontabFilename, shardOf("p3", 4), 4)}
+	if diff := cmp.Diff(want, client.takePuts()); diff != "" {
+		t.Errorf("shards written diff: %s", diff)
+	}
+
+	fresh := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(4))
+	got, err = fresh.GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("sharded entries diff: %s", diff)
+	}
+}
+
+func TestS3CronStore_ShardsChanged(t *testing.T) {
+	client := newMockS3()
+	entries := map[string]ScanEntry{}
+	for _, id := range []string{"p1", "p2", "p3", "p4", "p5", "p6"} {
+		entries[id] = ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 * * * *"}
+	}
+	if err := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(4)).SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		shards int
+		want   []string
+	}{
+		{"MoreShards", 8, []string{shardKey(S3ScansCrontabFilename, 0, 8)}},
+		{"FewerShards", 2, []string{shardKey(S3ScansCrontabFilename, 0, 2), shardKey(S3ScansCrontabFilename, 1, 2)}},
+		{"Unsharded", 0, []string{S3ScansCrontabFilename}},
+		{"ShardedAgain", 4, []string{shardKey(S3ScansCrontabFilename, 0, 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(tt.shards))
+			got, err := store.GetScanEntries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(entries, got); diff != "" {
+				t.Fatalf("entries of the previous layout not read, diff: %s", diff)
+			}
+			if err := store.SaveScanEntries(got); err != nil {
+				t.Fatal(err)
+			}
+			// Only the objects of the new layout are left.
+			keys := client.keys()
+			for _, k := range keys {
+				if shards, ok := parseShardKey(S3ScansCrontabFilename, k); ok && shards != tt.shards {
+					t.Fatalf("stale shard %s not removed, got %v", k, keys)
+				}
+				if k == S3ScansCrontabFilename && tt.shards > 1 {
+					t.Fatalf("stale unsharded crontab not removed, got %v", keys)
+				}
+			}
+			if keys[0] != tt.want[0] {
+				t.Errorf("want the objects %v, got %v", tt.want, keys)
+			}
+		})
+	}
+}
+
+func TestS3CronStore_AmbiguousCrontab(t *testing.T) {
+	client := newMockS3()
+	entries := map[string]ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"}}
+	if err := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(4)).SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	// An unsharded crontab written behind the store.
+	client.objects[S3ScansCrontabFilename] = []byte(`{}`)
+
+	tests := []struct {
+		shards  int
+		want    map[string]ScanEntry
+		wantErr error
+	}{
+		{shards: 0, want: map[string]ScanEntry{}},
+		{shards: 4, want: entries},
+		{shards: 8, wantErr: errAmbiguousCrontab},
+	}
+	for _, tt := range tests {
+		got, err := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(tt.shards)).GetScanEntries()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%d shards: want error %v, got %v", tt.shards, tt.wantErr, err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%d shards: entries diff: %s", tt.shards, diff)
+		}
+	}
+}
+
+func TestS3CronStore_MixedShards(t *testing.T) {
+	client := newMockS3()
+	old := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(4))
+	entries := map[string]ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"}}
+	if err := old.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	// A store with a different number of shards, as in a rolling deploy,
+	// migrates the crontab. Then the old store reads it and writes its
+	// layout again.
+	if err := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(8)).SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := old.GetScanEntries(); err != nil {
+		t.Fatal(err)
+	}
+	entries["p2"] = ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 * * * *"}
+	if err := old.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.keys()) != 4+8 {
+		t.Fatalf("want the objects of both layouts, got %v", client.keys())
+	}
+
+	got, err := old.GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Fatalf("entries of the configured layout not read, diff: %s", diff)
+	}
+	if err := old.SaveScanEntries(got); err != nil {
+		t.Fatal(err)
+	}
+	keys := client.keys()
+	for _, k := range keys {
+		if shards, ok := parseShardKey(S3ScansCrontabFilename, k); !ok || shards != 4 {
+			t.Fatalf("stale object %s not removed, got %v", k, keys)
+		}
+	}
+	got, err = NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(8)).GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("entries diff: %s", diff)
+	}
+}
+
+func TestParseShardKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		shards int
+		ok     bool
+	}{
+		{"crontab-003-of-016.json", 16, true},
+		{"crontab-000-of-002.json", 2, true},
+		{"crontab-016-of-016.json", 0, false},
+		{"crontab-3-of-16.json", 0, false},
+		{"crontab.json", 0, false},
+		{"crontab-backup.json", 0, false},
+		{"reportsCrontab-000-of-002.json", 0, false},
+	}
+	for _, tt := range tests {
+		shards, ok := parseShardKey(S3ScansCrontabFilename, tt.key)
+		if shards != tt.shards || ok != tt.ok {
+			t.Errorf("%s: want %d, %v, got %d, %v", tt.key, tt.shards, tt.ok, shards, ok)
+		}
+	}
+}
+
+func TestS3CronStore_Compression(t *testing.T) {
+	client := newMockS3()
+	entries := map[string]ScanEntry{}
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("p%d", i)
+		entries[id] = ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 * * * *"}
+	}
+
+	plain := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client)
+	if err := plain.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	plainSize := len(client.objects[S3ScansCrontabFilename])
+
+	// The uncompressed crontab is read by a store with compression.
+	compressed := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Compression())
+	got, err := compressed.GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Fatalf("uncompressed entries diff: %s", diff)
+	}
+
+	if err := compressed.SaveScanEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	stored := client.objects[S3ScansCrontabFilename]
+	if !bytes.HasPrefix(stored, gzipMagic) || len(stored) >= plainSize {
+		t.Fatalf("crontab not compressed, size %d, uncompressed size %d", len(stored), plainSize)
+	}
+	if size := compressed.StoredSizes()[S3ScansCrontabFilename]; size != int64(len(stored)) {
+		t.Errorf("stored size got %d, want %d", size, len(stored))
+	}
+
+	// The compressed crontab is read by a store without compression.
+	got, err = plain.GetScanEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("compressed entries diff: %s", diff)
+	}
+}
+
+func TestS3CronStore_SortedEntries(t *testing.T) {
+	client := newMockS3()
+	entries := map[string]ReportEntry{
+		"t2": {TeamID: "t2", CronSpec: "0 8 * * 1"},
+		"t1": {TeamID: "t1", CronSpec: "0 9 * * 1"},
+		"t3": {TeamID: "t3", CronSpec: "0 10 * * 1"},
+	}
+
+	sorted := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3SortedEntries())
+	if err := sorted.SaveReportEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	var stored []ReportEntry
+	if err := json.Unmarshal(client.objects[S3ReportsCrontabFilename], &stored); err != nil {
+		t.Fatalf("want the crontab stored as an array: %v", err)
+	}
+	if len(stored) != 3 || stored[0].TeamID != "t1" || stored[1].TeamID != "t2" || stored[2].TeamID != "t3" {
+		t.Errorf("want the entries sorted by ID, got %+v", stored)
+	}
+
+	// Both formats are read regardless of the option.
+	for _, s := range []*S3CronStore{
+		sorted,
+		NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client),
+	} {
+		got, err := s.GetReportEntries()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(entries, got); diff != "" {
+			t.Errorf("sorted entries diff: %s", diff)
+		}
+	}
+
+	// Sharded crontabs are also sorted.
+	sharded := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client,
+		WithS3Shards(2), WithS3SortedEntries())
+	if err := sharded.SaveReportEntries(entries); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewS3CronStore("bucket", S3ScansCrontabFilename, S3ReportsCrontabFilename, client, WithS3Shards(2)).GetReportEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("sharded sorted entries diff: %s", diff)
+	}
+}
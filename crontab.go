@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ErrMalformedCrontab indicates a crontab to import can not be parsed.
+var ErrMalformedCrontab = errors.New("ErrorMalformedCrontab")
+
+// crontabEntryComment matches the comments identifying the entry of the next
+// line of a crontab, as written by WriteCrontab, for instance:
+// "# scan 44a57d24 of team 461a62aa: Every day at 03:15".
+var crontabEntryComment = regexp.MustCompile(`^#\s*(\S+)\s+(\S+)\s+of\s+team\s+([^\s:]+)`)
+
+// crontabVariable matches the lines of a crontab setting environment
+// variables, like MAILTO=ops.
+var crontabVariable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+
+// ParseCrontab reads the scan entries of the given crontab. Every line
+// scheduling a scan must be preceded by a comment with the program ID and the
+// team of the scan, as written by WriteCrontab:
+//
+//	# scan <program ID> of team <team ID>
+//	15 3 * * * /usr/local/bin/scan.sh
+//
+// The comment may be followed by a colon and any text, and the command of the
+// line is ignored. The lines identified as entries of other types, the rest of
+// the comments and the environment variables are ignored. It returns an error
+// wrapping ErrMalformedCrontab, with the number of the offending line, if a
+// line scheduling a job is not preceded by the comment of an entry or has not
+// a complete spec. The specs are not validated, that is done when the entries
+// are saved.
+func ParseCrontab(r io.Reader) ([]ScanEntry, error) {
+	var (
+		entries []ScanEntry
+		typ     string
+		id      string
+		team    string
+		n       int
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case line == "" || crontabVariable.MatchString(line):
+			continue
+		case strings.HasPrefix(line, "#"):
+			if m := crontabEntryComment.FindStringSubmatch(line); m != nil {
+				typ, id, team = m[1], m[2], m[3]
+			}
+			continue
+		}
+		if id == "" {
+			return nil, fmt.Errorf("%w: line %d: missing the comment identifying the entry", ErrMalformedCrontab, n)
+		}
+		spec, ok := crontabSpec(line)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: incomplete spec", ErrMalformedCrontab, n)
+		}
+		if typ == ScanCronType.String() {
+			entries = append(entries, ScanEntry{ProgramID: id, TeamID: team, CronSpec: spec})
+		}
+		typ, id, team = "", "", ""
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// crontabSpec returns the spec of the given crontab line, that is, its first
+// five fields or its descriptor, like @daily or @every 1h.
+func crontabSpec(line string) (string, bool) {
+	fields := strings.Fields(line)
+	n := 5
+	if strings.HasPrefix(fields[0], "@") {
+		n = 1
+		if fields[0] == "@every" {
+			n = 2
+		}
+	}
+	if len(fields) < n {
+		return "", false
+	}
+	return strings.Join(fields[:n], " "), true
+}
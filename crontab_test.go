@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCrontab(t *testing.T) {
+	tests := []struct {
+		name    string
+		crontab string
+		want    []ScanEntry
+		wantErr bool
+	}{
+		{
+			name: "LegacyCrontab",
+			crontab: `SHELL=/bin/sh
+MAILTO=ops
+
+# scan p1 of team t1
+15 3 * * * /usr/local/bin/scan.sh p1
+
+# Weekly scan.
+# scan p2 of team t2: Every Monday at 08:00
+0 8 * * 1 /usr/local/bin/scan.sh p2
+# scan p3 of team t2
+@every 12h /usr/local/bin/scan.sh p3
+`,
+			want: []ScanEntry{
+				{ProgramID: "p1", TeamID: "t1", CronSpec: "15 3 * * *"},
+				{ProgramID: "p2", TeamID: "t2", CronSpec: "0 8 * * 1"},
+				{ProgramID: "p3", TeamID: "t2", CronSpec: "@every 12h"},
+			},
+		},
+		{
+			name: "IgnoresOtherTypes",
+			crontab: `# report t1 of team t1
+0 8 * * 1 report t1
+# scan p1 of team t1
+@daily scan p1
+`,
+			want: []ScanEntry{{ProgramID: "p1", TeamID: "t1", CronSpec: "@daily"}},
+		},
+		{
+			name:    "MissingComment",
+			crontab: "# scan p1 of team t1\n15 3 * * * scan p1\n0 8 * * 1 scan p2\n",
+			wantErr: true,
+		},
+		{
+			name:    "IncompleteSpec",
+			crontab: "# scan p1 of team t1\n15 3 * *\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCrontab(strings.NewReader(tt.crontab))
+			if tt.wantErr {
+				if !errors.Is(err, ErrMalformedCrontab) {
+					t.Fatalf("want ErrMalformedCrontab, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error parsing crontab: %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("entries got!=want, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseCrontab_Exported(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	exported := []ExportedEntry{
+		{Type: ScanCronType, ID: "p1", TeamID: "t1", Spec: "17 3 * * *", Description: "Every day at 03:17", NextRun: now},
+		{Type: ScanCronType, ID: "p2", TeamID: "t2", Spec: "0 * * * *", Description: "Every hour at minute 0"},
+		{Type: ReportCronType, ID: "t1", TeamID: "t1", Spec: "0 8 * * 1", Description: "Every Monday at 08:00", NextRun: now},
+	}
+	var b strings.Builder
+	if err := WriteCrontab(&b, exported); err != nil {
+		t.Fatalf("Error writing crontab: %v", err)
+	}
+	got, err := ParseCrontab(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Error parsing crontab: %v", err)
+	}
+	// The entries not scheduled are commented out, so they are not imported.
+	want := []ScanEntry{{ProgramID: "p1", TeamID: "t1", CronSpec: "17 3 * * *"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries got!=want, diff %s", diff)
+	}
+}
@@ -5,8 +5,14 @@ Copyright 2020 Adevinta
 package crontinuous
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/manelmontilla/cron"
@@ -15,6 +21,8 @@ import (
 const (
 	ScanCronType CronType = iota
 	ReportCronType
+	TeamScanCronType
+	CommandCronType
 )
 
 var (
@@ -27,13 +35,26 @@ var (
 	// ErrMalformedEntry indicates the given entry is invalid.
 	ErrMalformedEntry = errors.New("ErrorMalformedEntry")
 
+	// ErrScheduleConflict indicates the given entry fires too close to the
+	// executions of an entry of the other type for the same team.
+	ErrScheduleConflict = errors.New("ErrorScheduleConflict")
+
 	// ErrInvalidCronType indicates the given cron type is invalid.
 	ErrInvalidCronType = errors.New("ErrInvalidCronType")
 
+	// ErrTeamNotAllowed indicates the team of the entry is not
+	// whitelisted, or not at the current time, so its jobs can not
+	// be executed.
+	ErrTeamNotAllowed = errors.New("ErrorTeamNotAllowed")
+
 	// errTeamNotWhitelisted is used internally from scan and report
 	// cron files to indicate that entry was saved but should not be
 	// created because the teamID is not whitelisted.
 	errTeamNotWhitelisted = errors.New("ErrTeamNotWhitelisted")
+
+	// errJobSkipped is returned by the jobs
+	// that are not executed by design.
+	errJobSkipped = errors.New("ErrJobSkipped")
 )
 
 // Config holds the information required by the Crontinuous
@@ -43,326 +64,1503 @@ type Config struct {
 	TeamsWhitelistScan         []string
 	EnableTeamsWhitelistReport bool
 	TeamsWhitelistReport       []string
+	// ReportScanMinGap is the minimum time allowed between the executions
+	// of the scan and report entries of a team. Zero disables the check.
+	ReportScanMinGap time.Duration
+	// StopTimeout is the maximum time Stop waits for the running jobs
+	// to finish after cancelling them. Defaults to DefaultStopTimeout.
+	StopTimeout time.Duration
+	// ExecutionTimeout is the maximum time a job can run before being
+	// cancelled, unless its entry defines its own timeout. Zero means no
+	// timeout.
+	ExecutionTimeout time.Duration
+	// ScanCapacity is the number of scans per minute vulcan-api can
+	// handle. The saves of entries creating more scans in a minute log a
+	// warning. Zero disables the check.
+	ScanCapacity int
+	// EnforceScanCapacity makes SaveEntry, and the bulk creations and
+	// replacements, reject the entries creating more scans in a minute than
+	// ScanCapacity with ErrScanCapacityExceeded.
+	EnforceScanCapacity bool
+	// WarmUp is the period after Start in which the activations of the
+	// jobs are skipped, so a start at a minute many entries fire at does
+	// not execute all of them at once. The manual executions are not
+	// affected. Zero disables the warm-up.
+	WarmUp time.Duration
+	// WarmUpGradual makes the jobs be allowed progressively during the
+	// WarmUp instead of all of them at its end.
+	WarmUpGradual bool
 }
 
+// DefaultStopTimeout is the time Stop waits for the running jobs to finish
+// when no StopTimeout is configured.
+const DefaultStopTimeout = 30 * time.Second
+
 type CronType int
 
+var cronTypeNames = map[CronType]string{
+	ScanCronType:     "scan",
+	ReportCronType:   "report",
+	TeamScanCronType: "team-scan",
+	CommandCronType:  "command",
+}
+
+func (t CronType) String() string {
+	if name, ok := cronTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the cron type using its name.
+func (t CronType) MarshalText() ([]byte, error) {
+	if _, ok := cronTypeNames[t]; !ok {
+		return nil, ErrInvalidCronType
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a cron type from its name.
+func (t *CronType) UnmarshalText(text []byte) error {
+	for typ, name := range cronTypeNames {
+		if name == string(text) {
+			*t = typ
+			return nil
+		}
+	}
+	return ErrInvalidCronType
+}
+
+// CronEntry defines the methods that every kind of entry must implement
+// in order to be stored and scheduled by crontinuous.
 type CronEntry interface {
 	GetID() string
+	GetTeamID() string
+	GetType() CronType
 	GetCronSpec() string
+	// Validate returns ErrMalformedSchedule if the cron spec of the entry
+	// is invalid, and ErrMalformedEntry if any other field is invalid.
+	Validate() error
+}
+
+// UnmarshalEntry decodes an entry of the given type from its JSON encoding.
+func UnmarshalEntry(typ CronType, data []byte) (CronEntry, error) {
+	var (
+		entry CronEntry
+		err   error
+	)
+	switch typ {
+	case ScanCronType:
+		var e ScanEntry
+		err = json.Unmarshal(data, &e)
+		entry = e
+	case ReportCronType:
+		var e ReportEntry
+		err = json.Unmarshal(data, &e)
+		entry = e
+	case TeamScanCronType:
+		var e TeamScanEntry
+		err = json.Unmarshal(data, &e)
+		entry = e
+	case CommandCronType:
+		var e CommandEntry
+		err = json.Unmarshal(data, &e)
+		entry = e
+	default:
+		return nil, ErrInvalidCronType
+	}
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// reservedEntryIDs are the IDs the entries can not have, because the API
+// serves other endpoints in their paths, e.g. POST /entries/lookup.
+var reservedEntryIDs = map[string]bool{
+	"lookup": true,
+	"import": true,
+	"export": true,
+}
+
+// validateEntry checks the given entry is valid and of the given type.
+func validateEntry(typ CronType, entry CronEntry) error {
+	if entry == nil || entry.GetType() != typ {
+		return ErrMalformedEntry
+	}
+	if reservedEntryIDs[entry.GetID()] {
+		return fmt.Errorf("%w: reserved ID %s", ErrMalformedEntry, entry.GetID())
+	}
+	return entry.Validate()
+}
+
+// validateCronSpec returns ErrMalformedSchedule if spec is not a valid cron spec.
+func validateCronSpec(spec string) error {
+	// The values the H tokens resolve to depend on the
+	// entry, but any of them is valid if one is.
+	resolved, err := ResolveCronSpec(spec, "")
+	if err != nil {
+		return ErrMalformedSchedule
+	}
+	if _, err := cron.ParseStandard(resolved); err != nil {
+		return ErrMalformedSchedule
+	}
+	return nil
 }
 
 type cronEntryWithSchedule struct {
-	entry          CronEntry
-	schedule       cron.Schedule
-	overwriteEntry bool
+	entry     CronEntry
+	schedule  cron.Schedule
+	overwrite OverwritePolicy
+}
+
+// entryJob is implemented by the jobs executing the entries.
+type entryJob interface {
+	// run executes the job, returning an error if it did not succeed.
+	run(ctx context.Context) error
+	team() string
+	cronType() CronType
+	// entryID returns the ID of the entry the job executes.
+	entryID() string
+}
+
+// contextJob adapts an entryJob to a Job executing
+// it with the given context.
+type contextJob struct {
+	entryJob
+	ctx context.Context
+}
+
+func (j *contextJob) Run() {
+	j.run(j.ctx) // nolint
 }
 
 type cronJobSchedule struct {
 	schedule cron.Schedule
-	job      cron.Job
+	job      entryJob
 	id       string
 }
 
 // Crontinuous implements the logic for storing and executing programs.
 type Crontinuous struct {
-	config Config
-	log    *logrus.Logger
+	// config and the whitelists built from it can be changed by Reload, so
+	// they are read holding configMux.
+	config    Config
+	configMux sync.RWMutex
+
+	log *logrus.Logger
 
 	scanCreator   ScanCreator
 	scanCronStore ScanCronStore
-	scanEntries   map[string]ScanEntry
-	scanMux       sync.RWMutex
+	scans         *entrySet[ScanEntry]
 
 	reportSender    ReportSender
 	reportCronStore ReportCronStore
-	reportEntries   map[string]ReportEntry
-	reportMux       sync.RWMutex
+	reports         *entrySet[ReportEntry]
+
+	programLister     ProgramLister
+	teamScanCronStore TeamScanCronStore
+	teamScans         *entrySet[TeamScanEntry]
 
-	cron *cron.Cron
+	commandCronStore CommandCronStore
+	commands         *entrySet[CommandEntry]
+	scriptsDir       string
+	commandRuns      commandExecutions
+
+	validator      Validator
+	specRules      []SpecRule
+	specAliases    map[string]string
+	metadataSchema *MetadataSchema
+
+	journal  Journal
+	flags    FeatureFlags
+	reporter ErrorReporter
+	alerts   *alertTracker
+	running  runningJobs
+	waiting  runningJobs
+	jobSlots map[CronType]chan struct{}
+	timeouts typeCounts
+
+	deadLetters          DeadLetterQueue
+	deadLettersPublished typeCounts
+	deadLetterErrors     typeCounts
+	failures             failedExecutions
+	circuits             *teamCircuits
+	shard                *shard
+	locker               ExecutionLocker
+	lockTTL              time.Duration
+	healthGate           *healthGate
+	watchdog             *engineWatchdog
+	warmUp               *warmUp
+	readOnly             bool
+	scanIDs              ScanIDStrategy
+	reportIDs            ReportIDStrategy
+	progress             loadProgress
+	canaries             canaries
+	operations           operations
+	results              lastResults
+	executions           executionLog
+	budgets              teamBudgets
+	teamPrograms         teamPrograms
+	densities            scanDensities
+	divergence           divergences
+	divergenceNotifier   DivergenceNotifier
+	version              snapshotVersion
+
+	storeCache *storeCache
+
+	templateStore TemplateStore
+	templates     map[string]Template
+	templatesMux  sync.Mutex
+
+	pauseWindowStore PauseWindowStore
+	pauseWindows     map[string]PauseWindow
+	pauseWindowsMux  sync.Mutex
+
+	suppressionStore SuppressionStore
+	suppressions     map[string]Suppression
+	suppressionsMux  sync.Mutex
+
+	teamDefaultsStore TeamDefaultsStore
+	teamDefaults      map[string]TeamDefaults
+	teamDefaultsMux   sync.Mutex
+	// exclusive holds the entries running whose team forbids overlapping
+	// executions.
+	exclusive runningEntries
+
+	// conflictsMux serializes the saves of the entries checked for schedule
+	// conflicts.
+	conflictsMux sync.Mutex
+
+	// scanWhitelist applies to the scan and team scan entries.
+	scanWhitelist   teamsWhitelist
+	reportWhitelist teamsWhitelist
+
+	// dirty holds the types of entries whose last save to the store
+	// failed or, with a save interval, that are pending to be saved.
+	dirty        map[CronType]bool
+	dirtyMux     sync.Mutex
+	saveInterval time.Duration
+
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+
+	started      bool
+	lifecycleMux sync.Mutex
+
+	history       HistoryStore
+	historyLimit  int
+	historyMaxAge time.Duration
+	historyMux    sync.Mutex
+	historyStats  historyStats
+
+	// staged is the manifest in the staging area, nil if there is none.
+	staged    *Manifest
+	stagedMux sync.Mutex
+
+	cron         Scheduler
+	newScheduler func() Scheduler
+}
+
+// Option configures optional behaviour of the crontinuous service.
+type Option func(*Crontinuous)
+
+// WithFeatureFlags makes crontinuous consult the given feature flags
+// before executing a job.
+func WithFeatureFlags(f FeatureFlags) Option {
+	return func(c *Crontinuous) {
+		c.flags = f
+	}
+}
+
+// WithTeamScans enables the team scan entries, which create a scan for each
+// of the programs of a team returned by the given lister.
+func WithTeamScans(lister ProgramLister, store TeamScanCronStore) Option {
+	return func(c *Crontinuous) {
+		c.programLister = lister
+		c.teamScanCronStore = store
+	}
+}
+
+// WithJournal makes crontinuous record every entry mutation in the given
+// journal before applying it.
+func WithJournal(j Journal) Option {
+	return func(c *Crontinuous) {
+		c.journal = j
+	}
 }
 
 // NewCrontinuous creates a new instance of the crontinuous service.
 func NewCrontinuous(cfg Config, logger *logrus.Logger,
 	scanCreator ScanCreator, scanCronStore ScanCronStore,
-	reportSender ReportSender, reportCronStore ReportCronStore) *Crontinuous {
+	reportSender ReportSender, reportCronStore ReportCronStore, opts ...Option) *Crontinuous {
 
-	return &Crontinuous{
+	c := &Crontinuous{
 		config:          cfg,
 		log:             logger,
 		scanCreator:     scanCreator,
 		scanCronStore:   scanCronStore,
-		scanEntries:     make(map[string]ScanEntry),
 		reportSender:    reportSender,
 		reportCronStore: reportCronStore,
-		reportEntries:   make(map[string]ReportEntry),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if cfg.WarmUp > 0 {
+		c.warmUp = &warmUp{period: cfg.WarmUp, gradual: cfg.WarmUpGradual}
+	}
+	if c.circuits != nil {
+		c.circuits.log = logger
+		if c.scanCreator != nil {
+			c.scanCreator = &circuitScanCreator{ScanCreator: c.scanCreator, circuits: c.circuits}
+		}
+		if c.reportSender != nil {
+			c.reportSender = &circuitReportSender{ReportSender: c.reportSender, circuits: c.circuits}
+		}
+	}
+	if c.deadLetters != nil && c.scanCreator != nil {
+		c.scanCreator = &deadLetterScanCreator{ScanCreator: c.scanCreator, c: c}
+	}
+	if c.newScheduler == nil {
+		c.newScheduler = NewForkScheduler
+	}
+	c.scanWhitelist = newTeamsWhitelist(cfg.EnableTeamsWhitelistScan, cfg.TeamsWhitelistScan, logger)
+	c.reportWhitelist = newTeamsWhitelist(cfg.EnableTeamsWhitelistReport, cfg.TeamsWhitelistReport, logger)
+
+	loadScans, storeScans := withStoreCache(c, ScanCronType,
+		func() (map[string]ScanEntry, error) { return c.scanCronStore.GetScanEntries() },
+		func(e map[string]ScanEntry) error { return c.scanCronStore.SaveScanEntries(e) })
+	c.scans = newEntrySet(c, ScanCronType, loadScans, storeScans, c.newScanJob)
+	loadReports, storeReports := withStoreCache(c, ReportCronType,
+		func() (map[string]ReportEntry, error) { return c.reportCronStore.GetReportEntries() },
+		func(e map[string]ReportEntry) error { return c.reportCronStore.SaveReportEntries(e) })
+	c.reports = newEntrySet(c, ReportCronType, loadReports, storeReports, c.newReportJob)
+	if c.programLister != nil && c.teamScanCronStore != nil {
+		loadTeamScans, storeTeamScans := withStoreCache(c, TeamScanCronType,
+			func() (map[string]TeamScanEntry, error) { return c.teamScanCronStore.GetTeamScanEntries() },
+			func(e map[string]TeamScanEntry) error { return c.teamScanCronStore.SaveTeamScanEntries(e) })
+		c.teamScans = newEntrySet(c, TeamScanCronType, loadTeamScans, storeTeamScans, c.newTeamScanJob)
+	}
+	if c.commandCronStore != nil && c.scriptsDir != "" {
+		loadCommands, storeCommands := withStoreCache(c, CommandCronType,
+			func() (map[string]CommandEntry, error) { return c.commandCronStore.GetCommandEntries() },
+			func(e map[string]CommandEntry) error { return c.commandCronStore.SaveCommandEntries(e) })
+		c.commands = newEntrySet(c, CommandCronType, loadCommands, storeCommands, c.newCommandJob)
+	}
+	return c
 }
 
 // Start reads the cron entries from store, s3 by now, and initializes all the entries.
+// Calling Start on an already started instance does nothing.
 func (c *Crontinuous) Start() error {
-	c.cron = cron.New()
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
 
-	var cronSchedules []cronJobSchedule
+	if c.started {
+		return nil
+	}
+	return c.start()
+}
 
-	// Scan Entries
-	scanEntries, scanSchedules, err := c.buildScanEntries()
-	if err != nil {
+// Restart rebuilds the crontab from the entries in the store. If the entries
+// can not be loaded the current crontab keeps running. If the instance is not
+// started, Restart starts it.
+func (c *Crontinuous) Restart() error {
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	if !c.started {
+		return c.start()
+	}
+	if err := c.load(); err != nil {
 		return err
 	}
-	c.scanEntries = scanEntries
-	cronSchedules = append(cronSchedules, scanSchedules...)
+	c.log.Info("Restarted")
+	return nil
+}
 
-	// Report Entries
-	reportEntries, reportSchedules, err := c.buildReportEntries()
-	if err != nil {
+// Reload applies the given config and reloads the entries, the templates and
+// the pause windows from the stores, so the changes made to them out of
+// crontinuous are scheduled. The entries are rescheduled with the new
+// whitelists, and the rest of the settings apply to the next executions and
+// saves. The warm-up is not applied again. If the entries can not be loaded
+// the current ones keep running with the new config. If the instance is not
+// started only the config is applied.
+func (c *Crontinuous) Reload(cfg Config) error {
+	c.configMux.Lock()
+	cfg.WarmUp, cfg.WarmUpGradual = c.config.WarmUp, c.config.WarmUpGradual
+	c.config = cfg
+	c.scanWhitelist = newTeamsWhitelist(cfg.EnableTeamsWhitelistScan, cfg.TeamsWhitelistScan, c.log)
+	c.reportWhitelist = newTeamsWhitelist(cfg.EnableTeamsWhitelistReport, cfg.TeamsWhitelistReport, c.log)
+	c.configMux.Unlock()
+
+	if err := c.refresh(); err != nil {
 		return err
 	}
-	c.reportEntries = reportEntries
-	cronSchedules = append(cronSchedules, reportSchedules...)
+	c.log.Info("Reloaded")
+	return nil
+}
 
-	// Schedule cron jobs
-	for _, cs := range cronSchedules {
-		c.cron.Schedule(cs.schedule, cs.job, cs.id)
+func (c *Crontinuous) start() error {
+	c.jobsCtx, c.cancelJobs = context.WithCancel(context.Background())
+	if c.warmUp != nil {
+		c.warmUp.begin(time.Now())
 	}
-
-	c.cron.Start()
+	c.progress.reset(len(c.cronTypes()))
+	if err := c.load(); err != nil {
+		return err
+	}
+	c.started = true
+	c.progress.setReady(true)
+	if c.watchdog != nil {
+		go c.watchEngine(c.jobsCtx)
+	}
+	if c.saveInterval > 0 {
+		go c.saveEntries(c.jobsCtx)
+	}
+	if c.shard != nil {
+		c.log.WithField("shard", c.shard.String()).Info("Scheduling only the entries of the shard")
+	}
+	c.warnScanCapacity()
 	return nil
 }
 
-func (c *Crontinuous) buildScanEntries() (map[string]ScanEntry, []cronJobSchedule, error) {
-	scanEntries, err := c.scanCronStore.GetScanEntries()
+// load reads the entries from the stores and replaces the current
+// entries and cron with new ones built from them. The entries of the
+// different types are read and built in parallel. The entries pending to be
+// saved are saved before, so their changes are not lost.
+//
+// The lock of every set is held while the stores are read and until the
+// new entries are applied, so the mutations made meanwhile wait for the load
+// instead of being overwritten by the entries read.
+func (c *Crontinuous) load() error {
+	types := c.cronTypes()
+	sets := make([]entries, len(types))
+	for i, typ := range types {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return err
+		}
+		sets[i] = set
+	}
+
+	for _, set := range sets {
+		set.lock()
+	}
+	old, err := c.loadLocked(types, sets)
+	for i := len(sets) - 1; i >= 0; i-- {
+		sets[i].unlock()
+	}
 	if err != nil {
-		return nil, nil, err
+		return err
+	}
+
+	if old != nil {
+		old.Stop()
 	}
+	return nil
+}
 
-	var scanSchedules []cronJobSchedule
-	for _, se := range scanEntries {
-		if !c.isTeamWhitelisted(ScanCronType, se.TeamID) {
-			// If team is not whitelisted, return entry
-			// but do not build job to be scheduled.
+// loadLocked loads the given sets of the given types and returns the
+// replaced cron. It must be called holding the lock of the sets.
+func (c *Crontinuous) loadLocked(types []CronType, sets []entries) (Scheduler, error) {
+	dirty := map[CronType]bool{}
+	for _, typ := range c.dirtyTypes() {
+		dirty[typ] = true
+	}
+	for i, set := range sets {
+		if !dirty[types[i]] {
 			continue
 		}
-		s, err := cron.ParseStandard(se.CronSpec)
-		if err != nil {
-			// Abort start
-			// TODO: skip this entry and continue?
-			return nil, nil, err
+		if err := set.write(); err != nil {
+			return nil, err
 		}
+	}
+	// The jobs of the entries are built with the defaults of their teams.
+	if err := c.loadTeamDefaults(); err != nil {
+		return nil, err
+	}
 
-		jobLog := logrus.New().WithFields(logrus.Fields{"job": se.ProgramID})
+	var (
+		applies   = make([]func(), len(types))
+		schedules = make([][]cronJobSchedule, len(types))
+		errs      = make([]error, len(types))
+		wg        sync.WaitGroup
+	)
+	for i := range sets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applies[i], schedules[i], errs[i] = sets[i].build()
+			if errs[i] == nil {
+				c.progress.setLoaded(types[i], len(schedules[i]))
+			}
+		}(i)
+	}
+	wg.Wait()
 
-		scanSchedules = append(scanSchedules, cronJobSchedule{
-			schedule: s,
-			job: &scanJob{
-				programID:   se.ProgramID,
-				teamID:      se.TeamID,
-				scanCreator: c.scanCreator,
-				log:         jobLog,
-			},
-			id: se.ProgramID,
-		})
+	var cronSchedules []cronJobSchedule
+	for i := range sets {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		cronSchedules = append(cronSchedules, schedules[i]...)
 	}
 
-	return scanEntries, scanSchedules, nil
+	for _, apply := range applies {
+		apply()
+	}
+	// The entries in memory match the stores again.
+	c.divergence.reset()
+	c.version.inc()
+	return c.replaceCron(cronSchedules), nil
 }
 
-func (c *Crontinuous) buildReportEntries() (map[string]ReportEntry, []cronJobSchedule, error) {
-	reportEntries, err := c.reportCronStore.GetReportEntries()
-	if err != nil {
-		return nil, nil, err
+// replaceCron replaces the current cron with a new one running the given
+// jobs and returns the previous cron, which must be stopped by the caller.
+func (c *Crontinuous) replaceCron(schedules []cronJobSchedule) Scheduler {
+	old := c.cron
+	c.cron = c.newScheduler()
+	for _, cs := range schedules {
+		c.scheduleJob(cs.schedule, cs.job, cs.id)
 	}
+	c.scheduleHeartbeat()
+	c.cron.Start()
+	return old
+}
 
-	var reportSchedules []cronJobSchedule
-	for _, re := range reportEntries {
-		if !c.isTeamWhitelisted(ReportCronType, re.TeamID) {
-			// If team is not whitelisted, return entry
-			// but do not build job to be scheduled.
-			continue
+// entrySet returns the entries of the given type.
+func (c *Crontinuous) entrySet(typ CronType) (entries, error) {
+	switch typ {
+	case ScanCronType:
+		return c.scans, nil
+	case ReportCronType:
+		return c.reports, nil
+	case TeamScanCronType:
+		if c.teamScansEnabled() {
+			return c.teamScans, nil
 		}
-		s, err := cron.ParseStandard(re.CronSpec)
-		if err != nil {
-			// Abort start
-			// TODO: skip this entry and continue?
-			return nil, nil, err
+	case CommandCronType:
+		if c.commandsEnabled() {
+			return c.commands, nil
 		}
+	}
+	return nil, ErrInvalidCronType
+}
 
-		jobLog := logrus.New().WithFields(logrus.Fields{"job": re.TeamID})
+func (c *Crontinuous) teamScansEnabled() bool {
+	return c.teamScans != nil
+}
+
+func (c *Crontinuous) commandsEnabled() bool {
+	return c.commands != nil
+}
 
-		reportSchedules = append(reportSchedules, cronJobSchedule{
-			schedule: s,
-			job: &reportJob{
-				teamID:       re.TeamID,
-				reportSender: c.reportSender,
-				log:          jobLog,
-			},
-			id: re.TeamID,
-		})
+// cronJobID returns the ID of the cron job scheduled for the entry with
+// the given ID. Team scan entries are keyed by team ID as report entries
+// are, and command entries by any ID, so their job IDs are prefixed to avoid
+// collisions.
+func cronJobID(typ CronType, ID string) string {
+	switch typ {
+	case TeamScanCronType, CommandCronType:
+		return typ.String() + "/" + ID
 	}
+	return ID
+}
+
+// settings returns the current config.
+func (c *Crontinuous) settings() Config {
+	c.configMux.RLock()
+	defer c.configMux.RUnlock()
+	return c.config
+}
 
-	return reportEntries, reportSchedules, nil
+// whitelist returns the teams whitelist of the given cron type.
+func (c *Crontinuous) whitelist(typ CronType) teamsWhitelist {
+	c.configMux.RLock()
+	defer c.configMux.RUnlock()
+	switch typ {
+	case ReportCronType:
+		return c.reportWhitelist
+	case CommandCronType:
+		// The commands are not restricted to the whitelisted teams.
+		return teamsWhitelist{}
+	}
+	return c.scanWhitelist
 }
 
+// isTeamWhitelisted returns true if the jobs of the entries of the given
+// team must be scheduled, although they may only be allowed to execute in
+// the time windows of the whitelist of the team.
 func (c *Crontinuous) isTeamWhitelisted(typ CronType, teamID string) bool {
-	enable := false
-	whitelist := []string{}
+	return c.whitelist(typ).has(teamID)
+}
+
+// isTeamAllowedAt returns true if the jobs of the entries of the given team
+// are allowed to execute at the given time.
+func (c *Crontinuous) isTeamAllowedAt(typ CronType, teamID string, t time.Time) bool {
+	return c.whitelist(typ).allows(teamID, t)
+}
+
+// UnscheduledTeamNotWhitelisted is the reason an entry is stored but not
+// scheduled when the team of the entry is not in the whitelist of its type.
+const UnscheduledTeamNotWhitelisted = "team not whitelisted"
+
+// ScheduleStatus defines whether the jobs of a stored entry are scheduled.
+type ScheduleStatus struct {
+	Scheduled bool `json:"scheduled" yaml:"scheduled"`
+	// UnscheduledReason explains why the entry is not
+	// scheduled, empty if it is scheduled.
+	UnscheduledReason string `json:"unscheduled_reason,omitempty" yaml:"unscheduled_reason,omitempty"`
+}
 
-	if typ == ScanCronType {
-		enable = c.config.EnableTeamsWhitelistScan
-		whitelist = c.config.TeamsWhitelistScan
+// ScheduleStatus returns whether the jobs of the given
+// entry of the given type are scheduled once stored.
+func (c *Crontinuous) ScheduleStatus(typ CronType, entry CronEntry) ScheduleStatus {
+	if !c.isTeamWhitelisted(typ, entry.GetTeamID()) {
+		return ScheduleStatus{UnscheduledReason: UnscheduledTeamNotWhitelisted}
 	}
-	if typ == ReportCronType {
-		enable = c.config.EnableTeamsWhitelistReport
-		whitelist = c.config.TeamsWhitelistReport
+	if !c.shard.owns(cronJobID(typ, entry.GetID())) {
+		return ScheduleStatus{UnscheduledReason: UnscheduledOtherShard}
 	}
+	return ScheduleStatus{Scheduled: true}
+}
 
-	if !enable {
-		return true
+// scheduleJob schedules the given job in the cron wrapping it
+// with the checks that must be performed before each execution. The health
+// probe goes after the execution lock, so the lock is keyed by the minute of
+// the activation even if it is deferred.
+// The jobs assigned to the shards of other instances, and all of them in a
+// read-only replica, are ignored.
+func (c *Crontinuous) scheduleJob(s Schedule, job entryJob, id string) {
+	if c.readOnly || !c.shard.owns(id) {
+		return
+	}
+	cj := c.wrapJob(job)
+	var j Job = cj
+	if c.healthGate != nil {
+		j = &probedJob{Job: j, id: id, gate: c.healthGate, ctx: cj.ctx}
+	}
+	skipped := func(err error) { c.executions.skipped(job, err) }
+	if c.locker != nil {
+		j = &lockedJob{Job: j, id: id, locker: c.locker, ttl: c.lockTTL, log: c.log, skipped: skipped}
+	}
+	if c.warmUp != nil {
+		j = &warmingJob{Job: j, id: id, warmUp: c.warmUp, log: c.log, skipped: skipped}
 	}
-	for _, t := range whitelist {
-		if t == teamID {
-			return true
+	c.cron.Schedule(s, j, id)
+}
+
+// jobSettings holds the settings of an entry
+// that change how its jobs are executed.
+type jobSettings struct {
+	timeout  Duration
+	pingURL  string
+	alerting *AlertSettings
+	jitter   Duration
+	// windows are the execution windows of the entry, and windowPolicy the
+	// policy applied to the activations out of them.
+	windows      []string
+	windowPolicy WindowPolicy
+}
+
+// newEntryJob wraps the job of an entry according to the settings of the
+// entry.
+func (c *Crontinuous) newEntryJob(job entryJob, s jobSettings) entryJob {
+	s = c.teamDefaultsOf(job.team()).inherit(s)
+	job = c.withTimeout(&recoveredJob{entryJob: job}, s.timeout)
+	if job.cronType() != CommandCronType {
+		job = &meteredJob{entryJob: job, budgets: &c.budgets}
+	}
+	if s.jitter > 0 {
+		job = &jitteredJob{entryJob: job, delay: jitterDelay(job.entryID(), time.Duration(s.jitter))}
+	}
+	if s.pingURL != "" {
+		job = &pingJob{entryJob: job, url: s.pingURL, client: http.DefaultClient, log: c.log}
+	}
+	if c.alerts != nil {
+		aj := &alertingJob{entryJob: job, alerts: c.alerts}
+		if s.alerting != nil {
+			aj.settings = *s.alerting
 		}
+		job = aj
+	}
+	if c.reporter != nil {
+		job = &reportedJob{entryJob: job, c: c}
+	}
+	job = &resultJob{entryJob: job, results: &c.results}
+	if w := c.executionWindowsOf(job.team(), s.windows, s.windowPolicy); len(w.windows) > 0 {
+		job = &windowJob{entryJob: job, windows: w, log: c.log}
 	}
-	return false
+	return &executionJob{entryJob: job}
 }
 
-// Stop signals the command processor to stop processing commands and wait for it to exit.
-func (c *Crontinuous) Stop() {
-	c.cron.Stop()
+// wrapJob returns a cron job executing the given job after performing
+// the checks required before each execution.
+func (c *Crontinuous) wrapJob(job entryJob) *contextJob {
+	job = &trackedJob{entryJob: job, running: &c.running}
+	if slots, ok := c.jobSlots[job.cronType()]; ok {
+		job = &limitedJob{entryJob: job, slots: slots, waiting: &c.waiting}
+	}
+	if w := c.whitelist(job.cronType()); w.enabled {
+		job = &windowedJob{entryJob: job, whitelist: w, log: c.log}
+	}
+	if c.flags != nil {
+		job = &flagGuardedJob{entryJob: job, flags: c.flags, log: c.log}
+	}
+	if c.pauseWindowStore != nil {
+		job = &pausedJob{entryJob: job, c: c}
+	}
+	if c.suppressionStore != nil {
+		job = &suppressedJob{entryJob: job, c: c}
+	}
+	if c.budgets.limited() && job.cronType() != CommandCronType {
+		job = &budgetedJob{entryJob: job, budgets: &c.budgets, log: c.log}
+	}
+	if c.teamDefaultsOf(job.team()).Concurrency == ConcurrencyForbid {
+		job = &exclusiveJob{entryJob: job, running: &c.exclusive, log: c.log}
+	}
+	job = &recordedJob{entryJob: job, executions: &c.executions}
+	ctx := c.jobsCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &contextJob{entryJob: job, ctx: ctx}
+}
+
+// Stop stops the execution of new jobs, cancels the running ones and waits
+// for them to finish up to the configured StopTimeout. After that, the
+// entries that could not be persisted to the store are saved again. The
+// returned error describes the jobs and entries that could not finish.
+func (c *Crontinuous) Stop() error {
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	if c.cron != nil {
+		c.cron.Stop()
+	}
+	c.started = false
+	c.progress.setReady(false)
+	if c.cancelJobs != nil {
+		c.cancelJobs()
+	}
+
+	timeout := c.settings().StopTimeout
+	if timeout <= 0 {
+		timeout = DefaultStopTimeout
+	}
+
+	var problems []string
+	if running := c.running.wait(timeout); running > 0 {
+		problems = append(problems, fmt.Sprintf("%d jobs still running", running))
+	}
+	for _, typ := range c.dirtyTypes() {
+		if err := c.flush(typ); err != nil {
+			problems = append(problems, fmt.Sprintf("error saving %s entries: %v", typ, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		err := fmt.Errorf("stop: %s", strings.Join(problems, "; "))
+		c.log.WithError(err).Error("Stopped with errors")
+		return err
+	}
 	c.log.Info("Stopped")
+	return nil
+}
+
+// setDirty records whether the entries of the given
+// type are pending to be persisted to the store.
+func (c *Crontinuous) setDirty(typ CronType, saveErr error) {
+	c.markDirty(typ, saveErr != nil)
+}
+
+func (c *Crontinuous) markDirty(typ CronType, dirty bool) {
+	c.dirtyMux.Lock()
+	defer c.dirtyMux.Unlock()
+
+	if c.dirty == nil {
+		c.dirty = map[CronType]bool{}
+	}
+	c.dirty[typ] = dirty
+}
+
+func (c *Crontinuous) dirtyTypes() []CronType {
+	c.dirtyMux.Lock()
+	defer c.dirtyMux.Unlock()
+
+	var types []CronType
+	for typ, dirty := range c.dirty {
+		if dirty {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
+
+// flush persists the current entries of the given type to the store.
+func (c *Crontinuous) flush(typ CronType) error {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return err
+	}
+	return set.flush()
+}
+
+// OverwritePolicy defines how BulkCreateWithOverwrite treats a given entry
+// that already exists.
+type OverwritePolicy string
+
+const (
+	// OverwriteAll replaces the existing entry.
+	OverwriteAll OverwritePolicy = "all"
+	// OverwriteMissingOnly keeps the existing entry, only creating the
+	// entry if it does not exist.
+	OverwriteMissingOnly OverwritePolicy = "missing_only"
+	// OverwriteNone rejects the whole operation with ErrEntryExists if the
+	// entry exists.
+	OverwriteNone OverwritePolicy = "none"
+)
+
+var (
+	// ErrInvalidOverwrite indicates the overwrite policies are unknown or
+	// there is not one per entry.
+	ErrInvalidOverwrite = errors.New("ErrorInvalidOverwrite")
+	// ErrEntryExists indicates the entry already exists and must not
+	// be overwritten.
+	ErrEntryExists = errors.New("ErrorEntryExists")
+)
+
+// Valid returns true if the policy is one of the known ones.
+func (p OverwritePolicy) Valid() bool {
+	return p == OverwriteAll || p == OverwriteMissingOnly || p == OverwriteNone
 }
 
 // BulkCreate tests for each specified entry if an entry with the same programID exists.
 // If it exists and overwrite setting for that entry is set to false the method does nothing.
 // If it doesn't exist or overwrite setting is set to true, the method creates/overwrites the entry.
-func (c *Crontinuous) BulkCreate(typ CronType, entries []CronEntry, overwriteSettings []bool) error {
-	parsedEntries := make(map[string]cronEntryWithSchedule)
+// ErrInvalidOverwrite is returned if there is not an overwrite setting per entry.
+// As when saving an entry, the references of the entries created, or moved
+// to another team, are validated if a validator is configured,
+// ErrScheduleConflict is returned if any of the entries created or
+// overwritten conflicts with the entries of the other type of its team,
+// unless the IgnoreScheduleConflicts option is given, and
+// ErrScanCapacityExceeded if they exceed the enforced scan capacity, unless
+// the IgnoreScanCapacity option is given. No other option is considered.
+func (c *Crontinuous) BulkCreate(typ CronType, entries []CronEntry, overwriteSettings []bool, opts ...SaveOption) error {
+	if len(overwriteSettings) != len(entries) {
+		return ErrInvalidOverwrite
+	}
+	policies := make([]OverwritePolicy, len(overwriteSettings))
+	for i, overwrite := range overwriteSettings {
+		policies[i] = OverwriteMissingOnly
+		if overwrite {
+			policies[i] = OverwriteAll
+		}
+	}
+	return c.BulkCreateWithOverwrite(typ, entries, policies, opts...)
+}
+
+// BulkCreateWithOverwrite is like BulkCreate, but each of the given entries
+// that already exists is treated according to its overwrite policy.
+// ErrInvalidOverwrite is returned if there is not a known policy per entry.
+func (c *Crontinuous) BulkCreateWithOverwrite(typ CronType, entries []CronEntry, policies []OverwritePolicy, opts ...SaveOption) error {
+	return c.BulkCreateWithOverwriteContext(context.Background(), typ, entries, policies, opts...)
+}
 
-	// In order to try to reduce to the minimun the time this methods
-	// locks the entries, we parse the cron strings in this loop and not inside
-	// the loop below inside the lock-unlock block.
+// BulkCreateWithOverwriteContext is like BulkCreateWithOverwrite but the
+// validation of the references of the entries is cancelled when the given
+// context is done.
+func (c *Crontinuous) BulkCreateWithOverwriteContext(ctx context.Context, typ CronType, entries []CronEntry, policies []OverwritePolicy, opts ...SaveOption) error {
+	_, _, err := c.bulkCreate(ctx, typ, entries, policies, opts...)
+	return err
+}
+
+// bulkCreate implements BulkCreateWithOverwrite, returning the IDs of the
+// given entries, in the same order, and the changes performed.
+func (c *Crontinuous) bulkCreate(ctx context.Context, typ CronType, entries []CronEntry, policies []OverwritePolicy, opts ...SaveOption) ([]string, []Change, error) {
+	if len(policies) != len(entries) {
+		return nil, nil, ErrInvalidOverwrite
+	}
+	for _, p := range policies {
+		if !p.Valid() {
+			return nil, nil, ErrInvalidOverwrite
+		}
+	}
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if entries, err = c.applyTemplates(entries); err != nil {
+		return nil, nil, err
+	}
 	for i, e := range entries {
-		s, err := cron.ParseStandard(e.GetCronSpec())
-		if err != nil {
-			return ErrMalformedSchedule
+		e = c.expandSpecAlias(e)
+		if err := validateEntry(typ, e); err != nil {
+			return nil, nil, entryError(e, err)
 		}
-		parsedEntries[e.GetID()] = cronEntryWithSchedule{
-			entry:          e,
-			schedule:       s,
-			overwriteEntry: overwriteSettings[i],
+		if err := c.checkMetadata(e); err != nil {
+			return nil, nil, entryError(e, err)
 		}
+		if e, err = c.applySpecRules(typ, e); err != nil {
+			return nil, nil, entryError(e, err)
+		}
+		entries[i] = e
+	}
+	if err := c.validateNewReferences(ctx, typ, entries, policies); err != nil {
+		return nil, nil, err
 	}
 
-	var jobsWithSchedule []cronJobSchedule
-	var err error
-
-	switch typ {
-	case ScanCronType:
-		jobsWithSchedule, err = c.scanBulkCreate(parsedEntries)
-	case ReportCronType:
-		jobsWithSchedule, err = c.reportBulkCreate(parsedEntries)
-	default:
-		return ErrInvalidCronType
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// The entries are identified and checked holding the lock of the set,
+	// and the lock of the conflicts, so they are unique among the entries
+	// saved concurrently and the checks still hold when they are saved.
+	entryIDs := make([]string, len(entries))
+	prepare := func() (map[string]cronEntryWithSchedule, error) {
+		parsedEntries := make(map[string]cronEntryWithSchedule)
+		var saved []CronEntry
+		ids := c.newEntryIdentifier(typ)
+		for i, e := range entries {
+			e, err := ids.identify(e)
+			if err != nil {
+				return nil, err
+			}
+			s, err := c.entrySchedule(e)
+			if err != nil {
+				return nil, entryError(e, ErrMalformedSchedule)
+			}
+			if err := c.checkWindows(e, s); err != nil {
+				return nil, entryError(e, err)
+			}
+			// The existing entries that are kept are not checked.
+			if _, err := set.get(e.GetID()); err != nil || policies[i] == OverwriteAll {
+				if !o.ignoreConflicts {
+					if err := c.checkScheduleConflict(typ, e, s); err != nil {
+						return nil, entryError(e, err)
+					}
+				}
+				saved = append(saved, e)
+			}
+			parsedEntries[e.GetID()] = cronEntryWithSchedule{
+				entry:     e,
+				schedule:  s,
+				overwrite: policies[i],
+			}
+			entryIDs[i] = e.GetID()
+		}
+		if err := c.checkScanCapacity(typ, saved, nil, o.ignoreCapacity); err != nil {
+			return nil, err
+		}
+		return parsedEntries, nil
 	}
 
+	unlock := c.lockConflicts(typ)
+	jobsWithSchedule, changes, err := set.bulkCreate(prepare)
+	unlock()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	c.recordHistory("", changes...)
 
 	for _, j := range jobsWithSchedule {
 		j := j // Prevent gotcha with pointers and ranges.
-		c.cron.Schedule(j.schedule, j.job, j.id)
+		c.scheduleJob(j.schedule, j.job, j.id)
 	}
-	return nil
+	return entryIDs, changes, nil
 }
 
-// SaveEntry adds a new entry to the crontab.
-func (c *Crontinuous) SaveEntry(typ CronType, entry CronEntry) error {
-	s, err := cron.ParseStandard(entry.GetCronSpec())
+// BulkMode defines how BulkReplace treats the entries
+// that are not in the given set.
+type BulkMode string
+
+const (
+	// BulkModeReplaceTeam makes the given entries the complete set of entries
+	// of their teams, and of the teams given with the ReplaceTeams option,
+	// removing the other entries of those teams.
+	BulkModeReplaceTeam BulkMode = "replace_team"
+	// BulkModeSync makes the given entries the complete set of entries
+	// of their type, removing all the other entries.
+	BulkModeSync BulkMode = "sync"
+)
+
+var (
+	// ErrInvalidBulkMode indicates the given bulk mode is invalid.
+	ErrInvalidBulkMode = errors.New("ErrorInvalidBulkMode")
+)
+
+// BulkReplace treats the given entries as the complete desired set of entries
+// for the scope defined by the mode: the entries in scope that are not given
+// are removed, the new ones are created and the changed ones are updated. It
+// returns the changes performed. As when saving an entry, the references of
+// the entries created, or moved to another team, are validated if a validator
+// is configured, ErrScheduleConflict is returned if any of the given entries
+// conflicts with the entries of the other type of its team, unless the
+// IgnoreScheduleConflicts option is given, and ErrScanCapacityExceeded if they
+// exceed the enforced scan capacity, unless the IgnoreScanCapacity option is
+// given. ErrInvalidBulkMode is returned if the mode is BulkModeReplaceTeam
+// and there is no team to replace, neither given with the ReplaceTeams option
+// nor in the entries. No other option is considered.
+func (c *Crontinuous) BulkReplace(typ CronType, entries []CronEntry, mode BulkMode, opts ...SaveOption) ([]Change, error) {
+	return c.BulkReplaceContext(context.Background(), typ, entries, mode, opts...)
+}
+
+// BulkReplaceContext is like BulkReplace but the validation of the
+// references of the entries is cancelled when the given context is done.
+func (c *Crontinuous) BulkReplaceContext(ctx context.Context, typ CronType, entries []CronEntry, mode BulkMode, opts ...SaveOption) ([]Change, error) {
+	set, err := c.entrySet(typ)
 	if err != nil {
-		return ErrMalformedSchedule
+		return nil, err
 	}
 
-	var cronJob cron.Job
-
-	switch typ {
-	case ScanCronType:
-		cronJob, err = c.saveScanEntry(entry)
-	case ReportCronType:
-		cronJob, err = c.saveReportEntry(entry)
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var inScope func(CronEntry) bool
+	switch mode {
+	case BulkModeReplaceTeam:
+		teams := map[string]bool{}
+		for _, t := range o.teams {
+			teams[t] = true
+		}
+		for _, e := range entries {
+			teams[e.GetTeamID()] = true
+		}
+		if len(teams) == 0 {
+			return nil, fmt.Errorf("%w: no team to replace", ErrInvalidBulkMode)
+		}
+		inScope = func(e CronEntry) bool { return teams[e.GetTeamID()] }
+	case BulkModeSync:
+		inScope = func(CronEntry) bool { return true }
 	default:
-		return ErrInvalidCronType
+		return nil, ErrInvalidBulkMode
+	}
+
+	if entries, err = c.applyTemplates(entries); err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		e = c.expandSpecAlias(e)
+		if err := validateEntry(typ, e); err != nil {
+			return nil, entryError(e, err)
+		}
+		if err := c.checkMetadata(e); err != nil {
+			return nil, entryError(e, err)
+		}
+		if e, err = c.applySpecRules(typ, e); err != nil {
+			return nil, entryError(e, err)
+		}
+		entries[i] = e
+	}
+	if err := c.validateNewReferences(ctx, typ, entries, nil); err != nil {
+		return nil, err
 	}
 
+	// The entries are identified and checked holding the lock of the set,
+	// and the lock of the conflicts, so they are unique among the entries
+	// saved concurrently and the checks still hold when they are saved.
+	schedules := make(map[string]cron.Schedule)
+	prepare := func() ([]CronEntry, error) {
+		ids := c.newEntryIdentifier(typ)
+		for i, e := range entries {
+			e, err := ids.identify(e)
+			if err != nil {
+				return nil, err
+			}
+			entries[i] = e
+			if _, ok := schedules[e.GetID()]; ok {
+				return nil, entryError(e, ErrMalformedEntry)
+			}
+			s, err := c.entrySchedule(e)
+			if err != nil {
+				return nil, entryError(e, ErrMalformedSchedule)
+			}
+			if err := c.checkWindows(e, s); err != nil {
+				return nil, entryError(e, err)
+			}
+			if !o.ignoreConflicts {
+				if err := c.checkScheduleConflict(typ, e, s); err != nil {
+					return nil, entryError(e, err)
+				}
+			}
+			schedules[e.GetID()] = s
+		}
+		if err := c.checkScanCapacity(typ, entries, inScope, o.ignoreCapacity); err != nil {
+			return nil, err
+		}
+		return entries, nil
+	}
+
+	unlock := c.lockConflicts(typ)
+	previous, err := set.bulkReplace(prepare, inScope)
+	unlock()
 	if err != nil {
-		if errors.Is(err, errTeamNotWhitelisted) {
-			// If team is not whitelisted, do not
-			// schedule job and return.
-			return nil
+		return nil, err
+	}
+
+	changes := Diff(typ, previous, entries)
+	c.recordHistory("", changes...)
+	for _, ch := range changes {
+		id := cronJobID(typ, ch.ID)
+		if ch.Action == ChangeDelete || !c.isTeamWhitelisted(typ, ch.Desired.GetTeamID()) {
+			c.cron.RemoveJob(id)
+			continue
+		}
+		job, err := set.job(ch.ID)
+		if err != nil {
+			// The entry was removed meanwhile.
+			continue
+		}
+		c.scheduleJob(schedules[ch.ID], job, id)
+	}
+	return changes, nil
+}
+
+// SaveEntry adds a new entry to the crontab. If a validator is configured,
+// ErrTeamNotFound or ErrProgramNotFound is returned when creating an entry, or
+// moving it to another team, whose team or program does not exist. The spec
+// of the entry is expanded if it is a spec alias and rewritten by the spec
+// rules, if any, and ErrSpecRuleViolation is returned if they reject it.
+// ErrMetadataSchemaViolation is returned if the metadata of the entry does
+// not match the metadata schema.
+func (c *Crontinuous) SaveEntry(typ CronType, entry CronEntry, opts ...SaveOption) error {
+	return c.SaveEntryContext(context.Background(), typ, entry, opts...)
+}
+
+// SaveEntryContext is like SaveEntry but the validation of the references of
+// the entry is cancelled when the given context is done.
+func (c *Crontinuous) SaveEntryContext(ctx context.Context, typ CronType, entry CronEntry, opts ...SaveOption) error {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		if entry, err = c.applyTemplate(entry); err != nil {
+			return err
+		}
+	}
+	entry = c.expandSpecAlias(entry)
+	if err := validateEntry(typ, entry); err != nil {
+		return entryError(entry, err)
+	}
+	if err := c.checkMetadata(entry); err != nil {
+		return entryError(entry, err)
+	}
+	if entry, err = c.applySpecRules(typ, entry); err != nil {
+		return entryError(entry, err)
+	}
+
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := c.validateNewReferences(ctx, typ, []CronEntry{entry}, nil); err != nil {
+		return err
+	}
+
+	// The entry is identified and checked against the current entries
+	// holding the lock of the set, and the lock of the conflicts, so the
+	// checks still hold when it is saved.
+	var s cron.Schedule
+	prepare := func() (CronEntry, error) {
+		identified, err := c.newEntryIdentifier(typ).identify(entry)
+		if err != nil {
+			return nil, err
 		}
+		if s, err = c.entrySchedule(identified); err != nil {
+			return nil, entryError(identified, ErrMalformedSchedule)
+		}
+		if err := c.checkWindows(identified, s); err != nil {
+			return nil, entryError(identified, err)
+		}
+		if !o.ignoreConflicts {
+			if err := c.checkScheduleConflict(typ, identified, s); err != nil {
+				return nil, entryError(identified, err)
+			}
+		}
+		if err := c.checkScanCapacity(typ, []CronEntry{identified}, nil, o.ignoreCapacity); err != nil {
+			return nil, entryError(identified, err)
+		}
+		entry = identified
+		return entry, nil
+	}
+
+	unlock := c.lockConflicts(typ)
+	previous, cronJob, err := set.save(prepare)
+	unlock()
+	if err != nil && !errors.Is(err, errTeamNotWhitelisted) {
 		return err
 	}
+	change := Change{Action: ChangeAdd, Type: typ, ID: entry.GetID(), Desired: entry}
+	if previous != nil {
+		change.Action, change.Current = ChangeUpdate, previous
+	}
+	c.recordHistory(o.changedBy, change)
+	if err != nil {
+		// If team is not whitelisted, do not schedule the job, and
+		// unschedule the one of the previous version of the entry.
+		c.cron.RemoveJob(cronJobID(typ, entry.GetID()))
+	} else {
+		c.scheduleJob(s, cronJob, cronJobID(typ, entry.GetID()))
+	}
+	if o.savedID != nil {
+		*o.savedID = entry.GetID()
+	}
 
-	c.cron.Schedule(s, cronJob, entry.GetID())
+	if o.canary != "" && previous == nil {
+		ca := c.startCanary(typ, entry, o.canary)
+		if o.startedCanary != nil {
+			*o.startedCanary = ca
+		}
+	}
 	return nil
 }
 
+// TransferScanEntry reassigns the scan entry of the given program to the given
+// team, as needed when a program moves between teams. The entry is
+// rescheduled, or unscheduled if the new team is not whitelisted, and the
+// transferred entry is returned. As when saving an entry, ErrScheduleConflict
+// is returned if the schedule conflicts with the report of the new team,
+// unless the IgnoreScheduleConflicts option is given, and, if a validator is
+// configured, ErrTeamNotFound or ErrProgramNotFound if the new team does not
+// exist or the program does not belong to it.
+func (c *Crontinuous) TransferScanEntry(programID, teamID string, opts ...SaveOption) (ScanEntry, error) {
+	return c.TransferScanEntryContext(context.Background(), programID, teamID, opts...)
+}
+
+// TransferScanEntryContext is like TransferScanEntry but the validation of
+// the new team is cancelled when the given context is done.
+func (c *Crontinuous) TransferScanEntryContext(ctx context.Context, programID, teamID string, opts ...SaveOption) (ScanEntry, error) {
+	if teamID == "" {
+		return ScanEntry{}, ErrMalformedEntry
+	}
+	e, err := c.scans.get(programID)
+	if err != nil {
+		return ScanEntry{}, err
+	}
+	s, err := c.entrySchedule(e)
+	if err != nil {
+		return ScanEntry{}, entryError(e, ErrMalformedSchedule)
+	}
+
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	moved := e.(ScanEntry)
+	moved.TeamID = teamID
+	if c.validator != nil && e.GetTeamID() != teamID {
+		if err := c.validateReferences(ctx, moved); err != nil {
+			return ScanEntry{}, entryError(moved, err)
+		}
+	}
+
+	// The conflicts are checked holding the lock of the set, and the lock
+	// of the conflicts, against the current version of the entry.
+	unlock := c.lockConflicts(ScanCronType)
+	previous, entry, job, err := c.scans.update(programID, func(e ScanEntry) (ScanEntry, error) {
+		e.TeamID = teamID
+		if o.ignoreConflicts {
+			return e, nil
+		}
+		s, err := c.entrySchedule(e)
+		if err != nil {
+			return e, entryError(e, ErrMalformedSchedule)
+		}
+		if err := c.checkScheduleConflict(ScanCronType, e, s); err != nil {
+			return e, entryError(e, err)
+		}
+		return e, nil
+	})
+	unlock()
+	if err != nil {
+		return ScanEntry{}, err
+	}
+	c.recordHistory(o.changedBy, Change{Action: ChangeUpdate, Type: ScanCronType, ID: programID, Current: previous, Desired: entry})
+
+	id := cronJobID(ScanCronType, programID)
+	if job == nil {
+		c.cron.RemoveJob(id)
+		return entry, nil
+	}
+	// The spec may have changed since it was parsed.
+	if s, err = c.entrySchedule(entry); err != nil {
+		return entry, entryError(entry, ErrMalformedSchedule)
+	}
+	c.scheduleJob(s, job, id)
+	return entry, nil
+}
+
 // GetEntries returns a snapshot of the current entries.
 func (c *Crontinuous) GetEntries(typ CronType) ([]CronEntry, error) {
-	var entries []CronEntry
-	var err error
-
-	switch typ {
-	case ScanCronType:
-		entries, err = c.getScanEntries()
-	case ReportCronType:
-		entries, err = c.getReportEntries()
-	default:
-		return nil, ErrInvalidCronType
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, err
 	}
+	return set.all(), nil
+}
 
-	return entries, err
+// StreamEntries calls fn with the entries of the given type changed after the
+// given time, or all of them if it is zero, sorted by ID in chunks of at most
+// the given size. Instead of copying all the entries at once, only their IDs
+// are, and the entries of every chunk are read holding the lock of the
+// entries just while doing it. So an entry changed meanwhile is passed in its
+// latest version, a removed one is skipped if its chunk was not read yet, and
+// the ones created meanwhile are not passed. It stops at the first error
+// returned by fn and returns it.
+func (c *Crontinuous) StreamEntries(typ CronType, since time.Time, size int, fn func([]CronEntry) error) error {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return err
+	}
+	if size < 1 {
+		size = 1
+	}
+	ids := set.ids()
+	for len(ids) > 0 {
+		n := size
+		if n > len(ids) {
+			n = len(ids)
+		}
+		chunk, _ := set.lookup(ids[:n])
+		ids = ids[n:]
+		if !since.IsZero() {
+			changed := chunk[:0]
+			for _, e := range chunk {
+				if u := entryUpdatedAt(e); u != nil && u.After(since) {
+					changed = append(changed, e)
+				}
+			}
+			chunk = changed
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetEntryByID returns a snapshot of the current entries.
 func (c *Crontinuous) GetEntryByID(typ CronType, ID string) (CronEntry, error) {
-	var entry CronEntry
-	var err error
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, err
+	}
+	return set.get(ID)
+}
 
-	switch typ {
-	case ScanCronType:
-		entry, err = c.getScanEntryByID(ID)
-	case ReportCronType:
-		entry, err = c.getReportEntryByID(ID)
-	default:
-		return nil, ErrInvalidCronType
+// LookupEntries returns, in the given order, the entries of the given type
+// with the given IDs and the IDs not found, so many entries can be retrieved
+// at once.
+func (c *Crontinuous) LookupEntries(typ CronType, IDs []string) (found []CronEntry, missing []string, err error) {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, nil, err
 	}
+	found, missing = set.lookup(IDs)
+	return found, missing, nil
+}
 
+// RemoveEntry remove an existing entry. Only the ChangedBy option
+// is considered.
+func (c *Crontinuous) RemoveEntry(typ CronType, ID string, opts ...SaveOption) error {
+	set, err := c.entrySet(typ)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	removed, err := set.remove(ID)
+	if err != nil {
+		return err
 	}
 
-	return entry, nil
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c.recordHistory(o.changedBy, Change{Action: ChangeDelete, Type: typ, ID: ID, Current: removed})
+
+	c.cron.RemoveJob(cronJobID(typ, ID))
+	c.results.forget(typ, ID)
+	return nil
 }
 
-// RemoveEntry remove an existing entry.
-func (c *Crontinuous) RemoveEntry(typ CronType, ID string) error {
-	var err error
+// RemoveEntries removes the existing entries of the given type with the
+// given IDs, saving the entries to the store once instead of once per entry
+// as RemoveEntry does, and returns the changes performed and the IDs not
+// found. Only the ChangedBy option is considered.
+func (c *Crontinuous) RemoveEntries(typ CronType, IDs []string, opts ...SaveOption) ([]Change, []string, error) {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, nil, err
+	}
+	removed, missing, err := set.removeAll(IDs)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	switch typ {
-	case ScanCronType:
-		err = c.removeScanEntry(ID)
-	case ReportCronType:
-		err = c.removeReportEntry(ID)
-	default:
-		return ErrInvalidCronType
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	changes := make([]Change, 0, len(removed))
+	for _, e := range removed {
+		changes = append(changes, Change{Action: ChangeDelete, Type: typ, ID: e.GetID(), Current: e})
 	}
+	c.recordHistory(o.changedBy, changes...)
+
+	for _, e := range removed {
+		c.cron.RemoveJob(cronJobID(typ, e.GetID()))
+		c.results.forget(typ, e.GetID())
+	}
+	return changes, missing, nil
+}
 
+// RunEntry executes in background the job of an existing entry without
+// waiting for its next activation. It returns ErrTeamNotAllowed if the team
+// of the entry is not whitelisted or it is out of its whitelist windows.
+func (c *Crontinuous) RunEntry(typ CronType, ID string) error {
+	return c.RunEntryContext(context.Background(), typ, ID)
+}
+
+// RunEntryContext is like RunEntry but the execution carries the request ID
+// of the given context, if any. The execution is not cancelled when the
+// given context is done.
+func (c *Crontinuous) RunEntryContext(ctx context.Context, typ CronType, ID string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return err
+	}
+	job, err := set.job(ID)
 	if err != nil {
 		return err
 	}
+	if !c.isTeamAllowedAt(typ, job.team(), time.Now()) {
+		return ErrTeamNotAllowed
+	}
+	if _, paused := c.activePauseWindow(typ, job.team(), time.Now()); paused {
+		return ErrTeamNotAllowed
+	}
+	if _, suppressed := c.activeSuppression(typ, ID, time.Now()); suppressed {
+		return ErrTeamNotAllowed
+	}
+	if typ != CommandCronType && c.budgets.usage(job.team(), time.Now()).Exhausted {
+		return ErrBudgetExhausted
+	}
 
-	c.cron.RemoveJob(ID)
+	cj := c.wrapJob(job)
+	if id := RequestIDFromContext(ctx); id != "" {
+		cj.ctx = ContextWithRequestID(cj.ctx, id)
+	}
+	go cj.Run()
 	return nil
 }
@@ -5,8 +5,12 @@ Copyright 2020 Adevinta
 package crontinuous
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +21,14 @@ import (
 )
 
 var (
+	// ignoreUpdatedAtOption ignores the time of the last change of the
+	// entries, set when they are saved.
+	ignoreUpdatedAtOption = cmp.Options{
+		cmpopts.IgnoreFields(ScanEntry{}, "UpdatedAt"),
+		cmpopts.IgnoreFields(ReportEntry{}, "UpdatedAt"),
+		cmpopts.IgnoreFields(TeamScanEntry{}, "UpdatedAt"),
+		cmpopts.IgnoreFields(CommandEntry{}, "UpdatedAt"),
+	}
 	sortEntriesSliceOption = cmp.Transformer("SortEntries", func(in []CronEntry) []CronEntry {
 		out := append([]CronEntry(nil), in...)
 		sort.Slice(out, func(i, j int) bool {
@@ -24,8 +36,8 @@ var (
 		})
 		return out
 	})
-	sortJobsSliceOption = cmp.Transformer("SortJobs", func(in []*cron.Entry) []*cron.Entry {
-		out := append([]*cron.Entry(nil), in...)
+	sortJobsSliceOption = cmp.Transformer("SortJobs", func(in []ScheduledJob) []ScheduledJob {
+		out := append([]ScheduledJob(nil), in...)
 		sort.Slice(out, func(i, j int) bool {
 			return strings.Compare(out[i].ID, out[j].ID) < 0
 		})
@@ -390,10 +402,7 @@ func TestCrontinuous_GetEntries(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := &Crontinuous{
-				scanEntries:   tt.scanEntries,
-				reportEntries: tt.reportEntries,
-			}
+			c := newTestCrontinuous(Config{}, nil, tt.scanEntries, nil, tt.reportEntries)
 
 			gotScanEntries, err := c.GetEntries(ScanCronType)
 			if err != nil {
@@ -416,6 +425,90 @@ func TestCrontinuous_GetEntries(t *testing.T) {
 	}
 }
 
+func TestCrontinuous_StreamEntries(t *testing.T) {
+	updated := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	scanEntries := map[string]ScanEntry{
+		"1": {ProgramID: "1", TeamID: "team1", CronSpec: "*/2 * * * *"},
+		"2": {ProgramID: "2", TeamID: "team1", CronSpec: "*/3 * * * *", EntrySettings: EntrySettings{UpdatedAt: &updated}},
+		"3": {ProgramID: "3", TeamID: "team2", CronSpec: "*/4 * * * *", EntrySettings: EntrySettings{UpdatedAt: &updated}},
+		"4": {ProgramID: "4", TeamID: "team2", CronSpec: "*/5 * * * *"},
+		"5": {ProgramID: "5", TeamID: "team3", CronSpec: "*/6 * * * *", EntrySettings: EntrySettings{UpdatedAt: &updated}},
+	}
+	tests := []struct {
+		name       string
+		since      time.Time
+		size       int
+		wantChunks [][]string
+	}{
+		{"all", time.Time{}, 2, [][]string{{"1", "2"}, {"3", "4"}, {"5"}}},
+		{"one chunk", time.Time{}, 10, [][]string{{"1", "2", "3", "4", "5"}}},
+		{"invalid size", time.Time{}, 0, [][]string{{"1"}, {"2"}, {"3"}, {"4"}, {"5"}}},
+		{"changed since", updated.Add(-time.Hour), 2, [][]string{{"2"}, {"3"}, {"5"}}},
+		{"none changed", updated, 2, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrontinuous(Config{}, nil, scanEntries, nil, map[string]ReportEntry{})
+			var got [][]string
+			err := c.StreamEntries(ScanCronType, tt.since, tt.size, func(entries []CronEntry) error {
+				var ids []string
+				for _, e := range entries {
+					ids = append(ids, e.GetID())
+				}
+				got = append(got, ids)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantChunks, got); diff != "" {
+				t.Errorf("chunks mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	t.Run("removed while streaming", func(t *testing.T) {
+		store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+		for id, e := range scanEntries {
+			store.scanEntries[id] = e
+		}
+		c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Stop() // nolint
+		var got []string
+		err := c.StreamEntries(ScanCronType, time.Time{}, 2, func(entries []CronEntry) error {
+			for _, e := range entries {
+				got = append(got, e.GetID())
+			}
+			if len(got) == 2 {
+				return c.RemoveEntry(ScanCronType, "3")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"1", "2", "4", "5"}, got); diff != "" {
+			t.Errorf("entries mismatch (-want +got):\n%s", diff)
+		}
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		c := newTestCrontinuous(Config{}, nil, scanEntries, nil, map[string]ReportEntry{})
+		errStop := errors.New("stop")
+		var calls int
+		err := c.StreamEntries(ScanCronType, time.Time{}, 2, func([]CronEntry) error {
+			calls++
+			return errStop
+		})
+		if err != errStop || calls != 1 {
+			t.Errorf("want error %v after 1 call, got %v after %d", errStop, err, calls)
+		}
+	})
+}
+
 func TestCrontinuous_BulkCreate(t *testing.T) {
 	type fields struct {
 		config          Config
@@ -436,7 +529,7 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 		inputReportEntries      []CronEntry
 		reportOverwriteSettings []bool
 		wantReportEntries       map[string]ReportEntry
-		wantJobs                []*cron.Entry
+		wantJobs                []ScheduledJob
 	}{
 		{
 			name: "HappyPath",
@@ -542,7 +635,7 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 					TeamID:   "reportOverwritable",
 				},
 			},
-			wantJobs: []*cron.Entry{
+			wantJobs: []ScheduledJob{
 				{
 					ID:       "scanScheduled",
 					Schedule: mustParseSchedule("*/2 * * * *"),
@@ -680,7 +773,7 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 					TeamID:   "reportOverwritable",
 				},
 			},
-			wantJobs: []*cron.Entry{
+			wantJobs: []ScheduledJob{
 				{
 					ID:       "scanScheduled",
 					Schedule: mustParseSchedule("*/2 * * * *"),
@@ -818,7 +911,7 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 					TeamID:   "reportOverwritable",
 				},
 			},
-			wantJobs: []*cron.Entry{
+			wantJobs: []ScheduledJob{
 				{
 					ID:       "scanScheduled",
 					Schedule: mustParseSchedule("*/2 * * * *"),
@@ -844,15 +937,9 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := &Crontinuous{
-				config:          tt.fields.config,
-				log:             logrus.New(),
-				scanCronStore:   tt.fields.scanCronStore,
-				scanEntries:     tt.fields.scanEntries,
-				reportCronStore: tt.fields.reportCronStore,
-				reportEntries:   tt.fields.reportEntries,
-				cron:            cron.New(),
-			}
+			c := newTestCrontinuous(tt.fields.config,
+				tt.fields.scanCronStore, tt.fields.scanEntries,
+				tt.fields.reportCronStore, tt.fields.reportEntries)
 
 			// Add initial entries to crontab so we verify
 			// later on that the correct entries are scheduled.
@@ -870,11 +957,11 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Error Scan BulkCreate: %v", err)
 			}
-			diff := cmp.Diff(c.scanEntries, tt.wantScanEntries)
+			diff := cmp.Diff(c.scans.entries, tt.wantScanEntries, ignoreUpdatedAtOption)
 			if diff != "" {
 				t.Fatalf("scan entries got!=want, diff %s", diff)
 			}
-			diff = cmp.Diff(mockCronStore.scanEntries, tt.wantScanEntries)
+			diff = cmp.Diff(mockCronStore.scanEntries, tt.wantScanEntries, ignoreUpdatedAtOption)
 			if diff != "" {
 				t.Fatalf("saved scan entries != want, diff %s", diff)
 			}
@@ -884,19 +971,19 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 			if err != nil {
 				t.Fatalf("Error Report BulkCreate: %v", err)
 			}
-			diff = cmp.Diff(c.reportEntries, tt.wantReportEntries)
+			diff = cmp.Diff(c.reports.entries, tt.wantReportEntries, ignoreUpdatedAtOption)
 			if diff != "" {
 				t.Fatalf("report entries got!=want, diff %s", diff)
 			}
-			diff = cmp.Diff(mockCronStore.reportEntries, tt.wantReportEntries)
+			diff = cmp.Diff(mockCronStore.reportEntries, tt.wantReportEntries, ignoreUpdatedAtOption)
 			if diff != "" {
 				t.Fatalf("saved report entries != want, diff %s", diff)
 			}
 
 			// Jobs
 			if tt.wantJobs != nil {
-				got := c.cron.Entries()
-				diff := cmp.Diff(got, tt.wantJobs, sortJobsSliceOption, cmpopts.IgnoreFields(cron.Entry{}, "Job"))
+				got := c.cron.Jobs()
+				diff := cmp.Diff(got, tt.wantJobs, sortJobsSliceOption, cmpopts.IgnoreFields(ScheduledJob{}, "Job"))
 				if diff != "" {
 					t.Errorf("jobs got!=want, diff %s", diff)
 				}
@@ -905,6 +992,657 @@ func TestCrontinuous_BulkCreate(t *testing.T) {
 	}
 }
 
+func TestCrontinuous_BulkCreateWithOverwrite(t *testing.T) {
+	existing := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}
+	updated := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * *"}
+	created := ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 4 * * *"}
+	tests := []struct {
+		name     string
+		policies []OverwritePolicy
+		wantErr  error
+		want     map[string]ScanEntry
+	}{
+		{
+			name:     "All",
+			policies: []OverwritePolicy{OverwriteAll, OverwriteAll},
+			want:     map[string]ScanEntry{"p1": updated, "p2": created},
+		},
+		{
+			name:     "MissingOnly",
+			policies: []OverwritePolicy{OverwriteMissingOnly, OverwriteMissingOnly},
+			want:     map[string]ScanEntry{"p1": existing, "p2": created},
+		},
+		{
+			name:     "None",
+			policies: []OverwritePolicy{OverwriteNone, OverwriteAll},
+			wantErr:  ErrEntryExists,
+			want:     map[string]ScanEntry{"p1": existing},
+		},
+		{
+			name:     "NotOnePerEntry",
+			policies: []OverwritePolicy{OverwriteAll},
+			wantErr:  ErrInvalidOverwrite,
+			want:     map[string]ScanEntry{"p1": existing},
+		},
+		{
+			name:     "Unknown",
+			policies: []OverwritePolicy{OverwriteAll, "some"},
+			wantErr:  ErrInvalidOverwrite,
+			want:     map[string]ScanEntry{"p1": existing},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockCronStore{scanEntries: map[string]ScanEntry{"p1": existing}}
+			c := newTestCrontinuous(Config{}, store, map[string]ScanEntry{"p1": existing}, &mockCronStore{}, nil)
+			err := c.BulkCreateWithOverwrite(ScanCronType, []CronEntry{updated, created}, tt.policies)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, c.scans.entries, ignoreUpdatedAtOption); diff != "" {
+				t.Errorf("scan entries got!=want, diff %s", diff)
+			}
+		})
+	}
+
+	// The overwrite settings of BulkCreate must be one per entry.
+	c := newTestCrontinuous(Config{}, &mockCronStore{}, nil, &mockCronStore{}, nil)
+	if err := c.BulkCreate(ScanCronType, []CronEntry{created}, nil); !errors.Is(err, ErrInvalidOverwrite) {
+		t.Errorf("want ErrInvalidOverwrite, got %v", err)
+	}
+}
+
+func TestCrontinuous_SaveEntryScheduleConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     CronType
+		entry   CronEntry
+		opts    []SaveOption
+		wantErr error
+	}{
+		{
+			name:    "ScanTooCloseToReport",
+			typ:     ScanCronType,
+			entry:   ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "10 8 * * *"},
+			wantErr: ErrScheduleConflict,
+		},
+		{
+			name:    "ReportTooCloseToScan",
+			typ:     ReportCronType,
+			entry:   ReportEntry{TeamID: "t1", CronSpec: "30 2 * * *"},
+			wantErr: ErrScheduleConflict,
+		},
+		{
+			name:  "ConflictIgnored",
+			typ:   ReportCronType,
+			entry: ReportEntry{TeamID: "t1", CronSpec: "30 2 * * *"},
+			opts:  []SaveOption{IgnoreScheduleConflicts()},
+		},
+		{
+			name:  "OtherTeam",
+			typ:   ScanCronType,
+			entry: ScanEntry{ProgramID: "p3", TeamID: "t2", CronSpec: "0 8 * * *"},
+		},
+		{
+			name:  "FarEnough",
+			typ:   ScanCronType,
+			entry: ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 5 * * *"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrontinuous(Config{ReportScanMinGap: time.Hour},
+				&mockCronStore{}, map[string]ScanEntry{
+					"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+				},
+				&mockCronStore{}, map[string]ReportEntry{
+					"t1": {TeamID: "t1", CronSpec: "0 8 * * *"},
+				})
+
+			err := c.SaveEntry(tt.typ, tt.entry, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_BulkScheduleConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		save    func(c *Crontinuous) error
+		wantErr error
+	}{
+		{
+			name: "Create",
+			save: func(c *Crontinuous) error {
+				return c.BulkCreate(ScanCronType, []CronEntry{
+					ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "10 8 * * *"},
+				}, []bool{false})
+			},
+			wantErr: ErrScheduleConflict,
+		},
+		{
+			name: "CreateIgnored",
+			save: func(c *Crontinuous) error {
+				return c.BulkCreate(ScanCronType, []CronEntry{
+					ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "10 8 * * *"},
+				}, []bool{false}, IgnoreScheduleConflicts())
+			},
+		},
+		{
+			name: "CreateKeepingExisting",
+			save: func(c *Crontinuous) error {
+				return c.BulkCreate(ScanCronType, []CronEntry{
+					ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "10 8 * * *"},
+				}, []bool{false})
+			},
+		},
+		{
+			name: "Overwrite",
+			save: func(c *Crontinuous) error {
+				return c.BulkCreate(ScanCronType, []CronEntry{
+					ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "10 8 * * *"},
+				}, []bool{true})
+			},
+			wantErr: ErrScheduleConflict,
+		},
+		{
+			name: "Replace",
+			save: func(c *Crontinuous) error {
+				_, err := c.BulkReplace(ReportCronType, []CronEntry{
+					ReportEntry{TeamID: "t1", CronSpec: "30 2 * * *"},
+				}, BulkModeReplaceTeam)
+				return err
+			},
+			wantErr: ErrScheduleConflict,
+		},
+		{
+			name: "ReplaceIgnored",
+			save: func(c *Crontinuous) error {
+				_, err := c.BulkReplace(ReportCronType, []CronEntry{
+					ReportEntry{TeamID: "t1", CronSpec: "30 2 * * *"},
+				}, BulkModeReplaceTeam, IgnoreScheduleConflicts())
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrontinuous(Config{ReportScanMinGap: time.Hour},
+				&mockCronStore{}, map[string]ScanEntry{
+					"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+				},
+				&mockCronStore{}, map[string]ReportEntry{
+					"t1": {TeamID: "t1", CronSpec: "0 8 * * *"},
+				})
+
+			if err := tt.save(c); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_ConcurrentScheduleConflict(t *testing.T) {
+	// The entries of other teams make the checks last enough to overlap.
+	scans := map[string]ScanEntry{}
+	reports := map[string]ReportEntry{}
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("other%d", i)
+		scans[id] = ScanEntry{ProgramID: id, TeamID: id, CronSpec: "0 8 * * *"}
+		reports[id] = ReportEntry{TeamID: id, CronSpec: "0 8 * * *"}
+	}
+	for i := 0; i < 20; i++ {
+		c := newTestCrontinuous(Config{ReportScanMinGap: time.Hour}, &mockCronStore{}, scans, &mockCronStore{}, reports)
+
+		// The scan and the report conflict with each other, so only one
+		// of them can be saved.
+		var (
+			wg   sync.WaitGroup
+			errs = make([]error, 2)
+		)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs[0] = c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 8 * * *"})
+		}()
+		go func() {
+			defer wg.Done()
+			errs[1] = c.SaveEntry(ReportCronType, ReportEntry{TeamID: "t1", CronSpec: "10 8 * * *"})
+		}()
+		wg.Wait()
+
+		if errs[0] == nil && errs[1] == nil {
+			t.Fatal("want one of the conflicting entries rejected, both saved")
+		}
+	}
+}
+
+func TestCrontinuous_Simulate(t *testing.T) {
+	cfg := Config{
+		EnableTeamsWhitelistScan: true,
+		TeamsWhitelistScan:       []string{"t1"},
+	}
+	c := newTestCrontinuous(cfg,
+		nil, map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 */12 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"},
+		},
+		nil, map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "30 6 * * *"},
+		})
+
+	from := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	got, err := c.Simulate(from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("Error simulating: %v", err)
+	}
+
+	want := []PlannedExecution{
+		{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Time: from.Add(12 * time.Hour)},
+		{Type: ReportCronType, EntryID: "t1", TeamID: "t1", Time: from.Add(6*time.Hour + 30*time.Minute)},
+		{Type: ScanCronType, EntryID: "p1", TeamID: "t1", Time: from.Add(24 * time.Hour)},
+	}
+	sort.SliceStable(want, func(i, j int) bool { return want[i].Time.Before(want[j].Time) })
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("executions got!=want, diff %s", diff)
+	}
+
+	if _, err := c.Simulate(from, from.Add(8*24*time.Hour)); err != ErrInvalidTimeWindow {
+		t.Errorf("expected ErrInvalidTimeWindow, got %v", err)
+	}
+}
+
+type blockingScanCreator struct {
+	started chan struct{}
+}
+
+func (b *blockingScanCreator) CreateScan(programID, teamID string) error {
+	return b.CreateScanContext(context.Background(), programID, teamID)
+}
+
+func (b *blockingScanCreator) CreateScanContext(ctx context.Context, programID, teamID string) error {
+	close(b.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type failingOnceCronStore struct {
+	mockCronStore
+	failed bool
+}
+
+func (s *failingOnceCronStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	if !s.failed {
+		s.failed = true
+		return errors.New("store unavailable")
+	}
+	return s.mockCronStore.SaveScanEntries(entries)
+}
+
+func TestCrontinuous_Stop(t *testing.T) {
+	creator := &blockingScanCreator{started: make(chan struct{})}
+	store := &failingOnceCronStore{
+		mockCronStore: mockCronStore{
+			scanEntries:   map[string]ScanEntry{},
+			reportEntries: map[string]ReportEntry{},
+		},
+	}
+	c := NewCrontinuous(Config{StopTimeout: 5 * time.Second}, logrus.New(), creator, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	if err := c.SaveEntry(ScanCronType, entry); err == nil {
+		t.Fatalf("expected error saving entry")
+	}
+
+	// Execute the job as the cron engine would do
+	// and wait until it is blocked creating the scan.
+	jobs := c.cron.Jobs()
+	if len(jobs) != 0 {
+		t.Fatalf("no job should be scheduled if saving fails, got %d", len(jobs))
+	}
+	job := &scanJob{programID: "p1", teamID: "t1", scanCreator: creator, log: logrus.NewEntry(logrus.New())}
+	c.scheduleJob(mustParseSchedule(entry.CronSpec), job, entry.ProgramID)
+	go c.cron.Jobs()[0].Job.Run()
+	<-creator.started
+
+	if err := c.Stop(); err != nil {
+		t.Fatalf("Error stopping crontinuous: %v", err)
+	}
+	if c.running.total() != 0 {
+		t.Errorf("jobs still running after stop")
+	}
+	want := map[string]ScanEntry{"p1": entry}
+	if diff := cmp.Diff(want, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("entries not flushed on stop, diff %s", diff)
+	}
+}
+
+func TestCrontinuous_Restart(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	engine := c.cron
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous twice: %v", err)
+	}
+	if c.cron != engine {
+		t.Fatalf("calling Start twice must not create a new cron")
+	}
+
+	// Modify the store behind crontinuous.
+	store.scanEntries = map[string]ScanEntry{
+		"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"},
+	}
+	if err := c.Restart(); err != nil {
+		t.Fatalf("Error restarting crontinuous: %v", err)
+	}
+
+	got, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CronEntry{ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries got!=want, diff %s", diff)
+	}
+	jobs := c.cron.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != "p2" {
+		t.Errorf("unexpected jobs after restart: %+v", jobs)
+	}
+}
+
+func TestCrontinuous_Reload(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 1 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1"}}
+	c := NewCrontinuous(cfg, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	store.scanEntries["p3"] = ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "0 2 * * *"}
+	cfg = Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t2"}, ScanCapacity: 10}
+	if err := c.Reload(cfg); err != nil {
+		t.Fatalf("Error reloading crontinuous: %v", err)
+	}
+
+	var got []string
+	for _, j := range c.cron.Jobs() {
+		got = append(got, j.ID)
+	}
+	if diff := cmp.Diff([]string{"p2"}, got); diff != "" {
+		t.Errorf("jobs got!=want, diff %s", diff)
+	}
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("want the entries reloaded from the store, got %+v", entries)
+	}
+	if s := c.Snapshot(); !s.ScanWhitelist.Enabled || len(s.ScanWhitelist.Rules) != 1 || s.ScanWhitelist.Rules[0] != "t2" {
+		t.Errorf("want the new whitelist applied, got %+v", s.ScanWhitelist)
+	}
+	if d, err := c.ScanDensity(); err != nil || d.Capacity != 10 {
+		t.Errorf("want the new scan capacity applied, got %+v, %v", d, err)
+	}
+}
+
+// hookedScanStore calls onGet the next time the scan entries are read, after
+// reading them.
+type hookedScanStore struct {
+	*mockCronStore
+	mux   sync.Mutex
+	onGet func()
+}
+
+func (s *hookedScanStore) GetScanEntries() (map[string]ScanEntry, error) {
+	s.mux.Lock()
+	onGet := s.onGet
+	s.onGet = nil
+	s.mux.Unlock()
+	entries, err := s.mockCronStore.GetScanEntries()
+	if onGet != nil {
+		onGet()
+	}
+	return entries, err
+}
+
+func TestCrontinuous_ReloadConcurrentSave(t *testing.T) {
+	store := &hookedScanStore{mockCronStore: &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	// The entry is saved while the reload is reading the store.
+	saved := make(chan error, 1)
+	store.onGet = func() {
+		go func() {
+			saved <- c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"})
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := c.Reload(Config{}); err != nil {
+		t.Fatalf("Error reloading crontinuous: %v", err)
+	}
+	if err := <-saved; err != nil {
+		t.Fatalf("Error saving entry: %v", err)
+	}
+
+	if _, err := c.GetEntryByID(ScanCronType, "p2"); err != nil {
+		t.Errorf("want the entry saved during the reload kept, got %v", err)
+	}
+	if _, ok := store.scanEntries["p2"]; !ok {
+		t.Errorf("want the entry saved during the reload stored, got %+v", store.scanEntries)
+	}
+	var jobs []string
+	for _, j := range c.cron.Jobs() {
+		jobs = append(jobs, j.ID)
+	}
+	sort.Strings(jobs)
+	if diff := cmp.Diff([]string{"p1", "p2"}, jobs); diff != "" {
+		t.Errorf("jobs got!=want, diff %s", diff)
+	}
+}
+
+func TestCrontinuous_LogState(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	var b strings.Builder
+	logger := logrus.New()
+	logger.Out = &b
+	c := NewCrontinuous(Config{}, logger, nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	c.LogState()
+	for _, want := range []string{`msg="State: entry"`, "id=p1", `msg="State: next execution"`, "job=p1", "cron_jobs=1"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("want %q in the state logged, got %s", want, b.String())
+		}
+	}
+}
+
+func TestCrontinuous_SaveEntryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     CronType
+		entry   CronEntry
+		wantErr error
+	}{
+		{
+			name:    "EntryOfOtherType",
+			typ:     ScanCronType,
+			entry:   ReportEntry{TeamID: "t1", CronSpec: "* * * * *"},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "MissingTeam",
+			typ:     ScanCronType,
+			entry:   ScanEntry{ProgramID: "p1", CronSpec: "* * * * *"},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "MalformedSpec",
+			typ:     ReportCronType,
+			entry:   ReportEntry{TeamID: "t1", CronSpec: "* *"},
+			wantErr: ErrMalformedSchedule,
+		},
+		{
+			name:    "ReservedID",
+			typ:     ScanCronType,
+			entry:   ScanEntry{ProgramID: "lookup", TeamID: "t1", CronSpec: "* * * * *"},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:  "Valid",
+			typ:   ReportCronType,
+			entry: ReportEntry{TeamID: "t1", CronSpec: "* * * * *"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrontinuous(Config{}, &mockCronStore{}, nil, &mockCronStore{}, nil)
+			if err := c.SaveEntry(tt.typ, tt.entry); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_Export(t *testing.T) {
+	cfg := Config{
+		EnableTeamsWhitelistScan: true,
+		TeamsWhitelistScan:       []string{"t1"},
+	}
+	c := newTestCrontinuous(cfg,
+		nil, map[string]ScanEntry{
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"},
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * *"},
+		},
+		nil, map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		})
+
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC) // Monday.
+	got, err := c.Export(now)
+	if err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	want := []ExportedEntry{
+		{Type: ScanCronType, ID: "p1", TeamID: "t1", CronSpec: "0 3 * * *", Spec: "0 3 * * *", Description: "Every day at 03:00", NextRun: now.Add(3 * time.Hour)},
+		{Type: ScanCronType, ID: "p2", TeamID: "t2", CronSpec: "0 * * * *", Spec: "0 * * * *", Description: "Every hour at minute 0"},
+		{Type: ReportCronType, ID: "t1", TeamID: "t1", CronSpec: "0 8 * * 1", Spec: "0 8 * * 1", Description: "Every Monday at 08:00", NextRun: now.Add(8 * time.Hour)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("exported entries got!=want, diff %s", diff)
+	}
+}
+
+func TestWriteCrontab(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	entries := []ExportedEntry{
+		{Type: ScanCronType, ID: "p1", TeamID: "t1", CronSpec: "H 3 * * *", Spec: "17 3 * * *", Description: "Every day at 03:17", NextRun: now},
+		{Type: ScanCronType, ID: "p2", TeamID: "t2", CronSpec: "0 * * * *", Spec: "0 * * * *", Description: "Every hour at minute 0"},
+		{Type: ReportCronType, ID: "t1", TeamID: "t1", CronSpec: "@every 1h", Spec: "@every 1h", Description: "Every 1h", NextRun: now},
+	}
+	var b strings.Builder
+	if err := WriteCrontab(&b, entries); err != nil {
+		t.Fatalf("Error writing crontab: %v", err)
+	}
+	want := "# Schedule of vulcan-crontinuous, times in " + time.Local.String() + ".\n" +
+		"\n# scan p1 of team t1: Every day at 03:17\n" +
+		"17 3 * * * scan p1\n" +
+		"\n# scan p2 of team t2: Every hour at minute 0\n" +
+		"# Not scheduled, team not whitelisted.\n" +
+		"# 0 * * * * scan p2\n" +
+		"\n# report t1 of team t1: Every 1h\n" +
+		"# Not expressible in crontab.\n" +
+		"# @every 1h report t1\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("crontab got!=want, diff %s", diff)
+	}
+}
+
+func TestCrontinuous_Coverage(t *testing.T) {
+	cfg := Config{
+		EnableTeamsWhitelistScan: true,
+		TeamsWhitelistScan:       []string{"t1", "t2"},
+	}
+	c := newTestCrontinuous(cfg,
+		nil, map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * *"},
+			"p3": {ProgramID: "p3", TeamID: "t3", CronSpec: "0 3 * * *"},
+		},
+		nil, map[string]ReportEntry{
+			"t2": {TeamID: "t2", CronSpec: "0 8 * * 1"},
+		})
+
+	got, err := c.Coverage([]string{"t1", "t2", "t3", "t3"})
+	if err != nil {
+		t.Fatalf("Error analysing coverage: %v", err)
+	}
+	want := CoverageReport{
+		Teams:          3,
+		WithoutScans:   []string{"t2", "t3"},
+		WithoutReports: []string{"t1", "t3"},
+		Uncovered:      []string{"t3"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("coverage got!=want, diff %s", diff)
+	}
+}
+
+// newTestCrontinuous returns a not started crontinuous holding the given
+// entries, if not nil, with an empty and stopped cron.
+func newTestCrontinuous(cfg Config,
+	scanCronStore ScanCronStore, scanEntries map[string]ScanEntry,
+	reportCronStore ReportCronStore, reportEntries map[string]ReportEntry) *Crontinuous {
+
+	c := NewCrontinuous(cfg, logrus.New(), nil, scanCronStore, nil, reportCronStore)
+	if scanEntries != nil {
+		c.scans.entries = scanEntries
+	}
+	if reportEntries != nil {
+		c.reports.entries = reportEntries
+	}
+	c.cron = NewForkScheduler()
+	return c
+}
+
 type voidCronJob struct{}
 
 func (j *voidCronJob) Run() {}
@@ -916,3 +1654,239 @@ func mustParseSchedule(cronexpr string) cron.Schedule {
 	}
 	return s
 }
+
+func TestCrontinuous_BulkReplace(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        BulkMode
+		entries     []CronEntry
+		opts        []SaveOption
+		want        map[string]ScanEntry
+		wantChanges int
+		wantErr     error
+	}{
+		{
+			name: "ReplaceTeam",
+			mode: BulkModeReplaceTeam,
+			entries: []CronEntry{
+				ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 2 1 *"},
+				ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			},
+			want: map[string]ScanEntry{
+				"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 2 1 *"},
+				"p3": {ProgramID: "p3", TeamID: "t2", CronSpec: "0 0 1 1 *"},
+				"p4": {ProgramID: "p4", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			},
+			wantChanges: 3,
+		},
+		{
+			name: "ReplaceTeamEmptied",
+			mode: BulkModeReplaceTeam,
+			opts: []SaveOption{ReplaceTeams("t2")},
+			want: map[string]ScanEntry{
+				"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+				"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			},
+			wantChanges: 1,
+		},
+		{
+			name:    "ReplaceTeamWithoutTeams",
+			mode:    BulkModeReplaceTeam,
+			wantErr: ErrInvalidBulkMode,
+		},
+		{
+			name: "Sync",
+			mode: BulkModeSync,
+			entries: []CronEntry{
+				ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			},
+			want: map[string]ScanEntry{
+				"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			},
+			wantChanges: 2,
+		},
+		{
+			name: "DuplicatedEntries",
+			mode: BulkModeSync,
+			entries: []CronEntry{
+				ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+				ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 2 1 *"},
+			},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "InvalidMode",
+			mode:    "invalid",
+			wantErr: ErrInvalidBulkMode,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockCronStore{
+				scanEntries: map[string]ScanEntry{
+					"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+					"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+					"p3": {ProgramID: "p3", TeamID: "t2", CronSpec: "0 0 1 1 *"},
+				},
+				reportEntries: map[string]ReportEntry{},
+			}
+			c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+			if err := c.Start(); err != nil {
+				t.Fatalf("Error starting crontinuous: %v", err)
+			}
+			defer c.Stop()
+
+			changes, err := c.BulkReplace(ScanCronType, tt.entries, tt.mode, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(changes) != tt.wantChanges {
+				t.Errorf("changes got %d, want %d: %+v", len(changes), tt.wantChanges, changes)
+			}
+			if diff := cmp.Diff(tt.want, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+				t.Errorf("stored entries got!=want, diff %s", diff)
+			}
+			var jobs []string
+			for _, j := range c.cron.Jobs() {
+				jobs = append(jobs, j.ID)
+			}
+			sort.Strings(jobs)
+			var wantJobs []string
+			for id := range tt.want {
+				wantJobs = append(wantJobs, id)
+			}
+			sort.Strings(wantJobs)
+			if diff := cmp.Diff(wantJobs, jobs); diff != "" {
+				t.Errorf("scheduled jobs got!=want, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_BulkReplaceMovedEntry(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	// p2 moves from t2, which is out of scope, to t1.
+	moved := ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	changes, err := c.BulkReplace(ScanCronType, []CronEntry{moved}, BulkModeReplaceTeam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Action: ChangeDelete, Type: ScanCronType, ID: "p1",
+			Current: ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}},
+		{Action: ChangeUpdate, Type: ScanCronType, ID: "p2",
+			Current: ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "0 0 1 1 *"}, Desired: moved},
+	}
+	if diff := cmp.Diff(want, changes, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("changes got!=want, diff %s", diff)
+	}
+}
+
+func TestCrontinuous_TransferScanEntry(t *testing.T) {
+	tests := []struct {
+		name          string
+		programID     string
+		teamID        string
+		opts          []SaveOption
+		wantErr       error
+		wantTeam      string
+		wantScheduled bool
+	}{
+		{
+			name:          "Transferred",
+			programID:     "p1",
+			teamID:        "t2",
+			wantTeam:      "t2",
+			wantScheduled: true,
+		},
+		{
+			name:      "NotWhitelistedTeam",
+			programID: "p1",
+			teamID:    "t3",
+			wantTeam:  "t3",
+		},
+		{
+			name:      "ConflictWithReport",
+			programID: "p1",
+			teamID:    "t4",
+			wantErr:   ErrScheduleConflict,
+			wantTeam:  "t1",
+			// The entry keeps its job.
+			wantScheduled: true,
+		},
+		{
+			name:          "ConflictIgnored",
+			programID:     "p1",
+			teamID:        "t4",
+			opts:          []SaveOption{IgnoreScheduleConflicts()},
+			wantTeam:      "t4",
+			wantScheduled: true,
+		},
+		{
+			name:      "NotFound",
+			programID: "p2",
+			teamID:    "t2",
+			wantErr:   ErrScheduleNotFound,
+		},
+		{
+			name:          "MissingTeam",
+			programID:     "p1",
+			wantErr:       ErrMalformedEntry,
+			wantTeam:      "t1",
+			wantScheduled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				ReportScanMinGap:         time.Hour,
+				EnableTeamsWhitelistScan: true,
+				TeamsWhitelistScan:       []string{"t1", "t2", "t4"},
+			}
+			store := &mockCronStore{}
+			c := newTestCrontinuous(cfg,
+				store, map[string]ScanEntry{
+					"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+				},
+				store, map[string]ReportEntry{
+					"t4": {TeamID: "t4", CronSpec: "30 2 * * *"},
+				})
+			c.scheduleJob(mustParseSchedule("0 2 * * *"), c.newScanJob(c.scans.entries["p1"]), "p1")
+			c.cron.Start()
+			defer c.cron.Stop()
+
+			_, err := c.TransferScanEntry(tt.programID, tt.teamID, tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransferScanEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantTeam == "" {
+				return
+			}
+			if got := store.scanEntries["p1"].TeamID; got != tt.wantTeam && tt.wantErr == nil {
+				t.Errorf("stored team got %q, want %q", got, tt.wantTeam)
+			}
+			if got := c.scans.entries["p1"].TeamID; got != tt.wantTeam {
+				t.Errorf("team got %q, want %q", got, tt.wantTeam)
+			}
+			jobs := c.cron.Jobs()
+			if scheduled := len(jobs) == 1 && jobs[0].ID == "p1"; scheduled != tt.wantScheduled {
+				t.Errorf("scheduled got %v, want %v", scheduled, tt.wantScheduled)
+			}
+		})
+	}
+}
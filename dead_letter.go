@@ -0,0 +1,251 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// deadLetterPublishTimeout is the maximum time spent publishing a dead
+// letter. The publication does not depend on the context of the job, which
+// is usually done when the scan creation fails.
+const deadLetterPublishTimeout = 30 * time.Second
+
+// DeadLetter defines a scan creation that failed after all its retries, with
+// the data needed to replay it.
+type DeadLetter struct {
+	// ID is unique for every dead letter.
+	ID string `json:"id" yaml:"id"`
+	// Execution is the ID of the execution of the job that failed, empty if
+	// the creation was not performed by a job.
+	Execution string `json:"execution,omitempty" yaml:"execution,omitempty"`
+	// Type and EntryID identify the entry whose job failed.
+	Type    CronType `json:"type" yaml:"type"`
+	EntryID string   `json:"entry_id" yaml:"entry_id"`
+	// Entry is the entry at the time of the failure, null if it does not
+	// exist anymore. It is encoded in YAML as a document, see MarshalYAML.
+	Entry json.RawMessage `json:"entry" yaml:"-"`
+	// Payload holds the parameters of the scan creation.
+	Payload ScanCreation `json:"payload" yaml:"payload"`
+	// Error is the error returned by the last try.
+	Error    string    `json:"error" yaml:"error"`
+	FailedAt time.Time `json:"failed_at" yaml:"failed_at"`
+	// Replay is set when the execution is replayed.
+	Replay *Replay `json:"replay,omitempty" yaml:"replay,omitempty"`
+}
+
+// yamlDeadLetter is the YAML encoding of a dead letter, with the entry as a
+// document instead of the bytes of its JSON encoding.
+type yamlDeadLetter struct {
+	plainDeadLetter `yaml:",inline"`
+	Entry           interface{} `yaml:"entry"`
+}
+
+type plainDeadLetter DeadLetter
+
+// MarshalYAML implements yaml.Marshaler.
+func (l DeadLetter) MarshalYAML() (interface{}, error) {
+	var entry interface{}
+	if len(l.Entry) > 0 {
+		if err := json.Unmarshal(l.Entry, &entry); err != nil {
+			return nil, err
+		}
+	}
+	return yamlDeadLetter{plainDeadLetter: plainDeadLetter(l), Entry: entry}, nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (l *DeadLetter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var y yamlDeadLetter
+	if err := unmarshal(&y); err != nil {
+		return err
+	}
+	*l = DeadLetter(y.plainDeadLetter)
+	if y.Entry == nil {
+		return nil
+	}
+	entry, err := json.Marshal(jsonValue(y.Entry))
+	if err != nil {
+		return err
+	}
+	l.Entry = entry
+	return nil
+}
+
+// jsonValue returns the given value decoded from YAML with its maps keyed by
+// strings, so it can be encoded in JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	}
+	return v
+}
+
+// ScanCreation defines the parameters of the creation of a scan.
+type ScanCreation struct {
+	ProgramID string `json:"program_id" yaml:"program_id"`
+	TeamID    string `json:"team_id" yaml:"team_id"`
+}
+
+// DeadLetterQueue defines the services needed to publish the scan creations
+// that failed.
+type DeadLetterQueue interface {
+	Publish(ctx context.Context, l DeadLetter) error
+}
+
+// WithDeadLetterQueue makes crontinuous publish in the given queue the scan
+// creations that fail after all their retries, so they can be replayed.
+func WithDeadLetterQueue(q DeadLetterQueue) Option {
+	return func(c *Crontinuous) {
+		c.deadLetters = q
+	}
+}
+
+// deadLetterScanCreator wraps the scan creator of crontinuous to publish the
+// scan creations that fail, both by the scan and the team scan jobs.
+type deadLetterScanCreator struct {
+	ScanCreator
+	c *Crontinuous
+}
+
+func (d *deadLetterScanCreator) CreateScan(programID, teamID string) error {
+	return d.CreateScanContext(context.Background(), programID, teamID)
+}
+
+func (d *deadLetterScanCreator) CreateScanContext(ctx context.Context, programID, teamID string) error {
+	err := createScan(ctx, d.ScanCreator, programID, teamID)
+	if err == nil {
+		return nil
+	}
+	l := DeadLetter{
+		ID:       NewRequestID(),
+		Type:     ScanCronType,
+		EntryID:  programID,
+		Payload:  ScanCreation{ProgramID: programID, TeamID: teamID},
+		Error:    err.Error(),
+		FailedAt: time.Now(),
+	}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		l.Execution, l.Type, l.EntryID = e.ID, e.Type, e.EntryID
+	}
+	d.c.publishDeadLetter(l)
+	return err
+}
+
+// publishDeadLetter publishes the given dead letter with the current state
+// of its entry. Errors are only logged, as the job already failed.
+func (c *Crontinuous) publishDeadLetter(l DeadLetter) {
+	l.Entry = json.RawMessage("null")
+	if e, err := c.GetEntryByID(l.Type, l.EntryID); err == nil {
+		if data, err := json.Marshal(e); err == nil {
+			l.Entry = data
+		}
+	}
+
+	c.failures.record(l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadLetterPublishTimeout)
+	defer cancel()
+	log := c.log.WithFields(logrus.Fields{
+		"dead_letter": l.ID,
+		"program":     l.Payload.ProgramID,
+		"team":        l.Payload.TeamID,
+	})
+	if err := c.deadLetters.Publish(ctx, l); err != nil {
+		c.deadLetterErrors.inc(l.Type)
+		log.WithError(err).Error("Error publishing dead letter of failed scan creation")
+		c.reportError(ErrorReport{
+			Err:  fmt.Errorf("publishing dead letter: %w", err),
+			Tags: map[string]string{"type": l.Type.String(), "entry": l.EntryID, "dead_letter": l.ID},
+		})
+		return
+	}
+	c.deadLettersPublished.inc(l.Type)
+	log.Info("Published dead letter of failed scan creation")
+}
+
+// SQSDeadLetterQueue publishes the dead letters as JSON messages in an SQS
+// queue.
+type SQSDeadLetterQueue struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+}
+
+// NewSQSDeadLetterQueue returns a queue publishing the
+// dead letters in the SQS queue with the given URL.
+func NewSQSDeadLetterQueue(client sqsiface.SQSAPI, queueURL string) *SQSDeadLetterQueue {
+	return &SQSDeadLetterQueue{client: client, queueURL: queueURL}
+}
+
+// Publish sends the given dead letter to the queue.
+func (q *SQSDeadLetterQueue) Publish(ctx context.Context, l DeadLetter) error {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(body)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(l.Type.String())},
+		},
+	})
+	return err
+}
+
+// S3DeadLetterQueue stores the dead letters as JSON objects under a prefix
+// of an S3 bucket, keyed by the time of the failure and their ID.
+type S3DeadLetterQueue struct {
+	client s3iface.S3API
+	bucket string
+	prefix string
+}
+
+// NewS3DeadLetterQueue returns a queue storing the dead letters
+// under the given prefix of the given bucket.
+func NewS3DeadLetterQueue(client s3iface.S3API, bucket, prefix string) *S3DeadLetterQueue {
+	return &S3DeadLetterQueue{client: client, bucket: bucket, prefix: strings.Trim(prefix, "/")}
+}
+
+// Publish writes the given dead letter to the bucket.
+func (q *S3DeadLetterQueue) Publish(ctx context.Context, l DeadLetter) error {
+	body, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(q.bucket),
+		Key:         aws.String(q.key(l)),
+		Body:        strings.NewReader(string(body)),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (q *S3DeadLetterQueue) key(l DeadLetter) string {
+	name := fmt.Sprintf("%s-%s.json", l.FailedAt.UTC().Format("20060102T150405Z"), l.ID)
+	return path.Join(q.prefix, name)
+}
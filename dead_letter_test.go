@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type chanDeadLetterQueue chan DeadLetter
+
+func (q chanDeadLetterQueue) Publish(ctx context.Context, l DeadLetter) error {
+	q <- l
+	return nil
+}
+
+func TestCrontinuous_DeadLetters(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	creator := &mockScanCreator{creator: func(string, string) error {
+		return errors.New("vulcan-api unavailable")
+	}}
+	q := make(chanDeadLetterQueue, 1)
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store, WithDeadLetterQueue(q))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	if err := c.SaveEntry(ScanCronType, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got DeadLetter
+	select {
+	case got = <-q:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead letter not published")
+	}
+	stored, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DeadLetter{
+		Type:    ScanCronType,
+		EntryID: "p1",
+		Entry:   data,
+		Payload: ScanCreation{ProgramID: "p1", TeamID: "t1"},
+		Error:   "vulcan-api unavailable",
+	}
+	if diff := cmp.Diff(want, got, cmpopts.IgnoreFields(DeadLetter{}, "ID", "Execution", "FailedAt")); diff != "" {
+		t.Errorf("dead letter diff: %s", diff)
+	}
+	if got.ID == "" || got.Execution == "" || got.FailedAt.IsZero() {
+		t.Errorf("want the dead letter identified, got %+v", got)
+	}
+
+	// The counter is increased after publishing.
+	deadline := time.Now().Add(5 * time.Second)
+	for c.deadLettersPublished.snapshot()[ScanCronType] != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("dead letter not counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type mockSQS struct {
+	sqsiface.SQSAPI
+	sent []*sqs.SendMessageInput
+}
+
+func (m *mockSQS) SendMessageWithContext(ctx aws.Context, in *sqs.SendMessageInput, opts ...request.Option) (*sqs.SendMessageOutput, error) {
+	m.sent = append(m.sent, in)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+// contextMockS3 adds to mockS3 the operations with context.
+type contextMockS3 struct {
+	*mockS3
+}
+
+func (m contextMockS3) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	return m.PutObject(in)
+}
+
+func TestDeadLetterQueues(t *testing.T) {
+	l := DeadLetter{
+		ID:       "l1",
+		Type:     ScanCronType,
+		EntryID:  "p1",
+		Entry:    json.RawMessage("null"),
+		Payload:  ScanCreation{ProgramID: "p1", TeamID: "t1"},
+		Error:    "failed",
+		FailedAt: time.Date(2020, 6, 1, 3, 15, 0, 0, time.UTC),
+	}
+
+	sqsClient := &mockSQS{}
+	if err := NewSQSDeadLetterQueue(sqsClient, "https://sqs.example.com/dlq").Publish(context.Background(), l); err != nil {
+		t.Fatal(err)
+	}
+	if len(sqsClient.sent) != 1 || aws.StringValue(sqsClient.sent[0].QueueUrl) != "https://sqs.example.com/dlq" {
+		t.Fatalf("want a message sent to the queue, got %v", sqsClient.sent)
+	}
+	var sent DeadLetter
+	if err := json.Unmarshal([]byte(aws.StringValue(sqsClient.sent[0].MessageBody)), &sent); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(l, sent); diff != "" {
+		t.Errorf("sent dead letter diff: %s", diff)
+	}
+
+	s3Client := contextMockS3{newMockS3()}
+	if err := NewS3DeadLetterQueue(s3Client, "bucket", "/dead-letters/").Publish(context.Background(), l); err != nil {
+		t.Fatal(err)
+	}
+	key := "dead-letters/20200601T031500Z-l1.json"
+	stored, ok := s3Client.objects[key]
+	if !ok {
+		t.Fatalf("want the dead letter stored in %s, got %v", key, s3Client.takePuts())
+	}
+	if !strings.Contains(string(stored), `"program_id":"p1"`) {
+		t.Errorf("unexpected stored dead letter %s", stored)
+	}
+}
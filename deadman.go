@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// pingTimeout is the maximum time to wait for
+// the response of a dead-man's switch.
+const pingTimeout = 10 * time.Second
+
+// validatePingURL returns ErrMalformedEntry if the
+// given dead-man's switch URL is not an HTTP(S) URL.
+func validatePingURL(pingURL string) error {
+	if pingURL == "" {
+		return nil
+	}
+	u, err := url.Parse(pingURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// pingJob wraps the job of an entry so the dead-man's switch of the entry,
+// e.g. a healthchecks.io check, is pinged after every successful execution.
+// If the executions stop succeeding the switch alerts, even if crontinuous
+// itself is down.
+type pingJob struct {
+	entryJob
+	url    string
+	client *http.Client
+	log    *logrus.Logger
+}
+
+func (j *pingJob) run(ctx context.Context) error {
+	if err := j.entryJob.run(ctx); err != nil {
+		return err
+	}
+	if err := j.ping(ctx); err != nil {
+		j.log.WithFields(executionFields(ctx)).WithFields(logrus.Fields{
+			"team": j.team(),
+			"type": j.cronType().String(),
+		}).WithError(err).Error("Error pinging dead-man's switch")
+	}
+	return nil
+}
+
+func (j *pingJob) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()            // nolint
+	io.Copy(ioutil.Discard, resp.Body) // nolint
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestPingJob(t *testing.T) {
+	var pings int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pings, 1)
+	}))
+	defer srv.Close()
+
+	var fail bool
+	creator := &mockScanCreator{creator: func(string, string) error {
+		if fail {
+			return errors.New("vulcan-api unavailable")
+		}
+		return nil
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, nil, nil, nil)
+	job := c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{PingURL: srv.URL}}))
+
+	job.Run()
+	if got := atomic.LoadInt32(&pings); got != 1 {
+		t.Errorf("pings after a successful execution got %d, want 1", got)
+	}
+
+	fail = true
+	job.Run()
+	if got := atomic.LoadInt32(&pings); got != 1 {
+		t.Errorf("pings after a failed execution got %d, want 1", got)
+	}
+}
+
+func TestValidatePingURL(t *testing.T) {
+	for _, u := range []string{"", "https://hc-ping.com/eb095278-f28d-448d-87fb-7b75c171a6aa"} {
+		if err := validatePingURL(u); err != nil {
+			t.Errorf("URL %q should be valid, got %v", u, err)
+		}
+	}
+	for _, u := range []string{"hc-ping.com/check", "ftp://example.com/check", "https://"} {
+		if err := validatePingURL(u); err != ErrMalformedEntry {
+			t.Errorf("URL %q should be invalid, got %v", u, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// derivedReportsWindow is the period the activations of the scan entries
+// are computed in to find the latest time of the day a team is scanned at.
+const derivedReportsWindow = 7 * 24 * time.Hour
+
+// ErrMalformedReportDelay indicates the delay of the derived report entries
+// is not greater than zero and lower than a day.
+var ErrMalformedReportDelay = errors.New("ErrorMalformedReportDelay")
+
+// DeriveReportEntries creates, for every team with scan or team scan
+// entries, a daily report entry firing the given delay after the latest time
+// of the day the entries of the team fire at during the next week, including
+// their jitter. For instance, the report of a team scanned at 02:00 with a
+// delay of 6h is sent at 08:00. The existing report entries are kept unless
+// overwrite is true, in which case only their spec is replaced and they are
+// detached from their template. The teams with many report entries are
+// skipped. The given options are applied to every entry saved. The changes
+// applied are returned, also the ones applied before an error, e.g.
+// ErrScheduleConflict if a report fires too close to the scans of its team.
+func (c *Crontinuous) DeriveReportEntries(delay time.Duration, overwrite bool, opts ...SaveOption) ([]Change, error) {
+	if delay <= 0 || delay >= 24*time.Hour {
+		return nil, ErrMalformedReportDelay
+	}
+	slots, err := c.latestScanSlots(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	teams := make([]string, 0, len(slots))
+	for team := range slots {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+
+	var changes []Change
+	for _, team := range teams {
+		slot := (slots[team] + delay) % (24 * time.Hour)
+		spec := fmt.Sprintf("%d %d * * *", int(slot.Minutes())%60, int(slot.Hours()))
+
+		entry := ReportEntry{TeamID: team, CronSpec: spec}
+		change := Change{Action: ChangeAdd, Type: ReportCronType, Desired: entry}
+		current := c.TeamReportEntries(team)
+		if len(current) > 1 {
+			// The teams with many report entries were scheduled
+			// on purpose.
+			continue
+		}
+		if len(current) == 1 {
+			if !overwrite || current[0].CronSpec == spec {
+				continue
+			}
+			entry = current[0]
+			entry.CronSpec, entry.Template = spec, ""
+			change = Change{Action: ChangeUpdate, Type: ReportCronType, Current: current[0], Desired: entry}
+		}
+		saveOpts := append(append([]SaveOption{}, opts...), SavedID(&change.ID))
+		if err := c.SaveEntry(ReportCronType, entry, saveOpts...); err != nil {
+			return changes, err
+		}
+		if change.ID != entry.GetID() {
+			// The ID was assigned by the ID strategy.
+			change.Desired = withID(entry, change.ID)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// latestScanSlots returns, for every team with scan or team scan entries
+// firing in the week after the given time, the latest time of the day, as
+// the hours and minutes since midnight, the entries of the team fire at.
+func (c *Crontinuous) latestScanSlots(from time.Time) (map[string]time.Duration, error) {
+	entries := c.scans.all()
+	if c.teamScansEnabled() {
+		entries = append(entries, c.teamScans.all()...)
+	}
+	slots := map[string]time.Duration{}
+	for _, e := range entries {
+		s, err := c.entrySchedule(e)
+		if err != nil {
+			return nil, fmt.Errorf("%s entry %q: %w", e.GetType(), e.GetID(), ErrMalformedSchedule)
+		}
+		jitter := time.Duration(entrySettings(e).Jitter)
+		for _, t := range activations(s, from, from.Add(derivedReportsWindow)) {
+			t = t.Add(jitter)
+			slot := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+			if latest, ok := slots[e.GetTeamID()]; !ok || slot > latest {
+				slots[e.GetTeamID()] = slot
+			}
+		}
+	}
+	return slots, nil
+}
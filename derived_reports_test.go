@@ -0,0 +1,96 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_DeriveReportEntries(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "30 1 * * 1"},
+			"p3": {ProgramID: "p3", TeamID: "t2", CronSpec: "0 20 * * *", EntrySettings: EntrySettings{Jitter: Duration(15 * time.Minute)}},
+			"p4": {ProgramID: "p4", TeamID: "t3", CronSpec: "0 3 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t3": {TeamID: "t3", CronSpec: "0 12 * * 1", EntrySettings: EntrySettings{PingURL: "https://example.com/ping"}},
+		},
+	}
+	teamScans := &mockTeamScanCronStore{entries: map[string]TeamScanEntry{
+		"t4": {TeamID: "t4", CronSpec: "45 4 * * *"},
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithTeamScans(programListerFunc(func(string) ([]string, error) { return nil, nil }), teamScans))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if _, err := c.DeriveReportEntries(0, false); err != ErrMalformedReportDelay {
+		t.Errorf("want ErrMalformedReportDelay, got %v", err)
+	}
+	if _, err := c.DeriveReportEntries(24*time.Hour, false); err != ErrMalformedReportDelay {
+		t.Errorf("want ErrMalformedReportDelay, got %v", err)
+	}
+
+	changes, err := c.DeriveReportEntries(6*time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"t1": "0 8 * * *",
+		"t2": "15 2 * * *",
+		"t3": "0 12 * * 1",
+		"t4": "45 10 * * *",
+	}
+	for team, spec := range want {
+		if got := store.reportEntries[team].CronSpec; got != spec {
+			t.Errorf("team %s: want report spec %q, got %q", team, spec, got)
+		}
+	}
+	if len(changes) != 3 || changes[0].ID != "t1" || changes[0].Action != ChangeAdd {
+		t.Errorf("want the reports of the teams without one created, got %+v", changes)
+	}
+
+	changes, err = c.DeriveReportEntries(6*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].ID != "t3" || changes[0].Action != ChangeUpdate {
+		t.Fatalf("want only the report of t3 updated, got %+v", changes)
+	}
+	if got := store.reportEntries["t3"]; got.CronSpec != "0 9 * * *" || got.PingURL == "" {
+		t.Errorf("want only the spec of the existing report replaced, got %+v", got)
+	}
+}
+
+func TestCrontinuous_DeriveReportEntriesConflict(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{ReportScanMinGap: 2 * time.Hour}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if _, err := c.DeriveReportEntries(time.Hour, false); !errors.Is(err, ErrScheduleConflict) {
+		t.Errorf("want ErrScheduleConflict, got %v", err)
+	}
+	if _, err := c.DeriveReportEntries(time.Hour, false, IgnoreScheduleConflicts()); err != nil {
+		t.Errorf("want the conflict ignored, got %v", err)
+	}
+	if got := store.reportEntries["t1"].CronSpec; got != "0 3 * * *" {
+		t.Errorf("want report spec %q, got %q", "0 3 * * *", got)
+	}
+}
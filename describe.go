@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var descriptorDescriptions = map[string]string{
+	"@yearly":   "Every year on January 1 at 00:00",
+	"@annually": "Every year on January 1 at 00:00",
+	"@monthly":  "Every month on day 1 at 00:00",
+	"@weekly":   "Every Sunday at 00:00",
+	"@daily":    "Every day at 00:00",
+	"@midnight": "Every day at 00:00",
+	"@hourly":   "Every hour at minute 0",
+}
+
+// DescribeCronSpec returns a human readable description of the given cron
+// spec. Specs too complex to be described are returned as they are.
+func DescribeCronSpec(spec string) string {
+	spec = strings.TrimSpace(spec)
+	if d, ok := descriptorDescriptions[spec]; ok {
+		return d
+	}
+	if strings.HasPrefix(spec, "@every ") {
+		return "Every " + strings.TrimPrefix(spec, "@every ")
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return spec
+	}
+	minute, hour, dom, month, dow := fields[0], fields[1], fields[2], fields[3], fields[4]
+	if month != "*" {
+		return spec
+	}
+
+	var when string
+	switch {
+	case minute == "*" && hour == "*":
+		when = "every minute"
+	case strings.HasPrefix(minute, "*/") && hour == "*":
+		when = fmt.Sprintf("every %s minutes", strings.TrimPrefix(minute, "*/"))
+	case isNumber(minute) && hour == "*":
+		when = fmt.Sprintf("every hour at minute %s", minute)
+	case isNumber(minute) && isNumber(hour):
+		m, _ := strconv.Atoi(minute)
+		h, _ := strconv.Atoi(hour)
+		when = fmt.Sprintf("at %02d:%02d", h, m)
+	default:
+		return spec
+	}
+
+	daily := strings.HasPrefix(when, "at ")
+	var description string
+	switch {
+	case dom == "*" && dow == "*":
+		description = when
+		if daily {
+			description = "every day " + when
+		}
+	case dom == "*":
+		names, ok := weekdayNames(dow)
+		if !ok {
+			return spec
+		}
+		description = when + " on " + names
+		if daily {
+			description = "every " + names + " " + when
+		}
+	case dow == "*" && isNumber(dom):
+		description = when + " on day " + dom + " of the month"
+		if daily {
+			description = "every month on day " + dom + " " + when
+		}
+	default:
+		return spec
+	}
+	return strings.ToUpper(description[:1]) + description[1:]
+}
+
+// weekdayNames returns the names of the days in a comma
+// separated list of days of the week.
+func weekdayNames(dow string) (string, bool) {
+	var names []string
+	for _, d := range strings.Split(dow, ",") {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 || n > 7 {
+			return "", false
+		}
+		names = append(names, time.Weekday(n%7).String())
+	}
+	return strings.Join(names, ", "), true
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
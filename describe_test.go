@@ -0,0 +1,33 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import "testing"
+
+func TestDescribeCronSpec(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{spec: "* * * * *", want: "Every minute"},
+		{spec: "*/15 * * * *", want: "Every 15 minutes"},
+		{spec: "15 * * * *", want: "Every hour at minute 15"},
+		{spec: "5 3 * * *", want: "Every day at 03:05"},
+		{spec: "0 8 * * 1,5", want: "Every Monday, Friday at 08:00"},
+		{spec: "0 * * * 0", want: "Every hour at minute 0 on Sunday"},
+		{spec: "30 22 1 * *", want: "Every month on day 1 at 22:30"},
+		{spec: "@weekly", want: "Every Sunday at 00:00"},
+		{spec: "@every 1h30m", want: "Every 1h30m"},
+		{spec: "0 8 * 1 *", want: "0 8 * 1 *"},
+		{spec: "0 8-18 * * *", want: "0 8-18 * * *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			if got := DescribeCronSpec(tt.spec); got != tt.want {
+				t.Errorf("DescribeCronSpec() got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
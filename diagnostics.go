@@ -0,0 +1,174 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// diagnosticsNextExecutions is the max number of upcoming
+// executions reported by Diagnostics.
+const diagnosticsNextExecutions = 20
+
+// Diagnostics defines runtime information about the process
+// and the cron engine.
+type Diagnostics struct {
+	Goroutines     int                `json:"goroutines"`
+	HeapAllocBytes uint64             `json:"heap_alloc_bytes"`
+	CronJobs       int                `json:"cron_jobs"`
+	RunningJobs    map[string]int     `json:"running_jobs"`
+	WaitingJobs    map[string]int     `json:"waiting_jobs"`
+	NextExecutions []ScheduledCronJob `json:"next_executions"`
+}
+
+// ScheduledCronJob defines a job scheduled in the cron engine.
+type ScheduledCronJob struct {
+	ID   string    `json:"id"`
+	Next time.Time `json:"next"`
+	Prev time.Time `json:"prev,omitempty"`
+}
+
+// Diagnostics returns information useful to diagnose the runtime
+// state of crontinuous, like job goroutines not finishing because
+// of endless retries.
+func (c *Crontinuous) Diagnostics() Diagnostics {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	d := Diagnostics{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		RunningJobs:    c.running.snapshot(),
+		WaitingJobs:    c.waiting.snapshot(),
+		NextExecutions: []ScheduledCronJob{},
+	}
+	if c.cron == nil {
+		return d
+	}
+
+	// Cron entries are returned sorted by next execution.
+	for _, e := range c.cron.Jobs() {
+		if e.ID == heartbeatJobID {
+			continue
+		}
+		d.CronJobs++
+		if len(d.NextExecutions) >= diagnosticsNextExecutions {
+			continue
+		}
+		d.NextExecutions = append(d.NextExecutions, ScheduledCronJob{
+			ID:   e.ID,
+			Next: e.Next,
+			Prev: e.Prev,
+		})
+	}
+	return d
+}
+
+// LogState writes to the log the entries, with their next execution, the
+// jobs running and waiting by type and the next executions of the cron
+// engine, so the state of a running instance can be inspected without the
+// API.
+func (c *Crontinuous) LogState() {
+	entries, err := c.Export(time.Now())
+	if err != nil {
+		c.log.WithError(err).Error("Error reading the entries to log the state")
+	}
+	for _, e := range entries {
+		fields := logrus.Fields{
+			"type": e.Type.String(),
+			"id":   e.ID,
+			"team": e.TeamID,
+			"spec": e.Spec,
+		}
+		if !e.NextRun.IsZero() {
+			fields["next"] = e.NextRun.Format(time.RFC3339)
+		}
+		c.log.WithFields(fields).Info("State: entry")
+	}
+
+	d := c.Diagnostics()
+	for _, next := range d.NextExecutions {
+		c.log.WithFields(logrus.Fields{
+			"job":  next.ID,
+			"next": next.Next.Format(time.RFC3339),
+		}).Info("State: next execution")
+	}
+	c.log.WithFields(logrus.Fields{
+		"entries":      len(entries),
+		"cron_jobs":    d.CronJobs,
+		"running_jobs": d.RunningJobs,
+		"waiting_jobs": d.WaitingJobs,
+	}).Info("State")
+}
+
+// runningJobs tracks the number of jobs being executed, or waiting to be
+// executed, by type.
+type runningJobs struct {
+	mux    sync.Mutex
+	counts map[CronType]int
+}
+
+func (r *runningJobs) add(typ CronType, delta int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.counts == nil {
+		r.counts = map[CronType]int{}
+	}
+	r.counts[typ] += delta
+}
+
+func (r *runningJobs) total() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var total int
+	for _, n := range r.counts {
+		total += n
+	}
+	return total
+}
+
+// wait waits until there are no jobs running or the timeout expires,
+// returning the number of jobs still running.
+func (r *runningJobs) wait(timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		n := r.total()
+		if n == 0 || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (r *runningJobs) snapshot() map[string]int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	counts := map[string]int{}
+	for typ, n := range r.counts {
+		counts[typ.String()] = n
+	}
+	return counts
+}
+
+// trackedJob wraps the job of an entry keeping track
+// of the number of jobs running.
+type trackedJob struct {
+	entryJob
+	running *runningJobs
+}
+
+func (j *trackedJob) run(ctx context.Context) error {
+	j.running.add(j.cronType(), 1)
+	defer j.running.add(j.cronType(), -1)
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Divergence defines the differences found by a check between the entries in
+// the stores and the ones in memory, e.g. because the stores were edited
+// directly. The changes are the ones needed to make the entries in memory
+// match the stores, so Current is the entry in memory and Desired the one in
+// the store.
+type Divergence struct {
+	CheckedAt time.Time `json:"checked_at" yaml:"checked_at"`
+	Changes   []Change  `json:"changes" yaml:"changes"`
+}
+
+// DivergenceNotifier defines the services needed to notify the divergences
+// between the stores and the entries in memory.
+type DivergenceNotifier interface {
+	NotifyDivergence(d Divergence) error
+}
+
+// WithDivergenceNotifier makes crontinuous notify the given notifier when a
+// check finds the stores diverged from the entries in memory. The same
+// divergence is only notified once.
+func WithDivergenceNotifier(n DivergenceNotifier) Option {
+	return func(c *Crontinuous) {
+		c.divergenceNotifier = n
+	}
+}
+
+// divergences holds the result of the last divergence check.
+type divergences struct {
+	mux  sync.Mutex
+	last *Divergence
+}
+
+// set records the result of a check and returns true if it found changes
+// different from the ones found by the previous check.
+func (d *divergences) set(div Divergence) bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	changed := len(div.Changes) > 0 && (d.last == nil || !reflect.DeepEqual(d.last.Changes, div.Changes))
+	d.last = &div
+	return changed
+}
+
+func (d *divergences) get() (Divergence, bool) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if d.last == nil {
+		return Divergence{}, false
+	}
+	return *d.last, true
+}
+
+// resolve discards the changes of the given type found by the last check, as
+// the store and the entries in memory match again, and returns how many
+// there were.
+func (d *divergences) resolve(typ CronType) int {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if d.last == nil {
+		return 0
+	}
+	changes := []Change{}
+	for _, ch := range d.last.Changes {
+		if ch.Type != typ {
+			changes = append(changes, ch)
+		}
+	}
+	resolved := len(d.last.Changes) - len(changes)
+	d.last = &Divergence{CheckedAt: d.last.CheckedAt, Changes: changes}
+	return resolved
+}
+
+// reset discards the result of the last check.
+func (d *divergences) reset() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.last = nil
+}
+
+// CheckDivergence reads the entries from the stores and compares them with
+// the ones in memory. The entries of the types whose last save to the store
+// failed are not compared, as they are expected to differ. If they diverge, a
+// warning is logged and the divergence is notified, unless it was already
+// found by the previous check. The divergence lasts until the entries are
+// read again from the stores, e.g. by Restart, or the entries in memory are
+// saved to them, overwriting the changes made in the stores.
+func (c *Crontinuous) CheckDivergence() (Divergence, error) {
+	dirty := map[CronType]bool{}
+	for _, typ := range c.dirtyTypes() {
+		dirty[typ] = true
+	}
+	d := Divergence{CheckedAt: time.Now(), Changes: []Change{}}
+	for _, typ := range c.cronTypes() {
+		if dirty[typ] {
+			continue
+		}
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return Divergence{}, err
+		}
+		changes, err := set.diverged()
+		if err != nil {
+			return Divergence{}, err
+		}
+		d.Changes = append(d.Changes, changes...)
+	}
+	if !c.divergence.set(d) {
+		return d, nil
+	}
+
+	c.log.WithField("changes", len(d.Changes)).Warn("Entries in the store diverged from the ones in memory")
+	if c.divergenceNotifier != nil {
+		if err := c.divergenceNotifier.NotifyDivergence(d); err != nil {
+			c.log.WithError(err).Error("Error notifying divergence")
+		}
+	}
+	return d, nil
+}
+
+// Divergence returns the result of the last divergence check, if any, without
+// the changes already resolved.
+func (c *Crontinuous) Divergence() (Divergence, bool) {
+	return c.divergence.get()
+}
+
+// resolveDivergence discards the divergence of the given type, as its
+// entries in memory were just saved to the store, logging a warning if
+// changes made in the store were overwritten.
+func (c *Crontinuous) resolveDivergence(typ CronType) {
+	if n := c.divergence.resolve(typ); n > 0 {
+		c.log.WithFields(logrus.Fields{
+			"type":    typ.String(),
+			"changes": n,
+		}).Warn("Overwrote the entries diverged in the store")
+	}
+}
+
+// DivergenceChecker periodically checks the stores of a crontinuous instance
+// did not diverge from the entries in memory.
+type DivergenceChecker struct {
+	c        *Crontinuous
+	interval time.Duration
+	log      *logrus.Logger
+}
+
+// NewDivergenceChecker returns a checker of the stores of the given
+// crontinuous instance that runs every given interval.
+func NewDivergenceChecker(c *Crontinuous, interval time.Duration, logger *logrus.Logger) *DivergenceChecker {
+	return &DivergenceChecker{c: c, interval: interval, log: logger}
+}
+
+// Run checks the divergence on every interval until the context is done.
+func (d *DivergenceChecker) Run(ctx context.Context) {
+	if d.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.c.CheckDivergence(); err != nil {
+			d.log.WithError(err).Error("Error checking divergence")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type divergenceRecorder struct {
+	notified []Divergence
+}
+
+func (r *divergenceRecorder) NotifyDivergence(d Divergence) error {
+	r.notified = append(r.notified, d)
+	return nil
+}
+
+func TestCrontinuous_CheckDivergence(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	notifier := &divergenceRecorder{}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithDivergenceNotifier(notifier))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	d, err := c.CheckDivergence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Changes) != 0 || len(notifier.notified) != 0 {
+		t.Fatalf("want no divergence, got %+v", d)
+	}
+
+	// The store is edited directly.
+	store.scanEntries = map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "30 1 * * *"},
+		"p3": {ProgramID: "p3", TeamID: "t1", CronSpec: "0 3 * * *"},
+	}
+	for i := 0; i < 2; i++ {
+		if d, err = c.CheckDivergence(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []ChangeAction{ChangeUpdate, ChangeDelete, ChangeAdd}
+	if len(d.Changes) != len(want) {
+		t.Fatalf("want %d changes, got %+v", len(want), d.Changes)
+	}
+	for i, ch := range d.Changes {
+		if ch.Action != want[i] || ch.Type != ScanCronType {
+			t.Errorf("change %d: want %s of a scan entry, got %+v", i, want[i], ch)
+		}
+	}
+	if len(notifier.notified) != 1 {
+		t.Errorf("want the divergence notified once, got %d", len(notifier.notified))
+	}
+
+	// Saving an entry overwrites the store and resolves the divergence.
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "0 4 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := c.Divergence()
+	if !ok || len(d.Changes) != 0 {
+		t.Errorf("want the divergence resolved, got %+v", d)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// heartbeatJobID is the ID of the job beating in the cron engine. It is
+	// not a valid entry ID, so it does not collide with the entry jobs.
+	heartbeatJobID = "crontinuous/heartbeat"
+
+	// engineStallBeats is the number of heartbeats an engine can miss before
+	// it is considered dead.
+	engineStallBeats = 3
+
+	// engineStopTimeout is the time waited for an engine replaced by the
+	// watchdog to stop before giving up on it.
+	engineStopTimeout = 10 * time.Second
+)
+
+// WithEngineWatchdog makes crontinuous schedule a heartbeat in the cron
+// engine every given interval and check it at the same interval. If the
+// engine misses three heartbeats, because its goroutine died or is stuck, it
+// is replaced with a new one scheduling the entries in memory. Every
+// replacement is logged as an error and counted in a metric.
+func WithEngineWatchdog(interval time.Duration) Option {
+	return func(c *Crontinuous) {
+		if interval > 0 {
+			c.watchdog = &engineWatchdog{interval: interval}
+		}
+	}
+}
+
+// engineWatchdog holds the last heartbeat of the cron engine.
+type engineWatchdog struct {
+	interval time.Duration
+
+	mux      sync.Mutex
+	beat     time.Time
+	restarts int
+}
+
+// Run implements the Job interface, so the watchdog is the heartbeat job.
+func (w *engineWatchdog) Run() {
+	w.reset(time.Now())
+}
+
+func (w *engineWatchdog) reset(t time.Time) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.beat = t
+}
+
+// stalled returns the last heartbeat if it is older than the given time
+// allows.
+func (w *engineWatchdog) stalled(now time.Time) (time.Time, bool) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.beat, now.Sub(w.beat) > engineStallBeats*w.interval
+}
+
+func (w *engineWatchdog) restarted() {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.restarts++
+}
+
+func (w *engineWatchdog) restartCount() int {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return w.restarts
+}
+
+// heartbeatSchedule activates the heartbeat job every interval.
+type heartbeatSchedule time.Duration
+
+func (s heartbeatSchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// scheduleHeartbeat schedules the heartbeat in the current cron engine. It
+// must be called before starting it.
+func (c *Crontinuous) scheduleHeartbeat() {
+	if c.watchdog == nil {
+		return
+	}
+	c.watchdog.reset(time.Now())
+	c.cron.Schedule(heartbeatSchedule(c.watchdog.interval), c.watchdog, heartbeatJobID)
+}
+
+// watchEngine checks the heartbeat of the cron engine on every interval of
+// the watchdog until the given context is done.
+func (c *Crontinuous) watchEngine(ctx context.Context) {
+	ticker := time.NewTicker(c.watchdog.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := c.checkEngine(now); err != nil {
+				c.log.WithError(err).Error("Error restarting the cron engine")
+			}
+		}
+	}
+}
+
+// checkEngine replaces the cron engine with a new one, built from the entries
+// in memory, if it missed too many heartbeats at the given time. It returns
+// true if the engine was replaced.
+func (c *Crontinuous) checkEngine(now time.Time) (bool, error) {
+	old, err := c.replaceStalledEngine(now)
+	if err != nil || old == nil {
+		return false, err
+	}
+	c.watchdog.restarted()
+
+	// A stuck engine may never stop, so it is not waited for longer than
+	// engineStopTimeout.
+	stopped := make(chan struct{})
+	go func() {
+		old.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(engineStopTimeout):
+		c.log.Error("The replaced cron engine did not stop, abandoning it")
+	}
+	return true, nil
+}
+
+// replaceStalledEngine replaces the cron engine if it missed too many
+// heartbeats at the given time and returns the replaced one, which must be
+// stopped by the caller, or nil if it was not replaced.
+func (c *Crontinuous) replaceStalledEngine(now time.Time) (Scheduler, error) {
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	if !c.started {
+		return nil, nil
+	}
+	beat, stalled := c.watchdog.stalled(now)
+	if !stalled {
+		return nil, nil
+	}
+	c.log.WithField("last_heartbeat", beat.Format(time.RFC3339)).
+		Error("Cron engine missed its heartbeats, restarting it")
+
+	var sets []entries
+	for _, typ := range c.cronTypes() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	// Lock all the entries while swapping the
+	// cron so no mutations happen meanwhile.
+	for _, set := range sets {
+		set.lock()
+	}
+	defer func() {
+		for i := len(sets) - 1; i >= 0; i-- {
+			sets[i].unlock()
+		}
+	}()
+	var cronSchedules []cronJobSchedule
+	for _, set := range sets {
+		schedules, err := set.jobSchedules()
+		if err != nil {
+			return nil, err
+		}
+		cronSchedules = append(cronSchedules, schedules...)
+	}
+	return c.replaceCron(cronSchedules), nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+// deadScheduler is a Scheduler whose goroutine died: it holds the jobs but
+// never runs them.
+type deadScheduler struct {
+	mux     sync.Mutex
+	jobs    map[string]ScheduledJob
+	stopped bool
+}
+
+func (s *deadScheduler) Schedule(sch Schedule, j Job, id string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.jobs[id] = ScheduledJob{ID: id, Schedule: sch, Job: j}
+}
+
+func (s *deadScheduler) RemoveJob(id string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	delete(s.jobs, id)
+}
+
+func (s *deadScheduler) Jobs() []ScheduledJob {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var jobs []ScheduledJob
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+func (s *deadScheduler) Start() {}
+
+func (s *deadScheduler) Stop() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.stopped = true
+}
+
+func TestCrontinuous_EngineWatchdog(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	var engines []*deadScheduler
+	newScheduler := func() Scheduler {
+		s := &deadScheduler{jobs: map[string]ScheduledJob{}}
+		engines = append(engines, s)
+		return s
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithScheduler(newScheduler), WithEngineWatchdog(time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if restarted, err := c.checkEngine(time.Now().Add(time.Hour)); err != nil || restarted {
+		t.Fatalf("want the engine kept while it is not stalled, got %v, %v", restarted, err)
+	}
+	// The entries in memory are rescheduled, not the ones in the store.
+	store.scanEntries = map[string]ScanEntry{}
+	restarted, err := c.checkEngine(time.Now().Add(4 * time.Hour))
+	if err != nil || !restarted {
+		t.Fatalf("want the stalled engine restarted, got %v, %v", restarted, err)
+	}
+	if len(engines) != 2 || !engines[0].stopped {
+		t.Fatalf("want the stalled engine stopped and replaced, got %d engines", len(engines))
+	}
+	var got []string
+	for _, j := range engines[1].Jobs() {
+		got = append(got, j.ID)
+	}
+	if diff := cmp.Diff([]string{heartbeatJobID, "p1"}, got); diff != "" {
+		t.Errorf("jobs of the new engine got!=want, diff %s", diff)
+	}
+	if d := c.Diagnostics(); d.CronJobs != 1 || d.NextExecutions[0].ID != "p1" {
+		t.Errorf("want the heartbeat hidden from the diagnostics, got %+v", d)
+	}
+
+	// The new engine beats, so it is not restarted again.
+	engines[1].jobs[heartbeatJobID].Job.Run()
+	if restarted, _ := c.checkEngine(time.Now().Add(time.Hour)); restarted {
+		t.Errorf("want the new engine kept")
+	}
+	var restarts float64
+	for _, m := range c.Metrics() {
+		if m.Name == "engine_restarts_total" {
+			restarts = m.Value
+		}
+	}
+	if restarts != 1 {
+		t.Errorf("want 1 engine restart counted, got %v", restarts)
+	}
+}
+
+func TestCrontinuous_EngineWatchdogHeartbeat(t *testing.T) {
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithScheduler(func() Scheduler { return NewInternalScheduler(logrus.New()) }),
+		WithEngineWatchdog(10*time.Millisecond))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	time.Sleep(100 * time.Millisecond)
+	if _, stalled := c.watchdog.stalled(time.Now()); stalled {
+		t.Errorf("want a running engine beating")
+	}
+	if n := c.watchdog.restartCount(); n != 0 {
+		t.Errorf("want no restarts of a running engine, got %d", n)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+)
+
+// ErrDuplicatedEntry indicates the entry has a different ID than an existing
+// entry that must be unique with it.
+var ErrDuplicatedEntry = errors.New("ErrorDuplicatedEntry")
+
+// SavedID makes SaveEntry store in the given string the ID of the entry
+// saved, which is assigned by crontinuous to the scan and report entries
+// saved without one.
+func SavedID(id *string) SaveOption {
+	return func(o *saveOptions) {
+		o.savedID = id
+	}
+}
+
+// entryIdentifier assigns the IDs of a batch of entries of a type with an ID
+// strategy, considering the entries stored when it is created and the ones
+// identified before in the batch.
+type entryIdentifier struct {
+	typ   CronType
+	key   func(CronEntry) string
+	newID func(CronEntry) string
+	// byKey holds the entry with every key and keys the key of every entry
+	// by its ID.
+	byKey map[string]CronEntry
+	keys  map[string]string
+	// desired holds the keys of the entries identified by identifyDesired.
+	desired map[string]bool
+}
+
+// newEntryIdentifier returns an identifier of the entries of the given type,
+// nil if the type has no ID strategy.
+func (c *Crontinuous) newEntryIdentifier(typ CronType) *entryIdentifier {
+	i := &entryIdentifier{
+		typ:   typ,
+		byKey: map[string]CronEntry{},
+		keys:  map[string]string{},
+	}
+	var current []CronEntry
+	// The keys are computed from the expanded specs, as the entries of
+	// the manifests are identified before their spec aliases are expanded.
+	switch {
+	case typ == ScanCronType && c.scanIDs != nil:
+		i.key = func(e CronEntry) string { return c.scanIDs.Key(c.expandSpecAlias(e).(ScanEntry)) }
+		i.newID = func(e CronEntry) string { return c.scanIDs.NewID(e.(ScanEntry)) }
+		current = c.scans.all()
+	case typ == ReportCronType && c.reportIDs != nil:
+		i.key = func(e CronEntry) string { return c.reportIDs.Key(c.expandSpecAlias(e).(ReportEntry)) }
+		i.newID = func(e CronEntry) string { return c.reportIDs.NewID(e.(ReportEntry)) }
+		current = c.reports.all()
+	default:
+		return nil
+	}
+	// The entries are sorted by ID, so, if many have the same key, e.g.
+	// after changing the strategy, the first one always gets the entries
+	// saved without ID.
+	for _, e := range current {
+		key := i.key(e)
+		if _, ok := i.byKey[key]; !ok {
+			i.byKey[key] = e
+		}
+		i.keys[e.GetID()] = key
+	}
+	return i
+}
+
+// identify returns the given entry with its ID assigned, or
+// ErrDuplicatedEntry if it has the key of an entry with another ID.
+func (i *entryIdentifier) identify(e CronEntry) (CronEntry, error) {
+	if i == nil || e.GetType() != i.typ {
+		return e, nil
+	}
+	key := i.key(e)
+	existing, exists := i.byKey[key]
+	switch {
+	case entryID(e) == "" && exists:
+		e = withID(e, entryID(existing))
+	case entryID(e) == "":
+		e = withID(e, i.newID(e))
+	case exists && existing.GetID() != e.GetID():
+		return nil, ErrDuplicatedEntry
+	}
+
+	// The entry may have changed its key.
+	if previous, ok := i.keys[e.GetID()]; ok && previous != key && i.byKey[previous].GetID() == e.GetID() {
+		delete(i.byKey, previous)
+	}
+	i.byKey[key] = e
+	i.keys[e.GetID()] = key
+	return e, nil
+}
+
+// identifyManifest returns the given manifest with the IDs of its scan and
+// report entries assigned. The manifest defines the complete set of entries,
+// so they only must be unique among them, and the ones without ID take the
+// ID of the current entry with the same key, if any.
+func (c *Crontinuous) identifyManifest(m Manifest) (Manifest, error) {
+	if current := c.newEntryIdentifier(ScanCronType); current != nil {
+		scans := make([]ScanEntry, 0, len(m.Scans))
+		for _, e := range m.Scans {
+			identified, err := current.identifyDesired(e)
+			if err != nil {
+				return Manifest{}, err
+			}
+			scans = append(scans, identified.(ScanEntry))
+		}
+		m.Scans = scans
+	}
+	if current := c.newEntryIdentifier(ReportCronType); current != nil {
+		reports := make([]ReportEntry, 0, len(m.Reports))
+		for _, e := range m.Reports {
+			identified, err := current.identifyDesired(e)
+			if err != nil {
+				return Manifest{}, err
+			}
+			reports = append(reports, identified.(ReportEntry))
+		}
+		m.Reports = reports
+	}
+	return m, nil
+}
+
+// identifyDesired returns the given entry of a complete set of entries with
+// its ID assigned, or ErrDuplicatedEntry if another entry of the set has its
+// key. Unlike identify, the entries of the set only must be unique among
+// them, so the current entries are only used to take the ID of the ones
+// without it.
+func (i *entryIdentifier) identifyDesired(e CronEntry) (CronEntry, error) {
+	key := i.key(e)
+	if i.desired == nil {
+		i.desired = map[string]bool{}
+	}
+	if i.desired[key] {
+		return nil, entryError(e, ErrDuplicatedEntry)
+	}
+	i.desired[key] = true
+	if entryID(e) != "" {
+		return e, nil
+	}
+	if existing, ok := i.byKey[key]; ok {
+		return withID(e, entryID(existing)), nil
+	}
+	return withID(e, i.newID(e)), nil
+}
+
+// entryID returns the ID assigned to the given entry, empty if it is
+// identified by its program or team ID.
+func entryID(e CronEntry) string {
+	switch e := e.(type) {
+	case ScanEntry:
+		return e.ID
+	case ReportEntry:
+		return e.ID
+	}
+	return ""
+}
+
+// withID returns the given entry with the given ID.
+func withID(e CronEntry, id string) CronEntry {
+	switch e := e.(type) {
+	case ScanEntry:
+		e.ID = id
+		return e
+	case ReportEntry:
+		e.ID = id
+		return e
+	}
+	return e
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxNameLength is the maximum number of characters of the name of an
+	// entry.
+	maxNameLength = 128
+
+	// maxDescriptionLength is the maximum number of characters of the
+	// description of an entry.
+	maxDescriptionLength = 1024
+)
+
+// validateName returns ErrMalformedEntry if the given name or description
+// of an entry are too long, or the name has control characters, e.g. line
+// breaks.
+func validateName(name, description string) error {
+	if utf8.RuneCountInString(name) > maxNameLength ||
+		utf8.RuneCountInString(description) > maxDescriptionLength {
+		return ErrMalformedEntry
+	}
+	if strings.IndexFunc(name, unicode.IsControl) != -1 {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// entryName returns the name and the description of the given entry.
+func entryName(e CronEntry) (string, string) {
+	s := entrySettings(e)
+	return s.Name, s.Description
+}
+
+// MatchEntry returns true if every word of the given query is contained, in
+// a case insensitive way, in the ID, the name or the description of the
+// given entry. Any entry matches an empty query.
+func MatchEntry(e CronEntry, q string) bool {
+	name, description := entryName(e)
+	text := strings.ToLower(strings.Join([]string{e.GetID(), name, description}, "\n"))
+	for _, word := range strings.Fields(strings.ToLower(q)) {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchEntries returns the entries of the given type matching the given
+// query, as MatchEntry does, sorted by ID.
+func (c *Crontinuous) SearchEntries(typ CronType, q string) ([]CronEntry, error) {
+	all, err := c.GetEntries(typ)
+	if err != nil {
+		return nil, err
+	}
+	entries := []CronEntry{}
+	for _, e := range all {
+		if MatchEntry(e, q) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
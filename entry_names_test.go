@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		wantErr     error
+	}{
+		{"Weekly PCI scan", "Scans the assets in scope of PCI DSS.\nOwned by security.", nil},
+		{"", "", nil},
+		{strings.Repeat("ñ", maxNameLength), "", nil},
+		{strings.Repeat("a", maxNameLength+1), "", ErrMalformedEntry},
+		{"Weekly\nPCI scan", "", ErrMalformedEntry},
+		{"", strings.Repeat("a", maxDescriptionLength+1), ErrMalformedEntry},
+	}
+	for _, tt := range tests {
+		if err := validateName(tt.name, tt.description); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%q: want error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestCrontinuous_SearchEntries(t *testing.T) {
+	store := &mockCronStore{}
+	c := newTestCrontinuous(Config{}, store, map[string]ScanEntry{
+		"p0": {ProgramID: "p0", TeamID: "t1", CronSpec: "0 1 * * *", EntrySettings: EntrySettings{Name: "Weekly PCI scan"}},
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *", EntrySettings: EntrySettings{Name: "Daily scan", Description: "Internet facing assets of PCI"}},
+		"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"},
+	}, store, nil)
+
+	tests := []struct {
+		q       string
+		wantIDs []string
+	}{
+		{"", []string{"p0", "p1", "p2"}},
+		{"pci", []string{"p0", "p1"}},
+		{"PCI weekly", []string{"p0"}},
+		{"  daily   PCI ", []string{"p1"}},
+		{"p2", []string{"p2"}},
+		{"monthly", nil},
+	}
+	for _, tt := range tests {
+		entries, err := c.SearchEntries(ScanCronType, tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range entries {
+			ids = append(ids, e.GetID())
+		}
+		if strings.Join(ids, ",") != strings.Join(tt.wantIDs, ",") {
+			t.Errorf("%q: want entries %v, got %v", tt.q, tt.wantIDs, ids)
+		}
+	}
+}
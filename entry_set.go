@@ -0,0 +1,780 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// entries is implemented by the entry sets of every cron type, so the
+// Crontinuous methods can operate on any of them without knowing the
+// concrete type of their entries.
+type entries interface {
+	build() (apply func(), schedules []cronJobSchedule, err error)
+	bulkCreate(prepare func() (map[string]cronEntryWithSchedule, error)) ([]cronJobSchedule, []Change, error)
+	bulkReplace(prepare func() ([]CronEntry, error), inScope func(CronEntry) bool) (previous []CronEntry, err error)
+	save(prepare func() (CronEntry, error)) (previous CronEntry, job entryJob, err error)
+	updateAll(fn func(CronEntry) (CronEntry, bool, error)) ([]Change, error)
+	all() []CronEntry
+	sorted() []CronEntry
+	ids() []string
+	get(ID string) (CronEntry, error)
+	lookup(IDs []string) (found []CronEntry, missing []string)
+	job(ID string) (entryJob, error)
+	remove(ID string) (CronEntry, error)
+	removeAll(IDs []string) (removed []CronEntry, missing []string, err error)
+	flush() error
+	write() error
+	sync() error
+	replace(entries []CronEntry) (previous []CronEntry, restore func() error, err error)
+	diverged() ([]Change, error)
+	jobSchedules() ([]cronJobSchedule, error)
+	lock()
+	unlock()
+}
+
+// entrySet holds the entries of a cron type, keeping the copy in memory in
+// sync with the store and the journal, and building the jobs that execute
+// them.
+//
+// The entries are copied on write: the map holding them is never modified
+// once set, the mutations replace it with an updated copy. The mutations are
+// serialized by the lock of the set, held while they are persisted to the
+// store, but the reads only hold entriesMux while taking the current map, so
+// they never wait for the store. The mutations that must keep invariants
+// among the entries, as the uniqueness of their keys, take a prepare function
+// called holding the lock, so the checks it performs against the current
+// entries still hold when the entries are saved.
+type entrySet[T CronEntry] struct {
+	c   *Crontinuous
+	typ CronType
+	mux sync.Mutex
+
+	entriesMux sync.RWMutex
+	entries    map[string]T
+
+	// load and store read and write the entries from the store.
+	load  func() (map[string]T, error)
+	store func(map[string]T) error
+	// newJob builds the job executing the given entry.
+	newJob func(T) entryJob
+}
+
+func newEntrySet[T CronEntry](c *Crontinuous, typ CronType,
+	load func() (map[string]T, error), store func(map[string]T) error,
+	newJob func(T) entryJob) *entrySet[T] {
+
+	return &entrySet[T]{
+		c:       c,
+		typ:     typ,
+		entries: make(map[string]T),
+		load:    load,
+		store:   store,
+		newJob:  newJob,
+	}
+}
+
+// build reads the entries from the store and returns the jobs to schedule for
+// them. The returned apply function replaces the current entries with the
+// ones read. Both must be called holding the lock of the set, so no mutation
+// is persisted between reading the store and applying the entries read.
+func (s *entrySet[T]) build() (func(), []cronJobSchedule, error) {
+	entries, err := s.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if entries, err = s.replay(entries); err != nil {
+		return nil, nil, err
+	}
+	if entries == nil {
+		entries = make(map[string]T)
+	}
+
+	schedules, err := s.schedulesOf(entries)
+	if err != nil {
+		// Abort start
+		// TODO: skip this entry and continue?
+		return nil, nil, err
+	}
+
+	apply := func() {
+		s.publish(entries)
+	}
+	return apply, schedules, nil
+}
+
+// current returns the current entries. The returned map must not be
+// modified.
+func (s *entrySet[T]) current() map[string]T {
+	s.entriesMux.RLock()
+	defer s.entriesMux.RUnlock()
+	return s.entries
+}
+
+// publish makes the given entries the current ones. It must be called
+// holding the lock of the set, and the given map must not be modified
+// afterwards.
+func (s *entrySet[T]) publish(entries map[string]T) {
+	s.entriesMux.Lock()
+	defer s.entriesMux.Unlock()
+	s.entries = entries
+}
+
+// clone returns a copy of the current entries to modify and publish.
+func (s *entrySet[T]) clone() map[string]T {
+	current := s.current()
+	entries := make(map[string]T, len(current))
+	for id, e := range current {
+		entries[id] = e
+	}
+	return entries
+}
+
+// jobSchedules returns the jobs to schedule for the current entries. It must
+// be called holding the lock of the set.
+func (s *entrySet[T]) jobSchedules() ([]cronJobSchedule, error) {
+	return s.schedulesOf(s.current())
+}
+
+func (s *entrySet[T]) schedulesOf(entries map[string]T) ([]cronJobSchedule, error) {
+	var (
+		scheduled []T
+		specs     []string
+	)
+	for _, e := range entries {
+		if !s.c.isTeamWhitelisted(s.typ, e.GetTeamID()) {
+			// If team is not whitelisted, return entry
+			// but do not build job to be scheduled.
+			continue
+		}
+		scheduled = append(scheduled, e)
+		specs = append(specs, entrySpec(e))
+	}
+	parsed, err := parseSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedules []cronJobSchedule
+	for i, e := range scheduled {
+		schedules = append(schedules, cronJobSchedule{
+			schedule: s.c.inTeamTimezone(e.GetTeamID(), parsed[i]),
+			job:      s.newJob(e),
+			id:       cronJobID(s.typ, e.GetID()),
+		})
+	}
+	return schedules, nil
+}
+
+// bulkCreate creates or updates, according to their overwrite policies, the
+// entries returned by prepare, which is called holding the lock of the set.
+// It returns the jobs to schedule and the changes performed.
+func (s *entrySet[T]) bulkCreate(prepare func() (map[string]cronEntryWithSchedule, error)) ([]cronJobSchedule, []Change, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, nil, err
+	}
+	scheduledEntries, err := prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Make deep copy of current jobs in order
+	// to make the operation atomic.
+	current := s.clone()
+
+	// Update the hash of entries and create required jobs to be scheduled.
+	scheduledJobs := []cronJobSchedule{}
+	records := []JournalRecord{}
+	changes := []Change{}
+	now := time.Now()
+	for _, e := range scheduledEntries {
+		entry, ok := e.entry.(T)
+		if !ok {
+			return nil, nil, ErrMalformedEntry
+		}
+
+		previous, ok := current[entry.GetID()]
+		if ok && e.overwrite == OverwriteNone {
+			return nil, nil, entryError(entry, ErrEntryExists)
+		}
+		if ok && e.overwrite != OverwriteAll {
+			continue
+		}
+		entry = stampEntry(current, entry, now)
+
+		record, err := newSaveRecord(s.typ, entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		records = append(records, record)
+		current[entry.GetID()] = entry
+		if !ok {
+			changes = append(changes, Change{Action: ChangeAdd, Type: s.typ, ID: entry.GetID(), Desired: entry})
+		} else if !sameEntry(previous, entry) {
+			changes = append(changes, Change{Action: ChangeUpdate, Type: s.typ, ID: entry.GetID(), Current: previous, Desired: entry})
+		}
+
+		if !s.c.isTeamWhitelisted(s.typ, entry.GetTeamID()) {
+			// If team is not whitelisted, do not
+			// return job to schedule.
+			continue
+		}
+
+		scheduledJobs = append(scheduledJobs, cronJobSchedule{
+			schedule: e.schedule,
+			job:      s.newJob(entry),
+			id:       cronJobID(s.typ, entry.GetID()),
+		})
+	}
+
+	if err := s.c.journalAppend(records...); err != nil {
+		return nil, nil, err
+	}
+
+	// Now it's safe to update all the entries and reschedule the jobs.
+	s.publish(current)
+	return scheduledJobs, changes, s.persist()
+}
+
+// bulkReplace makes the desired entries, returned by prepare, which is called
+// holding the lock of the set, the only ones in the scope defined by the
+// given function: the entries in scope that are not desired are removed and
+// the desired ones are created or updated. It returns the previous version of
+// every entry replaced: the ones that were in scope and the desired ones that
+// existed out of it, e.g. moved from a team out of scope.
+func (s *entrySet[T]) bulkReplace(prepare func() ([]CronEntry, error), inScope func(CronEntry) bool) ([]CronEntry, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, err
+	}
+	desired, err := prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := s.current()
+	current := make(map[string]T)
+	previous := []CronEntry{}
+	records := []JournalRecord{}
+	for id, e := range entries {
+		if !inScope(e) {
+			current[id] = e
+			continue
+		}
+		previous = append(previous, e)
+		records = append(records, newRemoveRecord(s.typ, id))
+	}
+	now := time.Now()
+	for _, e := range desired {
+		entry, ok := e.(T)
+		if !ok {
+			return nil, ErrMalformedEntry
+		}
+		if p, ok := entries[entry.GetID()]; ok && !inScope(p) {
+			previous = append(previous, p)
+		}
+		entry = stampEntry(entries, entry, now)
+		record, err := newSaveRecord(s.typ, entry)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		current[entry.GetID()] = entry
+	}
+
+	if err := s.c.journalAppend(records...); err != nil {
+		return nil, err
+	}
+	s.publish(current)
+	return previous, s.persist()
+}
+
+// save creates or updates the entry returned by prepare, which is called
+// holding the lock of the set, and returns the entry it replaced, nil if
+// there was none.
+func (s *entrySet[T]) save(prepare func() (CronEntry, error)) (CronEntry, entryJob, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, nil, err
+	}
+	e, err := prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, ok := e.(T)
+	if !ok {
+		return nil, nil, ErrMalformedEntry
+	}
+
+	entries := s.clone()
+	entry = stampEntry(entries, entry, time.Now())
+	record, err := newSaveRecord(s.typ, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = s.c.journalAppend(record); err != nil {
+		return nil, nil, err
+	}
+
+	var previous CronEntry
+	if p, ok := entries[entry.GetID()]; ok {
+		previous = p
+	}
+	entries[entry.GetID()] = entry
+	s.publish(entries)
+	if err = s.persist(); err != nil {
+		return nil, nil, err
+	}
+
+	if !s.c.isTeamWhitelisted(s.typ, entry.GetTeamID()) {
+		return previous, nil, errTeamNotWhitelisted
+	}
+	return previous, s.newJob(entry), nil
+}
+
+// update replaces the entry with the given ID with the result of applying
+// fn to it, so the change is based on the current version of the entry, and
+// persists it. It returns the previous entry, the updated one and its job,
+// nil if the team of the updated entry is not whitelisted.
+func (s *entrySet[T]) update(ID string, fn func(T) (T, error)) (T, T, entryJob, error) {
+	var zero T
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return zero, zero, nil, err
+	}
+
+	entries := s.clone()
+	previous, ok := entries[ID]
+	if !ok {
+		return zero, zero, nil, ErrScheduleNotFound
+	}
+	entry, err := fn(previous)
+	if err != nil {
+		return zero, zero, nil, err
+	}
+	if entry.GetID() != ID {
+		return zero, zero, nil, ErrMalformedEntry
+	}
+	entry = stampEntry(entries, entry, time.Now())
+
+	record, err := newSaveRecord(s.typ, entry)
+	if err != nil {
+		return zero, zero, nil, err
+	}
+	if err = s.c.journalAppend(record); err != nil {
+		return zero, zero, nil, err
+	}
+	entries[ID] = entry
+	s.publish(entries)
+	if err = s.persist(); err != nil {
+		return zero, zero, nil, err
+	}
+
+	if !s.c.isTeamWhitelisted(s.typ, entry.GetTeamID()) {
+		return previous, entry, nil, nil
+	}
+	return previous, entry, s.newJob(entry), nil
+}
+
+// updateAll replaces the entries for which fn returns true with the entry
+// returned by fn and persists them. It returns the changes performed.
+func (s *entrySet[T]) updateAll(fn func(CronEntry) (CronEntry, bool, error)) ([]Change, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, err
+	}
+
+	entries := s.current()
+	current := s.clone()
+	var (
+		records []JournalRecord
+		changes []Change
+		now     = time.Now()
+	)
+	for id, e := range entries {
+		updated, ok, err := fn(e)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		entry, isT := updated.(T)
+		if !isT || entry.GetID() != id {
+			return nil, ErrMalformedEntry
+		}
+		entry = stampEntry(entries, entry, now)
+		record, err := newSaveRecord(s.typ, entry)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+		current[id] = entry
+		changes = append(changes, Change{Action: ChangeUpdate, Type: s.typ, ID: id, Current: e, Desired: entry})
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	if err := s.c.journalAppend(records...); err != nil {
+		return nil, err
+	}
+	s.publish(current)
+	return changes, s.persist()
+}
+
+func (s *entrySet[T]) all() []CronEntry {
+	return s.sorted()
+}
+
+// sorted returns the entries sorted by ID. The entries of many sets are
+// consistent among them if it is called holding the lock of the sets.
+func (s *entrySet[T]) sorted() []CronEntry {
+	var entries = []CronEntry{}
+	for _, e := range s.current() {
+		entries = append(entries, e)
+	}
+	// The entries are sorted so the API responses are stable.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].GetID() < entries[j].GetID()
+	})
+	return entries
+}
+
+// ids returns the IDs of the entries sorted.
+func (s *entrySet[T]) ids() []string {
+	entries := s.current()
+	ids := make([]string, 0, len(entries))
+	for id := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *entrySet[T]) get(ID string) (CronEntry, error) {
+	e, ok := s.current()[ID]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return e, nil
+}
+
+// lookup returns the entries with the given IDs that exist
+// and the IDs of the ones that do not.
+func (s *entrySet[T]) lookup(IDs []string) ([]CronEntry, []string) {
+	entries := s.current()
+	found := []CronEntry{}
+	missing := []string{}
+	for _, ID := range IDs {
+		if e, ok := entries[ID]; ok {
+			found = append(found, e)
+		} else {
+			missing = append(missing, ID)
+		}
+	}
+	return found, missing
+}
+
+// job builds the job executing the entry with the given ID.
+func (s *entrySet[T]) job(ID string) (entryJob, error) {
+	e, ok := s.current()[ID]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return s.newJob(e), nil
+}
+
+// remove removes the entry with the given ID and returns it.
+func (s *entrySet[T]) remove(ID string) (CronEntry, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, err
+	}
+
+	entries := s.clone()
+	e, ok := entries[ID]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	if err := s.c.journalAppend(newRemoveRecord(s.typ, ID)); err != nil {
+		return nil, err
+	}
+	delete(entries, ID)
+	s.publish(entries)
+	return e, s.persist()
+}
+
+// removeAll removes the entries with the given IDs, persisting them once,
+// and returns the entries removed and the IDs not found. Nothing is
+// persisted if no entry is found.
+func (s *entrySet[T]) removeAll(IDs []string) ([]CronEntry, []string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.syncShard(); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		removed []CronEntry
+		missing []string
+		records []JournalRecord
+	)
+	current := s.current()
+	seen := map[string]bool{}
+	for _, ID := range IDs {
+		if seen[ID] {
+			continue
+		}
+		seen[ID] = true
+		e, ok := current[ID]
+		if !ok {
+			missing = append(missing, ID)
+			continue
+		}
+		removed = append(removed, e)
+		records = append(records, newRemoveRecord(s.typ, ID))
+	}
+	if len(removed) == 0 {
+		return nil, missing, nil
+	}
+	if err := s.c.journalAppend(records...); err != nil {
+		return nil, nil, err
+	}
+	entries := s.clone()
+	for _, e := range removed {
+		delete(entries, e.GetID())
+	}
+	s.publish(entries)
+	return removed, missing, s.persist()
+}
+
+// flush saves the current entries to the store.
+func (s *entrySet[T]) flush() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.write()
+}
+
+// replace sets the given entries as the current ones and saves them, even
+// with a save interval, so the failure to save them can be rolled back. It
+// returns the previous entries. The returned restore function, also returned
+// when replace fails, sets back and saves the previous entries. It must be
+// called holding the lock of the set.
+func (s *entrySet[T]) replace(entries []CronEntry) ([]CronEntry, func() error, error) {
+	previous := s.current()
+	replacement := make(map[string]T)
+	now := time.Now()
+	for _, e := range entries {
+		entry, ok := e.(T)
+		if !ok {
+			return nil, nil, ErrMalformedEntry
+		}
+		replacement[entry.GetID()] = stampEntry(previous, entry, now)
+	}
+
+	var previousEntries = []CronEntry{}
+	for _, e := range previous {
+		previousEntries = append(previousEntries, e)
+	}
+	restore := func() error {
+		s.c.version.inc()
+		s.publish(previous)
+		return s.write()
+	}
+
+	s.c.version.inc()
+	s.publish(replacement)
+	return previousEntries, restore, s.write()
+}
+
+// diverged reads the entries from the store and returns the changes needed
+// to make the current entries match them. The lock of the set is held while
+// the store is read, so no mutation is persisted meanwhile.
+func (s *entrySet[T]) diverged() ([]Change, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stored, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := s.current()
+	current := make([]CronEntry, 0, len(entries))
+	for _, e := range entries {
+		current = append(current, e)
+	}
+	desired := make([]CronEntry, 0, len(stored))
+	for _, e := range stored {
+		desired = append(desired, e)
+	}
+	return Diff(s.typ, current, desired), nil
+}
+
+// syncShard syncs the entries with the store before a mutation if the
+// instance is sharded, so the mutation does not overwrite in the store the
+// changes made through the other instances. It must be called holding the
+// lock of the set.
+func (s *entrySet[T]) syncShard() error {
+	if s.c.shard == nil {
+		return nil
+	}
+	return s.sync()
+}
+
+// sync makes the entries in the store the current ones and reschedules the
+// jobs of the entries that changed, so the changes made through other
+// instances sharing the store are scheduled. Nothing is done while there
+// are entries pending to be saved, as the store does not hold the current
+// entries. It must be called holding the lock of the set.
+func (s *entrySet[T]) sync() error {
+	for _, typ := range s.c.dirtyTypes() {
+		if typ == s.typ {
+			return nil
+		}
+	}
+	stored, err := s.load()
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		stored = make(map[string]T)
+	}
+
+	var current, desired []CronEntry
+	for _, e := range s.current() {
+		current = append(current, e)
+	}
+	for _, e := range stored {
+		desired = append(desired, e)
+	}
+	changes := Diff(s.typ, current, desired)
+	if len(changes) == 0 {
+		return nil
+	}
+	changed := make(map[string]T)
+	for _, ch := range changes {
+		if ch.Action != ChangeDelete {
+			changed[ch.ID] = stored[ch.ID]
+		}
+	}
+	schedules, err := s.schedulesOf(changed)
+	if err != nil {
+		return err
+	}
+
+	s.publish(stored)
+	s.c.version.inc()
+	// The entries in memory match the store again.
+	s.c.divergence.resolve(s.typ)
+	for _, ch := range changes {
+		s.c.cron.RemoveJob(cronJobID(s.typ, ch.ID))
+		if ch.Action == ChangeDelete {
+			s.c.results.forget(s.typ, ch.ID)
+		}
+	}
+	for _, cs := range schedules {
+		s.c.scheduleJob(cs.schedule, cs.job, cs.id)
+	}
+	s.c.log.WithField("changes", len(changes)).Infof("Synced %s entries from the store", s.typ)
+	return nil
+}
+
+// persist saves the current entries to the store or, with a save interval,
+// records they are pending to be saved. It must be called holding the lock
+// of the set.
+func (s *entrySet[T]) persist() error {
+	if err := s.c.checkWritable(); err != nil {
+		return err
+	}
+	s.c.version.inc()
+	if s.c.saveInterval > 0 {
+		s.c.markDirty(s.typ, true)
+		return nil
+	}
+	return s.write()
+}
+
+// write saves the current entries to the store, recording whether they are
+// pending to be persisted. A read-only replica never writes to the store, so
+// it does not overwrite the entries of the scheduling instance. It must be
+// called holding the lock of the set.
+func (s *entrySet[T]) write() error {
+	if err := s.c.checkWritable(); err != nil {
+		return err
+	}
+	err := s.store(s.current())
+	s.c.setDirty(s.typ, err)
+	if err != nil {
+		s.c.reportError(ErrorReport{
+			Err:  err,
+			Tags: map[string]string{"source": "store", "type": s.typ.String()},
+		})
+	}
+	if err == nil {
+		s.c.journalCommit(s.typ)
+		s.c.resolveDivergence(s.typ)
+	}
+	return err
+}
+
+// replay applies to the given entries the mutations recorded in the journal
+// that were not persisted to the store, and persists the result. A read-only
+// replica ignores the journal, as any record in it, e.g. left by the instance
+// before being demoted, is stale compared to the entries in the store.
+func (s *entrySet[T]) replay(entries map[string]T) (map[string]T, error) {
+	if s.c.readOnly {
+		return entries, nil
+	}
+	records, err := s.c.journalRecords(s.typ)
+	if err != nil || len(records) == 0 {
+		return entries, err
+	}
+
+	if entries == nil {
+		entries = make(map[string]T)
+	}
+	for _, r := range records {
+		switch r.Op {
+		case JournalOpSave:
+			e, err := UnmarshalEntry(s.typ, r.Entry)
+			if err != nil {
+				return nil, err
+			}
+			entry, ok := e.(T)
+			if !ok {
+				return nil, ErrMalformedEntry
+			}
+			entries[entry.GetID()] = entry
+		case JournalOpRemove:
+			delete(entries, r.ID)
+		}
+	}
+
+	s.c.log.WithField("records", len(records)).Warnf("Replayed %s entries journal", s.typ)
+	if err := s.store(entries); err != nil {
+		return nil, err
+	}
+	s.c.journalCommit(s.typ)
+	return entries, nil
+}
+
+func (s *entrySet[T]) lock() {
+	s.mux.Lock()
+}
+
+func (s *entrySet[T]) unlock() {
+	s.mux.Unlock()
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// slowScanStore blocks the saves of the scan entries until released.
+type slowScanStore struct {
+	*mockCronStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *slowScanStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.mockCronStore.SaveScanEntries(entries)
+}
+
+func TestEntrySet_ReadsDoNotWaitForStore(t *testing.T) {
+	store := &slowScanStore{
+		mockCronStore: &mockCronStore{
+			scanEntries:   map[string]ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}},
+			reportEntries: map[string]ReportEntry{},
+		},
+		saving:  make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	saved := make(chan error, 1)
+	go func() {
+		saved <- c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"}}, []bool{true})
+	}()
+	<-store.saving
+
+	read := make(chan []CronEntry)
+	go func() {
+		entries, _ := c.GetEntries(ScanCronType)
+		if _, err := c.GetEntryByID(ScanCronType, "p1"); err != nil {
+			t.Errorf("Error getting entry: %v", err)
+		}
+		read <- entries
+	}()
+	select {
+	case entries := <-read:
+		if len(entries) != 2 {
+			t.Errorf("want the entry being saved returned, got %v", entries)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the reads waited for the store")
+	}
+
+	close(store.release)
+	if err := <-saved; err != nil {
+		t.Fatal(err)
+	}
+	if len(store.scanEntries) != 2 {
+		t.Errorf("want 2 entries stored, got %v", store.scanEntries)
+	}
+}
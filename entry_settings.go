@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import "time"
+
+// EntrySettings defines the settings shared by every type of entry. It is
+// embedded in the entries, so its fields are encoded as fields of the entry.
+type EntrySettings struct {
+	// ExecutionTimeout overrides the global execution timeout of the jobs.
+	ExecutionTimeout Duration `json:"execution_timeout,omitempty" yaml:"execution_timeout,omitempty"`
+	// PingURL is the URL of a dead-man's switch requested after
+	// every successful execution.
+	PingURL string `json:"ping_url,omitempty" yaml:"ping_url,omitempty"`
+	// Alerting overrides the default alerting preferences of the entry.
+	Alerting *AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	// Jitter delays every execution by a duration lower than it.
+	Jitter Duration `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// Windows restricts the executions to the given time windows, written
+	// as the windows of the whitelist rules, e.g. 22:00-06:00, and
+	// WindowPolicy defines whether the activations out of them are skipped
+	// or deferred until the start of the next window.
+	Windows      []string     `json:"windows,omitempty" yaml:"windows,omitempty"`
+	WindowPolicy WindowPolicy `json:"window_policy,omitempty" yaml:"window_policy,omitempty"`
+	// Template is the name of the template the entry takes its schedule
+	// and presets from.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// SpecAlias is the configured alias the spec of the entry was given
+	// as, if any. It is set by crontinuous when the entry is saved.
+	SpecAlias string `json:"spec_alias,omitempty" yaml:"spec_alias,omitempty"`
+	// Name and Description are free-form texts identifying the entry for
+	// humans, e.g. "Weekly PCI scan". They can be searched with
+	// SearchEntries.
+	Name        string `json:"name,omitempty" yaml:"name,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Metadata holds free-form references of the entry, like ticket IDs
+	// or cost centers. It is stored and returned untouched.
+	Metadata map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	// UpdatedAt is the time the entry was last changed. It is set by
+	// crontinuous when the entry is saved.
+	UpdatedAt *time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+// validate returns an error if any of the settings is not valid.
+func (s EntrySettings) validate() error {
+	if err := validateExecutionTimeout(s.ExecutionTimeout); err != nil {
+		return err
+	}
+	if err := validatePingURL(s.PingURL); err != nil {
+		return err
+	}
+	if err := validateAlertSettings(s.Alerting); err != nil {
+		return err
+	}
+	if err := validateJitter(s.Jitter); err != nil {
+		return err
+	}
+	if err := validateWindows(s.Windows, s.WindowPolicy); err != nil {
+		return err
+	}
+	if err := validateName(s.Name, s.Description); err != nil {
+		return err
+	}
+	return validateMetadata(s.Metadata)
+}
+
+// entrySettings returns the settings of the given entry.
+func entrySettings(e CronEntry) EntrySettings {
+	switch e := e.(type) {
+	case ScanEntry:
+		return e.EntrySettings
+	case ReportEntry:
+		return e.EntrySettings
+	case TeamScanEntry:
+		return e.EntrySettings
+	case CommandEntry:
+		return e.EntrySettings
+	}
+	return EntrySettings{}
+}
+
+// withEntrySettings returns the given entry with the given settings.
+func withEntrySettings(e CronEntry, s EntrySettings) CronEntry {
+	switch e := e.(type) {
+	case ScanEntry:
+		e.EntrySettings = s
+		return e
+	case ReportEntry:
+		e.EntrySettings = s
+		return e
+	case TeamScanEntry:
+		e.EntrySettings = s
+		return e
+	case CommandEntry:
+		e.EntrySettings = s
+		return e
+	}
+	return e
+}
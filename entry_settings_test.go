@@ -0,0 +1,113 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v2"
+)
+
+func TestEntrySettings_Encoding(t *testing.T) {
+	updated := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	entry := ScanEntry{
+		ProgramID: "p1",
+		TeamID:    "t1",
+		CronSpec:  "0 0 * * *",
+		EntrySettings: EntrySettings{
+			ExecutionTimeout: Duration(time.Hour),
+			Jitter:           Duration(time.Minute),
+			Windows:          []string{"22:00-06:00"},
+			Name:             "Nightly",
+			Metadata:         map[string]string{"ticket": "SEC-1"},
+			UpdatedAt:        &updated,
+		},
+	}
+
+	content, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"program_id", "execution_timeout", "jitter", "windows", "name", "metadata", "updated_at"} {
+		if _, ok := fields[f]; !ok {
+			t.Errorf("JSON field %s not found in %s", f, content)
+		}
+	}
+	var got ScanEntry
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entry, got); diff != "" {
+		t.Errorf("JSON round trip (-want +got):\n%s", diff)
+	}
+
+	content, err = yaml.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlFields := map[string]interface{}{}
+	if err := yaml.Unmarshal(content, &yamlFields); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"program_id", "execution_timeout", "jitter", "windows", "name", "metadata", "updated_at"} {
+		if _, ok := yamlFields[f]; !ok {
+			t.Errorf("YAML field %s not found in %s", f, content)
+		}
+	}
+	got = ScanEntry{}
+	if err := yaml.Unmarshal(content, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entry, got); diff != "" {
+		t.Errorf("YAML round trip (-want +got):\n%s", diff)
+	}
+}
+
+func TestEntrySettings_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   CronEntry
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			entry: ReportEntry{TeamID: "t1", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Jitter: Duration(time.Minute)}},
+		},
+		{
+			name:    "scan",
+			entry:   ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Windows: []string{"25:00-26:00"}}},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "report",
+			entry:   ReportEntry{TeamID: "t1", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Windows: []string{"25:00-26:00"}}},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "team scan",
+			entry:   TeamScanEntry{TeamID: "t1", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Windows: []string{"25:00-26:00"}}},
+			wantErr: ErrMalformedEntry,
+		},
+		{
+			name:    "command",
+			entry:   CommandEntry{ID: "c1", Script: "run.sh", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Windows: []string{"25:00-26:00"}}},
+			wantErr: ErrMalformedEntry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.entry.Validate(); err != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
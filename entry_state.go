@@ -0,0 +1,77 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"time"
+)
+
+// EntryState defines the state of an entry at a given time: whether it is
+// scheduled, whether its executions are paused or quarantined, when it is
+// executed next and the result of its last execution.
+type EntryState struct {
+	ScheduleStatus `yaml:",inline"`
+	// Paused is true if the entry is scheduled but its executions are
+	// skipped at the moment, for the reason in PausedReason.
+	Paused       bool   `json:"paused" yaml:"paused"`
+	PausedReason string `json:"paused_reason,omitempty" yaml:"paused_reason,omitempty"`
+	// Quarantined is true if the entry is scheduled but its executions are
+	// skipped because the circuit of its team is open, see WithTeamCircuit,
+	// until QuarantinedUntil.
+	Quarantined      bool       `json:"quarantined" yaml:"quarantined"`
+	QuarantinedUntil *time.Time `json:"quarantined_until,omitempty" yaml:"quarantined_until,omitempty"`
+	// NextRun is the next execution of the entry in the next week,
+	// considering the whitelists, the feature flags and the pause windows,
+	// nil if none or if the entry is not scheduled.
+	NextRun *time.Time `json:"next_run,omitempty" yaml:"next_run,omitempty"`
+	// LastResult is the result of the last execution of the entry since
+	// the start, nil if it was not executed.
+	LastResult *ExecutionResult `json:"last_result,omitempty" yaml:"last_result,omitempty"`
+}
+
+// GetEntryState returns the entry of the given type with the given ID
+// together with its state. The state is computed for the returned version of
+// the entry, at the same time for all its fields, so it is consistent even if
+// the entry is being updated meanwhile.
+func (c *Crontinuous) GetEntryState(typ CronType, ID string) (CronEntry, EntryState, error) {
+	set, err := c.entrySet(typ)
+	if err != nil {
+		return nil, EntryState{}, err
+	}
+	entry, err := set.get(ID)
+	if err != nil {
+		return nil, EntryState{}, err
+	}
+
+	now := time.Now()
+	entriesOf := func(t CronType) ([]CronEntry, error) {
+		if t != typ {
+			return nil, nil
+		}
+		return []CronEntry{entry}, nil
+	}
+	executions, err := c.simulate(now, now.Add(MaxSimulationWindow), MaxSimulationWindow, entriesOf)
+	if err != nil {
+		return nil, EntryState{}, err
+	}
+
+	state := EntryState{ScheduleStatus: c.ScheduleStatus(typ, entry)}
+	if state.Scheduled && len(executions) > 0 {
+		state.NextRun = &executions[0].Time
+	}
+	if state.Scheduled {
+		teamID := entry.GetTeamID()
+		budget := c.budgets.usage(teamID, now)
+		state.PausedReason = c.pausedReason(typ, teamID, now, budget.Exhausted)
+		state.Paused = state.PausedReason != ""
+		if until, ok := c.quarantinedUntil(typ, teamID); ok {
+			state.Quarantined, state.QuarantinedUntil = true, &until
+		}
+	}
+	if r, ok := c.results.get(typ, ID); ok {
+		state.LastResult = &r
+	}
+	return entry, state, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_GetEntryState(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 3 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1"}}
+	creator := &mockScanCreator{creator: func(programID, teamID string) error { return nil }}
+	c := NewCrontinuous(cfg, logrus.New(), creator, store, nil, store, WithTeamCircuit(1, time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	waitLastResult(t, c, ScanCronType, "p1")
+
+	entry, state, err := c.GetEntryState(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.GetID() != "p1" || !state.Scheduled || state.Paused {
+		t.Errorf("want p1 scheduled, got %+v %+v", entry, state)
+	}
+	if state.NextRun == nil || state.NextRun.Hour() != 2 || !state.NextRun.After(time.Now()) {
+		t.Errorf("want the next run of p1 at 02:00, got %v", state.NextRun)
+	}
+	if state.LastResult == nil || !state.LastResult.Succeeded {
+		t.Errorf("want the successful execution of p1, got %+v", state.LastResult)
+	}
+
+	// The entries of teams not whitelisted are not scheduled.
+	_, state, err = c.GetEntryState(ScanCronType, "p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Scheduled || state.UnscheduledReason != UnscheduledTeamNotWhitelisted || state.NextRun != nil || state.LastResult != nil {
+		t.Errorf("want p2 not scheduled, got %+v", state)
+	}
+
+	// The entries of a team with the circuit open are quarantined.
+	if _, err := c.circuits.enter("t1"); err != nil {
+		t.Fatal(err)
+	}
+	c.circuits.failed("t1")
+	_, state, err = c.GetEntryState(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	until, _ := c.circuits.openUntil("t1")
+	if !state.Quarantined || state.QuarantinedUntil == nil || !state.QuarantinedUntil.Equal(until) || state.Paused {
+		t.Errorf("want p1 quarantined and not paused, got %+v", state)
+	}
+
+	if _, _, err := c.GetEntryState(ScanCronType, "unknown"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("want ErrScheduleNotFound, got %v", err)
+	}
+}
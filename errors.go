@@ -0,0 +1,72 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EntryError is returned when an entry can not be saved, wrapping the error
+// describing why, e.g. ErrMalformedSchedule, with the entry and, if known,
+// the field causing it. The wrapped error can be checked with errors.Is.
+type EntryError struct {
+	Type CronType
+	// ID is the ID of the entry, empty if it is a new entry whose ID is not
+	// assigned yet.
+	ID string
+	// Field is the name of the field causing the error, as in the JSON
+	// representation of the entry, empty if unknown.
+	Field string
+	Err   error
+}
+
+func (e *EntryError) Error() string {
+	msg := fmt.Sprintf("%v: %s entry", e.Err, e.Type)
+	if e.ID != "" {
+		msg += fmt.Sprintf(" %q", e.ID)
+	}
+	if e.Field != "" {
+		msg += fmt.Sprintf(", field %s", e.Field)
+	}
+	return msg
+}
+
+func (e *EntryError) Unwrap() error {
+	return e.Err
+}
+
+// entryErrorFields are the fields of the entries caused by the errors.
+var entryErrorFields = []struct {
+	err   error
+	field string
+}{
+	{ErrMalformedSchedule, "cron_spec"},
+	{ErrScheduleConflict, "cron_spec"},
+	{ErrSpecRuleViolation, "cron_spec"},
+	{ErrTemplateNotFound, "template"},
+	{ErrTemplatesDisabled, "template"},
+	{ErrMetadataSchemaViolation, "metadata"},
+	{ErrTeamNotFound, "team_id"},
+	{ErrProgramNotFound, "program_id"},
+	{ErrOutOfWindows, "windows"},
+}
+
+// entryError wraps the given error in an EntryError of the given entry,
+// unless it is nil or it already is an EntryError.
+func entryError(e CronEntry, err error) error {
+	var entryErr *EntryError
+	if err == nil || e == nil || errors.As(err, &entryErr) {
+		return err
+	}
+	wrapped := &EntryError{Type: e.GetType(), ID: e.GetID(), Err: err}
+	for _, f := range entryErrorFields {
+		if errors.Is(err, f.err) {
+			wrapped.Field = f.field
+			break
+		}
+	}
+	return wrapped
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEntryError(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     CronType
+		entry   CronEntry
+		wantErr error
+		want    EntryError
+		wantMsg string
+	}{
+		{
+			name:    "MalformedSchedule",
+			typ:     ScanCronType,
+			entry:   ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "* *"},
+			wantErr: ErrMalformedSchedule,
+			want:    EntryError{Type: ScanCronType, ID: "p1", Field: "cron_spec"},
+			wantMsg: `ErrorMalformedSchedule: scan entry "p1", field cron_spec`,
+		},
+		{
+			name:    "TemplatesDisabled",
+			typ:     ReportCronType,
+			entry:   ReportEntry{TeamID: "t1", EntrySettings: EntrySettings{Template: "nightly"}},
+			wantErr: ErrTemplatesDisabled,
+			want:    EntryError{Type: ReportCronType, ID: "t1", Field: "template"},
+			wantMsg: `ErrorTemplatesDisabled: report entry "t1", field template`,
+		},
+		{
+			name:    "MalformedEntry",
+			typ:     ReportCronType,
+			entry:   ReportEntry{CronSpec: "@daily"},
+			wantErr: ErrMalformedEntry,
+			want:    EntryError{Type: ReportCronType},
+			wantMsg: `ErrorMalformedEntry: report entry`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCrontinuous(Config{}, &mockCronStore{}, nil, &mockCronStore{}, nil)
+			err := c.SaveEntry(tt.typ, tt.entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			var got *EntryError
+			if !errors.As(err, &got) {
+				t.Fatalf("want an EntryError, got %T", err)
+			}
+			if got.Type != tt.want.Type || got.ID != tt.want.ID || got.Field != tt.want.Field {
+				t.Errorf("want entry error %+v, got %+v", tt.want, *got)
+			}
+			if err.Error() != tt.wantMsg {
+				t.Errorf("want message %q, got %q", tt.wantMsg, err.Error())
+			}
+		})
+	}
+
+	// The errors already wrapped are not wrapped again.
+	e := ScanEntry{ProgramID: "p1", TeamID: "t1"}
+	wrapped := entryError(e, ErrScheduleConflict)
+	if got := entryError(e, wrapped); got != wrapped {
+		t.Errorf("want the entry error as is, got %v", got)
+	}
+	if got := entryError(e, nil); got != nil {
+		t.Errorf("want no error, got %v", got)
+	}
+}
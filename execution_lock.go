@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// DefaultExecutionLockTTL is the time the execution locks are held when
+	// no TTL is configured.
+	DefaultExecutionLockTTL = 10 * time.Minute
+
+	// executionLockTimeout is the maximum time spent acquiring the lock of
+	// an execution.
+	executionLockTimeout = 10 * time.Second
+)
+
+// ExecutionLocker defines the services needed to prevent the same activation
+// of a job from being executed twice, e.g. by the instance being replaced
+// and the new one during a rolling deployment.
+type ExecutionLocker interface {
+	// Lock acquires the lock with the given key for the given time. It
+	// returns false if the lock is already held.
+	Lock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// WithExecutionLocks makes crontinuous acquire, before executing an
+// activation of a job, a lock keyed by the ID of the job and the minute of
+// the activation, and skip the activation if the lock is already held. The
+// locks expire after the given TTL, DefaultExecutionLockTTL if it is not
+// positive. The manual executions are not locked. If the lock can not be
+// acquired because of an error the activation is executed anyway, as a
+// missed scan is worse than a duplicated one.
+func WithExecutionLocks(l ExecutionLocker, ttl time.Duration) Option {
+	return func(c *Crontinuous) {
+		if ttl <= 0 {
+			ttl = DefaultExecutionLockTTL
+		}
+		c.locker = l
+		c.lockTTL = ttl
+	}
+}
+
+// lockedJob executes a job only if it acquires the lock of the activation.
+type lockedJob struct {
+	Job
+	id     string
+	locker ExecutionLocker
+	ttl    time.Duration
+	log    *logrus.Logger
+	// skipped, if not nil, is called with the error of the skipped
+	// activations.
+	skipped func(error)
+}
+
+func (j *lockedJob) Run() {
+	key := executionLockKey(j.id, time.Now())
+	ctx, cancel := context.WithTimeout(context.Background(), executionLockTimeout)
+	locked, err := j.locker.Lock(ctx, key, j.ttl)
+	cancel()
+	if err != nil {
+		j.log.WithError(err).WithField("lock", key).Error("Error acquiring execution lock, executing anyway")
+	} else if !locked {
+		j.log.WithField("lock", key).Info("Skipping job, activation already executed")
+		if j.skipped != nil {
+			j.skipped(skipJob(SkipExecutionLock, "activation already executed"))
+		}
+		return
+	}
+	j.Job.Run()
+}
+
+// executionLockKey returns the key of the lock of the activation at the
+// given time of the job with the given ID. The specs have a resolution of a
+// minute, so every activation is identified by its minute.
+func executionLockKey(jobID string, t time.Time) string {
+	return jobID + "@" + t.UTC().Truncate(time.Minute).Format(time.RFC3339)
+}
+
+// DynamoDBExecutionLocker implements ExecutionLocker storing the locks as
+// items of a DynamoDB table whose partition key is the string attribute
+// lock_key. The expiration of the locks is stored, in seconds since the
+// epoch, in the number attribute expires_at, which can be configured as
+// the TTL attribute of the table so the expired locks are deleted.
+type DynamoDBExecutionLocker struct {
+	client dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+// NewDynamoDBExecutionLocker creates a locker storing the locks in the given
+// DynamoDB table.
+func NewDynamoDBExecutionLocker(client dynamodbiface.DynamoDBAPI, table string) *DynamoDBExecutionLocker {
+	return &DynamoDBExecutionLocker{client: client, table: table}
+}
+
+// Lock creates the item of the lock, unless it exists and is not expired.
+func (l *DynamoDBExecutionLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	_, err := l.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"lock_key":   {S: aws.String(key)},
+			"expires_at": {N: aws.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(lock_key) OR expires_at < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
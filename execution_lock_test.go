@@ -0,0 +1,148 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// memLocker implements ExecutionLocker in memory.
+type memLocker struct {
+	mux   sync.Mutex
+	locks map[string]time.Time
+	err   error
+}
+
+func (l *memLocker) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.err != nil {
+		return false, l.err
+	}
+	if exp, ok := l.locks[key]; ok && exp.After(time.Now()) {
+		return false, nil
+	}
+	if l.locks == nil {
+		l.locks = map[string]time.Time{}
+	}
+	l.locks[key] = time.Now().Add(ttl)
+	return true, nil
+}
+
+type countingJob struct {
+	mux  sync.Mutex
+	runs int
+}
+
+func (j *countingJob) Run() {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	j.runs++
+}
+
+func TestLockedJob(t *testing.T) {
+	tests := []struct {
+		name     string
+		locker   *memLocker
+		wantRuns int
+	}{
+		{"locked", &memLocker{}, 1},
+		{"lock error", &memLocker{err: errors.New("unavailable")}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &countingJob{}
+			// The jobs of two instances fire the same activation.
+			for i := 0; i < 2; i++ {
+				lj := &lockedJob{Job: job, id: "p1", locker: tt.locker, ttl: time.Minute, log: logrus.New()}
+				lj.Run()
+			}
+			if job.runs != tt.wantRuns {
+				t.Errorf("want %d runs, got %d", tt.wantRuns, job.runs)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_ExecutionLocks(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithExecutionLocks(&memLocker{}, 0))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if c.lockTTL != DefaultExecutionLockTTL {
+		t.Errorf("want the default TTL, got %v", c.lockTTL)
+	}
+	jobs := c.cron.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("want 1 job scheduled, got %d", len(jobs))
+	}
+	if _, ok := jobs[0].Job.(*lockedJob); !ok {
+		t.Errorf("want the scheduled job locked, got %T", jobs[0].Job)
+	}
+}
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	input *dynamodb.PutItemInput
+	err   error
+}
+
+func (m *mockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.input = input
+	return &dynamodb.PutItemOutput{}, m.err
+}
+
+func TestDynamoDBExecutionLocker(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantLocked bool
+		wantErr    bool
+	}{
+		{"acquired", nil, true, false},
+		{"held", awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "held", nil), false, false},
+		{"error", errors.New("unavailable"), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDynamoDB{err: tt.err}
+			l := NewDynamoDBExecutionLocker(client, "locks")
+			key := executionLockKey("p1", time.Date(2020, 6, 1, 2, 0, 30, 0, time.UTC))
+			locked, err := l.Lock(context.Background(), key, time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if locked != tt.wantLocked {
+				t.Errorf("want locked %v, got %v", tt.wantLocked, locked)
+			}
+			if got := aws.StringValue(client.input.Item["lock_key"].S); got != "p1@2020-06-01T02:00:00Z" {
+				t.Errorf("unexpected lock key %q", got)
+			}
+			if aws.StringValue(client.input.TableName) != "locks" || client.input.ConditionExpression == nil {
+				t.Errorf("unexpected input %+v", client.input)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/manelmontilla/cron"
+)
+
+const (
+	// windowCheckHorizon and windowCheckActivations bound the activations
+	// of an entry checked when it is saved to find one inside its execution
+	// windows. The activations are more than the minutes of a week, so the
+	// entries firing every minute reach all the windows.
+	windowCheckHorizon     = 366 * 24 * time.Hour
+	windowCheckActivations = 20000
+)
+
+// ErrOutOfWindows indicates the cron spec of an entry whose activations out
+// of its execution windows are skipped never fires inside them, so the entry
+// would never be executed.
+var ErrOutOfWindows = errors.New("ErrorOutOfWindows")
+
+// WindowPolicy defines what happens to the activations of an entry out of
+// its execution windows.
+type WindowPolicy string
+
+const (
+	// WindowSkip skips the activations out of the windows. It is the
+	// default.
+	WindowSkip WindowPolicy = "skip"
+	// WindowDefer delays the activations out of the windows until the
+	// start of the next window.
+	WindowDefer WindowPolicy = "defer"
+)
+
+// validateWindows returns ErrMalformedEntry if any of the given execution
+// windows of an entry or its policy are not valid.
+func validateWindows(windows []string, policy WindowPolicy) error {
+	switch policy {
+	case "", WindowSkip, WindowDefer:
+	default:
+		return ErrMalformedEntry
+	}
+	if _, err := parseWindows(windows); err != nil {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// parseWindows parses the given execution windows of an entry, written as
+// the windows of the whitelist rules, e.g. mon-fri/22:00-06:00.
+func parseWindows(windows []string) ([]TimeWindow, error) {
+	var parsed []TimeWindow
+	for _, s := range windows {
+		w, err := parseTimeWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, w)
+	}
+	return parsed, nil
+}
+
+// entryWindows returns the execution windows of the given entry and the
+// policy applied to its activations out of them.
+func entryWindows(e CronEntry) ([]string, WindowPolicy) {
+	s := entrySettings(e)
+	return s.Windows, s.WindowPolicy
+}
+
+// inWindows returns true if the given time is inside any of the given
+// windows.
+func inWindows(windows []TimeWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the first start of any of the given windows after
+// the given time, in its location. It returns the zero time if there are no
+// windows.
+func nextWindowStart(windows []TimeWindow, t time.Time) time.Time {
+	var next time.Time
+	for _, w := range windows {
+		from := w.From
+		if w.From == w.To {
+			// The window lasts the whole day.
+			from = 0
+		}
+		// A window starts at most once a day, so one of the next 8 days
+		// has the first start after t.
+		for d := 0; d <= 7; d++ {
+			start := time.Date(t.Year(), t.Month(), t.Day()+d, from/60, from%60, 0, 0, t.Location())
+			if !start.After(t) || !w.hasDay(start.Weekday()) {
+				continue
+			}
+			if next.IsZero() || start.Before(next) {
+				next = start
+			}
+			break
+		}
+	}
+	return next
+}
+
+// executionWindows holds the parsed execution windows of an entry.
+type executionWindows struct {
+	windows []TimeWindow
+	policy  WindowPolicy
+	// loc is the time zone the windows are evaluated in, the local one if
+	// nil.
+	loc *time.Location
+}
+
+// executionWindowsOf returns the given execution windows of an entry of the
+// given team, evaluated in the time zone of the team.
+func (c *Crontinuous) executionWindowsOf(teamID string, windows []string, policy WindowPolicy) executionWindows {
+	// The windows of the entries are validated when they are saved.
+	parsed, _ := parseWindows(windows)
+	loc, _ := c.teamDefaultsOf(teamID).location()
+	return executionWindows{windows: parsed, policy: policy, loc: loc}
+}
+
+// executionTime returns the time an activation at the given time is
+// executed at: the same time inside the windows, or the start of the next
+// window if the activations out of them are deferred. It returns false if
+// the activation is skipped.
+func (w executionWindows) executionTime(t time.Time) (time.Time, bool) {
+	if len(w.windows) == 0 {
+		return t, true
+	}
+	if w.loc != nil {
+		t = t.In(w.loc)
+	}
+	if inWindows(w.windows, t) {
+		return t, true
+	}
+	if w.policy != WindowDefer {
+		return time.Time{}, false
+	}
+	start := nextWindowStart(w.windows, t)
+	return start, !start.IsZero()
+}
+
+// checkWindows returns ErrOutOfWindows if the given entry skips the
+// activations out of its execution windows and the given schedule of the
+// entry never fires inside them in the next year.
+func (c *Crontinuous) checkWindows(e CronEntry, s cron.Schedule) error {
+	windows, policy := entryWindows(e)
+	w := c.executionWindowsOf(e.GetTeamID(), windows, policy)
+	if len(w.windows) == 0 || w.policy == WindowDefer {
+		return nil
+	}
+	now := time.Now()
+	t := now
+	for i := 0; i < windowCheckActivations; i++ {
+		t = s.Next(t)
+		if t.IsZero() || t.Sub(now) > windowCheckHorizon {
+			// The specs that don't fire in the horizon can not be
+			// checked.
+			if i == 0 {
+				return nil
+			}
+			return ErrOutOfWindows
+		}
+		if _, ok := w.executionTime(t); ok {
+			return nil
+		}
+	}
+	// The entry fires too often to check all its activations in the
+	// horizon.
+	return nil
+}
+
+// windowJob wraps the job of an entry so it is only executed inside the
+// execution windows of the entry. Depending on the policy of the entry, the
+// activations out of the windows are skipped or deferred until the start of
+// the next window.
+type windowJob struct {
+	entryJob
+	windows executionWindows
+	log     *logrus.Logger
+}
+
+func (j *windowJob) run(ctx context.Context) error {
+	now := time.Now()
+	at, ok := j.windows.executionTime(now)
+	log := j.log.WithFields(logrus.Fields{
+		"type":     j.cronType().String(),
+		"entry_id": j.entryID(),
+	})
+	if !ok {
+		log.Info("Skipping job, out of the execution windows of the entry")
+		return skipJob(SkipExecutionWindow, "out of the execution windows of the entry")
+	}
+	if !at.After(now) {
+		return j.entryJob.run(ctx)
+	}
+	log.WithField("deferred_until", at).Info("Deferring job until the next execution window of the entry")
+	t := time.NewTimer(at.Sub(now))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestValidateWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []string
+		policy  WindowPolicy
+		wantErr error
+	}{
+		{"none", nil, "", nil},
+		{"hours", []string{"22:00-06:00"}, WindowDefer, nil},
+		{"days and hours", []string{"mon-fri/22:00-06:00", "sat-sun"}, WindowSkip, nil},
+		{"malformed hours", []string{"22:00"}, "", ErrMalformedEntry},
+		{"unknown day", []string{"mon-fry/22:00-06:00"}, "", ErrMalformedEntry},
+		{"unknown policy", []string{"22:00-06:00"}, "wait", ErrMalformedEntry},
+	}
+	for _, tt := range tests {
+		if err := validateWindows(tt.windows, tt.policy); err != tt.wantErr {
+			t.Errorf("%s: want error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestNextWindowStart(t *testing.T) {
+	// 2020-06-03 is a Wednesday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2020, 6, day, hour, min, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name    string
+		windows []string
+		t       time.Time
+		want    time.Time
+	}{
+		{"later today", []string{"22:00-06:00"}, at(3, 12, 0), at(3, 22, 0)},
+		{"tomorrow", []string{"01:00-05:00"}, at(3, 12, 0), at(4, 1, 0)},
+		{"next listed day", []string{"sat-sun/10:00-12:00"}, at(3, 12, 0), at(6, 10, 0)},
+		{"whole day", []string{"fri"}, at(3, 12, 0), at(5, 0, 0)},
+		{"first of many", []string{"sat", "20:00-21:00"}, at(3, 12, 0), at(3, 20, 0)},
+		{"same day next week", []string{"wed/10:00-11:00"}, at(3, 12, 0), at(10, 10, 0)},
+	}
+	for _, tt := range tests {
+		windows, err := parseWindows(tt.windows)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := nextWindowStart(windows, tt.t); !got.Equal(tt.want) {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+// windowFromNow returns a window of an hour starting the given number of
+// hours after now.
+func windowFromNow(hours int) string {
+	from := time.Now().Add(time.Duration(hours) * time.Hour)
+	return from.Format("15:04") + "-" + from.Add(time.Hour).Format("15:04")
+}
+
+func TestWindowJob(t *testing.T) {
+	outside, err := parseWindows([]string{windowFromNow(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inside, err := parseWindows([]string{windowFromNow(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newJob := func(windows []TimeWindow, policy WindowPolicy) (*windowJob, *int) {
+		calls := new(int)
+		creator := &mockScanCreator{creator: func(programID, teamID string) error {
+			*calls++
+			return nil
+		}}
+		job := &scanJob{id: "p1", programID: "p1", teamID: "t1", scanCreator: creator, log: logrus.New().WithFields(nil)}
+		return &windowJob{entryJob: job, windows: executionWindows{windows: windows, policy: policy}, log: logrus.New()}, calls
+	}
+
+	job, calls := newJob(inside, WindowSkip)
+	if err := job.run(context.Background()); err != nil || *calls != 1 {
+		t.Errorf("want the job executed inside the window, got error %v and %d executions", err, *calls)
+	}
+
+	job, calls = newJob(outside, WindowSkip)
+	err = job.run(context.Background())
+	if _, reason := executionOutcome(err); reason != SkipExecutionWindow || *calls != 0 {
+		t.Errorf("want the job skipped out of the window, got error %v and %d executions", err, *calls)
+	}
+
+	// The deferred jobs wait for the window until they are cancelled.
+	job, calls = newJob(outside, WindowDefer)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := job.run(ctx); !errors.Is(err, context.DeadlineExceeded) || *calls != 0 {
+		t.Errorf("want the job deferred out of the window, got error %v and %d executions", err, *calls)
+	}
+}
+
+func TestCrontinuous_ExecutionWindows(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		return nil
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The spec never fires inside the window, so the entry would never be
+	// executed.
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 12 * * *", EntrySettings: EntrySettings{Windows: []string{"10:00-11:00"}}}
+	if err := c.SaveEntry(ScanCronType, entry); !errors.Is(err, ErrOutOfWindows) {
+		t.Fatalf("want ErrOutOfWindows, got %v", err)
+	}
+	// Unless its activations are deferred.
+	entry.WindowPolicy = WindowDefer
+	if err := c.SaveEntry(ScanCronType, entry); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	planned, err := c.Simulate(now, now.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) == 0 {
+		t.Fatal("want the deferred executions simulated, got none")
+	}
+	for _, p := range planned {
+		if p.Time.Hour() != 10 || p.Time.Minute() != 0 {
+			t.Errorf("want the executions deferred to the start of the window, got %v", p.Time)
+		}
+	}
+
+	entry = ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "* * * * *", EntrySettings: EntrySettings{Windows: []string{windowFromNow(2)}}}
+	if err := c.SaveEntry(ScanCronType, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunEntry(ScanCronType, "p2"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		skipped, err := c.Executions(ExecutionFilter{Status: ExecutionSkipped, SkipReason: SkipExecutionWindow})
+		return err == nil && len(skipped) == 1
+	})
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxExecutionRecords is the maximum number of executions kept in the
+// execution log. The oldest ones are discarded first.
+const maxExecutionRecords = 5000
+
+// ErrMalformedExecutionFilter indicates the status or the skip reason to
+// filter the executions by are not valid.
+var ErrMalformedExecutionFilter = errors.New("ErrorMalformedExecutionFilter")
+
+// ExecutionStatus defines the outcome of an execution of an entry.
+type ExecutionStatus string
+
+const (
+	ExecutionSucceeded ExecutionStatus = "succeeded"
+	ExecutionFailed    ExecutionStatus = "failed"
+	// ExecutionSkipped is the status of the executions not performed by
+	// design, for the reason in their SkipReason.
+	ExecutionSkipped ExecutionStatus = "skipped"
+)
+
+var executionStatuses = map[ExecutionStatus]bool{
+	ExecutionSucceeded: true,
+	ExecutionFailed:    true,
+	ExecutionSkipped:   true,
+}
+
+// SkipReason defines why an execution of an entry was skipped.
+type SkipReason string
+
+const (
+	// SkipWhitelist is the reason of the executions of the teams out of
+	// their whitelist windows.
+	SkipWhitelist SkipReason = "whitelist"
+	// SkipPauseWindow is the reason of the executions inside a pause window.
+	SkipPauseWindow SkipReason = "pause-window"
+	// SkipFeatureFlag is the reason of the executions of the teams with the
+	// feature flag of the type of the entry disabled.
+	SkipFeatureFlag SkipReason = "feature-flag"
+	// SkipCircuitOpen is the reason of the executions rejected by the open
+	// circuit of their team.
+	SkipCircuitOpen SkipReason = "circuit-open"
+	// SkipConcurrency is the reason of the executions cancelled while
+	// waiting for a free slot of their type.
+	SkipConcurrency SkipReason = "concurrency"
+	// SkipWarmUp is the reason of the activations skipped during the
+	// warm-up.
+	SkipWarmUp SkipReason = "warm-up"
+	// SkipExecutionLock is the reason of the activations already executed
+	// by another instance.
+	SkipExecutionLock SkipReason = "execution-lock"
+	// SkipBudget is the reason of the executions of the teams that
+	// exhausted their daily runtime budget.
+	SkipBudget SkipReason = "budget"
+	// SkipOverlap is the reason of the activations of the entries whose
+	// team forbids overlapping executions while the previous execution of
+	// the entry is running.
+	SkipOverlap SkipReason = "overlap"
+	// SkipExecutionWindow is the reason of the activations of the entries
+	// out of their execution windows, when they are not deferred.
+	SkipExecutionWindow SkipReason = "execution-window"
+	// SkipSuppressed is the reason of the executions of the entries
+	// selected by an active suppression.
+	SkipSuppressed SkipReason = "suppressed"
+)
+
+var skipReasons = map[SkipReason]bool{
+	SkipWhitelist:       true,
+	SkipPauseWindow:     true,
+	SkipFeatureFlag:     true,
+	SkipCircuitOpen:     true,
+	SkipConcurrency:     true,
+	SkipWarmUp:          true,
+	SkipExecutionLock:   true,
+	SkipBudget:          true,
+	SkipOverlap:         true,
+	SkipExecutionWindow: true,
+	SkipSuppressed:      true,
+}
+
+// skipError is returned by the jobs not performed by design.
+type skipError struct {
+	reason SkipReason
+	err    error
+	detail string
+}
+
+func (e *skipError) Error() string {
+	if e.detail == "" {
+		return e.err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.err, e.detail)
+}
+
+func (e *skipError) Unwrap() error {
+	return e.err
+}
+
+// skipJob returns the error of a job skipped for the given reason.
+func skipJob(reason SkipReason, detail string) error {
+	return &skipError{reason: reason, err: errJobSkipped, detail: detail}
+}
+
+// executionOutcome returns the status, and the skip reason if it was
+// skipped, of an execution finished with the given error.
+func executionOutcome(err error) (ExecutionStatus, SkipReason) {
+	var skip *skipError
+	switch {
+	case err == nil:
+		return ExecutionSucceeded, ""
+	case errors.As(err, &skip):
+		return ExecutionSkipped, skip.reason
+	case err == ErrTeamCircuitOpen:
+		// The circuit was open before the request, so it was not sent.
+		return ExecutionSkipped, SkipCircuitOpen
+	case errors.Is(err, errJobSkipped):
+		return ExecutionSkipped, ""
+	}
+	return ExecutionFailed, ""
+}
+
+// ExecutionRecord defines an execution of an entry, including the ones
+// skipped.
+type ExecutionRecord struct {
+	// Execution is the ID of the execution, as logged.
+	Execution string          `json:"execution" yaml:"execution"`
+	Type      CronType        `json:"type" yaml:"type"`
+	EntryID   string          `json:"entry_id" yaml:"entry_id"`
+	TeamID    string          `json:"team_id" yaml:"team_id"`
+	StartedAt time.Time       `json:"started_at" yaml:"started_at"`
+	Duration  Duration        `json:"duration" yaml:"duration"`
+	Status    ExecutionStatus `json:"status" yaml:"status"`
+	// SkipReason is only set in the skipped executions.
+	SkipReason SkipReason `json:"skip_reason,omitempty" yaml:"skip_reason,omitempty"`
+	Error      string     `json:"error,omitempty" yaml:"error,omitempty"`
+	// ScanIDs and ReportID are the IDs of the scans created and of the job
+	// sending the report, when the scan creator or the report sender return
+	// them, see ResultScanCreator and ResultReportSender.
+	ScanIDs  []string `json:"scan_ids,omitempty" yaml:"scan_ids,omitempty"`
+	ReportID string   `json:"report_id,omitempty" yaml:"report_id,omitempty"`
+}
+
+// ExecutionFilter selects the executions returned by Executions. The zero
+// value of every field matches all the executions.
+type ExecutionFilter struct {
+	Types      []CronType
+	EntryID    string
+	TeamID     string
+	Status     ExecutionStatus
+	SkipReason SkipReason
+	// Since excludes the executions started before it.
+	Since time.Time
+	// ScanID and ReportID select the execution that created the given scan
+	// or sent the given report.
+	ScanID   string
+	ReportID string
+}
+
+func (f ExecutionFilter) validate() error {
+	if f.Status != "" && !executionStatuses[f.Status] {
+		return ErrMalformedExecutionFilter
+	}
+	if f.SkipReason != "" && !skipReasons[f.SkipReason] {
+		return ErrMalformedExecutionFilter
+	}
+	return nil
+}
+
+func (f ExecutionFilter) matches(r ExecutionRecord) bool {
+	if len(f.Types) > 0 {
+		var found bool
+		for _, t := range f.Types {
+			found = found || t == r.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case f.EntryID != "" && f.EntryID != r.EntryID,
+		f.TeamID != "" && f.TeamID != r.TeamID,
+		f.Status != "" && f.Status != r.Status,
+		f.SkipReason != "" && f.SkipReason != r.SkipReason,
+		f.ReportID != "" && f.ReportID != r.ReportID,
+		r.StartedAt.Before(f.Since):
+		return false
+	}
+	if f.ScanID != "" {
+		for _, id := range r.ScanIDs {
+			if id == f.ScanID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// executionLog holds the last executions of all the entries.
+type executionLog struct {
+	mux     sync.Mutex
+	records []ExecutionRecord
+}
+
+func (l *executionLog) record(r ExecutionRecord) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if len(l.records) >= maxExecutionRecords {
+		l.records = l.records[1:]
+	}
+	l.records = append(l.records, r)
+}
+
+// skipped records an activation of the given job skipped with the given
+// error before executing it.
+func (l *executionLog) skipped(job entryJob, err error) {
+	r := ExecutionRecord{
+		Execution: NewRequestID(),
+		Type:      job.cronType(),
+		EntryID:   job.entryID(),
+		TeamID:    job.team(),
+		StartedAt: time.Now(),
+		Error:     err.Error(),
+	}
+	r.Status, r.SkipReason = executionOutcome(err)
+	l.record(r)
+}
+
+func (l *executionLog) find(f ExecutionFilter) []ExecutionRecord {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	records := []ExecutionRecord{}
+	for _, r := range l.records {
+		if f.matches(r) {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+// Executions returns, from the oldest to the newest, the executions of the
+// entries matching the given filter, including the skipped ones, so the
+// reason of a missing execution can be found. The executions are recorded in
+// memory, so only the ones since the start, up to the last 5000, are
+// returned. It returns ErrMalformedExecutionFilter if the status or the skip
+// reason of the filter are not valid.
+func (c *Crontinuous) Executions(f ExecutionFilter) ([]ExecutionRecord, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return c.executions.find(f), nil
+}
+
+// recordedJob wraps the job of an entry so the outcome of every execution,
+// including the skipped ones, is recorded in the execution log.
+type recordedJob struct {
+	entryJob
+	executions *executionLog
+}
+
+func (j *recordedJob) run(ctx context.Context) error {
+	id, ok := ctx.Value(executionIDKey{}).(string)
+	if !ok {
+		id = NewRequestID()
+		ctx = context.WithValue(ctx, executionIDKey{}, id)
+	}
+	r := ExecutionRecord{
+		Execution: id,
+		Type:      j.cronType(),
+		EntryID:   j.entryID(),
+		TeamID:    j.team(),
+		StartedAt: time.Now(),
+	}
+	out := &executionOutputs{}
+	err := j.entryJob.run(context.WithValue(ctx, executionOutputsKey{}, out))
+	r.Duration = Duration(time.Since(r.StartedAt))
+	r.Status, r.SkipReason = executionOutcome(err)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.ScanIDs, r.ReportID = out.get()
+	j.executions.record(r)
+	return err
+}
+
+// executionOutputs collects the IDs of the resources created by an
+// execution, as returned by the scan creator and the report sender.
+type executionOutputs struct {
+	mux      sync.Mutex
+	scanIDs  []string
+	reportID string
+}
+
+// executionOutputsKey carries in the context of a job the outputs of its
+// execution.
+type executionOutputsKey struct{}
+
+func (o *executionOutputs) get() ([]string, string) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return o.scanIDs, o.reportID
+}
+
+// recordScanID records the given ID of a scan created in the execution
+// carried by the given context, if any.
+func recordScanID(ctx context.Context, id string) {
+	o, ok := ctx.Value(executionOutputsKey{}).(*executionOutputs)
+	if !ok || id == "" {
+		return
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.scanIDs = append(o.scanIDs, id)
+}
+
+// recordReportID records the given ID of the job sending a report in the
+// execution carried by the given context, if any.
+func recordReportID(ctx context.Context, id string) {
+	o, ok := ctx.Value(executionOutputsKey{}).(*executionOutputs)
+	if !ok || id == "" {
+		return
+	}
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.reportID = id
+}
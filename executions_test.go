@@ -0,0 +1,146 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestExecutionOutcome(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus ExecutionStatus
+		wantReason SkipReason
+	}{
+		{"Succeeded", nil, ExecutionSucceeded, ""},
+		{"Failed", errors.New("vulcan-api unavailable"), ExecutionFailed, ""},
+		{"Skipped", skipJob(SkipWhitelist, "out of the window"), ExecutionSkipped, SkipWhitelist},
+		{"SkippedWithoutReason", errJobSkipped, ExecutionSkipped, ""},
+		{"CircuitOpen", ErrTeamCircuitOpen, ExecutionSkipped, SkipCircuitOpen},
+		{"CircuitTripped", fmt.Errorf("%w: timeout", ErrTeamCircuitOpen), ExecutionFailed, ""},
+		{"WaitingCancelled", &skipError{reason: SkipConcurrency, err: context.Canceled}, ExecutionSkipped, SkipConcurrency},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := executionOutcome(tt.err)
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Errorf("want %s %q, got %s %q", tt.wantStatus, tt.wantReason, status, reason)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_Executions(t *testing.T) {
+	var fail bool
+	creator := &mockScanCreator{creator: func(string, string) error {
+		if fail {
+			return errors.New("vulcan-api unavailable")
+		}
+		return nil
+	}}
+	pauses := &memPauseWindowStore{windows: map[string]PauseWindow{}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, nil, nil, nil, WithPauseWindows(pauses))
+	job := c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1"}))
+
+	job.Run()
+	fail = true
+	job.Run()
+	pauses.windows["freeze"] = PauseWindow{Name: "freeze", CronSpec: "* * * * *", Duration: Duration(time.Hour)}
+	c.pauseWindows = nil
+	job.Run()
+	c.executions.skipped(c.newScanJob(ScanEntry{ProgramID: "p2", TeamID: "t2"}),
+		skipJob(SkipWarmUp, "warming up after the start"))
+
+	all, err := c.Executions(ExecutionFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []ExecutionStatus
+	for _, e := range all {
+		got = append(got, e.Status)
+	}
+	want := []ExecutionStatus{ExecutionSucceeded, ExecutionFailed, ExecutionSkipped, ExecutionSkipped}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("want statuses %v, got %v", want, got)
+	}
+
+	skipped, err := c.Executions(ExecutionFilter{EntryID: "p1", Status: ExecutionSkipped})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0].SkipReason != SkipPauseWindow || skipped[0].TeamID != "t1" {
+		t.Errorf("want the execution skipped by the pause window, got %+v", skipped)
+	}
+	warming, err := c.Executions(ExecutionFilter{Types: []CronType{ScanCronType}, SkipReason: SkipWarmUp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warming) != 1 || warming[0].EntryID != "p2" {
+		t.Errorf("want the activation skipped during the warm-up, got %+v", warming)
+	}
+	future, err := c.Executions(ExecutionFilter{Since: time.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(future) != 0 {
+		t.Errorf("want no executions since a minute in the future, got %+v", future)
+	}
+
+	if _, err := c.Executions(ExecutionFilter{Status: "unknown"}); err != ErrMalformedExecutionFilter {
+		t.Errorf("want ErrMalformedExecutionFilter, got %v", err)
+	}
+	if _, err := c.Executions(ExecutionFilter{SkipReason: "unknown"}); err != ErrMalformedExecutionFilter {
+		t.Errorf("want ErrMalformedExecutionFilter, got %v", err)
+	}
+}
+
+type resultScanCreator struct {
+	mockScanCreator
+}
+
+func (r *resultScanCreator) CreateScanResult(ctx context.Context, programID, teamID string) (string, error) {
+	return "scan-" + programID, r.creator(programID, teamID)
+}
+
+type resultReportSender struct {
+	mockReportSender
+}
+
+func (r *resultReportSender) SendReportResult(ctx context.Context, teamID string) (string, error) {
+	return "digest-" + teamID, r.sender(teamID)
+}
+
+func TestCrontinuous_ExecutionOutputs(t *testing.T) {
+	creator := &resultScanCreator{mockScanCreator{creator: func(string, string) error { return nil }}}
+	sender := &resultReportSender{mockReportSender{sender: func(string) error { return nil }}}
+	// The IDs are recorded through the wrappers of the scan creator and the
+	// report sender.
+	c := NewCrontinuous(Config{}, logrus.New(), creator, nil, sender, nil, WithTeamCircuit(5, time.Minute), WithDeadLetterQueue(make(chanDeadLetterQueue, 1)))
+	c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1"})).Run()
+	c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p2", TeamID: "t1"})).Run()
+	c.wrapJob(c.newReportJob(ReportEntry{TeamID: "t1"})).Run()
+
+	scans, err := c.Executions(ExecutionFilter{ScanID: "scan-p2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 1 || scans[0].EntryID != "p2" || fmt.Sprint(scans[0].ScanIDs) != "[scan-p2]" {
+		t.Errorf("want the execution that created the scan, got %+v", scans)
+	}
+	reports, err := c.Executions(ExecutionFilter{ReportID: "digest-t1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Type != ReportCronType || len(reports[0].ScanIDs) != 0 {
+		t.Errorf("want the execution that sent the report, got %+v", reports)
+	}
+}
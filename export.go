@@ -0,0 +1,86 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExportedEntry defines an entry as it is exported for reporting.
+type ExportedEntry struct {
+	Type     CronType
+	ID       string
+	TeamID   string
+	CronSpec string
+	// Spec is the spec the entry is scheduled with, that is, its cron spec
+	// with the hash expressions resolved.
+	Spec        string
+	Description string
+	// NextRun is the next execution of the entry after the export time.
+	// It is zero if the entry is not scheduled because its team is not
+	// whitelisted.
+	NextRun time.Time
+}
+
+// Export returns all the entries, sorted by type and ID, with the
+// description of their schedules and their next execution after now.
+func (c *Crontinuous) Export(now time.Time) ([]ExportedEntry, error) {
+	var exported []ExportedEntry
+	for _, typ := range c.cronTypes() {
+		entries, err := c.GetEntries(typ)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].GetID() < entries[j].GetID()
+		})
+		for _, e := range entries {
+			ee := ExportedEntry{
+				Type:        typ,
+				ID:          e.GetID(),
+				TeamID:      e.GetTeamID(),
+				CronSpec:    e.GetCronSpec(),
+				Spec:        entrySpec(e),
+				Description: DescribeCronSpec(entrySpec(e)),
+			}
+			if c.isTeamWhitelisted(typ, e.GetTeamID()) {
+				if s, err := c.entrySchedule(e); err == nil {
+					ee.NextRun = s.Next(now)
+				}
+			}
+			exported = append(exported, ee)
+		}
+	}
+	return exported, nil
+}
+
+// WriteCrontab writes the given exported entries to the given writer as
+// classic crontab lines, each one preceded by a comment describing the entry,
+// so the schedule can be compared with the one of other cron systems. The
+// command of every line is the type and the ID of the entry. The entries not
+// scheduled, and the ones with specs that can not be expressed in a crontab,
+// like @every, are written commented out.
+func WriteCrontab(w io.Writer, entries []ExportedEntry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Schedule of vulcan-crontinuous, times in %s.\n", time.Local) // nolint
+	for _, e := range entries {
+		fmt.Fprintf(bw, "\n# %s %s of team %s: %s\n", e.Type, e.ID, e.TeamID, e.Description) // nolint
+		line := fmt.Sprintf("%s %s %s", e.Spec, e.Type, e.ID)
+		switch {
+		case strings.HasPrefix(e.Spec, "@every"):
+			fmt.Fprintf(bw, "# Not expressible in crontab.\n# %s\n", line) // nolint
+		case e.NextRun.IsZero():
+			fmt.Fprintf(bw, "# Not scheduled, team not whitelisted.\n# %s\n", line) // nolint
+		default:
+			fmt.Fprintln(bw, line) // nolint
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by the operations of a FaultyStore
+// failing on purpose.
+var ErrInjectedFault = errors.New("ErrorInjectedFault")
+
+// StoreOp identifies the kind of the operations of a store.
+type StoreOp string
+
+const (
+	// StoreGet identifies the reads of the entries of a store.
+	StoreGet StoreOp = "get"
+	// StoreSave identifies the saves of the entries to a store.
+	StoreSave StoreOp = "save"
+)
+
+// Faults defines the faults injected in the operations of a FaultyStore.
+type Faults struct {
+	// GetFailureRate and SaveFailureRate are the probabilities, from 0 to
+	// 1, of a read or a save failing.
+	GetFailureRate  float64
+	SaveFailureRate float64
+	// Latency is added to every operation, including the failing ones.
+	Latency time.Duration
+}
+
+// FaultyStore decorates the stores of the entries injecting failures and
+// latency in their operations, so the behaviour of crontinuous when the
+// store is slow or unavailable, e.g. the store cache, the divergence checks
+// or the readiness while the entries are pending to be saved, can be
+// verified in tests and staging environments. The failures return
+// ErrInjectedFault without calling the decorated store. It implements the
+// store interfaces of all the types of entries, but the operations of the
+// types the decorated store does not implement fail with
+// ErrInvalidCronType.
+type FaultyStore struct {
+	store interface{}
+
+	mux      sync.Mutex
+	faults   Faults
+	failNext map[StoreOp]int
+	calls    map[StoreOp]int
+	rand     *rand.Rand
+}
+
+// NewFaultyStore returns a FaultyStore decorating the given store
+// with the given faults.
+func NewFaultyStore(store interface{}, faults Faults) *FaultyStore {
+	return &FaultyStore{
+		store:    store,
+		faults:   faults,
+		failNext: map[StoreOp]int{},
+		calls:    map[StoreOp]int{},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())), // nolint
+	}
+}
+
+// SetFaults replaces the faults injected in the next operations.
+func (s *FaultyStore) SetFaults(faults Faults) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.faults = faults
+}
+
+// FailNext makes the next n operations of the given kind fail, regardless
+// of the failure rates.
+func (s *FaultyStore) FailNext(op StoreOp, n int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failNext[op] = n
+}
+
+// Calls returns the number of operations of the given kind performed,
+// including the failed ones.
+func (s *FaultyStore) Calls(op StoreOp) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.calls[op]
+}
+
+// inject waits the latency and returns ErrInjectedFault if the operation
+// of the given kind must fail.
+func (s *FaultyStore) inject(op StoreOp) error {
+	s.mux.Lock()
+	s.calls[op]++
+	latency := s.faults.Latency
+	rate := s.faults.GetFailureRate
+	if op == StoreSave {
+		rate = s.faults.SaveFailureRate
+	}
+	fail := s.failNext[op] > 0 || (rate > 0 && s.rand.Float64() < rate)
+	if s.failNext[op] > 0 {
+		s.failNext[op]--
+	}
+	s.mux.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if fail {
+		return ErrInjectedFault
+	}
+	return nil
+}
+
+func (s *FaultyStore) GetScanEntries() (map[string]ScanEntry, error) {
+	store, ok := s.store.(ScanCronStore)
+	if !ok {
+		return nil, ErrInvalidCronType
+	}
+	if err := s.inject(StoreGet); err != nil {
+		return nil, err
+	}
+	return store.GetScanEntries()
+}
+
+func (s *FaultyStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	store, ok := s.store.(ScanCronStore)
+	if !ok {
+		return ErrInvalidCronType
+	}
+	if err := s.inject(StoreSave); err != nil {
+		return err
+	}
+	return store.SaveScanEntries(entries)
+}
+
+func (s *FaultyStore) GetReportEntries() (map[string]ReportEntry, error) {
+	store, ok := s.store.(ReportCronStore)
+	if !ok {
+		return nil, ErrInvalidCronType
+	}
+	if err := s.inject(StoreGet); err != nil {
+		return nil, err
+	}
+	return store.GetReportEntries()
+}
+
+func (s *FaultyStore) SaveReportEntries(entries map[string]ReportEntry) error {
+	store, ok := s.store.(ReportCronStore)
+	if !ok {
+		return ErrInvalidCronType
+	}
+	if err := s.inject(StoreSave); err != nil {
+		return err
+	}
+	return store.SaveReportEntries(entries)
+}
+
+func (s *FaultyStore) GetTeamScanEntries() (map[string]TeamScanEntry, error) {
+	store, ok := s.store.(TeamScanCronStore)
+	if !ok {
+		return nil, ErrInvalidCronType
+	}
+	if err := s.inject(StoreGet); err != nil {
+		return nil, err
+	}
+	return store.GetTeamScanEntries()
+}
+
+func (s *FaultyStore) SaveTeamScanEntries(entries map[string]TeamScanEntry) error {
+	store, ok := s.store.(TeamScanCronStore)
+	if !ok {
+		return ErrInvalidCronType
+	}
+	if err := s.inject(StoreSave); err != nil {
+		return err
+	}
+	return store.SaveTeamScanEntries(entries)
+}
+
+func (s *FaultyStore) GetCommandEntries() (map[string]CommandEntry, error) {
+	store, ok := s.store.(CommandCronStore)
+	if !ok {
+		return nil, ErrInvalidCronType
+	}
+	if err := s.inject(StoreGet); err != nil {
+		return nil, err
+	}
+	return store.GetCommandEntries()
+}
+
+func (s *FaultyStore) SaveCommandEntries(entries map[string]CommandEntry) error {
+	store, ok := s.store.(CommandCronStore)
+	if !ok {
+		return ErrInvalidCronType
+	}
+	if err := s.inject(StoreSave); err != nil {
+		return err
+	}
+	return store.SaveCommandEntries(entries)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// scanOnlyStore only stores scan entries.
+type scanOnlyStore struct {
+	ScanCronStore
+}
+
+func TestFaultyStore(t *testing.T) {
+	inner := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	store := NewFaultyStore(inner, Faults{})
+
+	store.FailNext(StoreSave, 2)
+	for i := 0; i < 2; i++ {
+		if err := store.SaveScanEntries(map[string]ScanEntry{}); !errors.Is(err, ErrInjectedFault) {
+			t.Errorf("save %d: want ErrInjectedFault, got %v", i, err)
+		}
+	}
+	entries := map[string]ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"}}
+	if err := store.SaveScanEntries(entries); err != nil {
+		t.Fatalf("want the save after the failed ones to succeed, got %v", err)
+	}
+	if _, err := store.GetScanEntries(); err != nil {
+		t.Fatal(err)
+	}
+
+	store.SetFaults(Faults{GetFailureRate: 1, Latency: 10 * time.Millisecond})
+	start := time.Now()
+	if _, err := store.GetReportEntries(); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("want ErrInjectedFault with a failure rate of 1, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("want the latency injected, the read took %s", elapsed)
+	}
+	if err := store.SaveReportEntries(map[string]ReportEntry{}); err != nil {
+		t.Errorf("want the saves not affected by the get failure rate, got %v", err)
+	}
+	if calls := store.Calls(StoreGet); calls != 2 {
+		t.Errorf("want 2 reads, got %d", calls)
+	}
+	if calls := store.Calls(StoreSave); calls != 4 {
+		t.Errorf("want 4 saves, got %d", calls)
+	}
+
+	scanOnly := NewFaultyStore(scanOnlyStore{inner}, Faults{})
+	if _, err := scanOnly.GetReportEntries(); err != ErrInvalidCronType {
+		t.Errorf("want ErrInvalidCronType for a type not stored, got %v", err)
+	}
+}
+
+func TestCrontinuous_FaultyStore(t *testing.T) {
+	inner := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	store := NewFaultyStore(inner, Faults{})
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithStoreCache(t.TempDir(), time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	store.FailNext(StoreSave, 1)
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}); err == nil {
+		t.Errorf("want the failure to save the entry returned")
+	}
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := inner.GetScanEntries(); len(got) != 2 {
+		t.Errorf("want the entry of the failed save saved with the next one, got %v", got)
+	}
+
+	store.SetFaults(Faults{GetFailureRate: 1})
+	if err := c.Restart(); err != nil {
+		t.Fatalf("restarting with the store failing: %v", err)
+	}
+	if status := c.LoadStatus(); len(status.Degraded) != 2 {
+		t.Errorf("want the entries loaded from the cache, got %+v", status)
+	}
+	if entries, _ := c.GetEntries(ScanCronType); len(entries) != 2 {
+		t.Errorf("want the cached entries, got %v", entries)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// ScanFeatureFlag is the flag consulted before executing scan
+	// and team scan jobs.
+	ScanFeatureFlag = "scheduled-scans"
+	// ReportFeatureFlag is the flag consulted before executing report jobs.
+	ReportFeatureFlag = "scheduled-reports"
+	// CommandFeatureFlag is the flag consulted before executing command
+	// jobs, with the team of the entry, if any.
+	CommandFeatureFlag = "scheduled-commands"
+)
+
+// FeatureFlags defines the feature flag provider consulted by crontinuous
+// before executing a job. Implementations must return a sensible default
+// when the flag can not be evaluated.
+type FeatureFlags interface {
+	Enabled(flag, teamID string) bool
+}
+
+// FileFlag defines the rollout of a flag read by the FileFeatureFlags.
+// A flag is enabled for a team if the team is in Teams or if it falls in
+// the given Percentage of teams.
+type FileFlag struct {
+	Teams      []string `json:"teams"`
+	Percentage int      `json:"percentage"`
+}
+
+// FileFeatureFlags implements FeatureFlags reading the flags from a JSON file
+// containing an object keyed by flag name. The file is read again whenever it
+// is modified. Flags not present in the file are enabled for every team.
+type FileFeatureFlags struct {
+	path string
+	log  *logrus.Logger
+
+	mux     sync.Mutex
+	modTime time.Time
+	flags   map[string]FileFlag
+}
+
+// NewFileFeatureFlags creates a feature flags provider that reads the flags
+// from the given file.
+func NewFileFeatureFlags(path string, logger *logrus.Logger) (*FileFeatureFlags, error) {
+	f := &FileFeatureFlags{
+		path: path,
+		log:  logger,
+	}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileFeatureFlags) Enabled(flag, teamID string) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if err := f.load(); err != nil {
+		// Keep using the last flags successfully loaded.
+		f.log.WithError(err).Error("Error reloading feature flags")
+	}
+
+	ff, ok := f.flags[flag]
+	if !ok {
+		return true
+	}
+	for _, t := range ff.Teams {
+		if t == teamID {
+			return true
+		}
+	}
+	return rolloutBucket(flag, teamID) < ff.Percentage
+}
+
+func (f *FileFeatureFlags) load() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	if f.flags != nil && info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	flags := map[string]FileFlag{}
+	if err := json.Unmarshal(content, &flags); err != nil {
+		return err
+	}
+	f.flags = flags
+	f.modTime = info.ModTime()
+	return nil
+}
+
+// rolloutBucket deterministically assigns a team to a bucket in the range
+// [0, 100) for the given flag, so increasing the percentage of a flag only
+// adds teams to the ones already enabled.
+func rolloutBucket(flag, teamID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + "/" + teamID)) // nolint
+	return int(h.Sum32() % 100)
+}
+
+// flagGuardedJob wraps the job of an entry so it is only
+// executed if its feature flag is enabled for the team.
+type flagGuardedJob struct {
+	entryJob
+	flags FeatureFlags
+	log   *logrus.Logger
+}
+
+func (j *flagGuardedJob) run(ctx context.Context) error {
+	flag := featureFlag(j.cronType())
+	if !j.flags.Enabled(flag, j.team()) {
+		j.log.WithFields(executionFields(ctx)).WithFields(logrus.Fields{
+			"team": j.team(),
+			"flag": flag,
+		}).Info("Skipping job, feature flag disabled for team")
+		return skipJob(SkipFeatureFlag, "feature flag "+flag+" disabled for the team")
+	}
+	return j.entryJob.run(ctx)
+}
+
+func featureFlag(typ CronType) string {
+	switch typ {
+	case ReportCronType:
+		return ReportFeatureFlag
+	case CommandCronType:
+		return CommandFeatureFlag
+	}
+	return ScanFeatureFlag
+}
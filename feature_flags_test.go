@@ -0,0 +1,62 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestFileFeatureFlags_Enabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags.json")
+	content := `{
+		"scheduled-scans": {"teams": ["t1"], "percentage": 0},
+		"scheduled-reports": {"percentage": 100}
+	}`
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	flags, err := NewFileFeatureFlags(path, logrus.New())
+	if err != nil {
+		t.Fatalf("Error loading flags: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		flag   string
+		teamID string
+		want   bool
+	}{
+		{name: "TeamListed", flag: ScanFeatureFlag, teamID: "t1", want: true},
+		{name: "TeamNotListed", flag: ScanFeatureFlag, teamID: "t2", want: false},
+		{name: "FullRollout", flag: ReportFeatureFlag, teamID: "t2", want: true},
+		{name: "FlagNotDefined", flag: "unknown", teamID: "t2", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flags.Enabled(tt.flag, tt.teamID); got != tt.want {
+				t.Errorf("Enabled(%s, %s) = %v, want %v", tt.flag, tt.teamID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolloutBucket(t *testing.T) {
+	// Buckets must be deterministic so teams enabled at a given
+	// percentage remain enabled when the percentage is increased.
+	for _, team := range []string{"t1", "t2", "t3", "t4"} {
+		b := rolloutBucket(ScanFeatureFlag, team)
+		if b < 0 || b >= 100 {
+			t.Fatalf("bucket out of range: %d", b)
+		}
+		if b != rolloutBucket(ScanFeatureFlag, team) {
+			t.Fatalf("bucket for team %s is not deterministic", team)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultGitSyncInterval is the interval between synchronizations
+// when no GitSyncConfig.Interval is configured.
+const DefaultGitSyncInterval = 5 * time.Minute
+
+// DefaultGitSyncPath is the path of the manifest inside the repository
+// when no GitSyncConfig.Path is configured.
+const DefaultGitSyncPath = "schedules.yaml"
+
+// GitSyncConfig defines the git repository containing the manifest
+// the entries are synchronized with.
+type GitSyncConfig struct {
+	// Repo is the URL of the repository.
+	Repo string
+	// Branch is the branch to synchronize with.
+	Branch string
+	// Path is the path of the YAML manifest inside the
+	// repository. Defaults to DefaultGitSyncPath.
+	Path string
+	// Dir is the local directory where the repository
+	// is cloned. Defaults to a directory in os.TempDir.
+	Dir string
+	// Interval is the time between synchronizations.
+	Interval time.Duration
+}
+
+// GitSyncStatus describes the last synchronization with the repository.
+type GitSyncStatus struct {
+	// Commit is the last commit successfully synchronized.
+	Commit   string    `json:"commit"`
+	SyncedAt time.Time `json:"synced_at"`
+	// Changes is the number of changes applied by the last synchronization.
+	Changes int `json:"changes"`
+	// LastError is the error of the last synchronization, if it failed.
+	LastError string    `json:"last_error,omitempty"`
+	LastTry   time.Time `json:"last_try"`
+}
+
+// GitSyncer periodically pulls a git repository containing a manifest of the
+// scan and report entries and makes crontinuous entries match it.
+type GitSyncer struct {
+	cfg GitSyncConfig
+	c   *Crontinuous
+	log *logrus.Logger
+
+	mux    sync.Mutex
+	status GitSyncStatus
+}
+
+// NewGitSyncer creates a syncer of the entries of the given crontinuous.
+func NewGitSyncer(cfg GitSyncConfig, c *Crontinuous, logger *logrus.Logger) *GitSyncer {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultGitSyncInterval
+	}
+	if cfg.Path == "" {
+		cfg.Path = DefaultGitSyncPath
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "crontinuous-git-sync")
+	}
+	return &GitSyncer{
+		cfg: cfg,
+		c:   c,
+		log: logger,
+	}
+}
+
+// Run synchronizes the entries on every interval until the context is done.
+func (s *GitSyncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Sync(ctx); err != nil {
+			s.log.WithError(err).Error("Error synchronizing entries with git")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync pulls the repository and applies its manifest. The manifest is applied
+// even if the commit did not change, so manual changes of the entries are
+// reverted.
+func (s *GitSyncer) Sync(ctx context.Context) error {
+	commit, changes, err := s.sync(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.status.LastTry = time.Now()
+	if err != nil {
+		s.status.LastError = err.Error()
+		return err
+	}
+	s.status = GitSyncStatus{
+		Commit:   commit,
+		SyncedAt: s.status.LastTry,
+		Changes:  len(changes),
+		LastTry:  s.status.LastTry,
+	}
+	if len(changes) > 0 {
+		s.log.WithFields(logrus.Fields{
+			"commit":  commit,
+			"changes": len(changes),
+		}).Info("Synchronized entries with git")
+	}
+	return nil
+}
+
+// Status returns the status of the last synchronization.
+func (s *GitSyncer) Status() GitSyncStatus {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.status
+}
+
+func (s *GitSyncer) sync(ctx context.Context) (string, []Change, error) {
+	if err := s.pull(ctx); err != nil {
+		return "", nil, err
+	}
+	commit, err := s.git(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(s.cfg.Dir, s.cfg.Path))
+	if err != nil {
+		return "", nil, err
+	}
+	var m Manifest
+	if err := yaml.UnmarshalStrict(content, &m); err != nil {
+		return "", nil, fmt.Errorf("decoding manifest at commit %s: %w", commit, err)
+	}
+	changes, err := s.c.Apply(m)
+	return commit, changes, err
+}
+
+// pull clones the repository if it was not cloned yet, otherwise
+// it updates the local copy to the last commit of the branch.
+func (s *GitSyncer) pull(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(s.cfg.Dir, ".git")); os.IsNotExist(err) {
+		args := []string{"clone", "--depth", "1"}
+		if s.cfg.Branch != "" {
+			args = append(args, "--branch", s.cfg.Branch)
+		}
+		_, err := s.gitIn(ctx, "", append(args, s.cfg.Repo, s.cfg.Dir)...)
+		return err
+	}
+
+	branch := s.cfg.Branch
+	if branch == "" {
+		branch = "HEAD"
+	}
+	if _, err := s.git(ctx, "fetch", "--depth", "1", "origin", branch); err != nil {
+		return err
+	}
+	_, err := s.git(ctx, "reset", "--hard", "FETCH_HEAD")
+	return err
+}
+
+func (s *GitSyncer) git(ctx context.Context, args ...string) (string, error) {
+	return s.gitIn(ctx, s.cfg.Dir, args...)
+}
+
+func (s *GitSyncer) gitIn(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGitSyncer_Sync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repo := t.TempDir()
+	gitCommit := func(manifest string) string {
+		if err := ioutil.WriteFile(filepath.Join(repo, "schedules.yaml"), []byte(manifest), 0600); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"add", "schedules.yaml"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "update"},
+		} {
+			if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v %s", args, err, out)
+			}
+		}
+		out, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	gitCommit(`
+scans:
+  - program_id: p1
+    team_id: t1
+    cron_spec: "0 3 * * *"
+`)
+
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 * * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	s := NewGitSyncer(GitSyncConfig{
+		Repo: repo,
+		Path: "schedules.yaml",
+		Dir:  filepath.Join(t.TempDir(), "clone"),
+	}, c, logrus.New())
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	want := map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * *"},
+	}
+	if diff := cmp.Diff(want, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored scan entries got!=want, diff %s", diff)
+	}
+
+	commit := gitCommit(`
+scans:
+  - program_id: p1
+    team_id: t1
+    cron_spec: "0 4 * * *"
+reports:
+  - team_id: t1
+    cron_spec: "0 8 * * 1"
+`)
+	if err := s.Sync(context.Background()); err != nil {
+		t.Fatalf("Error syncing: %v", err)
+	}
+	wantReports := map[string]ReportEntry{
+		"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+	}
+	if diff := cmp.Diff(wantReports, store.reportEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored report entries got!=want, diff %s", diff)
+	}
+	status := s.Status()
+	if status.Commit != commit || status.Changes != 2 || status.LastError != "" {
+		t.Errorf("unexpected status %+v, want commit %s with 2 changes", status, commit)
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/manelmontilla/cron"
+)
+
+// hashFieldRanges are the ranges of the values the H tokens of each field
+// of a cron spec resolve to. The day of the month is limited to 28 so the
+// entries execute every month.
+var hashFieldRanges = [5]struct{ min, max int }{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 28}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week
+}
+
+// ResolveCronSpec replaces the H tokens of the given cron spec with values
+// derived from the hash of the given entry ID, so the executions of the
+// entries are spread while every entry keeps executing at the same time.
+// The tokens have the following forms:
+//
+//	H          a value in the whole range of the field
+//	H(a-b)     a value between a and b, both inclusive
+//	H/n        every n units starting from a value lower than n
+//	H(a-b)/n   every n units between a and b, starting from a value
+//	           between a and a+n-1
+//
+// For instance, "H H(0-6) * * *" executes the entry once a day at a fixed
+// minute between midnight and 6:59. The specs without H tokens are returned
+// unchanged.
+func ResolveCronSpec(spec, entryID string) (string, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(hashFieldRanges) || !strings.Contains(spec, "H") {
+		return spec, nil
+	}
+	for i, field := range fields {
+		if !strings.Contains(field, "H") {
+			continue
+		}
+		items := strings.Split(field, ",")
+		for j, item := range items {
+			resolved, err := resolveHashItem(item, i, entryID)
+			if err != nil {
+				return "", fmt.Errorf("invalid H token %q: %w", item, err)
+			}
+			items[j] = resolved
+		}
+		fields[i] = strings.Join(items, ",")
+	}
+	return strings.Join(fields, " "), nil
+}
+
+func resolveHashItem(item string, field int, entryID string) (string, error) {
+	if !strings.HasPrefix(item, "H") {
+		return item, nil
+	}
+	r := hashFieldRanges[field]
+	min, max := r.min, r.max
+	rest := strings.TrimPrefix(item, "H")
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end < 0 {
+			return "", fmt.Errorf("unclosed range")
+		}
+		from, to, ok := strings.Cut(rest[1:end], "-")
+		if !ok {
+			return "", fmt.Errorf("invalid range")
+		}
+		var err error
+		if min, err = strconv.Atoi(from); err != nil {
+			return "", fmt.Errorf("invalid range")
+		}
+		if max, err = strconv.Atoi(to); err != nil {
+			return "", fmt.Errorf("invalid range")
+		}
+		if min < r.min || max > r.max || min > max {
+			return "", fmt.Errorf("range out of bounds")
+		}
+		rest = rest[end+1:]
+	}
+
+	h := int(specHash(entryID, field))
+	switch {
+	case rest == "":
+		return strconv.Itoa(min + h%(max-min+1)), nil
+	case strings.HasPrefix(rest, "/"):
+		step, err := strconv.Atoi(rest[1:])
+		if err != nil || step <= 0 {
+			return "", fmt.Errorf("invalid step")
+		}
+		start := min + h%step
+		if start > max {
+			start = min
+		}
+		return fmt.Sprintf("%d-%d/%d", start, max, step), nil
+	}
+	return "", fmt.Errorf("unexpected %q", rest)
+}
+
+// specHash returns the hash resolving the H tokens of the given field of the
+// specs of the given entry, different for each field so, for instance, the
+// minute and the hour of "H H * * *" are not correlated.
+func specHash(entryID string, field int) uint32 {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s/%d", entryID, field) // nolint
+	return h.Sum32()
+}
+
+// descriptorSpecs are the five fields specs equivalent to the predefined
+// descriptors of the cron parser.
+var descriptorSpecs = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// normalizeCronSpec returns the given spec in a canonical form, so the specs
+// written differently but executing at the same times are equal: the fields
+// are separated by a single space, the descriptors are replaced by their
+// fields and the H tokens are resolved with the given seed.
+func normalizeCronSpec(spec, seed string) string {
+	spec = strings.Join(strings.Fields(spec), " ")
+	if fields, ok := descriptorSpecs[spec]; ok {
+		spec = fields
+	}
+	if resolved, err := ResolveCronSpec(spec, seed); err == nil {
+		spec = resolved
+	}
+	return spec
+}
+
+// entrySpec returns the spec of the given entry with its H tokens resolved.
+// If they can not be resolved the spec is returned as is, so parsing it
+// fails.
+func entrySpec(e CronEntry) string {
+	spec, err := ResolveCronSpec(e.GetCronSpec(), e.GetID())
+	if err != nil {
+		return e.GetCronSpec()
+	}
+	return spec
+}
+
+// parseEntrySpec parses the spec of the given entry.
+func parseEntrySpec(e CronEntry) (cron.Schedule, error) {
+	return cron.ParseStandard(entrySpec(e))
+}
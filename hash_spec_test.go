@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestResolveCronSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		check   func(fields []string) error
+		wantErr bool
+	}{
+		{
+			spec: "0 12 * * *",
+			check: func(fields []string) error {
+				if strings.Join(fields, " ") != "0 12 * * *" {
+					return fmt.Errorf("spec without H tokens changed")
+				}
+				return nil
+			},
+		},
+		{
+			spec: "H H(0-6) * * *",
+			check: func(fields []string) error {
+				if err := inRange(fields[0], 0, 59); err != nil {
+					return err
+				}
+				return inRange(fields[1], 0, 6)
+			},
+		},
+		{
+			spec: "H/15 * * * H(1-5)",
+			check: func(fields []string) error {
+				start, rest, _ := strings.Cut(fields[0], "-")
+				if err := inRange(start, 0, 14); err != nil {
+					return err
+				}
+				if rest != "59/15" {
+					return fmt.Errorf("unexpected minutes %s", fields[0])
+				}
+				return inRange(fields[4], 1, 5)
+			},
+		},
+		{
+			spec: "0,H 0 H * *",
+			check: func(fields []string) error {
+				first, second, _ := strings.Cut(fields[0], ",")
+				if first != "0" {
+					return fmt.Errorf("unexpected minutes %s", fields[0])
+				}
+				if err := inRange(second, 0, 59); err != nil {
+					return err
+				}
+				return inRange(fields[2], 1, 28)
+			},
+		},
+		{spec: "H(0-60) * * * *", wantErr: true},
+		{spec: "H(6-0) * * * *", wantErr: true},
+		{spec: "H(0-6 * * * *", wantErr: true},
+		{spec: "H/0 * * * *", wantErr: true},
+		{spec: "Hx * * * *", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			for _, id := range []string{"p1", "p2", "p3"} {
+				got, err := ResolveCronSpec(tt.spec, id)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("want error %v, got %v", tt.wantErr, err)
+				}
+				if tt.wantErr {
+					return
+				}
+				if err := validateCronSpec(got); err != nil {
+					t.Fatalf("invalid resolved spec %q", got)
+				}
+				if err := tt.check(strings.Fields(got)); err != nil {
+					t.Errorf("resolved spec %q: %v", got, err)
+				}
+				again, _ := ResolveCronSpec(tt.spec, id)
+				if again != got {
+					t.Errorf("spec resolved to %q and %q for the same entry", got, again)
+				}
+			}
+		})
+	}
+}
+
+func inRange(s string, min, max int) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return fmt.Errorf("%q not in [%d, %d]", s, min, max)
+	}
+	return nil
+}
+
+func TestResolveCronSpec_Spreads(t *testing.T) {
+	minutes := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		spec, err := ResolveCronSpec("H * * * *", fmt.Sprintf("p%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		minutes[strings.Fields(spec)[0]] = true
+	}
+	// 100 entries hashed into 60 minutes should use many of them.
+	if len(minutes) < 30 {
+		t.Errorf("entries concentrated in %d minutes", len(minutes))
+	}
+}
+
+func TestCrontinuous_HashSpecs(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "H H(0-6) * * *"}
+	if err := c.SaveEntry(ScanCronType, entry); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetCronSpec() != entry.CronSpec {
+		t.Errorf("stored spec %q, want %q", got.GetCronSpec(), entry.CronSpec)
+	}
+
+	from := time.Date(2020, 6, 1, 0, 0, 0, 0, time.Local)
+	executions, err := c.Simulate(from, from.Add(3*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 3 {
+		t.Fatalf("want an execution a day, got %d", len(executions))
+	}
+	for _, e := range executions {
+		if e.Time.Hour() > 6 || e.Time.Hour() != executions[0].Time.Hour() || e.Time.Minute() != executions[0].Time.Minute() {
+			t.Errorf("unexpected execution at %s", e.Time)
+		}
+	}
+}
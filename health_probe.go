@@ -0,0 +1,130 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultHealthProbeDelay is the time the activations are deferred
+	// while vulcan-api is unhealthy when no delay is configured.
+	DefaultHealthProbeDelay = time.Minute
+
+	// DefaultHealthProbeRetries is the number of times the activations are
+	// deferred while vulcan-api is unhealthy when no number is configured.
+	DefaultHealthProbeRetries = 5
+
+	// healthProbeTimeout is the maximum time spent probing vulcan-api.
+	healthProbeTimeout = 5 * time.Second
+
+	// healthProbeTTL is the maximum time the result of a probe is reused,
+	// so the jobs activated at the same tick share it. It is reduced to the
+	// delay of the deferred activations, so they probe again.
+	healthProbeTTL = 10 * time.Second
+)
+
+// HealthProber defines the services needed to check, before executing the
+// activations of the jobs, whether vulcan-api can handle their requests.
+type HealthProber interface {
+	// Healthy returns an error if vulcan-api is not healthy.
+	Healthy(ctx context.Context) error
+}
+
+// WithHealthProbe makes crontinuous probe the health of vulcan-api with the
+// given prober before executing the activations of the jobs. The activations
+// at the same tick share the probe and, while vulcan-api is unhealthy, they
+// are deferred by the given delay, DefaultHealthProbeDelay if it is not
+// positive, up to the given number of retries, DefaultHealthProbeRetries if
+// it is not positive. After the retries the activations are executed anyway,
+// as a missed scan is worse than a failed one. The manual executions are not
+// deferred.
+func WithHealthProbe(p HealthProber, delay time.Duration, retries int) Option {
+	return func(c *Crontinuous) {
+		if delay <= 0 {
+			delay = DefaultHealthProbeDelay
+		}
+		if retries <= 0 {
+			retries = DefaultHealthProbeRetries
+		}
+		c.healthGate = &healthGate{prober: p, delay: delay, retries: retries, log: c.log}
+	}
+}
+
+// healthGate holds the activations of the jobs while vulcan-api is
+// unhealthy.
+type healthGate struct {
+	prober  HealthProber
+	delay   time.Duration
+	retries int
+	log     *logrus.Logger
+
+	mux       sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// check returns the result of the last probe if it is recent enough, or
+// probes vulcan-api again. The concurrent calls wait for the same probe.
+func (g *healthGate) check() error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	ttl := healthProbeTTL
+	if g.delay < ttl {
+		ttl = g.delay
+	}
+	if !g.checkedAt.IsZero() && time.Since(g.checkedAt) < ttl {
+		return g.err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), healthProbeTimeout)
+	defer cancel()
+	g.err = g.prober.Healthy(ctx)
+	g.checkedAt = time.Now()
+	return g.err
+}
+
+// wait blocks until vulcan-api is healthy or the retries are exhausted. It
+// returns false if the given context is done meanwhile.
+func (g *healthGate) wait(ctx context.Context, id string) bool {
+	for attempt := 0; ; attempt++ {
+		err := g.check()
+		if err == nil {
+			return true
+		}
+		log := g.log.WithError(err).WithField("job", id)
+		if attempt >= g.retries {
+			log.Error("vulcan-api still unhealthy, executing job anyway")
+			return true
+		}
+		log.Warnf("vulcan-api unhealthy, deferring job %s", g.delay)
+		timer := time.NewTimer(g.delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// probedJob defers the activations of a job while vulcan-api is unhealthy.
+type probedJob struct {
+	Job
+	id   string
+	gate *healthGate
+	ctx  context.Context
+}
+
+func (j *probedJob) Run() {
+	if !j.gate.wait(j.ctx, j.id) {
+		return
+	}
+	j.Job.Run()
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// unhealthyProber fails the given number of probes before succeeding.
+type unhealthyProber struct {
+	failures int32
+	probes   int32
+}
+
+func (p *unhealthyProber) Healthy(ctx context.Context) error {
+	if atomic.AddInt32(&p.probes, 1) <= atomic.LoadInt32(&p.failures) {
+		return errors.New("vulcan-api unavailable")
+	}
+	return nil
+}
+
+func TestHealthGate_Wait(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int32
+		retries    int
+		cancel     bool
+		want       bool
+		wantProbes int32
+	}{
+		{name: "Healthy", failures: 0, retries: 3, want: true, wantProbes: 1},
+		{name: "Recovers", failures: 2, retries: 3, want: true, wantProbes: 3},
+		{name: "RetriesExhausted", failures: 10, retries: 3, want: true, wantProbes: 4},
+		{name: "Cancelled", failures: 10, retries: 3, cancel: true, want: false, wantProbes: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &unhealthyProber{failures: tt.failures}
+			g := &healthGate{prober: p, delay: time.Millisecond, retries: tt.retries, log: logrus.New()}
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				g.delay = time.Hour
+				cancel()
+			}
+			defer cancel()
+			if got := g.wait(ctx, "p1"); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+			if got := atomic.LoadInt32(&p.probes); got != tt.wantProbes {
+				t.Errorf("want %d probes, got %d", tt.wantProbes, got)
+			}
+		})
+	}
+}
+
+func TestHealthGate_SharedProbe(t *testing.T) {
+	p := &unhealthyProber{}
+	g := &healthGate{prober: p, delay: time.Minute, retries: 1, log: logrus.New()}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.wait(context.Background(), "p1")
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&p.probes); got != 1 {
+		t.Errorf("want the activations of a tick sharing the probe, got %d probes", got)
+	}
+}
+
+func TestProbedJob(t *testing.T) {
+	p := &unhealthyProber{failures: 1}
+	g := &healthGate{prober: p, delay: time.Millisecond, retries: 3, log: logrus.New()}
+	job := &countingJob{}
+	pj := &probedJob{Job: job, id: "p1", gate: g, ctx: context.Background()}
+	pj.Run()
+	if job.runs != 1 {
+		t.Errorf("want the job executed once vulcan-api recovers, got %d executions", job.runs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	atomic.StoreInt32(&p.failures, 100)
+	g.checkedAt = time.Time{}
+	g.delay = time.Hour
+	(&probedJob{Job: job, id: "p1", gate: g, ctx: ctx}).Run()
+	if job.runs != 1 {
+		t.Errorf("want the job not executed after a stop, got %d executions", job.runs)
+	}
+}
+
+func TestVulcanClient_Healthy(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/healthcheck" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := &VulcanClient{VulcanAPI: srv.URL}
+	if err := c.Healthy(context.Background()); err != nil {
+		t.Errorf("want healthy, got %v", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := c.Healthy(context.Background()); err == nil {
+		t.Error("want an error when vulcan-api is unavailable")
+	}
+}
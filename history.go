@@ -0,0 +1,175 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of revisions kept per entry
+// when no limit is configured.
+const DefaultHistoryLimit = 20
+
+var (
+	// ErrHistoryDisabled indicates the history of the entries is not enabled.
+	ErrHistoryDisabled = errors.New("ErrorHistoryDisabled")
+
+	// ErrRevisionNotFound indicates the given revision is not in the
+	// history of the entry.
+	ErrRevisionNotFound = errors.New("ErrorRevisionNotFound")
+)
+
+// Revision defines the state of an entry after one of its changes.
+type Revision struct {
+	// Revision is the number of the revision, starting from 1 and increasing
+	// with every change of the entry.
+	Revision int `json:"revision"`
+	// Entry is the entry after the change, null if the change removed it.
+	Entry json.RawMessage `json:"entry"`
+	// ChangedBy identifies who made the change, if known.
+	ChangedBy string `json:"changed_by,omitempty"`
+	// ChangedAt is the time of the change. It is zero for the revision
+	// holding the state an entry had when its history started.
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// removed returns true if the revision removed the entry.
+func (r Revision) removed() bool {
+	return len(r.Entry) == 0 || string(r.Entry) == "null"
+}
+
+// HistoryStore defines the services needed to store the revisions of the
+// entries.
+type HistoryStore interface {
+	GetHistory(typ CronType, ID string) ([]Revision, error)
+	SaveHistory(typ CronType, ID string, revisions []Revision) error
+}
+
+// WithHistory makes crontinuous record in the given store the last revisions
+// of each entry, up to the given limit. A limit lower than 1 means
+// DefaultHistoryLimit.
+func WithHistory(store HistoryStore, limit int) Option {
+	return func(c *Crontinuous) {
+		if limit < 1 {
+			limit = DefaultHistoryLimit
+		}
+		c.history = store
+		c.historyLimit = limit
+	}
+}
+
+// ChangedBy records who makes the change in the history of the entry.
+func ChangedBy(who string) SaveOption {
+	return func(o *saveOptions) {
+		o.changedBy = who
+	}
+}
+
+// History returns, from the oldest to the newest, the revisions kept for the
+// entry with the given type and ID.
+func (c *Crontinuous) History(typ CronType, ID string) ([]Revision, error) {
+	if c.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	if _, err := c.entrySet(typ); err != nil {
+		return nil, err
+	}
+	return c.history.GetHistory(typ, ID)
+}
+
+// recordHistory adds to the history of the entries a revision for each of the
+// given changes. Errors are only logged, as the changes are already
+// performed.
+func (c *Crontinuous) recordHistory(by string, changes ...Change) {
+	if c.history == nil {
+		return
+	}
+
+	c.historyMux.Lock()
+	defer c.historyMux.Unlock()
+
+	now := time.Now()
+	for _, ch := range changes {
+		if ch.Current != nil && ch.Desired != nil && sameEntry(ch.Current, ch.Desired) {
+			continue
+		}
+		if err := c.addRevision(ch, by, now); err != nil {
+			c.log.WithError(err).WithField("entry", ch.ID).Errorf("Error recording history of %s entry", ch.Type)
+		}
+	}
+}
+
+func (c *Crontinuous) addRevision(ch Change, by string, at time.Time) error {
+	revisions, err := c.history.GetHistory(ch.Type, ch.ID)
+	if err != nil {
+		return err
+	}
+
+	// Keep the state the entry had before its first recorded
+	// change, so the change can be reverted.
+	if len(revisions) == 0 && ch.Current != nil {
+		data, err := json.Marshal(ch.Current)
+		if err != nil {
+			return err
+		}
+		revisions = append(revisions, Revision{Revision: 1, Entry: data})
+	}
+
+	var data json.RawMessage
+	if ch.Desired != nil {
+		if data, err = json.Marshal(ch.Desired); err != nil {
+			return err
+		}
+	}
+	next := 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	}
+	revisions = append(revisions, Revision{
+		Revision:  next,
+		Entry:     data,
+		ChangedBy: by,
+		ChangedAt: at,
+	})
+	return c.history.SaveHistory(ch.Type, ch.ID, c.retainRevisions(revisions, at))
+}
+
+// Revert restores the entry with the given type and ID to the state it had
+// in the given revision, rescheduling its job. If the entry did not exist in
+// that revision it is removed. The revert is recorded as a new revision. It
+// returns the restored entry, nil if it was removed.
+func (c *Crontinuous) Revert(typ CronType, ID string, revision int, opts ...SaveOption) (CronEntry, error) {
+	revisions, err := c.History(typ, ID)
+	if err != nil {
+		return nil, err
+	}
+	var target *Revision
+	for i := range revisions {
+		if revisions[i].Revision == revision {
+			target = &revisions[i]
+		}
+	}
+	if target == nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	if target.removed() {
+		err := c.RemoveEntry(typ, ID, opts...)
+		if err != nil && !errors.Is(err, ErrScheduleNotFound) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	entry, err := UnmarshalEntry(typ, target.Entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SaveEntry(typ, entry, opts...); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// HistoryRef identifies the history of an entry.
+type HistoryRef struct {
+	Type CronType
+	ID   string
+}
+
+// HistoryLister is implemented by the history stores able to list and
+// delete the histories they hold. The pruning of the stores not implementing
+// it only covers the histories of the existing entries, so the histories of
+// the removed entries are kept.
+type HistoryLister interface {
+	ListHistories() ([]HistoryRef, error)
+	DeleteHistory(typ CronType, ID string) error
+}
+
+// WithHistoryRetention makes crontinuous discard the revisions of the
+// entries that stopped being the current state of the entry more than the
+// given age ago. The last revision of the entries is always kept, except
+// when it removed the entry, so the whole history of the entries removed
+// before the given age is discarded. Zero keeps the revisions regardless of
+// their age.
+func WithHistoryRetention(maxAge time.Duration) Option {
+	return func(c *Crontinuous) {
+		c.historyMaxAge = maxAge
+	}
+}
+
+// HistoryPruneResult defines the outcome of a pruning of the history.
+type HistoryPruneResult struct {
+	// Histories is the number of histories kept by type of entry.
+	Histories map[CronType]int `json:"histories"`
+	// Revisions is the number of revisions kept by type of entry.
+	Revisions map[CronType]int `json:"revisions"`
+	// Pruned is the number of revisions discarded by type of entry.
+	Pruned map[CronType]int `json:"pruned"`
+}
+
+// historyStats holds the size of the history measured by the last pruning
+// and the number of revisions discarded since the start.
+type historyStats struct {
+	mux       sync.Mutex
+	measured  bool
+	histories map[CronType]int
+	revisions map[CronType]int
+	pruned    map[CronType]int
+}
+
+func (s *historyStats) record(r HistoryPruneResult) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.pruned == nil {
+		s.pruned = map[CronType]int{}
+	}
+	s.measured = true
+	s.histories = r.Histories
+	s.revisions = r.Revisions
+	for typ, n := range r.Pruned {
+		s.pruned[typ] += n
+	}
+}
+
+// snapshot returns a copy of the stats. The sizes are nil until the history
+// is pruned for the first time.
+func (s *historyStats) snapshot() (histories, revisions, pruned map[CronType]int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	pruned = map[CronType]int{}
+	for typ, n := range s.pruned {
+		pruned[typ] = n
+	}
+	if !s.measured {
+		return nil, nil, pruned
+	}
+	histories, revisions = map[CronType]int{}, map[CronType]int{}
+	for typ, n := range s.histories {
+		histories[typ] = n
+	}
+	for typ, n := range s.revisions {
+		revisions[typ] = n
+	}
+	return histories, revisions, pruned
+}
+
+// PruneHistory applies the retention policy, the limit of revisions per
+// entry and the max age, to all the histories, including the ones written
+// before the policy was configured. It returns ErrHistoryDisabled if the
+// history is not enabled.
+func (c *Crontinuous) PruneHistory() (HistoryPruneResult, error) {
+	res := HistoryPruneResult{
+		Histories: map[CronType]int{},
+		Revisions: map[CronType]int{},
+		Pruned:    map[CronType]int{},
+	}
+	if err := c.checkWritable(); err != nil {
+		return res, err
+	}
+	if c.history == nil {
+		return res, ErrHistoryDisabled
+	}
+	refs, err := c.historyRefs()
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now()
+	for _, ref := range refs {
+		kept, pruned, err := c.pruneEntryHistory(ref, now)
+		if err != nil {
+			c.log.WithError(err).WithField("entry", ref.ID).Errorf("Error pruning history of %s entry", ref.Type)
+			continue
+		}
+		if kept > 0 {
+			res.Histories[ref.Type]++
+			res.Revisions[ref.Type] += kept
+		}
+		res.Pruned[ref.Type] += pruned
+	}
+	c.historyStats.record(res)
+	return res, nil
+}
+
+// historyRefs returns the histories to prune: the ones in the store, if it
+// is able to list them, or the ones of the existing entries.
+func (c *Crontinuous) historyRefs() ([]HistoryRef, error) {
+	if l, ok := c.history.(HistoryLister); ok {
+		return l.ListHistories()
+	}
+	var refs []HistoryRef
+	for _, typ := range c.cronTypes() {
+		entries, err := c.GetEntries(typ)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			refs = append(refs, HistoryRef{Type: typ, ID: e.GetID()})
+		}
+	}
+	return refs, nil
+}
+
+// pruneEntryHistory applies the retention policy to the given history and
+// returns the number of revisions kept and discarded.
+func (c *Crontinuous) pruneEntryHistory(ref HistoryRef, now time.Time) (int, int, error) {
+	// The lock prevents losing the revisions recorded meanwhile.
+	c.historyMux.Lock()
+	defer c.historyMux.Unlock()
+
+	revisions, err := c.history.GetHistory(ref.Type, ref.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	kept := c.retainRevisions(revisions, now)
+	if len(kept) == len(revisions) && reflect.DeepEqual(kept, revisions) {
+		return len(kept), 0, nil
+	}
+	pruned := len(revisions) - len(kept)
+	if len(kept) == 0 {
+		if l, ok := c.history.(HistoryLister); ok {
+			return 0, pruned, l.DeleteHistory(ref.Type, ref.ID)
+		}
+	}
+	return len(kept), pruned, c.history.SaveHistory(ref.Type, ref.ID, kept)
+}
+
+// retainRevisions returns the given revisions, sorted from the oldest to the
+// newest, that must be kept at the given time according to the retention
+// policy.
+func (c *Crontinuous) retainRevisions(revisions []Revision, now time.Time) []Revision {
+	if len(revisions) > c.historyLimit {
+		revisions = revisions[len(revisions)-c.historyLimit:]
+	}
+	if c.historyMaxAge <= 0 || len(revisions) == 0 {
+		return revisions
+	}
+
+	cutoff := now.Add(-c.historyMaxAge)
+	last := revisions[len(revisions)-1]
+	if last.removed() && last.ChangedAt.Before(cutoff) {
+		return []Revision{}
+	}
+	// A revision stops being the current state of the entry when the next
+	// one is recorded, so the revision holding the state of the entry when
+	// its history started, which has no time, is kept as long as the next.
+	first := 0
+	for first < len(revisions)-1 && revisions[first+1].ChangedAt.Before(cutoff) {
+		first++
+	}
+	return revisions[first:]
+}
+
+// HistoryPruner periodically prunes the history of a crontinuous instance.
+type HistoryPruner struct {
+	c        *Crontinuous
+	interval time.Duration
+	log      *logrus.Logger
+}
+
+// NewHistoryPruner returns a pruner of the history of the given crontinuous
+// instance that runs every given interval.
+func NewHistoryPruner(c *Crontinuous, interval time.Duration, logger *logrus.Logger) *HistoryPruner {
+	return &HistoryPruner{c: c, interval: interval, log: logger}
+}
+
+// Run prunes the history on every interval until the context is done.
+func (p *HistoryPruner) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		res, err := p.c.PruneHistory()
+		if err != nil {
+			p.log.WithError(err).Error("Error pruning history")
+		} else {
+			var pruned int
+			for _, n := range res.Pruned {
+				pruned += n
+			}
+			p.log.WithField("pruned", pruned).Info("Pruned history")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
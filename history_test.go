@@ -0,0 +1,316 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+// mockHistoryStore stores the revisions encoded
+// as JSON, as the S3CronStore does.
+type mockHistoryStore struct {
+	revisions map[string][]byte
+}
+
+func (s *mockHistoryStore) GetHistory(typ CronType, ID string) ([]Revision, error) {
+	data, ok := s.revisions[fmt.Sprintf("%s/%s", typ, ID)]
+	if !ok {
+		return []Revision{}, nil
+	}
+	var revisions []Revision
+	err := json.Unmarshal(data, &revisions)
+	return revisions, err
+}
+
+func (s *mockHistoryStore) SaveHistory(typ CronType, ID string, revisions []Revision) error {
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		return err
+	}
+	s.revisions[fmt.Sprintf("%s/%s", typ, ID)] = data
+	return nil
+}
+
+func TestCrontinuous_History(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	history := &mockHistoryStore{revisions: map[string][]byte{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithHistory(history, 3))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	for _, spec := range []string{"0 0 2 1 *", "0 0 2 1 *", "0 0 3 1 *"} {
+		entry := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: spec}
+		if err := c.SaveEntry(ScanCronType, entry, ChangedBy("alice")); err != nil {
+			t.Fatalf("Error saving entry: %v", err)
+		}
+	}
+	if err := c.RemoveEntry(ScanCronType, "p1", ChangedBy("bob")); err != nil {
+		t.Fatalf("Error removing entry: %v", err)
+	}
+
+	revisions, err := c.History(ScanCronType, "p1")
+	if err != nil {
+		t.Fatalf("Error getting history: %v", err)
+	}
+	// The initial state, the two changes of the spec and the removal
+	// were recorded, but only the last three revisions are kept.
+	var got []string
+	for _, r := range revisions {
+		var e ScanEntry
+		if !r.removed() {
+			if err := json.Unmarshal(r.Entry, &e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got = append(got, fmt.Sprintf("%d %s %s", r.Revision, e.CronSpec, r.ChangedBy))
+	}
+	want := []string{"2 0 0 2 1 * alice", "3 0 0 3 1 * alice", "4  bob"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("revisions got %q, want %q", got, want)
+	}
+
+	c = NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if _, err := c.History(ScanCronType, "p1"); err != ErrHistoryDisabled {
+		t.Errorf("error got %v, want %v", err, ErrHistoryDisabled)
+	}
+}
+
+func TestCrontinuous_Revert(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	history := &mockHistoryStore{revisions: map[string][]byte{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithHistory(history, 0))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	first := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	second := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 2 1 *"}
+	for _, e := range []ScanEntry{first, second} {
+		if err := c.SaveEntry(ScanCronType, e); err != nil {
+			t.Fatalf("Error saving entry: %v", err)
+		}
+	}
+	if err := c.RemoveEntry(ScanCronType, "p1"); err != nil {
+		t.Fatalf("Error removing entry: %v", err)
+	}
+
+	got, err := c.Revert(ScanCronType, "p1", 1, ChangedBy("alice"))
+	if err != nil {
+		t.Fatalf("Error reverting entry: %v", err)
+	}
+	if !cmp.Equal(got, CronEntry(first)) {
+		t.Errorf("reverted entry got %v, want %v", got, first)
+	}
+	if diff := cmp.Diff(map[string]ScanEntry{"p1": first}, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored entries got!=want, diff %s", diff)
+	}
+	if n := len(c.cron.Jobs()); n != 1 {
+		t.Errorf("expected the reverted entry to be scheduled, got %d jobs", n)
+	}
+
+	// Reverting to the revision that removed the entry removes it again.
+	if _, err := c.Revert(ScanCronType, "p1", 3); err != nil {
+		t.Fatalf("Error reverting entry: %v", err)
+	}
+	if len(store.scanEntries) != 0 {
+		t.Errorf("entry not removed, got %v", store.scanEntries)
+	}
+
+	revisions, err := c.History(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(revisions); n != 5 {
+		t.Errorf("the reverts should be recorded as new revisions, got %d revisions", n)
+	}
+	if _, err := c.Revert(ScanCronType, "p1", 10); err != ErrRevisionNotFound {
+		t.Errorf("error got %v, want %v", err, ErrRevisionNotFound)
+	}
+}
+
+// listingHistoryStore is a mockHistoryStore implementing HistoryLister.
+type listingHistoryStore struct {
+	*mockHistoryStore
+}
+
+func (s listingHistoryStore) ListHistories() ([]HistoryRef, error) {
+	var refs []HistoryRef
+	for key := range s.revisions {
+		name, ID, _ := strings.Cut(key, "/")
+		var ref HistoryRef
+		if err := ref.Type.UnmarshalText([]byte(name)); err != nil {
+			return nil, err
+		}
+		ref.ID = ID
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func (s listingHistoryStore) DeleteHistory(typ CronType, ID string) error {
+	delete(s.revisions, fmt.Sprintf("%s/%s", typ, ID))
+	return nil
+}
+
+func TestRetainRevisions(t *testing.T) {
+	now := time.Date(2020, 6, 10, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time {
+		return now.Add(-time.Duration(d) * 24 * time.Hour)
+	}
+	entry := json.RawMessage(`{"program_id":"p1"}`)
+	tests := []struct {
+		name      string
+		limit     int
+		maxAge    time.Duration
+		revisions []Revision
+		want      []int
+	}{
+		{
+			name:  "Limit",
+			limit: 2,
+			revisions: []Revision{
+				{Revision: 1, Entry: entry},
+				{Revision: 2, Entry: entry, ChangedAt: daysAgo(3)},
+				{Revision: 3, Entry: entry, ChangedAt: daysAgo(2)},
+			},
+			want: []int{2, 3},
+		},
+		{
+			name:   "MaxAge",
+			limit:  10,
+			maxAge: 7 * 24 * time.Hour,
+			revisions: []Revision{
+				{Revision: 1, Entry: entry},
+				{Revision: 2, Entry: entry, ChangedAt: daysAgo(20)},
+				// Replaced 5 days ago, so kept.
+				{Revision: 3, Entry: entry, ChangedAt: daysAgo(10)},
+				{Revision: 4, Entry: entry, ChangedAt: daysAgo(5)},
+			},
+			want: []int{3, 4},
+		},
+		{
+			name:   "MaxAgeKeepsLast",
+			limit:  10,
+			maxAge: 7 * 24 * time.Hour,
+			revisions: []Revision{
+				{Revision: 1, Entry: entry},
+				{Revision: 2, Entry: entry, ChangedAt: daysAgo(20)},
+			},
+			want: []int{2},
+		},
+		{
+			name:   "MaxAgeRemoved",
+			limit:  10,
+			maxAge: 7 * 24 * time.Hour,
+			revisions: []Revision{
+				{Revision: 1, Entry: entry, ChangedAt: daysAgo(30)},
+				{Revision: 2, ChangedAt: daysAgo(20)},
+			},
+			want: []int{},
+		},
+		{
+			name:   "RecentlyRemoved",
+			limit:  10,
+			maxAge: 7 * 24 * time.Hour,
+			revisions: []Revision{
+				{Revision: 1, Entry: entry, ChangedAt: daysAgo(30)},
+				{Revision: 2, ChangedAt: daysAgo(2)},
+			},
+			want: []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Crontinuous{historyLimit: tt.limit, historyMaxAge: tt.maxAge}
+			got := []int{}
+			for _, r := range c.retainRevisions(tt.revisions, now) {
+				got = append(got, r.Revision)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("kept revisions diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_PruneHistory(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	history := listingHistoryStore{&mockHistoryStore{revisions: map[string][]byte{}}}
+	saved := map[string][]Revision{
+		// Written before the limit was lowered.
+		"p1": {
+			{Revision: 1, Entry: json.RawMessage(`{"program_id":"p1"}`), ChangedAt: old},
+			{Revision: 2, Entry: json.RawMessage(`{"program_id":"p1"}`), ChangedAt: old},
+			{Revision: 3, Entry: json.RawMessage(`{"program_id":"p1"}`), ChangedAt: old},
+		},
+		// Removed before the max age.
+		"p2": {
+			{Revision: 1, Entry: json.RawMessage(`{"program_id":"p2"}`), ChangedAt: old},
+			{Revision: 2, ChangedAt: old},
+		},
+	}
+	for ID, revisions := range saved {
+		if err := history.SaveHistory(ScanCronType, ID, revisions); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithHistory(history, 2), WithHistoryRetention(24*time.Hour))
+	res, err := c.PruneHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := HistoryPruneResult{
+		Histories: map[CronType]int{ScanCronType: 1},
+		Revisions: map[CronType]int{ScanCronType: 1},
+		Pruned:    map[CronType]int{ScanCronType: 4},
+	}
+	if diff := cmp.Diff(want, res); diff != "" {
+		t.Errorf("prune result diff: %s", diff)
+	}
+	if _, ok := history.revisions["scan/p2"]; ok {
+		t.Errorf("want the history of the removed entry deleted")
+	}
+
+	metrics := map[string]float64{}
+	for _, m := range c.Metrics() {
+		if strings.HasPrefix(m.Name, "history_") {
+			metrics[m.Name] = m.Value
+		}
+	}
+	wantMetrics := map[string]float64{
+		"history_entries":                1,
+		"history_revisions":              1,
+		"history_pruned_revisions_total": 4,
+	}
+	if diff := cmp.Diff(wantMetrics, metrics); diff != "" {
+		t.Errorf("history metrics diff: %s", diff)
+	}
+}
@@ -0,0 +1,28 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import "errors"
+
+// IdempotencyKeyHeader is the header identifying a request changing the
+// entries, so retrying it returns the response to the first request instead
+// of applying it again.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is the header set to true in the responses
+// replayed for a request with an already used idempotency key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+var (
+	// ErrIdempotencyKeyInUse indicates a request with the same idempotency
+	// key is still being served.
+	ErrIdempotencyKeyInUse = errors.New("ErrorIdempotencyKeyInUse")
+	// ErrIdempotencyKeyReused indicates the idempotency key was already
+	// used by a request to another endpoint or with another body.
+	ErrIdempotencyKeyReused = errors.New("ErrorIdempotencyKeyReused")
+	// ErrMalformedIdempotencyKey indicates the idempotency key is empty,
+	// too long or contains non printable characters.
+	ErrMalformedIdempotencyKey = errors.New("ErrorMalformedIdempotencyKey")
+)
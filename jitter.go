@@ -0,0 +1,49 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// validateJitter returns ErrMalformedEntry
+// if the given jitter is negative.
+func validateJitter(d Duration) error {
+	if d < 0 {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// jitterDelay returns the delay of the executions of the entry with the given
+// ID, derived from the ID so it is the same for every execution and spread
+// between the entries.
+func jitterDelay(entryID string, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(entryID)) // nolint
+	return time.Duration(h.Sum64() % uint64(jitter))
+}
+
+// jitteredJob wraps the job of an entry so its executions are delayed.
+type jitteredJob struct {
+	entryJob
+	delay time.Duration
+}
+
+func (j *jitteredJob) run(ctx context.Context) error {
+	t := time.NewTimer(j.delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	return j.entryJob.run(ctx)
+}
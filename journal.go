@@ -0,0 +1,263 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// JournalOp identifies the kind of mutation stored in a journal record.
+type JournalOp string
+
+const (
+	// JournalOpSave indicates an entry was created or updated.
+	JournalOpSave JournalOp = "save"
+	// JournalOpRemove indicates an entry was removed.
+	JournalOpRemove JournalOp = "remove"
+)
+
+// JournalRecord defines a single entry mutation.
+type JournalRecord struct {
+	Type  CronType        `json:"type"`
+	Op    JournalOp       `json:"op"`
+	ID    string          `json:"id"`
+	Entry json.RawMessage `json:"entry,omitempty"`
+}
+
+// Journal stores entry mutations before they are applied to the in memory
+// entries, so mutations not yet persisted to the cron store can be replayed
+// after a crash.
+type Journal interface {
+	// Append durably stores the given records.
+	Append(records ...JournalRecord) error
+	// Records returns all the records not yet committed.
+	Records() ([]JournalRecord, error)
+	// Commit discards the records of the given type, it must be
+	// called after the entries of that type have been persisted.
+	Commit(typ CronType) error
+}
+
+// FileJournal implements a Journal backed by a local file
+// where each record is stored as a JSON line.
+type FileJournal struct {
+	path string
+	mux  sync.Mutex
+}
+
+// NewFileJournal creates a journal stored in the given file path.
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+func (j *FileJournal) Append(records ...JournalRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint
+
+	if err = trimPartialLine(f); err != nil {
+		return err
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (j *FileJournal) Records() ([]JournalRecord, error) {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.readRecords()
+}
+
+func (j *FileJournal) Commit(typ CronType) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	records, err := j.readRecords()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, r := range records {
+		if r.Type == typ {
+			continue
+		}
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	// Write to a temporary file and rename it so the
+	// journal is never left partially written.
+	tmp := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+func (j *FileJournal) readRecords() ([]JournalRecord, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close() // nolint
+
+	var (
+		records []JournalRecord
+		invalid error
+		n       int
+	)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		n++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		// Only the last line can be partially written, so an
+		// invalid line followed by others is a corrupted journal.
+		if invalid != nil {
+			return nil, invalid
+		}
+		var r JournalRecord
+		if err := json.Unmarshal(line, &r); err != nil {
+			invalid = fmt.Errorf("invalid journal record at line %d: %w", n, err)
+			continue
+		}
+		records = append(records, r)
+	}
+	// A partially written trailing line means the
+	// process crashed while appending, so the mutation
+	// was never applied and can be safely ignored.
+	return records, scanner.Err()
+}
+
+// trimPartialLine truncates the journal file after its last complete line,
+// removing the partially written line left by a crash while appending, so
+// the following records are not appended to it.
+func trimPartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	buf := make([]byte, 4096)
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			if complete := start + int64(i) + 1; complete < size {
+				return f.Truncate(complete)
+			}
+			return nil
+		}
+		end = start
+	}
+	if size > 0 {
+		return f.Truncate(0)
+	}
+	return nil
+}
+
+func (c *Crontinuous) journalAppend(records ...JournalRecord) error {
+	// The changes of a read-only replica are rejected before being
+	// recorded, so they are not replayed later.
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.journal == nil {
+		return nil
+	}
+	return c.journal.Append(records...)
+}
+
+func (c *Crontinuous) journalCommit(typ CronType) {
+	if c.journal == nil {
+		return
+	}
+	if err := c.journal.Commit(typ); err != nil {
+		c.log.WithError(err).Error("Error committing journal")
+	}
+}
+
+// journalRecords returns the pending journal records for the given type.
+func (c *Crontinuous) journalRecords(typ CronType) ([]JournalRecord, error) {
+	if c.journal == nil {
+		return nil, nil
+	}
+	records, err := c.journal.Records()
+	if err != nil {
+		return nil, err
+	}
+	var typRecords []JournalRecord
+	for _, r := range records {
+		if r.Type == typ {
+			typRecords = append(typRecords, r)
+		}
+	}
+	return typRecords, nil
+}
+
+func newSaveRecord(typ CronType, entry CronEntry) (JournalRecord, error) {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return JournalRecord{}, err
+	}
+	return JournalRecord{
+		Type:  typ,
+		Op:    JournalOpSave,
+		ID:    entry.GetID(),
+		Entry: content,
+	}, nil
+}
+
+func newRemoveRecord(typ CronType, ID string) JournalRecord {
+	return JournalRecord{
+		Type: typ,
+		Op:   JournalOpRemove,
+		ID:   ID,
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileJournal_Commit(t *testing.T) {
+	j := NewFileJournal(filepath.Join(t.TempDir(), "journal"))
+
+	scanRecord, err := newSaveRecord(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "* * * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportRecord := newRemoveRecord(ReportCronType, "t2")
+
+	if err := j.Append(scanRecord, reportRecord); err != nil {
+		t.Fatalf("Error appending records: %v", err)
+	}
+	if err := j.Commit(ScanCronType); err != nil {
+		t.Fatalf("Error committing journal: %v", err)
+	}
+
+	got, err := j.Records()
+	if err != nil {
+		t.Fatalf("Error reading records: %v", err)
+	}
+	if diff := cmp.Diff([]JournalRecord{reportRecord}, got); diff != "" {
+		t.Fatalf("records got!=want, diff %s", diff)
+	}
+}
+
+func TestFileJournal_InvalidLines(t *testing.T) {
+	r1 := newRemoveRecord(ScanCronType, "s1")
+	r2 := newRemoveRecord(ScanCronType, "s2")
+	line := func(r JournalRecord) string {
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b) + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		append  []JournalRecord
+		want    []JournalRecord
+		wantErr bool
+	}{
+		{
+			name:    "PartialLastLine",
+			content: line(r1) + `{"type":"scan","op":"rem`,
+			want:    []JournalRecord{r1},
+		},
+		{
+			name:    "InvalidMiddleLine",
+			content: line(r1) + "{invalid\n" + line(r2),
+			wantErr: true,
+		},
+		{
+			name:    "AppendAfterPartialLine",
+			content: line(r1) + `{"type":"scan","op":"rem`,
+			append:  []JournalRecord{r2},
+			want:    []JournalRecord{r1, r2},
+		},
+		{
+			name:    "AppendAfterOnlyPartialLine",
+			content: `{"type":"scan"`,
+			append:  []JournalRecord{r2},
+			want:    []JournalRecord{r2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "journal")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			j := NewFileJournal(path)
+			if err := j.Append(tt.append...); err != nil {
+				t.Fatalf("Error appending records: %v", err)
+			}
+
+			got, err := j.Records()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error got %v, want error %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("records got!=want, diff %s", diff)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_ReplayJournal(t *testing.T) {
+	j := NewFileJournal(filepath.Join(t.TempDir(), "journal"))
+
+	// Simulate mutations that were journaled but never
+	// persisted to the store before a crash.
+	saveRecord, err := newSaveRecord(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Append(saveRecord, newRemoveRecord(ScanCronType, "p1"), newRemoveRecord(ReportCronType, "t1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "* * * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "* * * * *"},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithJournal(j))
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	wantScan := map[string]ScanEntry{
+		"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"},
+	}
+	if diff := cmp.Diff(wantScan, store.scanEntries); diff != "" {
+		t.Errorf("stored scan entries got!=want, diff %s", diff)
+	}
+	if diff := cmp.Diff(map[string]ReportEntry{}, store.reportEntries); diff != "" {
+		t.Errorf("stored report entries got!=want, diff %s", diff)
+	}
+
+	records, err := j.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("journal should be empty after replay, got %d records", len(records))
+	}
+}
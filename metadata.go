@@ -0,0 +1,221 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// maxMetadataKeys is the maximum number of keys in the metadata of an
+	// entry.
+	maxMetadataKeys = 32
+
+	// maxMetadataSize is the maximum size, in bytes, of the keys and values
+	// of the metadata of an entry.
+	maxMetadataSize = 4096
+)
+
+var (
+	// ErrMalformedMetadataSchema indicates the metadata schema is not valid.
+	ErrMalformedMetadataSchema = errors.New("ErrorMalformedMetadataSchema")
+
+	// ErrMetadataSchemaViolation indicates the metadata of the entry does not
+	// match the metadata schema.
+	ErrMetadataSchemaViolation = errors.New("ErrorMetadataSchemaViolation")
+)
+
+// validateMetadata returns ErrMalformedEntry if the given metadata has empty
+// keys or exceeds the size limits.
+func validateMetadata(m map[string]string) error {
+	if len(m) > maxMetadataKeys {
+		return ErrMalformedEntry
+	}
+	var size int
+	for k, v := range m {
+		if k == "" {
+			return ErrMalformedEntry
+		}
+		size += len(k) + len(v)
+	}
+	if size > maxMetadataSize {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// MetadataSchema is the JSON schema the metadata of the entries must match.
+// As the metadata is a map of strings, only the following subset of the
+// keywords of JSON schema is supported, any other is rejected when the schema
+// is loaded:
+//
+//	{
+//	    "$schema": "http://json-schema.org/draft-07/schema#",
+//	    "type": "object",
+//	    "properties": {
+//	        "ticket": {"type": "string", "pattern": "^SEC-[0-9]+$"},
+//	        "cost_center": {"type": "string", "enum": ["security", "platform"]}
+//	    },
+//	    "required": ["cost_center"],
+//	    "additionalProperties": false,
+//	    "maxProperties": 8
+//	}
+type MetadataSchema struct {
+	Schema               string                            `json:"$schema,omitempty"`
+	Title                string                            `json:"title,omitempty"`
+	Description          string                            `json:"description,omitempty"`
+	Type                 string                            `json:"type,omitempty"`
+	Properties           map[string]MetadataPropertySchema `json:"properties,omitempty"`
+	Required             []string                          `json:"required,omitempty"`
+	AdditionalProperties *bool                             `json:"additionalProperties,omitempty"`
+	MaxProperties        *int                              `json:"maxProperties,omitempty"`
+}
+
+// MetadataPropertySchema is the JSON schema of the value of a key of the
+// metadata.
+type MetadataPropertySchema struct {
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	MinLength   *int     `json:"minLength,omitempty"`
+	MaxLength   *int     `json:"maxLength,omitempty"`
+}
+
+// Validate returns ErrMalformedMetadataSchema if the schema is not valid.
+func (s MetadataSchema) Validate() error {
+	if s.Type != "" && s.Type != "object" {
+		return ErrMalformedMetadataSchema
+	}
+	if s.MaxProperties != nil && *s.MaxProperties < 0 {
+		return ErrMalformedMetadataSchema
+	}
+	for _, p := range s.Properties {
+		if p.Type != "" && p.Type != "string" {
+			return ErrMalformedMetadataSchema
+		}
+		if _, err := regexp.Compile(p.Pattern); err != nil {
+			return ErrMalformedMetadataSchema
+		}
+		if (p.MinLength != nil && *p.MinLength < 0) || (p.MaxLength != nil && *p.MaxLength < 0) {
+			return ErrMalformedMetadataSchema
+		}
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrMetadataSchemaViolation, with the
+// offending key, if the given metadata does not match the schema.
+func (s MetadataSchema) Check(m map[string]string) error {
+	for _, k := range s.Required {
+		if _, ok := m[k]; !ok {
+			return fmt.Errorf("%w: missing required key %q", ErrMetadataSchemaViolation, k)
+		}
+	}
+	if s.MaxProperties != nil && len(m) > *s.MaxProperties {
+		return fmt.Errorf("%w: more than %d keys", ErrMetadataSchemaViolation, *s.MaxProperties)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p, ok := s.Properties[k]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return fmt.Errorf("%w: key %q not allowed", ErrMetadataSchemaViolation, k)
+			}
+			continue
+		}
+		if !p.matches(m[k]) {
+			return fmt.Errorf("%w: invalid value of key %q", ErrMetadataSchemaViolation, k)
+		}
+	}
+	return nil
+}
+
+func (p MetadataPropertySchema) matches(v string) bool {
+	if len(p.Enum) > 0 {
+		var found bool
+		for _, e := range p.Enum {
+			found = found || e == v
+		}
+		if !found {
+			return false
+		}
+	}
+	n := utf8.RuneCountInString(v)
+	if (p.MinLength != nil && n < *p.MinLength) || (p.MaxLength != nil && n > *p.MaxLength) {
+		return false
+	}
+	// The pattern is checked by Validate.
+	matched, err := regexp.MatchString(p.Pattern, v)
+	return err == nil && matched
+}
+
+// LoadMetadataSchema reads the metadata schema from the given JSON file.
+func LoadMetadataSchema(path string) (MetadataSchema, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return MetadataSchema{}, err
+	}
+	var s MetadataSchema
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return MetadataSchema{}, fmt.Errorf("%w: %v", ErrMalformedMetadataSchema, err)
+	}
+	if err := s.Validate(); err != nil {
+		return MetadataSchema{}, err
+	}
+	return s, nil
+}
+
+// WithMetadataSchema makes SaveEntry, BulkCreate and BulkReplace reject the
+// entries whose metadata does not match the given schema with
+// ErrMetadataSchemaViolation. The schema must be valid.
+func WithMetadataSchema(s MetadataSchema) Option {
+	return func(c *Crontinuous) {
+		c.metadataSchema = &s
+	}
+}
+
+// MetadataSchema returns the schema the metadata of the entries must match,
+// or nil if there is none.
+func (c *Crontinuous) MetadataSchema() *MetadataSchema {
+	return c.metadataSchema
+}
+
+// checkMetadata returns ErrMetadataSchemaViolation if the metadata of the
+// given entry does not match the metadata schema, if any. The reason is
+// logged, so the error can be matched by the clients of the API.
+func (c *Crontinuous) checkMetadata(e CronEntry) error {
+	if c.metadataSchema == nil {
+		return nil
+	}
+	if err := c.metadataSchema.Check(entryMetadata(e)); err != nil {
+		c.log.WithFields(logrus.Fields{
+			"type":     e.GetType().String(),
+			"entry_id": e.GetID(),
+		}).WithError(err).Info("Entry rejected by metadata schema")
+		return ErrMetadataSchemaViolation
+	}
+	return nil
+}
+
+// entryMetadata returns the metadata of the given entry.
+func entryMetadata(e CronEntry) map[string]string {
+	return entrySettings(e).Metadata
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testMetadataSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"properties": {
+		"ticket": {"type": "string", "pattern": "^SEC-[0-9]+$", "maxLength": 10},
+		"cost_center": {"type": "string", "enum": ["security", "platform"]}
+	},
+	"required": ["cost_center"],
+	"additionalProperties": false
+}`
+
+func TestLoadMetadataSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{name: "valid", content: testMetadataSchema},
+		{name: "not an object", content: `{"type": "array"}`, wantErr: true},
+		{name: "not a string", content: `{"properties": {"n": {"type": "integer"}}}`, wantErr: true},
+		{name: "invalid pattern", content: `{"properties": {"n": {"pattern": "("}}}`, wantErr: true},
+		{name: "unsupported keyword", content: `{"properties": {"n": {"format": "uri"}}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schema.json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadMetadataSchema(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrMalformedMetadataSchema) {
+				t.Errorf("want ErrMalformedMetadataSchema, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMetadataSchema_Check(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := ioutil.WriteFile(path, []byte(testMetadataSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadMetadataSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  bool
+	}{
+		{"valid", map[string]string{"ticket": "SEC-1234", "cost_center": "security"}, false},
+		{"missing required", map[string]string{"ticket": "SEC-1234"}, true},
+		{"not in enum", map[string]string{"cost_center": "finance"}, true},
+		{"pattern", map[string]string{"ticket": "OPS-1", "cost_center": "security"}, true},
+		{"max length", map[string]string{"ticket": "SEC-1234567", "cost_center": "security"}, true},
+		{"additional", map[string]string{"owner": "alice", "cost_center": "security"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Check(tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrMetadataSchemaViolation) {
+				t.Errorf("want ErrMetadataSchemaViolation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_Metadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := ioutil.WriteFile(path, []byte(testMetadataSchema), 0600); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := LoadMetadataSchema(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithMetadataSchema(schema))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	metadata := map[string]string{"ticket": "SEC-1234", "cost_center": "security"}
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "@daily", EntrySettings: EntrySettings{Metadata: metadata}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(metadata, got.(ScanEntry).Metadata); diff != "" {
+		t.Errorf("metadata mismatch (-want +got):\n%s", diff)
+	}
+
+	err = c.SaveEntry(ReportCronType, ReportEntry{TeamID: "t1", CronSpec: "@daily", EntrySettings: EntrySettings{Metadata: map[string]string{"ticket": "SEC-1"}}})
+	if !errors.Is(err, ErrMetadataSchemaViolation) {
+		t.Errorf("want ErrMetadataSchemaViolation, got %v", err)
+	}
+	err = c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "@daily"}}, []bool{true})
+	if !errors.Is(err, ErrMetadataSchemaViolation) {
+		t.Errorf("want ErrMetadataSchemaViolation, got %v", err)
+	}
+	_, err = c.BulkReplace(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "@daily"}}, BulkModeReplaceTeam)
+	if !errors.Is(err, ErrMetadataSchemaViolation) {
+		t.Errorf("want ErrMetadataSchemaViolation, got %v", err)
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := 0; i <= maxMetadataKeys; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  error
+	}{
+		{"empty", nil, nil},
+		{"valid", map[string]string{"ticket": "SEC-1234"}, nil},
+		{"empty key", map[string]string{"": "v"}, ErrMalformedEntry},
+		{"too many keys", tooMany, ErrMalformedEntry},
+		{"too large", map[string]string{"notes": strings.Repeat("x", maxMetadataSize)}, ErrMalformedEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "@daily", EntrySettings: EntrySettings{Metadata: tt.metadata}}
+			if err := e.Validate(); err != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,451 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	metricsNamespace = "crontinuous"
+
+	// topTeamsMetricsCount is the number of teams, with more
+	// entries, for which the entry count is exported.
+	topTeamsMetricsCount = 10
+)
+
+// SizeReporter is implemented by the stores able to report
+// the size in bytes of the persisted crontabs.
+type SizeReporter interface {
+	// StoredSizes returns the size in bytes of each
+	// persisted crontab keyed by crontab name.
+	StoredSizes() map[string]int64
+}
+
+var (
+	entriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "entries"),
+		"Number of entries by type.",
+		[]string{"type"}, nil,
+	)
+	unscheduledEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "unscheduled_entries"),
+		"Number of entries stored but not scheduled because the team is not whitelisted.",
+		[]string{"type"}, nil,
+	)
+	pausedEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "paused_entries"),
+		"Number of entries scheduled but whose executions are skipped at the moment.",
+		[]string{"type"}, nil,
+	)
+	quarantinedEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "quarantined_entries"),
+		"Number of entries scheduled but whose executions are skipped because the circuit of their team is open.",
+		[]string{"type"}, nil,
+	)
+	teamEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "team_entries"),
+		"Number of entries of the teams with more entries.",
+		[]string{"team"}, nil,
+	)
+	jobTimeoutsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "job_timeouts_total"),
+		"Number of job executions cancelled because they exceeded their execution timeout.",
+		[]string{"type"}, nil,
+	)
+	runningJobsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "running_jobs"),
+		"Number of jobs running by type.",
+		[]string{"type"}, nil,
+	)
+	waitingJobsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "waiting_jobs"),
+		"Number of jobs waiting for the running jobs of their type to be under the concurrency limit.",
+		[]string{"type"}, nil,
+	)
+	storeSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "store", "size_bytes"),
+		"Size in bytes of the persisted crontabs.",
+		[]string{"crontab"}, nil,
+	)
+	deadLettersDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "dead_letters_total"),
+		"Number of failed scan creations published to the dead letter queue.",
+		[]string{"type"}, nil,
+	)
+	deadLetterErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "dead_letter_errors_total"),
+		"Number of failed scan creations that could not be published to the dead letter queue.",
+		[]string{"type"}, nil,
+	)
+	teamCircuitOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "team_circuit_open"),
+		"Teams whose requests to vulcan-api are suspended because of server errors.",
+		[]string{"team"}, nil,
+	)
+	teamCircuitRejectionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "team_circuit_rejections_total"),
+		"Number of scan creations and report sendings not performed because the circuit of their team was open.",
+		[]string{"type"}, nil,
+	)
+	teamRuntimeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "team_runtime_seconds"),
+		"Time spent today by the jobs of the teams calling vulcan-api.",
+		[]string{"team"}, nil,
+	)
+	teamBudgetExhaustedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "team_budget_exhausted"),
+		"Teams whose executions are skipped because they exhausted their daily runtime budget.",
+		[]string{"team"}, nil,
+	)
+	engineRestartsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "engine_restarts_total"),
+		"Number of times the cron engine was replaced because it missed its heartbeats.",
+		nil, nil,
+	)
+	historyEntriesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "history", "entries"),
+		"Number of entries with history, measured by the last pruning.",
+		[]string{"type"}, nil,
+	)
+	historyRevisionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "history", "revisions"),
+		"Number of revisions in the history, measured by the last pruning.",
+		[]string{"type"}, nil,
+	)
+	historyPrunedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "history", "pruned_revisions_total"),
+		"Number of revisions discarded by the retention policy of the history.",
+		[]string{"type"}, nil,
+	)
+	scanPeakDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "scans_per_minute_peak"),
+		"Maximum number of scans created in the same minute by the schedule in the next 24h.",
+		nil, nil,
+	)
+	scanCapacityExceededDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metricsNamespace, "", "scan_capacity_exceeded_minutes"),
+		"Number of minutes in the next 24h in which the schedule creates more scans than the capacity of vulcan-api.",
+		nil, nil,
+	)
+)
+
+// MetricKind identifies how the value of a metric evolves.
+type MetricKind int
+
+const (
+	// GaugeMetric is a metric whose value can go up and down.
+	GaugeMetric MetricKind = iota
+	// CounterMetric is a metric whose value only increases.
+	CounterMetric
+)
+
+// Metric defines the value of a metric of crontinuous at a point in time.
+type Metric struct {
+	// Name is the name of the metric, without namespace.
+	Name   string
+	Kind   MetricKind
+	Value  float64
+	Labels map[string]string
+}
+
+// Metrics returns the current value of the metrics about the entries, the
+// jobs and the stores, so they can be exported to any metrics backend. The
+// entries are paused and quarantined for the same reasons GetEntryState
+// reports.
+func (c *Crontinuous) Metrics() []Metric {
+	var metrics []Metric
+	teamCounts := map[string]int{}
+	now := time.Now()
+	for _, typ := range c.cronTypes() {
+		entries, err := c.GetEntries(typ)
+		if err != nil {
+			continue
+		}
+		var unscheduled, paused, quarantined int
+		// The entries of a team are paused and quarantined for the same
+		// reason.
+		pausedTeams := map[string]bool{}
+		quarantinedTeams := map[string]bool{}
+		for _, e := range entries {
+			teamID := e.GetTeamID()
+			teamCounts[teamID]++
+			if !c.isTeamWhitelisted(typ, teamID) {
+				unscheduled++
+			}
+			if !c.ScheduleStatus(typ, e).Scheduled {
+				continue
+			}
+			isPaused, ok := pausedTeams[teamID]
+			if !ok {
+				budget := c.budgets.usage(teamID, now)
+				isPaused = c.pausedReason(typ, teamID, now, budget.Exhausted) != ""
+				pausedTeams[teamID] = isPaused
+				_, quarantinedTeams[teamID] = c.quarantinedUntil(typ, teamID)
+			}
+			if isPaused {
+				paused++
+			}
+			if quarantinedTeams[teamID] {
+				quarantined++
+			}
+		}
+		labels := map[string]string{"type": typ.String()}
+		metrics = append(metrics,
+			Metric{Name: "entries", Kind: GaugeMetric, Value: float64(len(entries)), Labels: labels},
+			Metric{Name: "unscheduled_entries", Kind: GaugeMetric, Value: float64(unscheduled), Labels: labels},
+			Metric{Name: "paused_entries", Kind: GaugeMetric, Value: float64(paused), Labels: labels},
+			Metric{Name: "quarantined_entries", Kind: GaugeMetric, Value: float64(quarantined), Labels: labels},
+		)
+	}
+
+	for _, tc := range topTeams(teamCounts, topTeamsMetricsCount) {
+		metrics = append(metrics, Metric{
+			Name:   "team_entries",
+			Kind:   GaugeMetric,
+			Value:  float64(tc.count),
+			Labels: map[string]string{"team": tc.team},
+		})
+	}
+
+	for typ, n := range c.timeouts.snapshot() {
+		metrics = append(metrics, Metric{
+			Name:   "job_timeouts_total",
+			Kind:   CounterMetric,
+			Value:  float64(n),
+			Labels: map[string]string{"type": typ.String()},
+		})
+	}
+
+	for name, counts := range map[string]map[string]int{
+		"running_jobs": c.running.snapshot(),
+		"waiting_jobs": c.waiting.snapshot(),
+	} {
+		for typ, n := range counts {
+			metrics = append(metrics, Metric{
+				Name:   name,
+				Kind:   GaugeMetric,
+				Value:  float64(n),
+				Labels: map[string]string{"type": typ},
+			})
+		}
+	}
+
+	for name, counts := range map[string]*typeCounts{
+		"dead_letters_total":       &c.deadLettersPublished,
+		"dead_letter_errors_total": &c.deadLetterErrors,
+	} {
+		for typ, n := range counts.snapshot() {
+			metrics = append(metrics, Metric{
+				Name:   name,
+				Kind:   CounterMetric,
+				Value:  float64(n),
+				Labels: map[string]string{"type": typ.String()},
+			})
+		}
+	}
+
+	if c.circuits != nil {
+		for _, team := range c.circuits.open() {
+			metrics = append(metrics, Metric{
+				Name:   "team_circuit_open",
+				Kind:   GaugeMetric,
+				Value:  1,
+				Labels: map[string]string{"team": team},
+			})
+		}
+		for typ, n := range c.circuits.rejected.snapshot() {
+			metrics = append(metrics, Metric{
+				Name:   "team_circuit_rejections_total",
+				Kind:   CounterMetric,
+				Value:  float64(n),
+				Labels: map[string]string{"type": typ.String()},
+			})
+		}
+	}
+
+	for team, u := range c.budgets.snapshot(time.Now()) {
+		labels := map[string]string{"team": team}
+		metrics = append(metrics, Metric{
+			Name:   "team_runtime_seconds",
+			Kind:   GaugeMetric,
+			Value:  time.Duration(u.Used).Seconds(),
+			Labels: labels,
+		})
+		if u.Exhausted {
+			metrics = append(metrics, Metric{Name: "team_budget_exhausted", Kind: GaugeMetric, Value: 1, Labels: labels})
+		}
+	}
+
+	if c.watchdog != nil {
+		metrics = append(metrics, Metric{
+			Name:  "engine_restarts_total",
+			Kind:  CounterMetric,
+			Value: float64(c.watchdog.restartCount()),
+		})
+	}
+
+	histories, revisions, pruned := c.historyStats.snapshot()
+	for typ, n := range histories {
+		metrics = append(metrics, Metric{
+			Name:   "history_entries",
+			Kind:   GaugeMetric,
+			Value:  float64(n),
+			Labels: map[string]string{"type": typ.String()},
+		})
+	}
+	for typ, n := range revisions {
+		metrics = append(metrics, Metric{
+			Name:   "history_revisions",
+			Kind:   GaugeMetric,
+			Value:  float64(n),
+			Labels: map[string]string{"type": typ.String()},
+		})
+	}
+	for typ, n := range pruned {
+		metrics = append(metrics, Metric{
+			Name:   "history_pruned_revisions_total",
+			Kind:   CounterMetric,
+			Value:  float64(n),
+			Labels: map[string]string{"type": typ.String()},
+		})
+	}
+
+	if c.settings().ScanCapacity > 0 {
+		if d, err := c.cachedScanDensity(); err == nil {
+			metrics = append(metrics,
+				Metric{Name: "scans_per_minute_peak", Kind: GaugeMetric, Value: float64(d.Peak)},
+				Metric{Name: "scan_capacity_exceeded_minutes", Kind: GaugeMetric, Value: float64(d.ExceededMinutes)},
+			)
+		}
+	}
+
+	sizes := map[string]int64{}
+	for _, store := range []interface{}{c.scanCronStore, c.reportCronStore, c.teamScanCronStore, c.commandCronStore} {
+		if sr, ok := store.(SizeReporter); ok {
+			for k, v := range sr.StoredSizes() {
+				sizes[k] = v
+			}
+		}
+	}
+	for k, v := range sizes {
+		metrics = append(metrics, Metric{
+			Name:   "store_size_bytes",
+			Kind:   GaugeMetric,
+			Value:  float64(v),
+			Labels: map[string]string{"crontab": k},
+		})
+	}
+	return metrics
+}
+
+// MetricsCollector implements a prometheus.Collector exporting
+// the metrics of a Crontinuous.
+// The values are computed each time the metrics are collected.
+type MetricsCollector struct {
+	c *Crontinuous
+}
+
+// NewMetricsCollector returns a collector for the given crontinuous instance.
+func NewMetricsCollector(c *Crontinuous) *MetricsCollector {
+	return &MetricsCollector{c: c}
+}
+
+// promMetrics are the descriptions of the metrics
+// exported to prometheus, keyed by metric name.
+var promMetrics = map[string]struct {
+	desc  *prometheus.Desc
+	label string
+}{
+	"entries":                        {entriesDesc, "type"},
+	"unscheduled_entries":            {unscheduledEntriesDesc, "type"},
+	"paused_entries":                 {pausedEntriesDesc, "type"},
+	"quarantined_entries":            {quarantinedEntriesDesc, "type"},
+	"team_entries":                   {teamEntriesDesc, "team"},
+	"job_timeouts_total":             {jobTimeoutsDesc, "type"},
+	"running_jobs":                   {runningJobsDesc, "type"},
+	"waiting_jobs":                   {waitingJobsDesc, "type"},
+	"dead_letters_total":             {deadLettersDesc, "type"},
+	"dead_letter_errors_total":       {deadLetterErrorsDesc, "type"},
+	"team_circuit_open":              {teamCircuitOpenDesc, "team"},
+	"team_circuit_rejections_total":  {teamCircuitRejectionsDesc, "type"},
+	"team_runtime_seconds":           {teamRuntimeDesc, "team"},
+	"team_budget_exhausted":          {teamBudgetExhaustedDesc, "team"},
+	"engine_restarts_total":          {engineRestartsDesc, ""},
+	"store_size_bytes":               {storeSizeDesc, "crontab"},
+	"history_entries":                {historyEntriesDesc, "type"},
+	"history_revisions":              {historyRevisionsDesc, "type"},
+	"history_pruned_revisions_total": {historyPrunedDesc, "type"},
+	"scans_per_minute_peak":          {scanPeakDesc, ""},
+	"scan_capacity_exceeded_minutes": {scanCapacityExceededDesc, ""},
+}
+
+func (m *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- entriesDesc
+	ch <- unscheduledEntriesDesc
+	ch <- pausedEntriesDesc
+	ch <- quarantinedEntriesDesc
+	ch <- teamEntriesDesc
+	ch <- jobTimeoutsDesc
+	ch <- runningJobsDesc
+	ch <- waitingJobsDesc
+	ch <- deadLettersDesc
+	ch <- deadLetterErrorsDesc
+	ch <- teamCircuitOpenDesc
+	ch <- teamCircuitRejectionsDesc
+	ch <- teamRuntimeDesc
+	ch <- teamBudgetExhaustedDesc
+	ch <- engineRestartsDesc
+	ch <- storeSizeDesc
+	ch <- historyEntriesDesc
+	ch <- historyRevisionsDesc
+	ch <- historyPrunedDesc
+	ch <- scanPeakDesc
+	ch <- scanCapacityExceededDesc
+}
+
+func (m *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range m.c.Metrics() {
+		pm, ok := promMetrics[metric.Name]
+		if !ok {
+			continue
+		}
+		valueType := prometheus.GaugeValue
+		if metric.Kind == CounterMetric {
+			valueType = prometheus.CounterValue
+		}
+		var labels []string
+		if pm.label != "" {
+			labels = append(labels, metric.Labels[pm.label])
+		}
+		ch <- prometheus.MustNewConstMetric(pm.desc, valueType, metric.Value, labels...)
+	}
+}
+
+type teamCount struct {
+	team  string
+	count int
+}
+
+// topTeams returns the k teams with more entries.
+func topTeams(counts map[string]int, k int) []teamCount {
+	var tcs []teamCount
+	for t, n := range counts {
+		tcs = append(tcs, teamCount{team: t, count: n})
+	}
+	sort.Slice(tcs, func(i, j int) bool {
+		if tcs[i].count != tcs[j].count {
+			return tcs[i].count > tcs[j].count
+		}
+		return tcs[i].team < tcs[j].team
+	})
+	if len(tcs) > k {
+		tcs = tcs[:k]
+	}
+	return tcs
+}
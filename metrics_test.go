@@ -0,0 +1,66 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrontinuous_EntriesMetrics(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"},
+			"p3": {ProgramID: "p3", TeamID: "t2", CronSpec: "0 3 * * *"},
+			"p4": {ProgramID: "p4", TeamID: "t3", CronSpec: "0 3 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * *"},
+		},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1", "t2"}}
+	c := NewCrontinuous(cfg, logrus.New(), nil, store, nil, store, WithTeamCircuit(1, time.Hour), WithPauseWindows(&memPauseWindowStore{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The entries of t1 are quarantined by its circuit, and the scans of t2
+	// paused by a pause window.
+	if _, err := c.circuits.enter("t1"); err != nil {
+		t.Fatal(err)
+	}
+	c.circuits.failed("t1")
+	always := PauseWindow{Name: "always", Types: []CronType{ScanCronType}, Teams: []string{"t2"},
+		CronSpec: "* * * * *", Duration: Duration(time.Hour)}
+	if err := c.SavePauseWindow(always); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]float64{}
+	for _, m := range c.Metrics() {
+		switch m.Name {
+		case "entries", "unscheduled_entries", "paused_entries", "quarantined_entries":
+			got[m.Name+"/"+m.Labels["type"]] = m.Value
+		}
+	}
+	want := map[string]float64{
+		"entries/scan":               4,
+		"unscheduled_entries/scan":   1,
+		"paused_entries/scan":        1,
+		"quarantined_entries/scan":   2,
+		"entries/report":             1,
+		"unscheduled_entries/report": 0,
+		"paused_entries/report":      0,
+		"quarantined_entries/report": 1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries metrics diff: %s", diff)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// maxOperations is the maximum number of operations kept. The oldest
+	// finished ones are discarded first.
+	maxOperations = 1000
+	// operationBatchSize is the number of entries of an operation created
+	// at once.
+	operationBatchSize = 100
+)
+
+// ErrOperationNotFound is returned when getting an operation that was not
+// started or is not kept anymore.
+var ErrOperationNotFound = errors.New("ErrorOperationNotFound")
+
+// OperationStatus defines the state of an operation.
+type OperationStatus string
+
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationCompleted OperationStatus = "completed"
+	// OperationCancelled indicates the operation was cancelled, or
+	// crontinuous stopped, before processing all the entries.
+	OperationCancelled OperationStatus = "cancelled"
+)
+
+// EntryResult defines the outcome of an entry of an operation.
+type EntryResult string
+
+const (
+	EntryCreated   EntryResult = "created"
+	EntryUpdated   EntryResult = "updated"
+	EntryUnchanged EntryResult = "unchanged"
+	EntryFailed    EntryResult = "failed"
+)
+
+// OperationEntry defines the result of an entry of an operation.
+type OperationEntry struct {
+	// Index is the position of the entry in the operation.
+	Index  int         `json:"index" yaml:"index"`
+	ID     string      `json:"id,omitempty" yaml:"id,omitempty"`
+	Result EntryResult `json:"result" yaml:"result"`
+	Error  string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Operation defines a bulk creation of entries performed in background.
+type Operation struct {
+	ID     string          `json:"id" yaml:"id"`
+	Type   CronType        `json:"type" yaml:"type"`
+	Status OperationStatus `json:"status" yaml:"status"`
+	// Total is the number of entries of the operation, and Processed and
+	// Failed the number of them already processed and failed.
+	Total      int              `json:"total" yaml:"total"`
+	Processed  int              `json:"processed" yaml:"processed"`
+	Failed     int              `json:"failed" yaml:"failed"`
+	Entries    []OperationEntry `json:"entries" yaml:"entries"`
+	StartedAt  time.Time        `json:"started_at" yaml:"started_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+}
+
+// operations holds the last operations started.
+type operations struct {
+	mux     sync.Mutex
+	started map[string]*Operation
+	cancels map[string]context.CancelFunc
+	order   []string
+}
+
+func (ops *operations) add(op Operation, cancel context.CancelFunc) {
+	ops.mux.Lock()
+	defer ops.mux.Unlock()
+
+	if ops.started == nil {
+		ops.started = map[string]*Operation{}
+		ops.cancels = map[string]context.CancelFunc{}
+	}
+	if len(ops.order) >= maxOperations {
+		// The running operations are kept, so they can be followed and
+		// cancelled.
+		for i, id := range ops.order {
+			if ops.started[id].Status != OperationRunning {
+				delete(ops.started, id)
+				ops.order = append(ops.order[:i], ops.order[i+1:]...)
+				break
+			}
+		}
+	}
+	ops.started[op.ID] = &op
+	ops.cancels[op.ID] = cancel
+	ops.order = append(ops.order, op.ID)
+}
+
+// progress records the results of the given entries of the operation with
+// the given ID.
+func (ops *operations) progress(id string, entries []OperationEntry) {
+	ops.mux.Lock()
+	defer ops.mux.Unlock()
+
+	op := ops.started[id]
+	for _, e := range entries {
+		if e.Result == EntryFailed {
+			op.Failed++
+		}
+	}
+	op.Processed += len(entries)
+	op.Entries = append(op.Entries, entries...)
+}
+
+func (ops *operations) finish(id string, status OperationStatus) {
+	ops.mux.Lock()
+	defer ops.mux.Unlock()
+
+	op := ops.started[id]
+	now := time.Now()
+	op.Status, op.FinishedAt = status, &now
+	ops.cancels[id]()
+	delete(ops.cancels, id)
+}
+
+// cancel cancels the operation with the given ID if it is running.
+func (ops *operations) cancel(id string) bool {
+	ops.mux.Lock()
+	defer ops.mux.Unlock()
+
+	if _, ok := ops.started[id]; !ok {
+		return false
+	}
+	if cancel, ok := ops.cancels[id]; ok {
+		cancel()
+	}
+	return true
+}
+
+func (ops *operations) get(id string) (Operation, bool) {
+	ops.mux.Lock()
+	defer ops.mux.Unlock()
+
+	op, ok := ops.started[id]
+	if !ok {
+		return Operation{}, false
+	}
+	got := *op
+	got.Entries = append([]OperationEntry{}, op.Entries...)
+	return got, true
+}
+
+// Operation returns the operation with the given ID. Only the last 1000
+// operations started since the start are kept.
+func (c *Crontinuous) Operation(id string) (Operation, error) {
+	op, ok := c.operations.get(id)
+	if !ok {
+		return Operation{}, ErrOperationNotFound
+	}
+	return op, nil
+}
+
+// CancelOperation stops the operation with the given ID after the entries
+// being created, if it is running, and returns it. The entries already
+// created are kept.
+func (c *Crontinuous) CancelOperation(id string) (Operation, error) {
+	if !c.operations.cancel(id) {
+		return Operation{}, ErrOperationNotFound
+	}
+	return c.Operation(id)
+}
+
+// StartBulkCreate performs in background the same as
+// BulkCreateWithOverwrite, without waiting for it to finish, and returns the
+// operation started, so its progress can be followed with Operation. Unlike
+// BulkCreateWithOverwrite, the entries are created in batches, so the
+// operation is not atomic: the entries are created as they are processed,
+// and if a batch fails its entries are created one by one, so only the
+// invalid ones fail.
+func (c *Crontinuous) StartBulkCreate(typ CronType, entries []CronEntry, policies []OverwritePolicy, opts ...SaveOption) (Operation, error) {
+	if len(policies) != len(entries) {
+		return Operation{}, ErrInvalidOverwrite
+	}
+	for _, p := range policies {
+		if !p.Valid() {
+			return Operation{}, ErrInvalidOverwrite
+		}
+	}
+	if _, err := c.entrySet(typ); err != nil {
+		return Operation{}, err
+	}
+
+	op := Operation{
+		ID:        NewRequestID(),
+		Type:      typ,
+		Status:    OperationRunning,
+		Total:     len(entries),
+		Entries:   []OperationEntry{},
+		StartedAt: time.Now(),
+	}
+	ctx := c.jobsCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.operations.add(op, cancel)
+
+	log := c.log.WithFields(logrus.Fields{
+		"operation": op.ID,
+		"type":      typ.String(),
+		"entries":   len(entries),
+	})
+	log.Info("Operation started")
+	go func() {
+		status := OperationCompleted
+		for start := 0; start < len(entries); start += operationBatchSize {
+			if ctx.Err() != nil {
+				status = OperationCancelled
+				break
+			}
+			end := start + operationBatchSize
+			if end > len(entries) {
+				end = len(entries)
+			}
+			results := c.createBatch(ctx, typ, start, entries[start:end], policies[start:end], opts)
+			c.operations.progress(op.ID, results)
+			if len(results) < end-start {
+				status = OperationCancelled
+				break
+			}
+		}
+		c.operations.finish(op.ID, status)
+		log.WithField("status", string(status)).Info("Operation finished")
+	}()
+	return op, nil
+}
+
+// createBatch creates the given entries of an operation, starting at the
+// given position, and returns their results. If they can not be created at
+// once, they are created one by one. If the operation is cancelled, the
+// entries not processed yet are left out of the results.
+func (c *Crontinuous) createBatch(ctx context.Context, typ CronType, start int, entries []CronEntry, policies []OverwritePolicy, opts []SaveOption) []OperationEntry {
+	ids, changes, err := c.bulkCreate(ctx, typ, entries, policies, opts...)
+	if err != nil && ctx.Err() != nil {
+		return nil
+	}
+	if err != nil && len(entries) > 1 {
+		var results []OperationEntry
+		for i := range entries {
+			r := c.createBatch(ctx, typ, start+i, entries[i:i+1], policies[i:i+1], opts)
+			if len(r) == 0 {
+				break
+			}
+			results = append(results, r...)
+		}
+		return results
+	}
+	if err != nil {
+		return []OperationEntry{{Index: start, ID: entryID(entries[0]), Result: EntryFailed, Error: err.Error()}}
+	}
+
+	actions := map[string]ChangeAction{}
+	for _, ch := range changes {
+		actions[ch.ID] = ch.Action
+	}
+	results := make([]OperationEntry, len(entries))
+	for i, id := range ids {
+		results[i] = OperationEntry{Index: start + i, ID: id, Result: EntryUnchanged}
+		switch actions[id] {
+		case ChangeAdd:
+			results[i].Result = EntryCreated
+		case ChangeUpdate:
+			results[i].Result = EntryUpdated
+		}
+	}
+	return results
+}
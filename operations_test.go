@@ -0,0 +1,168 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// blockingStore is a store whose saves of scan entries wait until released.
+type blockingStore struct {
+	mockCronStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.mockCronStore.SaveScanEntries(entries)
+}
+
+// waitOperation waits until the operation with the given ID finishes and
+// returns it.
+func waitOperation(t *testing.T, c *Crontinuous, id string) Operation {
+	t.Helper()
+	var op Operation
+	waitFor(t, func() bool {
+		var err error
+		if op, err = c.Operation(id); err != nil {
+			t.Fatal(err)
+		}
+		return op.Status != OperationRunning
+	})
+	return op
+}
+
+func TestCrontinuous_StartBulkCreate(t *testing.T) {
+	existing := map[string]ScanEntry{
+		"p0": {ProgramID: "p0", TeamID: "t1", CronSpec: "0 1 * * *"},
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+	}
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}}
+	c := newTestCrontinuous(Config{}, store, existing, &mockCronStore{}, nil)
+
+	var entries []CronEntry
+	var policies []OverwritePolicy
+	for i := 0; i < 2*operationBatchSize+10; i++ {
+		e := ScanEntry{ProgramID: fmt.Sprintf("p%d", i), TeamID: "t1", CronSpec: "0 2 * * *"}
+		if i == 150 {
+			e.CronSpec = "* *"
+		}
+		entries = append(entries, e)
+		policies = append(policies, OverwriteAll)
+	}
+	policies[1] = OverwriteMissingOnly
+
+	op, err := c.StartBulkCreate(ScanCronType, entries, policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op = waitOperation(t, c, op.ID)
+	if op.Status != OperationCompleted || op.Total != len(entries) || op.Processed != len(entries) || op.Failed != 1 {
+		t.Fatalf("want the operation completed with a failed entry, got %+v", op)
+	}
+	want := map[int]EntryResult{0: EntryUpdated, 1: EntryUnchanged, 2: EntryCreated, 150: EntryFailed, 209: EntryCreated}
+	for i, result := range want {
+		if got := op.Entries[i]; got.Index != i || got.Result != result {
+			t.Errorf("want entry %d %s, got %+v", i, result, got)
+		}
+	}
+	if !strings.HasPrefix(op.Entries[150].Error, ErrMalformedSchedule.Error()) {
+		t.Errorf("want the error of the failed entry, got %+v", op.Entries[150])
+	}
+	if len(c.scans.entries) != len(entries)-1 {
+		t.Errorf("want all the valid entries created, got %d", len(c.scans.entries))
+	}
+
+	if _, err := c.StartBulkCreate(ScanCronType, entries, policies[1:]); !errors.Is(err, ErrInvalidOverwrite) {
+		t.Errorf("want ErrInvalidOverwrite, got %v", err)
+	}
+	if _, err := c.Operation("unknown"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("want ErrOperationNotFound, got %v", err)
+	}
+}
+
+func TestCrontinuous_CancelOperation(t *testing.T) {
+	store := &blockingStore{
+		mockCronStore: mockCronStore{scanEntries: map[string]ScanEntry{}},
+		saving:        make(chan struct{}),
+		release:       make(chan struct{}),
+	}
+	c := newTestCrontinuous(Config{}, store, nil, &mockCronStore{}, nil)
+
+	var entries []CronEntry
+	var policies []OverwritePolicy
+	for i := 0; i < 3*operationBatchSize; i++ {
+		entries = append(entries, ScanEntry{ProgramID: fmt.Sprintf("p%d", i), TeamID: "t1", CronSpec: "0 2 * * *"})
+		policies = append(policies, OverwriteAll)
+	}
+	op, err := c.StartBulkCreate(ScanCronType, entries, policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch is created before stopping.
+	<-store.saving
+	if _, err := c.CancelOperation(op.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(store.release)
+	op = waitOperation(t, c, op.ID)
+	if op.Status != OperationCancelled || op.Processed != operationBatchSize || len(c.scans.entries) != operationBatchSize {
+		t.Errorf("want the operation cancelled after the first batch, got %s with %d processed", op.Status, op.Processed)
+	}
+
+	if _, err := c.CancelOperation("unknown"); !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("want ErrOperationNotFound, got %v", err)
+	}
+}
+
+// signalingValidator signals the start of the validations and blocks them
+// until their context is done.
+type signalingValidator struct {
+	blockingValidator
+	validating chan struct{}
+}
+
+func (v *signalingValidator) GetTeamContext(ctx context.Context, teamID string) (Team, error) {
+	v.validating <- struct{}{}
+	return v.blockingValidator.GetTeamContext(ctx, teamID)
+}
+
+func TestCrontinuous_CancelOperationInBatch(t *testing.T) {
+	v := &signalingValidator{validating: make(chan struct{})}
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, &mockCronStore{}, WithValidator(v))
+	c.cron = NewForkScheduler()
+
+	var entries []CronEntry
+	var policies []OverwritePolicy
+	for i := 0; i < 2*operationBatchSize; i++ {
+		entries = append(entries, ScanEntry{ProgramID: fmt.Sprintf("p%d", i), TeamID: "t1", CronSpec: "0 2 * * *"})
+		policies = append(policies, OverwriteAll)
+	}
+	op, err := c.StartBulkCreate(ScanCronType, entries, policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The operation is cancelled while validating the first batch, so its
+	// entries are not created one by one.
+	<-v.validating
+	if _, err := c.CancelOperation(op.ID); err != nil {
+		t.Fatal(err)
+	}
+	op = waitOperation(t, c, op.ID)
+	if op.Status != OperationCancelled || op.Processed != 0 || op.Failed != 0 || len(op.Entries) != 0 {
+		t.Errorf("want the operation cancelled with no entry processed, got %+v", op)
+	}
+}
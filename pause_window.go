@@ -0,0 +1,297 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/manelmontilla/cron"
+)
+
+// S3PauseWindowsFilename is the key of the object storing the pause windows.
+const S3PauseWindowsFilename = "pause-windows.json"
+
+var (
+	// ErrPauseWindowsDisabled indicates no pause window store is configured.
+	ErrPauseWindowsDisabled = errors.New("ErrorPauseWindowsDisabled")
+	// ErrPauseWindowNotFound indicates the pause window does not exist.
+	ErrPauseWindowNotFound = errors.New("ErrorPauseWindowNotFound")
+	// ErrMalformedPauseWindow indicates the pause window is not valid.
+	ErrMalformedPauseWindow = errors.New("ErrorMalformedPauseWindow")
+)
+
+// pauseWindowNameRe matches the valid names of the pause windows,
+// e.g. weekend-freeze.
+var pauseWindowNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// PauseWindow defines a period in which the jobs of the entries of the given
+// types and teams, all of them if empty, are skipped. The period starts on
+// every activation of the cron spec and lasts the given duration. For
+// instance, the following window pauses the scans of a team from friday at
+// 16:00 to sunday at 22:00:
+//
+//	{"name": "weekend-freeze", "types": ["scan", "team-scan"], "teams": ["461a62aa-6e1c-11e8-802e-4c32758b498f"], "cron_spec": "0 16 * * 5", "duration": "54h"}
+type PauseWindow struct {
+	Name     string     `json:"name" yaml:"name"`
+	Types    []CronType `json:"types,omitempty" yaml:"types,omitempty"`
+	Teams    []string   `json:"teams,omitempty" yaml:"teams,omitempty"`
+	CronSpec string     `json:"cron_spec" yaml:"cron_spec"`
+	Duration Duration   `json:"duration" yaml:"duration"`
+	// Reason is logged when a job is skipped because of the window.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Validate returns ErrMalformedPauseWindow if the window is not valid.
+func (w PauseWindow) Validate() error {
+	if !pauseWindowNameRe.MatchString(w.Name) || w.Duration <= 0 {
+		return ErrMalformedPauseWindow
+	}
+	if _, err := w.schedule(); err != nil {
+		return ErrMalformedPauseWindow
+	}
+	for _, typ := range w.Types {
+		if _, ok := cronTypeNames[typ]; !ok {
+			return ErrMalformedPauseWindow
+		}
+	}
+	return nil
+}
+
+// schedule returns the schedule of the starts of the window. The H tokens of
+// the spec are resolved using the name of the window.
+func (w PauseWindow) schedule() (cron.Schedule, error) {
+	spec, err := ResolveCronSpec(w.CronSpec, w.Name)
+	if err != nil {
+		return nil, err
+	}
+	return cron.ParseStandard(spec)
+}
+
+// ActiveAt returns true if the window is active at the given time, that is,
+// if it started in the duration before it.
+func (w PauseWindow) ActiveAt(t time.Time) bool {
+	s, err := w.schedule()
+	if err != nil {
+		return false
+	}
+	return !s.Next(t.Add(-time.Duration(w.Duration))).After(t)
+}
+
+// appliesTo returns true if the window applies to the entries of the given
+// type and team.
+func (w PauseWindow) appliesTo(typ CronType, teamID string) bool {
+	if len(w.Types) > 0 {
+		var found bool
+		for _, t := range w.Types {
+			found = found || t == typ
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(w.Teams) > 0 {
+		var found bool
+		for _, t := range w.Teams {
+			found = found || t == teamID
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// PauseWindowStore defines the services needed to persist the pause windows.
+type PauseWindowStore interface {
+	GetPauseWindows() (map[string]PauseWindow, error)
+	SavePauseWindows(windows map[string]PauseWindow) error
+}
+
+// WithPauseWindows enables the pause windows, persisting them in the given
+// store.
+func WithPauseWindows(store PauseWindowStore) Option {
+	return func(c *Crontinuous) {
+		c.pauseWindowStore = store
+	}
+}
+
+// loadPauseWindows returns the pause windows, reading them from the store
+// the first time. It must be called holding the pause windows lock.
+func (c *Crontinuous) loadPauseWindows() (map[string]PauseWindow, error) {
+	if c.pauseWindows != nil {
+		return c.pauseWindows, nil
+	}
+	windows, err := c.pauseWindowStore.GetPauseWindows()
+	if err != nil {
+		return nil, err
+	}
+	if windows == nil {
+		windows = map[string]PauseWindow{}
+	}
+	c.pauseWindows = windows
+	return windows, nil
+}
+
+// PauseWindows returns the pause windows sorted by name.
+func (c *Crontinuous) PauseWindows() ([]PauseWindow, error) {
+	if c.pauseWindowStore == nil {
+		return nil, ErrPauseWindowsDisabled
+	}
+	c.pauseWindowsMux.Lock()
+	defer c.pauseWindowsMux.Unlock()
+
+	loaded, err := c.loadPauseWindows()
+	if err != nil {
+		return nil, err
+	}
+	windows := []PauseWindow{}
+	for _, w := range loaded {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Name < windows[j].Name
+	})
+	return windows, nil
+}
+
+// PauseWindow returns the pause window with the given name.
+func (c *Crontinuous) PauseWindow(name string) (PauseWindow, error) {
+	if c.pauseWindowStore == nil {
+		return PauseWindow{}, ErrPauseWindowsDisabled
+	}
+	c.pauseWindowsMux.Lock()
+	defer c.pauseWindowsMux.Unlock()
+
+	windows, err := c.loadPauseWindows()
+	if err != nil {
+		return PauseWindow{}, err
+	}
+	w, ok := windows[name]
+	if !ok {
+		return PauseWindow{}, ErrPauseWindowNotFound
+	}
+	return w, nil
+}
+
+// SavePauseWindow creates or updates the given pause window. It applies to
+// the executions started after it is saved.
+func (c *Crontinuous) SavePauseWindow(w PauseWindow) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.pauseWindowStore == nil {
+		return ErrPauseWindowsDisabled
+	}
+	if err := w.Validate(); err != nil {
+		return err
+	}
+
+	c.pauseWindowsMux.Lock()
+	defer c.pauseWindowsMux.Unlock()
+
+	current, err := c.loadPauseWindows()
+	if err != nil {
+		return err
+	}
+	windows := make(map[string]PauseWindow, len(current)+1)
+	for name, pw := range current {
+		windows[name] = pw
+	}
+	windows[w.Name] = w
+	if err := c.pauseWindowStore.SavePauseWindows(windows); err != nil {
+		return err
+	}
+	c.pauseWindows = windows
+	return nil
+}
+
+// RemovePauseWindow removes the pause window with the given name.
+func (c *Crontinuous) RemovePauseWindow(name string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.pauseWindowStore == nil {
+		return ErrPauseWindowsDisabled
+	}
+
+	c.pauseWindowsMux.Lock()
+	defer c.pauseWindowsMux.Unlock()
+
+	current, err := c.loadPauseWindows()
+	if err != nil {
+		return err
+	}
+	if _, ok := current[name]; !ok {
+		return ErrPauseWindowNotFound
+	}
+	windows := make(map[string]PauseWindow, len(current))
+	for n, pw := range current {
+		if n != name {
+			windows[n] = pw
+		}
+	}
+	if err := c.pauseWindowStore.SavePauseWindows(windows); err != nil {
+		return err
+	}
+	c.pauseWindows = windows
+	return nil
+}
+
+// currentPauseWindows returns the pause windows, none if they are disabled.
+// The windows that can not be read from the store are logged and ignored, as
+// a missed execution is worse than an unexpected one.
+func (c *Crontinuous) currentPauseWindows() []PauseWindow {
+	if c.pauseWindowStore == nil {
+		return nil
+	}
+	windows, err := c.PauseWindows()
+	if err != nil {
+		c.log.WithError(err).Error("Error reading pause windows, ignoring them")
+		return nil
+	}
+	return windows
+}
+
+// activePauseWindow returns the first pause window, by name, applying to the
+// entries of the given type and team that is active at the given time.
+func (c *Crontinuous) activePauseWindow(typ CronType, teamID string, t time.Time) (PauseWindow, bool) {
+	return pausingWindow(c.currentPauseWindows(), typ, teamID, t)
+}
+
+// pausingWindow returns the first of the given windows applying to the
+// entries of the given type and team that is active at the given time.
+func pausingWindow(windows []PauseWindow, typ CronType, teamID string, t time.Time) (PauseWindow, bool) {
+	for _, w := range windows {
+		if w.appliesTo(typ, teamID) && w.ActiveAt(t) {
+			return w, true
+		}
+	}
+	return PauseWindow{}, false
+}
+
+// pausedJob wraps the job of an entry so it is not executed while a pause
+// window applying to it is active.
+type pausedJob struct {
+	entryJob
+	c *Crontinuous
+}
+
+func (j *pausedJob) run(ctx context.Context) error {
+	if w, ok := j.c.activePauseWindow(j.cronType(), j.team(), time.Now()); ok {
+		j.c.log.WithFields(logrus.Fields{
+			"team":         j.team(),
+			"type":         j.cronType().String(),
+			"pause_window": w.Name,
+			"reason":       w.Reason,
+		}).Info("Skipping job, in a pause window")
+		return skipJob(SkipPauseWindow, "pause window "+w.Name)
+	}
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type memPauseWindowStore struct {
+	windows map[string]PauseWindow
+}
+
+func (s *memPauseWindowStore) GetPauseWindows() (map[string]PauseWindow, error) {
+	return s.windows, nil
+}
+
+func (s *memPauseWindowStore) SavePauseWindows(windows map[string]PauseWindow) error {
+	s.windows = windows
+	return nil
+}
+
+func TestPauseWindow_ActiveAt(t *testing.T) {
+	w := PauseWindow{Name: "weekend-freeze", CronSpec: "0 16 * * 5", Duration: Duration(54 * time.Hour)}
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2020, 5, 15, 15, 59, 0, 0, time.Local), false},
+		{time.Date(2020, 5, 15, 16, 0, 0, 0, time.Local), true},
+		{time.Date(2020, 5, 16, 12, 0, 0, 0, time.Local), true},
+		{time.Date(2020, 5, 17, 21, 59, 0, 0, time.Local), true},
+		{time.Date(2020, 5, 17, 22, 1, 0, 0, time.Local), false},
+		{time.Date(2020, 5, 18, 10, 0, 0, 0, time.Local), false},
+	}
+	for _, tt := range tests {
+		if got := w.ActiveAt(tt.t); got != tt.want {
+			t.Errorf("ActiveAt(%s): want %v, got %v", tt.t, tt.want, got)
+		}
+	}
+}
+
+func TestPauseWindow_Validate(t *testing.T) {
+	valid := PauseWindow{Name: "freeze", CronSpec: "H 16 * * 5", Duration: Duration(time.Hour)}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	invalid := []PauseWindow{
+		{Name: "Invalid Name", CronSpec: "0 16 * * 5", Duration: Duration(time.Hour)},
+		{Name: "freeze", CronSpec: "invalid", Duration: Duration(time.Hour)},
+		{Name: "freeze", CronSpec: "0 16 * * 5"},
+		{Name: "freeze", CronSpec: "0 16 * * 5", Duration: Duration(time.Hour), Types: []CronType{CronType(42)}},
+	}
+	for _, w := range invalid {
+		if err := w.Validate(); err != ErrMalformedPauseWindow {
+			t.Errorf("%+v: want ErrMalformedPauseWindow, got %v", w, err)
+		}
+	}
+}
+
+func TestCrontinuous_PauseWindows(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 0 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithPauseWindows(&memPauseWindowStore{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	always := PauseWindow{Name: "always", Types: []CronType{ScanCronType}, Teams: []string{"t1"},
+		CronSpec: "* * * * *", Duration: Duration(time.Hour), Reason: "testing"}
+	if err := c.SavePauseWindow(always); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SavePauseWindow(PauseWindow{Name: "invalid"}); err != ErrMalformedPauseWindow {
+		t.Errorf("want ErrMalformedPauseWindow, got %v", err)
+	}
+
+	now := time.Now()
+	if w, ok := c.activePauseWindow(ScanCronType, "t1", now); !ok || w.Name != always.Name {
+		t.Errorf("want the window active for t1, got %v %+v", ok, w)
+	}
+	if _, ok := c.activePauseWindow(ScanCronType, "t2", now); ok {
+		t.Error("want no window active for t2")
+	}
+	if _, ok := c.activePauseWindow(ReportCronType, "t1", now); ok {
+		t.Error("want no window active for the reports of t1")
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != ErrTeamNotAllowed {
+		t.Errorf("want ErrTeamNotAllowed, got %v", err)
+	}
+
+	executions, err := c.Simulate(now, now.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range executions {
+		if e.TeamID == "t1" {
+			t.Errorf("want no executions of t1 inside the window, got %+v", e)
+		}
+	}
+	if len(executions) == 0 {
+		t.Error("want the executions of t2")
+	}
+
+	if err := c.RemovePauseWindow(always.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemovePauseWindow(always.Name); err != ErrPauseWindowNotFound {
+		t.Errorf("want ErrPauseWindowNotFound, got %v", err)
+	}
+	if _, ok := c.activePauseWindow(ScanCronType, "t1", now); ok {
+		t.Error("want no window active after removing it")
+	}
+}
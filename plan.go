@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChangeAction identifies the kind of change needed to make an entry match
+// its desired state.
+type ChangeAction string
+
+const (
+	// ChangeAdd indicates the entry must be created.
+	ChangeAdd ChangeAction = "add"
+	// ChangeUpdate indicates the entry must be updated.
+	ChangeUpdate ChangeAction = "change"
+	// ChangeDelete indicates the entry must be removed.
+	ChangeDelete ChangeAction = "delete"
+)
+
+// Change defines a modification of an entry. Current is nil
+// when the entry is added and Desired is nil when it is deleted.
+type Change struct {
+	Action  ChangeAction `json:"action" yaml:"action"`
+	Type    CronType     `json:"type" yaml:"type"`
+	ID      string       `json:"id" yaml:"id"`
+	Current CronEntry    `json:"current,omitempty" yaml:"current,omitempty"`
+	Desired CronEntry    `json:"desired,omitempty" yaml:"desired,omitempty"`
+}
+
+// Manifest defines the desired scan and report entries.
+type Manifest struct {
+	Scans   []ScanEntry   `json:"scans" yaml:"scans"`
+	Reports []ReportEntry `json:"reports" yaml:"reports"`
+}
+
+// Types returns the types of entries defined by a manifest.
+func (m Manifest) Types() []CronType {
+	return []CronType{ScanCronType, ReportCronType}
+}
+
+// Entries returns the entries of the given type defined in the manifest.
+func (m Manifest) Entries(typ CronType) []CronEntry {
+	var entries []CronEntry
+	switch typ {
+	case ScanCronType:
+		for _, e := range m.Scans {
+			entries = append(entries, e)
+		}
+	case ReportCronType:
+		for _, e := range m.Reports {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Validate checks all the entries of the manifest are valid and
+// there are no two entries of the same type with the same ID.
+func (m Manifest) Validate() error {
+	for _, typ := range m.Types() {
+		ids := map[string]bool{}
+		for _, e := range m.Entries(typ) {
+			if err := validateEntry(typ, e); err != nil {
+				return entryError(e, err)
+			}
+			if ids[e.GetID()] {
+				return entryError(e, ErrMalformedEntry)
+			}
+			ids[e.GetID()] = true
+		}
+	}
+	return nil
+}
+
+// Diff returns, sorted by ID, the changes needed to make the current
+// entries of the given type match the desired ones.
+func Diff(typ CronType, current, desired []CronEntry) []Change {
+	currentByID := map[string]CronEntry{}
+	for _, e := range current {
+		currentByID[e.GetID()] = e
+	}
+
+	var changes []Change
+	desiredIDs := map[string]bool{}
+	for _, d := range desired {
+		desiredIDs[d.GetID()] = true
+		c, ok := currentByID[d.GetID()]
+		if !ok {
+			changes = append(changes, Change{Action: ChangeAdd, Type: typ, ID: d.GetID(), Desired: d})
+			continue
+		}
+		if !sameEntry(c, d) {
+			changes = append(changes, Change{Action: ChangeUpdate, Type: typ, ID: d.GetID(), Current: c, Desired: d})
+		}
+	}
+	for _, c := range current {
+		if !desiredIDs[c.GetID()] {
+			changes = append(changes, Change{Action: ChangeDelete, Type: typ, ID: c.GetID(), Current: c})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].ID < changes[j].ID
+	})
+	return changes
+}
+
+// Apply makes the entries of the types defined in the manifest match it and
+// returns the changes performed. If a change fails, Apply stops and returns
+// the changes performed until then together with the error.
+func (c *Crontinuous) Apply(m Manifest) ([]Change, error) {
+	m, err := c.applyManifestTemplates(m)
+	if err != nil {
+		return nil, err
+	}
+	if m, err = c.identifyManifest(m); err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, typ := range m.Types() {
+		current, err := c.GetEntries(typ)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Diff(typ, current, m.Entries(typ))...)
+	}
+
+	for i, ch := range changes {
+		var err error
+		switch ch.Action {
+		case ChangeAdd, ChangeUpdate:
+			err = c.SaveEntry(ch.Type, ch.Desired)
+		case ChangeDelete:
+			err = c.RemoveEntry(ch.Type, ch.ID)
+		}
+		if err != nil {
+			return changes[:i], fmt.Errorf("applying %s of %s entry %s: %w", ch.Action, ch.Type, ch.ID, err)
+		}
+	}
+	return changes, nil
+}
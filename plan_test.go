@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	current := []CronEntry{
+		ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+		ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"},
+		ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "0 2 * * *"},
+	}
+	desired := []CronEntry{
+		ScanEntry{ProgramID: "p4", TeamID: "t2", CronSpec: "0 3 * * *"},
+		ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 5 * * *"},
+		ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+	}
+	want := []Change{
+		{Action: ChangeUpdate, Type: ScanCronType, ID: "p2", Current: current[1], Desired: desired[1]},
+		{Action: ChangeDelete, Type: ScanCronType, ID: "p3", Current: current[2]},
+		{Action: ChangeAdd, Type: ScanCronType, ID: "p4", Desired: desired[0]},
+	}
+
+	got := Diff(ScanCronType, current, desired)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("changes got!=want, diff %s", diff)
+	}
+}
+
+func TestManifest_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		wantErr  error
+	}{
+		{
+			name: "Valid",
+			manifest: Manifest{
+				Scans:   []ScanEntry{{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"}},
+				Reports: []ReportEntry{{TeamID: "t1", CronSpec: "0 8 * * 1"}},
+			},
+		},
+		{
+			name: "MalformedSchedule",
+			manifest: Manifest{
+				Reports: []ReportEntry{{TeamID: "t1", CronSpec: "every monday"}},
+			},
+			wantErr: ErrMalformedSchedule,
+		},
+		{
+			name: "DuplicatedEntry",
+			manifest: Manifest{
+				Scans: []ScanEntry{
+					{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+					{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+				},
+			},
+			wantErr: ErrMalformedEntry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.manifest.Validate()
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ProvisionConfig defines the entries created for new teams. The specs can
+// contain the placeholders {minute}, {hour}, {weekday} and {day}, which are
+// replaced by values derived from the team ID, so the executions of the
+// entries of different teams are spread over time.
+type ProvisionConfig struct {
+	// TeamScanSpec is the cron spec of the team scan entry created for new
+	// teams. Empty disables the provisioning of team scan entries.
+	TeamScanSpec string
+	// ReportSpec is the cron spec of the report entry created for new
+	// teams. Empty disables the provisioning of report entries.
+	ReportSpec string
+	// Interval is the time between the checks for new teams.
+	// Zero disables the periodic checks.
+	Interval time.Duration
+}
+
+// Provisioner creates default entries for teams without entries.
+type Provisioner struct {
+	cfg    ProvisionConfig
+	c      *Crontinuous
+	lister TeamLister
+	log    *logrus.Logger
+
+	mux   sync.Mutex
+	known map[string]bool
+}
+
+// NewProvisioner creates a provisioner of the entries of the given crontinuous
+// that finds new teams using the given lister. It returns ErrMalformedSchedule
+// if any of the configured specs is invalid.
+func NewProvisioner(cfg ProvisionConfig, c *Crontinuous, lister TeamLister, logger *logrus.Logger) (*Provisioner, error) {
+	for _, spec := range []string{cfg.TeamScanSpec, cfg.ReportSpec} {
+		if spec == "" {
+			continue
+		}
+		if err := validateCronSpec(staggerSpec(spec, "")); err != nil {
+			return nil, err
+		}
+	}
+	return &Provisioner{
+		cfg:    cfg,
+		c:      c,
+		lister: lister,
+		log:    logger,
+	}, nil
+}
+
+// Provision creates the default entries of the given team. An entry is only
+// created if the team has no entries of the same kind, team scan entries and
+// scan entries are considered the same kind. The entries created are returned.
+func (p *Provisioner) Provision(teamID string) ([]CronEntry, error) {
+	var created []CronEntry
+	// Team scan entries can only be created when team scans are enabled.
+	if p.cfg.TeamScanSpec != "" && p.c.teamScansEnabled() {
+		covered, err := p.hasEntries(teamID, ScanCronType, TeamScanCronType)
+		if err != nil {
+			return created, err
+		}
+		if !covered {
+			e := TeamScanEntry{TeamID: teamID, CronSpec: staggerSpec(p.cfg.TeamScanSpec, teamID)}
+			if err := p.c.SaveEntry(TeamScanCronType, e); err != nil {
+				return created, err
+			}
+			created = append(created, e)
+		}
+	}
+	if p.cfg.ReportSpec != "" {
+		covered, err := p.hasEntries(teamID, ReportCronType)
+		if err != nil {
+			return created, err
+		}
+		if !covered {
+			e := ReportEntry{TeamID: teamID, CronSpec: staggerSpec(p.cfg.ReportSpec, teamID)}
+			if err := p.c.SaveEntry(ReportCronType, e); err != nil {
+				return created, err
+			}
+			created = append(created, e)
+		}
+	}
+	if len(created) > 0 {
+		p.log.WithFields(logrus.Fields{
+			"team":    teamID,
+			"entries": len(created),
+		}).Info("Provisioned default entries")
+	}
+	return created, nil
+}
+
+// Run checks for new teams on every interval until the context is done. The
+// teams existing on the first check are not provisioned, only the ones that
+// appear later.
+func (p *Provisioner) Run(ctx context.Context) {
+	if p.cfg.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := p.provisionNewTeams(ctx); err != nil {
+			p.log.WithError(err).Error("Error provisioning new teams")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Provisioner) provisionNewTeams(ctx context.Context) error {
+	teams, err := ListTeams(ctx, p.lister)
+	if err != nil {
+		return err
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.known == nil {
+		p.known = map[string]bool{}
+		for _, t := range teams {
+			p.known[t] = true
+		}
+		return nil
+	}
+	for _, t := range teams {
+		if p.known[t] {
+			continue
+		}
+		if _, err := p.Provision(t); err != nil {
+			// Try again in the next check.
+			p.log.WithError(err).WithField("team", t).Error("Error provisioning team")
+			continue
+		}
+		p.known[t] = true
+	}
+	return nil
+}
+
+func (p *Provisioner) hasEntries(teamID string, types ...CronType) (bool, error) {
+	for _, typ := range types {
+		entries, err := p.c.GetEntries(typ)
+		if err != nil {
+			return false, err
+		}
+		for _, e := range entries {
+			if e.GetTeamID() == teamID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// staggerSpec replaces the placeholders of the given spec
+// with values derived from the team ID.
+func staggerSpec(spec, teamID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(teamID)) // nolint
+	n := h.Sum32()
+
+	r := strings.NewReplacer(
+		"{minute}", strconv.Itoa(int(n%60)),
+		"{hour}", strconv.Itoa(int(n/60%24)),
+		"{weekday}", strconv.Itoa(int(n/(60*24)%7)),
+		"{day}", strconv.Itoa(int(n/(60*24*7)%28+1)),
+	)
+	return r.Replace(spec)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockTeamLister struct {
+	teams []string
+}
+
+func (l *mockTeamLister) ListTeams() ([]string, error) {
+	return l.teams, nil
+}
+
+func TestProvisioner_provisionNewTeams(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	lister := &mockTeamLister{teams: []string{"t1"}}
+	cfg := ProvisionConfig{
+		// Team scans are not enabled, so no scans are provisioned.
+		TeamScanSpec: "{minute} {hour} * * *",
+		ReportSpec:   "{minute} 8 * * {weekday}",
+	}
+	p, err := NewProvisioner(cfg, c, lister, logrus.New())
+	if err != nil {
+		t.Fatalf("Error creating provisioner: %v", err)
+	}
+
+	// The teams existing on the first check are not provisioned.
+	if err := p.provisionNewTeams(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	lister.teams = []string{"t1", "t2", "t3"}
+	if err := p.provisionNewTeams(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]ReportEntry{
+		"t2": {TeamID: "t2", CronSpec: staggerSpec(cfg.ReportSpec, "t2")},
+		"t3": {TeamID: "t3", CronSpec: staggerSpec(cfg.ReportSpec, "t3")},
+	}
+	if diff := cmp.Diff(want, store.reportEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored report entries got!=want, diff %s", diff)
+	}
+	if len(store.scanEntries) != 1 {
+		t.Errorf("scan entries should not be modified, got %v", store.scanEntries)
+	}
+}
+
+func TestNewProvisioner_MalformedSpec(t *testing.T) {
+	_, err := NewProvisioner(ProvisionConfig{ReportSpec: "{minute} {hours} * * *"}, nil, nil, logrus.New())
+	if err != ErrMalformedSchedule {
+		t.Errorf("error got %v, want %v", err, ErrMalformedSchedule)
+	}
+}
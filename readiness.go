@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/manelmontilla/cron"
+)
+
+// LoadStatus defines the progress of the start of crontinuous.
+type LoadStatus struct {
+	// Ready is true when all the entries are loaded and their jobs
+	// scheduled.
+	Ready bool `json:"ready"`
+	// Loaded holds the number of jobs built for each of the types of
+	// entries already loaded.
+	Loaded map[CronType]int `json:"loaded"`
+	// Types is the number of types of entries to load.
+	Types int `json:"types"`
+	// Degraded holds the reason why the entries of each type, if any,
+	// were not loaded from the store but from the local cache.
+	Degraded map[CronType]string `json:"degraded,omitempty"`
+}
+
+// LoadStatus returns the progress of the start of crontinuous, so it can
+// start serving before all the jobs are scheduled.
+func (c *Crontinuous) LoadStatus() LoadStatus {
+	return c.progress.status()
+}
+
+// Ready returns true if crontinuous is started
+// and all its jobs are scheduled.
+func (c *Crontinuous) Ready() bool {
+	return c.progress.status().Ready
+}
+
+// loadProgress tracks the progress of the start.
+type loadProgress struct {
+	mux    sync.Mutex
+	ready  bool
+	loaded map[CronType]int
+	types  int
+
+	degraded map[CronType]string
+}
+
+func (p *loadProgress) reset(types int) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.ready = false
+	p.loaded = map[CronType]int{}
+	p.types = types
+}
+
+func (p *loadProgress) setLoaded(typ CronType, jobs int) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.loaded == nil {
+		p.loaded = map[CronType]int{}
+	}
+	p.loaded[typ] = jobs
+}
+
+// setDegraded records the reason why the entries of the given type are
+// degraded, an empty reason means they are not.
+func (p *loadProgress) setDegraded(typ CronType, reason string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if reason == "" {
+		delete(p.degraded, typ)
+		return
+	}
+	if p.degraded == nil {
+		p.degraded = map[CronType]string{}
+	}
+	p.degraded[typ] = reason
+}
+
+func (p *loadProgress) setReady(ready bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	p.ready = ready
+}
+
+func (p *loadProgress) status() LoadStatus {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	loaded := make(map[CronType]int, len(p.loaded))
+	for typ, n := range p.loaded {
+		loaded[typ] = n
+	}
+	status := LoadStatus{Ready: p.ready, Loaded: loaded, Types: p.types}
+	if len(p.degraded) > 0 {
+		status.Degraded = make(map[CronType]string, len(p.degraded))
+		for typ, reason := range p.degraded {
+			status.Degraded[typ] = reason
+		}
+	}
+	return status
+}
+
+// minParseChunk is the minimum number of specs parsed by each goroutine, so
+// small crontabs are not split.
+const minParseChunk = 1000
+
+// parseSpecs parses the given cron specs in parallel, as the crontabs of
+// large installations hold hundreds of thousands of entries.
+func parseSpecs(specs []string) ([]cron.Schedule, error) {
+	schedules := make([]cron.Schedule, len(specs))
+	workers := runtime.GOMAXPROCS(0)
+	chunk := (len(specs) + workers - 1) / workers
+	if chunk < minParseChunk {
+		chunk = minParseChunk
+	}
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		err     error
+	)
+	for start := 0; start < len(specs); start += chunk {
+		end := start + chunk
+		if end > len(specs) {
+			end = len(specs)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				sch, perr := cron.ParseStandard(specs[i])
+				if perr != nil {
+					errOnce.Do(func() { err = perr })
+					return
+				}
+				schedules[i] = sch
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return schedules, err
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrontinuous_LoadStatus(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 * * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if c.Ready() {
+		t.Fatal("ready before starting")
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	want := LoadStatus{
+		Ready:  true,
+		Loaded: map[CronType]int{ScanCronType: 2, ReportCronType: 1},
+		Types:  2,
+	}
+	if diff := cmp.Diff(want, c.LoadStatus()); diff != "" {
+		t.Errorf("load status diff: %s", diff)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Ready() {
+		t.Error("ready after stopping")
+	}
+}
+
+func TestParseSpecs(t *testing.T) {
+	specs := make([]string, 5*minParseChunk)
+	for i := range specs {
+		specs[i] = fmt.Sprintf("%d * * * *", i%60)
+	}
+	schedules, err := parseSpecs(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range schedules {
+		if s == nil {
+			t.Fatalf("spec %d not parsed", i)
+		}
+	}
+
+	specs[3*minParseChunk+7] = "malformed"
+	if _, err := parseSpecs(specs); err == nil {
+		t.Error("malformed spec accepted")
+	}
+}
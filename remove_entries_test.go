@@ -0,0 +1,69 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type countingCronStore struct {
+	mockCronStore
+	scanSaves int
+}
+
+func (s *countingCronStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	s.scanSaves++
+	return s.mockCronStore.SaveScanEntries(entries)
+}
+
+func TestCrontinuous_RemoveEntries(t *testing.T) {
+	store := &countingCronStore{
+		mockCronStore: mockCronStore{
+			scanEntries: map[string]ScanEntry{
+				"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+				"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 2 * * *"},
+				"p3": {ProgramID: "p3", TeamID: "t1", CronSpec: "0 3 * * *"},
+			},
+			reportEntries: map[string]ReportEntry{},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	saves := store.scanSaves
+
+	changes, missing, err := c.RemoveEntries(ScanCronType, []string{"p1", "p4", "p2", "p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].ID != "p1" || changes[1].ID != "p2" || changes[0].Action != ChangeDelete {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	if len(missing) != 1 || missing[0] != "p4" {
+		t.Errorf("want p4 missing, got %v", missing)
+	}
+	if n := store.scanSaves - saves; n != 1 {
+		t.Errorf("want the entries saved once, got %d saves", n)
+	}
+	if _, ok := store.scanEntries["p1"]; ok || len(store.scanEntries) != 1 {
+		t.Errorf("unexpected entries in the store %+v", store.scanEntries)
+	}
+	if jobs := c.cron.Jobs(); len(jobs) != 1 {
+		t.Errorf("want 1 job scheduled, got %d", len(jobs))
+	}
+
+	// Nothing is saved if no entry is found.
+	saves = store.scanSaves
+	if _, _, err := c.RemoveEntries(ScanCronType, []string{"p4"}); err != nil {
+		t.Fatal(err)
+	}
+	if store.scanSaves != saves {
+		t.Errorf("want no save removing missing entries, got %d", store.scanSaves-saves)
+	}
+}
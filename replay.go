@@ -0,0 +1,162 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// maxFailedExecutions is the maximum number of failed executions kept to be
+// replayed. The oldest ones are discarded first.
+const maxFailedExecutions = 1000
+
+var (
+	// ErrDeadLettersDisabled is returned when replaying an execution while
+	// no dead letter queue is configured.
+	ErrDeadLettersDisabled = errors.New("ErrorDeadLettersDisabled")
+	// ErrExecutionNotFound is returned when replaying an execution that did
+	// not fail or is not recorded anymore.
+	ErrExecutionNotFound = errors.New("ErrorExecutionNotFound")
+	// ErrExecutionReplayed is returned when replaying an execution that was
+	// already replayed or is being replayed.
+	ErrExecutionReplayed = errors.New("ErrorExecutionReplayed")
+	// ErrMalformedDeadLetter is returned when replaying a dead letter without
+	// ID or payload.
+	ErrMalformedDeadLetter = errors.New("ErrorMalformedDeadLetter")
+)
+
+// Replay defines the execution that replayed a failed one.
+type Replay struct {
+	Execution string    `json:"execution" yaml:"execution"`
+	At        time.Time `json:"at" yaml:"at"`
+}
+
+// failedExecutions records the dead letters published since the start, so
+// their executions can be replayed by ID.
+type failedExecutions struct {
+	mux       sync.Mutex
+	letters   map[string]*DeadLetter
+	order     []string
+	replaying map[string]bool
+}
+
+// deadLetterKey returns the ID the execution of the given dead letter is
+// replayed by: the ID of the execution or, if the scan creation was not
+// performed by a job, the ID of the dead letter.
+func deadLetterKey(l DeadLetter) string {
+	if l.Execution != "" {
+		return l.Execution
+	}
+	return l.ID
+}
+
+// record stores the given dead letter unless there is already one with the
+// same key, and returns the stored one.
+func (f *failedExecutions) record(l DeadLetter) DeadLetter {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	if f.letters == nil {
+		f.letters = map[string]*DeadLetter{}
+		f.replaying = map[string]bool{}
+	}
+	key := deadLetterKey(l)
+	if stored, ok := f.letters[key]; ok {
+		return *stored
+	}
+	if len(f.order) >= maxFailedExecutions {
+		delete(f.letters, f.order[0])
+		f.order = f.order[1:]
+	}
+	f.letters[key] = &l
+	f.order = append(f.order, key)
+	return l
+}
+
+// start marks as being replayed the execution with the given ID and returns
+// its dead letter.
+func (f *failedExecutions) start(id string) (DeadLetter, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	l, ok := f.letters[id]
+	if !ok {
+		return DeadLetter{}, ErrExecutionNotFound
+	}
+	if l.Replay != nil || f.replaying[id] {
+		return DeadLetter{}, ErrExecutionReplayed
+	}
+	f.replaying[id] = true
+	return *l, nil
+}
+
+// finish ends the replay of the execution with the given ID, marking it as
+// replayed by the given one if it is not nil.
+func (f *failedExecutions) finish(id string, r *Replay) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	delete(f.replaying, id)
+	// The execution may have been discarded while being replayed.
+	if l, ok := f.letters[id]; ok && r != nil {
+		l.Replay = r
+	}
+}
+
+// ReplayExecution creates again the scan of the failed execution with the
+// given ID, using the parameters recorded in its dead letter, even if its
+// entry was changed or removed meanwhile. The original execution is marked
+// as replayed and returned. The executions are recorded in memory when their
+// dead letter is published, so only the ones failed since the start, up to
+// the last 1000, can be replayed. It returns ErrDeadLettersDisabled if there
+// is no dead letter queue, ErrExecutionNotFound if the execution is not
+// recorded and ErrExecutionReplayed if it was already replayed. A failed
+// replay does not publish a new dead letter, so it can be retried.
+func (c *Crontinuous) ReplayExecution(ctx context.Context, id string) (DeadLetter, error) {
+	if err := c.checkWritable(); err != nil {
+		return DeadLetter{}, err
+	}
+	if c.deadLetters == nil {
+		return DeadLetter{}, ErrDeadLettersDisabled
+	}
+	l, err := c.failures.start(id)
+	if err != nil {
+		return DeadLetter{}, err
+	}
+
+	e := Execution{Type: l.Type, EntryID: l.EntryID, ID: NewRequestID()}
+	ctx = context.WithValue(ctx, executionKey{}, e)
+	log := c.log.WithFields(executionFields(ctx)).WithField("replayed_execution", id)
+
+	creator := c.scanCreator
+	if d, ok := creator.(*deadLetterScanCreator); ok {
+		creator = d.ScanCreator
+	}
+	if err := createScan(ctx, creator, l.Payload.ProgramID, l.Payload.TeamID); err != nil {
+		c.failures.finish(id, nil)
+		log.WithError(err).Error("Error replaying failed execution")
+		return DeadLetter{}, err
+	}
+	log.Info("Replayed failed execution")
+	l.Replay = &Replay{Execution: e.ID, At: time.Now()}
+	c.failures.finish(id, l.Replay)
+	return l, nil
+}
+
+// ReplayDeadLetter is like ReplayExecution but it replays the execution of
+// the given dead letter, e.g. read from the dead letter queue, recording it
+// first if it was not recorded, e.g. because it failed before the start.
+func (c *Crontinuous) ReplayDeadLetter(ctx context.Context, l DeadLetter) (DeadLetter, error) {
+	if c.deadLetters == nil {
+		return DeadLetter{}, ErrDeadLettersDisabled
+	}
+	if deadLetterKey(l) == "" || l.Payload.ProgramID == "" {
+		return DeadLetter{}, ErrMalformedDeadLetter
+	}
+	return c.ReplayExecution(ctx, deadLetterKey(c.failures.record(l)))
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_ReplayExecution(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	var (
+		mux     sync.Mutex
+		failing = true
+		created []string
+	)
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		mux.Lock()
+		defer mux.Unlock()
+		if failing {
+			return errors.New("vulcan-api unavailable")
+		}
+		created = append(created, programID+"/"+teamID)
+		return nil
+	}}
+	q := make(chanDeadLetterQueue, 1)
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store, WithDeadLetterQueue(q))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	ctx := context.Background()
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	var failed DeadLetter
+	select {
+	case failed = <-q:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead letter not published")
+	}
+
+	// A failed replay can be retried and does not publish a dead letter.
+	if _, err := c.ReplayExecution(ctx, failed.Execution); err == nil {
+		t.Fatal("want error replaying while the scan creation fails")
+	}
+	if len(q) != 0 {
+		t.Error("want no dead letter published by a failed replay")
+	}
+
+	mux.Lock()
+	failing = false
+	mux.Unlock()
+	// The replay uses the recorded parameters even if the entry changed.
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t2", CronSpec: "0 0 1 1 *"}); err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := c.ReplayExecution(ctx, failed.Execution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Replay == nil || replayed.Replay.Execution == "" || replayed.Replay.Execution == failed.Execution {
+		t.Errorf("want the execution marked as replayed, got %+v", replayed.Replay)
+	}
+	mux.Lock()
+	if len(created) != 1 || created[0] != "p1/t1" {
+		t.Errorf("want the scan of p1 created for t1, got %v", created)
+	}
+	mux.Unlock()
+
+	if _, err := c.ReplayExecution(ctx, failed.Execution); err != ErrExecutionReplayed {
+		t.Errorf("want ErrExecutionReplayed, got %v", err)
+	}
+	if _, err := c.ReplayExecution(ctx, "unknown"); err != ErrExecutionNotFound {
+		t.Errorf("want ErrExecutionNotFound, got %v", err)
+	}
+
+	// Dead letters read from the queue can be replayed after a restart.
+	letter := DeadLetter{
+		ID:       "l2",
+		Type:     ScanCronType,
+		EntryID:  "p2",
+		Payload:  ScanCreation{ProgramID: "p2", TeamID: "t3"},
+		FailedAt: time.Now(),
+	}
+	if _, err := c.ReplayDeadLetter(ctx, letter); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReplayExecution(ctx, "l2"); err != ErrExecutionReplayed {
+		t.Errorf("want ErrExecutionReplayed, got %v", err)
+	}
+	if _, err := c.ReplayDeadLetter(ctx, DeadLetter{ID: "l3"}); err != ErrMalformedDeadLetter {
+		t.Errorf("want ErrMalformedDeadLetter, got %v", err)
+	}
+
+	disabled := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store)
+	if _, err := disabled.ReplayExecution(ctx, failed.Execution); err != ErrDeadLettersDisabled {
+		t.Errorf("want ErrDeadLettersDisabled, got %v", err)
+	}
+}
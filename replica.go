@@ -0,0 +1,101 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// ErrReadOnlyReplica indicates the operation is rejected because the
+// instance is a read-only replica.
+var ErrReadOnlyReplica = errors.New("ErrorReadOnlyReplica")
+
+// WithReadOnlyReplica makes the instance a read-only replica of the one
+// scheduling the entries: it loads the entries from the stores but does not
+// schedule nor execute them, and rejects any change with ErrReadOnlyReplica.
+// The entries are reloaded from the stores by a ReplicaRefresher. The status
+// of the entries is reported as the scheduling instance would.
+func WithReadOnlyReplica() Option {
+	return func(c *Crontinuous) {
+		c.readOnly = true
+	}
+}
+
+// ReadOnly returns true if the instance is a read-only replica.
+func (c *Crontinuous) ReadOnly() bool {
+	return c.readOnly
+}
+
+// checkWritable returns ErrReadOnlyReplica if the instance is a read-only
+// replica.
+func (c *Crontinuous) checkWritable() error {
+	if c.readOnly {
+		return ErrReadOnlyReplica
+	}
+	return nil
+}
+
+// refresh reloads the entries, the templates and the pause windows from the
+// stores, keeping the current ones if they can not be loaded. It does
+// nothing if the instance is not started.
+func (c *Crontinuous) refresh() error {
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	if !c.started {
+		return nil
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	// The templates and the pause windows are read from the store the next
+	// time they are used.
+	c.templatesMux.Lock()
+	c.templates = nil
+	c.templatesMux.Unlock()
+	c.pauseWindowsMux.Lock()
+	c.pauseWindows = nil
+	c.pauseWindowsMux.Unlock()
+	return nil
+}
+
+// ReplicaRefresher periodically reloads the entries of a read-only replica
+// from the stores, so it serves the changes made through the scheduling
+// instance.
+type ReplicaRefresher struct {
+	c        *Crontinuous
+	interval time.Duration
+	log      *logrus.Logger
+}
+
+// NewReplicaRefresher returns a refresher of the entries of the given
+// crontinuous instance that runs every given interval.
+func NewReplicaRefresher(c *Crontinuous, interval time.Duration, logger *logrus.Logger) *ReplicaRefresher {
+	return &ReplicaRefresher{c: c, interval: interval, log: logger}
+}
+
+// Run reloads the entries on every interval until the context is done. The
+// entries are not reloaded right away, as they are loaded by Start.
+func (r *ReplicaRefresher) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := r.c.refresh(); err != nil {
+			r.log.WithError(err).Error("Error refreshing replica entries")
+		}
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrontinuous_ReadOnlyReplica(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithReadOnlyReplica())
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if jobs := c.cron.Jobs(); len(jobs) != 0 {
+		t.Errorf("want no jobs scheduled by the replica, got %d", len(jobs))
+	}
+	e, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.ScheduleStatus(ScanCronType, e); !s.Scheduled {
+		t.Errorf("want the entry reported as scheduled, got %+v", s)
+	}
+
+	err = c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 2 * * *"})
+	if err != ErrReadOnlyReplica {
+		t.Errorf("want error %v saving an entry, got %v", ErrReadOnlyReplica, err)
+	}
+	if err := c.RemoveEntry(ScanCronType, "p1"); err != ErrReadOnlyReplica {
+		t.Errorf("want error %v removing an entry, got %v", ErrReadOnlyReplica, err)
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != ErrReadOnlyReplica {
+		t.Errorf("want error %v running an entry, got %v", ErrReadOnlyReplica, err)
+	}
+
+	// The changes made by the scheduling instance are loaded on refresh.
+	store.scanEntries = map[string]ScanEntry{
+		"p3": {ProgramID: "p3", TeamID: "t1", CronSpec: "0 3 * * *"},
+	}
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].GetID() != "p3" {
+		t.Errorf("want the refreshed entries, got %+v", entries)
+	}
+}
+
+func TestCrontinuous_ReadOnlyReplicaJournal(t *testing.T) {
+	stored := map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+	}
+	store := &mockCronStore{scanEntries: stored, reportEntries: map[string]ReportEntry{}}
+
+	// The journal left by the instance before being demoted.
+	j := NewFileJournal(filepath.Join(t.TempDir(), "journal"))
+	record, err := newSaveRecord(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 2 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(record, newRemoveRecord(ScanCronType, "p1")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithReadOnlyReplica(), WithJournal(j))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CronEntry{stored["p1"]}
+	if diff := cmp.Diff(want, c.scans.all()); diff != "" {
+		t.Errorf("want the entries in the store, got diff: %s", diff)
+	}
+	if diff := cmp.Diff(stored, store.scanEntries); diff != "" {
+		t.Errorf("want the store not written, got diff: %s", diff)
+	}
+	records, err := j.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("want the journal kept, got %d records", len(records))
+	}
+}
@@ -5,8 +5,9 @@ Copyright 2020 Adevinta
 package crontinuous
 
 import (
+	"context"
+
 	"github.com/Sirupsen/logrus"
-	"github.com/manelmontilla/cron"
 )
 
 const (
@@ -19,148 +20,115 @@ type ReportSender interface {
 	SendReport(teamID string) error
 }
 
+// ContextReportSender is implemented by the report senders
+// that support aborting the sending of a report.
+type ContextReportSender interface {
+	SendReportContext(ctx context.Context, teamID string) error
+}
+
+// ResultReportSender is implemented by the report senders that return the
+// ID of the job sending the report, recorded in the execution of the entry.
+// It is preferred to ContextReportSender, and the ID can be empty if it is
+// not known.
+type ResultReportSender interface {
+	SendReportResult(ctx context.Context, teamID string) (string, error)
+}
+
+func sendReport(ctx context.Context, rs ReportSender, teamID string) error {
+	if rrs, ok := rs.(ResultReportSender); ok {
+		id, err := rrs.SendReportResult(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		recordReportID(ctx, id)
+		return nil
+	}
+	if crs, ok := rs.(ContextReportSender); ok {
+		return crs.SendReportContext(ctx, teamID)
+	}
+	return rs.SendReport(teamID)
+}
+
 // ReportEntry defines the data stored by a report cron entry.
 type ReportEntry struct {
-	TeamID   string `json:"team_id"`
-	CronSpec string `json:"cron_spec"`
+	// ID identifies the entry when there can be many entries per team, see
+	// WithReportIDStrategy. The entries without ID are identified by their
+	// team ID.
+	ID       string `json:"id,omitempty" yaml:"id,omitempty"`
+	TeamID   string `json:"team_id" yaml:"team_id"`
+	CronSpec string `json:"cron_spec" yaml:"cron_spec"`
+
+	EntrySettings `yaml:",inline"`
 }
 
 func (e ReportEntry) GetID() string {
+	if e.ID != "" {
+		return e.ID
+	}
 	return e.TeamID
 }
+func (e ReportEntry) GetTeamID() string {
+	return e.TeamID
+}
+func (e ReportEntry) GetType() CronType {
+	return ReportCronType
+}
 func (e ReportEntry) GetCronSpec() string {
 	return e.CronSpec
 }
+func (e ReportEntry) Validate() error {
+	if e.TeamID == "" {
+		return ErrMalformedEntry
+	}
+	if err := e.EntrySettings.validate(); err != nil {
+		return err
+	}
+	return validateCronSpec(e.CronSpec)
+}
 
 type reportJob struct {
+	id           string
 	teamID       string
 	reportSender ReportSender
 	log          *logrus.Entry
 }
 
-func (j *reportJob) Run() {
-	j.log.Info("Executing Report Job")
-	err := j.reportSender.SendReport(j.teamID)
-	if err != nil {
-		j.log.Error("Error Executing Report Job", err)
-		return
-	}
-	j.log.Info("Executed Report Job")
+func (j *reportJob) team() string {
+	return j.teamID
 }
 
-func (c *Crontinuous) reportBulkCreate(scheduledEntries map[string]cronEntryWithSchedule) ([]cronJobSchedule, error) {
-	c.reportMux.Lock()
-	defer c.reportMux.Unlock()
-
-	// Make deep copy of current jobs in order
-	// to make the operation atomic.
-	current := make(map[string]ReportEntry)
-	for _, e := range c.reportEntries {
-		current[e.TeamID] = e
-	}
-
-	// Update the hash of entries and create required jobs to be scheduled.
-	scheduledJobs := []cronJobSchedule{}
-	for _, e := range scheduledEntries {
-		var re ReportEntry
-		var ok bool
-
-		if re, ok = e.entry.(ReportEntry); !ok {
-			return nil, ErrMalformedEntry
-		}
-
-		if _, ok := current[re.TeamID]; ok && !e.overwriteEntry {
-			continue
-		}
-
-		current[re.TeamID] = re
-
-		if !c.isTeamWhitelisted(ReportCronType, re.TeamID) {
-			// If team is not whitelisted, do not
-			// return job to schedule.
-			continue
-		}
-
-		jobLog := logrus.New().WithFields(logrus.Fields{"job": re.TeamID})
-		scheduledJobs = append(scheduledJobs, cronJobSchedule{
-			schedule: e.schedule,
-			job: &reportJob{
-				reportSender: c.reportSender,
-				teamID:       re.TeamID,
-				log:          jobLog,
-			},
-			id: re.TeamID,
-		})
-	}
-
-	// Now it's safe to update all the entries and reschedule the jobs.
-	c.reportEntries = current
-	err := c.reportCronStore.SaveReportEntries(c.reportEntries)
-	return scheduledJobs, err
+func (j *reportJob) cronType() CronType {
+	return ReportCronType
 }
 
-func (c *Crontinuous) saveReportEntry(entry CronEntry) (cron.Job, error) {
-	reportEntry, ok := entry.(ReportEntry)
-	if !ok {
-		return nil, ErrMalformedEntry
-	}
-
-	c.reportMux.Lock()
-	defer c.reportMux.Unlock()
-
-	c.reportEntries[reportEntry.TeamID] = reportEntry
-
-	err := c.reportCronStore.SaveReportEntries(c.reportEntries)
-	if err != nil {
-		return nil, err
-	}
-
-	if !c.isTeamWhitelisted(ReportCronType, reportEntry.TeamID) {
-		return nil, errTeamNotWhitelisted
-	}
-
-	jobLog := logrus.New().WithFields(logrus.Fields{"job": reportEntry.TeamID})
-
-	return &reportJob{
-		teamID:       reportEntry.TeamID,
-		reportSender: c.reportSender,
-		log:          jobLog,
-	}, nil
+func (j *reportJob) entryID() string {
+	return j.id
 }
 
-func (c *Crontinuous) getReportEntries() ([]CronEntry, error) {
-	c.reportMux.RLock()
-	defer c.reportMux.RUnlock()
-
-	var entries = []CronEntry{}
-	for _, e := range c.reportEntries {
-		entries = append(entries, e)
-	}
-
-	return entries, nil
-}
-
-func (c *Crontinuous) getReportEntryByID(ID string) (ReportEntry, error) {
-	c.reportMux.RLock()
-	defer c.reportMux.RUnlock()
-
-	e, ok := c.reportEntries[ID]
-	if !ok {
-		return ReportEntry{}, ErrScheduleNotFound
+func (j *reportJob) run(ctx context.Context) error {
+	log := j.log.WithFields(executionFields(ctx))
+	log.Info("Executing Report Job")
+	err := sendReport(ctx, j.reportSender, j.teamID)
+	if err != nil {
+		log.Error("Error Executing Report Job", err)
+		return err
 	}
-
-	return e, nil
+	log.Info("Executed Report Job")
+	return nil
 }
 
-func (c *Crontinuous) removeReportEntry(ID string) error {
-	c.reportMux.Lock()
-	defer c.reportMux.Unlock()
-
-	_, ok := c.reportEntries[ID]
-	if !ok {
-		return ErrScheduleNotFound
-	}
-	delete(c.reportEntries, ID)
-
-	return c.reportCronStore.SaveReportEntries(c.reportEntries)
+func (c *Crontinuous) newReportJob(e ReportEntry) entryJob {
+	return c.newEntryJob(&reportJob{
+		id:           e.GetID(),
+		teamID:       e.TeamID,
+		reportSender: c.reportSender,
+		log:          logrus.New().WithFields(logrus.Fields{"job": e.TeamID}),
+	}, jobSettings{
+		timeout:      e.ExecutionTimeout,
+		pingURL:      e.PingURL,
+		alerting:     e.Alerting,
+		jitter:       e.Jitter,
+		windows:      e.Windows,
+		windowPolicy: e.WindowPolicy,
+	})
 }
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ReportIDsPerTeam is the name of the strategy allowing one report entry
+	// per team.
+	ReportIDsPerTeam = "team"
+	// ReportIDsPerTeamSpec is the name of the strategy allowing one report
+	// entry per team and spec.
+	ReportIDsPerTeamSpec = "team-spec"
+)
+
+// ReportIDStrategy defines which report entries are the same entry and which
+// ID the new ones get, as ScanIDStrategy does for the scan entries.
+type ReportIDStrategy interface {
+	// Key returns the value that must be unique among the report entries.
+	Key(e ReportEntry) string
+	// NewID returns the ID of a new report entry, empty to identify it by
+	// its team ID, as the entries stored before the IDs were introduced.
+	NewID(e ReportEntry) string
+}
+
+// TeamReportIDs allows one report entry per team, identified by the ID of
+// the team.
+type TeamReportIDs struct{}
+
+// Key returns the team ID of the entry.
+func (TeamReportIDs) Key(e ReportEntry) string {
+	return e.TeamID
+}
+
+// NewID returns an empty ID, so the entry is identified by its team ID.
+func (TeamReportIDs) NewID(e ReportEntry) string {
+	return ""
+}
+
+// TeamSpecReportIDs allows many report entries per team, e.g. a weekly
+// digest and a monthly full report, as long as their specs are different.
+// The new entries are identified by a random UUID.
+type TeamSpecReportIDs struct{}
+
+// Key returns the team ID and the normalized spec of the entry, so the same
+// schedule written differently, e.g. @daily and 0 0 * * *, is the same key.
+// The H tokens are resolved with the team ID, as the key of an entry is
+// needed before it gets its ID.
+func (TeamSpecReportIDs) Key(e ReportEntry) string {
+	return e.TeamID + " " + normalizeCronSpec(e.CronSpec, e.TeamID)
+}
+
+// NewID returns a random UUID.
+func (TeamSpecReportIDs) NewID(e ReportEntry) string {
+	return uuid.New().String()
+}
+
+// ReportIDStrategyByName returns the ID strategy with the given name,
+// ReportIDsPerTeam or ReportIDsPerTeamSpec. Empty means ReportIDsPerTeam.
+func ReportIDStrategyByName(name string) (ReportIDStrategy, error) {
+	switch name {
+	case "", ReportIDsPerTeam:
+		return TeamReportIDs{}, nil
+	case ReportIDsPerTeamSpec:
+		return TeamSpecReportIDs{}, nil
+	}
+	return nil, fmt.Errorf("unknown report ID strategy %q", name)
+}
+
+// WithReportIDStrategy makes crontinuous assign the IDs of the report
+// entries with the given strategy. As with the scan entries, the ones stored
+// without ID keep being identified by their team ID.
+func WithReportIDStrategy(s ReportIDStrategy) Option {
+	return func(c *Crontinuous) {
+		c.reportIDs = s
+	}
+}
+
+// TeamReportEntries returns the report entries of the given team, sorted by
+// ID.
+func (c *Crontinuous) TeamReportEntries(teamID string) []ReportEntry {
+	entries := []ReportEntry{}
+	for _, e := range c.reports.all() {
+		if e.GetTeamID() == teamID {
+			entries = append(entries, e.(ReportEntry))
+		}
+	}
+	return entries
+}
+
+// ResolveReportID returns the given ID if there is a report entry with it.
+// Otherwise, if the ID is the one of a team with a single report entry, it
+// returns the ID of the entry, so the team IDs keep identifying the report
+// entries of the teams that have only one.
+func (c *Crontinuous) ResolveReportID(id string) string {
+	if _, err := c.reports.get(id); err == nil {
+		return id
+	}
+	if entries := c.TeamReportEntries(id); len(entries) == 1 {
+		return entries[0].GetID()
+	}
+	return id
+}
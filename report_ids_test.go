@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_ReportIDStrategies(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithReportIDStrategy(TeamSpecReportIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The team ID identifies the only report entry of the team.
+	if id := c.ResolveReportID("t1"); id != "t1" {
+		t.Errorf("want the legacy entry identified by its team ID, got %q", id)
+	}
+
+	var monthly string
+	err := c.SaveEntry(ReportCronType, ReportEntry{TeamID: "t1", CronSpec: "0 8 1 * *"}, SavedID(&monthly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if monthly == "" || monthly == "t1" {
+		t.Fatalf("want a new ID for the second entry of the team, got %q", monthly)
+	}
+	if entries := c.TeamReportEntries("t1"); len(entries) != 2 {
+		t.Errorf("want 2 report entries of the team, got %+v", entries)
+	}
+
+	err = c.SaveEntry(ReportCronType, ReportEntry{ID: monthly, TeamID: "t1", CronSpec: "0 8 * * 1"})
+	if err != ErrDuplicatedEntry {
+		t.Errorf("want error %v, got %v", ErrDuplicatedEntry, err)
+	}
+
+	// A team whose only entry has a surrogate ID is still identified by its
+	// team ID.
+	err = c.SaveEntry(ReportCronType, ReportEntry{TeamID: "t2", CronSpec: "0 9 * * 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := c.ResolveReportID("t2")
+	e, err := c.GetEntryByID(ReportCronType, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "t2" || e.GetTeamID() != "t2" {
+		t.Errorf("want the entry of the team resolved, got ID %q and entry %+v", id, e)
+	}
+}
+
+func TestCrontinuous_DeriveReportEntriesSkipsManyReports(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+			"r1": {ID: "r1", TeamID: "t1", CronSpec: "0 8 1 * *"},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithReportIDStrategy(TeamSpecReportIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	changes, err := c.DeriveReportEntries(6*time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("want no changes, got %+v", changes)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrorReport defines an error to be reported to an error tracking service.
+type ErrorReport struct {
+	Err error
+	// Tags give the context of the error, e.g. the type, team and ID of the
+	// entry whose job failed.
+	Tags map[string]string
+	// Stack is the stack trace of the goroutine, only set for panics.
+	Stack string
+}
+
+// ErrorReporter defines the services needed to report the failures of the
+// jobs, the failures of the stores and the panics in the jobs to an error
+// tracking service, e.g. Sentry.
+type ErrorReporter interface {
+	Report(r ErrorReport)
+}
+
+// WithErrorReporter makes crontinuous report the failures
+// to the given reporter.
+func WithErrorReporter(r ErrorReporter) Option {
+	return func(c *Crontinuous) {
+		c.reporter = r
+	}
+}
+
+// reportError reports the given error if a reporter is configured.
+func (c *Crontinuous) reportError(r ErrorReport) {
+	if c.reporter == nil {
+		return
+	}
+	c.reporter.Report(r)
+}
+
+// jobPanicError is returned by a job that panicked.
+type jobPanicError struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *jobPanicError) Error() string {
+	return fmt.Sprintf("job panicked: %v", e.value)
+}
+
+// recoveredJob wraps the job of an entry so the panics are returned as
+// errors. The jobs can run in their own goroutine, e.g. when they have an
+// execution timeout, where a panic would crash the process.
+type recoveredJob struct {
+	entryJob
+}
+
+func (j *recoveredJob) run(ctx context.Context) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &jobPanicError{value: v, stack: debug.Stack()}
+		}
+	}()
+	return j.entryJob.run(ctx)
+}
+
+// reportedJob wraps the job of an entry so its failures are reported.
+type reportedJob struct {
+	entryJob
+	c *Crontinuous
+}
+
+func (j *reportedJob) run(ctx context.Context) error {
+	err := j.entryJob.run(ctx)
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	r := ErrorReport{
+		Err: err,
+		Tags: map[string]string{
+			"source": "job",
+			"type":   j.cronType().String(),
+			"team":   j.team(),
+			"entry":  j.entryID(),
+		},
+	}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		r.Tags["execution"] = e.ID
+	}
+	var perr *jobPanicError
+	if errors.As(err, &perr) {
+		r.Tags["source"] = "panic"
+		r.Stack = string(perr.stack)
+	}
+	j.c.reportError(r)
+	return err
+}
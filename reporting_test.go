@@ -0,0 +1,173 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type mockErrorReporter struct {
+	mux     sync.Mutex
+	reports []ErrorReport
+}
+
+func (m *mockErrorReporter) Report(r ErrorReport) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.reports = append(m.reports, r)
+}
+
+func TestReportedJob(t *testing.T) {
+	tests := []struct {
+		name       string
+		creator    func(string, string) error
+		timeout    Duration
+		wantSource string
+		wantStack  bool
+	}{
+		{
+			name:       "ReportsFailures",
+			creator:    func(string, string) error { return errors.New("vulcan-api unavailable") },
+			wantSource: "job",
+		},
+		{
+			name:       "ReportsPanics",
+			creator:    func(string, string) error { panic("boom") },
+			wantSource: "panic",
+			wantStack:  true,
+		},
+		{
+			name:       "RecoversPanicsWithTimeout",
+			creator:    func(string, string) error { panic("boom") },
+			timeout:    Duration(time.Hour),
+			wantSource: "panic",
+			wantStack:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := &mockErrorReporter{}
+			c := NewCrontinuous(Config{}, logrus.New(), &mockScanCreator{creator: tt.creator}, nil, nil, nil,
+				WithErrorReporter(reporter))
+
+			c.wrapJob(c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{ExecutionTimeout: tt.timeout}})).Run()
+
+			if len(reporter.reports) != 1 {
+				t.Fatalf("reports got %d, want 1", len(reporter.reports))
+			}
+			r := reporter.reports[0]
+			wantTags := map[string]string{"source": tt.wantSource, "type": "scan", "team": "t1", "entry": "p1"}
+			for k, v := range wantTags {
+				if r.Tags[k] != v {
+					t.Errorf("tag %s got %q, want %q", k, r.Tags[k], v)
+				}
+			}
+			if (r.Stack != "") != tt.wantStack {
+				t.Errorf("stack got %q, want stack %v", r.Stack, tt.wantStack)
+			}
+		})
+	}
+}
+
+func TestSentryReporter(t *testing.T) {
+	events := make(chan *http.Request, 1)
+	var ev sentryEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&ev) // nolint
+		events <- r
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "://", "://key@", 1) + "/42"
+	s, err := NewSentryReporter(SentryConfig{DSN: dsn, Environment: "test", Tags: []string{"region:eu"}}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Report(ErrorReport{Err: errors.New("store unavailable"), Tags: map[string]string{"source": "store"}})
+
+	var r *http.Request
+	select {
+	case r = <-events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not sent")
+	}
+	if r.URL.Path != "/api/42/store/" {
+		t.Errorf("path got %s, want /api/42/store/", r.URL.Path)
+	}
+	if auth := r.Header.Get("X-Sentry-Auth"); !strings.Contains(auth, "sentry_key=key") {
+		t.Errorf("auth header got %q, want it to contain the key", auth)
+	}
+	if ev.Message != "store unavailable" || ev.Environment != "test" ||
+		ev.Tags["region"] != "eu" || ev.Tags["source"] != "store" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+
+	if _, err := NewSentryReporter(SentryConfig{DSN: srv.URL}, logrus.New()); err == nil {
+		t.Error("DSN without key and project accepted")
+	}
+}
+
+func TestSentryReporter_Queue(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		received int
+	)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		mux.Lock()
+		received++
+		mux.Unlock()
+	}))
+	defer srv.Close()
+
+	dsn := strings.Replace(srv.URL, "://", "://key@", 1) + "/42"
+	s, err := NewSentryReporter(SentryConfig{DSN: dsn}, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first event blocks the worker sending it,
+	// so the rest fill the queue.
+	s.Report(ErrorReport{Err: errors.New("first")})
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not sent")
+	}
+	for i := 0; i < sentryQueueSize+2; i++ {
+		s.Report(ErrorReport{Err: errors.New("queued")})
+	}
+	if got := s.Dropped(); got != 2 {
+		t.Errorf("dropped got %d, want 2", got)
+	}
+
+	close(release)
+	s.Stop()
+	mux.Lock()
+	if received != sentryQueueSize+1 {
+		t.Errorf("received got %d, want %d", received, sentryQueueSize+1)
+	}
+	mux.Unlock()
+
+	s.Report(ErrorReport{Err: errors.New("after stop")})
+	if got := s.Dropped(); got != 3 {
+		t.Errorf("dropped after stop got %d, want 3", got)
+	}
+}
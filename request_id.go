@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// RequestIDHeader is the header identifying a request, so the logs of the
+// services involved in serving it can be correlated.
+const RequestIDHeader = "X-Request-ID"
+
+// ServiceName is the name crontinuous identifies itself with
+// in the requests it performs.
+const ServiceName = "vulcan-crontinuous"
+
+// Version is the version of crontinuous, set at build time with:
+//
+//	-ldflags "-X github.com/adevinta/vulcan-crontinuous.Version=<version>"
+var Version = "dev"
+
+// NewRequestID returns a new random identifier for a request or an
+// execution.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// The reader of the OS does not fail in practice,
+		// but an ID is better than none.
+		return fmt.Sprintf("%x", b)
+	}
+	return hex.EncodeToString(b)
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of the given context carrying the
+// given request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by the given context,
+// empty if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Execution identifies an execution of the job of an entry.
+type Execution struct {
+	Type    CronType
+	EntryID string
+	// ID is unique for every execution.
+	ID string
+	// ScheduledAt is the time the execution was activated, before the
+	// jitter of the entry, if any. It is zero in the replays.
+	ScheduledAt time.Time
+}
+
+type executionKey struct{}
+
+// ExecutionFromContext returns the execution of the job carried by the
+// given context, if any.
+func ExecutionFromContext(ctx context.Context) (Execution, bool) {
+	e, ok := ctx.Value(executionKey{}).(Execution)
+	return e, ok
+}
+
+// executionJob wraps the job of an entry so every execution carries in its
+// context a new Execution.
+type executionJob struct {
+	entryJob
+}
+
+// executionIDKey carries in the context of a job the ID its execution must
+// have, when it must be known before the job runs.
+type executionIDKey struct{}
+
+func (j *executionJob) run(ctx context.Context) error {
+	id, ok := ctx.Value(executionIDKey{}).(string)
+	if !ok {
+		id = NewRequestID()
+	}
+	e := Execution{Type: j.cronType(), EntryID: j.entryID(), ID: id, ScheduledAt: time.Now()}
+	return j.entryJob.run(context.WithValue(ctx, executionKey{}, e))
+}
+
+// executionFields returns the fields identifying in the logs the execution
+// and the request carried by the given context.
+func executionFields(ctx context.Context) logrus.Fields {
+	fields := logrus.Fields{}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		fields["execution"] = e.ID
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		fields["request_id"] = id
+	}
+	return fields
+}
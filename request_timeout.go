@@ -0,0 +1,11 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import "errors"
+
+// ErrRequestTimeout indicates the request to the API did not start to be
+// responded in the timeout of its endpoint.
+var ErrRequestTimeout = errors.New("ErrorRequestTimeout")
@@ -3,9 +3,81 @@
 # Copyright 2020 Adevinta
 
 export PORT=${PORT:-8080}
+export HTTP_READ_HEADER_TIMEOUT=${HTTP_READ_HEADER_TIMEOUT:-10s}
+export HTTP_READ_TIMEOUT=${HTTP_READ_TIMEOUT:-30s}
+export HTTP_WRITE_TIMEOUT=${HTTP_WRITE_TIMEOUT:-2m}
+export HTTP_IDLE_TIMEOUT=${HTTP_IDLE_TIMEOUT:-2m}
+export HTTP_MAX_HEADER_BYTES=${HTTP_MAX_HEADER_BYTES:-1048576}
+export TLS_AUTOCERT_DOMAINS=${TLS_AUTOCERT_DOMAINS:-[]}
 export PATH_STYLE=${PATH_STYLE:-false}
+export S3_SHARDS=${S3_SHARDS:-0}
+export S3_COMPRESSION=${S3_COMPRESSION:-false}
+export S3_SORTED_ENTRIES=${S3_SORTED_ENTRIES:-false}
+export VULCAN_TEAM_CREDENTIALS_TTL=${VULCAN_TEAM_CREDENTIALS_TTL:-5m}
+export VULCAN_TEAM_CREDENTIALS_REQUIRED=${VULCAN_TEAM_CREDENTIALS_REQUIRED:-false}
+export VULCAN_REPORT_SCHEDULE=${VULCAN_REPORT_SCHEDULE:-false}
+export VULCAN_API_HEALTH_PROBE=${VULCAN_API_HEALTH_PROBE:-false}
+export VULCAN_API_HEALTH_PROBE_DELAY=${VULCAN_API_HEALTH_PROBE_DELAY:-1m}
+export VULCAN_API_HEALTH_PROBE_RETRIES=${VULCAN_API_HEALTH_PROBE_RETRIES:-5}
+export VALIDATE_ENTRIES=${VALIDATE_ENTRIES:-false}
+export STORE_CACHE_TTL=${STORE_CACHE_TTL:-24h}
+export STORE_FAULT_GET_RATE=${STORE_FAULT_GET_RATE:-0}
+export STORE_FAULT_SAVE_RATE=${STORE_FAULT_SAVE_RATE:-0}
+export STORE_FAULT_LATENCY=${STORE_FAULT_LATENCY:-0s}
+export REPORT_SCAN_MIN_GAP=${REPORT_SCAN_MIN_GAP:-0s}
+export SCAN_CAPACITY=${SCAN_CAPACITY:-0}
+export ENFORCE_SCAN_CAPACITY=${ENFORCE_SCAN_CAPACITY:-false}
+export SPEC_RULES_FILE=${SPEC_RULES_FILE:-}
+export SPEC_ALIASES=${SPEC_ALIASES:-[]}
+export METADATA_SCHEMA_FILE=${METADATA_SCHEMA_FILE:-}
+export SCAN_ID_STRATEGY=${SCAN_ID_STRATEGY:-program}
+export REPORT_ID_STRATEGY=${REPORT_ID_STRATEGY:-team}
+export ENABLE_DEBUG=${ENABLE_DEBUG:-false}
+export IDEMPOTENCY_WINDOW=${IDEMPOTENCY_WINDOW:-10m}
+export REQUEST_READ_TIMEOUT=${REQUEST_READ_TIMEOUT:-10s}
+export REQUEST_WRITE_TIMEOUT=${REQUEST_WRITE_TIMEOUT:-30s}
+export REQUEST_TIMEOUTS=${REQUEST_TIMEOUTS:-[]}
+export STOP_TIMEOUT=${STOP_TIMEOUT:-30s}
+export EXECUTION_TIMEOUT=${EXECUTION_TIMEOUT:-0s}
+export WARM_UP=${WARM_UP:-0s}
+export WARM_UP_GRADUAL=${WARM_UP_GRADUAL:-false}
+export GIT_SYNC_INTERVAL=${GIT_SYNC_INTERVAL:-5m}
+export PROVISION_INTERVAL=${PROVISION_INTERVAL:-0s}
+export CRON_ENGINE=${CRON_ENGINE:-fork}
+export CRON_ENGINE_WATCHDOG_INTERVAL=${CRON_ENGINE_WATCHDOG_INTERVAL:-0s}
+export SAVE_INTERVAL=${SAVE_INTERVAL:-0s}
+export SHARD_INDEX=${SHARD_INDEX:-0}
+export SHARD_COUNT=${SHARD_COUNT:-1}
+export SHARD_SYNC_INTERVAL=${SHARD_SYNC_INTERVAL:-1m}
+export EXECUTION_LOCK_TABLE=${EXECUTION_LOCK_TABLE:-}
+export EXECUTION_LOCK_TTL=${EXECUTION_LOCK_TTL:-10m}
+export ENABLE_HISTORY=${ENABLE_HISTORY:-false}
+export HISTORY_LIMIT=${HISTORY_LIMIT:-20}
+export HISTORY_MAX_AGE=${HISTORY_MAX_AGE:-0s}
+export HISTORY_PRUNE_INTERVAL=${HISTORY_PRUNE_INTERVAL:-1h}
+export DIVERGENCE_CHECK_INTERVAL=${DIVERGENCE_CHECK_INTERVAL:-0s}
+export READ_ONLY_REPLICA=${READ_ONLY_REPLICA:-false}
+export REPLICA_REFRESH_INTERVAL=${REPLICA_REFRESH_INTERVAL:-1m}
+export STATSD_PREFIX=${STATSD_PREFIX:-crontinuous}
+export STATSD_TAGS=${STATSD_TAGS:-[]}
+export STATSD_INTERVAL=${STATSD_INTERVAL:-10s}
+export SENTRY_TAGS=${SENTRY_TAGS:-[]}
+export ALERT_CHANNEL_WEBHOOKS=${ALERT_CHANNEL_WEBHOOKS:-[]}
+export ALERT_FAILURE_THRESHOLD=${ALERT_FAILURE_THRESHOLD:-1}
+export MAX_CONCURRENT_SCAN_JOBS=${MAX_CONCURRENT_SCAN_JOBS:-0}
+export MAX_CONCURRENT_REPORT_JOBS=${MAX_CONCURRENT_REPORT_JOBS:-0}
+export MAX_CONCURRENT_TEAM_SCAN_JOBS=${MAX_CONCURRENT_TEAM_SCAN_JOBS:-0}
+export TEAM_CIRCUIT_THRESHOLD=${TEAM_CIRCUIT_THRESHOLD:-0}
+export TEAM_CIRCUIT_COOLDOWN=${TEAM_CIRCUIT_COOLDOWN:-1m}
+export TEAM_DAILY_BUDGET=${TEAM_DAILY_BUDGET:-0s}
+export TEAM_BUDGETS=${TEAM_BUDGETS:-[]}
+export DEV_MODE=${DEV_MODE:-false}
 
 # Apply env variables
 cat config.toml | envsubst > run.toml
 
-./vulcan-crontinuous -c run.toml
+if [ "$DEV_MODE" = "true" ]; then
+  ./vulcan-crontinuous -c run.toml --dev
+else
+  ./vulcan-crontinuous -c run.toml
+fi
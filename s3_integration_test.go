@@ -0,0 +1,132 @@
+//go:build integration
+
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous_test
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/storetest"
+)
+
+// The integration tests run against the S3 compatible service, e.g. the
+// Minio of the docker-compose.yml file, at the endpoint defined in the
+// CRONTINUOUS_TEST_S3_ENDPOINT env var, http://localhost:9000 by default.
+// The Minio default credentials are used unless AWS_ACCESS_KEY_ID is set.
+
+var bucketSeq int64
+
+// newS3Client returns a client of the S3 service of the integration tests.
+func newS3Client(t *testing.T) *s3.S3 {
+	endpoint := os.Getenv("CRONTINUOUS_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://localhost:9000"
+	}
+	cfg := aws.NewConfig().
+		WithRegion("local-region").
+		WithEndpoint(endpoint).
+		WithS3ForcePathStyle(true)
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials("minioadmin", "minioadmin", ""))
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3.New(sess)
+}
+
+// newBucket creates an empty bucket removed at the end of the test.
+func newBucket(t *testing.T, client *s3.S3) string {
+	bucket := fmt.Sprintf("crontinuous-test-%d-%d", time.Now().UnixNano(), atomic.AddInt64(&bucketSeq, 1))
+	if _, err := client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		t.Fatalf("creating bucket, is the S3 service running? %v", err)
+	}
+	t.Cleanup(func() {
+		err := client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(bucket)},
+			func(out *s3.ListObjectsV2Output, last bool) bool {
+				for _, o := range out.Contents {
+					client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: o.Key}) // nolint
+				}
+				return true
+			})
+		if err == nil {
+			_, err = client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(bucket)})
+		}
+		if err != nil {
+			t.Logf("removing bucket %s: %v", bucket, err)
+		}
+	})
+	return bucket
+}
+
+func newS3Store(t *testing.T, client *s3.S3, bucket string, opts ...crontinuous.S3StoreOption) *crontinuous.S3CronStore {
+	return crontinuous.NewS3CronStore(bucket,
+		crontinuous.S3ScansCrontabFilename, crontinuous.S3ReportsCrontabFilename, client, opts...)
+}
+
+func TestIntegration_S3CronStore(t *testing.T) {
+	client := newS3Client(t)
+	tests := []struct {
+		name string
+		opts []crontinuous.S3StoreOption
+	}{
+		{name: "Plain"},
+		{name: "Sharded", opts: []crontinuous.S3StoreOption{crontinuous.WithS3Shards(8)}},
+		{name: "Compressed", opts: []crontinuous.S3StoreOption{crontinuous.WithS3Compression()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storetest.TestStore(t, func(t *testing.T) interface{} {
+				return newS3Store(t, client, newBucket(t, client), tt.opts...)
+			})
+		})
+	}
+}
+
+func TestIntegration_Restart(t *testing.T) {
+	client := newS3Client(t)
+	bucket := newBucket(t, client)
+
+	// The entries saved by an instance are loaded by the next one.
+	store := newS3Store(t, client, bucket)
+	c := crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	entry := crontinuous.ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * *"}
+	if err := c.SaveEntry(crontinuous.ScanCronType, entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = newS3Store(t, client, bucket)
+	c = crontinuous.NewCrontinuous(crontinuous.Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+	got, err := c.GetEntryByID(crontinuous.ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != entry {
+		t.Errorf("entry got %+v, want %+v", got, entry)
+	}
+}
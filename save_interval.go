@@ -0,0 +1,62 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"time"
+)
+
+// WithSaveInterval makes crontinuous save the changed entries of every type
+// to the store at most once per given interval, instead of on every change,
+// so bursts of changes, like the onboarding of many programs, are coalesced
+// into a single save. The changes are applied and scheduled right away, and
+// saved when the interval elapses, when Flush is called, before the entries
+// are read again from the stores and when crontinuous is stopped. Unless a
+// journal is configured, the changes pending to be saved are lost if the
+// process dies.
+func WithSaveInterval(d time.Duration) Option {
+	return func(c *Crontinuous) {
+		c.saveInterval = d
+	}
+}
+
+// Flush saves now the entries of the types with changes pending to be saved,
+// including the ones whose last save failed, and returns those types. It
+// stops at the first error.
+func (c *Crontinuous) Flush() ([]CronType, error) {
+	dirty := map[CronType]bool{}
+	for _, typ := range c.dirtyTypes() {
+		dirty[typ] = true
+	}
+	flushed := []CronType{}
+	for _, typ := range c.cronTypes() {
+		if !dirty[typ] {
+			continue
+		}
+		if err := c.flush(typ); err != nil {
+			return flushed, err
+		}
+		flushed = append(flushed, typ)
+	}
+	return flushed, nil
+}
+
+// saveEntries saves the entries pending to be saved every save interval
+// until the given context is done.
+func (c *Crontinuous) saveEntries(ctx context.Context) {
+	ticker := time.NewTicker(c.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := c.Flush(); err != nil {
+				c.log.WithError(err).Error("Error saving the entries")
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+// countingStore counts the saves of the scan entries.
+type countingStore struct {
+	*mockCronStore
+	mux   sync.Mutex
+	saves int
+}
+
+func (s *countingStore) SaveScanEntries(entries map[string]ScanEntry) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.saves++
+	return s.mockCronStore.SaveScanEntries(entries)
+}
+
+func (s *countingStore) stored() (int, int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.saves, len(s.scanEntries)
+}
+
+func newCountingStore() *countingStore {
+	return &countingStore{mockCronStore: &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}}
+}
+
+func TestCrontinuous_SaveInterval(t *testing.T) {
+	store := newCountingStore()
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithSaveInterval(time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}}, []bool{true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"p2", "p3"} {
+		if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 3 * * *"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if saves, _ := store.stored(); saves != 0 {
+		t.Errorf("want no saves before the interval, got %d", saves)
+	}
+	if entries, _ := c.GetEntries(ScanCronType); len(entries) != 3 {
+		t.Errorf("want the changes applied, got %v", entries)
+	}
+
+	flushed, err := c.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]CronType{ScanCronType}, flushed); diff != "" {
+		t.Errorf("flushed types mismatch (-want +got):\n%s", diff)
+	}
+	if saves, n := store.stored(); saves != 1 || n != 3 {
+		t.Errorf("want 1 save of 3 entries, got %d saves of %d", saves, n)
+	}
+	if flushed, err = c.Flush(); err != nil || len(flushed) != 0 {
+		t.Errorf("want nothing flushed, got %v, %v", flushed, err)
+	}
+
+	if err := c.RemoveEntry(ScanCronType, "p3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if saves, n := store.stored(); saves != 2 || n != 2 {
+		t.Errorf("want the pending changes saved before restarting, got %d saves of %d entries", saves, n)
+	}
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "0 4 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if saves, n := store.stored(); saves != 3 || n != 3 {
+		t.Errorf("want the pending changes saved on stop, got %d saves of %d entries", saves, n)
+	}
+}
+
+func TestCrontinuous_SaveIntervalElapsed(t *testing.T) {
+	store := newCountingStore()
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithSaveInterval(10*time.Millisecond))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	for _, id := range []string{"p1", "p2", "p3"} {
+		if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 3 * * *"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		saves, n := store.stored()
+		if n == 3 {
+			if saves > 3 {
+				t.Errorf("want the saves coalesced, got %d", saves)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the entries were not saved after the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// scanCapacityHorizon is the period of time, starting from now, in which the
+// scans created per minute are compared with the capacity of vulcan-api.
+const scanCapacityHorizon = 24 * time.Hour
+
+// scanDensityMaxAge is the maximum time the density of the scans computed
+// for the current entries is reused, as the executions in the next 24h change
+// over time even if the entries do not.
+const scanDensityMaxAge = time.Hour
+
+// ErrScanCapacityExceeded indicates the executions of the given entry would
+// create, in some minute, more scans than Config.ScanCapacity.
+var ErrScanCapacityExceeded = errors.New("ErrorScanCapacityExceeded")
+
+// IgnoreScanCapacity makes SaveEntry, and the bulk creations and
+// replacements, store the entries even if their executions push the scans
+// created in a minute past Config.ScanCapacity.
+func IgnoreScanCapacity() SaveOption {
+	return func(o *saveOptions) {
+		o.ignoreCapacity = true
+	}
+}
+
+// ScanDensity defines how close the scans created by the schedule are to
+// the capacity of vulcan-api in the next 24h. Every execution of a scan
+// entry counts as one scan, and every execution of a team scan entry as one
+// scan per program of its team.
+type ScanDensity struct {
+	// Capacity is the number of scans per minute vulcan-api can handle,
+	// zero if it is not configured.
+	Capacity int `json:"capacity" yaml:"capacity"`
+	// Peak is the maximum number of scans created in the same minute, at
+	// the time PeakAt.
+	Peak   int        `json:"peak" yaml:"peak"`
+	PeakAt *time.Time `json:"peak_at,omitempty" yaml:"peak_at,omitempty"`
+	// ExceededMinutes is the number of minutes in which more scans than
+	// the capacity are created.
+	ExceededMinutes int `json:"exceeded_minutes" yaml:"exceeded_minutes"`
+}
+
+// ScanDensity returns the density of the scans created by the schedule in
+// the next 24h.
+func (c *Crontinuous) ScanDensity() (ScanDensity, error) {
+	perMinute, _, err := c.scansPerMinute(time.Now(), c.GetEntries)
+	if err != nil {
+		return ScanDensity{}, err
+	}
+	d := ScanDensity{Capacity: c.settings().ScanCapacity}
+	for minute, n := range perMinute {
+		if n > d.Peak || (n == d.Peak && minute.Before(*d.PeakAt)) {
+			at := minute
+			d.Peak, d.PeakAt = n, &at
+		}
+		if d.Capacity > 0 && n > d.Capacity {
+			d.ExceededMinutes++
+		}
+	}
+	return d, nil
+}
+
+// scanDensities holds the density of the scans last computed and the version
+// of the entries it was computed for.
+type scanDensities struct {
+	mux      sync.Mutex
+	density  *ScanDensity
+	version  uint64
+	computed time.Time
+}
+
+// cachedScanDensity returns the density of the scans of the schedule, as
+// ScanDensity does, computing it again only if the entries changed since it
+// was last computed or it is older than scanDensityMaxAge, so reading the
+// metrics does not simulate the schedule every time.
+func (c *Crontinuous) cachedScanDensity() (ScanDensity, error) {
+	c.densities.mux.Lock()
+	defer c.densities.mux.Unlock()
+
+	version := c.version.get()
+	if d := c.densities.density; d != nil && c.densities.version == version &&
+		time.Since(c.densities.computed) < scanDensityMaxAge {
+		return *d, nil
+	}
+	d, err := c.ScanDensity()
+	if err != nil {
+		return ScanDensity{}, err
+	}
+	c.densities.density, c.densities.version, c.densities.computed = &d, version, time.Now()
+	return d, nil
+}
+
+// scansPerMinute returns the number of scans created in every minute of the
+// scanCapacityHorizon after the given time by the scan and team scan entries
+// returned by the given function, and the executions creating them. The
+// executions of the team scan entries create one scan per program of their
+// team, see teamProgramCount.
+func (c *Crontinuous) scansPerMinute(from time.Time, entriesOf func(CronType) ([]CronEntry, error)) (map[time.Time]int, []PlannedExecution, error) {
+	scanEntriesOf := func(typ CronType) ([]CronEntry, error) {
+		if typ != ScanCronType && typ != TeamScanCronType {
+			return nil, nil
+		}
+		return entriesOf(typ)
+	}
+	executions, err := c.simulate(from, from.Add(scanCapacityHorizon), scanCapacityHorizon, scanEntriesOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	perMinute := map[time.Time]int{}
+	programs := map[string]int{}
+	for _, e := range executions {
+		n := 1
+		if e.Type == TeamScanCronType {
+			if _, ok := programs[e.TeamID]; !ok {
+				programs[e.TeamID] = c.teamProgramCount(e.TeamID)
+			}
+			n = programs[e.TeamID]
+		}
+		perMinute[e.Time.Truncate(time.Minute)] += n
+	}
+	return perMinute, executions, nil
+}
+
+// checkScanCapacity returns ErrScanCapacityExceeded, for the first entry
+// exceeding it, if, once the given entries of the given type are saved, more
+// scans than Config.ScanCapacity would be created in any of the minutes the
+// entries fire at. The given entries replace the current versions of
+// themselves, and the current entries for which removed returns true, if it
+// is not nil, are removed. If the capacity is not enforced, or the check is
+// ignored, a warning is logged instead.
+func (c *Crontinuous) checkScanCapacity(typ CronType, saved []CronEntry, removed func(CronEntry) bool, ignore bool) error {
+	capacity := c.settings().ScanCapacity
+	if capacity <= 0 || (typ != ScanCronType && typ != TeamScanCronType) || len(saved) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]CronEntry, len(saved))
+	for _, e := range saved {
+		byID[e.GetID()] = e
+	}
+	entriesOf := func(t CronType) ([]CronEntry, error) {
+		entries, err := c.GetEntries(t)
+		if err != nil || t != typ {
+			return entries, err
+		}
+		desired := append([]CronEntry{}, saved...)
+		for _, e := range entries {
+			if _, ok := byID[e.GetID()]; ok || (removed != nil && removed(e)) {
+				continue
+			}
+			desired = append(desired, e)
+		}
+		return desired, nil
+	}
+	perMinute, executions, err := c.scansPerMinute(time.Now(), entriesOf)
+	if err != nil {
+		return err
+	}
+	var (
+		exceeded []time.Time
+		seen     = map[time.Time]bool{}
+		first    string
+	)
+	for _, e := range executions {
+		if _, ok := byID[e.EntryID]; !ok || e.Type != typ {
+			continue
+		}
+		minute := e.Time.Truncate(time.Minute)
+		if perMinute[minute] <= capacity || seen[minute] {
+			continue
+		}
+		if len(exceeded) == 0 {
+			first = e.EntryID
+		}
+		seen[minute] = true
+		exceeded = append(exceeded, minute)
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	if c.settings().EnforceScanCapacity && !ignore {
+		return entryError(byID[first], ErrScanCapacityExceeded)
+	}
+	c.log.WithFields(logrus.Fields{
+		"type":     typ.String(),
+		"entry_id": first,
+		"capacity": capacity,
+		"scans":    perMinute[exceeded[0]],
+		"at":       exceeded[0],
+		"minutes":  len(exceeded),
+	}).Warn("Entry exceeds the scan capacity of vulcan-api")
+	return nil
+}
+
+// warnScanCapacity logs a warning if the scans created by the schedule
+// exceed the configured capacity in the next 24h.
+func (c *Crontinuous) warnScanCapacity() {
+	if c.settings().ScanCapacity <= 0 {
+		return
+	}
+	d, err := c.cachedScanDensity()
+	if err != nil {
+		c.log.WithError(err).Error("Error computing the scan density")
+		return
+	}
+	if d.ExceededMinutes == 0 {
+		return
+	}
+	c.log.WithFields(logrus.Fields{
+		"capacity": d.Capacity,
+		"peak":     d.Peak,
+		"at":       *d.PeakAt,
+		"minutes":  d.ExceededMinutes,
+	}).Warn("Schedule exceeds the scan capacity of vulcan-api")
+}
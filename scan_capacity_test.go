@@ -0,0 +1,232 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_ScanCapacity(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"},
+			"p3": {ProgramID: "p3", TeamID: "t3", CronSpec: "0 3 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{ScanCapacity: 2, EnforceScanCapacity: true}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	d, err := c.ScanDensity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Capacity != 2 || d.Peak != 2 || d.PeakAt == nil || d.PeakAt.Hour() != 2 || d.ExceededMinutes != 0 {
+		t.Errorf("want a peak of 2 scans at 02:00 and no minutes exceeded, got %+v", d)
+	}
+
+	// Updating an entry does not count its current version.
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"}); err != nil {
+		t.Errorf("want the entry updated, got %v", err)
+	}
+
+	crowded := ScanEntry{ProgramID: "p4", TeamID: "t4", CronSpec: "0 2 * * *"}
+	if err := c.SaveEntry(ScanCronType, crowded); !errors.Is(err, ErrScanCapacityExceeded) {
+		t.Errorf("want ErrScanCapacityExceeded, got %v", err)
+	}
+	if _, ok := store.scanEntries["p4"]; ok {
+		t.Error("want the entry exceeding the capacity not stored")
+	}
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t4", CronSpec: "0 4 * * *"}); err != nil {
+		t.Errorf("want the entry under the capacity stored, got %v", err)
+	}
+	if err := c.SaveEntry(ScanCronType, crowded, IgnoreScanCapacity()); err != nil {
+		t.Errorf("want the entry stored ignoring the capacity, got %v", err)
+	}
+
+	d, err = c.ScanDensity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Peak != 3 || d.ExceededMinutes != 1 {
+		t.Errorf("want a peak of 3 scans exceeding the capacity in 1 minute, got %+v", d)
+	}
+}
+
+func TestCrontinuous_ScanCapacityNotEnforced(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{ScanCapacity: 1}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"}); err != nil {
+		t.Errorf("want the entry stored with a warning, got %v", err)
+	}
+	d, err := c.ScanDensity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Peak != 2 || d.ExceededMinutes != 1 {
+		t.Errorf("want a peak of 2 scans exceeding the capacity in 1 minute, got %+v", d)
+	}
+}
+
+func TestCrontinuous_ScanCapacityBulk(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{ScanCapacity: 2, EnforceScanCapacity: true}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// None of the entries exceeds the capacity alone, but together they do.
+	crowded := []CronEntry{
+		ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"},
+		ScanEntry{ProgramID: "p3", TeamID: "t3", CronSpec: "0 2 * * *"},
+	}
+	if err := c.BulkCreate(ScanCronType, crowded, []bool{false, false}); !errors.Is(err, ErrScanCapacityExceeded) {
+		t.Errorf("want ErrScanCapacityExceeded creating, got %v", err)
+	}
+	if _, err := c.BulkReplace(ScanCronType, crowded, BulkModeReplaceTeam); !errors.Is(err, ErrScanCapacityExceeded) {
+		t.Errorf("want ErrScanCapacityExceeded replacing, got %v", err)
+	}
+	if len(store.scanEntries) != 1 {
+		t.Errorf("want the entries exceeding the capacity not stored, got %+v", store.scanEntries)
+	}
+
+	// The entries removed by the replacement are not counted.
+	if _, err := c.BulkReplace(ScanCronType, crowded, BulkModeSync); err != nil {
+		t.Errorf("want the entries replacing the current ones stored, got %v", err)
+	}
+	if err := c.BulkCreate(ScanCronType, []CronEntry{
+		ScanEntry{ProgramID: "p4", TeamID: "t4", CronSpec: "0 2 * * *"},
+	}, []bool{false}, IgnoreScanCapacity()); err != nil {
+		t.Errorf("want the entries stored ignoring the capacity, got %v", err)
+	}
+}
+
+func TestCrontinuous_ScanCapacityTeamScans(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t2", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	teamScans := &mockTeamScanCronStore{entries: map[string]TeamScanEntry{
+		"t1": {TeamID: "t1", CronSpec: "0 2 * * *"},
+	}}
+	var (
+		mux      sync.Mutex
+		programs = []string{"p2", "p3", "p4"}
+		listed   int
+	)
+	lister := programListerFunc(func(string) ([]string, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		listed++
+		return programs, nil
+	})
+	creator := &mockScanCreator{creator: func(string, string) error { return nil }}
+	c := NewCrontinuous(Config{ScanCapacity: 3, EnforceScanCapacity: true}, logrus.New(), creator, store, nil, store, WithTeamScans(lister, teamScans))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The team scan creates one scan per program of the team.
+	d, err := c.ScanDensity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Peak != 4 || d.ExceededMinutes != 1 {
+		t.Errorf("want a peak of 4 scans exceeding the capacity in 1 minute, got %+v", d)
+	}
+	err = c.SaveEntry(TeamScanCronType, TeamScanEntry{TeamID: "t3", CronSpec: "0 2 * * *"})
+	if !errors.Is(err, ErrScanCapacityExceeded) {
+		t.Errorf("want ErrScanCapacityExceeded, got %v", err)
+	}
+
+	// The programs found by the last execution of the team scan are used.
+	mux.Lock()
+	programs = []string{"p2"}
+	mux.Unlock()
+	job := &teamScanJob{id: "t1", teamID: "t1", programLister: lister, programs: &c.teamPrograms, scanCreator: creator, log: logrus.NewEntry(logrus.New())}
+	if err := job.run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d, err = c.ScanDensity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Peak != 2 || d.ExceededMinutes != 0 {
+		t.Errorf("want a peak of 2 scans and no minutes exceeded, got %+v", d)
+	}
+	if listed != 3 {
+		t.Errorf("want the programs of t1 and t3 listed once and the ones of t1 once executed, got %d listings", listed)
+	}
+}
+
+func TestCrontinuous_ScanDensityMetrics(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{ScanCapacity: 1}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	peak := func() float64 {
+		for _, m := range c.Metrics() {
+			if m.Name == "scans_per_minute_peak" {
+				return m.Value
+			}
+		}
+		t.Fatal("scans_per_minute_peak metric not found")
+		return 0
+	}
+	if got := peak(); got != 1 {
+		t.Fatalf("want a peak of 1 scan, got %v", got)
+	}
+
+	// The density is not computed again while the entries do not change.
+	c.densities.mux.Lock()
+	c.densities.density.Peak = 42
+	c.densities.mux.Unlock()
+	if got := peak(); got != 42 {
+		t.Errorf("want the cached peak, got %v", got)
+	}
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := peak(); got != 2 {
+		t.Errorf("want the peak computed again once the entries change, got %v", got)
+	}
+}
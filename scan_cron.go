@@ -5,8 +5,9 @@ Copyright 2020 Adevinta
 package crontinuous
 
 import (
+	"context"
+
 	"github.com/Sirupsen/logrus"
-	"github.com/manelmontilla/cron"
 )
 
 const (
@@ -19,152 +20,122 @@ type ScanCreator interface {
 	CreateScan(scanID, teamID string) error
 }
 
+// ContextScanCreator is implemented by the scan creators that
+// support aborting the creation of a scan.
+type ContextScanCreator interface {
+	CreateScanContext(ctx context.Context, scanID, teamID string) error
+}
+
+// ResultScanCreator is implemented by the scan creators that return the ID
+// of the scan created, recorded in the execution of the entry, so the
+// executions can be cross-referenced with the scans of vulcan-api. It is
+// preferred to ContextScanCreator, and the ID can be empty if it is not
+// known.
+type ResultScanCreator interface {
+	CreateScanResult(ctx context.Context, scanID, teamID string) (string, error)
+}
+
+func createScan(ctx context.Context, sc ScanCreator, scanID, teamID string) error {
+	if rsc, ok := sc.(ResultScanCreator); ok {
+		id, err := rsc.CreateScanResult(ctx, scanID, teamID)
+		if err != nil {
+			return err
+		}
+		recordScanID(ctx, id)
+		return nil
+	}
+	if csc, ok := sc.(ContextScanCreator); ok {
+		return csc.CreateScanContext(ctx, scanID, teamID)
+	}
+	return sc.CreateScan(scanID, teamID)
+}
+
 // ScanEntry defines the data stored by a scan cron entry.
 type ScanEntry struct {
-	ProgramID string `json:"program_id"`
-	TeamID    string `json:"team_id"`
-	CronSpec  string `json:"cron_spec"`
+	// ID identifies the entry when there can be many entries per program,
+	// see WithScanIDStrategy. The entries without ID are identified by
+	// their program ID.
+	ID        string `json:"id,omitempty" yaml:"id,omitempty"`
+	ProgramID string `json:"program_id" yaml:"program_id"`
+	TeamID    string `json:"team_id" yaml:"team_id"`
+	CronSpec  string `json:"cron_spec" yaml:"cron_spec"`
+
+	EntrySettings `yaml:",inline"`
 }
 
 func (e ScanEntry) GetID() string {
+	if e.ID != "" {
+		return e.ID
+	}
 	return e.ProgramID
 }
+func (e ScanEntry) GetTeamID() string {
+	return e.TeamID
+}
+func (e ScanEntry) GetType() CronType {
+	return ScanCronType
+}
 func (e ScanEntry) GetCronSpec() string {
 	return e.CronSpec
 }
+func (e ScanEntry) Validate() error {
+	if e.ProgramID == "" {
+		return ErrMalformedEntry
+	}
+	if e.TeamID == "" {
+		return ErrMalformedEntry
+	}
+	if err := e.EntrySettings.validate(); err != nil {
+		return err
+	}
+	return validateCronSpec(e.CronSpec)
+}
 
 type scanJob struct {
+	id          string
 	programID   string
 	teamID      string
 	scanCreator ScanCreator
 	log         *logrus.Entry
 }
 
-func (j *scanJob) Run() {
-	j.log.Info("Executing Scan Job")
-	err := j.scanCreator.CreateScan(j.programID, j.teamID)
-	if err != nil {
-		j.log.Error("Error Executing Scan Job", err)
-		return
-	}
-	j.log.Info("Executed Scan Job")
+func (j *scanJob) team() string {
+	return j.teamID
 }
 
-func (c *Crontinuous) scanBulkCreate(scheduledEntries map[string]cronEntryWithSchedule) ([]cronJobSchedule, error) {
-	c.scanMux.Lock()
-	defer c.scanMux.Unlock()
-
-	// Make deep copy of current jobs in order
-	// to make the operation atomic.
-	current := make(map[string]ScanEntry)
-	for _, e := range c.scanEntries {
-		current[e.ProgramID] = e
-	}
-
-	// Update the hash of entries and create required jobs to be scheduled.
-	scheduledJobs := []cronJobSchedule{}
-	for _, e := range scheduledEntries {
-		var se ScanEntry
-		var ok bool
-
-		if se, ok = e.entry.(ScanEntry); !ok {
-			return nil, ErrMalformedEntry
-		}
-
-		if _, ok := current[se.ProgramID]; ok && !e.overwriteEntry {
-			continue
-		}
-
-		current[se.ProgramID] = se
-
-		if !c.isTeamWhitelisted(ScanCronType, se.TeamID) {
-			// If team is not whitelisted, do not
-			// return job to schedule.
-			continue
-		}
-
-		jobLog := logrus.New().WithFields(logrus.Fields{"job": se.ProgramID})
-		scheduledJobs = append(scheduledJobs, cronJobSchedule{
-			schedule: e.schedule,
-			job: &scanJob{
-				scanCreator: c.scanCreator,
-				programID:   se.ProgramID,
-				teamID:      se.TeamID,
-				log:         jobLog,
-			},
-			id: se.ProgramID,
-		})
-	}
-
-	// Now it's safe to update all the entries and reschedule the jobs.
-	c.scanEntries = current
-	err := c.scanCronStore.SaveScanEntries(c.scanEntries)
-	return scheduledJobs, err
+func (j *scanJob) cronType() CronType {
+	return ScanCronType
 }
 
-func (c *Crontinuous) saveScanEntry(entry CronEntry) (cron.Job, error) {
-	scanEntry, ok := entry.(ScanEntry)
-	if !ok {
-		return nil, ErrMalformedEntry
-	}
-
-	c.scanMux.Lock()
-	defer c.scanMux.Unlock()
-
-	c.scanEntries[scanEntry.ProgramID] = scanEntry
-
-	err := c.scanCronStore.SaveScanEntries(c.scanEntries)
-	if err != nil {
-		return nil, err
-	}
-
-	if !c.isTeamWhitelisted(ScanCronType, scanEntry.TeamID) {
-		return nil, errTeamNotWhitelisted
-	}
-
-	jobLog := logrus.New().WithFields(logrus.Fields{"job": scanEntry.ProgramID})
-
-	return &scanJob{
-		scanCreator: c.scanCreator,
-		programID:   scanEntry.ProgramID,
-		teamID:      scanEntry.TeamID,
-		log:         jobLog,
-	}, nil
+func (j *scanJob) entryID() string {
+	return j.id
 }
 
-func (c *Crontinuous) getScanEntries() ([]CronEntry, error) {
-	c.scanMux.RLock()
-	defer c.scanMux.RUnlock()
-
-	var entries = []CronEntry{}
-	for _, e := range c.scanEntries {
-		entries = append(entries, e)
-	}
-
-	return entries, nil
-}
-
-func (c *Crontinuous) getScanEntryByID(ID string) (ScanEntry, error) {
-	c.scanMux.RLock()
-	defer c.scanMux.RUnlock()
-
-	e, ok := c.scanEntries[ID]
-	if !ok {
-		return ScanEntry{}, ErrScheduleNotFound
+func (j *scanJob) run(ctx context.Context) error {
+	log := j.log.WithFields(executionFields(ctx))
+	log.Info("Executing Scan Job")
+	err := createScan(ctx, j.scanCreator, j.programID, j.teamID)
+	if err != nil {
+		log.Error("Error Executing Scan Job", err)
+		return err
 	}
-
-	return e, nil
+	log.Info("Executed Scan Job")
+	return nil
 }
 
-func (c *Crontinuous) removeScanEntry(ID string) error {
-	c.scanMux.Lock()
-	defer c.scanMux.Unlock()
-
-	_, ok := c.scanEntries[ID]
-	if !ok {
-		return ErrScheduleNotFound
-	}
-	delete(c.scanEntries, ID)
-
-	return c.scanCronStore.SaveScanEntries(c.scanEntries)
+func (c *Crontinuous) newScanJob(e ScanEntry) entryJob {
+	return c.newEntryJob(&scanJob{
+		id:          e.GetID(),
+		programID:   e.ProgramID,
+		teamID:      e.TeamID,
+		scanCreator: c.scanCreator,
+		log:         logrus.New().WithFields(logrus.Fields{"job": e.ProgramID}),
+	}, jobSettings{
+		timeout:      e.ExecutionTimeout,
+		pingURL:      e.PingURL,
+		alerting:     e.Alerting,
+		jitter:       e.Jitter,
+		windows:      e.Windows,
+		windowPolicy: e.WindowPolicy,
+	})
 }
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ScanIDsPerProgram is the name of the strategy allowing one scan entry
+	// per program.
+	ScanIDsPerProgram = "program"
+	// ScanIDsPerProgramSpec is the name of the strategy allowing one scan
+	// entry per program and spec.
+	ScanIDsPerProgramSpec = "program-spec"
+)
+
+// ScanIDStrategy defines which scan entries are the same entry and which ID
+// the new ones get. The scan entries saved without ID take the ID of the
+// existing entry with the same key, if any, or a new one otherwise, and the
+// ones saved with an ID can not have the key of another entry.
+type ScanIDStrategy interface {
+	// Key returns the value that must be unique among the scan entries.
+	Key(e ScanEntry) string
+	// NewID returns the ID of a new scan entry, empty to identify it by
+	// its program ID, as the entries stored before the IDs were introduced.
+	NewID(e ScanEntry) string
+}
+
+// ProgramScanIDs allows one scan entry per program, identified by the ID of
+// the program.
+type ProgramScanIDs struct{}
+
+// Key returns the program ID of the entry.
+func (ProgramScanIDs) Key(e ScanEntry) string {
+	return e.ProgramID
+}
+
+// NewID returns an empty ID, so the entry is identified by its program ID.
+func (ProgramScanIDs) NewID(e ScanEntry) string {
+	return ""
+}
+
+// ProgramSpecScanIDs allows many scan entries per program, e.g. a weekly
+// deep scan and a nightly light one, as long as their specs are different.
+// The new entries are identified by a random UUID.
+type ProgramSpecScanIDs struct{}
+
+// Key returns the program ID and the normalized spec of the entry, so the
+// same schedule written differently, e.g. @daily and 0 0 * * *, is the same
+// key. The H tokens are resolved with the program ID, as the key of an entry
+// is needed before it gets its ID.
+func (ProgramSpecScanIDs) Key(e ScanEntry) string {
+	return e.ProgramID + " " + normalizeCronSpec(e.CronSpec, e.ProgramID)
+}
+
+// NewID returns a random UUID.
+func (ProgramSpecScanIDs) NewID(e ScanEntry) string {
+	return uuid.New().String()
+}
+
+// ScanIDStrategyByName returns the ID strategy with the given name,
+// ScanIDsPerProgram or ScanIDsPerProgramSpec. Empty means ScanIDsPerProgram.
+func ScanIDStrategyByName(name string) (ScanIDStrategy, error) {
+	switch name {
+	case "", ScanIDsPerProgram:
+		return ProgramScanIDs{}, nil
+	case ScanIDsPerProgramSpec:
+		return ProgramSpecScanIDs{}, nil
+	}
+	return nil, fmt.Errorf("unknown scan ID strategy %q", name)
+}
+
+// WithScanIDStrategy makes crontinuous assign the IDs of the scan entries
+// with the given strategy. The entries stored without ID, as all of them
+// were before the IDs were introduced, keep being identified by their
+// program ID, so the stores do not need to be migrated. Without a strategy,
+// the entries are identified by their ID, or their program ID if they have
+// none, and not checked for uniqueness.
+func WithScanIDStrategy(s ScanIDStrategy) Option {
+	return func(c *Crontinuous) {
+		c.scanIDs = s
+	}
+}
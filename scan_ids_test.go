@@ -0,0 +1,238 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_ScanIDStrategies(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithScanIDStrategy(ProgramSpecScanIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The entry stored without ID keeps its program ID.
+	var id string
+	err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"}, SavedID(&id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "p1" {
+		t.Errorf("want the legacy entry identified by its program ID, got %q", id)
+	}
+
+	// A program can have many entries with different specs.
+	var weekly string
+	err = c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * 0"}, SavedID(&weekly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weekly == "" || weekly == "p1" {
+		t.Fatalf("want a new ID for the second entry of the program, got %q", weekly)
+	}
+	var again string
+	err = c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * 0"}, SavedID(&again))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != weekly {
+		t.Errorf("want the entry with the same spec updated, got ID %q, want %q", again, weekly)
+	}
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("want 2 entries, got %+v", entries)
+	}
+
+	// An entry can not take the program and spec of another one.
+	err = c.SaveEntry(ScanCronType, ScanEntry{ID: weekly, ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"})
+	if err != ErrDuplicatedEntry {
+		t.Errorf("want error %v, got %v", ErrDuplicatedEntry, err)
+	}
+
+	// Changing the spec of an entry by its ID frees the previous one.
+	err = c.SaveEntry(ScanCronType, ScanEntry{ID: weekly, ProgramID: "p1", TeamID: "t1", CronSpec: "0 4 * * 0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := c.GetEntryByID(ScanCronType, weekly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.GetCronSpec() != "0 4 * * 0" {
+		t.Errorf("want the spec of the entry updated, got %q", e.GetCronSpec())
+	}
+
+	// Applying a manifest without IDs keeps the IDs of the current entries.
+	changes, err := c.Apply(Manifest{Scans: []ScanEntry{
+		{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+		{ProgramID: "p1", TeamID: "t1", CronSpec: "0 4 * * 0"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("want no changes applying the current entries, got %+v", changes)
+	}
+	_, err = c.Apply(Manifest{Scans: []ScanEntry{
+		{ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"},
+		{ProgramID: "p2", TeamID: "t1", CronSpec: "0 1 * * *"},
+	}})
+	if err == nil {
+		t.Errorf("want error %v applying duplicated entries, got nil", ErrDuplicatedEntry)
+	}
+}
+
+func TestCrontinuous_ProgramScanIDs(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithScanIDStrategy(ProgramScanIDs{}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	for _, spec := range []string{"0 1 * * *", "0 2 * * *"} {
+		err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].GetID() != "p1" || entries[0].GetCronSpec() != "0 2 * * *" {
+		t.Errorf("want the entry of the program updated, got %+v", entries)
+	}
+}
+
+// barrierValidator holds the validations until all the given ones are in
+// progress.
+type barrierValidator struct {
+	mockValidator
+	barrier *sync.WaitGroup
+}
+
+func (v *barrierValidator) GetTeamContext(ctx context.Context, teamID string) (Team, error) {
+	v.barrier.Done()
+	v.barrier.Wait()
+	return Team{ID: teamID}, nil
+}
+
+func TestCrontinuous_ScanIDStrategiesConcurrentSaves(t *testing.T) {
+	const saves = 5
+	var barrier sync.WaitGroup
+	barrier.Add(saves)
+	validator := &barrierValidator{mockValidator: mockValidator{programs: map[string][]string{"t1": {"p1"}}}, barrier: &barrier}
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithScanIDStrategy(ProgramSpecScanIDs{}), WithValidator(validator))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// The saves of the same program and spec without ID are the same entry,
+	// even if they are validated concurrently.
+	ids := make([]string, saves)
+	var wg sync.WaitGroup
+	for i := 0; i < saves; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * 0"}, SavedID(&ids[i]))
+			if err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, got %+v", entries)
+	}
+	for _, id := range ids {
+		if id != entries[0].GetID() {
+			t.Errorf("want every save identified as %q, got %q", entries[0].GetID(), id)
+		}
+	}
+}
+
+func TestProgramSpecScanIDs_Key(t *testing.T) {
+	key := func(spec string) string {
+		return ProgramSpecScanIDs{}.Key(ScanEntry{ProgramID: "p1", CronSpec: spec})
+	}
+	resolved, err := ResolveCronSpec("H 2 * * *", "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	same := [][]string{
+		{"@daily", "0 0 * * *", "@midnight"},
+		{"0  3 * * 0", " 0 3 * * 0 "},
+		{"H 2 * * *", "H(0-59) 2 * * *", resolved},
+	}
+	for _, specs := range same {
+		for _, spec := range specs[1:] {
+			if key(spec) != key(specs[0]) {
+				t.Errorf("want %q and %q to be the same key, got %q and %q", specs[0], spec, key(specs[0]), key(spec))
+			}
+		}
+	}
+	if key("@daily") == key("@hourly") {
+		t.Errorf("want different schedules to be different keys")
+	}
+}
+
+func TestCrontinuous_ScanIDStrategiesNormalizedSpecs(t *testing.T) {
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithScanIDStrategy(ProgramSpecScanIDs{}), WithSpecAliases(map[string]string{"@nightly": "0 0 * * *"}))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	var id string
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *"}, SavedID(&id)); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{"@daily", "0  0 * * *", "@nightly"} {
+		var again string
+		if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: spec}, SavedID(&again)); err != nil {
+			t.Fatal(err)
+		}
+		if again != id {
+			t.Errorf("%s: want the entry with the same schedule updated, got ID %q, want %q", spec, again, id)
+		}
+	}
+	entries, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("want 1 entry, got %+v", entries)
+	}
+}
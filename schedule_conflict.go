@@ -0,0 +1,143 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"time"
+
+	"github.com/manelmontilla/cron"
+)
+
+// scheduleConflictHorizon is the period of time, starting from now, in which
+// scan and report executions are compared to detect conflicts.
+const scheduleConflictHorizon = 7 * 24 * time.Hour
+
+// SaveOption configures the behaviour of a call saving entries, as SaveEntry.
+type SaveOption func(*saveOptions)
+
+type saveOptions struct {
+	ignoreConflicts bool
+	ignoreCapacity  bool
+	changedBy       string
+	canary          CanaryMode
+	startedCanary   *Canary
+	savedID         *string
+	teams           []string
+}
+
+// IgnoreScheduleConflicts makes SaveEntry, and the bulk creations and
+// replacements, store the entries even if they are scheduled too close to the
+// executions of entries of the other type for the same team.
+func IgnoreScheduleConflicts() SaveOption {
+	return func(o *saveOptions) {
+		o.ignoreConflicts = true
+	}
+}
+
+// ReplaceTeams makes BulkReplace, with BulkModeReplaceTeam, replace the
+// entries of the given teams besides the ones of the teams of the given
+// entries, so the entries of a team can be removed by giving none of them.
+func ReplaceTeams(teams ...string) SaveOption {
+	return func(o *saveOptions) {
+		o.teams = append(o.teams, teams...)
+	}
+}
+
+// checkScheduleConflict returns ErrScheduleConflict if the given schedule fires
+// within Config.ReportScanMinGap of any execution of an entry of the opposite
+// type belonging to the same team. Reports generated while the scans of a team
+// are still running would be misleading.
+func (c *Crontinuous) checkScheduleConflict(typ CronType, entry CronEntry, s cron.Schedule) error {
+	gap := c.settings().ReportScanMinGap
+	if gap <= 0 || typ == CommandCronType {
+		return nil
+	}
+
+	// Reports conflict with scans and team scans
+	// and both kinds of scans conflict with reports.
+	otherTypes := []CronType{ReportCronType}
+	if typ == ReportCronType {
+		otherTypes = []CronType{ScanCronType}
+		if c.teamScansEnabled() {
+			otherTypes = append(otherTypes, TeamScanCronType)
+		}
+	}
+
+	var others []cron.Schedule
+	for _, ot := range otherTypes {
+		entries, err := c.GetEntries(ot)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.GetTeamID() != entry.GetTeamID() {
+				continue
+			}
+			if es, err := c.entrySchedule(e); err == nil {
+				others = append(others, es)
+			}
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+
+	from := time.Now()
+	to := from.Add(scheduleConflictHorizon)
+	fires := activations(s, from, to)
+	for _, o := range others {
+		if minDistance(fires, activations(o, from, to)) < gap {
+			return ErrScheduleConflict
+		}
+	}
+	return nil
+}
+
+// lockConflicts takes the lock of the conflicts if the entries of the given
+// type are checked for schedule conflicts, and returns the function releasing
+// it. The check reads the entries of the other types without taking the lock
+// of their sets, so the saves of the entries checked are serialized by this
+// lock instead, and two entries of opposite types of a team saved
+// concurrently can not both pass the check against the previous version of
+// the other one. It must be called before taking the lock of any set.
+func (c *Crontinuous) lockConflicts(typ CronType) func() {
+	if typ == CommandCronType || c.settings().ReportScanMinGap <= 0 {
+		return func() {}
+	}
+	c.conflictsMux.Lock()
+	return c.conflictsMux.Unlock
+}
+
+// activations returns the times, in ascending order, a schedule
+// fires in the interval (from, to].
+func activations(s cron.Schedule, from, to time.Time) []time.Time {
+	var times []time.Time
+	for t := s.Next(from); !t.IsZero() && !t.After(to); t = s.Next(t) {
+		times = append(times, t)
+	}
+	return times
+}
+
+// minDistance returns the minimum distance between any pair of times
+// of the given ascending lists.
+func minDistance(a, b []time.Time) time.Duration {
+	min := time.Duration(1<<63 - 1)
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		d := a[i].Sub(b[j])
+		if d < 0 {
+			d = -d
+		}
+		if d < min {
+			min = d
+		}
+		if a[i].Before(b[j]) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return min
+}
@@ -0,0 +1,281 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/manelmontilla/cron"
+)
+
+const (
+	// CronEngineFork is the name of the scheduler based on the
+	// manelmontilla/cron fork, used by default.
+	CronEngineFork = "fork"
+	// CronEngineInternal is the name of the scheduler implemented in this
+	// package.
+	CronEngineInternal = "internal"
+)
+
+// Schedule defines the activations of a job.
+type Schedule interface {
+	// Next returns the next activation time, later than the given time.
+	Next(time.Time) time.Time
+}
+
+// Job is executed by a Scheduler on every activation of its schedule.
+type Job interface {
+	Run()
+}
+
+// ScheduledJob defines a job in a Scheduler.
+type ScheduledJob struct {
+	ID       string
+	Schedule Schedule
+	Job      Job
+	// Next is the next activation of the job, zero if the scheduler is not
+	// started or the schedule has no more activations.
+	Next time.Time
+	// Prev is the last activation of the job, zero if it has not run.
+	Prev time.Time
+}
+
+// Scheduler executes jobs on the activations of their schedules. Crontinuous
+// creates a new scheduler every time the crontab is rebuilt, so the
+// schedulers are not reused after being stopped.
+type Scheduler interface {
+	// Schedule adds the given job, replacing the job with the same ID, if
+	// any.
+	Schedule(s Schedule, j Job, id string)
+	// RemoveJob removes the job with the given ID, if any.
+	RemoveJob(id string)
+	// Jobs returns the jobs sorted by their next activation, the jobs without
+	// next activation at the end.
+	Jobs() []ScheduledJob
+	Start()
+	// Stop stops the activation of the jobs. It does not wait for the
+	// running jobs to finish.
+	Stop()
+}
+
+// WithScheduler makes crontinuous execute the jobs with the schedulers
+// returned by the given function, e.g. NewInternalScheduler or an adapter of
+// other cron library.
+func WithScheduler(newScheduler func() Scheduler) Option {
+	return func(c *Crontinuous) {
+		c.newScheduler = newScheduler
+	}
+}
+
+// SchedulerEngine returns the function creating the schedulers of the given
+// engine, CronEngineFork or CronEngineInternal, to be passed to
+// WithScheduler. Empty means CronEngineFork.
+func SchedulerEngine(engine string, logger *logrus.Logger) (func() Scheduler, error) {
+	switch engine {
+	case "", CronEngineFork:
+		return NewForkScheduler, nil
+	case CronEngineInternal:
+		return func() Scheduler { return NewInternalScheduler(logger) }, nil
+	}
+	return nil, fmt.Errorf("unknown cron engine %q", engine)
+}
+
+// forkScheduler adapts a manelmontilla/cron engine to a Scheduler. Removing
+// jobs from it blocks until it is started.
+type forkScheduler struct {
+	cron *cron.Cron
+}
+
+// NewForkScheduler returns a scheduler based on the manelmontilla/cron fork.
+func NewForkScheduler() Scheduler {
+	return &forkScheduler{cron: cron.New()}
+}
+
+func (s *forkScheduler) Schedule(sch Schedule, j Job, id string) {
+	s.cron.Schedule(sch, j, id)
+}
+
+func (s *forkScheduler) RemoveJob(id string) {
+	s.cron.RemoveJob(id)
+}
+
+func (s *forkScheduler) Jobs() []ScheduledJob {
+	entries := s.cron.Entries()
+	jobs := make([]ScheduledJob, len(entries))
+	for i, e := range entries {
+		jobs[i] = ScheduledJob{ID: e.ID, Schedule: e.Schedule, Job: e.Job, Next: e.Next, Prev: e.Prev}
+	}
+	return jobs
+}
+
+func (s *forkScheduler) Start() {
+	s.cron.Start()
+}
+
+func (s *forkScheduler) Stop() {
+	s.cron.Stop()
+}
+
+// internalScheduler implements a Scheduler that, unlike the fork, can have
+// its jobs removed before being started.
+type internalScheduler struct {
+	log *logrus.Logger
+
+	mux     sync.Mutex
+	jobs    map[string]*ScheduledJob
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+	// wake makes the loop recompute the next activation.
+	wake chan struct{}
+}
+
+// NewInternalScheduler returns a scheduler implemented in this package,
+// which logs to the given logger the panics of the jobs.
+func NewInternalScheduler(logger *logrus.Logger) Scheduler {
+	return &internalScheduler{
+		log:  logger,
+		jobs: map[string]*ScheduledJob{},
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (s *internalScheduler) Schedule(sch Schedule, j Job, id string) {
+	s.mux.Lock()
+	job := &ScheduledJob{ID: id, Schedule: sch, Job: j}
+	if s.running {
+		job.Next = sch.Next(time.Now())
+	}
+	s.jobs[id] = job
+	s.mux.Unlock()
+	s.notify()
+}
+
+func (s *internalScheduler) RemoveJob(id string) {
+	s.mux.Lock()
+	delete(s.jobs, id)
+	s.mux.Unlock()
+	s.notify()
+}
+
+func (s *internalScheduler) Jobs() []ScheduledJob {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	jobs := make([]ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Next.IsZero() {
+			return false
+		}
+		if jobs[j].Next.IsZero() {
+			return true
+		}
+		return jobs[i].Next.Before(jobs[j].Next)
+	})
+	return jobs
+}
+
+func (s *internalScheduler) Start() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.running {
+		return
+	}
+	now := time.Now()
+	for _, j := range s.jobs {
+		j.Next = j.Schedule.Next(now)
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop(s.stop, s.done)
+}
+
+func (s *internalScheduler) Stop() {
+	s.mux.Lock()
+	if !s.running {
+		s.mux.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mux.Unlock()
+	<-done
+}
+
+func (s *internalScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *internalScheduler) loop(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		timer := time.NewTimer(s.untilNext())
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case now := <-timer.C:
+			s.runDue(now)
+		}
+	}
+}
+
+// untilNext returns the time until the next activation of any job.
+func (s *internalScheduler) untilNext() time.Duration {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var next time.Time
+	for _, j := range s.jobs {
+		if !j.Next.IsZero() && (next.IsZero() || j.Next.Before(next)) {
+			next = j.Next
+		}
+	}
+	if next.IsZero() {
+		// Only a change of the jobs can wake the loop.
+		return 24 * time.Hour
+	}
+	return time.Until(next)
+}
+
+// runDue executes the jobs whose activation is due at the given time.
+func (s *internalScheduler) runDue(now time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, j := range s.jobs {
+		if j.Next.IsZero() || j.Next.After(now) {
+			continue
+		}
+		go s.run(j.ID, j.Job)
+		j.Prev = j.Next
+		j.Next = j.Schedule.Next(now)
+	}
+}
+
+func (s *internalScheduler) run(id string, j Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.WithField("job", id).Errorf("panic running job: %v\n%s", r, debug.Stack())
+		}
+	}()
+	j.Run()
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// everySchedule activates every given interval.
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+type chanJob chan string
+
+func (j chanJob) Run() {
+	j <- "run"
+}
+
+type panickingJob struct{}
+
+func (panickingJob) Run() {
+	panic("job failed")
+}
+
+func TestInternalScheduler(t *testing.T) {
+	s := NewInternalScheduler(logrus.New())
+
+	runs := make(chanJob, 10)
+	s.Schedule(everySchedule(10*time.Millisecond), runs, "j1")
+	s.Schedule(everySchedule(time.Hour), runs, "j2")
+	s.Schedule(everySchedule(10*time.Millisecond), panickingJob{}, "j3")
+	// Removing jobs must not block while the scheduler is stopped.
+	s.RemoveJob("j2")
+	if n := len(s.Jobs()); n != 2 {
+		t.Fatalf("want 2 jobs, got %d", n)
+	}
+
+	s.Start()
+	s.Schedule(everySchedule(time.Hour), runs, "j4")
+	select {
+	case <-runs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job not executed")
+	}
+
+	jobs := s.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("want 3 jobs, got %d", len(jobs))
+	}
+	if last := jobs[len(jobs)-1]; last.ID != "j4" || last.Next.IsZero() {
+		t.Errorf("want j4 scheduled last, got %+v", last)
+	}
+
+	s.RemoveJob("j1")
+	s.Stop()
+	for len(runs) > 0 {
+		<-runs
+	}
+	select {
+	case <-runs:
+		t.Error("job executed after stopping the scheduler")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestCrontinuous_InternalScheduler(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	newScheduler, err := SchedulerEngine(CronEngineInternal, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithScheduler(newScheduler))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 2 1 *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	jobs := c.cron.Jobs()
+	if len(jobs) != 1 || jobs[0].ID != "p2" {
+		t.Errorf("want only the job of p2 scheduled, got %+v", jobs)
+	}
+
+	if _, err := SchedulerEngine("unknown", nil); err == nil {
+		t.Error("want error for an unknown engine")
+	}
+}
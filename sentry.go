@@ -0,0 +1,239 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// sentryTimeout is the maximum time to wait for
+	// Sentry to accept an event.
+	sentryTimeout = 10 * time.Second
+
+	// sentryQueueSize is the number of events waiting to be sent
+	// to Sentry above which the reported errors are dropped.
+	sentryQueueSize = 100
+
+	// sentryStopTimeout is the maximum time to wait, when
+	// stopping, for the queued events to be sent.
+	sentryStopTimeout = 30 * time.Second
+)
+
+// SentryConfig defines the configuration of the SentryReporter.
+type SentryConfig struct {
+	// DSN is the Data Source Name of the Sentry project,
+	// e.g. https://key@sentry.example.com/42.
+	DSN string
+	// Environment is the environment the events are reported from.
+	Environment string
+	// Tags are added, in the format "key:value", to all the events.
+	Tags []string
+}
+
+// SentryReporter implements an ErrorReporter sending the errors as events to
+// Sentry using its store HTTP API. The events are sent one at a time by a
+// background worker, and the errors reported while its queue is full are
+// dropped.
+type SentryReporter struct {
+	endpoint    string
+	auth        string
+	environment string
+	tags        map[string]string
+	client      *http.Client
+	log         *logrus.Logger
+
+	queue   chan sentryEvent
+	done    chan struct{}
+	mux     sync.Mutex
+	stopped bool
+	dropped uint64
+}
+
+// NewSentryReporter returns a SentryReporter sending
+// the events to the project of the configured DSN.
+func NewSentryReporter(cfg SentryConfig, logger *logrus.Logger) (*SentryReporter, error) {
+	u, err := url.Parse(cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sentry DSN: %w", err)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	i := strings.LastIndex(path, "/")
+	if u.Host == "" || u.User == nil || u.User.Username() == "" || i < 0 || path[i+1:] == "" {
+		return nil, fmt.Errorf("invalid sentry DSN: %s", cfg.DSN)
+	}
+	project := path[i+1:]
+	endpoint := fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:i], project)
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=vulcan-crontinuous, sentry_key=%s", u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	tags := map[string]string{}
+	for _, t := range cfg.Tags {
+		k, v, ok := strings.Cut(t, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid sentry tag %q, want key:value", t)
+		}
+		tags[k] = v
+	}
+	s := &SentryReporter{
+		endpoint:    endpoint,
+		auth:        auth,
+		environment: cfg.Environment,
+		tags:        tags,
+		client:      &http.Client{Timeout: sentryTimeout},
+		log:         logger,
+		queue:       make(chan sentryEvent, sentryQueueSize),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Message     string            `json:"message"`
+	Environment string            `json:"environment,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+// Report queues the error to be sent to Sentry in the background, so the
+// failing job or store operation is not delayed. If the queue is full, or the
+// reporter is stopped, the error is dropped. Errors sending it are only
+// logged.
+func (s *SentryReporter) Report(r ErrorReport) {
+	ev, err := s.event(r)
+	if err != nil {
+		s.log.WithError(err).Error("Error building sentry event")
+		return
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.stopped {
+		select {
+		case s.queue <- ev:
+			return
+		default:
+		}
+	}
+	s.dropped++
+	s.log.WithError(r.Err).WithField("dropped", s.dropped).Warn("Error not reported to sentry, queue full or stopped")
+}
+
+// Dropped returns the number of errors not sent to
+// Sentry because the queue was full.
+func (s *SentryReporter) Dropped() uint64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.dropped
+}
+
+// Stop stops accepting errors and waits, up to sentryStopTimeout,
+// for the queued events to be sent.
+func (s *SentryReporter) Stop() {
+	s.mux.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.queue)
+	}
+	s.mux.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(sentryStopTimeout):
+		s.log.WithField("pending", len(s.queue)).Error("Timeout sending the queued events to sentry")
+	}
+}
+
+func (s *SentryReporter) run() {
+	defer close(s.done)
+	for ev := range s.queue {
+		if err := s.send(ev); err != nil {
+			s.log.WithError(err).Error("Error sending event to sentry")
+		}
+	}
+}
+
+func (s *SentryReporter) event(r ErrorReport) (sentryEvent, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return sentryEvent{}, err
+	}
+	ev := sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "error",
+		Platform:    "go",
+		Logger:      "crontinuous",
+		Message:     r.Err.Error(),
+		Environment: s.environment,
+		Tags:        map[string]string{},
+	}
+	for k, v := range s.tags {
+		ev.Tags[k] = v
+	}
+	for k, v := range r.Tags {
+		ev.Tags[k] = v
+	}
+	if r.Stack != "" {
+		ev.Extra = map[string]string{"stack": r.Stack}
+	}
+	ev.Exception.Values = []sentryException{{
+		Type:  fmt.Sprintf("%T", r.Err),
+		Value: r.Err.Error(),
+	}}
+	return ev, nil
+}
+
+func (s *SentryReporter) send(ev sentryEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()            // nolint
+	io.Copy(ioutil.Discard, resp.Body) // nolint
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// UnscheduledOtherShard is the reason an entry is stored but not scheduled
+// when it is assigned to the shard of another instance.
+const UnscheduledOtherShard = "entry assigned to another shard"
+
+// shard identifies the part of the entries scheduled by an instance.
+type shard struct {
+	index int
+	count int
+}
+
+// WithShard makes the instance schedule only the entries assigned to the
+// shard with the given index, from 0 to count-1, so count instances sharing
+// the same stores schedule every entry exactly once. The entries are
+// assigned by the hash of the ID of their job, which is the ID of the entry
+// prefixed by its type for the team scan and command entries. All the
+// instances must be configured with the same count. The entries are still
+// stored and served by every instance: each mutation is applied to the
+// entries read from the store, so it does not overwrite the changes made
+// through the other instances, and the changes made through the other
+// instances are scheduled when the entries are synced by a ShardSyncer. A
+// count lower than 2 disables the sharding.
+func WithShard(index, count int) Option {
+	return func(c *Crontinuous) {
+		if count < 2 {
+			return
+		}
+		c.shard = &shard{index: index, count: count}
+	}
+}
+
+// owns returns true if the job with the given ID is assigned to the shard.
+func (s *shard) owns(jobID string) bool {
+	if s == nil {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(jobID)) // nolint
+	return int(h.Sum32()%uint32(s.count)) == s.index
+}
+
+func (s *shard) String() string {
+	return fmt.Sprintf("%d/%d", s.index, s.count)
+}
+
+// SyncShard reads the entries from the stores and reschedules the jobs of
+// the ones changed through other instances. The entries pending to be saved
+// are saved before, so their changes are not lost. It does nothing if the
+// instance is not sharded or not started.
+func (c *Crontinuous) SyncShard() error {
+	if c.shard == nil {
+		return nil
+	}
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	if !c.started {
+		return nil
+	}
+	if _, err := c.Flush(); err != nil {
+		return err
+	}
+	for _, typ := range c.cronTypes() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return err
+		}
+		set.lock()
+		err = set.sync()
+		set.unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ShardSyncer periodically syncs the entries of a sharded instance with the
+// stores, so it schedules the entries of its shard created or updated
+// through the other instances.
+type ShardSyncer struct {
+	c        *Crontinuous
+	interval time.Duration
+	log      *logrus.Logger
+}
+
+// NewShardSyncer returns a syncer of the entries of the given crontinuous
+// instance that runs every given interval.
+func NewShardSyncer(c *Crontinuous, interval time.Duration, logger *logrus.Logger) *ShardSyncer {
+	return &ShardSyncer{c: c, interval: interval, log: logger}
+}
+
+// Run syncs the entries on every interval until the context is done.
+func (s *ShardSyncer) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.c.SyncShard(); err != nil {
+			s.log.WithError(err).Error("Error syncing shard entries")
+		}
+	}
+}
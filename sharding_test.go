@@ -0,0 +1,150 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_Shards(t *testing.T) {
+	scans := map[string]ScanEntry{}
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("p%d", i)
+		scans[id] = ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 2 * * *"}
+	}
+
+	const count = 3
+	scheduled := map[string]int{}
+	for index := 0; index < count; index++ {
+		store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+		for id, e := range scans {
+			store.scanEntries[id] = e
+		}
+		c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithShard(index, count))
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+		jobs := c.cron.Jobs()
+		if len(jobs) == 0 || len(jobs) == len(scans) {
+			t.Errorf("shard %d: want a part of the entries scheduled, got %d", index, len(jobs))
+		}
+		for _, j := range jobs {
+			id := j.ID
+			scheduled[id]++
+			if s := c.ScheduleStatus(ScanCronType, scans[id]); !s.Scheduled {
+				t.Errorf("shard %d: want entry %s scheduled, got %+v", index, id, s)
+			}
+		}
+
+		// The new entries are only scheduled by the instance owning them.
+		e := ScanEntry{ProgramID: "new", TeamID: "t1", CronSpec: "0 3 * * *"}
+		if err := c.SaveEntry(ScanCronType, e); err != nil {
+			t.Fatal(err)
+		}
+		status := c.ScheduleStatus(ScanCronType, e)
+		if got := len(c.cron.Jobs()) == len(jobs)+1; got != status.Scheduled {
+			t.Errorf("shard %d: want the new entry scheduled %v, got %v", index, status.Scheduled, got)
+		}
+		if !status.Scheduled && status.UnscheduledReason != UnscheduledOtherShard {
+			t.Errorf("shard %d: unexpected unscheduled reason %q", index, status.UnscheduledReason)
+		}
+		c.Stop() // nolint
+	}
+	for id := range scans {
+		if scheduled[id] != 1 {
+			t.Errorf("want entry %s scheduled by 1 shard, got %d", id, scheduled[id])
+		}
+	}
+}
+
+func TestCrontinuous_SyncShard(t *testing.T) {
+	store := &mockCronStore{scanEntries: map[string]ScanEntry{}, reportEntries: map[string]ReportEntry{}}
+	var instances []*Crontinuous
+	for index := 0; index < 2; index++ {
+		c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithShard(index, 2))
+		if err := c.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer c.Stop() // nolint
+		instances = append(instances, c)
+	}
+	owner, other := instances[0], instances[1]
+	jobs := func(c *Crontinuous) map[string]string {
+		got := map[string]string{}
+		for _, j := range c.cron.Jobs() {
+			got[j.ID] = j.Next.String()
+		}
+		return got
+	}
+
+	// Find an entry owned by the first instance.
+	var id string
+	for i := 0; id == ""; i++ {
+		if owner.shard.owns(cronJobID(ScanCronType, fmt.Sprintf("p%d", i))) {
+			id = fmt.Sprintf("p%d", i)
+		}
+	}
+
+	// The entry created through the other instance is scheduled by the
+	// owner once synced.
+	e := ScanEntry{ProgramID: id, TeamID: "t1", CronSpec: "0 3 * * *"}
+	if err := other.SaveEntry(ScanCronType, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SyncShard(); err != nil {
+		t.Fatal(err)
+	}
+	created := jobs(owner)
+	if _, ok := created[id]; !ok {
+		t.Fatalf("want the entry created through the other instance scheduled, got %v", created)
+	}
+
+	// And rescheduled when updated.
+	e.CronSpec = "0 4 * * *"
+	if err := other.SaveEntry(ScanCronType, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SyncShard(); err != nil {
+		t.Fatal(err)
+	}
+	if updated := jobs(owner); updated[id] == created[id] {
+		t.Errorf("want the entry updated through the other instance rescheduled, got %v", updated)
+	}
+	if got, err := owner.GetEntryByID(ScanCronType, id); err != nil || got.(ScanEntry).CronSpec != e.CronSpec {
+		t.Errorf("want the updated entry served, got %+v, %v", got, err)
+	}
+
+	// A save through the owner keeps the entries saved through the other
+	// instance, even before syncing.
+	if err := other.SaveEntry(ScanCronType, ScanEntry{ProgramID: "q1", TeamID: "t1", CronSpec: "0 5 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SaveEntry(ScanCronType, ScanEntry{ProgramID: "q2", TeamID: "t1", CronSpec: "0 6 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	var stored []string
+	for id := range store.scanEntries {
+		stored = append(stored, id)
+	}
+	sort.Strings(stored)
+	if want := []string{id, "q1", "q2"}; fmt.Sprint(stored) != fmt.Sprint(want) {
+		t.Errorf("want the entries %v stored, got %v", want, stored)
+	}
+
+	// And unscheduled when removed.
+	if err := other.RemoveEntry(ScanCronType, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SyncShard(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := jobs(owner)[id]; ok {
+		t.Errorf("want the entry removed through the other instance unscheduled")
+	}
+}
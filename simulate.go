@@ -0,0 +1,108 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// MaxSimulationWindow is the maximum time window that can be simulated.
+const MaxSimulationWindow = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidTimeWindow indicates the given time window is invalid.
+	ErrInvalidTimeWindow = errors.New("ErrorInvalidTimeWindow")
+)
+
+// PlannedExecution defines an execution of an entry that
+// would happen at a given time.
+type PlannedExecution struct {
+	Type    CronType  `json:"type"`
+	EntryID string    `json:"entry_id"`
+	TeamID  string    `json:"team_id"`
+	Time    time.Time `json:"time"`
+}
+
+// Simulate returns, sorted by time, the executions that would happen in the
+// interval (from, to] considering the teams whitelists, the feature flags,
+// the pause windows, the suppressions and the execution windows of the
+// entries.
+func (c *Crontinuous) Simulate(from, to time.Time) ([]PlannedExecution, error) {
+	return c.simulate(from, to, MaxSimulationWindow, c.GetEntries)
+}
+
+// simulate returns the executions of the entries returned by the given
+// function for each of the cron types, in a window no longer than maxWindow.
+func (c *Crontinuous) simulate(from, to time.Time, maxWindow time.Duration,
+	entriesOf func(CronType) ([]CronEntry, error)) ([]PlannedExecution, error) {
+	if !to.After(from) || to.Sub(from) > maxWindow {
+		return nil, ErrInvalidTimeWindow
+	}
+
+	var executions []PlannedExecution
+	pauses := c.currentPauseWindows()
+	suppressions := c.currentSuppressions()
+	for _, typ := range c.cronTypes() {
+		entries, err := entriesOf(typ)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			teamID := e.GetTeamID()
+			if !c.isTeamWhitelisted(typ, teamID) {
+				continue
+			}
+			if c.flags != nil && !c.flags.Enabled(featureFlag(typ), teamID) {
+				continue
+			}
+			s, err := c.entrySchedule(e)
+			if err != nil {
+				continue
+			}
+			windows, policy := entryWindows(e)
+			w := c.executionWindowsOf(teamID, windows, policy)
+			for _, t := range activations(s, from, to) {
+				if !c.isTeamAllowedAt(typ, teamID, t) {
+					continue
+				}
+				if _, paused := pausingWindow(pauses, typ, teamID, t); paused {
+					continue
+				}
+				if _, suppressed := suppressing(suppressions, typ, e, t); suppressed {
+					continue
+				}
+				at, ok := w.executionTime(t)
+				if !ok || at.After(to) {
+					continue
+				}
+				executions = append(executions, PlannedExecution{
+					Type:    typ,
+					EntryID: e.GetID(),
+					TeamID:  teamID,
+					Time:    at,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(executions, func(i, j int) bool {
+		return executions[i].Time.Before(executions[j].Time)
+	})
+	return executions, nil
+}
+
+// cronTypes returns the types of entries handled by crontinuous.
+func (c *Crontinuous) cronTypes() []CronType {
+	types := []CronType{ScanCronType, ReportCronType}
+	if c.teamScansEnabled() {
+		types = append(types, TeamScanCronType)
+	}
+	if c.commandsEnabled() {
+		types = append(types, CommandCronType)
+	}
+	return types
+}
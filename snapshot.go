@@ -0,0 +1,107 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"sync"
+	"time"
+)
+
+// Snapshot defines the scan and report entries, and the configuration
+// deciding whether they are executed, at one instant.
+type Snapshot struct {
+	// Version grows every time the entries in memory change, so two
+	// snapshots with the same version hold the same entries. It starts
+	// from zero when the instance starts.
+	Version uint64    `json:"version" yaml:"version"`
+	TakenAt time.Time `json:"taken_at" yaml:"taken_at"`
+
+	Scans   []ScanEntry   `json:"scans" yaml:"scans"`
+	Reports []ReportEntry `json:"reports" yaml:"reports"`
+
+	ScanWhitelist   WhitelistSnapshot `json:"scan_whitelist" yaml:"scan_whitelist"`
+	ReportWhitelist WhitelistSnapshot `json:"report_whitelist" yaml:"report_whitelist"`
+
+	// Paused is true if the instance does not execute the activations of
+	// the jobs at the moment, for the reason in PausedReason.
+	Paused       bool   `json:"paused" yaml:"paused"`
+	PausedReason string `json:"paused_reason,omitempty" yaml:"paused_reason,omitempty"`
+}
+
+// WhitelistSnapshot defines the teams whitelist of a cron type.
+type WhitelistSnapshot struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// Rules are the rules of the whitelist as configured, see
+	// WhitelistRule.
+	Rules []string `json:"rules" yaml:"rules"`
+}
+
+// Snapshot returns the scan and report entries together with the whitelists
+// and the paused state of the instance. The entries of both types are read
+// holding their locks at the same time, so no change is applied to one
+// type between reading it and reading the other. Unlike the rest of the
+// reads, it waits for the changes being persisted to finish.
+func (c *Crontinuous) Snapshot() Snapshot {
+	cfg := c.settings()
+	s := Snapshot{
+		Scans:   []ScanEntry{},
+		Reports: []ReportEntry{},
+		ScanWhitelist: WhitelistSnapshot{
+			Enabled: cfg.EnableTeamsWhitelistScan,
+			Rules:   append([]string{}, cfg.TeamsWhitelistScan...),
+		},
+		ReportWhitelist: WhitelistSnapshot{
+			Enabled: cfg.EnableTeamsWhitelistReport,
+			Rules:   append([]string{}, cfg.TeamsWhitelistReport...),
+		},
+	}
+
+	c.scans.lock()
+	c.reports.lock()
+	s.TakenAt = time.Now()
+	s.Version = c.version.get()
+	for _, e := range c.scans.sorted() {
+		s.Scans = append(s.Scans, e.(ScanEntry))
+	}
+	for _, e := range c.reports.sorted() {
+		s.Reports = append(s.Reports, e.(ReportEntry))
+	}
+	c.reports.unlock()
+	c.scans.unlock()
+
+	s.PausedReason = c.instancePausedReason(s.TakenAt)
+	s.Paused = s.PausedReason != ""
+	return s
+}
+
+// instancePausedReason returns why the instance does not execute the
+// activations of the jobs at the given time, or an empty string if it does.
+func (c *Crontinuous) instancePausedReason(t time.Time) string {
+	if c.readOnly {
+		return "read-only replica"
+	}
+	if c.warmUp != nil && c.warmUp.active(t) {
+		return "warming up"
+	}
+	return ""
+}
+
+// snapshotVersion counts the changes of the entries in memory.
+type snapshotVersion struct {
+	mux sync.Mutex
+	n   uint64
+}
+
+func (v *snapshotVersion) inc() {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	v.n++
+}
+
+func (v *snapshotVersion) get() uint64 {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+	return v.n
+}
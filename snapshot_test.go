@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestCrontinuous_Snapshot(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1@sat-sun"}}
+	c := NewCrontinuous(cfg, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	s := c.Snapshot()
+	if len(s.Scans) != 1 || s.Scans[0].ProgramID != "p1" || len(s.Reports) != 1 || s.Reports[0].TeamID != "t1" {
+		t.Errorf("unexpected entries in snapshot %+v", s)
+	}
+	if !s.ScanWhitelist.Enabled || len(s.ScanWhitelist.Rules) != 1 || s.ReportWhitelist.Enabled {
+		t.Errorf("unexpected whitelists in snapshot %+v", s)
+	}
+	if s.Paused {
+		t.Errorf("want the instance not paused, got reason %q", s.PausedReason)
+	}
+	if again := c.Snapshot(); again.Version != s.Version {
+		t.Errorf("want the same version without changes, got %d and %d", s.Version, again.Version)
+	}
+
+	err := c.SaveEntry(ReportCronType, ReportEntry{TeamID: "t2", CronSpec: "0 9 * * 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := c.Snapshot()
+	if changed.Version <= s.Version {
+		t.Errorf("want the version increased after a change, got %d after %d", changed.Version, s.Version)
+	}
+	if len(changed.Reports) != 2 {
+		t.Errorf("want the saved report in the snapshot, got %+v", changed.Reports)
+	}
+}
+
+func TestCrontinuous_SnapshotPaused(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithReadOnlyReplica())
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if s := c.Snapshot(); !s.Paused || s.PausedReason == "" {
+		t.Errorf("want the replica paused, got %+v", s)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"regexp"
+)
+
+// ErrMalformedSpecAlias indicates a spec alias is not valid.
+var ErrMalformedSpecAlias = errors.New("ErrorMalformedSpecAlias")
+
+// specAliasName matches the valid names of the spec aliases.
+var specAliasName = regexp.MustCompile(`^@[a-z0-9][a-z0-9_-]*$`)
+
+// cronDescriptors are the predefined specs of the cron parser, which can not
+// be redefined by an alias.
+var cronDescriptors = map[string]bool{
+	"@yearly":   true,
+	"@annually": true,
+	"@monthly":  true,
+	"@weekly":   true,
+	"@daily":    true,
+	"@midnight": true,
+	"@hourly":   true,
+	"@every":    true,
+}
+
+// ValidateSpecAlias returns ErrMalformedSpecAlias if the given name, for
+// instance @business-nightly, can not be an alias of the given spec. The
+// names must start with @ and not be one of the predefined descriptors, like
+// @daily, and the spec must be a valid cron spec.
+func ValidateSpecAlias(name, spec string) error {
+	if !specAliasName.MatchString(name) || cronDescriptors[name] {
+		return ErrMalformedSpecAlias
+	}
+	if validateCronSpec(spec) != nil {
+		return ErrMalformedSpecAlias
+	}
+	return nil
+}
+
+// WithSpecAliases makes SaveEntry, BulkCreate and BulkReplace accept the
+// names of the given aliases as the cron spec of the entries. The spec of
+// those entries is replaced with the one of the alias, and the alias is kept
+// in their SpecAlias field. The aliases must be valid.
+func WithSpecAliases(aliases map[string]string) Option {
+	return func(c *Crontinuous) {
+		c.specAliases = aliases
+	}
+}
+
+// SpecAliases returns the spec of every alias by name.
+func (c *Crontinuous) SpecAliases() map[string]string {
+	aliases := make(map[string]string, len(c.specAliases))
+	for name, spec := range c.specAliases {
+		aliases[name] = spec
+	}
+	return aliases
+}
+
+// expandSpecAlias returns the given entry with its spec replaced by the one
+// of the alias it is given as, if any. The alias of the entries given with
+// any other spec is kept only if it still expands to that spec, so the
+// entries read from the API can be saved back unchanged.
+func (c *Crontinuous) expandSpecAlias(e CronEntry) CronEntry {
+	if e == nil {
+		return e
+	}
+	if spec, ok := c.specAliases[e.GetCronSpec()]; ok {
+		return withSpecAlias(withCronSpec(e, spec), e.GetCronSpec())
+	}
+	alias := entrySpecAlias(e)
+	if alias == "" {
+		return e
+	}
+	if spec, ok := c.specAliases[alias]; ok && spec == e.GetCronSpec() {
+		return e
+	}
+	return withSpecAlias(e, "")
+}
+
+// entrySpecAlias returns the spec alias of the given entry.
+func entrySpecAlias(e CronEntry) string {
+	return entrySettings(e).SpecAlias
+}
+
+// withSpecAlias returns the given entry with the given spec alias.
+func withSpecAlias(e CronEntry, alias string) CronEntry {
+	s := entrySettings(e)
+	s.SpecAlias = alias
+	return withEntrySettings(e, s)
+}
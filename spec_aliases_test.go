@@ -0,0 +1,102 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestValidateSpecAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		spec    string
+		wantErr error
+	}{
+		{"valid", "@business-nightly", "0 2 * * 1-5", nil},
+		{"hashed", "@nightly", "H H(0-5) * * *", nil},
+		{"descriptor", "@business-daily", "@daily", nil},
+		{"no at", "business-nightly", "0 2 * * 1-5", ErrMalformedSpecAlias},
+		{"predefined", "@daily", "0 2 * * *", ErrMalformedSpecAlias},
+		{"invalid spec", "@nightly", "0 2 * *", ErrMalformedSpecAlias},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSpecAlias(tt.alias, tt.spec); err != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_SpecAliases(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	aliases := map[string]string{
+		"@business-nightly": "0 2 * * 1-5",
+		"@business-daily":   "@daily",
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithSpecAliases(aliases), WithSpecRules(testSpecRules))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	tests := []struct {
+		name      string
+		entry     ScanEntry
+		wantSpec  string
+		wantAlias string
+		wantErr   error
+	}{
+		{"alias", ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "@business-nightly"}, "0 2 * * 1-5", "@business-nightly", nil},
+		{"saved back", ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * 1-5", EntrySettings: EntrySettings{SpecAlias: "@business-nightly"}}, "0 2 * * 1-5", "@business-nightly", nil},
+		{"spec changed", ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 3 * * 1-5", EntrySettings: EntrySettings{SpecAlias: "@business-nightly"}}, "0 3 * * 1-5", "", nil},
+		{"rewritten by rule", ScanEntry{ProgramID: "p2", TeamID: "t2", CronSpec: "@business-daily"}, "H H(0-5) * * *", "", nil},
+		{"undefined", ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "@business-weekly"}, "", "", ErrMalformedSchedule},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.SaveEntry(ScanCronType, tt.entry)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			got := store.scanEntries[tt.entry.ProgramID]
+			if got.CronSpec != tt.wantSpec || got.SpecAlias != tt.wantAlias {
+				t.Errorf("want spec %q and alias %q, got %q and %q", tt.wantSpec, tt.wantAlias, got.CronSpec, got.SpecAlias)
+			}
+		})
+	}
+
+	err := c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "@business-nightly"}}, []bool{true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.scanEntries["p4"]; got.CronSpec != "0 2 * * 1-5" || got.SpecAlias != "@business-nightly" {
+		t.Errorf("want the alias of the bulk created entry expanded, got %+v", got)
+	}
+	_, err = c.BulkReplace(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p5", TeamID: "t1", CronSpec: "@business-nightly"}}, BulkModeReplaceTeam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.scanEntries["p5"]; got.CronSpec != "0 2 * * 1-5" || got.SpecAlias != "@business-nightly" {
+		t.Errorf("want the alias of the replaced entry expanded, got %+v", got)
+	}
+
+	got := c.SpecAliases()
+	got["@other"] = "@hourly"
+	if len(c.SpecAliases()) != len(aliases) {
+		t.Error("want a copy of the aliases")
+	}
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/manelmontilla/cron"
+)
+
+// cronStarBit is the bit set by the cron parser in the fields of the
+// schedules given as *.
+const cronStarBit = 1 << 63
+
+var (
+	// ErrMalformedSpecRule indicates a spec rule is not valid.
+	ErrMalformedSpecRule = errors.New("ErrorMalformedSpecRule")
+
+	// ErrSpecRuleViolation indicates the cron spec of the entry is rejected
+	// by one of the spec rules.
+	ErrSpecRuleViolation = errors.New("ErrorSpecRuleViolation")
+)
+
+// SpecRule defines a rewrite rule applied to the cron specs of the entries
+// when they are saved. A rule applies to the entries of the given types and
+// teams, all of them if empty, whose current spec matches the regular
+// expression in Match, any spec if empty. The spec of the entries it applies
+// to is replaced with Rewrite, if set, and the entries are rejected with
+// ErrSpecRuleViolation if the resulting spec can fire in any of the
+// ForbiddenWeekdays. For instance, the following rule moves the daily
+// entries to a minute, derived from their ID, between midnight and 5:59:
+//
+//	{"name": "staggered-daily", "match": "^@(daily|midnight)$", "rewrite": "H H(0-5) * * *"}
+type SpecRule struct {
+	Name              string         `json:"name" yaml:"name"`
+	Types             []CronType     `json:"types,omitempty" yaml:"types,omitempty"`
+	Teams             []string       `json:"teams,omitempty" yaml:"teams,omitempty"`
+	Match             string         `json:"match,omitempty" yaml:"match,omitempty"`
+	Rewrite           string         `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+	ForbiddenWeekdays []time.Weekday `json:"forbidden_weekdays,omitempty" yaml:"forbidden_weekdays,omitempty"`
+}
+
+// Validate returns ErrMalformedSpecRule if the rule is not valid.
+func (r SpecRule) Validate() error {
+	if r.Name == "" || (r.Rewrite == "" && len(r.ForbiddenWeekdays) == 0) {
+		return ErrMalformedSpecRule
+	}
+	if _, err := regexp.Compile(r.Match); err != nil {
+		return ErrMalformedSpecRule
+	}
+	if r.Rewrite != "" && validateCronSpec(r.Rewrite) != nil {
+		return ErrMalformedSpecRule
+	}
+	for _, d := range r.ForbiddenWeekdays {
+		if d < time.Sunday || d > time.Saturday {
+			return ErrMalformedSpecRule
+		}
+	}
+	return nil
+}
+
+// appliesTo returns true if the rule applies to the entry of the given type
+// and team with the given spec.
+func (r SpecRule) appliesTo(typ CronType, teamID, spec string) bool {
+	if len(r.Types) > 0 {
+		var found bool
+		for _, t := range r.Types {
+			found = found || t == typ
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(r.Teams) > 0 {
+		var found bool
+		for _, t := range r.Teams {
+			found = found || t == teamID
+		}
+		if !found {
+			return false
+		}
+	}
+	// The expression is checked by Validate.
+	matched, err := regexp.MatchString(r.Match, spec)
+	return err == nil && matched
+}
+
+// LoadSpecRules reads the spec rules from the given JSON file, containing an
+// array of rules in the order they are applied.
+func LoadSpecRules(path string) ([]SpecRule, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []SpecRule
+	if err := json.Unmarshal(content, &rules); err != nil {
+		return nil, err
+	}
+	for _, r := range rules {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// WithSpecRules makes SaveEntry, BulkCreate and ReplaceEntries apply the
+// given rules, in order, to the specs of the entries. The rules must be
+// valid.
+func WithSpecRules(rules []SpecRule) Option {
+	return func(c *Crontinuous) {
+		c.specRules = rules
+	}
+}
+
+// SpecRules returns the spec rules applied to the entries.
+func (c *Crontinuous) SpecRules() []SpecRule {
+	rules := make([]SpecRule, len(c.specRules))
+	copy(rules, c.specRules)
+	return rules
+}
+
+// SpecRewrite defines the result of applying the spec rules to an entry.
+type SpecRewrite struct {
+	// CronSpec is the spec of the entry once rewritten.
+	CronSpec string `json:"cron_spec" yaml:"cron_spec"`
+	// Rules are the names of the rules applied to the entry, in order.
+	Rules []string `json:"rules" yaml:"rules"`
+	// RejectedBy is the name of the rule rejecting the entry, if any.
+	RejectedBy string `json:"rejected_by,omitempty" yaml:"rejected_by,omitempty"`
+}
+
+// PreviewSpecRules returns the result of applying the spec rules to the given
+// entry of the given type without saving it. The entries rejected by a rule
+// are not considered an error, but reported in the RejectedBy field.
+func (c *Crontinuous) PreviewSpecRules(typ CronType, entry CronEntry) (SpecRewrite, error) {
+	entry = c.expandSpecAlias(entry)
+	if err := validateEntry(typ, entry); err != nil {
+		return SpecRewrite{}, err
+	}
+	_, rw := c.rewriteSpec(typ, entry)
+	return rw, nil
+}
+
+// applySpecRules returns the given entry with its spec rewritten by the spec
+// rules, or ErrSpecRuleViolation if any of them rejects it.
+func (c *Crontinuous) applySpecRules(typ CronType, entry CronEntry) (CronEntry, error) {
+	if len(c.specRules) == 0 {
+		return entry, nil
+	}
+	rewritten, rw := c.rewriteSpec(typ, entry)
+	if rw.RejectedBy != "" {
+		c.log.WithFields(logrus.Fields{
+			"type":      typ.String(),
+			"entry_id":  entry.GetID(),
+			"cron_spec": rw.CronSpec,
+			"rule":      rw.RejectedBy,
+		}).Info("Entry rejected by spec rule")
+		return nil, ErrSpecRuleViolation
+	}
+	return rewritten, nil
+}
+
+// rewriteSpec applies the spec rules to the given entry, stopping at the
+// first rule rejecting it.
+func (c *Crontinuous) rewriteSpec(typ CronType, entry CronEntry) (CronEntry, SpecRewrite) {
+	rw := SpecRewrite{CronSpec: entry.GetCronSpec(), Rules: []string{}}
+	for _, r := range c.specRules {
+		if !r.appliesTo(typ, entry.GetTeamID(), entry.GetCronSpec()) {
+			continue
+		}
+		rw.Rules = append(rw.Rules, r.Name)
+		if r.Rewrite != "" {
+			// The alias of the entry no longer describes its spec.
+			entry = withSpecAlias(withCronSpec(entry, r.Rewrite), "")
+			rw.CronSpec = r.Rewrite
+		}
+		if firesOnAny(entry, r.ForbiddenWeekdays) {
+			rw.RejectedBy = r.Name
+			break
+		}
+	}
+	return entry, rw
+}
+
+// firesOnAny returns true if the spec of the given entry can fire in any of
+// the given days of the week.
+func firesOnAny(entry CronEntry, days []time.Weekday) bool {
+	if len(days) == 0 {
+		return false
+	}
+	s, err := parseEntrySpec(entry)
+	if err != nil {
+		return true
+	}
+	spec, ok := s.(*cron.SpecSchedule)
+	if !ok {
+		// The specs with a constant delay, e.g. @every 36h, fire every
+		// day of the week sooner or later.
+		return true
+	}
+	for _, d := range days {
+		// If the day of the week is restricted but not the day of the
+		// month, the spec only fires in the given days of the week.
+		// Otherwise, it fires in the given days of the month, and they
+		// fall in every day of the week.
+		domRestricted := spec.Dom&cronStarBit == 0
+		if domRestricted || spec.Dow&(1<<uint(d)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// withCronSpec returns the given entry with the given spec.
+func withCronSpec(e CronEntry, spec string) CronEntry {
+	switch e := e.(type) {
+	case ScanEntry:
+		e.CronSpec = spec
+		return e
+	case ReportEntry:
+		e.CronSpec = spec
+		return e
+	case TeamScanEntry:
+		e.CronSpec = spec
+		return e
+	case CommandEntry:
+		e.CronSpec = spec
+		return e
+	}
+	return e
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var testSpecRules = []SpecRule{
+	{Name: "staggered-daily", Match: "^@(daily|midnight)$", Rewrite: "H H(0-5) * * *"},
+	{Name: "no-weekends", Types: []CronType{ScanCronType}, Teams: []string{"t1"}, ForbiddenWeekdays: []time.Weekday{time.Saturday, time.Sunday}},
+}
+
+func TestCrontinuous_SpecRules(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithSpecRules(testSpecRules))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	tests := []struct {
+		name     string
+		typ      CronType
+		entry    CronEntry
+		wantSpec string
+		wantErr  error
+	}{
+		{"rewritten", ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t2", CronSpec: "@daily"}, "H H(0-5) * * *", nil},
+		{"not matching", ReportCronType, ReportEntry{TeamID: "t1", CronSpec: "0 8 * * *"}, "0 8 * * *", nil},
+		{"weekdays", ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 2 * * 1-5"}, "0 2 * * 1-5", nil},
+		{"weekends", ScanCronType, ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "0 2 * * 0"}, "", ErrSpecRuleViolation},
+		{"rewritten and rejected", ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "@midnight"}, "", ErrSpecRuleViolation},
+		{"days of month", ScanCronType, ScanEntry{ProgramID: "p5", TeamID: "t1", CronSpec: "0 2 1 * 1"}, "", ErrSpecRuleViolation},
+		{"constant delay", ScanCronType, ScanEntry{ProgramID: "p6", TeamID: "t1", CronSpec: "@every 36h"}, "", ErrSpecRuleViolation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.SaveEntry(tt.typ, tt.entry)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			got, err := c.GetEntryByID(tt.typ, tt.entry.GetID())
+			if tt.wantErr != nil {
+				if err != ErrScheduleNotFound {
+					t.Errorf("want the rejected entry not stored, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.GetCronSpec() != tt.wantSpec {
+				t.Errorf("want spec %q, got %q", tt.wantSpec, got.GetCronSpec())
+			}
+		})
+	}
+
+	err := c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p7", TeamID: "t2", CronSpec: "@daily"}}, []bool{true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.scanEntries["p7"].CronSpec; got != "H H(0-5) * * *" {
+		t.Errorf("want the spec of the bulk created entry rewritten, got %q", got)
+	}
+	err = c.BulkCreate(ScanCronType, []CronEntry{ScanEntry{ProgramID: "p8", TeamID: "t1", CronSpec: "0 2 * * *"}}, []bool{true})
+	if err != ErrSpecRuleViolation {
+		t.Errorf("want ErrSpecRuleViolation, got %v", err)
+	}
+}
+
+func TestCrontinuous_PreviewSpecRules(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithSpecRules(testSpecRules))
+
+	got, err := c.PreviewSpecRules(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "@daily"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SpecRewrite{CronSpec: "H H(0-5) * * *", Rules: []string{"staggered-daily", "no-weekends"}, RejectedBy: "no-weekends"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if _, err := c.PreviewSpecRules(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "invalid"}); err != ErrMalformedSchedule {
+		t.Errorf("want ErrMalformedSchedule, got %v", err)
+	}
+	if len(store.scanEntries) != 0 {
+		t.Error("want the entry not stored")
+	}
+}
+
+func TestLoadSpecRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []SpecRule
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			content: `[{"name": "no-weekends", "types": ["scan"], "teams": ["t1"], "forbidden_weekdays": [6, 0]}]`,
+			want:    []SpecRule{{Name: "no-weekends", Types: []CronType{ScanCronType}, Teams: []string{"t1"}, ForbiddenWeekdays: []time.Weekday{time.Saturday, time.Sunday}}},
+		},
+		{name: "without action", content: `[{"name": "noop"}]`, wantErr: true},
+		{name: "invalid match", content: `[{"name": "r", "match": "(", "rewrite": "@daily"}]`, wantErr: true},
+		{name: "invalid rewrite", content: `[{"name": "r", "rewrite": "invalid"}]`, wantErr: true},
+		{name: "invalid weekday", content: `[{"name": "r", "forbidden_weekdays": [7]}]`, wantErr: true},
+		{name: "invalid type", content: `[{"name": "r", "types": ["other"], "rewrite": "@daily"}]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadSpecRules(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNothingStaged indicates there is no manifest in the staging area.
+	ErrNothingStaged = errors.New("ErrorNothingStaged")
+)
+
+// CurrentManifest returns the current scan and report entries, sorted by ID,
+// so they can be modified and staged.
+func (c *Crontinuous) CurrentManifest() (Manifest, error) {
+	var m Manifest
+	scans, err := c.GetEntries(ScanCronType)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for _, e := range scans {
+		m.Scans = append(m.Scans, e.(ScanEntry))
+	}
+	reports, err := c.GetEntries(ReportCronType)
+	if err != nil {
+		return Manifest{}, err
+	}
+	for _, e := range reports {
+		m.Reports = append(m.Reports, e.(ReportEntry))
+	}
+
+	sort.Slice(m.Scans, func(i, j int) bool {
+		return m.Scans[i].GetID() < m.Scans[j].GetID()
+	})
+	sort.Slice(m.Reports, func(i, j int) bool {
+		return m.Reports[i].GetID() < m.Reports[j].GetID()
+	})
+	return m, nil
+}
+
+// Stage validates the given manifest and stores it in the staging area,
+// replacing the manifest staged before. The staged manifest does not affect
+// the current entries until it is swapped in with SwapStaged.
+func (c *Crontinuous) Stage(m Manifest) error {
+	m, err := c.applyManifestTemplates(m)
+	if err != nil {
+		return err
+	}
+	if m, err = c.identifyManifest(m); err != nil {
+		return err
+	}
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	c.stagedMux.Lock()
+	defer c.stagedMux.Unlock()
+
+	c.staged = &m
+	return nil
+}
+
+// Staged returns the manifest in the staging area.
+func (c *Crontinuous) Staged() (Manifest, error) {
+	c.stagedMux.Lock()
+	defer c.stagedMux.Unlock()
+
+	if c.staged == nil {
+		return Manifest{}, ErrNothingStaged
+	}
+	return *c.staged, nil
+}
+
+// DiscardStaged empties the staging area.
+func (c *Crontinuous) DiscardStaged() {
+	c.stagedMux.Lock()
+	defer c.stagedMux.Unlock()
+
+	c.staged = nil
+}
+
+// StagedChanges returns the changes swapping in the staged manifest
+// would perform on the current entries.
+func (c *Crontinuous) StagedChanges() ([]Change, error) {
+	m, err := c.Staged()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for _, typ := range m.Types() {
+		current, err := c.GetEntries(typ)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, Diff(typ, current, m.Entries(typ))...)
+	}
+	return changes, nil
+}
+
+// SimulateStaged returns, like Simulate, the executions that would happen in
+// the interval (from, to] if the staged manifest was swapped in.
+func (c *Crontinuous) SimulateStaged(from, to time.Time) ([]PlannedExecution, error) {
+	m, err := c.Staged()
+	if err != nil {
+		return nil, err
+	}
+
+	staged := map[CronType]bool{}
+	for _, typ := range m.Types() {
+		staged[typ] = true
+	}
+	return c.simulate(from, to, MaxSimulationWindow, func(typ CronType) ([]CronEntry, error) {
+		if staged[typ] {
+			return m.Entries(typ), nil
+		}
+		return c.GetEntries(typ)
+	})
+}
+
+// SwapStaged swaps in the staged manifest, as Swap does, and empties the
+// staging area if it succeeds.
+func (c *Crontinuous) SwapStaged() ([]Change, error) {
+	c.stagedMux.Lock()
+	defer c.stagedMux.Unlock()
+
+	if c.staged == nil {
+		return nil, ErrNothingStaged
+	}
+	changes, err := c.Swap(*c.staged)
+	if err != nil {
+		return nil, err
+	}
+	c.staged = nil
+	return changes, nil
+}
+
+// Swap atomically replaces the entries of the types defined in the manifest
+// with the ones in it and reschedules all the jobs, returning the changes
+// performed. Unlike Apply, no mutation of the entries can happen in the middle
+// of the swap, and if the manifest is invalid or the new entries can not be
+// persisted or scheduled the previous entries are restored.
+func (c *Crontinuous) Swap(m Manifest) ([]Change, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	m, err := c.applyManifestTemplates(m)
+	if err != nil {
+		return nil, err
+	}
+	if m, err = c.identifyManifest(m); err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	changes, err := c.swap(m)
+	if err != nil {
+		return nil, err
+	}
+	c.recordHistory("", changes...)
+	return changes, nil
+}
+
+func (c *Crontinuous) swap(m Manifest) ([]Change, error) {
+
+	c.lifecycleMux.Lock()
+	defer c.lifecycleMux.Unlock()
+
+	var sets []entries
+	for _, typ := range c.cronTypes() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+
+	// Lock all the entries while swapping them
+	// so no mutations happen meanwhile.
+	for _, set := range sets {
+		set.lock()
+	}
+	var old Scheduler
+	defer func() {
+		for i := len(sets) - 1; i >= 0; i-- {
+			sets[i].unlock()
+		}
+		if old != nil {
+			old.Stop()
+		}
+	}()
+
+	var (
+		changes  []Change
+		restores []func() error
+	)
+	rollback := func(err error) ([]Change, error) {
+		for i := len(restores) - 1; i >= 0; i-- {
+			if rerr := restores[i](); rerr != nil {
+				c.log.WithError(rerr).Error("Error restoring entries after a failed swap")
+			}
+		}
+		return nil, err
+	}
+	for _, typ := range m.Types() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return rollback(err)
+		}
+		previous, restore, err := set.replace(m.Entries(typ))
+		if restore != nil {
+			restores = append(restores, restore)
+		}
+		if err != nil {
+			return rollback(fmt.Errorf("swapping %s entries: %w", typ, err))
+		}
+		changes = append(changes, Diff(typ, previous, m.Entries(typ))...)
+	}
+
+	if !c.started {
+		// The jobs are scheduled when started.
+		return changes, nil
+	}
+	var cronSchedules []cronJobSchedule
+	for _, set := range sets {
+		schedules, err := set.jobSchedules()
+		if err != nil {
+			return rollback(fmt.Errorf("scheduling swapped entries: %w", err))
+		}
+		cronSchedules = append(cronSchedules, schedules...)
+	}
+	old = c.replaceCron(cronSchedules)
+
+	c.log.WithField("changes", len(changes)).Info("Swapped entries")
+	return changes, nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+type failingReportCronStore struct {
+	mockCronStore
+}
+
+func (s *failingReportCronStore) SaveReportEntries(entries map[string]ReportEntry) error {
+	return errors.New("store unavailable")
+}
+
+func TestCrontinuous_SwapStaged(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	if _, err := c.SwapStaged(); err != ErrNothingStaged {
+		t.Fatalf("error got %v, want %v", err, ErrNothingStaged)
+	}
+
+	m, err := c.CurrentManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Scans = m.Scans[1:]
+	m.Scans[0].CronSpec = "0 0 2 1 *"
+	m.Reports = append(m.Reports, ReportEntry{TeamID: "t1", CronSpec: "0 0 3 1 *"})
+	if err := c.Stage(m); err != nil {
+		t.Fatalf("Error staging manifest: %v", err)
+	}
+
+	staged, err := c.StagedChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := c.SwapStaged()
+	if err != nil {
+		t.Fatalf("Error swapping staged manifest: %v", err)
+	}
+	if diff := cmp.Diff(staged, changes); diff != "" {
+		t.Errorf("changes performed differ from the staged ones, diff %s", diff)
+	}
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %+v", changes)
+	}
+
+	wantScans := map[string]ScanEntry{"p2": m.Scans[0]}
+	if diff := cmp.Diff(wantScans, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored scan entries got!=want, diff %s", diff)
+	}
+	wantReports := map[string]ReportEntry{"t1": m.Reports[0]}
+	if diff := cmp.Diff(wantReports, store.reportEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored report entries got!=want, diff %s", diff)
+	}
+	if n := len(c.cron.Jobs()); n != 2 {
+		t.Errorf("expected 2 jobs scheduled, got %d", n)
+	}
+	if _, err := c.Staged(); err != ErrNothingStaged {
+		t.Errorf("staging area not emptied after swap")
+	}
+}
+
+func TestCrontinuous_SwapRollback(t *testing.T) {
+	initial := map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+	}
+	store := &failingReportCronStore{
+		mockCronStore: mockCronStore{
+			scanEntries:   map[string]ScanEntry{"p1": initial["p1"]},
+			reportEntries: map[string]ReportEntry{},
+		},
+	}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatalf("Error starting crontinuous: %v", err)
+	}
+	defer c.Stop()
+
+	invalid := Manifest{Scans: []ScanEntry{{ProgramID: "p2", TeamID: "t1", CronSpec: "invalid"}}}
+	if _, err := c.Swap(invalid); !errors.Is(err, ErrMalformedSchedule) {
+		t.Errorf("error got %v, want %v", err, ErrMalformedSchedule)
+	}
+
+	m := Manifest{
+		Scans:   []ScanEntry{{ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 2 1 *"}},
+		Reports: []ReportEntry{{TeamID: "t1", CronSpec: "0 0 3 1 *"}},
+	}
+	if _, err := c.Swap(m); err == nil {
+		t.Fatalf("expected error swapping entries")
+	}
+	if diff := cmp.Diff(initial, store.scanEntries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("stored scan entries not restored, diff %s", diff)
+	}
+	if diff := cmp.Diff(initial, c.scans.entries, ignoreUpdatedAtOption); diff != "" {
+		t.Errorf("scan entries not restored, diff %s", diff)
+	}
+	if n := len(c.cron.Jobs()); n != 1 {
+		t.Errorf("expected the previous job to be kept scheduled, got %d jobs", n)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultStatsdPrefix is the prefix of the metrics
+	// sent to statsd when no prefix is configured.
+	DefaultStatsdPrefix = "crontinuous"
+
+	// DefaultStatsdInterval is the interval between the sends
+	// of the metrics to statsd when no interval is configured.
+	DefaultStatsdInterval = 10 * time.Second
+
+	// statsdMaxPacketSize is the maximum size of the UDP packets sent to
+	// statsd, small enough to not be fragmented in most networks.
+	statsdMaxPacketSize = 1432
+)
+
+// StatsdConfig defines the configuration of the StatsdEmitter.
+type StatsdConfig struct {
+	// Address is the host:port of the statsd or DogStatsD agent.
+	Address string
+	// Prefix is prepended, followed by a dot, to the name of the metrics.
+	Prefix string
+	// Tags are added, in the DogStatsD format "key:value",
+	// to all the metrics.
+	Tags []string
+	// Interval is the time between two sends of the metrics.
+	Interval time.Duration
+}
+
+// StatsdEmitter periodically sends the metrics of a Crontinuous to a statsd
+// agent using the DogStatsD protocol, for the deployments not scraping the
+// prometheus endpoint. Gauges are sent with their current value while
+// counters are sent with their increase since the previous send.
+type StatsdEmitter struct {
+	cfg  StatsdConfig
+	c    *Crontinuous
+	conn net.Conn
+	log  *logrus.Logger
+	// sent holds the last value sent of each counter.
+	sent map[string]float64
+}
+
+// NewStatsdEmitter returns a StatsdEmitter sending the metrics of the given
+// crontinuous instance to the configured address.
+func NewStatsdEmitter(cfg StatsdConfig, c *Crontinuous, logger *logrus.Logger) (*StatsdEmitter, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("statsd address not defined")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultStatsdPrefix
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultStatsdInterval
+	}
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdEmitter{
+		cfg:  cfg,
+		c:    c,
+		conn: conn,
+		log:  logger,
+		sent: map[string]float64{},
+	}, nil
+}
+
+// Run sends the metrics every configured interval
+// until the given context is done.
+func (s *StatsdEmitter) Run(ctx context.Context) {
+	defer s.conn.Close() // nolint
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.emit(); err != nil {
+			s.log.WithError(err).Error("Error sending metrics to statsd")
+		}
+	}
+}
+
+// emit sends the current metrics, packing as many lines as possible in
+// each packet.
+func (s *StatsdEmitter) emit() error {
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, err := s.conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for _, line := range s.lines(s.c.Metrics()) {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > statsdMaxPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return flush()
+}
+
+// lines returns the given metrics in the DogStatsD format.
+func (s *StatsdEmitter) lines(metrics []Metric) []string {
+	var lines []string
+	for _, m := range metrics {
+		tags := append([]string{}, s.cfg.Tags...)
+		for k, v := range m.Labels {
+			tags = append(tags, k+":"+v)
+		}
+		sort.Strings(tags)
+
+		name := s.cfg.Prefix + "." + m.Name
+		value, typ := m.Value, "g"
+		if m.Kind == CounterMetric {
+			key := name + "|" + strings.Join(tags, ",")
+			value, typ = m.Value-s.sent[key], "c"
+			s.sent[key] = m.Value
+			if value <= 0 {
+				continue
+			}
+		}
+
+		line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + typ
+		if len(tags) > 0 {
+			line += "|#" + strings.Join(tags, ",")
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestStatsdEmitter_emit(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	c := NewCrontinuous(Config{}, logrus.New(), nil, nil, nil, nil)
+	c.timeouts.inc(ScanCronType)
+	c.timeouts.inc(ScanCronType)
+	s, err := NewStatsdEmitter(StatsdConfig{
+		Address: listener.LocalAddr().String(),
+		Prefix:  "test",
+		Tags:    []string{"env:dev"},
+	}, c, logrus.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.conn.Close()
+
+	read := func() []string {
+		buf := make([]byte, statsdMaxPacketSize)
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second)) // nolint
+		n, _, err := listener.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(buf[:n]), "\n")
+	}
+	contains := func(lines []string, line string) bool {
+		for _, l := range lines {
+			if l == line {
+				return true
+			}
+		}
+		return false
+	}
+
+	if err := s.emit(); err != nil {
+		t.Fatal(err)
+	}
+	lines := read()
+	for _, want := range []string{
+		"test.entries:0|g|#env:dev,type:scan",
+		"test.job_timeouts_total:2|c|#env:dev,type:scan",
+	} {
+		if !contains(lines, want) {
+			t.Errorf("line %q not sent, got %v", want, lines)
+		}
+	}
+
+	// Counters are sent as increments.
+	c.timeouts.inc(ScanCronType)
+	if err := s.emit(); err != nil {
+		t.Fatal(err)
+	}
+	lines = read()
+	if want := "test.job_timeouts_total:1|c|#env:dev,type:scan"; !contains(lines, want) {
+		t.Errorf("line %q not sent, got %v", want, lines)
+	}
+}
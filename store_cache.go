@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WithStoreCache makes crontinuous keep in the given local directory a copy
+// of the entries last read from or written to the stores. If the entries can
+// not be read from a store on Start or Restart, the copy is loaded instead,
+// as long as it is not older than the given TTL, and the status of the
+// instance is reported as degraded until the store is read again. A TTL
+// lower than or equal to zero makes any copy usable.
+func WithStoreCache(dir string, ttl time.Duration) Option {
+	return func(c *Crontinuous) {
+		c.storeCache = &storeCache{dir: dir, ttl: ttl}
+	}
+}
+
+// storeCache stores a copy of each crontab in a local directory.
+type storeCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// cachedCrontab defines the content of the files of the cache.
+type cachedCrontab struct {
+	CachedAt time.Time       `json:"cached_at"`
+	Entries  json.RawMessage `json:"entries"`
+}
+
+func (s *storeCache) path(typ CronType) string {
+	return filepath.Join(s.dir, typ.String()+".json")
+}
+
+// write replaces the cached copy of the entries of the given type. The copy
+// is written to a temporary file first, so a failed write does not corrupt
+// the previous one.
+func (s *storeCache) write(typ CronType, entries interface{}, now time.Time) error {
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	content, err = json.Marshal(cachedCrontab{CachedAt: now, Entries: content})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(s.dir, typ.String())
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(typ))
+}
+
+// read decodes into entries the cached copy of the entries of the given
+// type and returns the time it was cached. It returns an error if there is no
+// copy or it is older than the TTL.
+func (s *storeCache) read(typ CronType, entries interface{}, now time.Time) (time.Time, error) {
+	content, err := ioutil.ReadFile(s.path(typ))
+	if err != nil {
+		return time.Time{}, err
+	}
+	var cached cachedCrontab
+	if err := json.Unmarshal(content, &cached); err != nil {
+		return time.Time{}, err
+	}
+	if s.ttl > 0 && now.Sub(cached.CachedAt) > s.ttl {
+		return time.Time{}, fmt.Errorf("cached copy expired at %s", cached.CachedAt.Add(s.ttl).Format(time.RFC3339))
+	}
+	return cached.CachedAt, json.Unmarshal(cached.Entries, entries)
+}
+
+// withStoreCache wraps the functions reading and writing the entries of the
+// given type from the store, so the entries are cached on every successful
+// read and write, and the cached copy is returned when the store can not be
+// read.
+func withStoreCache[T CronEntry](c *Crontinuous, typ CronType,
+	load func() (map[string]T, error), store func(map[string]T) error) (func() (map[string]T, error), func(map[string]T) error) {
+
+	if c.storeCache == nil {
+		return load, store
+	}
+	cache := func(entries map[string]T) {
+		if err := c.storeCache.write(typ, entries, time.Now()); err != nil {
+			c.log.Errorf("error caching %s entries: %v", typ, err)
+		}
+	}
+
+	cachedLoad := func() (map[string]T, error) {
+		entries, err := load()
+		if err == nil {
+			cache(entries)
+			c.progress.setDegraded(typ, "")
+			return entries, nil
+		}
+		var cached map[string]T
+		cachedAt, cacheErr := c.storeCache.read(typ, &cached, time.Now())
+		if cacheErr != nil {
+			c.log.Errorf("error reading cached %s entries: %v", typ, cacheErr)
+			return nil, err
+		}
+		c.log.Warnf("error reading %s entries from the store, using the copy cached at %s: %v",
+			typ, cachedAt.Format(time.RFC3339), err)
+		c.progress.setDegraded(typ, fmt.Sprintf("store unreachable, using the entries cached at %s",
+			cachedAt.Format(time.RFC3339)))
+		c.reportError(ErrorReport{
+			Err:  err,
+			Tags: map[string]string{"source": "store", "type": typ.String()},
+		})
+		return cached, nil
+	}
+
+	cachedStore := func(entries map[string]T) error {
+		if err := store(entries); err != nil {
+			return err
+		}
+		cache(entries)
+		return nil
+	}
+	return cachedLoad, cachedStore
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+// unreachableStore fails to read the entries while err is set.
+type unreachableStore struct {
+	*mockCronStore
+	err error
+}
+
+func (s *unreachableStore) GetScanEntries() (map[string]ScanEntry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.mockCronStore.GetScanEntries()
+}
+
+func (s *unreachableStore) GetReportEntries() (map[string]ReportEntry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.mockCronStore.GetReportEntries()
+}
+
+func TestCrontinuous_StoreCache(t *testing.T) {
+	dir := t.TempDir()
+	scans := map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"},
+	}
+	store := &unreachableStore{mockCronStore: &mockCronStore{
+		scanEntries:   scans,
+		reportEntries: map[string]ReportEntry{},
+	}}
+
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithStoreCache(dir, time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	store.err = errors.New("store unreachable")
+	c = NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithStoreCache(dir, time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatalf("starting with the store unreachable: %v", err)
+	}
+	defer c.Stop() // nolint
+
+	got, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(scans["p1"], got); diff != "" {
+		t.Errorf("cached entry diff: %s", diff)
+	}
+	status := c.LoadStatus()
+	if len(status.Degraded) != 2 {
+		t.Errorf("want scan and report entries degraded, got %v", status.Degraded)
+	}
+
+	store.err = nil
+	if err := c.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if status := c.LoadStatus(); status.Degraded != nil {
+		t.Errorf("want no degraded entries after reading the store, got %v", status.Degraded)
+	}
+}
+
+func TestStoreCache_TTL(t *testing.T) {
+	cache := &storeCache{dir: t.TempDir(), ttl: time.Hour}
+	now := time.Now()
+	entries := map[string]ScanEntry{"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *"}}
+	if err := cache.write(ScanCronType, entries, now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]ScanEntry
+	if _, err := cache.read(ScanCronType, &got, now); err == nil {
+		t.Error("expired copy read")
+	}
+
+	if err := cache.write(ScanCronType, entries, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.read(ScanCronType, &got, now); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(entries, got); diff != "" {
+		t.Errorf("cached entries diff: %s", diff)
+	}
+}
@@ -0,0 +1,521 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+// Package storetest implements a conformance test suite for the stores of
+// crontinuous, so every implementation, e.g. S3, file, Postgres or Redis,
+// behaves the way crontinuous expects.
+package storetest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+)
+
+// LargeCrontabSize is the number of entries of the crontabs saved by the
+// large payload tests. It is reduced ten times when testing in short mode.
+var LargeCrontabSize = 20000
+
+// concurrentSaves is the number of saves run concurrently.
+const concurrentSaves = 8
+
+// TestStore runs the suites of all the store interfaces implemented by the
+// stores returned by newStore, and checks the crontabs of the different
+// types of entries are independent. newStore must return an empty store
+// every time it is called.
+func TestStore(t *testing.T, newStore func(t *testing.T) interface{}) {
+	t.Helper()
+	store := newStore(t)
+	if _, ok := store.(crontinuous.ScanCronStore); ok {
+		t.Run("ScanCronStore", func(t *testing.T) {
+			TestScanCronStore(t, func(t *testing.T) crontinuous.ScanCronStore {
+				return newStore(t).(crontinuous.ScanCronStore)
+			})
+		})
+	}
+	if _, ok := store.(crontinuous.ReportCronStore); ok {
+		t.Run("ReportCronStore", func(t *testing.T) {
+			TestReportCronStore(t, func(t *testing.T) crontinuous.ReportCronStore {
+				return newStore(t).(crontinuous.ReportCronStore)
+			})
+		})
+	}
+	if _, ok := store.(crontinuous.TeamScanCronStore); ok {
+		t.Run("TeamScanCronStore", func(t *testing.T) {
+			TestTeamScanCronStore(t, func(t *testing.T) crontinuous.TeamScanCronStore {
+				return newStore(t).(crontinuous.TeamScanCronStore)
+			})
+		})
+	}
+	if _, ok := store.(crontinuous.CommandCronStore); ok {
+		t.Run("CommandCronStore", func(t *testing.T) {
+			TestCommandCronStore(t, func(t *testing.T) crontinuous.CommandCronStore {
+				return newStore(t).(crontinuous.CommandCronStore)
+			})
+		})
+	}
+	if _, ok := store.(crontinuous.HistoryStore); ok {
+		t.Run("HistoryStore", func(t *testing.T) {
+			TestHistoryStore(t, func(t *testing.T) crontinuous.HistoryStore {
+				return newStore(t).(crontinuous.HistoryStore)
+			})
+		})
+	}
+	if _, ok := store.(crontinuous.HistoryLister); ok {
+		t.Run("HistoryLister", func(t *testing.T) {
+			TestHistoryLister(t, func(t *testing.T) crontinuous.HistoryLister {
+				return newStore(t).(crontinuous.HistoryLister)
+			})
+		})
+	}
+	t.Run("IndependentCrontabs", func(t *testing.T) {
+		testIndependentCrontabs(t, newStore(t))
+	})
+}
+
+// TestScanCronStore runs the conformance suite of the ScanCronStore
+// interface. newStore must return an empty store every time it is called.
+func TestScanCronStore(t *testing.T, newStore func(t *testing.T) crontinuous.ScanCronStore) {
+	t.Helper()
+	testCrontab(t, func(t *testing.T) crontab[crontinuous.ScanEntry] {
+		s := newStore(t)
+		return crontab[crontinuous.ScanEntry]{get: s.GetScanEntries, save: s.SaveScanEntries, entry: scanEntry}
+	})
+}
+
+// TestReportCronStore runs the conformance suite of the ReportCronStore
+// interface. newStore must return an empty store every time it is called.
+func TestReportCronStore(t *testing.T, newStore func(t *testing.T) crontinuous.ReportCronStore) {
+	t.Helper()
+	testCrontab(t, func(t *testing.T) crontab[crontinuous.ReportEntry] {
+		s := newStore(t)
+		return crontab[crontinuous.ReportEntry]{get: s.GetReportEntries, save: s.SaveReportEntries, entry: reportEntry}
+	})
+}
+
+// TestTeamScanCronStore runs the conformance suite of the TeamScanCronStore
+// interface. newStore must return an empty store every time it is called.
+func TestTeamScanCronStore(t *testing.T, newStore func(t *testing.T) crontinuous.TeamScanCronStore) {
+	t.Helper()
+	testCrontab(t, func(t *testing.T) crontab[crontinuous.TeamScanEntry] {
+		s := newStore(t)
+		return crontab[crontinuous.TeamScanEntry]{get: s.GetTeamScanEntries, save: s.SaveTeamScanEntries, entry: teamScanEntry}
+	})
+}
+
+// TestCommandCronStore runs the conformance suite of the CommandCronStore
+// interface. newStore must return an empty store every time it is called.
+func TestCommandCronStore(t *testing.T, newStore func(t *testing.T) crontinuous.CommandCronStore) {
+	t.Helper()
+	testCrontab(t, func(t *testing.T) crontab[crontinuous.CommandEntry] {
+		s := newStore(t)
+		return crontab[crontinuous.CommandEntry]{get: s.GetCommandEntries, save: s.SaveCommandEntries, entry: commandEntry}
+	})
+}
+
+// crontab holds the operations of a store on the entries of a type.
+type crontab[T crontinuous.CronEntry] struct {
+	get   func() (map[string]T, error)
+	save  func(map[string]T) error
+	entry func(i int) T
+}
+
+// entries returns n entries generated with the given offset.
+func (c crontab[T]) entries(n, offset int) map[string]T {
+	entries := make(map[string]T, n)
+	for i := 0; i < n; i++ {
+		e := c.entry(i + offset)
+		entries[e.GetID()] = e
+	}
+	return entries
+}
+
+func testCrontab[T crontinuous.CronEntry](t *testing.T, newCrontab func(t *testing.T) crontab[T]) {
+	t.Run("NotFound", func(t *testing.T) {
+		// The crontabs never saved must be read as empty.
+		got, err := newCrontab(t).get()
+		if err != nil {
+			t.Fatalf("getting entries of an empty store: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("entries of an empty store got %d, want none", len(got))
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		c := newCrontab(t)
+		want := c.entries(10, 0)
+		saveAndCheck(t, c, want)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		// Saving replaces all the entries, so the entries
+		// missing from the saved crontab are removed.
+		c := newCrontab(t)
+		saveAndCheck(t, c, c.entries(10, 0))
+		saveAndCheck(t, c, c.entries(5, 7))
+		saveAndCheck(t, c, map[string]T{})
+	})
+
+	t.Run("SaveDoesNotRetain", func(t *testing.T) {
+		// crontinuous keeps modifying the map it saves,
+		// so the store must not keep a reference to it.
+		c := newCrontab(t)
+		entries := c.entries(3, 0)
+		if err := c.save(entries); err != nil {
+			t.Fatalf("saving entries: %v", err)
+		}
+		want := c.entries(3, 0)
+		extra := c.entry(100)
+		entries[extra.GetID()] = extra
+		checkEntries(t, c, want)
+	})
+
+	t.Run("ConcurrentSave", func(t *testing.T) {
+		// The concurrent saves must leave one of the
+		// saved crontabs, not a mix of them.
+		c := newCrontab(t)
+		versions := make([]map[string]T, concurrentSaves)
+		for i := range versions {
+			versions[i] = c.entries(20, i*10)
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, len(versions))
+		for i := range versions {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = c.save(versions[i])
+			}(i)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("concurrent save %d: %v", i, err)
+			}
+		}
+		got, err := c.get()
+		if err != nil {
+			t.Fatalf("getting entries: %v", err)
+		}
+		match := false
+		for _, v := range versions {
+			if cmp.Equal(v, got) {
+				match = true
+				break
+			}
+		}
+		if !match {
+			t.Fatalf("entries after concurrent saves are not any of the saved crontabs, got %d entries", len(got))
+		}
+
+		// The store must keep working after the concurrent saves.
+		saveAndCheck(t, c, versions[0])
+		saveAndCheck(t, c, versions[len(versions)-1])
+	})
+
+	t.Run("LargePayload", func(t *testing.T) {
+		n := LargeCrontabSize
+		if testing.Short() {
+			n /= 10
+		}
+		c := newCrontab(t)
+		saveAndCheck(t, c, c.entries(n, 0))
+		// Change a single entry of the large crontab.
+		changed := c.entries(n, 0)
+		e := c.entry(n)
+		changed[e.GetID()] = e
+		saveAndCheck(t, c, changed)
+	})
+}
+
+func saveAndCheck[T crontinuous.CronEntry](t *testing.T, c crontab[T], entries map[string]T) {
+	t.Helper()
+	if err := c.save(entries); err != nil {
+		t.Fatalf("saving entries: %v", err)
+	}
+	checkEntries(t, c, entries)
+}
+
+func checkEntries[T crontinuous.CronEntry](t *testing.T, c crontab[T], want map[string]T) {
+	t.Helper()
+	got, err := c.get()
+	if err != nil {
+		t.Fatalf("getting entries: %v", err)
+	}
+	if len(want) == 0 && len(got) == 0 {
+		return
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("entries read differ from the saved ones, diff: %s", diff)
+	}
+}
+
+// TestHistoryStore runs the conformance suite of the HistoryStore
+// interface. newStore must return an empty store every time it is called.
+func TestHistoryStore(t *testing.T, newStore func(t *testing.T) crontinuous.HistoryStore) {
+	t.Helper()
+
+	t.Run("NotFound", func(t *testing.T) {
+		got, err := newStore(t).GetHistory(crontinuous.ScanCronType, "p1")
+		if err != nil {
+			t.Fatalf("getting history of an empty store: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("revisions of an empty store got %d, want none", len(got))
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		want := revisions(scanEntry(1), 5)
+		if err := s.SaveHistory(crontinuous.ScanCronType, "p1", want); err != nil {
+			t.Fatalf("saving history: %v", err)
+		}
+		checkHistory(t, s, crontinuous.ScanCronType, "p1", want)
+
+		// Saving replaces the revisions.
+		want = want[2:]
+		if err := s.SaveHistory(crontinuous.ScanCronType, "p1", want); err != nil {
+			t.Fatalf("saving history: %v", err)
+		}
+		checkHistory(t, s, crontinuous.ScanCronType, "p1", want)
+	})
+
+	t.Run("IndependentEntries", func(t *testing.T) {
+		// The histories of the entries with the same ID and different
+		// type, and of different entries, are independent.
+		s := newStore(t)
+		scan := revisions(scanEntry(1), 3)
+		report := revisions(reportEntry(1), 2)
+		if err := s.SaveHistory(crontinuous.ScanCronType, "id", scan); err != nil {
+			t.Fatalf("saving history: %v", err)
+		}
+		if err := s.SaveHistory(crontinuous.ReportCronType, "id", report); err != nil {
+			t.Fatalf("saving history: %v", err)
+		}
+		checkHistory(t, s, crontinuous.ScanCronType, "id", scan)
+		checkHistory(t, s, crontinuous.ReportCronType, "id", report)
+		checkHistory(t, s, crontinuous.ScanCronType, "other", nil)
+	})
+
+	t.Run("ConcurrentSave", func(t *testing.T) {
+		s := newStore(t)
+		var wg sync.WaitGroup
+		errs := make([]error, concurrentSaves)
+		for i := 0; i < concurrentSaves; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = s.SaveHistory(crontinuous.ScanCronType, fmt.Sprintf("p%d", i), revisions(scanEntry(i), i+1))
+			}(i)
+		}
+		wg.Wait()
+		for i := 0; i < concurrentSaves; i++ {
+			if errs[i] != nil {
+				t.Fatalf("concurrent save %d: %v", i, errs[i])
+			}
+			checkHistory(t, s, crontinuous.ScanCronType, fmt.Sprintf("p%d", i), revisions(scanEntry(i), i+1))
+		}
+	})
+}
+
+// TestHistoryLister runs the conformance suite of the HistoryLister
+// interface. newStore must return an empty store, also implementing
+// HistoryStore, every time it is called.
+func TestHistoryLister(t *testing.T, newStore func(t *testing.T) crontinuous.HistoryLister) {
+	t.Helper()
+
+	l := newStore(t)
+	s, ok := l.(crontinuous.HistoryStore)
+	if !ok {
+		t.Fatalf("store does not implement HistoryStore")
+	}
+	refs, err := l.ListHistories()
+	if err != nil {
+		t.Fatalf("listing histories of an empty store: %v", err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("histories of an empty store got %v, want none", refs)
+	}
+
+	want := []crontinuous.HistoryRef{
+		{Type: crontinuous.ScanCronType, ID: "id"},
+		{Type: crontinuous.ReportCronType, ID: "id"},
+		{Type: crontinuous.ScanCronType, ID: "other"},
+	}
+	for _, ref := range want {
+		if err := s.SaveHistory(ref.Type, ref.ID, revisions(scanEntry(1), 2)); err != nil {
+			t.Fatalf("saving history: %v", err)
+		}
+	}
+	checkHistories(t, l, want)
+
+	if err := l.DeleteHistory(crontinuous.ScanCronType, "id"); err != nil {
+		t.Fatalf("deleting history: %v", err)
+	}
+	checkHistories(t, l, want[1:])
+	checkHistory(t, s, crontinuous.ScanCronType, "id", nil)
+}
+
+func checkHistories(t *testing.T, l crontinuous.HistoryLister, want []crontinuous.HistoryRef) {
+	t.Helper()
+	got, err := l.ListHistories()
+	if err != nil {
+		t.Fatalf("listing histories: %v", err)
+	}
+	less := func(a, b crontinuous.HistoryRef) bool {
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.ID < b.ID
+	}
+	if diff := cmp.Diff(want, got, cmpopts.SortSlices(less)); diff != "" {
+		t.Fatalf("histories listed differ from the saved ones, diff: %s", diff)
+	}
+}
+
+func checkHistory(t *testing.T, s crontinuous.HistoryStore, typ crontinuous.CronType, ID string, want []crontinuous.Revision) {
+	t.Helper()
+	got, err := s.GetHistory(typ, ID)
+	if err != nil {
+		t.Fatalf("getting history: %v", err)
+	}
+	if len(want) == 0 && len(got) == 0 {
+		return
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("revisions read differ from the saved ones, diff: %s", diff)
+	}
+}
+
+// revisions returns n revisions of the given entry, the last one removing it.
+func revisions(e crontinuous.CronEntry, n int) []crontinuous.Revision {
+	content, _ := json.Marshal(e) // nolint
+	changedAt := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	revs := make([]crontinuous.Revision, n)
+	for i := range revs {
+		revs[i] = crontinuous.Revision{
+			Revision:  i + 1,
+			Entry:     content,
+			ChangedBy: "storetest",
+			ChangedAt: changedAt.Add(time.Duration(i) * time.Hour),
+		}
+	}
+	revs[n-1].Entry = json.RawMessage("null")
+	return revs
+}
+
+func testIndependentCrontabs(t *testing.T, store interface{}) {
+	scans, okScans := store.(crontinuous.ScanCronStore)
+	reports, okReports := store.(crontinuous.ReportCronStore)
+	teamScans, okTeamScans := store.(crontinuous.TeamScanCronStore)
+	commands, okCommands := store.(crontinuous.CommandCronStore)
+
+	var wg sync.WaitGroup
+	var errs [4]error
+	wantScans := crontab[crontinuous.ScanEntry]{entry: scanEntry}.entries(10, 0)
+	wantReports := crontab[crontinuous.ReportEntry]{entry: reportEntry}.entries(10, 0)
+	wantTeamScans := crontab[crontinuous.TeamScanEntry]{entry: teamScanEntry}.entries(10, 0)
+	wantCommands := crontab[crontinuous.CommandEntry]{entry: commandEntry}.entries(10, 0)
+	if okScans {
+		wg.Add(1)
+		go func() { defer wg.Done(); errs[0] = scans.SaveScanEntries(wantScans) }()
+	}
+	if okReports {
+		wg.Add(1)
+		go func() { defer wg.Done(); errs[1] = reports.SaveReportEntries(wantReports) }()
+	}
+	if okTeamScans {
+		wg.Add(1)
+		go func() { defer wg.Done(); errs[2] = teamScans.SaveTeamScanEntries(wantTeamScans) }()
+	}
+	if okCommands {
+		wg.Add(1)
+		go func() { defer wg.Done(); errs[3] = commands.SaveCommandEntries(wantCommands) }()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("saving entries: %v", err)
+		}
+	}
+
+	if okScans {
+		checkEntries(t, crontab[crontinuous.ScanEntry]{get: scans.GetScanEntries}, wantScans)
+	}
+	if okReports {
+		checkEntries(t, crontab[crontinuous.ReportEntry]{get: reports.GetReportEntries}, wantReports)
+	}
+	if okTeamScans {
+		checkEntries(t, crontab[crontinuous.TeamScanEntry]{get: teamScans.GetTeamScanEntries}, wantTeamScans)
+	}
+	if okCommands {
+		checkEntries(t, crontab[crontinuous.CommandEntry]{get: commands.GetCommandEntries}, wantCommands)
+	}
+}
+
+// scanEntry returns the i-th scan entry generated by the suite. Some of
+// the entries set all the optional fields.
+func scanEntry(i int) crontinuous.ScanEntry {
+	e := crontinuous.ScanEntry{
+		ProgramID: fmt.Sprintf("program-%06d", i),
+		TeamID:    fmt.Sprintf("team-%03d", i%100),
+		CronSpec:  fmt.Sprintf("%d %d * * *", i%60, i%24),
+	}
+	if i%3 == 0 {
+		e.ExecutionTimeout = crontinuous.Duration(time.Duration(i%10+1) * time.Minute)
+		e.PingURL = fmt.Sprintf("https://hc.example.com/ping/%d", i)
+		e.Alerting = &crontinuous.AlertSettings{FailureThreshold: i%5 + 1, Channel: "team-ñ"}
+	}
+	return e
+}
+
+func reportEntry(i int) crontinuous.ReportEntry {
+	e := crontinuous.ReportEntry{
+		TeamID:   fmt.Sprintf("team-%06d", i),
+		CronSpec: fmt.Sprintf("%d 8 * * %d", i%60, i%7),
+	}
+	if i%3 == 0 {
+		e.ExecutionTimeout = crontinuous.Duration(time.Duration(i%10+1) * time.Minute)
+		e.PingURL = fmt.Sprintf("https://hc.example.com/ping/%d", i)
+		e.Alerting = &crontinuous.AlertSettings{FailureThreshold: i%5 + 1}
+	}
+	return e
+}
+
+func teamScanEntry(i int) crontinuous.TeamScanEntry {
+	e := crontinuous.TeamScanEntry{
+		TeamID:   fmt.Sprintf("team-%06d", i),
+		CronSpec: fmt.Sprintf("%d 2 * * %d", i%60, i%7),
+	}
+	if i%3 == 0 {
+		e.ExecutionTimeout = crontinuous.Duration(time.Duration(i%10+1) * time.Minute)
+		e.PingURL = fmt.Sprintf("https://hc.example.com/ping/%d", i)
+		e.Alerting = &crontinuous.AlertSettings{Channel: "team"}
+	}
+	return e
+}
+
+func commandEntry(i int) crontinuous.CommandEntry {
+	e := crontinuous.CommandEntry{
+		ID:       fmt.Sprintf("command-%06d", i),
+		Script:   fmt.Sprintf("maintenance/task-%d.sh", i%10),
+		CronSpec: fmt.Sprintf("%d 4 * * *", i%60),
+	}
+	if i%3 == 0 {
+		e.Args = []string{"--index", fmt.Sprint(i)}
+		e.TeamID = fmt.Sprintf("team-%03d", i%100)
+		e.ExecutionTimeout = crontinuous.Duration(time.Duration(i%10+1) * time.Minute)
+		e.Alerting = &crontinuous.AlertSettings{Channel: "ops"}
+	}
+	return e
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package storetest_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	crontinuous "github.com/adevinta/vulcan-crontinuous"
+	"github.com/adevinta/vulcan-crontinuous/storetest"
+)
+
+// memS3 implements the S3 operations used by the
+// S3CronStore keeping the objects in memory.
+type memS3 struct {
+	s3iface.S3API
+	mux     sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	content, ok := m.objects[aws.StringValue(in.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "not found", nil)
+	}
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(content))}, nil
+}
+
+func (m *memS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	content, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.objects[aws.StringValue(in.Key)] = content
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *memS3) ListObjectsV2Pages(in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	m.mux.Lock()
+	out := &s3.ListObjectsV2Output{}
+	for k := range m.objects {
+		if strings.HasPrefix(k, aws.StringValue(in.Prefix)) {
+			out.Contents = append(out.Contents, &s3.Object{Key: aws.String(k)})
+		}
+	}
+	m.mux.Unlock()
+
+	fn(out, true)
+	return nil
+}
+
+func (m *memS3) DeleteObject(in *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delete(m.objects, aws.StringValue(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// TestFaultyStore checks the FaultyStore does not change the
+// behaviour of the decorated store when it injects no failures.
+func TestFaultyStore(t *testing.T) {
+	storetest.TestStore(t, func(t *testing.T) interface{} {
+		s3Store := crontinuous.NewS3CronStore("bucket",
+			crontinuous.S3ScansCrontabFilename, crontinuous.S3ReportsCrontabFilename,
+			&memS3{objects: map[string][]byte{}})
+		return crontinuous.NewFaultyStore(s3Store, crontinuous.Faults{})
+	})
+}
+
+func TestS3CronStore(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []crontinuous.S3StoreOption
+	}{
+		{name: "Plain"},
+		{name: "Sharded", opts: []crontinuous.S3StoreOption{crontinuous.WithS3Shards(8)}},
+		{name: "Compressed", opts: []crontinuous.S3StoreOption{crontinuous.WithS3Compression()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storetest.TestStore(t, func(t *testing.T) interface{} {
+				return crontinuous.NewS3CronStore("bucket",
+					crontinuous.S3ScansCrontabFilename, crontinuous.S3ReportsCrontabFilename,
+					&memS3{objects: map[string][]byte{}}, tt.opts...)
+			})
+		})
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// summaryNextRuns is the maximum number of next executions of every entry
+// included in the summary of a team.
+const summaryNextRuns = 3
+
+// ExecutionResult defines the result of an execution of an entry.
+type ExecutionResult struct {
+	// Execution is the ID of the execution, as logged.
+	Execution string    `json:"execution" yaml:"execution"`
+	StartedAt time.Time `json:"started_at" yaml:"started_at"`
+	Duration  Duration  `json:"duration" yaml:"duration"`
+	Succeeded bool      `json:"succeeded" yaml:"succeeded"`
+	Error     string    `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// resultKey identifies the entry an execution result belongs to.
+type resultKey struct {
+	typ CronType
+	id  string
+}
+
+// lastResults holds the result of the last execution of every entry
+// executed since the start.
+type lastResults struct {
+	mux     sync.Mutex
+	results map[resultKey]ExecutionResult
+}
+
+func (lr *lastResults) record(typ CronType, entryID string, r ExecutionResult) {
+	lr.mux.Lock()
+	defer lr.mux.Unlock()
+
+	if lr.results == nil {
+		lr.results = map[resultKey]ExecutionResult{}
+	}
+	lr.results[resultKey{typ: typ, id: entryID}] = r
+}
+
+func (lr *lastResults) get(typ CronType, entryID string) (ExecutionResult, bool) {
+	lr.mux.Lock()
+	defer lr.mux.Unlock()
+
+	r, ok := lr.results[resultKey{typ: typ, id: entryID}]
+	return r, ok
+}
+
+// forget discards the result of the given entry.
+func (lr *lastResults) forget(typ CronType, entryID string) {
+	lr.mux.Lock()
+	defer lr.mux.Unlock()
+
+	delete(lr.results, resultKey{typ: typ, id: entryID})
+}
+
+// resultJob records the result of the executions of an entry. The
+// executions skipped by design are not recorded.
+type resultJob struct {
+	entryJob
+	results *lastResults
+}
+
+func (j *resultJob) run(ctx context.Context) error {
+	r := ExecutionResult{StartedAt: time.Now()}
+	err := j.entryJob.run(ctx)
+	if errors.Is(err, errJobSkipped) {
+		return err
+	}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		r.Execution = e.ID
+	}
+	r.Duration = Duration(time.Since(r.StartedAt))
+	r.Succeeded = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	j.results.record(j.cronType(), j.entryID(), r)
+	return err
+}
+
+// EntrySummary defines the state of an entry in the summary of its team.
+type EntrySummary struct {
+	Type           CronType  `json:"type" yaml:"type"`
+	Entry          CronEntry `json:"entry" yaml:"entry"`
+	ScheduleStatus `yaml:",inline"`
+	// Paused is true if the entry is scheduled but its executions are
+	// skipped at the moment, for the reason in PausedReason.
+	Paused       bool   `json:"paused" yaml:"paused"`
+	PausedReason string `json:"paused_reason,omitempty" yaml:"paused_reason,omitempty"`
+	// Quarantined is true if the entry is scheduled but its executions are
+	// skipped because the circuit of its team is open.
+	Quarantined bool `json:"quarantined" yaml:"quarantined"`
+	// NextRuns are the next executions of the entry in the next week,
+	// up to 3, considering the whitelists and the feature flags.
+	NextRuns []time.Time `json:"next_runs" yaml:"next_runs"`
+	// LastResult is the result of the last execution of the entry since
+	// the start, nil if it was not executed.
+	LastResult *ExecutionResult `json:"last_result,omitempty" yaml:"last_result,omitempty"`
+}
+
+// TeamSummary defines the entries of a team and their state.
+type TeamSummary struct {
+	TeamID    string         `json:"team_id" yaml:"team_id"`
+	Scans     []EntrySummary `json:"scans" yaml:"scans"`
+	Reports   []EntrySummary `json:"reports" yaml:"reports"`
+	TeamScans []EntrySummary `json:"team_scans,omitempty" yaml:"team_scans,omitempty"`
+	Commands  []EntrySummary `json:"commands,omitempty" yaml:"commands,omitempty"`
+	// Quarantined is true while the requests for the team to vulcan-api are
+	// suspended because they are failing, see WithTeamCircuit, so the
+	// executions of its entries, but the command ones, are skipped until
+	// CircuitOpenUntil.
+	Quarantined      bool       `json:"quarantined" yaml:"quarantined"`
+	CircuitOpenUntil *time.Time `json:"circuit_open_until,omitempty" yaml:"circuit_open_until,omitempty"`
+	// Budget is the runtime consumed today by the jobs of the team, see
+	// WithTeamBudgets.
+	Budget BudgetUsage `json:"budget" yaml:"budget"`
+}
+
+// TeamSummary returns the entries of all the types of the given team, with
+// their schedule status, their next executions and the result of their last
+// execution, together with the state of the circuit of the team. A team
+// without entries has an empty summary.
+func (c *Crontinuous) TeamSummary(teamID string) (TeamSummary, error) {
+	entriesOf := func(typ CronType) ([]CronEntry, error) {
+		all, err := c.GetEntries(typ)
+		if err != nil {
+			return nil, err
+		}
+		var entries []CronEntry
+		for _, e := range all {
+			if e.GetTeamID() == teamID {
+				entries = append(entries, e)
+			}
+		}
+		return entries, nil
+	}
+
+	now := time.Now()
+	executions, err := c.simulate(now, now.Add(MaxSimulationWindow), MaxSimulationWindow, entriesOf)
+	if err != nil {
+		return TeamSummary{}, err
+	}
+	nextRuns := map[resultKey][]time.Time{}
+	for _, e := range executions {
+		key := resultKey{typ: e.Type, id: e.EntryID}
+		if len(nextRuns[key]) < summaryNextRuns {
+			nextRuns[key] = append(nextRuns[key], e.Time)
+		}
+	}
+
+	s := TeamSummary{
+		TeamID:  teamID,
+		Scans:   []EntrySummary{},
+		Reports: []EntrySummary{},
+		Budget:  c.budgets.usage(teamID, now),
+	}
+	if c.circuits != nil {
+		if until, ok := c.circuits.openUntil(teamID); ok {
+			s.Quarantined, s.CircuitOpenUntil = true, &until
+		}
+	}
+	for _, typ := range c.cronTypes() {
+		entries, err := entriesOf(typ)
+		if err != nil {
+			return TeamSummary{}, err
+		}
+		summaries := []EntrySummary{}
+		for _, e := range entries {
+			es := EntrySummary{
+				Type:           typ,
+				Entry:          e,
+				ScheduleStatus: c.ScheduleStatus(typ, e),
+				NextRuns:       nextRuns[resultKey{typ: typ, id: e.GetID()}],
+			}
+			if es.NextRuns == nil {
+				es.NextRuns = []time.Time{}
+			}
+			if es.Scheduled {
+				es.PausedReason = c.pausedReason(typ, teamID, now, s.Budget.Exhausted)
+				es.Paused = es.PausedReason != ""
+				_, es.Quarantined = c.quarantinedUntil(typ, teamID)
+			}
+			if r, ok := c.results.get(typ, e.GetID()); ok {
+				es.LastResult = &r
+			}
+			summaries = append(summaries, es)
+		}
+		switch typ {
+		case ScanCronType:
+			s.Scans = summaries
+		case ReportCronType:
+			s.Reports = summaries
+		case TeamScanCronType:
+			s.TeamScans = summaries
+		case CommandCronType:
+			s.Commands = summaries
+		}
+	}
+	return s, nil
+}
+
+// pausedReason returns why the executions of the scheduled entries of the
+// given type and team are skipped at the given time, or an empty string if
+// they are not. The quarantine of the entries is reported apart, see
+// quarantinedUntil.
+func (c *Crontinuous) pausedReason(typ CronType, teamID string, t time.Time, budgetExhausted bool) string {
+	if !c.isTeamAllowedAt(typ, teamID, t) {
+		return "outside the whitelist window of the team"
+	}
+	if c.flags != nil && !c.flags.Enabled(featureFlag(typ), teamID) {
+		return fmt.Sprintf("feature flag %s disabled for the team", featureFlag(typ))
+	}
+	if w, ok := c.activePauseWindow(typ, teamID, t); ok {
+		return fmt.Sprintf("pause window %s", w.Name)
+	}
+	if budgetExhausted && typ != CommandCronType {
+		return "daily budget of the team exhausted"
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// waitLastResult waits until the entry of the given type and ID has the
+// result of an execution and returns it.
+func waitLastResult(t *testing.T, c *Crontinuous, typ CronType, ID string) ExecutionResult {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if r, ok := c.results.get(typ, ID); ok {
+			return r
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no result of %s %s", typ, ID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCrontinuous_TeamSummary(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t1", CronSpec: "0 3 * * *"},
+			"p3": {ProgramID: "p3", TeamID: "t2", CronSpec: "0 3 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * *"},
+		},
+	}
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		if programID == "p2" {
+			return errors.New("scan failed")
+		}
+		return nil
+	}}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1"}}
+	c := NewCrontinuous(cfg, logrus.New(), creator, store, nil, store, WithTeamCircuit(1, time.Hour))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	for _, id := range []string{"p1", "p2"} {
+		if err := c.RunEntry(ScanCronType, id); err != nil {
+			t.Fatal(err)
+		}
+		waitLastResult(t, c, ScanCronType, id)
+	}
+
+	s, err := c.TeamSummary("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Scans) != 2 || len(s.Reports) != 1 || s.Quarantined || s.CircuitOpenUntil != nil {
+		t.Fatalf("unexpected summary %+v", s)
+	}
+	p1, p2 := s.Scans[0], s.Scans[1]
+	if p1.Entry.GetID() != "p1" || !p1.Scheduled || p1.Paused {
+		t.Errorf("want p1 scheduled, got %+v", p1)
+	}
+	if p1.LastResult == nil || !p1.LastResult.Succeeded || p1.LastResult.Execution == "" {
+		t.Errorf("want the successful execution of p1, got %+v", p1.LastResult)
+	}
+	if p2.LastResult == nil || p2.LastResult.Succeeded || p2.LastResult.Error != "scan failed" {
+		t.Errorf("want the failed execution of p2, got %+v", p2.LastResult)
+	}
+	if len(p1.NextRuns) != summaryNextRuns || p1.NextRuns[0].Hour() != 2 {
+		t.Errorf("want the next %d runs of p1, got %v", summaryNextRuns, p1.NextRuns)
+	}
+	if r := s.Reports[0]; r.LastResult != nil || len(r.NextRuns) != summaryNextRuns {
+		t.Errorf("want the next runs of the report and no result, got %+v", r)
+	}
+
+	// The entries of teams not whitelisted are not scheduled.
+	s, err = c.TeamSummary("t2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Scans) != 1 || s.Scans[0].Scheduled || len(s.Scans[0].NextRuns) != 0 || len(s.Reports) != 0 {
+		t.Errorf("want the entry of t2 not scheduled, got %+v", s)
+	}
+
+	// The entries of a team with the circuit open are quarantined.
+	if _, err := c.circuits.enter("t1"); err != nil {
+		t.Fatal(err)
+	}
+	c.circuits.failed("t1")
+	s, err = c.TeamSummary("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Quarantined || s.CircuitOpenUntil == nil || !s.Scans[0].Quarantined || s.Scans[0].Paused {
+		t.Errorf("want the entries of t1 quarantined, got %+v", s)
+	}
+
+	// The results of the removed entries are discarded.
+	if err := c.RemoveEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.results.get(ScanCronType, "p1"); ok {
+		t.Error("want the result of the removed entry discarded")
+	}
+
+	s, err = c.TeamSummary("unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Scans) != 0 || len(s.Reports) != 0 || s.Scans == nil {
+		t.Errorf("want an empty summary, got %+v", s)
+	}
+}
@@ -0,0 +1,314 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// S3SuppressionsFilename is the key of the object storing the suppressions.
+const S3SuppressionsFilename = "suppressions.json"
+
+// maxSuppressionTTL is the longest time a suppression can last. The longer
+// silences must be configured as pause windows.
+const maxSuppressionTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrSuppressionsDisabled indicates no suppression store is configured.
+	ErrSuppressionsDisabled = errors.New("ErrorSuppressionsDisabled")
+	// ErrSuppressionNotFound indicates the suppression does not exist or it
+	// already expired.
+	ErrSuppressionNotFound = errors.New("ErrorSuppressionNotFound")
+	// ErrMalformedSuppression indicates the suppression is not valid.
+	ErrMalformedSuppression = errors.New("ErrorMalformedSuppression")
+)
+
+// Suppression defines a temporary silence of the jobs of the entries
+// matching its selector, so the entries of a team under an incident are not
+// executed without editing them. The selector is formed by the teams, the
+// labels, that is the metadata the entries must have, and the types of the
+// entries, all of them if empty, and it must have teams or labels. For
+// instance, the following suppression silences the scans of a team for two
+// hours:
+//
+//	{"teams": ["461a62aa-6e1c-11e8-802e-4c32758b498f"], "types": ["scan"], "ttl": "2h", "reason": "INC-1234"}
+type Suppression struct {
+	// ID is assigned by crontinuous when the suppression is created.
+	ID     string            `json:"id" yaml:"id"`
+	Teams  []string          `json:"teams,omitempty" yaml:"teams,omitempty"`
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Types  []CronType        `json:"types,omitempty" yaml:"types,omitempty"`
+	// TTL is the time the suppression lasts since it is created.
+	TTL Duration `json:"ttl" yaml:"ttl"`
+	// Reason is recorded in the executions skipped by the suppression.
+	Reason    string    `json:"reason" yaml:"reason"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// Validate returns ErrMalformedSuppression if the suppression is not valid.
+func (s Suppression) Validate() error {
+	if len(s.Teams) == 0 && len(s.Labels) == 0 {
+		return ErrMalformedSuppression
+	}
+	if s.TTL <= 0 || time.Duration(s.TTL) > maxSuppressionTTL {
+		return ErrMalformedSuppression
+	}
+	if strings.TrimSpace(s.Reason) == "" {
+		return ErrMalformedSuppression
+	}
+	for _, typ := range s.Types {
+		if _, ok := cronTypeNames[typ]; !ok {
+			return ErrMalformedSuppression
+		}
+	}
+	return nil
+}
+
+// ActiveAt returns true if the suppression has not expired at the given
+// time.
+func (s Suppression) ActiveAt(t time.Time) bool {
+	return t.Before(s.ExpiresAt)
+}
+
+// selects returns true if the given entry of the given type matches the
+// selector of the suppression.
+func (s Suppression) selects(typ CronType, e CronEntry) bool {
+	if len(s.Types) > 0 {
+		var found bool
+		for _, t := range s.Types {
+			found = found || t == typ
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(s.Teams) > 0 {
+		var found bool
+		for _, t := range s.Teams {
+			found = found || t == e.GetTeamID()
+		}
+		if !found {
+			return false
+		}
+	}
+	metadata := entryMetadata(e)
+	for k, v := range s.Labels {
+		if got, ok := metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SuppressionStore defines the services needed to persist the suppressions.
+type SuppressionStore interface {
+	GetSuppressions() (map[string]Suppression, error)
+	SaveSuppressions(suppressions map[string]Suppression) error
+}
+
+// WithSuppressions enables the suppressions, persisting them in the given
+// store, so they survive the restarts while they last.
+func WithSuppressions(store SuppressionStore) Option {
+	return func(c *Crontinuous) {
+		c.suppressionStore = store
+	}
+}
+
+// loadSuppressions returns the suppressions, reading them from the store
+// the first time. It must be called holding the suppressions lock.
+func (c *Crontinuous) loadSuppressions() (map[string]Suppression, error) {
+	if c.suppressions != nil {
+		return c.suppressions, nil
+	}
+	suppressions, err := c.suppressionStore.GetSuppressions()
+	if err != nil {
+		return nil, err
+	}
+	if suppressions == nil {
+		suppressions = map[string]Suppression{}
+	}
+	c.suppressions = suppressions
+	return suppressions, nil
+}
+
+// Suppressions returns the suppressions not expired yet sorted by
+// expiration.
+func (c *Crontinuous) Suppressions() ([]Suppression, error) {
+	if c.suppressionStore == nil {
+		return nil, ErrSuppressionsDisabled
+	}
+	c.suppressionsMux.Lock()
+	defer c.suppressionsMux.Unlock()
+
+	loaded, err := c.loadSuppressions()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	suppressions := []Suppression{}
+	for _, s := range loaded {
+		if s.ActiveAt(now) {
+			suppressions = append(suppressions, s)
+		}
+	}
+	sort.Slice(suppressions, func(i, j int) bool {
+		if !suppressions[i].ExpiresAt.Equal(suppressions[j].ExpiresAt) {
+			return suppressions[i].ExpiresAt.Before(suppressions[j].ExpiresAt)
+		}
+		return suppressions[i].ID < suppressions[j].ID
+	})
+	return suppressions, nil
+}
+
+// Suppress creates the given suppression, expiring after its TTL, and
+// returns it with its ID and expiration. It applies to the executions
+// started after it is created.
+func (c *Crontinuous) Suppress(s Suppression) (Suppression, error) {
+	if err := c.checkWritable(); err != nil {
+		return Suppression{}, err
+	}
+	if c.suppressionStore == nil {
+		return Suppression{}, ErrSuppressionsDisabled
+	}
+	if err := s.Validate(); err != nil {
+		return Suppression{}, err
+	}
+	s.ID = NewRequestID()
+	s.CreatedAt = time.Now()
+	s.ExpiresAt = s.CreatedAt.Add(time.Duration(s.TTL))
+
+	err := c.updateSuppressions(func(suppressions map[string]Suppression) error {
+		suppressions[s.ID] = s
+		return nil
+	})
+	if err != nil {
+		return Suppression{}, err
+	}
+	c.log.WithFields(logrus.Fields{
+		"suppression": s.ID,
+		"teams":       s.Teams,
+		"labels":      s.Labels,
+		"expires_at":  s.ExpiresAt,
+		"reason":      s.Reason,
+	}).Info("Suppression created")
+	return s, nil
+}
+
+// Unsuppress removes the suppression with the given ID before it expires.
+func (c *Crontinuous) Unsuppress(id string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.suppressionStore == nil {
+		return ErrSuppressionsDisabled
+	}
+	err := c.updateSuppressions(func(suppressions map[string]Suppression) error {
+		if _, ok := suppressions[id]; !ok {
+			return ErrSuppressionNotFound
+		}
+		delete(suppressions, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.log.WithField("suppression", id).Info("Suppression removed")
+	return nil
+}
+
+// updateSuppressions applies fn to a copy of the suppressions not expired
+// yet and persists them, so the expired ones are discarded.
+func (c *Crontinuous) updateSuppressions(fn func(map[string]Suppression) error) error {
+	c.suppressionsMux.Lock()
+	defer c.suppressionsMux.Unlock()
+
+	current, err := c.loadSuppressions()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	suppressions := make(map[string]Suppression, len(current)+1)
+	for id, s := range current {
+		if s.ActiveAt(now) {
+			suppressions[id] = s
+		}
+	}
+	if err := fn(suppressions); err != nil {
+		return err
+	}
+	if err := c.suppressionStore.SaveSuppressions(suppressions); err != nil {
+		return err
+	}
+	c.suppressions = suppressions
+	return nil
+}
+
+// currentSuppressions returns the suppressions not expired yet, none if they
+// are disabled. The suppressions that can not be read from the store are
+// logged and ignored, as the pause windows.
+func (c *Crontinuous) currentSuppressions() []Suppression {
+	if c.suppressionStore == nil {
+		return nil
+	}
+	suppressions, err := c.Suppressions()
+	if err != nil {
+		c.log.WithError(err).Error("Error reading suppressions, ignoring them")
+		return nil
+	}
+	return suppressions
+}
+
+// activeSuppression returns the first suppression, by expiration, selecting
+// the entry of the given type and ID that is active at the given time.
+func (c *Crontinuous) activeSuppression(typ CronType, entryID string, t time.Time) (Suppression, bool) {
+	suppressions := c.currentSuppressions()
+	if len(suppressions) == 0 {
+		return Suppression{}, false
+	}
+	e, err := c.GetEntryByID(typ, entryID)
+	if err != nil {
+		return Suppression{}, false
+	}
+	return suppressing(suppressions, typ, e, t)
+}
+
+// suppressing returns the first of the given suppressions selecting the
+// given entry of the given type that is active at the given time.
+func suppressing(suppressions []Suppression, typ CronType, e CronEntry, t time.Time) (Suppression, bool) {
+	for _, s := range suppressions {
+		if s.ActiveAt(t) && s.selects(typ, e) {
+			return s, true
+		}
+	}
+	return Suppression{}, false
+}
+
+// suppressedJob wraps the job of an entry so it is not executed while a
+// suppression selecting it is active.
+type suppressedJob struct {
+	entryJob
+	c *Crontinuous
+}
+
+func (j *suppressedJob) run(ctx context.Context) error {
+	if s, ok := j.c.activeSuppression(j.cronType(), j.entryID(), time.Now()); ok {
+		j.c.log.WithFields(logrus.Fields{
+			"team":        j.team(),
+			"type":        j.cronType().String(),
+			"entry_id":    j.entryID(),
+			"suppression": s.ID,
+			"reason":      s.Reason,
+		}).Info("Skipping job, suppressed")
+		return skipJob(SkipSuppressed, "suppression "+s.ID+": "+s.Reason)
+	}
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type memSuppressionStore struct {
+	suppressions map[string]Suppression
+}
+
+func (s *memSuppressionStore) GetSuppressions() (map[string]Suppression, error) {
+	return s.suppressions, nil
+}
+
+func (s *memSuppressionStore) SaveSuppressions(suppressions map[string]Suppression) error {
+	s.suppressions = suppressions
+	return nil
+}
+
+func TestSuppression_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		suppression Suppression
+		wantErr     error
+	}{
+		{"teams", Suppression{Teams: []string{"t1"}, TTL: Duration(time.Hour), Reason: "INC-1"}, nil},
+		{"labels", Suppression{Labels: map[string]string{"env": "pro"}, Types: []CronType{ScanCronType}, TTL: Duration(time.Hour), Reason: "INC-1"}, nil},
+		{"no selector", Suppression{TTL: Duration(time.Hour), Reason: "INC-1"}, ErrMalformedSuppression},
+		{"no ttl", Suppression{Teams: []string{"t1"}, Reason: "INC-1"}, ErrMalformedSuppression},
+		{"ttl too long", Suppression{Teams: []string{"t1"}, TTL: Duration(30 * 24 * time.Hour), Reason: "INC-1"}, ErrMalformedSuppression},
+		{"no reason", Suppression{Teams: []string{"t1"}, TTL: Duration(time.Hour), Reason: " "}, ErrMalformedSuppression},
+		{"unknown type", Suppression{Teams: []string{"t1"}, Types: []CronType{CronType(42)}, TTL: Duration(time.Hour), Reason: "INC-1"}, ErrMalformedSuppression},
+	}
+	for _, tt := range tests {
+		if err := tt.suppression.Validate(); err != tt.wantErr {
+			t.Errorf("%s: want error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestSuppression_selects(t *testing.T) {
+	entry := ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{Metadata: map[string]string{"env": "pro", "owner": "a"}}}
+	tests := []struct {
+		name        string
+		suppression Suppression
+		want        bool
+	}{
+		{"team", Suppression{Teams: []string{"t2", "t1"}}, true},
+		{"other team", Suppression{Teams: []string{"t2"}}, false},
+		{"labels", Suppression{Labels: map[string]string{"env": "pro"}}, true},
+		{"other label value", Suppression{Labels: map[string]string{"env": "dev"}}, false},
+		{"missing label", Suppression{Labels: map[string]string{"tier": "1"}}, false},
+		{"team and labels", Suppression{Teams: []string{"t1"}, Labels: map[string]string{"owner": "a"}}, true},
+		{"other type", Suppression{Teams: []string{"t1"}, Types: []CronType{ReportCronType}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.suppression.selects(ScanCronType, entry); got != tt.want {
+			t.Errorf("%s: want %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCrontinuous_Suppress(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	executed := make(chan string, 2)
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		executed <- programID
+		return nil
+	}}
+	expired := Suppression{ID: "old", Teams: []string{"t1"}, TTL: Duration(time.Hour), Reason: "INC-0",
+		ExpiresAt: time.Now().Add(-time.Minute)}
+	suppressions := &memSuppressionStore{suppressions: map[string]Suppression{"old": expired}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store, WithSuppressions(suppressions))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if got, err := c.Suppressions(); err != nil || len(got) != 0 {
+		t.Fatalf("want the expired suppressions ignored, got %v, %v", got, err)
+	}
+	s, err := c.Suppress(Suppression{Teams: []string{"t1"}, TTL: Duration(time.Hour), Reason: "INC-1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ID == "" || !s.ExpiresAt.Equal(s.CreatedAt.Add(time.Hour)) {
+		t.Errorf("want the suppression identified and expiring after its TTL, got %+v", s)
+	}
+	if _, ok := suppressions.suppressions["old"]; ok {
+		t.Error("want the expired suppressions discarded from the store")
+	}
+
+	if err := c.RunEntry(ScanCronType, "p1"); err != ErrTeamNotAllowed {
+		t.Errorf("want ErrTeamNotAllowed running a suppressed entry, got %v", err)
+	}
+	// The activations of the entry are skipped.
+	set, err := c.entrySet(ScanCronType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := set.job("p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.wrapJob(job).Run()
+	skipped, err := c.Executions(ExecutionFilter{Status: ExecutionSkipped, SkipReason: SkipSuppressed})
+	if err != nil || len(skipped) != 1 {
+		t.Fatalf("want the activation skipped, got %+v, %v", skipped, err)
+	}
+	if !strings.Contains(skipped[0].Error, "INC-1234") {
+		t.Errorf("want the reason of the suppression recorded, got %q", skipped[0].Error)
+	}
+
+	if err := c.Unsuppress(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Unsuppress(s.ID); err != ErrSuppressionNotFound {
+		t.Errorf("want ErrSuppressionNotFound removing twice, got %v", err)
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-executed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the entry executed after removing the suppression")
+	}
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// maxTeamCircuitCooldown is the maximum time the circuit of a team stays
+// open, regardless of the number of consecutive openings.
+const maxTeamCircuitCooldown = time.Hour
+
+// ErrTeamCircuitOpen is returned when creating a scan or sending a report
+// for a team whose requests to vulcan-api are failing with server errors.
+var ErrTeamCircuitOpen = errors.New("ErrorTeamCircuitOpen")
+
+// WithTeamCircuit makes crontinuous stop requesting vulcan-api for a team
+// after the given number of consecutive server errors in the requests for it,
+// regardless of the entries performing them. The scan creations and report
+// sendings of the team fail with ErrTeamCircuitOpen during the given
+// cooldown, which doubles every time the circuit opens again after it, up to
+// one hour. When the cooldown passes a single request is allowed, closing the
+// circuit if it succeeds. Only the server errors of the VulcanClient are
+// counted. Zero threshold disables the circuit.
+func WithTeamCircuit(threshold int, cooldown time.Duration) Option {
+	return func(c *Crontinuous) {
+		if threshold <= 0 {
+			return
+		}
+		c.circuits = &teamCircuits{
+			threshold: threshold,
+			cooldown:  cooldown,
+			teams:     map[string]*teamCircuit{},
+		}
+	}
+}
+
+// teamCircuits holds the circuit of every team with server errors.
+type teamCircuits struct {
+	threshold int
+	cooldown  time.Duration
+	log       *logrus.Logger
+	rejected  typeCounts
+
+	mux   sync.Mutex
+	teams map[string]*teamCircuit
+}
+
+// teamCircuit defines the state of the requests for a team.
+type teamCircuit struct {
+	// failures is the number of consecutive server errors.
+	failures int
+	// opens is the number of consecutive openings.
+	opens int
+	// openUntil is zero while the circuit is closed.
+	openUntil time.Time
+	// probing is true while the request allowed after the cooldown runs.
+	probing bool
+	// tripped is closed when the circuit opens, to abort the requests in
+	// progress.
+	tripped chan struct{}
+}
+
+type circuitKey struct{}
+
+// circuitCall identifies in the context of a request the circuit it is
+// performed under.
+type circuitCall struct {
+	circuits *teamCircuits
+	team     string
+}
+
+// recordServerError counts a server error of vulcan-api in the circuit of
+// the request with the given context, if any.
+func recordServerError(ctx context.Context) {
+	if call, ok := ctx.Value(circuitKey{}).(circuitCall); ok {
+		call.circuits.failed(call.team)
+	}
+}
+
+// call performs the given request for the given team under its circuit.
+func (tc *teamCircuits) call(ctx context.Context, typ CronType, team string, req func(context.Context) error) error {
+	tripped, err := tc.enter(team)
+	if err != nil {
+		tc.rejected.inc(typ)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, circuitKey{}, circuitCall{circuits: tc, team: team}))
+	defer cancel()
+	go func() {
+		select {
+		case <-tripped:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err = req(ctx)
+	tc.exit(team, err)
+	if err == nil {
+		return nil
+	}
+	select {
+	case <-tripped:
+		return fmt.Errorf("%w: %v", ErrTeamCircuitOpen, err)
+	default:
+		return err
+	}
+}
+
+// enter returns the channel closed when the circuit of the given team opens,
+// or ErrTeamCircuitOpen if it is open.
+func (tc *teamCircuits) enter(team string) (<-chan struct{}, error) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	c, ok := tc.teams[team]
+	if !ok {
+		c = &teamCircuit{tripped: make(chan struct{})}
+		tc.teams[team] = c
+	}
+	if !c.openUntil.IsZero() {
+		if c.probing || time.Now().Before(c.openUntil) {
+			return nil, ErrTeamCircuitOpen
+		}
+		c.probing = true
+	}
+	return c.tripped, nil
+}
+
+// exit records the result of a request for the given team.
+func (tc *teamCircuits) exit(team string, err error) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	c := tc.teams[team]
+	if err != nil {
+		// The server errors were already counted, other errors only end
+		// the probe.
+		c.probing = false
+		return
+	}
+	if !c.openUntil.IsZero() {
+		tc.log.WithField("team", team).Info("Closing circuit of team, vulcan-api requests succeed again")
+	}
+	c.failures, c.opens, c.openUntil, c.probing = 0, 0, time.Time{}, false
+}
+
+// failed counts a server error in the requests for the given team.
+func (tc *teamCircuits) failed(team string) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	c := tc.teams[team]
+	c.failures++
+	if c.probing {
+		c.probing = false
+	} else if !c.openUntil.IsZero() || c.failures < tc.threshold {
+		return
+	}
+
+	c.opens++
+	cooldown := tc.cooldown
+	for i := 1; i < c.opens && cooldown < maxTeamCircuitCooldown; i++ {
+		cooldown *= 2
+	}
+	if cooldown > maxTeamCircuitCooldown {
+		cooldown = maxTeamCircuitCooldown
+	}
+	c.openUntil = time.Now().Add(cooldown)
+	close(c.tripped)
+	c.tripped = make(chan struct{})
+	tc.log.WithFields(logrus.Fields{
+		"team":     team,
+		"failures": c.failures,
+		"cooldown": cooldown.String(),
+	}).Warn("Opening circuit of team, vulcan-api requests are failing")
+}
+
+// openUntil returns the time the circuit of the given team is open until, if
+// it is open.
+func (tc *teamCircuits) openUntil(team string) (time.Time, bool) {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	c, ok := tc.teams[team]
+	if !ok || c.openUntil.IsZero() {
+		return time.Time{}, false
+	}
+	return c.openUntil, true
+}
+
+// quarantinedUntil returns the time until which the scheduled entries of the
+// given type and team are quarantined, that is, their executions are skipped
+// because the circuit of the team is open, and false if they are not. The
+// command entries are never quarantined.
+func (c *Crontinuous) quarantinedUntil(typ CronType, teamID string) (time.Time, bool) {
+	if c.circuits == nil || typ == CommandCronType {
+		return time.Time{}, false
+	}
+	return c.circuits.openUntil(teamID)
+}
+
+// open returns the teams with the circuit open.
+func (tc *teamCircuits) open() []string {
+	tc.mux.Lock()
+	defer tc.mux.Unlock()
+
+	var teams []string
+	for team, c := range tc.teams {
+		if !c.openUntil.IsZero() {
+			teams = append(teams, team)
+		}
+	}
+	return teams
+}
+
+// circuitScanCreator wraps the scan creator of crontinuous to create the
+// scans under the circuit of their team.
+type circuitScanCreator struct {
+	ScanCreator
+	circuits *teamCircuits
+}
+
+func (s *circuitScanCreator) CreateScan(programID, teamID string) error {
+	return s.CreateScanContext(context.Background(), programID, teamID)
+}
+
+func (s *circuitScanCreator) CreateScanContext(ctx context.Context, programID, teamID string) error {
+	return s.circuits.call(ctx, executionType(ctx, ScanCronType), teamID, func(ctx context.Context) error {
+		return createScan(ctx, s.ScanCreator, programID, teamID)
+	})
+}
+
+// circuitReportSender wraps the report sender of crontinuous to send the
+// reports under the circuit of their team.
+type circuitReportSender struct {
+	ReportSender
+	circuits *teamCircuits
+}
+
+func (s *circuitReportSender) SendReport(teamID string) error {
+	return s.SendReportContext(context.Background(), teamID)
+}
+
+func (s *circuitReportSender) SendReportContext(ctx context.Context, teamID string) error {
+	return s.circuits.call(ctx, ReportCronType, teamID, func(ctx context.Context) error {
+		return sendReport(ctx, s.ReportSender, teamID)
+	})
+}
+
+// executionType returns the type of the entry whose execution the given
+// context carries, or the given type if none.
+func executionType(ctx context.Context, typ CronType) CronType {
+	if e, ok := ExecutionFromContext(ctx); ok {
+		return e.Type
+	}
+	return typ
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// serverErrorReq returns a request failing with a server error.
+func serverErrorReq(ctx context.Context) error {
+	recordServerError(ctx)
+	return errors.New("503 Service Unavailable")
+}
+
+func okReq(ctx context.Context) error {
+	return nil
+}
+
+func TestTeamCircuits(t *testing.T) {
+	c := NewCrontinuous(Config{}, logrus.New(), nil, nil, nil, nil, WithTeamCircuit(2, 50*time.Millisecond))
+	tc := c.circuits
+
+	if err := tc.call(context.Background(), ScanCronType, "t1", serverErrorReq); err == nil || errors.Is(err, ErrTeamCircuitOpen) {
+		t.Fatalf("want the server error, got %v", err)
+	}
+	// The second consecutive server error opens the circuit, aborting the
+	// request performing it.
+	if err := tc.call(context.Background(), ScanCronType, "t1", serverErrorReq); !errors.Is(err, ErrTeamCircuitOpen) {
+		t.Fatalf("want ErrTeamCircuitOpen, got %v", err)
+	}
+	if err := tc.call(context.Background(), ReportCronType, "t1", okReq); err != ErrTeamCircuitOpen {
+		t.Fatalf("want ErrTeamCircuitOpen while open, got %v", err)
+	}
+	if err := tc.call(context.Background(), ScanCronType, "t2", okReq); err != nil {
+		t.Fatalf("want the requests of other teams performed, got %v", err)
+	}
+	if open := tc.open(); len(open) != 1 || open[0] != "t1" {
+		t.Errorf("want the circuit of t1 open, got %v", open)
+	}
+	if n := tc.rejected.snapshot()[ReportCronType]; n != 1 {
+		t.Errorf("want 1 report rejected, got %d", n)
+	}
+
+	// A failed probe opens the circuit again for twice the cooldown.
+	time.Sleep(60 * time.Millisecond)
+	if err := tc.call(context.Background(), ScanCronType, "t1", serverErrorReq); !errors.Is(err, ErrTeamCircuitOpen) {
+		t.Fatalf("want the probe aborted, got %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if err := tc.call(context.Background(), ScanCronType, "t1", okReq); err != ErrTeamCircuitOpen {
+		t.Fatalf("want ErrTeamCircuitOpen during the doubled cooldown, got %v", err)
+	}
+
+	// A successful probe closes the circuit.
+	time.Sleep(50 * time.Millisecond)
+	if err := tc.call(context.Background(), ScanCronType, "t1", okReq); err != nil {
+		t.Fatalf("want the probe performed, got %v", err)
+	}
+	if err := tc.call(context.Background(), ScanCronType, "t1", okReq); err != nil {
+		t.Errorf("want the circuit closed, got %v", err)
+	}
+	if open := tc.open(); len(open) != 0 {
+		t.Errorf("want no circuit open, got %v", open)
+	}
+}
+
+func TestCrontinuous_TeamCircuit(t *testing.T) {
+	var (
+		mux      sync.Mutex
+		requests = map[string]int{}
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		requests[r.URL.Path]++
+		if strings.Contains(r.URL.Path, "/t1/") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	vulcan := &VulcanClient{VulcanAPI: s.URL, VulcanUser: "user", VulcanToken: "token"}
+	c := NewCrontinuous(Config{}, logrus.New(), vulcan, nil, vulcan, nil, WithTeamCircuit(2, time.Hour))
+
+	// The retries of the first scan open the circuit.
+	if err := createScan(context.Background(), c.scanCreator, "p1", "t1"); !errors.Is(err, ErrTeamCircuitOpen) {
+		t.Fatalf("want ErrTeamCircuitOpen, got %v", err)
+	}
+	if err := createScan(context.Background(), c.scanCreator, "p2", "t1"); err != ErrTeamCircuitOpen {
+		t.Fatalf("want ErrTeamCircuitOpen, got %v", err)
+	}
+	if err := sendReport(context.Background(), c.reportSender, "t1"); err != ErrTeamCircuitOpen {
+		t.Fatalf("want ErrTeamCircuitOpen, got %v", err)
+	}
+	if err := createScan(context.Background(), c.scanCreator, "p3", "t2"); err != nil {
+		t.Fatal(err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if n := requests["/v1/teams/t1/scans"]; n != 2 {
+		t.Errorf("want 2 requests for t1, got %d", n)
+	}
+	if n := requests["/v1/teams/t1/report/digest"]; n != 0 {
+		t.Errorf("want no report requested for t1, got %d", n)
+	}
+}
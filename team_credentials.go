@@ -0,0 +1,151 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// ErrNoTeamCredentials is returned when performing a request for a team
+// without credentials while they are required.
+var ErrNoTeamCredentials = errors.New("ErrorNoTeamCredentials")
+
+// TeamCredentials defines the identity a team is scanned with in vulcan-api.
+type TeamCredentials struct {
+	User  string `json:"user"`
+	Token string `json:"token"`
+}
+
+// TeamCredentialsStore defines the services needed to read the credentials
+// of the teams, indexed by team ID.
+type TeamCredentialsStore interface {
+	GetTeamCredentials() (map[string]TeamCredentials, error)
+}
+
+// TeamCredentialsCache caches the credentials read from a store, so the store
+// is not read on every request to vulcan-api.
+type TeamCredentialsCache struct {
+	store TeamCredentialsStore
+	ttl   time.Duration
+
+	mux    sync.Mutex
+	creds  map[string]TeamCredentials
+	loaded time.Time
+}
+
+// NewTeamCredentialsCache returns a cache of the credentials of the given
+// store that reads them again when they are older than the given TTL. Zero
+// reads them only once.
+func NewTeamCredentialsCache(store TeamCredentialsStore, ttl time.Duration) *TeamCredentialsCache {
+	return &TeamCredentialsCache{store: store, ttl: ttl}
+}
+
+// Get returns the credentials of the given team, if any. If the store can not
+// be read the credentials read before are used, and the error is only
+// returned if they were never read.
+func (c *TeamCredentialsCache) Get(teamID string) (TeamCredentials, bool, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	expired := c.ttl > 0 && time.Since(c.loaded) >= c.ttl
+	if c.creds == nil || expired {
+		creds, err := c.store.GetTeamCredentials()
+		switch {
+		case err == nil:
+			if creds == nil {
+				creds = map[string]TeamCredentials{}
+			}
+			c.creds, c.loaded = creds, time.Now()
+		case c.creds == nil:
+			return TeamCredentials{}, false, fmt.Errorf("reading team credentials: %w", err)
+		}
+	}
+	tc, ok := c.creds[teamID]
+	return tc, ok, nil
+}
+
+// S3TeamCredentialsStore reads the credentials of the teams from a JSON
+// object in an S3 bucket, which must only be readable by crontinuous.
+type S3TeamCredentialsStore struct {
+	client s3iface.S3API
+	bucket string
+	key    string
+}
+
+// NewS3TeamCredentialsStore returns a store reading the credentials of the
+// teams from the object with the given key of the given bucket.
+func NewS3TeamCredentialsStore(client s3iface.S3API, bucket, key string) *S3TeamCredentialsStore {
+	return &S3TeamCredentialsStore{client: client, bucket: bucket, key: key}
+}
+
+func (s *S3TeamCredentialsStore) GetTeamCredentials() (map[string]TeamCredentials, error) {
+	output, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close() // nolint
+
+	content, err := ioutil.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeTeamCredentials(content)
+}
+
+// SSMTeamCredentialsStore reads the credentials of the teams from an SSM
+// parameter, usually a SecureString.
+type SSMTeamCredentialsStore struct {
+	client ssmiface.SSMAPI
+	name   string
+}
+
+// NewSSMTeamCredentialsStore returns a store reading the credentials of the
+// teams from the SSM parameter with the given name.
+func NewSSMTeamCredentialsStore(client ssmiface.SSMAPI, name string) *SSMTeamCredentialsStore {
+	return &SSMTeamCredentialsStore{client: client, name: name}
+}
+
+func (s *SSMTeamCredentialsStore) GetTeamCredentials() (map[string]TeamCredentials, error) {
+	output, err := s.client.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(s.name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.Parameter == nil {
+		return nil, fmt.Errorf("parameter %s has no value", s.name)
+	}
+	return decodeTeamCredentials([]byte(aws.StringValue(output.Parameter.Value)))
+}
+
+// decodeTeamCredentials decodes the given JSON object, indexed by team ID,
+// checking every team has a token.
+func decodeTeamCredentials(content []byte) (map[string]TeamCredentials, error) {
+	var creds map[string]TeamCredentials
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return nil, fmt.Errorf("decoding team credentials: %w", err)
+	}
+	for teamID, tc := range creds {
+		if tc.Token == "" {
+			return nil, fmt.Errorf("team %s has no token", teamID)
+		}
+	}
+	return creds, nil
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockTeamCredentialsStore struct {
+	creds map[string]TeamCredentials
+	err   error
+	reads int
+}
+
+func (m *mockTeamCredentialsStore) GetTeamCredentials() (map[string]TeamCredentials, error) {
+	m.reads++
+	return m.creds, m.err
+}
+
+func TestTeamCredentialsCache(t *testing.T) {
+	store := &mockTeamCredentialsStore{err: errors.New("access denied")}
+	cache := NewTeamCredentialsCache(store, time.Millisecond)
+	if _, _, err := cache.Get("t1"); err == nil {
+		t.Fatal("want error if the credentials were never read")
+	}
+
+	store.creds, store.err = map[string]TeamCredentials{"t1": {Token: "token-1"}}, nil
+	got, ok, err := cache.Get("t1")
+	if err != nil || !ok || got.Token != "token-1" {
+		t.Fatalf("want the credentials of t1, got %+v, %v, %v", got, ok, err)
+	}
+	if _, ok, _ := cache.Get("t2"); ok {
+		t.Error("want no credentials for t2")
+	}
+
+	// The last credentials read are used while the store fails.
+	time.Sleep(2 * time.Millisecond)
+	store.err = errors.New("access denied")
+	reads := store.reads
+	if got, ok, err := cache.Get("t1"); err != nil || !ok || got.Token != "token-1" {
+		t.Errorf("want the cached credentials of t1, got %+v, %v, %v", got, ok, err)
+	}
+	if store.reads != reads+1 {
+		t.Errorf("want the expired credentials read again")
+	}
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	params map[string]string
+}
+
+func (m *mockSSM) GetParameter(in *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	if !aws.BoolValue(in.WithDecryption) {
+		return nil, errors.New("parameter not decrypted")
+	}
+	v, ok := m.params[aws.StringValue(in.Name)]
+	if !ok {
+		return nil, errors.New(ssm.ErrCodeParameterNotFound)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(v)}}, nil
+}
+
+func TestTeamCredentialsStores(t *testing.T) {
+	content := `{"t1": {"user": "team1@vulcan.com", "token": "token-1"}}`
+	want := map[string]TeamCredentials{"t1": {User: "team1@vulcan.com", Token: "token-1"}}
+
+	s3Client := newMockS3()
+	s3Client.objects["secrets/credentials.json"] = []byte(content)
+	s3Client.objects["secrets/invalid.json"] = []byte(`{"t1": {"user": "team1@vulcan.com"}}`)
+	got, err := NewS3TeamCredentialsStore(s3Client, "bucket", "secrets/credentials.json").GetTeamCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("S3 credentials diff: %s", diff)
+	}
+	if _, err := NewS3TeamCredentialsStore(s3Client, "bucket", "secrets/invalid.json").GetTeamCredentials(); err == nil {
+		t.Error("want error for credentials without token")
+	}
+
+	ssmClient := &mockSSM{params: map[string]string{"/crontinuous/credentials": content}}
+	got, err = NewSSMTeamCredentialsStore(ssmClient, "/crontinuous/credentials").GetTeamCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SSM credentials diff: %s", diff)
+	}
+	if _, err := NewSSMTeamCredentialsStore(ssmClient, "/unknown").GetTeamCredentials(); err == nil {
+		t.Error("want error for an unknown parameter")
+	}
+}
+
+func TestVulcanClient_TeamCredentials(t *testing.T) {
+	type request struct {
+		Auth        string
+		RequestedBy string
+	}
+	var got []request
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ScanRequest
+		json.NewDecoder(r.Body).Decode(&req) // nolint
+		got = append(got, request{Auth: r.Header.Get("Authorization"), RequestedBy: req.RequestedBy})
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	store := &mockTeamCredentialsStore{creds: map[string]TeamCredentials{
+		"t1": {User: "team1@vulcan.com", Token: "token-1"},
+		"t2": {Token: "token-2"},
+	}}
+	c := &VulcanClient{
+		VulcanAPI:       s.URL,
+		VulcanUser:      "user",
+		VulcanToken:     "token",
+		TeamCredentials: NewTeamCredentialsCache(store, 0),
+	}
+	for _, team := range []string{"t1", "t2", "t3"} {
+		if err := c.CreateScan("p1", team); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []request{
+		{Auth: "Bearer token-1", RequestedBy: "team1@vulcan.com"},
+		{Auth: "Bearer token-2", RequestedBy: "user"},
+		{Auth: "Bearer token", RequestedBy: "user"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("requests diff: %s", diff)
+	}
+
+	c.RequireTeamCredentials = true
+	if err := c.CreateScan("p1", "t3"); err != ErrNoTeamCredentials {
+		t.Errorf("want ErrNoTeamCredentials, got %v", err)
+	}
+	if store.reads != 1 {
+		t.Errorf("want the credentials read once, got %d", store.reads)
+	}
+}
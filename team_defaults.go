@@ -0,0 +1,353 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/manelmontilla/cron"
+)
+
+// S3TeamDefaultsFilename is the key of the object storing the default
+// settings of the teams.
+const S3TeamDefaultsFilename = "team-defaults.json"
+
+var (
+	// ErrTeamDefaultsDisabled indicates no team defaults store is
+	// configured.
+	ErrTeamDefaultsDisabled = errors.New("ErrorTeamDefaultsDisabled")
+	// ErrTeamDefaultsNotFound indicates the team has no default settings.
+	ErrTeamDefaultsNotFound = errors.New("ErrorTeamDefaultsNotFound")
+	// ErrMalformedTeamDefaults indicates the default settings of the team
+	// are not valid.
+	ErrMalformedTeamDefaults = errors.New("ErrorMalformedTeamDefaults")
+)
+
+// ConcurrencyPolicy defines whether the executions of an entry can overlap.
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow lets an activation of an entry run while the
+	// previous execution of the entry is still running. It is the default.
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid skips the activations of an entry while the
+	// previous execution of the entry is still running.
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+)
+
+// TeamDefaults defines the settings inherited by the entries of a team that
+// don't define their own, so they don't need to be repeated on every entry.
+type TeamDefaults struct {
+	TeamID string `json:"team_id" yaml:"team_id"`
+	// Jitter applies to the entries without jitter.
+	Jitter Duration `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	// Timezone is the name of the IANA time zone the specs of the entries
+	// of the team are evaluated in, e.g. Europe/Madrid. Empty means the
+	// time zone of crontinuous.
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	// Alerting applies to the entries without alerting preferences, and
+	// its fields to the fields the entries leave empty, e.g. the channel.
+	Alerting *AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	// Concurrency applies to all the entries of the team. Empty means
+	// ConcurrencyAllow.
+	Concurrency ConcurrencyPolicy `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+}
+
+// Validate returns ErrMalformedTeamDefaults if the settings are not valid.
+func (d TeamDefaults) Validate() error {
+	if d.TeamID == "" {
+		return ErrMalformedTeamDefaults
+	}
+	if validateJitter(d.Jitter) != nil || validateAlertSettings(d.Alerting) != nil {
+		return ErrMalformedTeamDefaults
+	}
+	if _, err := d.location(); err != nil {
+		return ErrMalformedTeamDefaults
+	}
+	switch d.Concurrency {
+	case "", ConcurrencyAllow, ConcurrencyForbid:
+	default:
+		return ErrMalformedTeamDefaults
+	}
+	return nil
+}
+
+// location returns the time zone of the team, nil if it is not set.
+func (d TeamDefaults) location() (*time.Location, error) {
+	if d.Timezone == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(d.Timezone)
+}
+
+// inherit returns the given settings of an entry of the team with the
+// settings the entry does not define taken from the defaults.
+func (d TeamDefaults) inherit(s jobSettings) jobSettings {
+	if s.jitter == 0 {
+		s.jitter = d.Jitter
+	}
+	if d.Alerting == nil {
+		return s
+	}
+	if s.alerting == nil {
+		s.alerting = d.Alerting
+		return s
+	}
+	alerting := *s.alerting
+	if alerting.FailureThreshold == 0 {
+		alerting.FailureThreshold = d.Alerting.FailureThreshold
+	}
+	if alerting.Channel == "" {
+		alerting.Channel = d.Alerting.Channel
+	}
+	s.alerting = &alerting
+	return s
+}
+
+// TeamDefaultsStore defines the services needed to persist the default
+// settings of the teams.
+type TeamDefaultsStore interface {
+	GetTeamDefaults() (map[string]TeamDefaults, error)
+	SaveTeamDefaults(defaults map[string]TeamDefaults) error
+}
+
+// WithTeamDefaults enables the default settings of the teams, persisting
+// them in the given store. As they change how the entries are scheduled,
+// they are read from the store every time the entries are loaded.
+func WithTeamDefaults(store TeamDefaultsStore) Option {
+	return func(c *Crontinuous) {
+		c.teamDefaultsStore = store
+	}
+}
+
+// loadTeamDefaults reads the default settings of the teams from the store.
+func (c *Crontinuous) loadTeamDefaults() error {
+	if c.teamDefaultsStore == nil {
+		return nil
+	}
+	defaults, err := c.teamDefaultsStore.GetTeamDefaults()
+	if err != nil {
+		return err
+	}
+	if defaults == nil {
+		defaults = map[string]TeamDefaults{}
+	}
+	c.teamDefaultsMux.Lock()
+	c.teamDefaults = defaults
+	c.teamDefaultsMux.Unlock()
+	return nil
+}
+
+// teamDefaultsOf returns the default settings of the given team, the zero
+// value if it has none.
+func (c *Crontinuous) teamDefaultsOf(teamID string) TeamDefaults {
+	c.teamDefaultsMux.Lock()
+	defer c.teamDefaultsMux.Unlock()
+	return c.teamDefaults[teamID]
+}
+
+// AllTeamDefaults returns the default settings of all the teams sorted by
+// team.
+func (c *Crontinuous) AllTeamDefaults() ([]TeamDefaults, error) {
+	if c.teamDefaultsStore == nil {
+		return nil, ErrTeamDefaultsDisabled
+	}
+	c.teamDefaultsMux.Lock()
+	defer c.teamDefaultsMux.Unlock()
+
+	defaults := []TeamDefaults{}
+	for _, d := range c.teamDefaults {
+		defaults = append(defaults, d)
+	}
+	sort.Slice(defaults, func(i, j int) bool {
+		return defaults[i].TeamID < defaults[j].TeamID
+	})
+	return defaults, nil
+}
+
+// TeamDefaults returns the default settings of the given team.
+func (c *Crontinuous) TeamDefaults(teamID string) (TeamDefaults, error) {
+	if c.teamDefaultsStore == nil {
+		return TeamDefaults{}, ErrTeamDefaultsDisabled
+	}
+	c.teamDefaultsMux.Lock()
+	defer c.teamDefaultsMux.Unlock()
+
+	d, ok := c.teamDefaults[teamID]
+	if !ok {
+		return TeamDefaults{}, ErrTeamDefaultsNotFound
+	}
+	return d, nil
+}
+
+// SaveTeamDefaults creates or updates the given default settings of a team
+// and reschedules the entries of the team, so they inherit them.
+func (c *Crontinuous) SaveTeamDefaults(d TeamDefaults) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.teamDefaultsStore == nil {
+		return ErrTeamDefaultsDisabled
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	err := c.updateTeamDefaults(func(defaults map[string]TeamDefaults) error {
+		defaults[d.TeamID] = d
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.rescheduleTeam(d.TeamID)
+	return nil
+}
+
+// RemoveTeamDefaults removes the default settings of the given team and
+// reschedules the entries of the team without them.
+func (c *Crontinuous) RemoveTeamDefaults(teamID string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.teamDefaultsStore == nil {
+		return ErrTeamDefaultsDisabled
+	}
+	err := c.updateTeamDefaults(func(defaults map[string]TeamDefaults) error {
+		if _, ok := defaults[teamID]; !ok {
+			return ErrTeamDefaultsNotFound
+		}
+		delete(defaults, teamID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	c.rescheduleTeam(teamID)
+	return nil
+}
+
+// updateTeamDefaults applies fn to a copy of the default settings of the
+// teams and persists them.
+func (c *Crontinuous) updateTeamDefaults(fn func(map[string]TeamDefaults) error) error {
+	c.teamDefaultsMux.Lock()
+	defer c.teamDefaultsMux.Unlock()
+
+	defaults := make(map[string]TeamDefaults, len(c.teamDefaults)+1)
+	for team, d := range c.teamDefaults {
+		defaults[team] = d
+	}
+	if err := fn(defaults); err != nil {
+		return err
+	}
+	if err := c.teamDefaultsStore.SaveTeamDefaults(defaults); err != nil {
+		return err
+	}
+	c.teamDefaults = defaults
+	return nil
+}
+
+// rescheduleTeam replaces the jobs of the entries of the given team, so they
+// take its current default settings.
+func (c *Crontinuous) rescheduleTeam(teamID string) {
+	for _, typ := range c.cronTypes() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			continue
+		}
+		var changes []Change
+		for _, e := range set.all() {
+			if e.GetTeamID() == teamID {
+				changes = append(changes, Change{Action: ChangeUpdate, Type: typ, ID: e.GetID(), Current: e, Desired: e})
+			}
+		}
+		c.reschedule(typ, set, changes)
+	}
+}
+
+// entrySchedule returns the schedule of the given entry, evaluated in the
+// time zone of its team.
+func (c *Crontinuous) entrySchedule(e CronEntry) (cron.Schedule, error) {
+	s, err := parseEntrySpec(e)
+	if err != nil {
+		return nil, err
+	}
+	return c.inTeamTimezone(e.GetTeamID(), s), nil
+}
+
+// inTeamTimezone returns the given schedule of an entry of the given team
+// evaluated in the time zone of the team.
+func (c *Crontinuous) inTeamTimezone(teamID string, s cron.Schedule) cron.Schedule {
+	loc, err := c.teamDefaultsOf(teamID).location()
+	if err != nil || loc == nil {
+		return s
+	}
+	return &zonedSchedule{Schedule: s, loc: loc}
+}
+
+// zonedSchedule evaluates a schedule in a time zone, as the schedules are
+// evaluated in the time zone of the times they are given.
+type zonedSchedule struct {
+	cron.Schedule
+	loc *time.Location
+}
+
+func (s *zonedSchedule) Next(t time.Time) time.Time {
+	return s.Schedule.Next(t.In(s.loc))
+}
+
+// runningEntries holds the entries with an execution running, so the
+// entries with the ConcurrencyForbid policy don't overlap.
+type runningEntries struct {
+	mux sync.Mutex
+	ids map[string]bool
+}
+
+// start marks the entry with the given job ID as running. It returns false
+// if it already is.
+func (r *runningEntries) start(id string) bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.ids[id] {
+		return false
+	}
+	if r.ids == nil {
+		r.ids = map[string]bool{}
+	}
+	r.ids[id] = true
+	return true
+}
+
+func (r *runningEntries) finish(id string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	delete(r.ids, id)
+}
+
+// exclusiveJob skips the activations of an entry while the previous
+// execution of the entry is running.
+type exclusiveJob struct {
+	entryJob
+	running *runningEntries
+	log     *logrus.Logger
+}
+
+func (j *exclusiveJob) run(ctx context.Context) error {
+	id := cronJobID(j.cronType(), j.entryID())
+	if !j.running.start(id) {
+		j.log.WithFields(logrus.Fields{
+			"type":     j.cronType().String(),
+			"entry_id": j.entryID(),
+		}).Info("Skipping job, the previous execution is still running")
+		return skipJob(SkipOverlap, "previous execution still running")
+	}
+	defer j.running.finish(id)
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+type memTeamDefaultsStore struct {
+	defaults map[string]TeamDefaults
+}
+
+func (s *memTeamDefaultsStore) GetTeamDefaults() (map[string]TeamDefaults, error) {
+	return s.defaults, nil
+}
+
+func (s *memTeamDefaultsStore) SaveTeamDefaults(defaults map[string]TeamDefaults) error {
+	s.defaults = defaults
+	return nil
+}
+
+func TestTeamDefaults_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults TeamDefaults
+		wantErr  error
+	}{
+		{"valid", TeamDefaults{TeamID: "t1", Jitter: Duration(time.Minute), Timezone: "Europe/Madrid", Concurrency: ConcurrencyForbid}, nil},
+		{"only team", TeamDefaults{TeamID: "t1"}, nil},
+		{"no team", TeamDefaults{Timezone: "UTC"}, ErrMalformedTeamDefaults},
+		{"unknown timezone", TeamDefaults{TeamID: "t1", Timezone: "Europe/Atlantis"}, ErrMalformedTeamDefaults},
+		{"negative jitter", TeamDefaults{TeamID: "t1", Jitter: Duration(-time.Minute)}, ErrMalformedTeamDefaults},
+		{"unknown concurrency", TeamDefaults{TeamID: "t1", Concurrency: "replace"}, ErrMalformedTeamDefaults},
+	}
+	for _, tt := range tests {
+		if err := tt.defaults.Validate(); err != tt.wantErr {
+			t.Errorf("%s: want error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestTeamDefaults_inherit(t *testing.T) {
+	defaults := TeamDefaults{
+		TeamID:   "t1",
+		Jitter:   Duration(time.Minute),
+		Alerting: &AlertSettings{FailureThreshold: 3, Channel: "#team-alerts"},
+	}
+	tests := []struct {
+		name     string
+		settings jobSettings
+		want     jobSettings
+	}{
+		{
+			name:     "inherited",
+			settings: jobSettings{pingURL: "http://ping"},
+			want:     jobSettings{pingURL: "http://ping", jitter: defaults.Jitter, alerting: defaults.Alerting},
+		},
+		{
+			name:     "overridden",
+			settings: jobSettings{jitter: Duration(time.Second), alerting: &AlertSettings{FailureThreshold: 1, Channel: "#oncall"}},
+			want:     jobSettings{jitter: Duration(time.Second), alerting: &AlertSettings{FailureThreshold: 1, Channel: "#oncall"}},
+		},
+		{
+			name:     "alerting partially overridden",
+			settings: jobSettings{alerting: &AlertSettings{FailureThreshold: 1}},
+			want:     jobSettings{jitter: defaults.Jitter, alerting: &AlertSettings{FailureThreshold: 1, Channel: "#team-alerts"}},
+		},
+	}
+	for _, tt := range tests {
+		got := defaults.inherit(tt.settings)
+		if diff := cmp.Diff(tt.want, got, cmp.AllowUnexported(jobSettings{})); diff != "" {
+			t.Errorf("%s: settings mismatch (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
+
+func TestCrontinuous_TeamDefaultsTimezone(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 10 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	defaults := &memTeamDefaultsStore{}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithTeamDefaults(defaults))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	nextHour := func() int {
+		jobs := c.cron.Jobs()
+		if len(jobs) != 1 {
+			t.Fatalf("want 1 job scheduled, got %d", len(jobs))
+		}
+		return jobs[0].Schedule.Next(time.Now()).In(loc).Hour()
+	}
+
+	if err := c.SaveTeamDefaults(TeamDefaults{TeamID: "t1", Timezone: loc.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if h := nextHour(); h != 10 {
+		t.Errorf("want the entry scheduled at 10:00 in the time zone of the team, got %d:00", h)
+	}
+	if got := defaults.defaults["t1"].Timezone; got != loc.String() {
+		t.Errorf("want the defaults persisted, got time zone %q", got)
+	}
+
+	// The defaults are read from the store when the entries are reloaded.
+	if err := c.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if h := nextHour(); h != 10 {
+		t.Errorf("want the time zone of the team after restarting, got %d:00", h)
+	}
+
+	if err := c.RemoveTeamDefaults("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.TeamDefaults("t1"); err != ErrTeamDefaultsNotFound {
+		t.Errorf("want ErrTeamDefaultsNotFound, got %v", err)
+	}
+	if err := c.RemoveTeamDefaults("t1"); err != ErrTeamDefaultsNotFound {
+		t.Errorf("want ErrTeamDefaultsNotFound removing twice, got %v", err)
+	}
+	next := c.cron.Jobs()[0].Schedule.Next(time.Now())
+	if next.Hour() != 10 {
+		t.Errorf("want the entry scheduled at 10:00 in the local time zone, got %v", next)
+	}
+}
+
+func TestCrontinuous_TeamDefaultsConcurrency(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	creator := &mockScanCreator{creator: func(programID, teamID string) error {
+		started <- programID
+		<-release
+		return nil
+	}}
+	defaults := &memTeamDefaultsStore{defaults: map[string]TeamDefaults{
+		"t1": {TeamID: "t1", Concurrency: ConcurrencyForbid},
+	}}
+	c := NewCrontinuous(Config{}, logrus.New(), creator, store, nil, store, WithTeamDefaults(defaults))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan job not executed")
+	}
+	if err := c.RunEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		skipped, err := c.Executions(ExecutionFilter{Status: ExecutionSkipped, SkipReason: SkipOverlap})
+		return err == nil && len(skipped) == 1
+	})
+	close(release)
+	select {
+	case id := <-started:
+		t.Errorf("want the overlapping execution skipped, got %s executed", id)
+	default:
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+// TeamEntries defines the scan and report entries of a team.
+type TeamEntries struct {
+	TeamID  string        `json:"team_id" yaml:"team_id"`
+	Scans   []ScanEntry   `json:"scans" yaml:"scans"`
+	Reports []ReportEntry `json:"reports" yaml:"reports"`
+}
+
+// EntriesOfTeams returns the scan and report entries of the given teams,
+// sorted by ID, in the order of the teams. The repeated teams are returned
+// once, and the teams without entries are returned with empty lists.
+func (c *Crontinuous) EntriesOfTeams(teamIDs []string) []TeamEntries {
+	teams := []TeamEntries{}
+	index := map[string]int{}
+	for _, id := range teamIDs {
+		if _, ok := index[id]; ok {
+			continue
+		}
+		index[id] = len(teams)
+		teams = append(teams, TeamEntries{TeamID: id, Scans: []ScanEntry{}, Reports: []ReportEntry{}})
+	}
+	// The entries of all the teams are collected in a single pass over
+	// every type, so the cost does not grow with the number of teams.
+	for _, e := range c.scans.all() {
+		if i, ok := index[e.GetTeamID()]; ok {
+			teams[i].Scans = append(teams[i].Scans, e.(ScanEntry))
+		}
+	}
+	for _, e := range c.reports.all() {
+		if i, ok := index[e.GetTeamID()]; ok {
+			teams[i].Reports = append(teams[i].Reports, e.(ReportEntry))
+		}
+	}
+	return teams
+}
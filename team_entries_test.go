@@ -0,0 +1,44 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCrontinuous_EntriesOfTeams(t *testing.T) {
+	store := &mockCronStore{}
+	c := newTestCrontinuous(Config{}, store, map[string]ScanEntry{
+		"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+		"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"},
+		"p3": {ProgramID: "p3", TeamID: "t1", CronSpec: "0 3 * * *"},
+	}, store, map[string]ReportEntry{
+		"t1": {TeamID: "t1", CronSpec: "0 4 * * 1"},
+		"t3": {TeamID: "t3", CronSpec: "0 5 * * 1"},
+	})
+
+	got := c.EntriesOfTeams([]string{"t2", "t1", "t4", "t2"})
+	want := []TeamEntries{
+		{
+			TeamID:  "t2",
+			Scans:   []ScanEntry{{ProgramID: "p2", TeamID: "t2", CronSpec: "0 2 * * *"}},
+			Reports: []ReportEntry{},
+		},
+		{
+			TeamID: "t1",
+			Scans: []ScanEntry{
+				{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"},
+				{ProgramID: "p3", TeamID: "t1", CronSpec: "0 3 * * *"},
+			},
+			Reports: []ReportEntry{{TeamID: "t1", CronSpec: "0 4 * * 1"}},
+		},
+		{TeamID: "t4", Scans: []ScanEntry{}, Reports: []ReportEntry{}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("entries mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	S3TeamScansCrontabFilename = "teamScansCrontab.json"
+)
+
+// ProgramLister defines the service needed by the crontinuous component
+// in order to find the programs of a team when a team scan entry is executed.
+type ProgramLister interface {
+	ListPrograms(teamID string) ([]string, error)
+}
+
+// ContextProgramLister is implemented by the program listers
+// that support aborting the listing.
+type ContextProgramLister interface {
+	ListProgramsContext(ctx context.Context, teamID string) ([]string, error)
+}
+
+func listPrograms(ctx context.Context, pl ProgramLister, teamID string) ([]string, error) {
+	if cpl, ok := pl.(ContextProgramLister); ok {
+		return cpl.ListProgramsContext(ctx, teamID)
+	}
+	return pl.ListPrograms(teamID)
+}
+
+// programCountTimeout is the maximum time spent listing the programs of a
+// team to estimate the scans created by its team scan entries.
+const programCountTimeout = 10 * time.Second
+
+// teamPrograms holds the number of programs of the teams found by the last
+// execution of their team scan entries, or listed to estimate the scans
+// created by them.
+type teamPrograms struct {
+	mux    sync.Mutex
+	counts map[string]int
+}
+
+func (tp *teamPrograms) record(teamID string, n int) {
+	tp.mux.Lock()
+	defer tp.mux.Unlock()
+
+	if tp.counts == nil {
+		tp.counts = map[string]int{}
+	}
+	tp.counts[teamID] = n
+}
+
+func (tp *teamPrograms) get(teamID string) (int, bool) {
+	tp.mux.Lock()
+	defer tp.mux.Unlock()
+
+	n, ok := tp.counts[teamID]
+	return n, ok
+}
+
+// teamProgramCount returns the number of scans created by an execution of
+// a team scan entry of the given team, that is, the number of programs of
+// the team found by the last execution of its team scan entries or, if they
+// were not executed yet, listed from vulcan-api. An execution is counted as
+// one scan if the programs can not be listed.
+func (c *Crontinuous) teamProgramCount(teamID string) int {
+	if n, ok := c.teamPrograms.get(teamID); ok {
+		return n
+	}
+	if c.programLister == nil {
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), programCountTimeout)
+	defer cancel()
+	programs, err := listPrograms(ctx, c.programLister, teamID)
+	if err != nil {
+		c.log.WithError(err).WithField("team_id", teamID).Warn("Error listing the programs of the team")
+		return 1
+	}
+	c.teamPrograms.record(teamID, len(programs))
+	return len(programs)
+}
+
+// TeamScanEntry defines the data stored by a team scan cron entry.
+// When executed, a scan is created for every active program of the team,
+// so programs created after the entry are covered automatically.
+type TeamScanEntry struct {
+	TeamID   string `json:"team_id" yaml:"team_id"`
+	CronSpec string `json:"cron_spec" yaml:"cron_spec"`
+
+	EntrySettings `yaml:",inline"`
+}
+
+func (e TeamScanEntry) GetID() string {
+	return e.TeamID
+}
+func (e TeamScanEntry) GetTeamID() string {
+	return e.TeamID
+}
+func (e TeamScanEntry) GetType() CronType {
+	return TeamScanCronType
+}
+func (e TeamScanEntry) GetCronSpec() string {
+	return e.CronSpec
+}
+func (e TeamScanEntry) Validate() error {
+	if e.TeamID == "" {
+		return ErrMalformedEntry
+	}
+	if err := e.EntrySettings.validate(); err != nil {
+		return err
+	}
+	return validateCronSpec(e.CronSpec)
+}
+
+type teamScanJob struct {
+	id            string
+	teamID        string
+	programLister ProgramLister
+	programs      *teamPrograms
+	scanCreator   ScanCreator
+	log           *logrus.Entry
+}
+
+func (j *teamScanJob) team() string {
+	return j.teamID
+}
+
+func (j *teamScanJob) cronType() CronType {
+	return TeamScanCronType
+}
+
+func (j *teamScanJob) entryID() string {
+	return j.id
+}
+
+func (j *teamScanJob) run(ctx context.Context) error {
+	log := j.log.WithFields(executionFields(ctx))
+	log.Info("Executing Team Scan Job")
+	programs, err := listPrograms(ctx, j.programLister, j.teamID)
+	if err != nil {
+		log.Error("Error Listing Team Programs", err)
+		return err
+	}
+	j.programs.record(j.teamID, len(programs))
+	var failed int
+	for _, p := range programs {
+		if ctx.Err() != nil {
+			log.Error("Team Scan Job Aborted", ctx.Err())
+			return ctx.Err()
+		}
+		err := createScan(ctx, j.scanCreator, p, j.teamID)
+		if err != nil {
+			failed++
+			log.WithField("program", p).Error("Error Creating Team Program Scan", err)
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"programs": len(programs),
+		"failed":   failed,
+	}).Info("Executed Team Scan Job")
+	if failed > 0 {
+		return fmt.Errorf("creating %d of %d team program scans failed", failed, len(programs))
+	}
+	return nil
+}
+
+func (c *Crontinuous) newTeamScanJob(e TeamScanEntry) entryJob {
+	return c.newEntryJob(&teamScanJob{
+		id:            e.GetID(),
+		teamID:        e.TeamID,
+		programLister: c.programLister,
+		programs:      &c.teamPrograms,
+		scanCreator:   c.scanCreator,
+		log:           logrus.New().WithFields(logrus.Fields{"job": e.TeamID}),
+	}, jobSettings{
+		timeout:      e.ExecutionTimeout,
+		pingURL:      e.PingURL,
+		alerting:     e.Alerting,
+		jitter:       e.Jitter,
+		windows:      e.Windows,
+		windowPolicy: e.WindowPolicy,
+	})
+}
@@ -0,0 +1,330 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+)
+
+// S3TemplatesFilename is the key of the object storing the templates.
+const S3TemplatesFilename = "templates.json"
+
+var (
+	// ErrTemplatesDisabled indicates no template store is configured.
+	ErrTemplatesDisabled = errors.New("ErrorTemplatesDisabled")
+	// ErrTemplateNotFound indicates the template does not exist.
+	ErrTemplateNotFound = errors.New("ErrorTemplateNotFound")
+	// ErrMalformedTemplate indicates the template is not valid.
+	ErrMalformedTemplate = errors.New("ErrorMalformedTemplate")
+	// ErrTemplateInUse indicates the template can not be removed
+	// because there are entries referencing it.
+	ErrTemplateInUse = errors.New("ErrorTemplateInUse")
+)
+
+// templateNameRe matches the valid names of the templates,
+// e.g. nightly-deep-scan.
+var templateNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Template defines a named schedule and presets shared by many entries. The
+// entries referencing a template take from it their cron spec, execution
+// timeout, jitter and alerting preferences.
+type Template struct {
+	Name     string `json:"name" yaml:"name"`
+	CronSpec string `json:"cron_spec" yaml:"cron_spec"`
+	// Jitter delays every execution of the entries by a duration lower than
+	// it, derived from the ID of each entry, so the entries sharing the spec
+	// do not start at once.
+	Jitter           Duration       `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	ExecutionTimeout Duration       `json:"execution_timeout,omitempty" yaml:"execution_timeout,omitempty"`
+	Alerting         *AlertSettings `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+}
+
+// Validate returns ErrMalformedTemplate if the template is not valid.
+func (t Template) Validate() error {
+	if !templateNameRe.MatchString(t.Name) {
+		return ErrMalformedTemplate
+	}
+	if validateCronSpec(t.CronSpec) != nil ||
+		validateJitter(t.Jitter) != nil ||
+		validateExecutionTimeout(t.ExecutionTimeout) != nil ||
+		validateAlertSettings(t.Alerting) != nil {
+		return ErrMalformedTemplate
+	}
+	return nil
+}
+
+// TemplateStore defines the services needed to persist the templates.
+type TemplateStore interface {
+	GetTemplates() (map[string]Template, error)
+	SaveTemplates(templates map[string]Template) error
+}
+
+// WithTemplates enables the templates, persisting them in the given store.
+func WithTemplates(store TemplateStore) Option {
+	return func(c *Crontinuous) {
+		c.templateStore = store
+	}
+}
+
+// loadTemplates returns the templates, reading them from the store the first
+// time. It must be called holding the templates lock. The templates are not
+// read on Start, so an unreachable store only affects the operations that
+// need them.
+func (c *Crontinuous) loadTemplates() (map[string]Template, error) {
+	if c.templates != nil {
+		return c.templates, nil
+	}
+	templates, err := c.templateStore.GetTemplates()
+	if err != nil {
+		return nil, err
+	}
+	if templates == nil {
+		templates = map[string]Template{}
+	}
+	c.templates = templates
+	return templates, nil
+}
+
+// Templates returns the templates sorted by name.
+func (c *Crontinuous) Templates() ([]Template, error) {
+	if c.templateStore == nil {
+		return nil, ErrTemplatesDisabled
+	}
+	c.templatesMux.Lock()
+	defer c.templatesMux.Unlock()
+
+	loaded, err := c.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	templates := []Template{}
+	for _, t := range loaded {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates, nil
+}
+
+// Template returns the template with the given name.
+func (c *Crontinuous) Template(name string) (Template, error) {
+	if c.templateStore == nil {
+		return Template{}, ErrTemplatesDisabled
+	}
+	c.templatesMux.Lock()
+	defer c.templatesMux.Unlock()
+
+	templates, err := c.loadTemplates()
+	if err != nil {
+		return Template{}, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return Template{}, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+// SaveTemplate creates or updates the given template. The entries referencing
+// the template are updated with its spec and presets and rescheduled. It
+// returns the changes performed to the entries.
+func (c *Crontinuous) SaveTemplate(t Template, opts ...SaveOption) ([]Change, error) {
+	if err := c.checkWritable(); err != nil {
+		return nil, err
+	}
+	if c.templateStore == nil {
+		return nil, ErrTemplatesDisabled
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	var o saveOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The lock is held while the entries are updated, so the entries
+	// saved meanwhile take the new version of the template.
+	c.templatesMux.Lock()
+	defer c.templatesMux.Unlock()
+
+	current, err := c.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	templates := make(map[string]Template, len(current)+1)
+	for name, tpl := range current {
+		templates[name] = tpl
+	}
+	templates[t.Name] = t
+	if err := c.templateStore.SaveTemplates(templates); err != nil {
+		return nil, err
+	}
+	c.templates = templates
+
+	changes := []Change{}
+	for _, typ := range c.cronTypes() {
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return nil, err
+		}
+		typeChanges, err := set.updateAll(func(e CronEntry) (CronEntry, bool, error) {
+			if entryTemplate(e) != t.Name {
+				return nil, false, nil
+			}
+			updated := withTemplate(e, t)
+			return updated, !reflect.DeepEqual(updated, e), nil
+		})
+		if err != nil {
+			return changes, err
+		}
+		c.recordHistory(o.changedBy, typeChanges...)
+		c.reschedule(typ, set, typeChanges)
+		changes = append(changes, typeChanges...)
+	}
+	return changes, nil
+}
+
+// reschedule replaces the jobs of the entries updated by the given changes.
+func (c *Crontinuous) reschedule(typ CronType, set entries, changes []Change) {
+	for _, ch := range changes {
+		id := cronJobID(typ, ch.ID)
+		if !c.isTeamWhitelisted(typ, ch.Desired.GetTeamID()) {
+			c.cron.RemoveJob(id)
+			continue
+		}
+		s, err := c.entrySchedule(ch.Desired)
+		if err != nil {
+			c.log.Errorf("error parsing the spec of %s entry %s: %v", typ, ch.ID, err)
+			continue
+		}
+		job, err := set.job(ch.ID)
+		if err != nil {
+			// The entry was removed meanwhile.
+			continue
+		}
+		c.scheduleJob(s, job, id)
+	}
+}
+
+// RemoveTemplate removes the template with the given name. It returns
+// ErrTemplateInUse if there are entries referencing it.
+func (c *Crontinuous) RemoveTemplate(name string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+	if c.templateStore == nil {
+		return ErrTemplatesDisabled
+	}
+
+	c.templatesMux.Lock()
+	defer c.templatesMux.Unlock()
+
+	current, err := c.loadTemplates()
+	if err != nil {
+		return err
+	}
+	if _, ok := current[name]; !ok {
+		return ErrTemplateNotFound
+	}
+	for _, typ := range c.cronTypes() {
+		entries, err := c.GetEntries(typ)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if entryTemplate(e) == name {
+				return ErrTemplateInUse
+			}
+		}
+	}
+
+	templates := make(map[string]Template, len(current))
+	for n, tpl := range current {
+		if n != name {
+			templates[n] = tpl
+		}
+	}
+	if err := c.templateStore.SaveTemplates(templates); err != nil {
+		return err
+	}
+	c.templates = templates
+	return nil
+}
+
+// applyTemplate returns the given entry with the spec and presets of the
+// template it references, if any.
+func (c *Crontinuous) applyTemplate(e CronEntry) (CronEntry, error) {
+	name := entryTemplate(e)
+	if name == "" {
+		return e, nil
+	}
+	if c.templateStore == nil {
+		return nil, entryError(e, ErrTemplatesDisabled)
+	}
+	c.templatesMux.Lock()
+	defer c.templatesMux.Unlock()
+
+	templates, err := c.loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	t, ok := templates[name]
+	if !ok {
+		return nil, entryError(e, ErrTemplateNotFound)
+	}
+	return withTemplate(e, t), nil
+}
+
+// applyTemplates is like applyTemplate for all the given entries.
+func (c *Crontinuous) applyTemplates(entries []CronEntry) ([]CronEntry, error) {
+	applied := make([]CronEntry, len(entries))
+	for i, e := range entries {
+		var err error
+		if applied[i], err = c.applyTemplate(e); err != nil {
+			return nil, err
+		}
+	}
+	return applied, nil
+}
+
+// applyManifestTemplates returns a copy of the given manifest with the
+// templates applied to its entries.
+func (c *Crontinuous) applyManifestTemplates(m Manifest) (Manifest, error) {
+	applied := Manifest{}
+	for _, e := range m.Scans {
+		a, err := c.applyTemplate(e)
+		if err != nil {
+			return Manifest{}, err
+		}
+		applied.Scans = append(applied.Scans, a.(ScanEntry))
+	}
+	for _, e := range m.Reports {
+		a, err := c.applyTemplate(e)
+		if err != nil {
+			return Manifest{}, err
+		}
+		applied.Reports = append(applied.Reports, a.(ReportEntry))
+	}
+	return applied, nil
+}
+
+// entryTemplate returns the name of the template the given entry references.
+func entryTemplate(e CronEntry) string {
+	return entrySettings(e).Template
+}
+
+// withTemplate returns the given entry with the spec and presets of the given
+// template.
+func withTemplate(e CronEntry, t Template) CronEntry {
+	s := entrySettings(e)
+	s.Jitter, s.ExecutionTimeout, s.Alerting = t.Jitter, t.ExecutionTimeout, t.Alerting
+	return withCronSpec(withEntrySettings(e, s), t.CronSpec)
+}
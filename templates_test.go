@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+type memTemplateStore struct {
+	templates map[string]Template
+}
+
+func (s *memTemplateStore) GetTemplates() (map[string]Template, error) {
+	return s.templates, nil
+}
+
+func (s *memTemplateStore) SaveTemplates(templates map[string]Template) error {
+	s.templates = templates
+	return nil
+}
+
+func TestCrontinuous_Templates(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	templates := &memTemplateStore{}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithTemplates(templates))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	nightly := Template{Name: "nightly-deep-scan", CronSpec: "H H(0-5) * * *", Jitter: Duration(time.Minute)}
+	if _, err := c.SaveTemplate(nightly); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SaveTemplate(Template{Name: "Invalid Name", CronSpec: "* * * * *"}); err != ErrMalformedTemplate {
+		t.Errorf("want ErrMalformedTemplate, got %v", err)
+	}
+
+	err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 * * *", EntrySettings: EntrySettings{Template: "unknown"}})
+	if !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("want ErrTemplateNotFound, got %v", err)
+	}
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{Template: nightly.Name}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := got.(ScanEntry); e.CronSpec != nightly.CronSpec || e.Jitter != nightly.Jitter {
+		t.Errorf("want the spec and jitter of the template, got %+v", e)
+	}
+
+	nightly.CronSpec = "0 3 * * *"
+	changes, err := c.SaveTemplate(nightly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].ID != "p1" {
+		t.Fatalf("want the entry p1 changed, got %+v", changes)
+	}
+	got, err = c.GetEntryByID(ScanCronType, "p1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec := got.GetCronSpec(); spec != nightly.CronSpec {
+		t.Errorf("want the updated spec of the template, got %q", spec)
+	}
+	if spec := store.scanEntries["p1"].CronSpec; spec != nightly.CronSpec {
+		t.Errorf("want the updated spec persisted, got %q", spec)
+	}
+
+	if err := c.RemoveTemplate(nightly.Name); err != ErrTemplateInUse {
+		t.Errorf("want ErrTemplateInUse, got %v", err)
+	}
+	if err := c.RemoveEntry(ScanCronType, "p1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveTemplate(nightly.Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Template(nightly.Name); err != ErrTemplateNotFound {
+		t.Errorf("want ErrTemplateNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// Duration is a time.Duration encoded as a string like "1h30m".
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// validateExecutionTimeout returns ErrMalformedEntry
+// if the given execution timeout is negative.
+func validateExecutionTimeout(d Duration) error {
+	if d < 0 {
+		return ErrMalformedEntry
+	}
+	return nil
+}
+
+// timeoutJob wraps the job of an entry so its execution is cancelled when it
+// lasts more than the timeout.
+type timeoutJob struct {
+	entryJob
+	timeout  time.Duration
+	timeouts *typeCounts
+	log      *logrus.Logger
+}
+
+// run cancels the context of the job when the timeout expires and returns
+// without waiting for the job to finish, so jobs not honouring the
+// cancellation do not pile up in the cron.
+func (j *timeoutJob) run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+
+	// Buffered so the job can finish after the timeout
+	// without blocking.
+	done := make(chan error, 1)
+	go func() {
+		done <- j.entryJob.run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		// The job was cancelled, it will return soon.
+		return <-done
+	}
+	j.timeouts.inc(j.cronType())
+	j.log.WithFields(executionFields(ctx)).WithFields(logrus.Fields{
+		"team":    j.team(),
+		"type":    j.cronType().String(),
+		"timeout": j.timeout.String(),
+	}).Error("Job execution timed out")
+	return ctx.Err()
+}
+
+// withTimeout wraps the given job so it is cancelled after the given timeout
+// or, if it is zero, after the configured ExecutionTimeout.
+func (c *Crontinuous) withTimeout(job entryJob, timeout Duration) entryJob {
+	t := time.Duration(timeout)
+	if t <= 0 {
+		t = c.settings().ExecutionTimeout
+	}
+	if t <= 0 {
+		return job
+	}
+	return &timeoutJob{entryJob: job, timeout: t, timeouts: &c.timeouts, log: c.log}
+}
+
+// typeCounts counts events, like the executions of jobs that
+// timed out, by type of entry.
+type typeCounts struct {
+	mux    sync.Mutex
+	counts map[CronType]int
+}
+
+func (t *typeCounts) inc(typ CronType) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	if t.counts == nil {
+		t.counts = map[CronType]int{}
+	}
+	t.counts[typ]++
+}
+
+func (t *typeCounts) snapshot() map[CronType]int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	counts := map[CronType]int{}
+	for typ, n := range t.counts {
+		counts[typ] = n
+	}
+	return counts
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// stuckScanCreator never returns, ignoring the cancellation of the context.
+type stuckScanCreator struct {
+	release chan struct{}
+}
+
+func (s *stuckScanCreator) CreateScan(scanID, teamID string) error {
+	<-s.release
+	return nil
+}
+
+func TestTimeoutJob(t *testing.T) {
+	creator := &stuckScanCreator{release: make(chan struct{})}
+	defer close(creator.release)
+	c := NewCrontinuous(Config{ExecutionTimeout: time.Hour}, logrus.New(), creator, nil, nil, nil)
+
+	job := c.newScanJob(ScanEntry{ProgramID: "p1", TeamID: "t1", EntrySettings: EntrySettings{ExecutionTimeout: Duration(50 * time.Millisecond)}})
+	done := make(chan struct{})
+	go func() {
+		c.wrapJob(job).Run()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("job not freed after its timeout")
+	}
+	if got := c.timeouts.snapshot()[ScanCronType]; got != 1 {
+		t.Errorf("timeouts got %d, want 1", got)
+	}
+	if n := c.running.total(); n != 0 {
+		t.Errorf("running jobs got %d, want 0", n)
+	}
+}
+
+func TestDuration_Encoding(t *testing.T) {
+	e := ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 * * * *", EntrySettings: EntrySettings{ExecutionTimeout: Duration(90 * time.Minute)}}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"program_id":"p1","team_id":"t1","cron_spec":"0 * * * *","execution_timeout":"1h30m0s"}`
+	if string(data) != want {
+		t.Errorf("JSON got %s, want %s", data, want)
+	}
+
+	var got ScanEntry
+	if err := yaml.Unmarshal([]byte("program_id: p1\nexecution_timeout: 1h30m\n"), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ExecutionTimeout != e.ExecutionTimeout {
+		t.Errorf("YAML decoded timeout got %v, want %v", got.ExecutionTimeout, e.ExecutionTimeout)
+	}
+}
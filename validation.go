@@ -0,0 +1,103 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// validationTimeout is the maximum time the validation of the references of
+// a new entry can last.
+const validationTimeout = 30 * time.Second
+
+var (
+	// ErrTeamNotFound indicates the team of the entry does not exist in
+	// vulcan-api.
+	ErrTeamNotFound = errors.New("ErrorTeamNotFound")
+
+	// ErrProgramNotFound indicates the program of the entry does not exist
+	// in vulcan-api or does not belong to the team of the entry.
+	ErrProgramNotFound = errors.New("ErrorProgramNotFound")
+)
+
+// Validator checks the teams and programs referenced by the entries exist.
+// The methods return ErrTeamNotFound and ErrProgramNotFound if they do not.
+type Validator interface {
+	GetTeamContext(ctx context.Context, teamID string) (Team, error)
+	GetProgramContext(ctx context.Context, teamID, programID string) (Program, error)
+}
+
+// WithValidator makes SaveEntry, and the bulk creations and replacements,
+// check, when creating an entry, that its team and, for the scan entries, its
+// program exist in the given validator and the program belongs to the team.
+func WithValidator(v Validator) Option {
+	return func(c *Crontinuous) {
+		c.validator = v
+	}
+}
+
+// validateNewReferences checks the references of the given entries of the
+// given type that are new, or moved to another team, with the IDs they would
+// get now. Only those are validated, so the rest can still be updated while
+// vulcan-api is unavailable. The entries are saved according to the given
+// overwrite policies, one per entry, or always if they are nil, so the
+// existing entries that are kept are not validated either. The validation
+// calls vulcan-api, so it must be called before taking the lock of any set.
+func (c *Crontinuous) validateNewReferences(ctx context.Context, typ CronType, entries []CronEntry, policies []OverwritePolicy) error {
+	if c.validator == nil {
+		return nil
+	}
+	ids := c.newEntryIdentifier(typ)
+	for i, e := range entries {
+		identified, err := ids.identify(e)
+		if err != nil {
+			return entryError(e, err)
+		}
+		current, err := c.GetEntryByID(typ, identified.GetID())
+		if err != nil && !errors.Is(err, ErrScheduleNotFound) {
+			return err
+		}
+		kept := policies != nil && policies[i] != OverwriteAll
+		if err == nil && (kept || current.GetTeamID() == e.GetTeamID()) {
+			continue
+		}
+		if err := c.validateReferences(ctx, e); err != nil {
+			return entryError(e, err)
+		}
+	}
+	return nil
+}
+
+// validateReferences checks the team and program referenced by the given
+// entry exist. The command entries are only checked if they have a team.
+func (c *Crontinuous) validateReferences(ctx context.Context, entry CronEntry) error {
+	teamID := entry.GetTeamID()
+	if _, ok := entry.(CommandEntry); ok && teamID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+
+	if _, err := c.validator.GetTeamContext(ctx, teamID); err != nil {
+		if errors.Is(err, ErrTeamNotFound) {
+			return err
+		}
+		return fmt.Errorf("validating team %s: %w", teamID, err)
+	}
+	e, ok := entry.(ScanEntry)
+	if !ok {
+		return nil
+	}
+	if _, err := c.validator.GetProgramContext(ctx, teamID, e.ProgramID); err != nil {
+		if errors.Is(err, ErrProgramNotFound) {
+			return err
+		}
+		return fmt.Errorf("validating program %s of team %s: %w", e.ProgramID, teamID, err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// mockValidator validates the entries against the given programs per team.
+type mockValidator struct {
+	programs map[string][]string
+	err      error
+}
+
+func (v *mockValidator) GetTeamContext(ctx context.Context, teamID string) (Team, error) {
+	if v.err != nil {
+		return Team{}, v.err
+	}
+	if _, ok := v.programs[teamID]; !ok {
+		return Team{}, ErrTeamNotFound
+	}
+	return Team{ID: teamID}, nil
+}
+
+func (v *mockValidator) GetProgramContext(ctx context.Context, teamID, programID string) (Program, error) {
+	for _, p := range v.programs[teamID] {
+		if p == programID {
+			return Program{ID: programID}, nil
+		}
+	}
+	return Program{}, ErrProgramNotFound
+}
+
+func TestCrontinuous_ValidateReferences(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	commands := &mockCommandCronStore{entries: map[string]CommandEntry{}}
+	v := &mockValidator{programs: map[string][]string{"t1": {"p1"}, "t2": {"p2"}}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store,
+		WithValidator(v), WithCommands(commands, t.TempDir()))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	tests := []struct {
+		name  string
+		typ   CronType
+		entry CronEntry
+		want  error
+	}{
+		{"Scan", ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1"}, nil},
+		{"ProgramOfOtherTeam", ScanCronType, ScanEntry{ProgramID: "p2", TeamID: "t1"}, ErrProgramNotFound},
+		{"UnknownTeam", ScanCronType, ScanEntry{ProgramID: "p3", TeamID: "t3"}, ErrTeamNotFound},
+		{"MovedToUnknownTeam", ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t3"}, ErrTeamNotFound},
+		{"Report", ReportCronType, ReportEntry{TeamID: "t2"}, nil},
+		{"ReportOfUnknownTeam", ReportCronType, ReportEntry{TeamID: "t3"}, ErrTeamNotFound},
+		{"CommandWithoutTeam", CommandCronType, CommandEntry{ID: "c1", Script: "task.sh"}, nil},
+		{"CommandOfUnknownTeam", CommandCronType, CommandEntry{ID: "c2", Script: "task.sh", TeamID: "t3"}, ErrTeamNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.entry
+			switch e := entry.(type) {
+			case ScanEntry:
+				e.CronSpec = "0 0 1 1 *"
+				entry = e
+			case ReportEntry:
+				e.CronSpec = "0 12 1 1 *"
+				entry = e
+			case CommandEntry:
+				e.CronSpec = "0 0 1 1 *"
+				entry = e
+			}
+			if err := c.SaveEntry(tt.typ, entry); !errors.Is(err, tt.want) {
+				t.Errorf("want error %v, got %v", tt.want, err)
+			}
+		})
+	}
+	if _, ok := store.scanEntries["p3"]; ok {
+		t.Errorf("want the invalid entries not stored, got %+v", store.scanEntries)
+	}
+	if _, err := c.TransferScanEntry("p1", "t3"); !errors.Is(err, ErrTeamNotFound) {
+		t.Errorf("want the transfer to an unknown team rejected, got %v", err)
+	}
+
+	// The updates keeping the team are not validated.
+	v.err = errors.New("vulcan-api unavailable")
+	if err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 1 * * *"}); err != nil {
+		t.Errorf("want the update saved, got %v", err)
+	}
+	err := c.SaveEntry(ScanCronType, ScanEntry{ProgramID: "p4", TeamID: "t1", CronSpec: "0 1 * * *"})
+	if !errors.Is(err, v.err) {
+		t.Errorf("want the error of the validator, got %v", err)
+	}
+}
+
+func TestCrontinuous_ValidateReferencesBulk(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	v := &mockValidator{programs: map[string][]string{"t1": {"p1", "p2"}}}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithValidator(v))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	valid := ScanEntry{ProgramID: "p2", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	unknown := ScanEntry{ProgramID: "p3", TeamID: "t1", CronSpec: "0 0 1 1 *"}
+	moved := ScanEntry{ProgramID: "p1", TeamID: "t3", CronSpec: "0 0 1 1 *"}
+	tests := []struct {
+		name string
+		save func() error
+		want error
+	}{
+		{"Create", func() error {
+			return c.BulkCreate(ScanCronType, []CronEntry{valid, unknown}, []bool{false, false})
+		}, ErrProgramNotFound},
+		{"Overwrite", func() error {
+			return c.BulkCreate(ScanCronType, []CronEntry{valid, moved}, []bool{false, true})
+		}, ErrTeamNotFound},
+		{"Replace", func() error {
+			_, err := c.BulkReplace(ScanCronType, []CronEntry{valid, unknown}, BulkModeSync)
+			return err
+		}, ErrProgramNotFound},
+		{"ReplaceMoving", func() error {
+			_, err := c.BulkReplace(ScanCronType, []CronEntry{moved}, BulkModeReplaceTeam)
+			return err
+		}, ErrTeamNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.save(); !errors.Is(err, tt.want) {
+				t.Errorf("want error %v, got %v", tt.want, err)
+			}
+			if len(store.scanEntries) != 1 || store.scanEntries["p1"].TeamID != "t1" {
+				t.Errorf("want the entries not stored, got %+v", store.scanEntries)
+			}
+		})
+	}
+
+	// The existing entries that are kept are not validated.
+	if err := c.BulkCreate(ScanCronType, []CronEntry{valid, moved}, []bool{false, false}); err != nil {
+		t.Errorf("want the valid entries created, got %v", err)
+	}
+}
+
+func TestCrontinuous_ValidateReferencesContext(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries:   map[string]ScanEntry{},
+		reportEntries: map[string]ReportEntry{},
+	}
+	v := &blockingValidator{}
+	c := NewCrontinuous(Config{}, logrus.New(), nil, store, nil, store, WithValidator(v))
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.SaveEntryContext(ctx, ScanCronType, ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want the validation cancelled, got %v", err)
+	}
+	err = c.BulkCreateWithOverwriteContext(ctx, ScanCronType, []CronEntry{
+		ScanEntry{ProgramID: "p1", TeamID: "t1", CronSpec: "0 0 1 1 *"},
+	}, []OverwritePolicy{OverwriteAll})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want the bulk validation cancelled, got %v", err)
+	}
+}
+
+// blockingValidator blocks the validations until their context is done.
+type blockingValidator struct{}
+
+func (blockingValidator) GetTeamContext(ctx context.Context, teamID string) (Team, error) {
+	<-ctx.Done()
+	return Team{}, ctx.Err()
+}
+
+func (blockingValidator) GetProgramContext(ctx context.Context, teamID, programID string) (Program, error) {
+	<-ctx.Done()
+	return Program{}, ctx.Err()
+}
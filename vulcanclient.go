@@ -6,8 +6,11 @@ package crontinuous
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
@@ -18,6 +21,11 @@ import (
 const (
 	createScanURL        = "%s/v1/teams/%s/scans"
 	sendReportURL        = "%s/v1/teams/%s/report/digest"
+	listProgramsURL      = "%s/v1/teams/%s/programs"
+	listTeamsURL         = "%s/v1/teams"
+	getTeamURL           = "%s/v1/teams/%s"
+	getProgramURL        = "%s/v1/teams/%s/programs/%s"
+	healthcheckURL       = "%s/v1/healthcheck"
 	bearerHeaderTemplate = "Bearer %s"
 )
 
@@ -28,52 +36,358 @@ type ScanRequest struct {
 	RequestedBy   string    `json:"requested_by"`
 }
 
+// ReportRequestVersion is the version of the ReportRequest payload, so
+// vulcan-api can tell the fields it carries.
+const ReportRequestVersion = 1
+
+// ReportRequest contains the payload sent to the API report endpoint when
+// VulcanClient.ReportSchedule is set, so the digests can be correlated with
+// the entries that produced them.
+type ReportRequest struct {
+	Version int `json:"version"`
+	// EntryID is the ID of the report entry, empty if the report is not
+	// sent by the job of an entry.
+	EntryID       string    `json:"entry_id,omitempty"`
+	ScheduledTime time.Time `json:"scheduled_time"`
+}
+
+// createdResource is the response of vulcan-api to the requests creating a
+// resource, like a scan.
+type createdResource struct {
+	ID string `json:"id"`
+}
+
+// Team defines a team of vulcan-api.
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Program defines a program of a team of vulcan-api.
+type Program struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+}
+
 // VulcanClient provides functionality for interacting with the vulcan-api.
 type VulcanClient struct {
 	VulcanAPI   string
 	VulcanUser  string
 	VulcanToken string
+	// UserAgent identifies crontinuous in the requests, defaults to
+	// ServiceName/Version. The entry and the execution of the job
+	// performing the request, if any, are appended to it.
+	UserAgent string
+	// TeamCredentials, if not nil, holds the credentials the requests for
+	// the teams are performed with instead of VulcanUser and VulcanToken.
+	TeamCredentials *TeamCredentialsCache
+	// RequireTeamCredentials makes the requests for the teams without
+	// credentials fail with ErrNoTeamCredentials instead of being performed
+	// with VulcanUser and VulcanToken.
+	RequireTeamCredentials bool
+	// ReportSchedule makes the report sendings carry a ReportRequest
+	// payload. The versions of vulcan-api not expecting it may reject it,
+	// so it must only be set for the ones supporting it.
+	ReportSchedule bool
+	// HTTPClient is the client used to perform the requests, so they can
+	// be instrumented or faked, http.DefaultClient is used if nil.
+	HTTPClient *http.Client
 }
 
 // CreateScan creates a scan by calling vulcan-api
 func (c *VulcanClient) CreateScan(scanID, teamID string) error {
+	return c.CreateScanContext(context.Background(), scanID, teamID)
+}
+
+// CreateScanContext creates a scan by calling vulcan-api, retries
+// are aborted when the given context is done.
+func (c *VulcanClient) CreateScanContext(ctx context.Context, scanID, teamID string) error {
+	_, err := c.CreateScanResult(ctx, scanID, teamID)
+	return err
+}
+
+// CreateScanResult creates a scan by calling vulcan-api and returns the ID
+// of the scan created, empty if vulcan-api does not return it. Retries are
+// aborted when the given context is done.
+func (c *VulcanClient) CreateScanResult(ctx context.Context, scanID, teamID string) (string, error) {
+	creds, err := c.credentials(teamID)
+	if err != nil {
+		return "", err
+	}
 	scanMsg := ScanRequest{
 		ProgramID:     scanID,
 		ScheduledTime: time.Now(),
-		RequestedBy:   c.VulcanUser,
+		RequestedBy:   creds.User,
 	}
 
+	var created createdResource
 	url := fmt.Sprintf(createScanURL, c.VulcanAPI, teamID)
 	operation := func() error {
-		return c.performReq(http.MethodPost, url, scanMsg)
+		return c.doReq(ctx, creds, http.MethodPost, url, scanMsg, http.StatusCreated, &created)
 	}
 
-	return backoff.Retry(operation, backoff.NewExponentialBackOff())
+	err = backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	return created.ID, err
 }
 
 // SendReport triggers a report sending operation by calling vulcan-api.
 func (c *VulcanClient) SendReport(teamID string) error {
+	return c.SendReportContext(context.Background(), teamID)
+}
+
+// SendReportContext triggers a report sending operation by calling
+// vulcan-api, retries are aborted when the given context is done.
+func (c *VulcanClient) SendReportContext(ctx context.Context, teamID string) error {
+	_, err := c.SendReportResult(ctx, teamID)
+	return err
+}
+
+// SendReportResult triggers a report sending operation by calling
+// vulcan-api and returns the ID of the job sending the report, empty if
+// vulcan-api does not return it. Retries are aborted when the given context
+// is done.
+func (c *VulcanClient) SendReportResult(ctx context.Context, teamID string) (string, error) {
+	creds, err := c.credentials(teamID)
+	if err != nil {
+		return "", err
+	}
+	var payload interface{}
+	if c.ReportSchedule {
+		payload = reportRequest(ctx)
+	}
+	var created createdResource
 	url := fmt.Sprintf(sendReportURL, c.VulcanAPI, teamID)
 	operation := func() error {
-		return c.performReq(http.MethodPost, url, nil)
+		return c.doReq(ctx, creds, http.MethodPost, url, payload, http.StatusCreated, &created)
+	}
+
+	err = backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	return created.ID, err
+}
+
+// reportRequest returns the payload of a report sending identifying the
+// execution carried by the given context, if any, or scheduled now.
+func reportRequest(ctx context.Context) ReportRequest {
+	r := ReportRequest{Version: ReportRequestVersion, ScheduledTime: time.Now()}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		r.EntryID = e.EntryID
+		if !e.ScheduledAt.IsZero() {
+			r.ScheduledTime = e.ScheduledAt
+		}
+	}
+	return r
+}
+
+// ListPrograms returns the IDs of the enabled programs of a team by calling vulcan-api.
+func (c *VulcanClient) ListPrograms(teamID string) ([]string, error) {
+	return c.ListProgramsContext(context.Background(), teamID)
+}
+
+// ListProgramsContext returns the IDs of the enabled programs of a team by
+// calling vulcan-api, retries are aborted when the given context is done.
+func (c *VulcanClient) ListProgramsContext(ctx context.Context, teamID string) ([]string, error) {
+	var programs []struct {
+		ID       string `json:"id"`
+		Disabled bool   `json:"disabled"`
+	}
+
+	creds, err := c.credentials(teamID)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf(listProgramsURL, c.VulcanAPI, teamID)
+	operation := func() error {
+		return c.doReq(ctx, creds, http.MethodGet, url, nil, http.StatusOK, &programs)
+	}
+	if err := backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx)); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, p := range programs {
+		if p.Disabled {
+			continue
+		}
+		ids = append(ids, p.ID)
+	}
+	return ids, nil
+}
+
+// ListTeams returns the IDs of all the teams by calling vulcan-api.
+func (c *VulcanClient) ListTeams() ([]string, error) {
+	return c.ListTeamsContext(context.Background())
+}
+
+// ListTeamsContext returns the IDs of all the teams by calling
+// vulcan-api, retries are aborted when the given context is done.
+func (c *VulcanClient) ListTeamsContext(ctx context.Context) ([]string, error) {
+	var teams []struct {
+		ID string `json:"id"`
+	}
+
+	url := fmt.Sprintf(listTeamsURL, c.VulcanAPI)
+	operation := func() error {
+		return c.doReq(ctx, c.globalCredentials(), http.MethodGet, url, nil, http.StatusOK, &teams)
+	}
+	if err := backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx)); err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, t := range teams {
+		ids = append(ids, t.ID)
+	}
+	return ids, nil
+}
+
+// GetTeam returns the team with the given ID by calling vulcan-api, or
+// ErrTeamNotFound if it does not exist.
+func (c *VulcanClient) GetTeam(teamID string) (Team, error) {
+	return c.GetTeamContext(context.Background(), teamID)
+}
+
+// GetTeamContext returns the team with the given ID by calling vulcan-api,
+// or ErrTeamNotFound if it does not exist, retries are aborted when the given
+// context is done.
+func (c *VulcanClient) GetTeamContext(ctx context.Context, teamID string) (Team, error) {
+	var team Team
+
+	creds, err := c.credentials(teamID)
+	if err != nil {
+		return Team{}, err
+	}
+	url := fmt.Sprintf(getTeamURL, c.VulcanAPI, teamID)
+	operation := func() error {
+		return c.doReq(ctx, creds, http.MethodGet, url, nil, http.StatusOK, &team)
 	}
+	err = backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if isStatus(err, http.StatusNotFound) {
+		return Team{}, ErrTeamNotFound
+	}
+	if err != nil {
+		return Team{}, err
+	}
+	return team, nil
+}
 
-	return backoff.Retry(operation, backoff.NewExponentialBackOff())
+// GetProgram returns the program with the given ID of the given team by
+// calling vulcan-api, or ErrProgramNotFound if it does not exist or does not
+// belong to the team.
+func (c *VulcanClient) GetProgram(teamID, programID string) (Program, error) {
+	return c.GetProgramContext(context.Background(), teamID, programID)
 }
 
-func (c *VulcanClient) performReq(httpMethod, url string, payload interface{}) error {
+// GetProgramContext returns the program with the given ID of the given team
+// by calling vulcan-api, or ErrProgramNotFound if it does not exist or does
+// not belong to the team, retries are aborted when the given context is done.
+func (c *VulcanClient) GetProgramContext(ctx context.Context, teamID, programID string) (Program, error) {
+	var program Program
+
+	creds, err := c.credentials(teamID)
+	if err != nil {
+		return Program{}, err
+	}
+	url := fmt.Sprintf(getProgramURL, c.VulcanAPI, teamID, programID)
+	operation := func() error {
+		return c.doReq(ctx, creds, http.MethodGet, url, nil, http.StatusOK, &program)
+	}
+	err = backoff.Retry(operation, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+	if isStatus(err, http.StatusNotFound) {
+		return Program{}, ErrProgramNotFound
+	}
+	if err != nil {
+		return Program{}, err
+	}
+	return program, nil
+}
+
+// credentials returns the credentials of the requests for the given team.
+func (c *VulcanClient) credentials(teamID string) (TeamCredentials, error) {
+	if c.TeamCredentials == nil {
+		return c.globalCredentials(), nil
+	}
+	creds, ok, err := c.TeamCredentials.Get(teamID)
+	if err != nil {
+		return TeamCredentials{}, err
+	}
+	if ok {
+		if creds.User == "" {
+			creds.User = c.VulcanUser
+		}
+		return creds, nil
+	}
+	if c.RequireTeamCredentials {
+		return TeamCredentials{}, ErrNoTeamCredentials
+	}
+	return c.globalCredentials(), nil
+}
+
+// globalCredentials returns the credentials of the requests not related to
+// a team.
+func (c *VulcanClient) globalCredentials() TeamCredentials {
+	return TeamCredentials{User: c.VulcanUser, Token: c.VulcanToken}
+}
+
+// userAgent returns the user agent of the requests performed with the given
+// context.
+func (c *VulcanClient) userAgent(ctx context.Context) string {
+	ua := c.UserAgent
+	if ua == "" {
+		ua = ServiceName + "/" + Version
+	}
+	if e, ok := ExecutionFromContext(ctx); ok {
+		ua = fmt.Sprintf("%s (%s %s; execution %s)", ua, e.Type, e.EntryID, e.ID)
+	}
+	return ua
+}
+
+// Healthy returns an error if the healthcheck of vulcan-api does not
+// succeed. The request is not retried.
+func (c *VulcanClient) Healthy(ctx context.Context) error {
+	url := fmt.Sprintf(healthcheckURL, c.VulcanAPI)
+	err := c.doReq(ctx, c.globalCredentials(), http.MethodGet, url, nil, http.StatusOK, nil)
+	var perm *backoff.PermanentError
+	if errors.As(err, &perm) {
+		return perm.Err
+	}
+	return err
+}
+
+// httpClient returns the client the requests are performed with.
+func (c *VulcanClient) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return c.HTTPClient
+}
+
+// doReq performs a request against vulcan-api with the given credentials
+// expecting the given status code in the response. If out is not nil the
+// response body, if any, is decoded into it.
+func (c *VulcanClient) doReq(ctx context.Context, creds TeamCredentials, httpMethod, url string, payload interface{}, wantStatus int, out interface{}) error {
 	content, err := json.Marshal(payload)
 	if err != nil {
 		return &backoff.PermanentError{Err: err}
 	}
-	req, err := http.NewRequest(httpMethod, url, bytes.NewReader(content))
+	var body io.Reader = bytes.NewReader(content)
+	if httpMethod == http.MethodGet {
+		body = nil
+	}
+	req, err := http.NewRequestWithContext(ctx, httpMethod, url, body)
 	if err != nil {
 		return &backoff.PermanentError{Err: err}
 	}
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf(bearerHeaderTemplate, c.VulcanToken))
+	req.Header.Add("Authorization", fmt.Sprintf(bearerHeaderTemplate, creds.Token))
+	req.Header.Set("User-Agent", c.userAgent(ctx))
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	req.Header.Set(RequestIDHeader, requestID)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		// This is the only error that can be
 		// related to network issues, so don't
@@ -83,14 +397,15 @@ func (c *VulcanClient) performReq(httpMethod, url string, payload interface{}) e
 	}
 	defer resp.Body.Close() // nolint
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != wantStatus {
 		var content string
 		b, err := ioutil.ReadAll(resp.Body)
 		if err == nil {
 			content = string(b)
 		}
-		err = fmt.Errorf("Error. Response status %s. Content: %s", resp.Status, content)
+		err = &statusError{code: resp.StatusCode, status: resp.Status, content: content}
 		if resp.StatusCode >= 500 {
+			recordServerError(ctx)
 			// If HTTP communication was successful
 			// but an error was produced in the server,
 			// return non permanent err so retries
@@ -101,5 +416,29 @@ func (c *VulcanClient) performReq(httpMethod, url string, payload interface{}) e
 			Err: err,
 		}
 	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+			return &backoff.PermanentError{Err: err}
+		}
+	}
 	return nil
 }
+
+// statusError is returned by doReq when vulcan-api responds with an
+// unexpected status code.
+type statusError struct {
+	code    int
+	status  string
+	content string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("Error. Response status %s. Content: %s", e.status, e.content)
+}
+
+// isStatus returns true if the given error is a response of vulcan-api with
+// the given status code.
+func isStatus(err error, code int) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == code
+}
@@ -5,11 +5,17 @@ Copyright 2020 Adevinta
 package crontinuous
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
@@ -86,16 +92,19 @@ func TestVulcanClient_CreateScan(t *testing.T) {
 }
 
 func TestVulcanClient_SendReport(t *testing.T) {
+	scheduledAt := time.Date(2020, 6, 8, 8, 0, 0, 0, time.UTC)
 	type fields struct {
-		VulcanUser  string
-		VulcanToken string
+		VulcanUser     string
+		VulcanToken    string
+		ReportSchedule bool
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		teamID  string
-		handler func(w http.ResponseWriter, r *http.Request) string
-		wantErr bool
+		name      string
+		fields    fields
+		teamID    string
+		execution *Execution
+		handler   func(w http.ResponseWriter, r *http.Request) string
+		wantErr   bool
 	}{
 		{
 			name: "SendsAProperSendReportRequest",
@@ -103,11 +112,61 @@ func TestVulcanClient_SendReport(t *testing.T) {
 				VulcanUser:  "user",
 				VulcanToken: "token",
 			},
-			teamID: "2",
+			teamID:    "2",
+			execution: &Execution{Type: ReportCronType, EntryID: "2", ID: "e1", ScheduledAt: scheduledAt},
 			handler: func(w http.ResponseWriter, r *http.Request) string {
 				if r.URL.Path != "/v1/teams/2/report/digest" {
 					return "wrong path:" + r.URL.Path
 				}
+				// The older versions of vulcan-api get no payload.
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					return err.Error()
+				}
+				if string(body) != "null" {
+					return "unexpected payload: " + string(body)
+				}
+				w.WriteHeader(http.StatusCreated)
+				return ""
+			},
+		},
+		{
+			name: "SendsTheScheduleOfTheExecution",
+			fields: fields{
+				VulcanUser:     "user",
+				VulcanToken:    "token",
+				ReportSchedule: true,
+			},
+			teamID:    "2",
+			execution: &Execution{Type: ReportCronType, EntryID: "r1", ID: "e1", ScheduledAt: scheduledAt},
+			handler: func(w http.ResponseWriter, r *http.Request) string {
+				var req ReportRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					return err.Error()
+				}
+				diff := cmp.Diff(ReportRequest{Version: ReportRequestVersion, EntryID: "r1", ScheduledTime: scheduledAt}, req)
+				if diff == "" {
+					w.WriteHeader(http.StatusCreated)
+				}
+				return diff
+			},
+		},
+		{
+			name: "SendsTheScheduleWithoutExecution",
+			fields: fields{
+				VulcanUser:     "user",
+				VulcanToken:    "token",
+				ReportSchedule: true,
+			},
+			teamID: "2",
+			handler: func(w http.ResponseWriter, r *http.Request) string {
+				var req ReportRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					return err.Error()
+				}
+				if req.Version != ReportRequestVersion || req.EntryID != "" || req.ScheduledTime.IsZero() {
+					return fmt.Sprintf("unexpected payload: %+v", req)
+				}
 				w.WriteHeader(http.StatusCreated)
 				return ""
 			},
@@ -123,11 +182,16 @@ func TestVulcanClient_SendReport(t *testing.T) {
 			defer s.Close()
 
 			c := &VulcanClient{
-				VulcanAPI:   s.URL,
-				VulcanUser:  tt.fields.VulcanUser,
-				VulcanToken: tt.fields.VulcanToken,
+				VulcanAPI:      s.URL,
+				VulcanUser:     tt.fields.VulcanUser,
+				VulcanToken:    tt.fields.VulcanToken,
+				ReportSchedule: tt.fields.ReportSchedule,
 			}
-			err := c.SendReport(tt.teamID)
+			ctx := context.Background()
+			if tt.execution != nil {
+				ctx = context.WithValue(ctx, executionKey{}, *tt.execution)
+			}
+			err := c.SendReportContext(ctx, tt.teamID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("VulcanClient.SendReport() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -138,6 +202,68 @@ func TestVulcanClient_SendReport(t *testing.T) {
 	}
 }
 
+func TestVulcanClient_ListPrograms(t *testing.T) {
+	tests := []struct {
+		name    string
+		teamID  string
+		handler func(w http.ResponseWriter, r *http.Request) string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "ReturnsEnabledPrograms",
+			teamID: "2",
+			handler: func(w http.ResponseWriter, r *http.Request) string {
+				if r.URL.Path != "/v1/teams/2/programs" {
+					return "wrong path:" + r.URL.Path
+				}
+				if r.Method != http.MethodGet {
+					return "wrong method:" + r.Method
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`[{"id":"p1"},{"id":"p2","disabled":true},{"id":"p3","disabled":false}]`)) // nolint
+				return ""
+			},
+			want: []string{"p1", "p3"},
+		},
+		{
+			name:   "ReturnsErrorOnNotFound",
+			teamID: "3",
+			handler: func(w http.ResponseWriter, r *http.Request) string {
+				w.WriteHeader(http.StatusNotFound)
+				return ""
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff string
+			s := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					diff = tt.handler(w, r)
+				}))
+			defer s.Close()
+
+			c := &VulcanClient{
+				VulcanAPI:   s.URL,
+				VulcanUser:  "user",
+				VulcanToken: "token",
+			}
+			got, err := c.ListPrograms(tt.teamID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VulcanClient.ListPrograms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff != "" {
+				t.Errorf(diff)
+			}
+			if d := cmp.Diff(tt.want, got); d != "" {
+				t.Errorf("programs got!=want, diff %s", d)
+			}
+		})
+	}
+}
+
 func TestVulcanClient_TestBackoff(t *testing.T) {
 	// Variable used to count consecutive requests
 	// to handler
@@ -201,3 +327,140 @@ func TestVulcanClient_TestBackoff(t *testing.T) {
 		})
 	}
 }
+
+func TestVulcanClient_RequestIdentification(t *testing.T) {
+	var got []http.Header
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer s.Close()
+
+	c := &VulcanClient{VulcanAPI: s.URL, VulcanUser: "user", VulcanToken: "token", UserAgent: "crontinuous/1.0"}
+	job := &executionJob{entryJob: &scanJob{id: "p1", programID: "p1", teamID: "t1", scanCreator: c, log: logrus.New().WithFields(nil)}}
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	if err := job.run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CreateScan("p1", "t1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("want 2 requests, got %d", len(got))
+	}
+	ua := got[0].Get("User-Agent")
+	if !strings.HasPrefix(ua, "crontinuous/1.0 (scan p1; execution ") {
+		t.Errorf("unexpected user agent of the execution %q", ua)
+	}
+	if id := got[0].Get(RequestIDHeader); id != "req-1" {
+		t.Errorf("want request ID of the context, got %q", id)
+	}
+	if ua := got[1].Get("User-Agent"); ua != "crontinuous/1.0" {
+		t.Errorf("unexpected user agent %q", ua)
+	}
+	if id := got[1].Get(RequestIDHeader); id == "" {
+		t.Error("want new request ID, got none")
+	}
+}
+
+func TestVulcanClient_GetTeamAndProgram(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/teams/t1":
+			w.Write([]byte(`{"id":"t1","name":"Team 1"}`)) // nolint
+		case "/v1/teams/t1/programs/p1":
+			w.Write([]byte(`{"id":"p1","name":"Program 1","disabled":true}`)) // nolint
+		case "/v1/teams/t1/programs/p2", "/v1/teams/t2":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer s.Close()
+	c := &VulcanClient{VulcanAPI: s.URL, VulcanUser: "user", VulcanToken: "token"}
+
+	team, err := c.GetTeam("t1")
+	if err != nil || team != (Team{ID: "t1", Name: "Team 1"}) {
+		t.Errorf("want team t1, got %+v, %v", team, err)
+	}
+	if _, err := c.GetTeam("t2"); err != ErrTeamNotFound {
+		t.Errorf("want ErrTeamNotFound, got %v", err)
+	}
+	if _, err := c.GetTeam("t3"); err == nil || err == ErrTeamNotFound {
+		t.Errorf("want the forbidden response returned, got %v", err)
+	}
+
+	program, err := c.GetProgram("t1", "p1")
+	if err != nil || program != (Program{ID: "p1", Name: "Program 1", Disabled: true}) {
+		t.Errorf("want program p1, got %+v, %v", program, err)
+	}
+	if _, err := c.GetProgram("t1", "p2"); err != ErrProgramNotFound {
+		t.Errorf("want ErrProgramNotFound, got %v", err)
+	}
+}
+
+// roundTripperFunc performs the requests with the given function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestVulcanClient_HTTPClient(t *testing.T) {
+	var requested []string
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requested = append(requested, r.Method+" "+r.URL.String())
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[{"id":"p1"}]`)),
+			Header:     http.Header{},
+			Request:    r,
+		}, nil
+	})
+	c := &VulcanClient{
+		VulcanAPI:   "http://vulcan-api.invalid",
+		VulcanToken: "token",
+		HTTPClient:  &http.Client{Transport: transport},
+	}
+	got, err := c.ListPrograms("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cmp.Diff([]string{"p1"}, got); d != "" {
+		t.Errorf("programs got!=want, diff %s", d)
+	}
+	want := []string{"GET http://vulcan-api.invalid/v1/teams/t1/programs"}
+	if d := cmp.Diff(want, requested); d != "" {
+		t.Errorf("want the requests performed with the given client, diff %s", d)
+	}
+}
+
+func TestVulcanClient_CreateScanResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wantID string
+	}{
+		{"ReturnsTheScanID", `{"id": "s1", "program_id": "p1"}`, "s1"},
+		{"AcceptsEmptyResponses", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(tt.body)) // nolint
+			}))
+			defer s.Close()
+
+			c := &VulcanClient{VulcanAPI: s.URL, VulcanUser: "user", VulcanToken: "token"}
+			id, err := c.CreateScanResult(context.Background(), "p1", "t1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.wantID {
+				t.Errorf("want scan ID %q, got %q", tt.wantID, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// warmUp defines the period after the start in which the activations of
+// the jobs are skipped.
+type warmUp struct {
+	period  time.Duration
+	gradual bool
+
+	mux   sync.Mutex
+	start time.Time
+}
+
+// begin starts the warm-up at the given time.
+func (w *warmUp) begin(t time.Time) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	w.start = t
+}
+
+// active returns true if the given time is in the warm-up period. During a
+// gradual warm-up some of the activations are already executed.
+func (w *warmUp) active(t time.Time) bool {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	return t.Sub(w.start) < w.period
+}
+
+// allows returns true if the activation at the given time of the job with
+// the given ID must be executed. If the warm-up is gradual the jobs are
+// allowed progressively, in an order derived from their ID, so the share of
+// jobs allowed grows linearly from none at the start to all of them at the
+// end of the period.
+func (w *warmUp) allows(jobID string, t time.Time) bool {
+	w.mux.Lock()
+	elapsed := t.Sub(w.start)
+	w.mux.Unlock()
+
+	if elapsed >= w.period {
+		return true
+	}
+	if !w.gradual || elapsed < 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(jobID)) // nolint
+	bucket := float64(h.Sum32()%1000) / 1000
+	return bucket < float64(elapsed)/float64(w.period)
+}
+
+// warmingJob skips the activations of a job during the warm-up.
+type warmingJob struct {
+	Job
+	id     string
+	warmUp *warmUp
+	log    *logrus.Logger
+	// skipped, if not nil, is called with the error of the skipped
+	// activations.
+	skipped func(error)
+}
+
+func (j *warmingJob) Run() {
+	if !j.warmUp.allows(j.id, time.Now()) {
+		j.log.WithField("job", j.id).Info("Skipping job, warming up after the start")
+		if j.skipped != nil {
+			j.skipped(skipJob(SkipWarmUp, "warming up after the start"))
+		}
+		return
+	}
+	j.Job.Run()
+}
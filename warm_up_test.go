@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestWarmUp(t *testing.T) {
+	start := time.Date(2020, 6, 1, 2, 0, 0, 0, time.UTC)
+	ids := make([]string, 200)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", i)
+	}
+	allowed := func(w *warmUp, t time.Time) int {
+		var n int
+		for _, id := range ids {
+			if w.allows(id, t) {
+				n++
+			}
+		}
+		return n
+	}
+
+	w := &warmUp{period: 10 * time.Minute}
+	w.begin(start)
+	if n := allowed(w, start.Add(9*time.Minute)); n != 0 {
+		t.Errorf("want no jobs allowed during the warm-up, got %d", n)
+	}
+	if n := allowed(w, start.Add(10*time.Minute)); n != len(ids) {
+		t.Errorf("want all the jobs allowed after the warm-up, got %d", n)
+	}
+
+	w = &warmUp{period: 10 * time.Minute, gradual: true}
+	w.begin(start)
+	prev := allowed(w, start)
+	if prev != 0 {
+		t.Errorf("want no jobs allowed at the start, got %d", prev)
+	}
+	for m := 1; m <= 10; m++ {
+		n := allowed(w, start.Add(time.Duration(m)*time.Minute))
+		if n < prev {
+			t.Errorf("minute %d: want the jobs allowed to grow, got %d after %d", m, n, prev)
+		}
+		prev = n
+	}
+	if n := allowed(w, start.Add(5*time.Minute)); n < 60 || n > 140 {
+		t.Errorf("want about half of the jobs allowed in the middle of the warm-up, got %d", n)
+	}
+	if prev != len(ids) {
+		t.Errorf("want all the jobs allowed after the warm-up, got %d", prev)
+	}
+}
+
+func TestCrontinuous_WarmUp(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 2 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	c := NewCrontinuous(Config{WarmUp: time.Hour}, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	jobs := c.cron.Jobs()
+	if len(jobs) != 1 {
+		t.Fatalf("want 1 job scheduled, got %d", len(jobs))
+	}
+	wj, ok := jobs[0].Job.(*warmingJob)
+	if !ok {
+		t.Fatalf("want the scheduled job warming up, got %T", jobs[0].Job)
+	}
+	job := &countingJob{}
+	wj.Job = job
+	wj.Run()
+	if job.runs != 0 {
+		t.Error("want the activation skipped during the warm-up")
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// WebhookTimestampHeader is the header holding the Unix time
+	// a signed webhook request was sent at.
+	WebhookTimestampHeader = "X-Crontinuous-Timestamp"
+	// WebhookSignatureHeader is the header holding the signature of a
+	// signed webhook request, in the format sha256=<hex encoded HMAC>.
+	WebhookSignatureHeader = "X-Crontinuous-Signature"
+)
+
+var (
+	// ErrInvalidWebhookSignature indicates the signature of a webhook
+	// request is missing, malformed or does not match its payload.
+	ErrInvalidWebhookSignature = errors.New("ErrorInvalidWebhookSignature")
+
+	// ErrExpiredWebhookSignature indicates a webhook request was signed
+	// too long ago, so it may be replayed.
+	ErrExpiredWebhookSignature = errors.New("ErrorExpiredWebhookSignature")
+)
+
+// SignWebhook sets in the given headers the timestamp and the signature of a
+// webhook request with the given body sent at the given time. The signature
+// is the HMAC-SHA256, with the given secret, of the timestamp, a dot and the
+// body, so the timestamp can not be changed to replay old requests.
+func SignWebhook(h http.Header, secret, body []byte, at time.Time) {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	h.Set(WebhookTimestampHeader, ts)
+	h.Set(WebhookSignatureHeader, "sha256="+hex.EncodeToString(webhookMAC(secret, ts, body)))
+}
+
+// VerifyWebhook checks the signature in the given headers of a webhook
+// request with the given body was made with the given secret, and that the
+// request was sent no longer than maxAge before now. A maxAge of zero
+// disables the check of the age. It returns ErrInvalidWebhookSignature or
+// ErrExpiredWebhookSignature if the request can not be trusted.
+func VerifyWebhook(h http.Header, secret, body []byte, now time.Time, maxAge time.Duration) error {
+	ts := h.Get(WebhookTimestampHeader)
+	sent, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	sig := h.Get(WebhookSignatureHeader)
+	if !strings.HasPrefix(sig, "sha256=") {
+		return ErrInvalidWebhookSignature
+	}
+	mac, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil || !hmac.Equal(mac, webhookMAC(secret, ts, body)) {
+		return ErrInvalidWebhookSignature
+	}
+	if age := now.Sub(time.Unix(sent, 0)); maxAge > 0 && (age > maxAge || age < -maxAge) {
+		return ErrExpiredWebhookSignature
+	}
+	return nil
+}
+
+func webhookMAC(secret []byte, ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(ts))  // nolint
+	m.Write([]byte(".")) // nolint
+	m.Write(body)        // nolint
+	return m.Sum(nil)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"entry_id":"p1"}`)
+	sent := time.Unix(1591000000, 0)
+	signed := func() http.Header {
+		h := http.Header{}
+		SignWebhook(h, secret, body, sent)
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header func() http.Header
+		secret []byte
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{
+			name:   "Valid",
+			header: signed,
+			now:    sent.Add(time.Minute),
+		},
+		{
+			name:   "WrongSecret",
+			header: signed,
+			secret: []byte("other"),
+			now:    sent,
+			want:   ErrInvalidWebhookSignature,
+		},
+		{
+			name:   "TamperedBody",
+			header: signed,
+			body:   []byte(`{"entry_id":"p2"}`),
+			now:    sent,
+			want:   ErrInvalidWebhookSignature,
+		},
+		{
+			name: "TamperedTimestamp",
+			header: func() http.Header {
+				h := signed()
+				h.Set(WebhookTimestampHeader, "1591000600")
+				return h
+			},
+			now:  sent,
+			want: ErrInvalidWebhookSignature,
+		},
+		{
+			name:   "Unsigned",
+			header: func() http.Header { return http.Header{} },
+			now:    sent,
+			want:   ErrInvalidWebhookSignature,
+		},
+		{
+			name:   "Expired",
+			header: signed,
+			now:    sent.Add(10 * time.Minute),
+			want:   ErrExpiredWebhookSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, b := secret, body
+			if tt.secret != nil {
+				s = tt.secret
+			}
+			if tt.body != nil {
+				b = tt.body
+			}
+			if err := VerifyWebhook(tt.header(), s, b, tt.now, 5*time.Minute); err != tt.want {
+				t.Errorf("got error %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// WhitelistRule defines an item of a teams whitelist: a team and, optionally,
+// the time window in which the jobs of its entries are allowed to execute.
+//
+// The rules are written as <team>[@<window>], where the window is formed by
+// the week days, the hours or both separated by a slash, for instance:
+//
+//	team-a@sat-sun
+//	team-b@mon,wed,fri/22:00-06:00
+//	team-c@01:00-05:00
+//
+// The days are ranges or lists of mon, tue, wed, thu, fri, sat and sun. The
+// hours are in the local time of crontinuous and, when the end is before the
+// start, the window ends on the day after the listed ones. A team can have
+// several rules, its jobs are allowed to execute in any of their windows.
+type WhitelistRule struct {
+	TeamID string
+	// Window is nil if the jobs of the team are allowed at any time.
+	Window *TimeWindow
+}
+
+// TimeWindow defines a period of time that repeats every week.
+type TimeWindow struct {
+	// Days holds the week days the window starts in, all if empty.
+	Days []time.Weekday
+	// From and To are the minutes since midnight of the start and the end
+	// of the window. The window lasts the whole day if they are equal.
+	From, To int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWhitelistRule parses an item of a teams whitelist. See WhitelistRule
+// for its format.
+func ParseWhitelistRule(s string) (WhitelistRule, error) {
+	teamID, window, hasWindow := strings.Cut(strings.TrimSpace(s), "@")
+	if teamID == "" {
+		return WhitelistRule{}, fmt.Errorf("whitelist rule %q has no team ID", s)
+	}
+	rule := WhitelistRule{TeamID: teamID}
+	if !hasWindow {
+		return rule, nil
+	}
+	w, err := parseTimeWindow(window)
+	if err != nil {
+		return WhitelistRule{}, fmt.Errorf("whitelist rule %q: %w", s, err)
+	}
+	rule.Window = &w
+	return rule, nil
+}
+
+func parseTimeWindow(s string) (TimeWindow, error) {
+	var w TimeWindow
+	days, hours, hasHours := strings.Cut(s, "/")
+	if !hasHours && strings.Contains(days, ":") {
+		days, hours, hasHours = "", days, true
+	}
+	if days == "" && !hasHours {
+		return w, fmt.Errorf("empty time window")
+	}
+	if days != "" {
+		var err error
+		if w.Days, err = parseWeekdays(days); err != nil {
+			return w, err
+		}
+	}
+	if hasHours {
+		from, to, ok := strings.Cut(hours, "-")
+		if !ok {
+			return w, fmt.Errorf("invalid hours %q", hours)
+		}
+		var err error
+		if w.From, err = parseClock(from); err != nil {
+			return w, err
+		}
+		if w.To, err = parseClock(to); err != nil {
+			return w, err
+		}
+	}
+	return w, nil
+}
+
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, item := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		from, ok := weekdays[strings.ToLower(first)]
+		if !ok {
+			return nil, fmt.Errorf("invalid week day %q", first)
+		}
+		if !isRange {
+			days = append(days, from)
+			continue
+		}
+		to, ok := weekdays[strings.ToLower(last)]
+		if !ok {
+			return nil, fmt.Errorf("invalid week day %q", last)
+		}
+		// The ranges can wrap around the end of the week, e.g. fri-mon.
+		for d := from; ; d = (d + 1) % 7 {
+			days = append(days, d)
+			if d == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// parseClock returns the minutes since midnight of the given HH:MM time.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Contains returns true if the given time is in the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	switch {
+	case w.From == w.To:
+		return w.hasDay(day)
+	case w.From < w.To:
+		return m >= w.From && m < w.To && w.hasDay(day)
+	case m >= w.From:
+		return w.hasDay(day)
+	case m < w.To:
+		// The window started the day before.
+		return w.hasDay((day + 6) % 7)
+	}
+	return false
+}
+
+func (w TimeWindow) hasDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, wd := range w.Days {
+		if wd == d {
+			return true
+		}
+	}
+	return false
+}
+
+// teamsWhitelist holds the rules of the whitelist of a cron type by team.
+type teamsWhitelist struct {
+	enabled bool
+	rules   map[string][]WhitelistRule
+}
+
+// newTeamsWhitelist parses the given whitelist rules. The invalid rules are
+// logged and ignored, so the teams they refer to are not whitelisted.
+func newTeamsWhitelist(enabled bool, items []string, log *logrus.Logger) teamsWhitelist {
+	w := teamsWhitelist{enabled: enabled, rules: map[string][]WhitelistRule{}}
+	if !enabled {
+		return w
+	}
+	for _, item := range items {
+		rule, err := ParseWhitelistRule(item)
+		if err != nil {
+			log.Errorf("ignoring invalid whitelist rule: %v", err)
+			continue
+		}
+		w.rules[rule.TeamID] = append(w.rules[rule.TeamID], rule)
+	}
+	return w
+}
+
+// has returns true if the given team is in the whitelist, regardless of the
+// windows of its rules, so the jobs of its entries must be scheduled.
+func (w teamsWhitelist) has(teamID string) bool {
+	if !w.enabled {
+		return true
+	}
+	return len(w.rules[teamID]) > 0
+}
+
+// allows returns true if the jobs of the given team are allowed to execute
+// at the given time.
+func (w teamsWhitelist) allows(teamID string, t time.Time) bool {
+	if !w.enabled {
+		return true
+	}
+	for _, r := range w.rules[teamID] {
+		if r.Window == nil || r.Window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// windowedJob wraps the job of an entry so it is only executed inside the
+// time windows its team is whitelisted in.
+type windowedJob struct {
+	entryJob
+	whitelist teamsWhitelist
+	log       *logrus.Logger
+}
+
+func (j *windowedJob) run(ctx context.Context) error {
+	if !j.whitelist.allows(j.team(), time.Now()) {
+		j.log.WithFields(executionFields(ctx)).WithFields(logrus.Fields{
+			"team": j.team(),
+			"type": j.cronType().String(),
+		}).Info("Skipping job, out of the whitelist window of the team")
+		return skipJob(SkipWhitelist, "out of the whitelist window of the team")
+	}
+	return j.entryJob.run(ctx)
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedWhitelistRule indicates a rule of a proposed
+// teams whitelist can not be parsed.
+var ErrMalformedWhitelistRule = errors.New("ErrorMalformedWhitelistRule")
+
+// WhitelistProposal defines a change of the teams whitelists. A nil
+// whitelist keeps the current one.
+type WhitelistProposal struct {
+	ScanWhitelist   *WhitelistSnapshot `json:"scan_whitelist,omitempty" yaml:"scan_whitelist,omitempty"`
+	ReportWhitelist *WhitelistSnapshot `json:"report_whitelist,omitempty" yaml:"report_whitelist,omitempty"`
+}
+
+// WhitelistImpact defines the stored entries whose jobs would start or stop
+// being scheduled if a change of the teams whitelists was applied.
+type WhitelistImpact struct {
+	Scheduled   []WhitelistImpactEntry `json:"scheduled" yaml:"scheduled"`
+	Unscheduled []WhitelistImpactEntry `json:"unscheduled" yaml:"unscheduled"`
+}
+
+// WhitelistImpactEntry identifies an entry affected by a change of the
+// teams whitelists.
+type WhitelistImpactEntry struct {
+	Type   CronType `json:"type" yaml:"type"`
+	ID     string   `json:"id" yaml:"id"`
+	TeamID string   `json:"team_id" yaml:"team_id"`
+}
+
+// PreviewWhitelists returns the stored entries that would become scheduled
+// or unscheduled if the given whitelists replaced the current ones, without
+// applying them. The scan whitelist applies to the scan and team scan
+// entries. Only whether the teams are whitelisted is considered: changing
+// the windows of a team whitelisted before and after the change does not
+// schedule nor unschedule its entries. Unlike the configured whitelists,
+// the proposed ones are rejected with ErrMalformedWhitelistRule if any of
+// their rules can not be parsed.
+func (c *Crontinuous) PreviewWhitelists(p WhitelistProposal) (WhitelistImpact, error) {
+	impact := WhitelistImpact{
+		Scheduled:   []WhitelistImpactEntry{},
+		Unscheduled: []WhitelistImpactEntry{},
+	}
+	scan, err := proposedWhitelist(p.ScanWhitelist, c.whitelist(ScanCronType))
+	if err != nil {
+		return impact, err
+	}
+	report, err := proposedWhitelist(p.ReportWhitelist, c.whitelist(ReportCronType))
+	if err != nil {
+		return impact, err
+	}
+	proposed := map[CronType]teamsWhitelist{
+		ScanCronType:     scan,
+		TeamScanCronType: scan,
+		ReportCronType:   report,
+	}
+
+	for _, typ := range c.cronTypes() {
+		w, ok := proposed[typ]
+		if !ok {
+			continue
+		}
+		set, err := c.entrySet(typ)
+		if err != nil {
+			return impact, err
+		}
+		current := c.whitelist(typ)
+		for _, e := range set.all() {
+			before, after := current.has(e.GetTeamID()), w.has(e.GetTeamID())
+			if before == after {
+				continue
+			}
+			ie := WhitelistImpactEntry{Type: typ, ID: e.GetID(), TeamID: e.GetTeamID()}
+			if after {
+				impact.Scheduled = append(impact.Scheduled, ie)
+			} else {
+				impact.Unscheduled = append(impact.Unscheduled, ie)
+			}
+		}
+	}
+	return impact, nil
+}
+
+// proposedWhitelist parses the given proposed whitelist, returning the
+// current one if nil.
+func proposedWhitelist(s *WhitelistSnapshot, current teamsWhitelist) (teamsWhitelist, error) {
+	if s == nil {
+		return current, nil
+	}
+	w := teamsWhitelist{enabled: s.Enabled, rules: map[string][]WhitelistRule{}}
+	for _, item := range s.Rules {
+		rule, err := ParseWhitelistRule(item)
+		if err != nil {
+			return teamsWhitelist{}, fmt.Errorf("%w: %v", ErrMalformedWhitelistRule, err)
+		}
+		w.rules[rule.TeamID] = append(w.rules[rule.TeamID], rule)
+	}
+	return w, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2020 Adevinta
+*/
+
+package crontinuous
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseWhitelistRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    WhitelistRule
+		wantErr bool
+	}{
+		{rule: "t1", want: WhitelistRule{TeamID: "t1"}},
+		{
+			rule: "t1@sat-sun",
+			want: WhitelistRule{TeamID: "t1", Window: &TimeWindow{Days: []time.Weekday{time.Saturday, time.Sunday}}},
+		},
+		{
+			rule: "t1@fri-mon",
+			want: WhitelistRule{TeamID: "t1", Window: &TimeWindow{
+				Days: []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday},
+			}},
+		},
+		{
+			rule: "t1@mon,wed/22:00-06:30",
+			want: WhitelistRule{TeamID: "t1", Window: &TimeWindow{
+				Days: []time.Weekday{time.Monday, time.Wednesday}, From: 22 * 60, To: 6*60 + 30,
+			}},
+		},
+		{rule: "t1@01:00-05:00", want: WhitelistRule{TeamID: "t1", Window: &TimeWindow{From: 60, To: 5 * 60}}},
+		{rule: "@sat", wantErr: true},
+		{rule: "t1@", wantErr: true},
+		{rule: "t1@someday", wantErr: true},
+		{rule: "t1@sat/22:00", wantErr: true},
+		{rule: "t1@sat/25:00-26:00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseWhitelistRule(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("rule diff: %s", diff)
+			}
+		})
+	}
+}
+
+func TestTimeWindow_Contains(t *testing.T) {
+	// 2020-06-05 is a Friday.
+	friday := func(hour int) time.Time {
+		return time.Date(2020, 6, 5, hour, 0, 0, 0, time.UTC)
+	}
+	nights := TimeWindow{Days: []time.Weekday{time.Friday}, From: 22 * 60, To: 6 * 60}
+	tests := []struct {
+		name   string
+		window TimeWindow
+		t      time.Time
+		want   bool
+	}{
+		{name: "Day", window: TimeWindow{Days: []time.Weekday{time.Friday}}, t: friday(12), want: true},
+		{name: "OtherDay", window: TimeWindow{Days: []time.Weekday{time.Saturday}}, t: friday(12)},
+		{name: "Hours", window: TimeWindow{From: 60, To: 5 * 60}, t: friday(1), want: true},
+		{name: "HoursEnd", window: TimeWindow{From: 60, To: 5 * 60}, t: friday(5)},
+		{name: "OvernightStart", window: nights, t: friday(23), want: true},
+		{name: "OvernightNextDay", window: nights, t: friday(24 + 3), want: true},
+		{name: "OvernightPreviousDay", window: nights, t: friday(3)},
+		{name: "OvernightOut", window: nights, t: friday(12)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCrontinuous_SimulateWhitelistWindows(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 12 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 12 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1@sat-sun", "t2"}}
+	c := NewCrontinuous(cfg, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	// From Friday to Monday.
+	from := time.Date(2020, 6, 5, 0, 0, 0, 0, time.Local)
+	executions, err := c.Simulate(from, from.Add(4*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]time.Weekday{}
+	for _, e := range executions {
+		got[e.EntryID] = append(got[e.EntryID], e.Time.Weekday())
+	}
+	want := map[string][]time.Weekday{
+		"p1": {time.Saturday, time.Sunday},
+		"p2": {time.Friday, time.Saturday, time.Sunday, time.Monday},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("simulated executions diff: %s", diff)
+	}
+}
+
+func TestCrontinuous_PreviewWhitelists(t *testing.T) {
+	store := &mockCronStore{
+		scanEntries: map[string]ScanEntry{
+			"p1": {ProgramID: "p1", TeamID: "t1", CronSpec: "0 12 * * *"},
+			"p2": {ProgramID: "p2", TeamID: "t2", CronSpec: "0 12 * * *"},
+			"p3": {ProgramID: "p3", TeamID: "t3", CronSpec: "0 12 * * *"},
+		},
+		reportEntries: map[string]ReportEntry{
+			"t1": {TeamID: "t1", CronSpec: "0 8 * * 1"},
+		},
+	}
+	cfg := Config{EnableTeamsWhitelistScan: true, TeamsWhitelistScan: []string{"t1", "t2@sat-sun"}}
+	c := NewCrontinuous(cfg, logrus.New(), nil, store, nil, store)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop() // nolint
+
+	tests := []struct {
+		name     string
+		proposal WhitelistProposal
+		want     WhitelistImpact
+		wantErr  error
+	}{
+		{
+			name: "ScanRulesChanged",
+			proposal: WhitelistProposal{
+				ScanWhitelist: &WhitelistSnapshot{Enabled: true, Rules: []string{"t2", "t3@mon-fri"}},
+			},
+			want: WhitelistImpact{
+				Scheduled:   []WhitelistImpactEntry{{Type: ScanCronType, ID: "p3", TeamID: "t3"}},
+				Unscheduled: []WhitelistImpactEntry{{Type: ScanCronType, ID: "p1", TeamID: "t1"}},
+			},
+		},
+		{
+			name: "ReportWhitelistEnabled",
+			proposal: WhitelistProposal{
+				ScanWhitelist:   &WhitelistSnapshot{Enabled: false},
+				ReportWhitelist: &WhitelistSnapshot{Enabled: true, Rules: []string{"t2"}},
+			},
+			want: WhitelistImpact{
+				Scheduled:   []WhitelistImpactEntry{{Type: ScanCronType, ID: "p3", TeamID: "t3"}},
+				Unscheduled: []WhitelistImpactEntry{{Type: ReportCronType, ID: "t1", TeamID: "t1"}},
+			},
+		},
+		{
+			name: "Unchanged",
+			want: WhitelistImpact{Scheduled: []WhitelistImpactEntry{}, Unscheduled: []WhitelistImpactEntry{}},
+		},
+		{
+			name: "MalformedRule",
+			proposal: WhitelistProposal{
+				ScanWhitelist: &WhitelistSnapshot{Enabled: true, Rules: []string{"t1@someday"}},
+			},
+			wantErr: ErrMalformedWhitelistRule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.PreviewWhitelists(tt.proposal)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error got %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("impact diff: %s", diff)
+			}
+		})
+	}
+	if status := c.ScheduleStatus(ScanCronType, store.scanEntries["p1"]); !status.Scheduled {
+		t.Errorf("want the whitelists not applied, got %+v", status)
+	}
+}